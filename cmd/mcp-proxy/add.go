@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/client"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/mcpregistry"
+)
+
+// runAdd implements `mcp-proxy add <registry-name>`: it looks the server up
+// in the MCP registry, asks for the inputs it requires, checks that it starts,
+// writes its entry to the config, and returns the process exit code.
+func runAdd(args []string) int {
+	flags := flag.NewFlagSet("add", flag.ContinueOnError)
+	conf := registerConfigFlags(flags)
+	name := flags.String("name", "", "name of the server in the config (default the last part of its registry name)")
+	registryURL := flags.String("registry", mcpregistry.DefaultURL, "URL of the MCP registry")
+	timeout := flags.Duration("timeout", 2*time.Minute, "how long the server may take to start, including installing its package")
+	skipProbe := flags.Bool("skip-probe", false, "write the entry without checking that the server starts")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: mcp-proxy add [flags] <registry-name>")
+		return exitUsage
+	}
+	registryName := flags.Arg(0)
+	if *name == "" {
+		*name = path.Base(registryName)
+	}
+
+	// Fail before any questions if the name is taken, by any server rather
+	// than only a profile's
+	if _, err := os.Stat(*conf.path); err == nil {
+		cfg, err := config.Load(*conf.path, *conf.insecure, *conf.expandEnv, *conf.httpHeaders, *conf.httpTimeout, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed to load %s: %v\n", *conf.path, err)
+			return exitLoadFailed
+		}
+		if _, exists := cfg.McpServers[*name]; exists {
+			fmt.Fprintf(os.Stderr, "error: server %q already exists; choose another with -name\n", *name)
+			return exitUsage
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	server, err := mcpregistry.NewClient(*registryURL).Lookup(ctx, registryName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitInvalid
+	}
+	fmt.Printf("%s %s: %s\n", server.Name, server.Version, server.Description)
+
+	entry, err := mcpregistry.Entry(server, promptInput(bufio.NewReader(os.Stdin)))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitInvalid
+	}
+
+	if !*skipProbe {
+		fmt.Printf("Starting %s...\n", *name)
+		result := client.Probe(ctx, *name, expandedEntry(entry), *timeout)
+		printProbeResult(result)
+		if !result.OK() {
+			fmt.Fprintln(os.Stderr, "error: the server did not start, so it was not added; use -skip-probe to add it anyway")
+			return exitServerFailed
+		}
+	}
+
+	if err := config.AddServer(*conf.path, *name, entry); err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to update %s: %v\n", *conf.path, err)
+		return exitInvalid
+	}
+	fmt.Printf("Added %s to %s\n", *name, *conf.path)
+	return exitValid
+}
+
+// promptInput asks the user for the inputs a server requires. Environment
+// variables left empty are read from the environment when the config loads.
+func promptInput(in *bufio.Reader) mcpregistry.Resolve {
+	return func(kind mcpregistry.InputKind, input mcpregistry.Input) (string, error) {
+		prompt := fmt.Sprintf("%s %s", kind, input.Name)
+		if input.Description != "" {
+			prompt += " (" + input.Description + ")"
+		}
+		if kind == mcpregistry.InputEnv {
+			prompt += fmt.Sprintf(" [leave empty to use $%s]", input.Name)
+		}
+		fmt.Fprintf(os.Stderr, "%s: ", prompt)
+		line, err := in.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		value := strings.TrimSpace(line)
+		if value == "" && kind == mcpregistry.InputEnv {
+			value = "${" + input.Name + "}"
+		}
+		return value, nil
+	}
+}
+
+// expandedEntry returns entry with the environment variables its env and
+// headers refer to expanded, as they are when the config loads
+func expandedEntry(entry *config.MCPClientConfigV2) *config.MCPClientConfigV2 {
+	expanded := *entry
+	expand := func(values map[string]string) map[string]string {
+		if values == nil {
+			return nil
+		}
+		out := make(map[string]string, len(values))
+		for k, v := range values {
+			out[k] = os.ExpandEnv(v)
+		}
+		return out
+	}
+	expanded.Env = expand(entry.Env)
+	expanded.Headers = expand(entry.Headers)
+	return &expanded
+}
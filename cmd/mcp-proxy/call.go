@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/server"
+)
+
+// exitCallFailed is the call exit code when the call failed or the tool
+// reported an error; the others are shared with validate
+const exitCallFailed = 1
+
+// runCall implements `mcp-proxy call <tool_path> [name=value ...]`: it calls
+// one tool the way execute_tool does, prints its result, and returns the
+// process exit code.
+func runCall(args []string) int {
+	flags := flag.NewFlagSet("call", flag.ContinueOnError)
+	conf := registerConfigFlags(flags)
+	argsJSON := flags.String("args", "", "the arguments as a JSON object, or - to read them from stdin")
+	asJSON := flags.Bool("json", false, "print the whole result as JSON")
+	timeout := flags.Duration("timeout", 2*time.Minute, "how long to wait for the server to start and the call to finish")
+	verbose := flags.Bool("v", false, "log what lazy-mcp does, not only warnings")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: mcp-proxy call [flags] <tool_path> [name=value ...]")
+		return exitUsage
+	}
+	toolPath := flags.Arg(0)
+	arguments, err := parseCallArguments(*argsJSON, flags.Args()[1:], os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitUsage
+	}
+
+	setCLILogging(*verbose)
+	cfg, err := conf.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to load %s: %v\n", *conf.path, err)
+		return exitLoadFailed
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	proxy, err := newCLIProxy(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitLoadFailed
+	}
+	defer proxy.Close()
+
+	result, err := proxy.Hierarchy.HandleExecuteTool(ctx, proxy.Registry, toolPath, arguments)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitCallFailed
+	}
+	if *asJSON {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return exitCallFailed
+		}
+		fmt.Println(string(data))
+	} else {
+		printCallResult(result)
+	}
+	if result.IsError {
+		return exitCallFailed
+	}
+	return exitValid
+}
+
+// parseCallArguments builds the arguments of a call from -args, read from
+// stdin for "-", and name=value pairs, whose values are parsed as JSON when
+// they are valid JSON and taken as strings otherwise
+func parseCallArguments(argsJSON string, pairs []string, stdin io.Reader) (map[string]interface{}, error) {
+	arguments := make(map[string]interface{})
+	if argsJSON == "-" {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read arguments from stdin: %w", err)
+		}
+		argsJSON = string(data)
+	}
+	if strings.TrimSpace(argsJSON) != "" {
+		if err := json.Unmarshal([]byte(argsJSON), &arguments); err != nil {
+			return nil, fmt.Errorf("-args must be a JSON object: %w", err)
+		}
+	}
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("argument %q is not name=value", pair)
+		}
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+			parsed = value
+		}
+		arguments[name] = parsed
+	}
+	return arguments, nil
+}
+
+// printCallResult prints the text of a result, or its structured content
+// when it has no text
+func printCallResult(result *mcp.CallToolResult) {
+	printed := false
+	for _, content := range result.Content {
+		switch content := content.(type) {
+		case mcp.TextContent:
+			fmt.Println(content.Text)
+			printed = true
+		case mcp.ImageContent:
+			fmt.Printf("[image %s, %d bytes base64]\n", content.MIMEType, len(content.Data))
+			printed = true
+		case mcp.AudioContent:
+			fmt.Printf("[audio %s, %d bytes base64]\n", content.MIMEType, len(content.Data))
+			printed = true
+		case mcp.EmbeddedResource, mcp.ResourceLink:
+			data, _ := json.Marshal(content)
+			fmt.Println(string(data))
+			printed = true
+		}
+	}
+	if !printed && result.StructuredContent != nil {
+		data, _ := json.MarshalIndent(result.StructuredContent, "", "  ")
+		fmt.Println(string(data))
+	}
+}
+
+// newCLIProxy sets up the proxy for cfg as serving does, so calls take the
+// same path through filters, overrides, approval and the audit log. It does
+// not prewarm servers, prefetch their schemas, watch the config or count
+// usage, which only matter to a long-running proxy.
+func newCLIProxy(ctx context.Context, cfg *config.Config) (*server.Proxy, error) {
+	for _, serverCfg := range cfg.McpServers {
+		serverCfg.Prewarm = false
+	}
+	if cfg.McpProxy.Options == nil {
+		cfg.McpProxy.Options = &config.OptionsV2{}
+	}
+	cfg.McpProxy.Options.WatchConfig = optional.NewField(false)
+	cfg.McpProxy.Options.PrefetchSchemas = optional.NewField(false)
+	if cfg.McpProxy.Usage == nil {
+		cfg.McpProxy.Usage = &config.UsageConfig{}
+	}
+	cfg.McpProxy.Usage.Disabled = true
+
+	return server.NewProxy(ctx, cfg, server.ProxyOptions{})
+}
+
+// setCLILogging keeps the proxy's logs to warnings on stderr, so they do not
+// bury the output, unless verbose
+func setCLILogging(verbose bool) {
+	level := slog.LevelWarn
+	if verbose {
+		level = slog.LevelInfo
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeMockConfig writes a config whose only server, mock, is a mockserver
+// run by the test binary with an echo tool and a fixed one, and returns its
+// path
+func writeMockConfig(t *testing.T) string {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "script.json")
+	require.NoError(t, os.WriteFile(script, []byte(`{"name": "mock", "tools": [
+		{"name": "echo", "description": "Echo the arguments\nas JSON"},
+		{"name": "fixed", "result": "done"}
+	]}`), 0o600))
+	servers, err := json.Marshal(map[string]interface{}{"mock": map[string]interface{}{
+		"command": os.Args[0],
+		"args":    []string{"mock-server", "-script", script},
+		"env":     map[string]string{"LAZY_MCP_TEST_MAIN": "1"},
+	}})
+	require.NoError(t, err)
+	return writeTestConfig(t, "stdio", string(servers), map[string]string{"mock": `{"tools": {
+		"echo": {"description": "Echo the arguments\nas JSON", "maps_to": "echo", "server": "mock"},
+		"fixed": {"description": "Answer done", "maps_to": "fixed", "server": "mock"}
+	}}`})
+}
+
+// runCaptured runs a subcommand and returns its exit code with what it
+// printed to stdout and stderr
+func runCaptured(t *testing.T, run func([]string) int, args ...string) (int, string, string) {
+	t.Helper()
+	capture := func(file **os.File) func() string {
+		reader, writer, err := os.Pipe()
+		require.NoError(t, err)
+		previous := *file
+		*file = writer
+		var buf bytes.Buffer
+		done := make(chan struct{})
+		go func() {
+			_, _ = io.Copy(&buf, reader)
+			close(done)
+		}()
+		return func() string {
+			*file = previous
+			_ = writer.Close()
+			<-done
+			return buf.String()
+		}
+	}
+	stdout, stderr := capture(&os.Stdout), capture(&os.Stderr)
+	code := run(args)
+	return code, stdout(), stderr()
+}
+
+// TestList verifies that list prints each server's tool paths with the first
+// line of their descriptions, or them all as JSON.
+func TestList(t *testing.T) {
+	path := writeMockConfig(t)
+
+	code, stdout, _ := runCaptured(t, runList, "-config", path)
+	assert.Equal(t, exitValid, code)
+	assert.Equal(t, "mock (stdio)\n  mock.echo: Echo the arguments …\n  mock.fixed: Answer done\n", stdout)
+
+	code, stdout, _ = runCaptured(t, runList, "-config", path, "-json")
+	assert.Equal(t, exitValid, code)
+	assert.JSONEq(t, `{"servers": [{"name": "mock", "transport": "stdio", "tools": [
+		{"path": "mock.echo", "description": "Echo the arguments\nas JSON"},
+		{"path": "mock.fixed", "description": "Answer done"}
+	]}]}`, stdout)
+
+	code, _, stderr := runCaptured(t, runList, "-config", path, "-server", "jira")
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "jira")
+}
+
+// TestCall verifies that call starts the tool's server, prints the result
+// and exits with 0, and fails with exitCallFailed for a tool that does not
+// exist.
+func TestCall(t *testing.T) {
+	path := writeMockConfig(t)
+
+	code, stdout, stderr := runCaptured(t, runCall, "-config", path, "mock.echo", "message=hi", "count=2")
+	assert.Equal(t, exitValid, code, stderr)
+	assert.JSONEq(t, `{"message": "hi", "count": 2}`, stdout)
+
+	code, stdout, _ = runCaptured(t, runCall, "-config", path, "mock.fixed")
+	assert.Equal(t, exitValid, code)
+	assert.Equal(t, "done\n", stdout)
+
+	code, stdout, stderr = runCaptured(t, runCall, "-config", path, "mock.missing")
+	assert.Equal(t, exitCallFailed, code)
+	assert.Empty(t, stdout)
+	assert.Contains(t, stderr, "mock.missing")
+
+	code, _, _ = runCaptured(t, runCall, "-config", path)
+	assert.Equal(t, exitUsage, code, "a tool path is required")
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/voicetreelab/lazy-mcp/internal/orphans"
+)
+
+// runCleanup implements `mcp-proxy cleanup`: it kills the server processes,
+// and removes the containers, that proxies which crashed left behind, as each
+// proxy does on startup, and with -dry-run only lists them. It returns the
+// process exit code.
+func runCleanup(args []string) int {
+	flags := flag.NewFlagSet("cleanup", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "list the orphaned servers without killing them")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: mcp-proxy cleanup [-dry-run]")
+		return exitUsage
+	}
+
+	dir, err := orphans.Dir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitLoadFailed
+	}
+	found, err := orphans.Cleanup(dir, *dryRun)
+	verb := "killed"
+	if *dryRun {
+		verb = "found"
+	}
+	for _, orphan := range found {
+		fmt.Printf("%s %s\n", verb, orphan)
+	}
+	if len(found) == 0 {
+		fmt.Println("no orphaned servers")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitLoadFailed
+	}
+	return exitValid
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/client"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// exitServerFailed is the doctor exit code when any server failed to come up;
+// the others are shared with validate
+const exitServerFailed = 1
+
+// runDoctor implements `mcp-proxy doctor`: it launches every configured
+// server (or those named with -server), reports how each one starts, and
+// returns the process exit code.
+func runDoctor(args []string) int {
+	flags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	conf := registerConfigFlags(flags)
+	timeout := flags.Duration("timeout", 30*time.Second, "how long each startup stage may take before the server is reported as hung")
+	only := flags.String("server", "", "comma-separated names of the servers to check (default all)")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	cfg, err := conf.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to load %s: %v\n", *conf.path, err)
+		return exitLoadFailed
+	}
+
+	names, err := selectServers(cfg, *only)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitUsage
+	}
+
+	// Servers are probed in parallel, so one that hangs does not hold up the rest
+	results := make([]*client.ProbeResult, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i] = client.Probe(context.Background(), name, cfg.McpServers[name], *timeout)
+		}(i, name)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		printProbeResult(result)
+		if !result.OK() {
+			failed++
+		}
+	}
+	fmt.Printf("\n%d of %d server(s) healthy\n", len(results)-failed, len(results))
+	if failed > 0 {
+		return exitServerFailed
+	}
+	return exitValid
+}
+
+// selectServers returns the sorted names of the servers to check
+func selectServers(cfg *config.Config, only string) ([]string, error) {
+	var names []string
+	if only == "" {
+		for name := range cfg.McpServers {
+			names = append(names, name)
+		}
+	} else {
+		for _, name := range strings.Split(only, ",") {
+			name = strings.TrimSpace(name)
+			if _, exists := cfg.McpServers[name]; !exists {
+				return nil, fmt.Errorf("no server named %q in the config", name)
+			}
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func printProbeResult(result *client.ProbeResult) {
+	if result.OK() {
+		fmt.Printf("ok    %s: started in %s, %d tool(s) listed in %s\n",
+			result.Server, round(result.StartupTime), len(result.Tools), round(result.ListToolsTime))
+		fmt.Printf("      server %s %s, protocol %s\n", result.ServerInfo.Name, result.ServerInfo.Version, result.ProtocolVersion)
+		fmt.Printf("      capabilities: %s\n", strings.Join(capabilityNames(result), ", "))
+		return
+	}
+
+	problem := "failed"
+	switch {
+	case result.Crashed:
+		problem = "crashed"
+	case result.Hung:
+		problem = "hung"
+	}
+	fmt.Printf("FAIL  %s: %s during %s: %v\n", result.Server, problem, result.Stage, result.Err)
+	if result.StartupTime > 0 {
+		fmt.Printf("      started in %s\n", round(result.StartupTime))
+	}
+	if stderr := strings.TrimSpace(result.Stderr); stderr != "" {
+		fmt.Println("      stderr:")
+		for _, line := range strings.Split(stderr, "\n") {
+			fmt.Printf("        %s\n", line)
+		}
+	}
+}
+
+// capabilityNames lists the capabilities a server advertised
+func capabilityNames(result *client.ProbeResult) []string {
+	caps := result.Capabilities
+	var names []string
+	if caps.Tools != nil {
+		names = append(names, "tools")
+	}
+	if caps.Prompts != nil {
+		names = append(names, "prompts")
+	}
+	if caps.Resources != nil {
+		names = append(names, "resources")
+	}
+	if caps.Logging != nil {
+		names = append(names, "logging")
+	}
+	if caps.Sampling != nil {
+		names = append(names, "sampling")
+	}
+	if caps.Elicitation != nil {
+		names = append(names, "elicitation")
+	}
+	if len(names) == 0 {
+		names = append(names, "none")
+	}
+	return names
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// configFlags are the flags for locating and loading the config, shared by
+// serving and the subcommands
+type configFlags struct {
+	path        *string
+	insecure    *bool
+	expandEnv   *bool
+	httpHeaders *string
+	httpTimeout *int
+	profile     *string
+}
+
+func registerConfigFlags(flags *flag.FlagSet) *configFlags {
+	return &configFlags{
+		path:        flags.String("config", "config.json", "path to a JSON or YAML (.yaml/.yml) config file, or a http(s) url"),
+		insecure:    flags.Bool("insecure", false, "allow insecure HTTPS connections by skipping TLS certificate verification"),
+		expandEnv:   flags.Bool("expand-env", true, "expand environment variables in config file"),
+		httpHeaders: flags.String("http-headers", "", "optional HTTP headers for config URL, format: 'Key1:Value1;Key2:Value2'"),
+		httpTimeout: flags.Int("http-timeout", 10, "HTTP timeout in seconds when fetching config from URL"),
+		profile:     flags.String("profile", os.Getenv(config.ProfileEnv), "name of the config profile to apply (default $"+config.ProfileEnv+")"),
+	}
+}
+
+func (f *configFlags) load() (*config.Config, error) {
+	return config.Load(*f.path, *f.insecure, *f.expandEnv, *f.httpHeaders, *f.httpTimeout, *f.profile)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/voicetreelab/lazy-mcp/internal/clientconfig"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// runImport implements `mcp-proxy import -from <client>`: it reads the servers
+// configured in another MCP client, adds those the config does not have yet,
+// reports the ones that conflict with it, and returns the process exit code.
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	conf := registerConfigFlags(flags)
+	from := flags.String("from", "", "client to import the servers of: claude-desktop, claude-code, cursor or vscode")
+	file := flags.String("file", "", "path to the client's config (default its usual location)")
+	only := flags.String("server", "", "comma-separated names of the servers to import (default all)")
+	dryRun := flags.Bool("dry-run", false, "report what would be imported without changing the config")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *from == "" || flags.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: mcp-proxy import -from claude-desktop|claude-code|cursor|vscode [flags]")
+		return exitUsage
+	}
+	source, err := clientconfig.Lookup(*from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitUsage
+	}
+
+	sourcePath := *file
+	if sourcePath == "" {
+		if sourcePath, err = source.Path(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: cannot locate the %s config: %v; pass it with -file\n", source.Title, err)
+			return exitUsage
+		}
+	}
+	imported, err := source.Read(sourcePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to read %s config %s: %v\n", source.Title, sourcePath, err)
+		return exitLoadFailed
+	}
+
+	// Entries are compared as written, so ${VAR} references are left alone,
+	// and with every server, not only a profile's
+	existing := map[string]*config.MCPClientConfigV2{}
+	if _, err := os.Stat(*conf.path); err == nil {
+		cfg, err := config.Load(*conf.path, *conf.insecure, false, *conf.httpHeaders, *conf.httpTimeout, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed to load %s: %v\n", *conf.path, err)
+			return exitLoadFailed
+		}
+		existing = cfg.McpServers
+	}
+
+	names, err := selectImported(imported, *only)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitUsage
+	}
+
+	var added []string
+	conflicts := 0
+	for _, name := range names {
+		entry := imported.Entries[name]
+		current, exists := existing[name]
+		switch {
+		case !exists:
+			fmt.Printf("add       %s\n", name)
+			added = append(added, name)
+		case sameEntry(current, entry):
+			fmt.Printf("exists    %s: already in %s\n", name, *conf.path)
+		default:
+			fmt.Printf("conflict  %s: %s has a different server of this name; rename one of them and import again\n", name, *conf.path)
+			conflicts++
+		}
+	}
+	skipped := make([]string, 0, len(imported.Skipped))
+	for name := range imported.Skipped {
+		skipped = append(skipped, name)
+	}
+	sort.Strings(skipped)
+	for _, name := range skipped {
+		fmt.Printf("skip      %s: %s\n", name, imported.Skipped[name])
+	}
+	for _, warning := range imported.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+
+	if !*dryRun && len(added) > 0 {
+		if err := config.AddServers(*conf.path, added, imported.Entries); err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed to update %s: %v\n", *conf.path, err)
+			return exitInvalid
+		}
+	}
+	verb := "Imported"
+	if *dryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("\n%s %d of %d server(s) from %s into %s\n", verb, len(added), len(names), source.Title, *conf.path)
+	if conflicts > 0 {
+		return exitInvalid
+	}
+	return exitValid
+}
+
+// selectImported returns the sorted names of the imported servers to add
+func selectImported(imported *clientconfig.Servers, only string) ([]string, error) {
+	if only == "" {
+		return imported.Names, nil
+	}
+	var names []string
+	for _, name := range strings.Split(only, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := imported.Entries[name]; !ok {
+			return nil, fmt.Errorf("no importable server named %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// sameEntry reports whether two entries start the same server
+func sameEntry(a, b *config.MCPClientConfigV2) bool {
+	key := func(e *config.MCPClientConfigV2) string {
+		data, _ := json.Marshal(config.MCPClientConfigV2{
+			Type:    e.Transport(),
+			Command: e.Command,
+			Args:    e.Args,
+			Env:     e.Env,
+			URL:     e.URL,
+			Headers: e.Headers,
+		})
+		return string(data)
+	}
+	return key(a) == key(b)
+}
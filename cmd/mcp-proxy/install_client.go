@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-sphere/confstore/provider/http"
+	"github.com/voicetreelab/lazy-mcp/internal/clientconfig"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// runInstallClient implements `mcp-proxy install-client -client <client>`: it
+// registers lazy-mcp in the client's config in place of the servers lazy-mcp
+// already serves, backing up the previous config, and returns the process
+// exit code.
+func runInstallClient(args []string) int {
+	flags := flag.NewFlagSet("install-client", flag.ContinueOnError)
+	conf := registerConfigFlags(flags)
+	target := flags.String("client", "", "client to install lazy-mcp into: claude-desktop, claude-code or cursor")
+	file := flags.String("file", "", "path to the client's config (default its usual location)")
+	name := flags.String("name", "lazy-mcp", "name of the lazy-mcp server in the client's config")
+	command := flags.String("command", "", "path to the mcp-proxy the client runs (default this executable)")
+	dryRun := flags.Bool("dry-run", false, "report what would change without writing the client's config")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *target == "" || flags.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: mcp-proxy install-client -client claude-desktop|claude-code|cursor [flags]")
+		return exitUsage
+	}
+	client, err := clientconfig.Lookup(*target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitUsage
+	}
+
+	// The client starts lazy-mcp from anywhere, so both paths are absolute
+	configPath := *conf.path
+	if !http.IsRemoteURL(configPath) {
+		if configPath, err = filepath.Abs(configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return exitUsage
+		}
+	}
+	cfg, err := config.Load(configPath, *conf.insecure, false, *conf.httpHeaders, *conf.httpTimeout, *conf.profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to load %s: %v\n", *conf.path, err)
+		return exitLoadFailed
+	}
+	if *command == "" {
+		if *command, err = os.Executable(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: cannot locate mcp-proxy: %v; pass it with -command\n", err)
+			return exitUsage
+		}
+	}
+	if *command, err = filepath.Abs(*command); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitUsage
+	}
+
+	clientPath := *file
+	if clientPath == "" {
+		if clientPath, err = client.Path(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: cannot locate the %s config: %v; pass it with -file\n", client.Title, err)
+			return exitUsage
+		}
+	}
+	current, err := client.Read(clientPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		current = &clientconfig.Servers{}
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "error: failed to read %s config %s: %v\n", client.Title, clientPath, err)
+		return exitLoadFailed
+	}
+
+	// Servers lazy-mcp does not serve stay in the client, so none is lost
+	remove := append([]string(nil), current.LazyMCP...)
+	for _, server := range current.Names {
+		if _, ok := cfg.McpServers[server]; ok {
+			fmt.Printf("remove  %s: served by lazy-mcp\n", server)
+			remove = append(remove, server)
+		} else {
+			fmt.Printf("keep    %s: not in %s; import it with mcp-proxy import -from %s\n", server, *conf.path, client.Name)
+		}
+	}
+	entry := &config.MCPClientConfigV2{Command: *command, Args: []string{"-config", configPath}}
+	if *conf.profile != "" {
+		entry.Args = append(entry.Args, "-profile", *conf.profile)
+	}
+	fmt.Printf("add     %s: %s %s\n", *name, *command, strings.Join(entry.Args, " "))
+	if *dryRun {
+		return exitValid
+	}
+
+	backup, err := client.Install(clientPath, *name, entry, remove)
+	if backup != "" {
+		fmt.Printf("\nBacked up %s to %s\n", clientPath, backup)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to update %s: %v\n", clientPath, err)
+		return exitInvalid
+	}
+	fmt.Printf("Installed lazy-mcp into %s; restart %s to load it\n", clientPath, client.Title)
+	return exitValid
+}
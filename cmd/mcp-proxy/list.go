@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+)
+
+// listedServer is a configured server and the tools the hierarchy lists for
+// it, as `mcp-proxy list -json` prints them
+type listedServer struct {
+	Name      string       `json:"name"`
+	Transport string       `json:"transport"`
+	Group     string       `json:"group,omitempty"`
+	Tools     []listedTool `json:"tools"`
+}
+
+type listedTool struct {
+	Path        string `json:"path"`
+	Description string `json:"description,omitempty"`
+}
+
+// runList implements `mcp-proxy list`: it prints the configured servers with
+// the tool paths execute_tool and `mcp-proxy call` accept, as the hierarchy
+// lists them, without starting any server. It returns the process exit code.
+func runList(args []string) int {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	conf := registerConfigFlags(flags)
+	only := flags.String("server", "", "comma-separated names of the servers to list (default all)")
+	asJSON := flags.Bool("json", false, "print the servers and tools as JSON")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	setCLILogging(false)
+	cfg, err := conf.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to load %s: %v\n", *conf.path, err)
+		return exitLoadFailed
+	}
+	names, err := selectServers(cfg, *only)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitUsage
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	proxy, err := newCLIProxy(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitLoadFailed
+	}
+	defer proxy.Close()
+
+	servers := listServers(proxy.Hierarchy, proxy.Registry, names)
+	for i := range servers {
+		servers[i].Group = cfg.McpServers[servers[i].Name].Group
+	}
+	if *asJSON {
+		data, err := json.MarshalIndent(map[string]interface{}{"servers": servers}, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return exitLoadFailed
+		}
+		fmt.Println(string(data))
+		return exitValid
+	}
+
+	for i, server := range servers {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (%s)\n", server.Name, server.Transport)
+		if len(server.Tools) == 0 {
+			fmt.Println("  no tools in the hierarchy")
+		}
+		for _, tool := range server.Tools {
+			fmt.Printf("  %s", tool.Path)
+			if tool.Description != "" {
+				fmt.Printf(": %s", firstLine(tool.Description))
+			}
+			fmt.Println()
+		}
+	}
+	return exitValid
+}
+
+// listServers returns the named servers, in order, with their tools
+func listServers(h *hierarchy.Hierarchy, registry *hierarchy.ServerRegistry, names []string) []listedServer {
+	tools := make(map[string][]listedTool)
+	for _, path := range h.ToolPaths() {
+		toolDef, serverName, err := h.ResolveExposedToolPath(path)
+		if err != nil {
+			continue
+		}
+		tools[serverName] = append(tools[serverName], listedTool{
+			Path:        path,
+			Description: h.ToolDescription(path, toolDef),
+		})
+	}
+
+	transports := make(map[string]string)
+	for _, status := range registry.ServerStatuses() {
+		transports[status.Name] = status.Transport
+	}
+	servers := make([]listedServer, 0, len(names))
+	for _, name := range names {
+		server := listedServer{Name: name, Transport: transports[name], Tools: tools[name]}
+		if server.Tools == nil {
+			server.Tools = []listedTool{}
+		}
+		servers = append(servers, server)
+	}
+	return servers
+}
+
+// firstLine returns the first line of text, marking that more was cut
+func firstLine(text string) string {
+	for i, r := range text {
+		if r == '\n' {
+			return text[:i] + " …"
+		}
+	}
+	return text
+}
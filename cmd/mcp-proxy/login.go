@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/oauth"
+)
+
+// runLogin implements `mcp-proxy login <server>`: it authorizes with a server
+// whose oauth section is set, storing the tokens for later runs, and returns
+// the process exit code.
+func runLogin(args []string) int {
+	flags := flag.NewFlagSet("login", flag.ContinueOnError)
+	conf := registerConfigFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: mcp-proxy login [flags] <server>")
+		return exitUsage
+	}
+	name := flags.Arg(0)
+
+	cfg, err := conf.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to load %s: %v\n", *conf.path, err)
+		return exitLoadFailed
+	}
+	server, exists := cfg.McpServers[name]
+	if !exists {
+		fmt.Fprintf(os.Stderr, "error: unknown server %q\n", name)
+		return exitUsage
+	}
+	if server.OAuth == nil || server.Transport() == config.MCPClientTypeStdio {
+		fmt.Fprintf(os.Stderr, "error: server %q is not a remote server with an oauth section\n", name)
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	handler, err := oauth.NewHandler(ctx, name, server)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitInvalid
+	}
+	authorizer := oauth.NewAuthorizer()
+	defer authorizer.Close()
+	authURL, err := authorizer.Start(ctx, name, server.OAuth, handler)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitInvalid
+	}
+	fmt.Printf("Open this URL to authorize lazy-mcp with %s:\n\n  %s\n\n", name, authURL)
+	if err := authorizer.Wait(ctx, name); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitInvalid
+	}
+	fmt.Printf("%s: authorized\n", name)
+	return exitValid
+}
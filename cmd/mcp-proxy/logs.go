@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+
+	"github.com/voicetreelab/lazy-mcp/internal/serverlog"
+)
+
+// runLogs implements `mcp-proxy logs <server> [-f]`: it prints the end of what
+// a stdio or Docker server wrote to stderr, as the proxy saved it, and with -f
+// keeps printing what it writes. It returns the process exit code.
+func runLogs(args []string) int {
+	flags := flag.NewFlagSet("logs", flag.ContinueOnError)
+	conf := registerConfigFlags(flags)
+	follow := flags.Bool("f", false, "keep printing what the server writes, until interrupted")
+	lines := flags.Int("n", 100, "how many of the last lines to print")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: mcp-proxy logs [flags] <server> [-f]")
+		return exitUsage
+	}
+	server := flags.Arg(0)
+	// Flags may also follow the server's name
+	if err := flags.Parse(flags.Args()[1:]); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: mcp-proxy logs [flags] <server> [-f]")
+		return exitUsage
+	}
+
+	cfg, err := conf.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to load %s: %v\n", *conf.path, err)
+		return exitLoadFailed
+	}
+	if _, exists := cfg.McpServers[server]; !exists {
+		fmt.Fprintf(os.Stderr, "error: no server named %q in %s\n", server, *conf.path)
+		return exitUsage
+	}
+	if !cfg.McpProxy.ServerLogs.Enabled() {
+		fmt.Fprintln(os.Stderr, "error: mcpProxy.serverLogs.disabled is set, so no log files are written")
+		return exitLoadFailed
+	}
+	dir, err := cfg.McpProxy.ServerLogs.LogDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitLoadFailed
+	}
+	path := serverlog.File(dir, server)
+
+	output, err := serverlog.LastLines(path, *lines)
+	if errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "error: no log for %s at %s; it is written once the proxy starts the server\n", server, path)
+		return exitLoadFailed
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitLoadFailed
+	}
+	_, _ = os.Stdout.Write(output)
+	if !*follow {
+		return exitValid
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := serverlog.Follow(ctx, path, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitLoadFailed
+	}
+	return exitValid
+}
@@ -3,8 +3,11 @@ package main
 import (
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+	"strings"
 
+	"github.com/TBXark/optional-go"
 	"github.com/voicetreelab/lazy-mcp/internal/config"
 	"github.com/voicetreelab/lazy-mcp/internal/server"
 )
@@ -12,13 +15,46 @@ import (
 var BuildVersion = "dev"
 
 func main() {
-	conf := flag.String("config", "config.json", "path to config file or a http(s) url")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(runValidate(os.Args[2:]))
+		case "doctor":
+			os.Exit(runDoctor(os.Args[2:]))
+		case "login":
+			os.Exit(runLogin(os.Args[2:]))
+		case "add":
+			os.Exit(runAdd(os.Args[2:]))
+		case "import":
+			os.Exit(runImport(os.Args[2:]))
+		case "install-client":
+			os.Exit(runInstallClient(os.Args[2:]))
+		case "list":
+			os.Exit(runList(os.Args[2:]))
+		case "call":
+			os.Exit(runCall(os.Args[2:]))
+		case "mock-server":
+			os.Exit(runMockServer(os.Args[2:]))
+		case "selftest":
+			os.Exit(runSelftest(os.Args[2:]))
+		case "top":
+			os.Exit(runTop(os.Args[2:]))
+		case "logs":
+			os.Exit(runLogs(os.Args[2:]))
+		case "cleanup":
+			os.Exit(runCleanup(os.Args[2:]))
+		}
+	}
+
+	conf := registerConfigFlags(flag.CommandLine)
 	port := flag.String("port", "", "port to listen on (overrides config), e.g. '8080' or ':8080'")
+	listen := flag.String("listen", "", "serve over HTTP on this address, e.g. ':8080', even if the config selects stdio")
 	_ = flag.String("hierarchy", "testdata/mcp_hierarchy", "path to hierarchy directory")
-	insecure := flag.Bool("insecure", false, "allow insecure HTTPS connections by skipping TLS certificate verification")
-	expandEnv := flag.Bool("expand-env", true, "expand environment variables in config file")
-	httpHeaders := flag.String("http-headers", "", "optional HTTP headers for config URL, format: 'Key1:Value1;Key2:Value2'")
-	httpTimeout := flag.Int("http-timeout", 10, "HTTP timeout in seconds when fetching config from URL")
+	noUsage := flag.Bool("no-usage-tracking", false, "do not track, save or promote the most used tools (overrides config)")
+	record := flag.String("record", "", "record the tool calls forwarded to servers in this cassette file (overrides config)")
+	replay := flag.String("replay", "", "answer tool calls from this cassette file without starting servers (overrides config)")
+	dryRun := flag.Bool("dry-run", false, "check and log tool calls, answering with what would have been executed instead of forwarding them")
+	tags := flag.String("tags", "", "expose only tools with one of these comma-separated tags, e.g. 'readonly,ci' (overrides config)")
 
 	version := flag.Bool("version", false, "print version and exit")
 	help := flag.Bool("help", false, "print help and exit")
@@ -31,9 +67,10 @@ func main() {
 		fmt.Println(BuildVersion)
 		return
 	}
-	cfg, err := config.Load(*conf, *insecure, *expandEnv, *httpHeaders, *httpTimeout)
+	cfg, err := conf.load()
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		slog.Error("Failed to load config", "error", err)
+		os.Exit(1)
 	}
 
 	// Override port if specified
@@ -45,6 +82,47 @@ func main() {
 		}
 	}
 
+	if *noUsage {
+		if cfg.McpProxy.Usage == nil {
+			cfg.McpProxy.Usage = &config.UsageConfig{}
+		}
+		cfg.McpProxy.Usage.Disabled = true
+	}
+
+	switch {
+	case *record != "" && *replay != "":
+		slog.Error("-record and -replay cannot be used together")
+		os.Exit(1)
+	case *record != "":
+		cfg.McpProxy.Cassette = &config.CassetteConfig{File: *record, Mode: config.CassetteModeRecord}
+	case *replay != "":
+		cfg.McpProxy.Cassette = &config.CassetteConfig{File: *replay, Mode: config.CassetteModeReplay}
+	}
+
+	if *dryRun {
+		if cfg.McpProxy.Options == nil {
+			cfg.McpProxy.Options = &config.OptionsV2{}
+		}
+		cfg.McpProxy.Options.DryRun = optional.NewField(true)
+	}
+
+	if *tags != "" {
+		if cfg.McpProxy.Options == nil {
+			cfg.McpProxy.Options = &config.OptionsV2{}
+		}
+		cfg.McpProxy.Options.ExposeTags = nil
+		for _, tag := range strings.Split(*tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				cfg.McpProxy.Options.ExposeTags = append(cfg.McpProxy.Options.ExposeTags, tag)
+			}
+		}
+	}
+
+	// Listen mode runs one long-lived HTTP instance that many clients share
+	if *listen != "" {
+		applyListen(cfg, *listen)
+	}
+
 	// Start server based on configured type
 	switch cfg.McpProxy.Type {
 	case config.MCPServerTypeStdio:
@@ -54,6 +132,16 @@ func main() {
 	}
 
 	if err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		slog.Error("Failed to start server", "error", err)
+		os.Exit(1)
+	}
+}
+
+// applyListen makes cfg serve over HTTP on addr, as -listen does, over
+// Streamable HTTP if the config selects stdio
+func applyListen(cfg *config.Config, addr string) {
+	cfg.McpProxy.Addr = addr
+	if cfg.McpProxy.Type == config.MCPServerTypeStdio {
+		cfg.McpProxy.Type = config.MCPServerTypeStreamable
 	}
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/server"
+)
+
+// TestMain runs the test binary as mcp-proxy when tests start it as a
+// server, as writeMockConfig does
+func TestMain(m *testing.M) {
+	if os.Getenv("LAZY_MCP_TEST_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// writeTestConfig writes a config of the given mcpProxy type and servers, as
+// JSON, with a hierarchy holding the given nodes, as written to
+// server/server.json, and returns its path. The user's cache, where orphaned
+// servers are tracked, is moved to a temporary directory.
+func writeTestConfig(t *testing.T, proxyType, servers string, nodes map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+
+	hierarchyDir := filepath.Join(dir, "hierarchy")
+	require.NoError(t, os.Mkdir(hierarchyDir, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(hierarchyDir, "root.json"), []byte(`{"overview": "Tools"}`), 0o600))
+	for name, node := range nodes {
+		require.NoError(t, os.Mkdir(filepath.Join(hierarchyDir, name), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(hierarchyDir, name, name+".json"), []byte(node), 0o600))
+	}
+
+	path := filepath.Join(dir, "config.json")
+	content := `{
+  "mcpProxy": {"name": "lazy-mcp", "version": "1.0.0", "type": "` + proxyType + `", "hierarchyPath": "` + filepath.ToSlash(hierarchyDir) + `"},
+  "mcpServers": ` + servers + `
+}`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// TestListen verifies that -listen serves a config that selects stdio over
+// HTTP, where clients can initialize and list the tools over Streamable HTTP
+// and SSE alike.
+func TestListen(t *testing.T) {
+	cfg, err := config.Load(writeTestConfig(t, "stdio", "{}", nil), false, true, "", 10, "")
+	require.NoError(t, err)
+	applyListen(cfg, "127.0.0.1:0")
+	assert.Equal(t, config.MCPServerTypeStreamable, cfg.McpProxy.Type)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	proxy, err := server.NewProxy(ctx, cfg, server.ProxyOptions{})
+	require.NoError(t, err)
+	defer proxy.Close()
+	handler, err := proxy.HTTPHandler(ctx)
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp", cfg.McpProxy.Addr)
+	require.NoError(t, err)
+	httpServer := &http.Server{Handler: handler}
+	go func() { _ = httpServer.Serve(listener) }()
+	defer httpServer.Close()
+	baseURL := "http://" + listener.Addr().String()
+
+	streamable, err := client.NewStreamableHttpClient(baseURL + "/mcp")
+	require.NoError(t, err)
+	sse, err := client.NewSSEMCPClient(baseURL + "/sse")
+	require.NoError(t, err)
+	for name, mcpClient := range map[string]*client.Client{"streamable": streamable, "sse": sse} {
+		require.NoError(t, mcpClient.Start(ctx), name)
+		initialized, err := mcpClient.Initialize(ctx, mcp.InitializeRequest{})
+		require.NoError(t, err, name)
+		assert.Equal(t, "lazy-mcp", initialized.ServerInfo.Name, name)
+
+		tools, err := mcpClient.ListTools(ctx, mcp.ListToolsRequest{})
+		require.NoError(t, err, name)
+		var names []string
+		for _, tool := range tools.Tools {
+			names = append(names, tool.Name)
+		}
+		assert.Contains(t, names, "get_tools_in_category", name)
+		assert.Contains(t, names, "execute_tool", name)
+		require.NoError(t, mcpClient.Close(), name)
+	}
+}
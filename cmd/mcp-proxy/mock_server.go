@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/voicetreelab/lazy-mcp/pkg/mockserver"
+)
+
+// runMockServer implements `mcp-proxy mock-server`: it serves the tools of a
+// mockserver script over stdio, to stand in for a real server in a config.
+func runMockServer(args []string) int {
+	flags := flag.NewFlagSet("mock-server", flag.ContinueOnError)
+	scriptPath := flags.String("script", "", "path to the JSON script of the tools to serve")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *scriptPath == "" {
+		fmt.Fprintln(os.Stderr, "error: -script is required")
+		return exitUsage
+	}
+
+	script, err := mockserver.Load(*scriptPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitLoadFailed
+	}
+	if err := mockserver.Serve(script); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
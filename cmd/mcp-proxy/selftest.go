@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/lazymcp"
+	"github.com/voicetreelab/lazy-mcp/pkg/mockserver"
+	generator "github.com/voicetreelab/lazy-mcp/structure_generator"
+)
+
+// selftestCallTimeout is the callTimeout of the mock servers, which the
+// hanging tool runs into
+const selftestCallTimeout = time.Second
+
+// selftestScripts are the mock servers the self-test runs the gateway with
+var selftestScripts = []*mockserver.Script{
+	{
+		Name: "mock",
+		Tools: []mockserver.Tool{
+			{Name: "echo", Description: "Returns its arguments."},
+			{Name: "slow", Description: "Answers after a while.", Result: "done", Latency: mockserver.Duration(200 * time.Millisecond)},
+			{Name: "quota", Description: "Fails as out of quota.", Failure: mockserver.FailToolError, Message: "out of quota"},
+			{Name: "hang", Description: "Never answers.", Failure: mockserver.FailHang},
+		},
+	},
+	{
+		Name: "crashy",
+		Tools: []mockserver.Tool{
+			{Name: "echo", Description: "Returns its arguments."},
+			{Name: "crash", Description: "Exits the server.", Failure: mockserver.FailCrash},
+		},
+	},
+}
+
+// selftestCheck is one behavior of the gateway the self-test verifies
+type selftestCheck struct {
+	name string
+	run  func(ctx context.Context, gateway *lazymcp.Gateway, mcpClient *client.Client) error
+}
+
+var selftestChecks = []selftestCheck{
+	{"lists the meta-tools", checkMetaTools},
+	{"browses the hierarchy", checkBrowse},
+	{"starts servers only when used", checkLazyStart},
+	{"forwards tool calls", checkForward},
+	{"waits for slow tools", checkSlow},
+	{"passes on tool errors", checkToolError},
+	{"times out hung calls", checkTimeout},
+	{"restarts crashed servers", checkCrashRecovery},
+}
+
+// runSelftest implements `mcp-proxy selftest`: it runs the gateway in-process
+// with mock servers, checks that it lists, browses, forwards, times out and
+// recovers as it should, and returns the process exit code.
+func runSelftest(args []string) int {
+	flags := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	timeout := flags.Duration("timeout", 30*time.Second, "how long each check may take")
+	verbose := flags.Bool("v", false, "show the gateway's logs")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if !*verbose {
+		slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitLoadFailed
+	}
+	dir, err := os.MkdirTemp("", "lazy-mcp-selftest")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitLoadFailed
+	}
+	defer os.RemoveAll(dir)
+
+	cfg, err := writeSelftestSetup(dir, executable)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to set up the self-test: %v\n", err)
+		return exitLoadFailed
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gateway, err := lazymcp.New(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to start the gateway: %v\n", err)
+		return exitLoadFailed
+	}
+	defer gateway.Close()
+
+	mcpClient, err := client.NewInProcessClient(gateway.MCPServer())
+	if err == nil {
+		defer mcpClient.Close()
+		if err = mcpClient.Start(ctx); err == nil {
+			_, err = mcpClient.Initialize(ctx, mcp.InitializeRequest{})
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to connect to the gateway: %v\n", err)
+		return exitLoadFailed
+	}
+
+	failed := 0
+	for _, check := range selftestChecks {
+		checkCtx, cancel := context.WithTimeout(ctx, *timeout)
+		start := time.Now()
+		err := check.run(checkCtx, gateway, mcpClient)
+		cancel()
+		if err != nil {
+			fmt.Printf("FAIL  %s: %v\n", check.name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("ok    %s (%s)\n", check.name, round(time.Since(start)))
+	}
+	fmt.Printf("\n%d of %d check(s) passed\n", len(selftestChecks)-failed, len(selftestChecks))
+	if failed > 0 {
+		return exitServerFailed
+	}
+	return exitValid
+}
+
+// writeSelftestSetup writes the mock scripts, their hierarchy and a config
+// serving them to dir, and loads the config
+func writeSelftestSetup(dir, executable string) (*lazymcp.Config, error) {
+	hierarchyPath := filepath.Join(dir, "hierarchy")
+	servers := map[string]interface{}{}
+	var serverTools []generator.ServerTools
+	for _, script := range selftestScripts {
+		scriptPath := filepath.Join(dir, script.Name+".json")
+		if err := writeJSON(scriptPath, script); err != nil {
+			return nil, err
+		}
+		servers[script.Name] = map[string]interface{}{
+			"command": executable,
+			"args":    []string{"mock-server", "-script", scriptPath},
+			"options": map[string]interface{}{"callTimeout": selftestCallTimeout.String()},
+		}
+
+		tools := generator.ServerTools{ServerName: script.Name}
+		for _, tool := range script.Tools {
+			tools.Tools = append(tools.Tools, generator.Tool{
+				Name:        tool.Name,
+				Description: tool.Description,
+				InputSchema: map[string]interface{}{"type": "object"},
+			})
+		}
+		serverTools = append(serverTools, tools)
+	}
+	if err := generator.GenerateStructure(serverTools, hierarchyPath); err != nil {
+		return nil, err
+	}
+
+	configPath := filepath.Join(dir, "config.json")
+	err := writeJSON(configPath, map[string]interface{}{
+		"mcpProxy": map[string]interface{}{
+			"name":          "lazy-mcp-selftest",
+			"version":       BuildVersion,
+			"type":          "stdio",
+			"hierarchyPath": hierarchyPath,
+			"usage":         map[string]interface{}{"disabled": true},
+			"options":       map[string]interface{}{"watchConfig": false},
+		},
+		"mcpServers": servers,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lazymcp.LoadConfig(configPath)
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// callTool calls a tool of the gateway, returning the text of its result and
+// whether it is an error
+func callTool(ctx context.Context, mcpClient *client.Client, name string, arguments map[string]interface{}) (string, bool, error) {
+	request := mcp.CallToolRequest{}
+	request.Params.Name = name
+	request.Params.Arguments = arguments
+	result, err := mcpClient.CallTool(ctx, request)
+	if err != nil {
+		return "", false, err
+	}
+	var texts []string
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), result.IsError, nil
+}
+
+// executeTool calls a tool of a mock server through execute_tool, failing
+// unless it succeeds
+func executeTool(ctx context.Context, mcpClient *client.Client, toolPath string, arguments map[string]interface{}) (string, error) {
+	text, isError, err := callTool(ctx, mcpClient, "execute_tool", map[string]interface{}{
+		"tool_path": toolPath,
+		"arguments": arguments,
+	})
+	if err != nil {
+		return "", err
+	}
+	if isError {
+		return "", errors.New(text)
+	}
+	return text, nil
+}
+
+func checkMetaTools(ctx context.Context, gateway *lazymcp.Gateway, mcpClient *client.Client) error {
+	tools, err := mcpClient.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		return err
+	}
+	listed := make(map[string]bool)
+	for _, tool := range tools.Tools {
+		listed[tool.Name] = true
+	}
+	for _, name := range []string{"get_tools_in_category", "execute_tool"} {
+		if !listed[name] {
+			return fmt.Errorf("%s is not listed", name)
+		}
+	}
+	return nil
+}
+
+func checkBrowse(ctx context.Context, gateway *lazymcp.Gateway, mcpClient *client.Client) error {
+	text, isError, err := callTool(ctx, mcpClient, "get_tools_in_category", map[string]interface{}{"path": "mock"})
+	if err != nil {
+		return err
+	}
+	if isError {
+		return errors.New(text)
+	}
+	for _, tool := range selftestScripts[0].Tools {
+		if !strings.Contains(text, tool.Name) {
+			return fmt.Errorf("tool %s is missing from category mock", tool.Name)
+		}
+	}
+	return nil
+}
+
+func checkLazyStart(ctx context.Context, gateway *lazymcp.Gateway, mcpClient *client.Client) error {
+	for _, event := range gateway.RecentEvents("") {
+		if event.Type == lazymcp.EventServerStarted {
+			return fmt.Errorf("%s started before any of its tools was called", event.Server)
+		}
+	}
+	return nil
+}
+
+func checkForward(ctx context.Context, gateway *lazymcp.Gateway, mcpClient *client.Client) error {
+	text, err := executeTool(ctx, mcpClient, "mock.echo", map[string]interface{}{"message": "hello"})
+	if err != nil {
+		return err
+	}
+	var echoed map[string]interface{}
+	if err := json.Unmarshal([]byte(text), &echoed); err != nil || echoed["message"] != "hello" {
+		return fmt.Errorf("unexpected result %q", text)
+	}
+	return nil
+}
+
+func checkSlow(ctx context.Context, gateway *lazymcp.Gateway, mcpClient *client.Client) error {
+	text, err := executeTool(ctx, mcpClient, "mock.slow", nil)
+	if err != nil {
+		return err
+	}
+	if text != "done" {
+		return fmt.Errorf("unexpected result %q", text)
+	}
+	return nil
+}
+
+func checkToolError(ctx context.Context, gateway *lazymcp.Gateway, mcpClient *client.Client) error {
+	_, err := executeTool(ctx, mcpClient, "mock.quota", nil)
+	if err == nil {
+		return errors.New("the call succeeded")
+	}
+	if !strings.Contains(err.Error(), "out of quota") {
+		return fmt.Errorf("unexpected error: %w", err)
+	}
+	return nil
+}
+
+func checkTimeout(ctx context.Context, gateway *lazymcp.Gateway, mcpClient *client.Client) error {
+	start := time.Now()
+	_, err := executeTool(ctx, mcpClient, "mock.hang", nil)
+	if err == nil {
+		return errors.New("the call succeeded")
+	}
+	if !strings.Contains(err.Error(), "timed out") {
+		return fmt.Errorf("unexpected error: %w", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*selftestCallTimeout {
+		return fmt.Errorf("timed out after %s, not %s", round(elapsed), selftestCallTimeout)
+	}
+	return nil
+}
+
+func checkCrashRecovery(ctx context.Context, gateway *lazymcp.Gateway, mcpClient *client.Client) error {
+	if _, err := executeTool(ctx, mcpClient, "crashy.crash", nil); err == nil {
+		return errors.New("the crashing call succeeded")
+	}
+	// The server is restarted after a delay; calls fail until it is back
+	for {
+		_, err := executeTool(ctx, mcpClient, "crashy.echo", nil)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("not back after the crash: %w", err)
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+	"github.com/voicetreelab/lazy-mcp/internal/server"
+)
+
+// topRequestTimeout bounds each request top makes to the proxy
+const topRequestTimeout = 30 * time.Second
+
+// Keys top reads from the terminal, with the arrow keys mapped to j and k
+const (
+	keyQuit    = 'q'
+	keyCtrlC   = 3
+	keyDown    = 'j'
+	keyUp      = 'k'
+	keyRestart = 'r'
+	keyDisable = 'd'
+)
+
+// runTop implements `mcp-proxy top`: a dashboard of a running proxy's
+// servers, in-flight and queued calls, call rates and recent errors, polled
+// from its /admin endpoints, with keys to restart, disable and enable
+// servers. It returns the process exit code.
+func runTop(args []string) int {
+	flags := flag.NewFlagSet("top", flag.ContinueOnError)
+	baseURL := flags.String("url", "http://localhost:8080", "base URL of the running proxy")
+	key := flags.String("key", "", "API key, if the proxy requires one")
+	interval := flags.Duration("interval", time.Second, "how often to refresh")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *interval <= 0 {
+		fmt.Fprintln(os.Stderr, "error: -interval must be positive")
+		return exitUsage
+	}
+
+	admin := &adminClient{baseURL: strings.TrimSuffix(*baseURL, "/"), key: *key}
+	// Fail before taking over the terminal if the proxy is not reachable
+	status, err := admin.status()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitLoadFailed
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: top needs a terminal: %v\n", err)
+		return exitUsage
+	}
+	defer tty.Close()
+	restore, err := makeRaw(tty)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitUsage
+	}
+	// Alternate screen, cursor hidden; undone in reverse on exit
+	fmt.Fprint(tty, "\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Fprint(tty, "\x1b[?25h\x1b[?1049l")
+		restore()
+	}()
+
+	keys := make(chan byte)
+	go readKeys(tty, keys)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	view := &topView{status: status}
+	for {
+		width, height := terminalSize(tty)
+		fmt.Fprint(tty, view.render(admin.baseURL, width, height))
+
+		select {
+		case <-ticker.C:
+		case k, ok := <-keys:
+			if !ok || k == keyQuit || k == keyCtrlC {
+				return exitValid
+			}
+			view.handleKey(admin, k)
+		}
+		if status, err := admin.status(); err != nil {
+			view.message = err.Error()
+		} else {
+			view.status = status
+		}
+	}
+}
+
+// topView is what top shows: the latest status, the selected server and the
+// outcome of the latest action
+type topView struct {
+	status   *server.DashboardStatus
+	selected int
+	message  string
+}
+
+func (v *topView) handleKey(admin *adminClient, k byte) {
+	servers := v.status.Servers
+	switch k {
+	case keyDown:
+		if v.selected < len(servers)-1 {
+			v.selected++
+		}
+	case keyUp:
+		if v.selected > 0 {
+			v.selected--
+		}
+	case keyRestart, keyDisable:
+		if v.selected >= len(servers) {
+			return
+		}
+		name := servers[v.selected].Name
+		action := "restart"
+		if k == keyDisable {
+			action = "disable"
+			if servers[v.selected].State == hierarchy.ServerStateDisabled {
+				action = "enable"
+			}
+		}
+		if err := admin.act(name, action); err != nil {
+			v.message = fmt.Sprintf("%s %s: %v", action, name, err)
+		} else {
+			v.message = fmt.Sprintf("%s %s: done", action, name)
+		}
+	}
+}
+
+// render draws the view to fit width by height, with "\r\n" line endings as
+// the terminal is raw
+func (v *topView) render(baseURL string, width, height int) string {
+	status := v.status
+	var lines []string
+	add := func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	add("lazy-mcp top - %s - %s", baseURL, status.Time.Local().Format("15:04:05"))
+	add("")
+	add("\x1b[1m  %-24s %-11s %-8s %8s %7s %7s %8s  %s\x1b[0m", "SERVER", "STATE", "HEALTH", "IN-FLIGHT", "QUEUED", "WAIT", "RESTARTS", "LAST ERROR")
+	for i, s := range status.Servers {
+		marker := "  "
+		if i == v.selected {
+			marker = "\x1b[7m> "
+		}
+		health := "ok"
+		if !s.Healthy {
+			health = fmt.Sprintf("\x1b[31m%-8s\x1b[39m", "bad")
+		}
+		line := fmt.Sprintf("%s%-24s %-11s %-8s %8d %7d %7s %8d  %s", marker,
+			truncate(s.Name, 24), s.State, health, s.InFlight, s.Queued, s.QueueWait.Std(), s.Restarts, firstLine(s.LastError))
+		if i == v.selected {
+			line += "\x1b[0m"
+		}
+		lines = append(lines, line)
+	}
+
+	add("")
+	add("\x1b[1m  %-48s %-24s %9s %7s %9s %9s\x1b[0m", "TOOL", "SERVER", "CALLS/MIN", "ERRORS", "P50", "P99")
+	if len(status.Tools) == 0 {
+		add("  no calls in the last minute")
+	}
+	latencies := make(map[[2]string]hierarchy.ToolLatency, len(status.Latencies))
+	for _, latency := range status.Latencies {
+		latencies[[2]string{latency.Server, latency.Tool}] = latency
+	}
+	for i, tool := range status.Tools {
+		if i == 10 {
+			break
+		}
+		latency := latencies[[2]string{tool.Server, tool.Tool}]
+		add("  %-48s %-24s %9d %7d %9s %9s", truncate(tool.ToolPath, 48), truncate(tool.Server, 24), tool.Calls, tool.Errors,
+			latency.P50.Std().Round(time.Millisecond), latency.P99.Std().Round(time.Millisecond))
+	}
+
+	add("")
+	add("\x1b[1m  RECENT ERRORS\x1b[0m")
+	if len(status.Errors) == 0 {
+		add("  none")
+	}
+	for _, event := range status.Errors {
+		what := string(event.Type)
+		if event.ToolPath != "" {
+			what = event.ToolPath
+		} else if event.Reason != "" {
+			what += " (" + event.Reason + ")"
+		}
+		add("  %s %-16s %s: %s", event.Time.Local().Format("15:04:05"), truncate(event.Server, 16), what, firstLine(event.Error))
+	}
+
+	footer := "j/k select  r restart  d disable/enable  q quit"
+	if v.message != "" {
+		footer = v.message + "  |  " + footer
+	}
+	if height > 1 && len(lines) > height-1 {
+		lines = lines[:height-1]
+	}
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, footer)
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(clip(line, width))
+		b.WriteString("\x1b[K")
+	}
+	return b.String()
+}
+
+// truncate shortens s to n runes, marking that it was cut
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// clip cuts line to width visible runes, passing escape sequences through
+func clip(line string, width int) string {
+	if width <= 0 {
+		return line
+	}
+	var b strings.Builder
+	visible, escape := 0, false
+	for _, r := range line {
+		switch {
+		case r == '\x1b':
+			escape = true
+		case escape:
+			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+				escape = false
+			}
+		default:
+			if visible == width {
+				continue
+			}
+			visible++
+		}
+		b.WriteRune(r)
+	}
+	return b.String() + "\x1b[0m"
+}
+
+// adminClient calls the /admin endpoints of a running proxy
+type adminClient struct {
+	baseURL string
+	key     string
+}
+
+func (c *adminClient) status() (*server.DashboardStatus, error) {
+	var status server.DashboardStatus
+	if err := c.do(http.MethodGet, "/admin/status", &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// act restarts, disables or enables a server
+func (c *adminClient) act(name, action string) error {
+	return c.do(http.MethodPost, "/admin/servers/"+url.PathEscape(name)+"/"+action, nil)
+}
+
+func (c *adminClient) do(method, path string, result interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), topRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.key != "" {
+		req.Header.Set("Authorization", "Bearer "+c.key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound && path == "/admin/status":
+		return fmt.Errorf("%s does not serve /admin/status; enable options.adminTools", c.baseURL)
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("%s requires an API key; pass -key", c.baseURL)
+	case resp.StatusCode != http.StatusOK:
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &failure) == nil && failure.Error != "" {
+			return fmt.Errorf("%s", failure.Error)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(body, result)
+}
+
+// makeRaw puts tty in raw mode with stty, and returns what restores it
+func makeRaw(tty *os.File) (restore func(), err error) {
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the terminal settings: %w", err)
+	}
+	if _, err := stty(tty, "raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("failed to set up the terminal: %w", err)
+	}
+	return func() { _, _ = stty(tty, strings.TrimSpace(saved)) }, nil
+}
+
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// terminalSize returns the width and height of tty, or 80 by 24 if stty
+// cannot tell
+func terminalSize(tty *os.File) (width, height int) {
+	out, err := stty(tty, "size")
+	if err == nil {
+		if fields := strings.Fields(out); len(fields) == 2 {
+			rows, rowsErr := strconv.Atoi(fields[0])
+			cols, colsErr := strconv.Atoi(fields[1])
+			if rowsErr == nil && colsErr == nil && rows > 0 && cols > 0 {
+				return cols, rows
+			}
+		}
+	}
+	return 80, 24
+}
+
+// readKeys sends the keys typed on tty, mapping the up and down arrows to k
+// and j, and closes keys when tty can no longer be read
+func readKeys(tty io.Reader, keys chan<- byte) {
+	defer close(keys)
+	buf := make([]byte, 16)
+	for {
+		n, err := tty.Read(buf)
+		if err != nil {
+			return
+		}
+		input := buf[:n]
+		if n >= 3 && input[0] == '\x1b' && input[1] == '[' {
+			switch input[2] {
+			case 'A':
+				keys <- keyUp
+			case 'B':
+				keys <- keyDown
+			}
+			continue
+		}
+		for _, k := range input {
+			keys <- k
+		}
+	}
+}
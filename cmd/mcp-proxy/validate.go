@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// Exit codes of the validate command
+const (
+	exitValid      = 0
+	exitInvalid    = 1
+	exitLoadFailed = 2
+	exitUsage      = 64
+)
+
+// runValidate implements `mcp-proxy validate`: it loads the config, reports
+// every problem found, and returns the process exit code.
+func runValidate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	conf := registerConfigFlags(flags)
+	offline := flags.Bool("offline", false, "skip checks that need the network, such as URL reachability")
+	timeout := flags.Duration("timeout", 5*time.Second, "timeout for each URL reachability check")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	cfg, err := conf.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to load %s: %v\n", *conf.path, err)
+		return exitLoadFailed
+	}
+
+	diags := config.Validate(context.Background(), cfg, config.ValidateOptions{Offline: *offline, Timeout: *timeout})
+	errors := 0
+	for _, diag := range diags {
+		fmt.Fprintln(os.Stderr, diag)
+		if diag.Severity == config.SeverityError {
+			errors++
+		}
+	}
+
+	if errors > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d error(s), %d warning(s)\n", *conf.path, errors, len(diags)-errors)
+		return exitInvalid
+	}
+	fmt.Printf("%s: OK, %d server(s), %d warning(s)\n", *conf.path, len(cfg.McpServers), len(diags))
+	return exitValid
+}
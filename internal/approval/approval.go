@@ -0,0 +1,235 @@
+// Package approval asks whether a tool call may be forwarded to its server:
+// the user through their MCP client or terminal, or a webhook for them.
+package approval
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/redact"
+)
+
+// Request is a tool call awaiting approval
+type Request struct {
+	ToolPath    string                 `json:"toolPath"`
+	Server      string                 `json:"server"`
+	Tool        string                 `json:"tool"`
+	Arguments   map[string]interface{} `json:"arguments"`
+	Destructive bool                   `json:"destructive"`
+}
+
+// Decision is the answer to a Request
+type Decision struct {
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Approver decides whether tool calls may be forwarded. An error means no
+// decision could be made, and the call is denied.
+type Approver interface {
+	Approve(ctx context.Context, request Request) (Decision, error)
+}
+
+// ElicitFunc sends an elicitation request to the client that made the
+// request ctx belongs to
+type ElicitFunc func(ctx context.Context, request mcp.ElicitationRequest) (*mcp.ElicitationResult, error)
+
+// New returns the approver configured by cfg, eliciting approval with elicit
+// unless it asks for another way. The arguments of calls are masked by
+// redactor before they are shown or sent.
+func New(cfg *config.ApprovalConfig, elicit ElicitFunc, redactor *redact.Redactor) Approver {
+	var approver Approver
+	switch cfg.Via {
+	case config.ApprovalViaTerminal:
+		approver = NewTerminal(terminalPath)
+	case config.ApprovalViaWebhook:
+		approver = NewWebhook(cfg.Webhook)
+	default:
+		approver = Elicitation(elicit)
+	}
+	return Redacted(approver, redactor)
+}
+
+// Redacted returns an approver asking approver with the secrets of the
+// arguments masked by redactor, so that they do not leave lazy-mcp
+func Redacted(approver Approver, redactor *redact.Redactor) Approver {
+	if redactor == nil {
+		return approver
+	}
+	return &redacted{approver: approver, redactor: redactor}
+}
+
+type redacted struct {
+	approver Approver
+	redactor *redact.Redactor
+}
+
+// Approve implements Approver
+func (r *redacted) Approve(ctx context.Context, request Request) (Decision, error) {
+	request.Arguments = r.redactor.Map(request.Arguments)
+	return r.approver.Approve(ctx, request)
+}
+
+// describe summarizes a request for the user
+func describe(request Request) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Allow calling %s on server %s?", request.Tool, request.Server)
+	if request.Destructive {
+		b.WriteString(" The server marks it as destructive.")
+	}
+	if len(request.Arguments) > 0 {
+		if args, err := json.MarshalIndent(request.Arguments, "", "  "); err == nil {
+			fmt.Fprintf(&b, "\nArguments: %s", args)
+		}
+	}
+	return b.String()
+}
+
+// Elicitation asks the user through their MCP client, which must support
+// elicitation
+func Elicitation(elicit ElicitFunc) Approver {
+	return elicitation(elicit)
+}
+
+type elicitation ElicitFunc
+
+// Approve implements Approver
+func (e elicitation) Approve(ctx context.Context, request Request) (Decision, error) {
+	elicitRequest := mcp.ElicitationRequest{}
+	elicitRequest.Method = string(mcp.MethodElicitationCreate)
+	elicitRequest.Params.Message = describe(request)
+	elicitRequest.Params.RequestedSchema = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"approve": map[string]interface{}{
+				"type":        "boolean",
+				"title":       "Approve",
+				"description": "Forward the call to the server",
+				"default":     true,
+			},
+		},
+		"required": []string{"approve"},
+	}
+	result, err := e(ctx, elicitRequest)
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to ask the client for approval: %w", err)
+	}
+	if result.Action != mcp.ElicitationResponseActionAccept {
+		return Decision{Reason: fmt.Sprintf("the user chose to %s", result.Action)}, nil
+	}
+	// Accepting without content approves, as the approval is the default
+	if content, ok := result.Content.(map[string]interface{}); ok {
+		if approve, ok := content["approve"].(bool); ok && !approve {
+			return Decision{Reason: "the user did not approve"}, nil
+		}
+	}
+	return Decision{Approved: true}, nil
+}
+
+// terminalPath is the controlling terminal, as stdin and stdout may carry
+// the MCP protocol
+const terminalPath = "/dev/tty"
+
+// Terminal prompts on a terminal, one call at a time
+type Terminal struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewTerminal returns an approver prompting on the terminal at path
+func NewTerminal(path string) *Terminal {
+	return &Terminal{path: path}
+}
+
+// Approve implements Approver
+func (t *Terminal) Approve(ctx context.Context, request Request) (Decision, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tty, err := os.OpenFile(t.path, os.O_RDWR, 0)
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to open terminal to ask for approval: %w", err)
+	}
+	defer tty.Close()
+	// Give up waiting for an answer once ctx is done
+	stop := context.AfterFunc(ctx, func() {
+		_ = tty.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	if _, err := fmt.Fprintf(tty, "\n%s\n[y/N] ", describe(request)); err != nil {
+		return Decision{}, fmt.Errorf("failed to ask for approval: %w", err)
+	}
+	answer, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil {
+		if ctx.Err() != nil {
+			fmt.Fprintln(tty, "\nNo answer, denied.")
+			return Decision{}, fmt.Errorf("no answer to approve with: %w", context.Cause(ctx))
+		}
+		return Decision{}, fmt.Errorf("failed to read approval: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return Decision{Approved: true}, nil
+	}
+	return Decision{Reason: "denied on the terminal"}, nil
+}
+
+// Webhook POSTs the Request as JSON to a URL, which responds with the
+// Decision as JSON
+type Webhook struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewWebhook returns an approver asking the webhook of cfg. Requests are
+// bounded by the context of the call, not by a timeout of their own.
+func NewWebhook(cfg *config.WebhookConfig) *Webhook {
+	webhook := &Webhook{client: &http.Client{}}
+	if cfg != nil {
+		webhook.url, webhook.headers = cfg.URL, cfg.Headers
+	}
+	return webhook
+}
+
+// Approve implements Approver
+func (w *Webhook) Approve(ctx context.Context, request Request) (Decision, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return Decision{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to create approval request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to request approval: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return Decision{}, fmt.Errorf("approval request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	var decision Decision
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return Decision{}, fmt.Errorf("failed to decode approval: %w", err)
+	}
+	return decision, nil
+}
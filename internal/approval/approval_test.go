@@ -0,0 +1,106 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/redact"
+)
+
+var testRequest = Request{
+	ToolPath:    "db.drop_table",
+	Server:      "db",
+	Tool:        "drop_table",
+	Arguments:   map[string]interface{}{"table": "users"},
+	Destructive: true,
+}
+
+// TestWebhookPostsCallsToApprove verifies that the webhook is POSTed the call
+// with the configured headers, and that its answer is the decision.
+func TestWebhookPostsCallsToApprove(t *testing.T) {
+	var got Request
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(Decision{Approved: false, Reason: "outside business hours"})
+	}))
+	defer srv.Close()
+
+	webhook := NewWebhook(&config.WebhookConfig{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer secret"}})
+	decision, err := webhook.Approve(context.Background(), testRequest)
+	require.NoError(t, err)
+	assert.Equal(t, Decision{Reason: "outside business hours"}, decision)
+	assert.Equal(t, testRequest, got)
+
+	status = http.StatusInternalServerError
+	_, err = webhook.Approve(context.Background(), testRequest)
+	assert.ErrorContains(t, err, "status 500")
+}
+
+// TestElicitationApprovesOnAccept verifies that only accepting the
+// elicitation, without unticking approve, approves the call.
+func TestElicitationApprovesOnAccept(t *testing.T) {
+	for name, tc := range map[string]struct {
+		result   mcp.ElicitationResult
+		approved bool
+	}{
+		"accept":            {result: mcp.ElicitationResult{ElicitationResponse: mcp.ElicitationResponse{Action: mcp.ElicitationResponseActionAccept, Content: map[string]interface{}{"approve": true}}}, approved: true},
+		"accept unticked":   {result: mcp.ElicitationResult{ElicitationResponse: mcp.ElicitationResponse{Action: mcp.ElicitationResponseActionAccept, Content: map[string]interface{}{"approve": false}}}},
+		"accept no content": {result: mcp.ElicitationResult{ElicitationResponse: mcp.ElicitationResponse{Action: mcp.ElicitationResponseActionAccept}}, approved: true},
+		"decline":           {result: mcp.ElicitationResult{ElicitationResponse: mcp.ElicitationResponse{Action: mcp.ElicitationResponseActionDecline}}},
+		"cancel":            {result: mcp.ElicitationResult{ElicitationResponse: mcp.ElicitationResponse{Action: mcp.ElicitationResponseActionCancel}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			approver := Elicitation(func(ctx context.Context, request mcp.ElicitationRequest) (*mcp.ElicitationResult, error) {
+				assert.Contains(t, request.Params.Message, "drop_table")
+				assert.Contains(t, request.Params.Message, `"table": "users"`)
+				return &tc.result, nil
+			})
+			decision, err := approver.Approve(context.Background(), testRequest)
+			require.NoError(t, err)
+			assert.Equal(t, tc.approved, decision.Approved)
+		})
+	}
+}
+
+// TestRedactedMasksArguments verifies that the secrets among the arguments of
+// a call are masked before they are sent to the webhook or shown to the user.
+func TestRedactedMasksArguments(t *testing.T) {
+	redactor, err := redact.New(nil)
+	require.NoError(t, err)
+	request := testRequest
+	request.Arguments = map[string]interface{}{"table": "users", "apiKey": "sk-123", "query": "password=hunter2"}
+
+	var got Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		_ = json.NewEncoder(w).Encode(Decision{Approved: true})
+	}))
+	defer srv.Close()
+	webhook := New(&config.ApprovalConfig{Via: config.ApprovalViaWebhook, Webhook: &config.WebhookConfig{URL: srv.URL}}, nil, redactor)
+	_, err = webhook.Approve(context.Background(), request)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"table": "users", "apiKey": redact.Mask, "query": "password=" + redact.Mask}, got.Arguments)
+
+	var message string
+	elicitation := New(&config.ApprovalConfig{}, func(ctx context.Context, request mcp.ElicitationRequest) (*mcp.ElicitationResult, error) {
+		message = request.Params.Message
+		return &mcp.ElicitationResult{ElicitationResponse: mcp.ElicitationResponse{Action: mcp.ElicitationResponseActionAccept}}, nil
+	}, redactor)
+	_, err = elicitation.Approve(context.Background(), request)
+	require.NoError(t, err)
+	assert.Contains(t, message, `"table": "users"`)
+	assert.NotContains(t, message, "sk-123")
+	assert.NotContains(t, message, "hunter2")
+	assert.Equal(t, "sk-123", request.Arguments["apiKey"], "the call's own arguments are left alone")
+}
@@ -0,0 +1,211 @@
+// Package audit keeps an append-only record of every tool call proxied by
+// lazy-mcp.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+	"github.com/voicetreelab/lazy-mcp/internal/redact"
+)
+
+// Outcomes of a call
+const (
+	OutcomeOK        = "ok"
+	OutcomeToolError = "tool_error" // The tool ran but reported an error
+	OutcomeError     = "error"      // The call could not be completed
+)
+
+const dayLayout = "2006-01-02"
+
+// Entry is one line of the audit log
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Session   string    `json:"session,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	ToolPath  string    `json:"tool_path"`
+	Server    string    `json:"server,omitempty"`
+	Tool      string    `json:"tool,omitempty"`
+	// ArgumentsSHA256 identifies the arguments without recording them
+	ArgumentsSHA256 string `json:"arguments_sha256"`
+	ResultBytes     int    `json:"result_bytes"`
+	Outcome         string `json:"outcome"`
+	Error           string `json:"error,omitempty"`
+	DurationMS      int64  `json:"duration_ms"`
+}
+
+// Call describes a finished tool call to be recorded
+type Call struct {
+	ToolPath  string
+	Server    string // Empty if the tool path did not resolve
+	Tool      string
+	Arguments map[string]interface{}
+	Start     time.Time
+	Result    *mcp.CallToolResult
+	Err       error
+}
+
+// Log writes entries to one JSON Lines file per UTC day. A nil *Log records
+// nothing.
+type Log struct {
+	dir           string
+	retentionDays int
+	redactor      *redact.Redactor
+	now           func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	day  string // Day of file
+}
+
+// Open starts the audit log configured by cfg, or returns nil if cfg is nil.
+// Secrets are masked by redactor before errors are recorded and arguments
+// hashed.
+func Open(cfg *config.AuditConfig, redactor *redact.Redactor) (*Log, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	if cfg.Dir == "" {
+		return nil, errors.New("audit.dir is required")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	l := &Log{dir: cfg.Dir, retentionDays: cfg.RetentionDays, redactor: redactor, now: time.Now}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.rotate(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Record appends an entry for call. The call has already happened, so a
+// failure to write is logged rather than returned.
+func (l *Log) Record(ctx context.Context, call Call) {
+	if l == nil {
+		return
+	}
+
+	entry := Entry{
+		Time:            call.Start.UTC(),
+		RequestID:       logging.RequestID(ctx),
+		ToolPath:        call.ToolPath,
+		Server:          call.Server,
+		Tool:            call.Tool,
+		ArgumentsSHA256: digest(l.redactor.Map(call.Arguments)),
+		Outcome:         OutcomeOK,
+		DurationMS:      time.Since(call.Start).Milliseconds(),
+	}
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		entry.Session = session.SessionID()
+	}
+	switch {
+	case call.Err != nil:
+		entry.Outcome = OutcomeError
+		entry.Error = l.redactor.String(call.Err.Error())
+	case call.Result != nil && call.Result.IsError:
+		entry.Outcome = OutcomeToolError
+	}
+	if call.Result != nil {
+		if data, err := json.Marshal(call.Result); err == nil {
+			entry.ResultBytes = len(data)
+		}
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to encode audit record", "error", err)
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err = l.rotate()
+	if err == nil {
+		_, err = l.file.Write(line)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to write audit record", "error", err)
+	}
+}
+
+// Close closes the current file
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// rotate switches to today's file, deleting expired files when the day
+// changes. l.mu must be held.
+func (l *Log) rotate() error {
+	now := l.now().UTC()
+	day := now.Format(dayLayout)
+	if l.file != nil && day == l.day {
+		return nil
+	}
+
+	file, err := os.OpenFile(filepath.Join(l.dir, "audit-"+day+".jsonl"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if l.file != nil {
+		_ = l.file.Close()
+	}
+	l.file, l.day = file, day
+	l.prune(now)
+	return nil
+}
+
+// prune deletes the files whose every entry is older than the retention period
+func (l *Log) prune(now time.Time) {
+	if l.retentionDays <= 0 {
+		return
+	}
+	cutoff := now.AddDate(0, 0, -l.retentionDays).Format(dayLayout)
+	files, _ := filepath.Glob(filepath.Join(l.dir, "audit-*.jsonl"))
+	for _, file := range files {
+		day := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "audit-"), ".jsonl")
+		if _, err := time.Parse(dayLayout, day); err != nil || day >= cutoff {
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			slog.Warn("Failed to delete expired audit log", "file", file, "error", err)
+		}
+	}
+}
+
+// digest hashes the JSON encoding of arguments, whose keys encoding/json sorts
+func digest(arguments map[string]interface{}) string {
+	data, err := json.Marshal(arguments)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+func readEntries(t *testing.T, file string) []Entry {
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
+
+// TestRecordWritesOneLinePerCall verifies the fields recorded for successful,
+// failed and tool-error calls, and that arguments are only stored as a digest.
+func TestRecordWritesOneLinePerCall(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(&config.AuditConfig{Dir: dir}, nil)
+	require.NoError(t, err)
+
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	start := time.Now()
+	l.Record(ctx, Call{
+		ToolPath:  "search.query",
+		Server:    "search",
+		Tool:      "query",
+		Arguments: map[string]interface{}{"q": "secret", "limit": 5},
+		Start:     start,
+		Result:    mcp.NewToolResultText("found"),
+	})
+	l.Record(ctx, Call{ToolPath: "search.query", Server: "search", Tool: "query", Start: start, Result: mcp.NewToolResultError("bad query")})
+	l.Record(ctx, Call{ToolPath: "missing.tool", Start: start, Err: errors.New("tool not found: missing.tool")})
+	require.NoError(t, l.Close())
+
+	file := filepath.Join(dir, "audit-"+time.Now().UTC().Format(dayLayout)+".jsonl")
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+
+	entries := readEntries(t, file)
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, "req-1", entries[0].RequestID)
+	assert.Equal(t, "search", entries[0].Server)
+	assert.Equal(t, "query", entries[0].Tool)
+	assert.Equal(t, OutcomeOK, entries[0].Outcome)
+	assert.Positive(t, entries[0].ResultBytes)
+	assert.Len(t, entries[0].ArgumentsSHA256, 64)
+	assert.Equal(t, digest(map[string]interface{}{"limit": 5, "q": "secret"}), entries[0].ArgumentsSHA256)
+
+	assert.Equal(t, OutcomeToolError, entries[1].Outcome)
+
+	assert.Equal(t, OutcomeError, entries[2].Outcome)
+	assert.Equal(t, "tool not found: missing.tool", entries[2].Error)
+	assert.Empty(t, entries[2].Server)
+}
+
+// TestRotationPrunesExpiredFiles verifies that a new file is started each day
+// and files older than the retention period are deleted.
+func TestRotationPrunesExpiredFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"audit-2026-01-01.jsonl", "audit-2026-01-08.jsonl", "notes.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("{}\n"), 0o600))
+	}
+
+	now := time.Date(2026, 1, 10, 23, 59, 0, 0, time.UTC)
+	l := &Log{dir: dir, retentionDays: 7, now: func() time.Time { return now }}
+	l.Record(context.Background(), Call{ToolPath: "a.b", Start: now})
+	now = now.Add(2 * time.Minute)
+	l.Record(context.Background(), Call{ToolPath: "a.b", Start: now})
+	require.NoError(t, l.Close())
+
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	for i := range names {
+		names[i] = filepath.Base(names[i])
+	}
+	assert.ElementsMatch(t, []string{"audit-2026-01-08.jsonl", "audit-2026-01-10.jsonl", "audit-2026-01-11.jsonl", "notes.txt"}, names)
+	assert.Len(t, readEntries(t, filepath.Join(dir, "audit-2026-01-11.jsonl")), 1)
+}
@@ -0,0 +1,187 @@
+// Package cassette records the tool calls lazy-mcp forwards to its servers in
+// a file, and replays them from it without starting the servers, so tests of
+// agents can run deterministically and offline.
+package cassette
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+)
+
+// ErrNotRecorded is returned when replaying a call the cassette has no
+// recording of
+var ErrNotRecorded = errors.New("call not recorded")
+
+// Interaction is a recorded call and its outcome
+type Interaction struct {
+	ToolPath  string                 `json:"tool_path"`
+	Server    string                 `json:"server"`
+	Tool      string                 `json:"tool"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Result    *mcp.CallToolResult    `json:"result,omitempty"`
+	// Error is why the call failed, if it did
+	Error string `json:"error,omitempty"`
+}
+
+// cassette is the file format
+type cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Open returns the middleware that records or replays calls as cfg says, or
+// nil if cfg is nil. Recording starts a new cassette, replacing the file.
+func Open(cfg *config.CassetteConfig) (hierarchy.CallMiddleware, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	switch cfg.Mode {
+	case config.CassetteModeRecord:
+		slog.Info("Recording tool calls", "cassette", cfg.File)
+		return NewRecorder(cfg.File).Middleware, nil
+	case config.CassetteModeReplay:
+		player, err := Load(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("Replaying tool calls, not starting servers", "cassette", cfg.File)
+		return player.Middleware, nil
+	}
+	return nil, fmt.Errorf("unknown cassette mode %q", cfg.Mode)
+}
+
+// Recorder saves calls to a cassette as they finish
+type Recorder struct {
+	path string
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecorder records to a new cassette at path
+func NewRecorder(path string) *Recorder {
+	return &Recorder{path: path}
+}
+
+// Middleware forwards calls and records them with their outcome
+func (r *Recorder) Middleware(next hierarchy.CallHandler) hierarchy.CallHandler {
+	return func(ctx context.Context, call *hierarchy.ToolCall) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, call)
+		interaction := Interaction{
+			ToolPath:  call.ToolPath,
+			Server:    call.Server,
+			Tool:      call.Tool,
+			Arguments: call.Arguments,
+			Result:    result,
+		}
+		if err != nil {
+			interaction.Error = err.Error()
+		}
+		if saveErr := r.record(interaction); saveErr != nil {
+			slog.Warn("Failed to record tool call", "path", call.ToolPath, "cassette", r.path, "error", saveErr)
+		}
+		return result, err
+	}
+}
+
+// record adds interaction and saves the cassette, so that what was recorded
+// survives lazy-mcp being killed
+func (r *Recorder) record(interaction Interaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, interaction)
+
+	data, err := json.MarshalIndent(cassette{Interactions: r.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+// Player answers calls from a cassette. Calls match recordings by tool path
+// and arguments; the same call made again gets the next recording of it, and
+// the last one once they run out.
+type Player struct {
+	mu       sync.Mutex
+	recorded map[string][]Interaction
+	replayed map[string]int
+}
+
+// Load reads the cassette at path to replay it
+func Load(path string) (*Player, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var c cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	p := &Player{
+		recorded: make(map[string][]Interaction),
+		replayed: make(map[string]int),
+	}
+	for _, interaction := range c.Interactions {
+		key := callKey(interaction.ToolPath, interaction.Arguments)
+		p.recorded[key] = append(p.recorded[key], interaction)
+	}
+	return p, nil
+}
+
+// Middleware answers calls with their recorded outcome, without passing them
+// on, so no server is started
+func (p *Player) Middleware(next hierarchy.CallHandler) hierarchy.CallHandler {
+	return func(ctx context.Context, call *hierarchy.ToolCall) (*mcp.CallToolResult, error) {
+		interaction, ok := p.next(call)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s with these arguments", ErrNotRecorded, call.ToolPath)
+		}
+		if interaction.Error != "" {
+			return nil, errors.New(interaction.Error)
+		}
+		return interaction.Result, nil
+	}
+}
+
+// next returns the recording to answer call with
+func (p *Player) next(call *hierarchy.ToolCall) (Interaction, bool) {
+	key := callKey(call.ToolPath, call.Arguments)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	recorded := p.recorded[key]
+	if len(recorded) == 0 {
+		return Interaction{}, false
+	}
+	i := min(p.replayed[key], len(recorded)-1)
+	p.replayed[key]++
+	return recorded[i], true
+}
+
+// callKey identifies a call by its tool path and arguments, as JSON with
+// sorted keys
+func callKey(toolPath string, arguments map[string]interface{}) string {
+	if len(arguments) == 0 {
+		return toolPath
+	}
+	data, err := json.Marshal(arguments)
+	if err != nil {
+		return toolPath
+	}
+	return toolPath + " " + string(data)
+}
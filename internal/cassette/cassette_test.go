@@ -0,0 +1,82 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+)
+
+func textOf(t *testing.T, result *mcp.CallToolResult) string {
+	require.NotNil(t, result)
+	require.Len(t, result.Content, 1)
+	return result.Content[0].(mcp.TextContent).Text
+}
+
+// TestRecordAndReplay verifies that recorded calls are replayed by tool path
+// and arguments, in order, without reaching the servers.
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calls.json")
+	recording, err := Open(&config.CassetteConfig{File: path, Mode: config.CassetteModeRecord})
+	require.NoError(t, err)
+
+	count := 0
+	record := recording(func(ctx context.Context, call *hierarchy.ToolCall) (*mcp.CallToolResult, error) {
+		count++
+		if call.Tool == "broken" {
+			return nil, errors.New("server unavailable")
+		}
+		return mcp.NewToolResultText(call.Arguments["message"].(string) + " " + string(rune('0'+count))), nil
+	})
+	ctx := context.Background()
+	for _, call := range []*hierarchy.ToolCall{
+		{ToolPath: "echo", Server: "everything", Tool: "echo", Arguments: map[string]interface{}{"message": "hi"}},
+		{ToolPath: "echo", Server: "everything", Tool: "echo", Arguments: map[string]interface{}{"message": "hi"}},
+		{ToolPath: "echo", Server: "everything", Tool: "echo", Arguments: map[string]interface{}{"message": "bye"}},
+		{ToolPath: "broken", Server: "everything", Tool: "broken"},
+	} {
+		_, _ = record(ctx, call)
+	}
+
+	replaying, err := Open(&config.CassetteConfig{File: path, Mode: config.CassetteModeReplay})
+	require.NoError(t, err)
+	replay := replaying(func(ctx context.Context, call *hierarchy.ToolCall) (*mcp.CallToolResult, error) {
+		t.Fatal("replayed call reached the server")
+		return nil, nil
+	})
+	hi := &hierarchy.ToolCall{ToolPath: "echo", Arguments: map[string]interface{}{"message": "hi"}}
+
+	result, err := replay(ctx, hi)
+	require.NoError(t, err)
+	assert.Equal(t, "hi 1", textOf(t, result))
+	result, err = replay(ctx, &hierarchy.ToolCall{ToolPath: "echo", Arguments: map[string]interface{}{"message": "bye"}})
+	require.NoError(t, err)
+	assert.Equal(t, "bye 3", textOf(t, result))
+	// The second recording, then the last one again
+	for _, want := range []string{"hi 2", "hi 2"} {
+		result, err = replay(ctx, hi)
+		require.NoError(t, err)
+		assert.Equal(t, want, textOf(t, result))
+	}
+
+	_, err = replay(ctx, &hierarchy.ToolCall{ToolPath: "broken"})
+	assert.EqualError(t, err, "server unavailable")
+	_, err = replay(ctx, &hierarchy.ToolCall{ToolPath: "echo", Arguments: map[string]interface{}{"message": "new"}})
+	assert.ErrorIs(t, err, ErrNotRecorded)
+}
+
+// TestOpenMissingCassette verifies that replaying needs an existing cassette.
+func TestOpenMissingCassette(t *testing.T) {
+	_, err := Open(&config.CassetteConfig{File: filepath.Join(t.TempDir(), "missing.json"), Mode: config.CassetteModeReplay})
+	assert.ErrorContains(t, err, "failed to read cassette")
+
+	recording, err := Open(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, recording)
+}
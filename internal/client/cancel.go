@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// MethodNotificationCancelled is the method of cancellation notifications,
+// for which mcp-go has no constant
+const MethodNotificationCancelled = "notifications/cancelled"
+
+// cancelNotifyTimeout bounds sending a cancellation notification, so that a
+// stuck server cannot hold up the caller that gave up on it
+const cancelNotifyTimeout = 5 * time.Second
+
+// cancellingTransport tells the server when a request is abandoned because
+// its context ended, which the mcp-go client does not do itself. The server
+// can then stop working on it.
+type cancellingTransport struct {
+	transport.Interface
+}
+
+func (t *cancellingTransport) SendRequest(ctx context.Context, request transport.JSONRPCRequest) (*transport.JSONRPCResponse, error) {
+	response, err := t.Interface.SendRequest(ctx, request)
+	// The initialize request must not be cancelled
+	if err != nil && ctx.Err() != nil && request.Method != string(mcp.MethodInitialize) {
+		t.cancelled(request.ID, context.Cause(ctx))
+	}
+	return response, err
+}
+
+func (t *cancellingTransport) cancelled(id mcp.RequestId, cause error) {
+	notification := mcp.JSONRPCNotification{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		Notification: mcp.Notification{
+			Method: MethodNotificationCancelled,
+			Params: mcp.NotificationParams{AdditionalFields: map[string]any{
+				"requestId": id,
+				"reason":    cause.Error(),
+			}},
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cancelNotifyTimeout)
+	defer cancel()
+	_ = t.Interface.SendNotification(ctx, notification)
+}
+
+// The mcp-go client discovers optional transport features by type
+// assertion, so they are passed through explicitly
+
+func (t *cancellingTransport) SetRequestHandler(handler transport.RequestHandler) {
+	if bidirectional, ok := t.Interface.(transport.BidirectionalInterface); ok {
+		bidirectional.SetRequestHandler(handler)
+	}
+}
+
+func (t *cancellingTransport) SetConnectionLostHandler(handler func(error)) {
+	if setter, ok := t.Interface.(interface{ SetConnectionLostHandler(func(error)) }); ok {
+		setter.SetConnectionLostHandler(handler)
+	}
+}
+
+func (t *cancellingTransport) SetProtocolVersion(version string) {
+	if httpConn, ok := t.Interface.(transport.HTTPConnection); ok {
+		httpConn.SetProtocolVersion(version)
+	}
+}
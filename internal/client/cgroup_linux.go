@@ -0,0 +1,197 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// cgroupRoot is where the cgroup v2 hierarchy is mounted
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cgroup2SuperMagic identifies the cgroup v2 file system
+const cgroup2SuperMagic = 0x63677270
+
+// cgroupCPUPeriod is the period, in microseconds, over which cpu.max
+// allots a cgroup its CPU time
+const cgroupCPUPeriod = 100000
+
+var (
+	cgroupParentOnce sync.Once
+	cgroupParent     string          // The cgroup servers' cgroups are created in
+	cgroupEnabled    map[string]bool // The controllers enabled for them
+	cgroupParentErr  error
+)
+
+// cgroup is the cgroup v2 a server's process runs in
+type cgroup struct {
+	dir string
+	fd  *os.File // Open until the process has started in it
+}
+
+// newCgroup creates a cgroup with the given limits below lazy-mcp's own
+func newCgroup(limits *config.ResourceLimitsConfig) (*cgroup, error) {
+	cgroupParentOnce.Do(func() {
+		cgroupParent, cgroupEnabled, cgroupParentErr = delegateCgroup()
+	})
+	if cgroupParentErr != nil {
+		return nil, cgroupParentErr
+	}
+	for controller, limited := range map[string]bool{"memory": limits.MemoryMB > 0, "cpu": limits.CPUs > 0, "pids": limits.Processes > 0} {
+		if limited && !cgroupEnabled[controller] {
+			return nil, fmt.Errorf("the %s controller is not available in %s", controller, cgroupParent)
+		}
+	}
+
+	dir, err := os.MkdirTemp(cgroupParent, "lazy-mcp-*")
+	if err != nil {
+		return nil, err
+	}
+	settings := map[string]string{}
+	if limits.MemoryMB > 0 {
+		settings["memory.max"] = strconv.Itoa(limits.MemoryMB << 20)
+	}
+	if limits.CPUs > 0 {
+		settings["cpu.max"] = fmt.Sprintf("%d %d", max(int(limits.CPUs*cgroupCPUPeriod), 1000), cgroupCPUPeriod)
+	}
+	if limits.Processes > 0 {
+		settings["pids.max"] = strconv.Itoa(limits.Processes)
+	}
+	for file, value := range settings {
+		if err := writeCgroupFile(filepath.Join(dir, file), value); err != nil {
+			_ = os.Remove(dir)
+			return nil, err
+		}
+	}
+	if limits.MemoryMB > 0 {
+		// Swapping out a runaway server would bring the machine to a crawl
+		// instead; not every kernel has swap accounting
+		_ = writeCgroupFile(filepath.Join(dir, "memory.swap.max"), "0")
+	}
+
+	fd, err := os.Open(dir)
+	if err != nil {
+		_ = os.Remove(dir)
+		return nil, err
+	}
+	return &cgroup{dir: dir, fd: fd}, nil
+}
+
+// delegateCgroup returns the cgroup, lazy-mcp's own, that servers' cgroups
+// are created in, and which of the controllers of their limits it could
+// enable for them. cgroups v2 only let a cgroup without processes of its own
+// enable controllers, so lazy-mcp moves itself into a leaf cgroup if it has
+// to.
+func delegateCgroup() (string, map[string]bool, error) {
+	// Hybrid hierarchies mount cgroups v1 there instead
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(cgroupRoot, &fs); err != nil || fs.Type != cgroup2SuperMagic {
+		return "", nil, errors.New("cgroups v2 are not mounted at " + cgroupRoot)
+	}
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", nil, err
+	}
+	var own string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			own = filepath.Join(cgroupRoot, path)
+		}
+	}
+	if own == "" {
+		return "", nil, errors.New("cgroups v2 are not in use")
+	}
+
+	subtreeControl := filepath.Join(own, "cgroup.subtree_control")
+	enabled := map[string]bool{}
+	for _, controller := range []string{"memory", "cpu", "pids"} {
+		err := writeCgroupFile(subtreeControl, "+"+controller)
+		if errors.Is(err, syscall.EBUSY) {
+			if err := moveToLeafCgroup(own); err != nil {
+				return "", nil, err
+			}
+			err = writeCgroupFile(subtreeControl, "+"+controller)
+		}
+		// The controllers not delegated to lazy-mcp stay disabled
+		enabled[controller] = err == nil
+	}
+	return own, enabled, nil
+}
+
+// moveToLeafCgroup moves lazy-mcp from the cgroup own into a new child of it
+func moveToLeafCgroup(own string) error {
+	leaf := filepath.Join(own, "lazy-mcp")
+	if err := os.Mkdir(leaf, 0o755); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+	if err := writeCgroupFile(filepath.Join(leaf, "cgroup.procs"), strconv.Itoa(os.Getpid())); err != nil {
+		return fmt.Errorf("failed to move lazy-mcp into a leaf cgroup: %w", err)
+	}
+	return nil
+}
+
+// writeCgroupFile sets one of a cgroup's interface files, which cannot be
+// created, unlike plain files
+func writeCgroupFile(name, value string) error {
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(value)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// attach makes cmd start its process in the cgroup
+func (g *cgroup) attach(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(g.fd.Fd())
+}
+
+// started closes the cgroup, which the process now runs in
+func (g *cgroup) started() {
+	_ = g.fd.Close()
+}
+
+// release kills the processes left in the cgroup and removes it, and logs
+// whether the server ran out of memory
+func (g *cgroup) release(server string) {
+	_ = g.fd.Close()
+	if events, err := os.ReadFile(filepath.Join(g.dir, "memory.events")); err == nil {
+		for _, line := range strings.Split(string(events), "\n") {
+			if count, ok := strings.CutPrefix(line, "oom_kill "); ok && count != "0" {
+				logging.ForServer(server).Warn("Server was killed for exceeding limits.memoryMB")
+			}
+		}
+	}
+	// Processes the server started may outlive it
+	_ = writeCgroupFile(filepath.Join(g.dir, "cgroup.kill"), "1")
+	for attempt := 0; ; attempt++ {
+		err := os.Remove(g.dir)
+		if err == nil || errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		if attempt == 20 {
+			logging.ForServer(server).Warn("Failed to remove cgroup", "cgroup", g.dir, "error", err)
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
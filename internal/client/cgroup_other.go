@@ -0,0 +1,24 @@
+//go:build unix && !linux
+
+package client
+
+import (
+	"os/exec"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// cgroup stands in for the cgroups of Linux, which other systems lack
+type cgroup struct{}
+
+// newCgroup returns no cgroup, config validation having warned about the
+// limits left unenforced
+func newCgroup(*config.ResourceLimitsConfig) (*cgroup, error) {
+	return nil, nil
+}
+
+func (g *cgroup) attach(*exec.Cmd) {}
+
+func (g *cgroup) started() {}
+
+func (g *cgroup) release(string) {}
@@ -5,13 +5,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"strings"
 	"sync"
-	"time"
+	"sync/atomic"
 
 	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+	"github.com/voicetreelab/lazy-mcp/internal/oauth"
+	"github.com/voicetreelab/lazy-mcp/internal/telemetry"
 	"github.com/mark3labs/mcp-go/client"
 	"github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
@@ -22,8 +26,15 @@ type Client struct {
 	name            string
 	needPing        bool
 	needManualStart bool
+	resumable       bool // The session outlives failed requests; see startPingTask
 	client          *client.Client
 	options         *config.OptionsV2
+	process         *childProcess // Stdio server subprocess, nil for other transports
+	// Connection supervision; lost is nil for transports that are not supervised
+	lost     chan struct{}
+	lostErr  error
+	lostOnce sync.Once
+	closing  atomic.Bool
 	// Lazy loading fields
 	mcpServer     *server.MCPServer
 	lazyTools     []mcp.Tool
@@ -34,66 +45,229 @@ type Client struct {
 	activated     bool
 }
 
-func NewMCPClient(name string, conf *config.MCPClientConfigV2) (*Client, error) {
+// Option configures how a Client answers requests from its server
+type Option func(*clientOptions)
+
+type clientOptions struct {
+	sampling    client.SamplingHandler
+	elicitation client.ElicitationHandler
+	roots       client.RootsHandler
+	stderr      io.Writer
+}
+
+// WithSamplingHandler declares the sampling capability to the server and
+// answers its sampling/createMessage requests with handler. The SSE
+// transport cannot carry requests from the server, so there it has no effect.
+func WithSamplingHandler(handler client.SamplingHandler) Option {
+	return func(o *clientOptions) {
+		o.sampling = handler
+	}
+}
+
+// WithElicitationHandler declares the elicitation capability to the server
+// and answers its elicitation/create requests with handler. Like sampling, it
+// has no effect over SSE.
+func WithElicitationHandler(handler client.ElicitationHandler) Option {
+	return func(o *clientOptions) {
+		o.elicitation = handler
+	}
+}
+
+// WithRootsHandler declares the roots capability to the server and answers
+// its roots/list requests with handler. Like sampling, it has no effect over
+// SSE.
+func WithRootsHandler(handler client.RootsHandler) Option {
+	return func(o *clientOptions) {
+		o.roots = handler
+	}
+}
+
+// WithStderr copies what a stdio or Docker server writes to stderr to w, as
+// well as keeping its end for Stderr. Other transports have no stderr.
+func WithStderr(w io.Writer) Option {
+	return func(o *clientOptions) {
+		o.stderr = w
+	}
+}
+
+func newClientOptions(options []Option) clientOptions {
+	var o clientOptions
+	for _, option := range options {
+		option(&o)
+	}
+	return o
+}
+
+// mcpOptions converts o to options for the mcp-go client
+func (o clientOptions) mcpOptions() []client.ClientOption {
+	var options []client.ClientOption
+	if o.sampling != nil {
+		options = append(options, client.WithSamplingHandler(o.sampling))
+	}
+	if o.elicitation != nil {
+		options = append(options, client.WithElicitationHandler(o.elicitation))
+	}
+	if o.roots != nil {
+		options = append(options, client.WithRootsHandler(o.roots))
+	}
+	return options
+}
+
+func NewMCPClient(name string, conf *config.MCPClientConfigV2, options ...Option) (*Client, error) {
+	clientOptions := newClientOptions(options)
 	clientInfo, pErr := config.ParseMCPClientConfigV2(conf)
 	if pErr != nil {
 		return nil, pErr
 	}
 	switch v := clientInfo.(type) {
 	case *config.StdioMCPClientConfig:
-		envs := make([]string, 0, len(v.Env))
-		for kk, vv := range v.Env {
-			envs = append(envs, fmt.Sprintf("%s=%s", kk, vv))
-		}
-		mcpClient, err := client.NewStdioMCPClient(v.Command, envs, v.Args...)
+		process, err := startChildProcess(name, "", v.Command, v.Args, v.Process, v.Env, clientOptions.stderr)
 		if err != nil {
 			return nil, err
 		}
-
-		return &Client{
-			name:    name,
-			client:  mcpClient,
-			options: conf.Options,
-		}, nil
-	case *config.SSEMCPClientConfig:
-		var options []transport.ClientOption
-		if len(v.Headers) > 0 {
-			options = append(options, client.WithHeaders(v.Headers))
-		}
-		mcpClient, err := client.NewSSEMCPClient(v.URL, options...)
+		return newProcessClient(name, process, conf.Options, clientOptions), nil
+	case *config.DockerMCPClientConfig:
+		process, err := startContainer(name, v, clientOptions.stderr)
 		if err != nil {
 			return nil, err
 		}
-		return &Client{
+		return newProcessClient(name, process, conf.Options, clientOptions), nil
+	case *config.SSEMCPClientConfig:
+		c := &Client{
 			name:            name,
 			needPing:        true,
 			needManualStart: true,
-			client:          mcpClient,
 			options:         conf.Options,
-		}, nil
+			lost:            make(chan struct{}),
+		}
+		base, err := baseTransport(name, v.TLS, v.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		// The SSE transport drops a broken event stream without telling anyone,
+		// leaving calls to wait for responses that can no longer arrive
+		httpClient := &http.Client{Transport: &streamWatcher{
+			base: base,
+			onEnd: func(err error) {
+				c.markLost(fmt.Errorf("%w: SSE stream ended: %v", ErrConnectionLost, err))
+			},
+		}}
+		options := []transport.ClientOption{
+			client.WithHTTPClient(httpClient),
+			transport.WithHeaderFunc(telemetry.InjectHeaders),
+		}
+		if len(v.Headers) > 0 {
+			options = append(options, client.WithHeaders(v.Headers))
+		}
+		if v.OAuth != nil {
+			oauthConfig, err := oauth.TransportConfig(context.Background(), name, v.OAuth)
+			if err != nil {
+				return nil, fmt.Errorf("failed to configure OAuth: %w", err)
+			}
+			oauthConfig.HTTPClient = oauthHTTPClient(base)
+			options = append(options, transport.WithOAuth(oauthConfig))
+		}
+		sseTransport, err := transport.NewSSE(v.URL, options...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SSE transport: %w", err)
+		}
+		mcpClient := client.NewClient(&cancellingTransport{sseTransport}, clientOptions.mcpOptions()...)
+		mcpClient.OnConnectionLost(func(err error) {
+			c.markLost(fmt.Errorf("%w: %v", ErrConnectionLost, err))
+		})
+		c.client = mcpClient
+		return c, nil
 	case *config.StreamableMCPClientConfig:
-		var options []transport.StreamableHTTPCOption
+		base, err := baseTransport(name, v.TLS, v.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		options := []transport.StreamableHTTPCOption{transport.WithHTTPHeaderFunc(telemetry.InjectHeaders)}
+		if base != http.DefaultTransport {
+			// Before the timeout, which is set on this client
+			options = append(options, transport.WithHTTPBasicClient(&http.Client{Transport: base}))
+		}
 		if len(v.Headers) > 0 {
 			options = append(options, transport.WithHTTPHeaders(v.Headers))
 		}
 		if v.Timeout > 0 {
 			options = append(options, transport.WithHTTPTimeout(v.Timeout))
 		}
-		mcpClient, err := client.NewStreamableHttpClient(v.URL, options...)
+		if v.OAuth != nil {
+			oauthConfig, err := oauth.TransportConfig(context.Background(), name, v.OAuth)
+			if err != nil {
+				return nil, fmt.Errorf("failed to configure OAuth: %w", err)
+			}
+			oauthConfig.HTTPClient = oauthHTTPClient(base)
+			options = append(options, transport.WithHTTPOAuth(oauthConfig))
+		}
+		httpTransport, err := transport.NewStreamableHTTP(v.URL, options...)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to create streamable HTTP transport: %w", err)
 		}
+		mcpClient := client.NewClient(&cancellingTransport{httpTransport}, clientOptions.mcpOptions()...)
+		// The transport tracks the session ID itself, so the session survives
+		// requests failing while the network is down; a session the server has
+		// expired surfaces as ErrSessionTerminated and is reported as lost
 		return &Client{
 			name:            name,
 			needPing:        true,
 			needManualStart: true,
+			resumable:       true,
 			client:          mcpClient,
 			options:         conf.Options,
+			lost:            make(chan struct{}),
 		}, nil
 	}
 	return nil, errors.New("invalid client type")
 }
 
+// newProcessClient returns a client talking to a stdio server process
+func newProcessClient(name string, process *childProcess, options *config.OptionsV2, clientOptions clientOptions) *Client {
+	// childProcess drains stderr itself; see Stderr
+	stdout := newMessageReader(process.stdout, maxMessageBytes(options), logging.ForServer(name))
+	stdio := transport.NewIO(stdout, process.stdin, nil)
+
+	c := &Client{
+		name:            name,
+		needManualStart: true,
+		client:          client.NewClient(&cancellingTransport{stdio}, clientOptions.mcpOptions()...),
+		options:         options,
+		process:         process,
+		lost:            make(chan struct{}),
+	}
+	go func() {
+		<-process.Done()
+		c.markLost(process.exitError())
+	}()
+	return c
+}
+
+// NewInProcessMCPClient creates a client connected directly to an MCP server
+// running in the same process, e.g. for embedding or tests.
+func NewInProcessMCPClient(name string, mcpServer *server.MCPServer, options *config.OptionsV2, clientOptions ...Option) (*Client, error) {
+	o := newClientOptions(clientOptions)
+	// The in-process transport hands requests from the server straight to
+	// the handlers
+	var transportOptions []transport.InProcessOption
+	if o.sampling != nil {
+		transportOptions = append(transportOptions, transport.WithSamplingHandler(o.sampling))
+	}
+	if o.elicitation != nil {
+		transportOptions = append(transportOptions, transport.WithElicitationHandler(o.elicitation))
+	}
+	if o.roots != nil {
+		transportOptions = append(transportOptions, transport.WithRootsHandler(o.roots))
+	}
+	mcpClient := client.NewClient(&cancellingTransport{transport.NewInProcessTransportWithOptions(mcpServer, transportOptions...)}, o.mcpOptions()...)
+	return &Client{
+		name:            name,
+		needManualStart: true,
+		client:          mcpClient,
+		options:         options,
+	}, nil
+}
+
 func (c *Client) AddToMCPServer(ctx context.Context, clientInfo mcp.Implementation, mcpServer *server.MCPServer) error {
 	// Store mcpServer reference for later activation
 	c.mcpServer = mcpServer
@@ -116,7 +290,7 @@ func (c *Client) AddToMCPServer(ctx context.Context, clientInfo mcp.Implementati
 	if err != nil {
 		return err
 	}
-	log.Printf("<%s> Successfully initialized MCP client", c.name)
+	c.logger().InfoContext(ctx, "Initialized MCP client")
 
 	// Check if lazy loading is enabled
 	if c.options != nil && c.options.LazyLoad.OrElse(false) {
@@ -154,20 +328,20 @@ func (c *Client) activateTools(ctx context.Context, request mcp.CallToolRequest)
 	var toolCount, promptCount, resourceCount, templateCount int
 
 	c.activateOnce.Do(func() {
-		log.Printf("<%s> Activating lazy-loaded tools, prompts, and resources", c.name)
+		c.logger().InfoContext(ctx, "Activating lazy-loaded tools, prompts, and resources")
 
 		// Register all stored tools
 		toolCount = 0
 		for _, tool := range c.lazyTools {
-			log.Printf("<%s> Adding tool %s", c.name, tool.Name)
-			c.mcpServer.AddTool(tool, c.client.CallTool)
+			c.logger().DebugContext(ctx, "Adding tool", "name", tool.Name)
+			c.addTool(c.mcpServer, tool)
 			toolCount++
 		}
 
 		// Register all stored prompts
 		promptCount = 0
 		for _, prompt := range c.lazyPrompts {
-			log.Printf("<%s> Adding prompt %s", c.name, prompt.Name)
+			c.logger().DebugContext(ctx, "Adding prompt", "name", prompt.Name)
 			c.mcpServer.AddPrompt(prompt, c.client.GetPrompt)
 			promptCount++
 		}
@@ -175,7 +349,7 @@ func (c *Client) activateTools(ctx context.Context, request mcp.CallToolRequest)
 		// Register all stored resources
 		resourceCount = 0
 		for _, resource := range c.lazyResources {
-			log.Printf("<%s> Adding resource %s", c.name, resource.Name)
+			c.logger().DebugContext(ctx, "Adding resource", "name", resource.Name)
 			c.mcpServer.AddResource(resource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
 				readResource, e := c.client.ReadResource(ctx, request)
 				if e != nil {
@@ -189,7 +363,7 @@ func (c *Client) activateTools(ctx context.Context, request mcp.CallToolRequest)
 		// Register all stored resource templates
 		templateCount = 0
 		for _, template := range c.lazyTemplates {
-			log.Printf("<%s> Adding resource template %s", c.name, template.Name)
+			c.logger().DebugContext(ctx, "Adding resource template", "name", template.Name)
 			c.mcpServer.AddResourceTemplate(template, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
 				readResource, e := c.client.ReadResource(ctx, request)
 				if e != nil {
@@ -207,8 +381,8 @@ func (c *Client) activateTools(ctx context.Context, request mcp.CallToolRequest)
 		c.lazyTemplates = nil
 		c.activated = true
 
-		log.Printf("<%s> Activation complete: %d tools, %d prompts, %d resources, %d templates",
-			c.name, toolCount, promptCount, resourceCount, templateCount)
+		c.logger().InfoContext(ctx, "Activation complete",
+			"tools", toolCount, "prompts", promptCount, "resources", resourceCount, "templates", templateCount)
 	})
 
 	if activationErr != nil {
@@ -292,38 +466,13 @@ func (c *Client) registerMetaTool() {
 		},
 	}
 
-	log.Printf("<%s> Registering meta-tool: %s", c.name, metaToolName)
+	c.logger().Debug("Registering meta-tool", "name", metaToolName)
 	c.mcpServer.AddTool(metaTool, c.activateTools)
 }
 
-func (c *Client) startPingTask(ctx context.Context) {
-	interval := 30 * time.Second
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	failCount := 0
-	for {
-		select {
-		case <-ctx.Done():
-			log.Printf("<%s> Context done, stopping ping", c.name)
-			return
-		case <-ticker.C:
-			if err := c.client.Ping(ctx); err != nil {
-				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-					return
-				}
-				failCount++
-				log.Printf("<%s> MCP Ping failed: %v (count=%d)", c.name, err, failCount)
-			} else if failCount > 0 {
-				log.Printf("<%s> MCP Ping recovered after %d failures", c.name, failCount)
-				failCount = 0
-			}
-		}
-	}
-}
-
-func (c *Client) addToolsToServer(ctx context.Context, mcpServer *server.MCPServer) error {
-	toolsRequest := mcp.ListToolsRequest{}
+// toolFilter returns whether to expose each of the server's tools, per its
+// toolFilter, includeTools and excludeTools options
+func (c *Client) toolFilter(ctx context.Context) func(toolName string) bool {
 	filterFunc := func(toolName string) bool {
 		return true
 	}
@@ -339,7 +488,7 @@ func (c *Client) addToolsToServer(ctx context.Context, mcpServer *server.MCPServ
 			filterFunc = func(toolName string) bool {
 				_, inList := filterSet[toolName]
 				if !inList {
-					log.Printf("<%s> Ignoring tool %s as it is not in allow list", c.name, toolName)
+					c.logger().DebugContext(ctx, "Ignoring tool as it is not in allow list", "name", toolName)
 				}
 				return inList
 			}
@@ -347,15 +496,48 @@ func (c *Client) addToolsToServer(ctx context.Context, mcpServer *server.MCPServ
 			filterFunc = func(toolName string) bool {
 				_, inList := filterSet[toolName]
 				if inList {
-					log.Printf("<%s> Ignoring tool %s as it is in block list", c.name, toolName)
+					c.logger().DebugContext(ctx, "Ignoring tool as it is in block list", "name", toolName)
 				}
 				return !inList
 			}
 		default:
-			log.Printf("<%s> Unknown tool filter mode: %s, skipping tool filter", c.name, mode)
+			c.logger().WarnContext(ctx, "Unknown tool filter mode, skipping tool filter", "mode", mode)
 		}
 	}
 
+	return func(toolName string) bool {
+		if !c.options.ToolIncluded(toolName) {
+			c.logger().DebugContext(ctx, "Ignoring tool as it is excluded by includeTools or excludeTools", "name", toolName)
+			return false
+		}
+		return filterFunc(toolName)
+	}
+}
+
+// addTool adds a tool of the server to mcpServer under its exposed name,
+// aliases and description, calling it by its own name
+func (c *Client) addTool(mcpServer *server.MCPServer, tool mcp.Tool) {
+	original := tool.Name
+	handler := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		request.Params.Name = original
+		return c.client.CallTool(ctx, request)
+	}
+	tool.Name = c.options.ExposedToolName(original)
+	tool.Description = c.options.ToolDescription(original, tool.Description)
+	mcpServer.AddTool(tool, handler)
+	if c.options == nil {
+		return
+	}
+	for _, alias := range c.options.ToolAliases[original] {
+		tool.Name = alias
+		mcpServer.AddTool(tool, handler)
+	}
+}
+
+func (c *Client) addToolsToServer(ctx context.Context, mcpServer *server.MCPServer) error {
+	toolsRequest := mcp.ListToolsRequest{}
+	filterFunc := c.toolFilter(ctx)
+
 	for {
 		tools, err := c.client.ListTools(ctx, toolsRequest)
 		if err != nil {
@@ -364,11 +546,11 @@ func (c *Client) addToolsToServer(ctx context.Context, mcpServer *server.MCPServ
 		if len(tools.Tools) == 0 {
 			break
 		}
-		log.Printf("<%s> Successfully listed %d tools", c.name, len(tools.Tools))
+		c.logger().InfoContext(ctx, "Listed tools", "count", len(tools.Tools))
 		for _, tool := range tools.Tools {
 			if filterFunc(tool.Name) {
-				log.Printf("<%s> Adding tool %s", c.name, tool.Name)
-				mcpServer.AddTool(tool, c.client.CallTool)
+				c.logger().DebugContext(ctx, "Adding tool", "name", tool.Name)
+				c.addTool(mcpServer, tool)
 			}
 		}
 		if tools.NextCursor == "" {
@@ -390,9 +572,9 @@ func (c *Client) addPromptsToServer(ctx context.Context, mcpServer *server.MCPSe
 		if len(prompts.Prompts) == 0 {
 			break
 		}
-		log.Printf("<%s> Successfully listed %d prompts", c.name, len(prompts.Prompts))
+		c.logger().InfoContext(ctx, "Listed prompts", "count", len(prompts.Prompts))
 		for _, prompt := range prompts.Prompts {
-			log.Printf("<%s> Adding prompt %s", c.name, prompt.Name)
+			c.logger().DebugContext(ctx, "Adding prompt", "name", prompt.Name)
 			mcpServer.AddPrompt(prompt, c.client.GetPrompt)
 		}
 		if prompts.NextCursor == "" {
@@ -413,9 +595,9 @@ func (c *Client) addResourcesToServer(ctx context.Context, mcpServer *server.MCP
 		if len(resources.Resources) == 0 {
 			break
 		}
-		log.Printf("<%s> Successfully listed %d resources", c.name, len(resources.Resources))
+		c.logger().InfoContext(ctx, "Listed resources", "count", len(resources.Resources))
 		for _, resource := range resources.Resources {
-			log.Printf("<%s> Adding resource %s", c.name, resource.Name)
+			c.logger().DebugContext(ctx, "Adding resource", "name", resource.Name)
 			mcpServer.AddResource(resource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
 				readResource, e := c.client.ReadResource(ctx, request)
 				if e != nil {
@@ -443,9 +625,9 @@ func (c *Client) addResourceTemplatesToServer(ctx context.Context, mcpServer *se
 		if len(resourceTemplates.ResourceTemplates) == 0 {
 			break
 		}
-		log.Printf("<%s> Successfully listed %d resource templates", c.name, len(resourceTemplates.ResourceTemplates))
+		c.logger().InfoContext(ctx, "Listed resource templates", "count", len(resourceTemplates.ResourceTemplates))
 		for _, resourceTemplate := range resourceTemplates.ResourceTemplates {
-			log.Printf("<%s> Adding resource template %s", c.name, resourceTemplate.Name)
+			c.logger().DebugContext(ctx, "Adding resource template", "name", resourceTemplate.Name)
 			mcpServer.AddResourceTemplate(resourceTemplate, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
 				readResource, e := c.client.ReadResource(ctx, request)
 				if e != nil {
@@ -465,37 +647,7 @@ func (c *Client) addResourceTemplatesToServer(ctx context.Context, mcpServer *se
 // storeToolsForLazyLoad fetches and stores tools without registering them
 func (c *Client) storeToolsForLazyLoad(ctx context.Context) error {
 	toolsRequest := mcp.ListToolsRequest{}
-	filterFunc := func(toolName string) bool {
-		return true
-	}
-
-	if c.options != nil && c.options.ToolFilter != nil && len(c.options.ToolFilter.List) > 0 {
-		filterSet := make(map[string]struct{})
-		mode := config.ToolFilterMode(strings.ToLower(string(c.options.ToolFilter.Mode)))
-		for _, toolName := range c.options.ToolFilter.List {
-			filterSet[toolName] = struct{}{}
-		}
-		switch mode {
-		case config.ToolFilterModeAllow:
-			filterFunc = func(toolName string) bool {
-				_, inList := filterSet[toolName]
-				if !inList {
-					log.Printf("<%s> Ignoring tool %s as it is not in allow list", c.name, toolName)
-				}
-				return inList
-			}
-		case config.ToolFilterModeBlock:
-			filterFunc = func(toolName string) bool {
-				_, inList := filterSet[toolName]
-				if inList {
-					log.Printf("<%s> Ignoring tool %s as it is in block list", c.name, toolName)
-				}
-				return !inList
-			}
-		default:
-			log.Printf("<%s> Unknown tool filter mode: %s, skipping tool filter", c.name, mode)
-		}
-	}
+	filterFunc := c.toolFilter(ctx)
 
 	for {
 		tools, err := c.client.ListTools(ctx, toolsRequest)
@@ -505,7 +657,7 @@ func (c *Client) storeToolsForLazyLoad(ctx context.Context) error {
 		if len(tools.Tools) == 0 {
 			break
 		}
-		log.Printf("<%s> Successfully listed %d tools for lazy loading", c.name, len(tools.Tools))
+		c.logger().InfoContext(ctx, "Listed tools for lazy loading", "count", len(tools.Tools))
 		for _, tool := range tools.Tools {
 			if filterFunc(tool.Name) {
 				c.lazyTools = append(c.lazyTools, tool)
@@ -531,7 +683,7 @@ func (c *Client) storePromptsForLazyLoad(ctx context.Context) error {
 		if len(prompts.Prompts) == 0 {
 			break
 		}
-		log.Printf("<%s> Successfully listed %d prompts for lazy loading", c.name, len(prompts.Prompts))
+		c.logger().InfoContext(ctx, "Listed prompts for lazy loading", "count", len(prompts.Prompts))
 		for _, prompt := range prompts.Prompts {
 			c.lazyPrompts = append(c.lazyPrompts, prompt)
 		}
@@ -554,7 +706,7 @@ func (c *Client) storeResourcesForLazyLoad(ctx context.Context) error {
 		if len(resources.Resources) == 0 {
 			break
 		}
-		log.Printf("<%s> Successfully listed %d resources for lazy loading", c.name, len(resources.Resources))
+		c.logger().InfoContext(ctx, "Listed resources for lazy loading", "count", len(resources.Resources))
 		for _, resource := range resources.Resources {
 			c.lazyResources = append(c.lazyResources, resource)
 		}
@@ -578,7 +730,7 @@ func (c *Client) storeResourceTemplatesForLazyLoad(ctx context.Context) error {
 		if len(resourceTemplates.ResourceTemplates) == 0 {
 			break
 		}
-		log.Printf("<%s> Successfully listed %d resource templates for lazy loading", c.name, len(resourceTemplates.ResourceTemplates))
+		c.logger().InfoContext(ctx, "Listed resource templates for lazy loading", "count", len(resourceTemplates.ResourceTemplates))
 		for _, resourceTemplate := range resourceTemplates.ResourceTemplates {
 			c.lazyTemplates = append(c.lazyTemplates, resourceTemplate)
 		}
@@ -591,12 +743,127 @@ func (c *Client) storeResourceTemplatesForLazyLoad(ctx context.Context) error {
 }
 
 func (c *Client) Close() error {
+	c.closing.Store(true)
+	var err error
 	if c.client != nil {
-		return c.client.Close()
+		err = c.client.Close()
+	}
+	if c.process != nil {
+		if stopErr := c.process.stop(); err == nil {
+			err = stopErr
+		}
+	}
+	return err
+}
+
+// markLost records that the connection to the server is gone, unless the
+// client is being closed deliberately. Only the first call has any effect.
+func (c *Client) markLost(err error) {
+	if c.lost == nil || c.closing.Load() {
+		return
+	}
+	c.lostOnce.Do(func() {
+		c.lostErr = err
+		close(c.lost)
+	})
+}
+
+// CallTool calls a tool on the server. For supervised transports the call is
+// abandoned as soon as the connection is lost, returning an error wrapping
+// ErrConnectionLost, instead of waiting for a response that will never arrive.
+func (c *Client) CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if c.lost == nil {
+		return c.client.CallTool(ctx, request)
+	}
+
+	ctx, cancel := c.abortOnLost(ctx)
+	defer cancel(nil)
+	result, err := c.client.CallTool(ctx, request)
+	if err != nil {
+		return nil, c.checkConnection(err)
+	}
+	return result, nil
+}
+
+// abortOnLost returns a context that is cancelled, with the reason as its
+// cause, as soon as the connection is lost. The caller must call cancel.
+func (c *Client) abortOnLost(ctx context.Context) (context.Context, context.CancelCauseFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		select {
+		case <-c.lost:
+			cancel(c.lostErr)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Ping checks that the server is responsive, reporting a lost connection the
+// same way as CallTool.
+func (c *Client) Ping(ctx context.Context) error {
+	if err := c.client.Ping(ctx); err != nil {
+		return c.checkConnection(err)
 	}
 	return nil
 }
 
+// checkConnection inspects a failed request, marking the connection lost if
+// the server no longer recognises our session, and returns the error to
+// report: the reason the connection was lost, if it was, otherwise err.
+func (c *Client) checkConnection(err error) error {
+	if errors.Is(err, transport.ErrSessionTerminated) {
+		c.markLost(fmt.Errorf("%w: %w", ErrConnectionLost, err))
+	}
+	if c.Disconnected() {
+		return c.lostErr
+	}
+	return err
+}
+
+// Done returns a channel that is closed when the connection to the server is
+// lost: the stdio process exited or the SSE stream ended. It returns nil for
+// transports that are not supervised, which never fires.
+func (c *Client) Done() <-chan struct{} {
+	return c.lost
+}
+
+// Disconnected reports whether the connection to the server has been lost
+func (c *Client) Disconnected() bool {
+	if c.lost == nil {
+		return false
+	}
+	select {
+	case <-c.lost:
+		return true
+	default:
+		return false
+	}
+}
+
+// Err describes why the connection was lost, or nil while it is up
+func (c *Client) Err() error {
+	if !c.Disconnected() {
+		return nil
+	}
+	return c.lostErr
+}
+
+// Stderr returns the most recent stderr output of a stdio server, which
+// usually explains why it failed to start or crashed. It is empty for other
+// transports.
+func (c *Client) Stderr() string {
+	if c.process == nil {
+		return ""
+	}
+	return c.process.stderrOutput()
+}
+
+// logger returns the logger for records about this server
+func (c *Client) logger() *slog.Logger {
+	return logging.ForServer(c.name)
+}
+
 // GetClient returns the underlying MCP client
 func (c *Client) GetClient() *client.Client {
 	return c.client
@@ -612,6 +879,12 @@ func (c *Client) NeedPing() bool {
 	return c.needPing
 }
 
+// Remote reports whether the server is reached over the network, so that
+// losing the connection is more likely a network failure than a crash
+func (c *Client) Remote() bool {
+	return c.needPing
+}
+
 // StartPingTask starts the ping task for the client
 func (c *Client) StartPingTask(ctx context.Context) {
 	c.startPingTask(ctx)
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/egress"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// dockerLabel marks the containers lazy-mcp runs with the server's name
+const dockerLabel = "lazy-mcp.server"
+
+// invalidContainerChars are those docker does not allow in container names
+var invalidContainerChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// startContainer runs the given docker server, attached to its stdio. The
+// container is removed when it exits, and force-removed if it has to be
+// killed, since killing docker run leaves its container behind. Its stderr is
+// copied to stderr, if not nil.
+func startContainer(server string, v *config.DockerMCPClientConfig, stderr io.Writer) (*childProcess, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	container := fmt.Sprintf("lazy-mcp-%s-%s", invalidContainerChars.ReplaceAllString(server, "-"), hex.EncodeToString(suffix))
+
+	// The env file's variables reach the container like env's, through
+	// docker's environment
+	env := v.Env
+	if v.Process.EnvFile != "" {
+		fileVars, err := readEnvFile(v.Process.EnvFile)
+		if err != nil {
+			return nil, err
+		}
+		env = make(map[string]string, len(fileVars)+len(v.Env))
+		for key, value := range fileVars {
+			env[key] = value
+		}
+		for key, value := range v.Env {
+			env[key] = value
+		}
+	}
+	if v.Process.Egress != nil {
+		proxied, _, err := egressEnv(server, v.Process.Egress, env, true)
+		if err != nil {
+			return nil, err
+		}
+		env = proxied
+	}
+	run := *v
+	run.Env = env
+	// The limits and egress policy apply to the container, through docker
+	// run's flags and env, and the container is sandbox enough
+	docker := v.Process
+	docker.EnvFile = ""
+	docker.Limits = nil
+	docker.Sandbox = nil
+	docker.Egress = nil
+	process, err := startChildProcess(server, container, "docker", dockerRunArgs(server, container, &run), docker, env, stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run container: %w", err)
+	}
+	process.onKill = func() {
+		if out, err := exec.Command("docker", "rm", "-f", container).CombinedOutput(); err != nil {
+			logging.ForServer(server).Warn("Failed to remove container", "container", container, "error", err, "output", string(out))
+		}
+	}
+	return process, nil
+}
+
+// dockerRunArgs returns the arguments of the docker run that starts the
+// given server in a container of that name. Env values are handed to the
+// container through the environment of docker itself, so that they do not
+// show in the process list.
+func dockerRunArgs(server, container string, v *config.DockerMCPClientConfig) []string {
+	args := []string{"run", "-i", "--rm", "--name", container, "--label", dockerLabel + "=" + server}
+	if v.Pull != "" {
+		args = append(args, "--pull", v.Pull)
+	}
+	if v.Network != "" {
+		args = append(args, "--network", v.Network)
+	}
+	if v.Process.Egress != nil {
+		// Where the egress proxy is reached, on Linux as on Docker Desktop
+		args = append(args, "--add-host", egress.ContainerHost+":host-gateway")
+	}
+	for _, volume := range v.Volumes {
+		args = append(args, "--volume", volume)
+	}
+	if limits := v.Process.Limits; limits != nil {
+		if limits.MemoryMB > 0 {
+			// Without swap on top
+			memory := fmt.Sprintf("%dm", limits.MemoryMB)
+			args = append(args, "--memory", memory, "--memory-swap", memory)
+		}
+		if limits.CPUs > 0 {
+			args = append(args, "--cpus", strconv.FormatFloat(limits.CPUs, 'f', -1, 64))
+		}
+		if limits.Processes > 0 {
+			args = append(args, "--pids-limit", strconv.Itoa(limits.Processes))
+		}
+		if limits.OpenFiles > 0 {
+			args = append(args, "--ulimit", fmt.Sprintf("nofile=%d", limits.OpenFiles))
+		}
+	}
+	keys := make([]string, 0, len(v.Env))
+	for key := range v.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "--env", key)
+	}
+	args = append(args, v.Image)
+	return append(args, v.Args...)
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestDockerRunArgs verifies that a docker server runs attached to its stdio
+// and removed on exit, with its settings, resource limits and route to the
+// egress proxy, and with env values kept out of the arguments.
+func TestDockerRunArgs(t *testing.T) {
+	args := dockerRunArgs("fetch", "lazy-mcp-fetch-1a2b", &config.DockerMCPClientConfig{
+		Image:   "mcp/fetch:latest",
+		Args:    []string{"--ignore-robots-txt"},
+		Env:     map[string]string{"USER_AGENT": "lazy-mcp", "API_KEY": "secret"},
+		Volumes: []string{"/srv/data:/data:ro"},
+		Network: "none",
+		Pull:    config.DockerPullAlways,
+	})
+	assert.Equal(t, []string{
+		"run", "-i", "--rm", "--name", "lazy-mcp-fetch-1a2b", "--label", "lazy-mcp.server=fetch",
+		"--pull", "always", "--network", "none", "--volume", "/srv/data:/data:ro",
+		"--env", "API_KEY", "--env", "USER_AGENT",
+		"mcp/fetch:latest", "--ignore-robots-txt",
+	}, args)
+	assert.NotContains(t, args, "secret")
+
+	assert.Equal(t, []string{"run", "-i", "--rm", "--name", "c", "--label", "lazy-mcp.server=fetch", "mcp/fetch"},
+		dockerRunArgs("fetch", "c", &config.DockerMCPClientConfig{Image: "mcp/fetch"}))
+
+	assert.Equal(t, []string{
+		"run", "-i", "--rm", "--name", "c", "--label", "lazy-mcp.server=fetch",
+		"--memory", "512m", "--memory-swap", "512m", "--cpus", "0.5", "--pids-limit", "64", "--ulimit", "nofile=1024",
+		"mcp/fetch",
+	}, dockerRunArgs("fetch", "c", &config.DockerMCPClientConfig{
+		Image:   "mcp/fetch",
+		Process: config.ProcessConfig{Limits: &config.ResourceLimitsConfig{MemoryMB: 512, CPUs: 0.5, Processes: 64, OpenFiles: 1024}},
+	}))
+
+	assert.Equal(t, []string{
+		"run", "-i", "--rm", "--name", "c", "--label", "lazy-mcp.server=fetch",
+		"--add-host", "host.docker.internal:host-gateway", "mcp/fetch",
+	}, dockerRunArgs("fetch", "c", &config.DockerMCPClientConfig{
+		Image:   "mcp/fetch",
+		Process: config.ProcessConfig{Egress: &config.EgressConfig{Allow: []string{"example.com"}}},
+	}))
+}
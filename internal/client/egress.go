@@ -0,0 +1,38 @@
+package client
+
+import (
+	"net/url"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/egress"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// egressProxyVars are the variables HTTP clients take their proxy from
+var egressProxyVars = []string{"HTTP_PROXY", "HTTPS_PROXY", "ALL_PROXY", "http_proxy", "https_proxy", "all_proxy"}
+
+// egressEnv registers the server with the egress proxy, as a local process
+// or a container, and returns env with the variables that send its traffic
+// through the proxy, which take precedence, along with the proxy's URL
+func egressEnv(server string, egressConf *config.EgressConfig, env map[string]string, forContainer bool) (map[string]string, *url.URL, error) {
+	policy, err := egress.NewPolicy(egressConf.Allow)
+	if err != nil {
+		return nil, nil, err
+	}
+	proxyURL, err := egress.Default.Register(server, policy, forContainer, logging.ForServer(server))
+	if err != nil {
+		return nil, nil, err
+	}
+	proxied := make(map[string]string, len(env)+len(egressProxyVars)+3)
+	for key, value := range env {
+		proxied[key] = value
+	}
+	for _, name := range egressProxyVars {
+		proxied[name] = proxyURL.String()
+	}
+	// No host may bypass the proxy
+	proxied["NO_PROXY"], proxied["no_proxy"] = "", ""
+	// Node.js only honors the variables with it
+	proxied["NODE_USE_ENV_PROXY"] = "1"
+	return proxied, proxyURL, nil
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// processEnv returns the environment of a server process as KEY=VALUE
+// entries: lazy-mcp's own, or only the variables of config.BaseEnv and the
+// allowlist unless it inherits it all, then the variables of the env file,
+// then env, each taking precedence over the ones before
+func processEnv(process config.ProcessConfig, env map[string]string) ([]string, error) {
+	vars := make(map[string]string)
+	for _, entry := range os.Environ() {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || key == "" {
+			continue
+		}
+		if process.InheritEnv || matchesEnv(config.BaseEnv, key) || matchesEnv(process.EnvAllowlist, key) {
+			vars[key] = value
+		}
+	}
+	if process.EnvFile != "" {
+		fileVars, err := readEnvFile(process.EnvFile)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range fileVars {
+			vars[key] = value
+		}
+	}
+	for key, value := range env {
+		vars[key] = value
+	}
+
+	entries := make([]string, 0, len(vars))
+	for key, value := range vars {
+		entries = append(entries, key+"="+value)
+	}
+	sort.Strings(entries)
+	return entries, nil
+}
+
+// matchesEnv reports whether the variable name matches one of the glob
+// patterns, such as "AWS_*"
+func matchesEnv(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// readEnvFile reads the variables of a dotenv file: KEY=VALUE lines,
+// optionally prefixed by export, with # comments. Values may be quoted,
+// double quotes allowing \n, \t, \" and \\ escapes.
+func readEnvFile(filename string) (map[string]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	defer file.Close()
+
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !envKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", filename, lineNo)
+		}
+		value, err := envFileValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filename, lineNo, err)
+		}
+		vars[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	return vars, nil
+}
+
+// envFileValue unquotes the value of a dotenv line, dropping a trailing
+// comment from an unquoted one
+func envFileValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	switch quote := value[0]; quote {
+	case '\'':
+		end := strings.IndexByte(value[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value")
+		}
+		return value[1 : end+1], nil
+	case '"':
+		var b strings.Builder
+		for i := 1; i < len(value); i++ {
+			switch ch := value[i]; {
+			case ch == '"':
+				return b.String(), nil
+			case ch == '\\' && i+1 < len(value):
+				i++
+				switch value[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(value[i])
+				}
+			default:
+				b.WriteByte(ch)
+			}
+		}
+		return "", fmt.Errorf("unterminated quoted value")
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value, nil
+}
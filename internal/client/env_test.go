@@ -0,0 +1,65 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestProcessEnv verifies that a server process only gets the base and
+// allowlisted variables of lazy-mcp's environment unless it inherits it, and
+// that env takes precedence over the env file, which takes precedence over
+// lazy-mcp's environment.
+func TestProcessEnv(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin")
+	t.Setenv("GATEWAY_SECRET", "hunter2")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	envFile := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(envFile, []byte("AWS_REGION=us-east-1\nAPI_KEY=from-file\n"), 0o600))
+
+	env, err := processEnv(config.ProcessConfig{InheritEnv: false, EnvAllowlist: []string{"AWS_*"}}, map[string]string{"DEBUG": "1"})
+	require.NoError(t, err)
+	assert.Contains(t, env, "PATH=/usr/bin")
+	assert.Contains(t, env, "AWS_REGION=eu-west-1")
+	assert.Contains(t, env, "DEBUG=1")
+	assert.NotContains(t, env, "GATEWAY_SECRET=hunter2")
+
+	env, err = processEnv(config.ProcessConfig{InheritEnv: true, EnvFile: envFile}, map[string]string{"API_KEY": "from-env"})
+	require.NoError(t, err)
+	assert.Contains(t, env, "GATEWAY_SECRET=hunter2")
+	assert.Contains(t, env, "AWS_REGION=us-east-1")
+	assert.Contains(t, env, "API_KEY=from-env")
+	assert.NotContains(t, env, "API_KEY=from-file")
+
+	_, err = processEnv(config.ProcessConfig{EnvFile: filepath.Join(t.TempDir(), "missing.env")}, nil)
+	assert.Error(t, err)
+}
+
+func TestReadEnvFile(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(envFile, []byte(`# Credentials
+export TOKEN=abc123
+PLAIN = value # trailing comment
+SINGLE='quoted # kept'
+DOUBLE="line one\nline \"two\""
+EMPTY=
+`), 0o600))
+
+	vars, err := readEnvFile(envFile)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"TOKEN":  "abc123",
+		"PLAIN":  "value",
+		"SINGLE": "quoted # kept",
+		"DOUBLE": "line one\nline \"two\"",
+		"EMPTY":  "",
+	}, vars)
+
+	require.NoError(t, os.WriteFile(envFile, []byte("TOKEN=ok\nnot a variable\n"), 0o600))
+	_, err = readEnvFile(envFile)
+	assert.ErrorContains(t, err, ".env:2")
+}
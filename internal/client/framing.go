@@ -0,0 +1,224 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// DefaultMaxMessageBytes bounds the messages of stdio and Docker servers when
+// maxMessageBytes is not configured
+const DefaultMaxMessageBytes = 64 << 20
+
+const (
+	// readChunkSize is how much of a server's stdout is read at a time
+	readChunkSize = 64 << 10
+	// headSize and tailSize are how much of the start and end of a dropped
+	// message are kept to find the ID of the request it answers
+	headSize = 1024
+	tailSize = 256
+	// snippetSize is how much of output that is not JSON is logged
+	snippetSize = 200
+)
+
+// tailID matches the ID of a response that ends with it, as those of the
+// TypeScript SDK do; a nested object would end with more than one brace
+var tailID = regexp.MustCompile(`"id"\s*:\s*(-?\d+|"(?:[^"\\]|\\.)*")\s*\}\s*$`)
+
+// maxMessageBytes returns the bound on the size of a server's messages, zero
+// for none
+func maxMessageBytes(options *config.OptionsV2) int {
+	if options == nil {
+		return DefaultMaxMessageBytes
+	}
+	return max(options.MaxMessageBytes.OrElse(DefaultMaxMessageBytes), 0)
+}
+
+// messageReader passes the newline-delimited JSON-RPC messages a server
+// writes to stdout on to the transport, which buffers each whole and drops
+// those it cannot parse without a word. It drops messages over maxSize
+// itself, without buffering them, and output that is not JSON, logging
+// either, and answers a request whose response it dropped with an error in
+// its place, so that the call fails instead of waiting for its timeout.
+type messageReader struct {
+	r       io.Reader
+	log     *slog.Logger
+	maxSize int // Zero for no bound
+
+	chunk   []byte
+	pending []byte // Read but not yet returned
+	err     error  // Of the last read of r, returned once pending is
+
+	line      []byte // The current message so far, unless dropping it
+	dropping  bool   // The current message is over maxSize
+	dropped   int    // Size of the message being dropped so far
+	head      []byte // Start of the message being dropped
+	tail      []byte // End of the message being dropped so far
+	tailSpare []byte
+}
+
+func newMessageReader(r io.Reader, maxSize int, log *slog.Logger) *messageReader {
+	return &messageReader{r: r, log: log, maxSize: maxSize, chunk: make([]byte, readChunkSize)}
+}
+
+func (m *messageReader) Read(p []byte) (int, error) {
+	for len(m.pending) == 0 {
+		if m.err != nil {
+			return 0, m.err
+		}
+		n, err := m.r.Read(m.chunk)
+		m.scan(m.chunk[:n])
+		m.err = err
+	}
+	n := copy(p, m.pending)
+	m.pending = m.pending[n:]
+	return n, nil
+}
+
+// scan splits what was read into messages
+func (m *messageReader) scan(data []byte) {
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n') + 1
+		complete := end > 0
+		if !complete {
+			end = len(data)
+		}
+		segment := data[:end]
+		data = data[end:]
+
+		switch {
+		case m.dropping:
+			m.dropped += len(segment)
+			m.keepTail(segment)
+		case m.maxSize > 0 && len(m.line)+len(bytes.TrimRight(segment, "\r\n")) > m.maxSize:
+			m.dropping = true
+			m.dropped = len(m.line) + len(segment)
+			m.head = append(append([]byte(nil), m.line[:min(len(m.line), headSize)]...), segment[:min(len(segment), headSize)]...)
+			m.head = m.head[:min(len(m.head), headSize)]
+			m.line = nil
+			m.keepTail(segment)
+		default:
+			m.line = append(m.line, segment...)
+		}
+		if complete {
+			m.endMessage()
+		}
+	}
+}
+
+// keepTail keeps the last tailSize bytes of the message being dropped
+func (m *messageReader) keepTail(segment []byte) {
+	if len(segment) >= tailSize {
+		m.tail = append(m.tail[:0], segment[len(segment)-tailSize:]...)
+		return
+	}
+	keep := min(len(m.tail), tailSize-len(segment))
+	m.tailSpare = append(append(m.tailSpare[:0], m.tail[len(m.tail)-keep:]...), segment...)
+	m.tail, m.tailSpare = m.tailSpare, m.tail
+}
+
+// endMessage passes on the message just read, or an error in place of one
+// that was dropped
+func (m *messageReader) endMessage() {
+	if m.dropping {
+		size, head, tail := m.dropped, m.head, bytes.TrimRight(m.tail, "\r\n")
+		m.dropping, m.dropped, m.head, m.tail = false, 0, nil, m.tail[:0]
+		id := responseID(head, tail)
+		m.log.Warn("Dropped oversized message from MCP server", "size", size, "maxMessageBytes", m.maxSize, "id", string(id))
+		m.answer(id, fmt.Sprintf("response of %d bytes exceeds the maxMessageBytes of %d", size, m.maxSize))
+		return
+	}
+
+	line := m.line
+	m.line = nil
+	message := bytes.TrimSpace(line)
+	if len(message) == 0 {
+		return
+	}
+	if !json.Valid(message) {
+		snippet := message[:min(len(message), snippetSize)]
+		id := responseID(message[:min(len(message), headSize)], message[max(len(message)-tailSize, 0):])
+		m.log.Warn("Ignored output on stdout that is not a JSON-RPC message", "output", string(snippet), "size", len(message))
+		m.answer(id, "response is not valid JSON")
+		return
+	}
+	if len(m.pending) == 0 {
+		m.pending = line
+		return
+	}
+	m.pending = append(m.pending, line...)
+}
+
+// answer passes on an error response to the request with the given ID, if
+// any, in place of its response
+func (m *messageReader) answer(id json.RawMessage, reason string) {
+	if id == nil {
+		return
+	}
+	response, err := json.Marshal(struct {
+		JSONRPC string                  `json:"jsonrpc"`
+		ID      json.RawMessage         `json:"id"`
+		Error   mcp.JSONRPCErrorDetails `json:"error"`
+	}{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		ID:      id,
+		Error:   mcp.JSONRPCErrorDetails{Code: mcp.INTERNAL_ERROR, Message: "lazy-mcp dropped the server's " + reason},
+	})
+	if err != nil {
+		return
+	}
+	m.pending = append(append(m.pending, response...), '\n')
+}
+
+// responseID returns the ID of the response that starts with head and ends
+// with tail, or nil if it is not a response or its ID was not found. The
+// top-level fields at the start are read up to the result or error, which
+// may be large.
+func responseID(head, tail []byte) json.RawMessage {
+	decoder := json.NewDecoder(bytes.NewReader(head))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil
+	}
+	var id json.RawMessage
+	for {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil
+		}
+		if key == "method" {
+			// A request or notification
+			return nil
+		}
+		if key == "result" || key == "error" {
+			break
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil
+		}
+		if key == "id" {
+			id = value
+		}
+	}
+	if id == nil {
+		if match := tailID.FindSubmatch(tail); match != nil {
+			id = match[1]
+		}
+	}
+	if !validID(id) {
+		return nil
+	}
+	return id
+}
+
+// validID reports whether raw is a JSON-RPC request ID: a number or string
+func validID(raw []byte) bool {
+	var id mcp.RequestId
+	return len(raw) > 0 && (raw[0] == '"' || raw[0] == '-' || raw[0] >= '0' && raw[0] <= '9') && json.Unmarshal(raw, &id) == nil
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readMessages reads what a messageReader bounded to maxSize passes on of
+// output, read a few bytes at a time, and what it logged
+func readMessages(t *testing.T, output string, maxSize int) ([]map[string]any, string) {
+	var logs bytes.Buffer
+	reader := newMessageReader(iotest.HalfReader(strings.NewReader(output)), maxSize, slog.New(slog.NewTextHandler(&logs, nil)))
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	var messages []map[string]any
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		var message map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &message), line)
+		messages = append(messages, message)
+	}
+	return messages, logs.String()
+}
+
+// TestMessageReaderDropsOversizedMessages verifies that messages over the
+// bound are dropped, whether the ID of a response comes first or last, that
+// the request of such a response is answered with an error, and that the
+// messages around them pass intact.
+func TestMessageReaderDropsOversizedMessages(t *testing.T) {
+	huge := strings.Repeat("x", 500)
+	output := `{"jsonrpc":"2.0","id":1,"result":{"text":"small"}}` + "\n" +
+		`{"jsonrpc":"2.0","id":2,"result":{"text":"` + huge + `"}}` + "\n" +
+		`{"result":{"text":"` + huge + `","id":9},"jsonrpc":"2.0","id":"three"}` + "\r\n" +
+		`{"jsonrpc":"2.0","method":"notifications/message","params":{"data":"` + huge + `"}}` + "\n" +
+		`{"jsonrpc":"2.0","id":4,"result":{}}` + "\n"
+
+	messages, logs := readMessages(t, output, 200)
+	require.Len(t, messages, 4)
+	assert.Equal(t, map[string]any{"text": "small"}, messages[0]["result"])
+
+	for i, id := range []any{float64(2), "three"} {
+		response := messages[i+1]
+		assert.Equal(t, id, response["id"])
+		require.Contains(t, response, "error")
+		errorDetails := response["error"].(map[string]any)
+		assert.Equal(t, float64(-32603), errorDetails["code"])
+		assert.Contains(t, errorDetails["message"], "exceeds the maxMessageBytes of 200")
+	}
+	assert.Equal(t, float64(4), messages[3]["id"])
+	assert.Equal(t, 3, strings.Count(logs, "Dropped oversized message"))
+
+	messages, _ = readMessages(t, output, 0)
+	assert.Len(t, messages, 5, "zero bounds nothing")
+}
+
+// TestMessageReaderReportsInvalidOutput verifies that output that is not
+// JSON is logged rather than passed on, and that a response cut short fails
+// its request.
+func TestMessageReaderReportsInvalidOutput(t *testing.T) {
+	output := "Server listening on stdio\n\n" +
+		`{"jsonrpc":"2.0","id":7,"result":{"text":"cut sh` + "\n" +
+		`{"jsonrpc":"2.0","id":8,"result":{}}` + "\n" +
+		`{"jsonrpc":"2.0","id":9,"result":{}}`
+
+	messages, logs := readMessages(t, output, DefaultMaxMessageBytes)
+	require.Len(t, messages, 2, "a message without its newline is not passed on")
+	assert.Equal(t, float64(7), messages[0]["id"])
+	assert.Contains(t, messages[0]["error"].(map[string]any)["message"], "not valid JSON")
+	assert.Equal(t, float64(8), messages[1]["id"])
+	assert.Contains(t, logs, "Server listening on stdio")
+}
+
+// TestResponseID verifies that only responses have their IDs found.
+func TestResponseID(t *testing.T) {
+	assert.Equal(t, `5`, string(responseID([]byte(`{"jsonrpc":"2.0","id":5,"result":{"te`), nil)))
+	assert.Equal(t, `"a\"b"`, string(responseID([]byte(`{"result":{"te`), []byte(`xt"},"jsonrpc":"2.0","id":"a\"b"}`))))
+	assert.Nil(t, responseID([]byte(`{"jsonrpc":"2.0","id":5,"method":"sampling/createMessage","params":{`), nil), "requests are not responses")
+	assert.Nil(t, responseID([]byte(`{"result":{"te`), []byte(`"id":1}}`)), "a nested ID is not the response's")
+	assert.Nil(t, responseID([]byte(`{"jsonrpc":"2.0","id":null,"error":{`), nil))
+}
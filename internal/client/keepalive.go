@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// DefaultPingInterval is how often SSE and streamable HTTP servers are pinged
+// when pingInterval is not configured
+const DefaultPingInterval = 30 * time.Second
+
+const (
+	// maxPingTimeout bounds a keep-alive ping; shorter intervals bound it
+	// to the interval
+	maxPingTimeout = 10 * time.Second
+
+	// pingFailuresBeforeLost is how many keep-alive pings in a row may fail
+	// before the connection is given up as dead
+	pingFailuresBeforeLost = 3
+)
+
+// pingInterval returns how often the server is pinged; zero disables pinging
+func (c *Client) pingInterval() time.Duration {
+	if c.options == nil {
+		return DefaultPingInterval
+	}
+	return c.options.PingInterval.OrElse(config.Duration(DefaultPingInterval)).Std()
+}
+
+// startPingTask pings the server until ctx is done or the connection is lost,
+// which keeps idle connections from being dropped along the way and finds
+// out when they were. A connection whose pings keep failing is reported lost,
+// so that it is reconnected before the next call rather than failing it,
+// except that a streamable HTTP session survives the network being down:
+// only the server expiring it ends it.
+func (c *Client) startPingTask(ctx context.Context) {
+	interval := c.pingInterval()
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failCount := 0
+	for {
+		select {
+		case <-ctx.Done():
+			c.logger().Debug("Context done, stopping ping")
+			return
+		case <-c.lost:
+			return
+		case <-ticker.C:
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, min(interval, maxPingTimeout))
+		err := c.Ping(pingCtx)
+		cancel()
+		if err == nil {
+			if failCount > 0 {
+				c.logger().Info("MCP Ping recovered", "failures", failCount)
+				failCount = 0
+			}
+			continue
+		}
+		if ctx.Err() != nil || errors.Is(err, ErrConnectionLost) {
+			return
+		}
+		failCount++
+		c.logger().Warn("MCP Ping failed", "error", err, "count", failCount)
+		if !c.resumable && failCount >= pingFailuresBeforeLost {
+			c.markLost(fmt.Errorf("%w: %d keep-alive pings failed, last: %v", ErrConnectionLost, failCount, err))
+			return
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// newUnresponsiveServer returns an MCP server that stops answering pings
+// while unresponsive is set, as one behind a dropped connection would
+func newUnresponsiveServer(unresponsive *atomic.Bool) *server.MCPServer {
+	hooks := &server.Hooks{}
+	hooks.AddBeforePing(func(ctx context.Context, id any, message *mcp.PingRequest) {
+		if unresponsive.Load() {
+			time.Sleep(time.Second)
+		}
+	})
+	return server.NewMCPServer("remote-server", "1.0.0", server.WithHooks(hooks))
+}
+
+// connectRemote starts and initializes a client of a remote server pinged
+// every 20ms
+func connectRemote(t *testing.T, clientType config.MCPClientType, url string) *Client {
+	c, err := NewMCPClient("remote", &config.MCPClientConfigV2{
+		TransportType: clientType,
+		URL:           url,
+		Options:       &config.OptionsV2{PingInterval: optional.NewField(config.Duration(20 * time.Millisecond))},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, c.GetClient().Start(context.Background()))
+	_, err = c.GetClient().Initialize(ctx, mcp.InitializeRequest{})
+	require.NoError(t, err)
+	return c
+}
+
+// TestKeepAliveReportsDeadSSEConnection verifies that an SSE connection whose
+// pings keep going unanswered is reported lost, so that it is reconnected.
+func TestKeepAliveReportsDeadSSEConnection(t *testing.T) {
+	var unresponsive atomic.Bool
+	sseServer := server.NewTestServer(newUnresponsiveServer(&unresponsive))
+	t.Cleanup(sseServer.Close) // After the client, whose event stream would hold it up
+
+	c := connectRemote(t, config.MCPClientTypeSSE, sseServer.URL+"/sse")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.StartPingTask(ctx)
+
+	// Answered pings keep the connection
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, c.Disconnected())
+
+	unresponsive.Store(true)
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("dead connection was not detected")
+	}
+	assert.ErrorIs(t, c.Err(), ErrConnectionLost)
+	assert.Contains(t, c.Err().Error(), "keep-alive pings failed")
+}
+
+// TestKeepAliveKeepsStreamableHTTPSession verifies that a streamable HTTP
+// session carries on once pings are answered again.
+func TestKeepAliveKeepsStreamableHTTPSession(t *testing.T) {
+	var unresponsive atomic.Bool
+	httpServer := server.NewTestStreamableHTTPServer(newUnresponsiveServer(&unresponsive), server.WithStateful(true))
+	t.Cleanup(httpServer.Close)
+
+	c := connectRemote(t, config.MCPClientTypeStreamable, httpServer.URL+"/mcp")
+	sessionID := c.GetClient().GetSessionId()
+	require.NotEmpty(t, sessionID)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.StartPingTask(ctx)
+
+	unresponsive.Store(true)
+	time.Sleep(200 * time.Millisecond)
+	unresponsive.Store(false)
+
+	assert.False(t, c.Disconnected())
+	pingCtx, cancelPing := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelPing()
+	assert.NoError(t, c.Ping(pingCtx))
+	assert.Equal(t, sessionID, c.GetClient().GetSessionId())
+}
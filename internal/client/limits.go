@@ -0,0 +1,20 @@
+package client
+
+import "os"
+
+// processLimiter enforces a server's resource limits on its process, set up
+// by limitProcess before the process starts
+type processLimiter interface {
+	// started puts the process under the limits once it has started
+	started(process *os.Process) error
+	// release frees what enforcing the limits took, once the process has
+	// exited or failed to start, and kills any processes it left behind
+	release()
+}
+
+// noLimits is the processLimiter of processes without limits
+type noLimits struct{}
+
+func (noLimits) started(*os.Process) error { return nil }
+
+func (noLimits) release() {}
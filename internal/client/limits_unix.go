@@ -0,0 +1,81 @@
+//go:build unix
+
+package client
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// limitProcess prepares cmd to run within limits. CPU, memory and process
+// limits are enforced by a cgroup where cgroups v2 are available; otherwise
+// memory is bounded by the data segment rlimit, and CPU and processes are not
+// bounded at all. Open files are always bounded by their rlimit.
+func limitProcess(server string, cmd *exec.Cmd, limits *config.ResourceLimitsConfig) (processLimiter, error) {
+	if limits.IsZero() || cmd.Err != nil {
+		// Start reports why the command cannot run
+		return noLimits{}, nil
+	}
+	limiter := &unixLimiter{server: server}
+	if limits.MemoryMB > 0 || limits.CPUs > 0 || limits.Processes > 0 {
+		group, err := newCgroup(limits)
+		if err != nil && (limits.CPUs > 0 || limits.Processes > 0) {
+			logging.ForServer(server).Warn("cgroups are unavailable, so limits.cpus and limits.processes are not enforced", "error", err)
+		}
+		if group != nil {
+			group.attach(cmd)
+			limiter.group = group
+		}
+	}
+
+	memoryMB := limits.MemoryMB
+	if limiter.group != nil {
+		memoryMB = 0
+	}
+	if ulimits := ulimitArgs(memoryMB, limits.OpenFiles); ulimits != "" {
+		// The shell sets the rlimits, which its exec keeps, so that they
+		// apply from the command's very start
+		cmd.Args = append([]string{"/bin/sh", "-c", ulimits + ` && exec "$0" "$@"`, cmd.Path}, cmd.Args[1:]...)
+		cmd.Path = "/bin/sh"
+	}
+	return limiter, nil
+}
+
+// ulimitArgs returns the ulimit commands that bound the data segment, and so
+// the memory, to memoryMB MiB and the open files to openFiles. RLIMIT_AS
+// would be stricter, but runtimes such as V8 and Go reserve far more address
+// space than they use.
+func ulimitArgs(memoryMB, openFiles int) string {
+	var commands []string
+	if memoryMB > 0 {
+		commands = append(commands, fmt.Sprintf("ulimit -d %d", memoryMB*1024))
+	}
+	if openFiles > 0 {
+		commands = append(commands, fmt.Sprintf("ulimit -n %d", openFiles))
+	}
+	return strings.Join(commands, " && ")
+}
+
+// unixLimiter is the processLimiter of Unix processes
+type unixLimiter struct {
+	server string
+	group  *cgroup // Nil without cgroups
+}
+
+func (l *unixLimiter) started(*os.Process) error {
+	if l.group != nil {
+		l.group.started()
+	}
+	return nil
+}
+
+func (l *unixLimiter) release() {
+	if l.group != nil {
+		l.group.release(l.server)
+	}
+}
@@ -0,0 +1,32 @@
+//go:build unix
+
+package client
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestLimitProcessSetsRlimits verifies that a server process starts with the
+// open files limit it is given, along with its own arguments.
+func TestLimitProcessSetsRlimits(t *testing.T) {
+	process, err := startChildProcess("limited", "", "sh", []string{"-c", `echo "$(ulimit -n) $1"`, "sh", "arg with spaces"},
+		config.ProcessConfig{InheritEnv: true, Limits: &config.ResourceLimitsConfig{OpenFiles: 64}}, nil, nil)
+	require.NoError(t, err)
+	out, err := io.ReadAll(process.stdout)
+	require.NoError(t, err)
+	<-process.Done()
+	assert.Equal(t, "64 arg with spaces", strings.TrimSpace(string(out)))
+	require.NoError(t, process.stop())
+}
+
+func TestUlimitArgs(t *testing.T) {
+	assert.Equal(t, "ulimit -d 524288 && ulimit -n 256", ulimitArgs(512, 256))
+	assert.Equal(t, "ulimit -n 256", ulimitArgs(0, 256))
+	assert.Empty(t, ulimitArgs(0, 0))
+}
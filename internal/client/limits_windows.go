@@ -0,0 +1,85 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"unsafe"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"golang.org/x/sys/windows"
+)
+
+// jobObjectCPURateControlInformation is JOBOBJECT_CPU_RATE_CONTROL_INFORMATION
+// with its CpuRate, which x/sys/windows does not define
+type jobObjectCPURateControlInformation struct {
+	ControlFlags uint32
+	CPURate      uint32 // In hundredths of a percent of all the CPUs' time
+}
+
+const (
+	jobObjectCPURateControlEnable  = 0x1
+	jobObjectCPURateControlHardCap = 0x4
+)
+
+// limitProcess prepares a job object with limits, if any, that the process
+// joins once started. The processes left in it are killed when it is
+// released, or when lazy-mcp exits, even if it crashes, which closes it.
+// Open files are not bounded on Windows.
+func limitProcess(server string, cmd *exec.Cmd, limits *config.ResourceLimitsConfig) (processLimiter, error) {
+	if limits == nil {
+		limits = &config.ResourceLimitsConfig{}
+	}
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job object: %w", err)
+	}
+
+	var info windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	if limits.MemoryMB > 0 {
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_JOB_MEMORY
+		info.JobMemoryLimit = uintptr(limits.MemoryMB) << 20
+	}
+	if limits.Processes > 0 {
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_ACTIVE_PROCESS
+		info.BasicLimitInformation.ActiveProcessLimit = uint32(limits.Processes)
+	}
+	if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		_ = windows.CloseHandle(job)
+		return nil, fmt.Errorf("failed to limit job object: %w", err)
+	}
+	if limits.CPUs > 0 {
+		rate := jobObjectCPURateControlInformation{
+			ControlFlags: jobObjectCPURateControlEnable | jobObjectCPURateControlHardCap,
+			CPURate:      uint32(min(max(limits.CPUs/float64(runtime.NumCPU())*10000, 1), 10000)),
+		}
+		if _, err := windows.SetInformationJobObject(job, windows.JobObjectCpuRateControlInformation, uintptr(unsafe.Pointer(&rate)), uint32(unsafe.Sizeof(rate))); err != nil {
+			_ = windows.CloseHandle(job)
+			return nil, fmt.Errorf("failed to limit the CPU rate of job object: %w", err)
+		}
+	}
+	return &jobLimiter{job: job}, nil
+}
+
+// jobLimiter is the processLimiter of Windows processes
+type jobLimiter struct {
+	job windows.Handle
+}
+
+func (l *jobLimiter) started(process *os.Process) error {
+	handle, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(process.Pid))
+	if err != nil {
+		return fmt.Errorf("failed to open process: %w", err)
+	}
+	defer windows.CloseHandle(handle)
+	if err := windows.AssignProcessToJobObject(l.job, handle); err != nil {
+		return fmt.Errorf("failed to assign process to job object: %w", err)
+	}
+	return nil
+}
+
+func (l *jobLimiter) release() {
+	_ = windows.CloseHandle(l.job)
+}
@@ -0,0 +1,40 @@
+//go:build windows
+
+package client
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestLimitProcessKillsOnRelease verifies that a limited process joins its
+// job object, and is killed once the job object is released.
+func TestLimitProcessKillsOnRelease(t *testing.T) {
+	cmd := exec.Command("ping", "-n", "60", "127.0.0.1")
+	limiter, err := limitProcess("limited", cmd, &config.ResourceLimitsConfig{MemoryMB: 256, Processes: 4, CPUs: 1})
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	if err := limiter.started(cmd.Process); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		limiter.release()
+		t.Fatal(err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+	limiter.release()
+	select {
+	case <-exited:
+	case <-time.After(10 * time.Second):
+		_ = cmd.Process.Kill()
+		t.Fatal("process outlived its job object")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package client
+
+import (
+	"io"
+	"os"
+)
+
+// newPipe returns the ends of a pipe for a child's stdio: the child's, and
+// lazy-mcp's, which reads what the child writes if childWrites, and writes
+// what it reads otherwise
+func newPipe(childWrites bool) (*os.File, io.ReadWriteCloser, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if childWrites {
+		return w, r, nil
+	}
+	return r, w, nil
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// pipeBufferSize is the size of the buffers of a child's stdio pipes
+const pipeBufferSize = 64 << 10
+
+// newPipe returns the ends of a pipe for a child's stdio: the child's, and
+// lazy-mcp's, which reads what the child writes if childWrites, and writes
+// what it reads otherwise. It is a named pipe rather than an anonymous one,
+// as those cannot be read asynchronously, so that closing lazy-mcp's end
+// interrupts a read blocked on a child that hangs.
+func newPipe(childWrites bool) (*os.File, io.ReadWriteCloser, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, nil, err
+	}
+	name := fmt.Sprintf(`\\.\pipe\lazy-mcp-%d-%s`, os.Getpid(), hex.EncodeToString(suffix))
+	name16, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	access, childAccess := uint32(windows.PIPE_ACCESS_OUTBOUND), uint32(windows.GENERIC_READ|windows.FILE_WRITE_ATTRIBUTES)
+	if childWrites {
+		access, childAccess = windows.PIPE_ACCESS_INBOUND, windows.GENERIC_WRITE|windows.FILE_READ_ATTRIBUTES
+	}
+	own, err := windows.CreateNamedPipe(name16,
+		access|windows.FILE_FLAG_OVERLAPPED|windows.FILE_FLAG_FIRST_PIPE_INSTANCE,
+		windows.PIPE_TYPE_BYTE|windows.PIPE_READMODE_BYTE|windows.PIPE_WAIT|windows.PIPE_REJECT_REMOTE_CLIENTS,
+		1, pipeBufferSize, pipeBufferSize, 0, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The child's end does synchronous I/O, which is what programs expect of
+	// their stdio
+	child, err := windows.CreateFile(name16, childAccess, 0, nil, windows.OPEN_EXISTING, windows.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		_ = windows.CloseHandle(own)
+		return nil, nil, err
+	}
+	pipe := &overlappedPipe{handle: own}
+	if err := pipe.connect(); err != nil {
+		_ = windows.CloseHandle(child)
+		_ = pipe.Close()
+		return nil, nil, err
+	}
+	return os.NewFile(uintptr(child), name), pipe, nil
+}
+
+// overlappedPipe is lazy-mcp's end of a named pipe, read and written with
+// overlapped I/O, which Close cancels
+type overlappedPipe struct {
+	handle  windows.Handle
+	mu      sync.RWMutex // Held for reading during I/O, and for writing to close the handle
+	closing atomic.Bool
+}
+
+// connect completes the connection the child's end opened
+func (p *overlappedPipe) connect() error {
+	_, err := p.overlapped(func(overlapped *windows.Overlapped) (uint32, error) {
+		return 0, windows.ConnectNamedPipe(p.handle, overlapped)
+	})
+	if errors.Is(err, windows.ERROR_PIPE_CONNECTED) {
+		return nil
+	}
+	return err
+}
+
+func (p *overlappedPipe) Read(b []byte) (int, error) {
+	n, err := p.overlapped(func(overlapped *windows.Overlapped) (uint32, error) {
+		var n uint32
+		err := windows.ReadFile(p.handle, b, &n, overlapped)
+		return n, err
+	})
+	if errors.Is(err, windows.ERROR_BROKEN_PIPE) || errors.Is(err, windows.ERROR_PIPE_NOT_CONNECTED) {
+		return n, io.EOF
+	}
+	return n, err
+}
+
+func (p *overlappedPipe) Write(b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		n, err := p.overlapped(func(overlapped *windows.Overlapped) (uint32, error) {
+			var n uint32
+			err := windows.WriteFile(p.handle, b[written:], &n, overlapped)
+			return n, err
+		})
+		written += n
+		if errors.Is(err, windows.ERROR_NO_DATA) || errors.Is(err, windows.ERROR_BROKEN_PIPE) {
+			return written, io.ErrClosedPipe
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// overlapped runs an overlapped operation on the pipe and waits for it to
+// complete, or to be cancelled by Close
+func (p *overlappedPipe) overlapped(operation func(*windows.Overlapped) (uint32, error)) (int, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closing.Load() {
+		return 0, os.ErrClosed
+	}
+	event, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseHandle(event)
+
+	overlapped := &windows.Overlapped{HEvent: event}
+	n, err := operation(overlapped)
+	if errors.Is(err, windows.ERROR_IO_PENDING) {
+		err = windows.GetOverlappedResult(p.handle, overlapped, &n, true)
+	}
+	if errors.Is(err, windows.ERROR_OPERATION_ABORTED) {
+		return int(n), os.ErrClosed
+	}
+	return int(n), err
+}
+
+// Close cancels the operations in progress and closes the pipe
+func (p *overlappedPipe) Close() error {
+	if p.closing.Swap(true) {
+		return nil
+	}
+	// An operation may start between cancelling and locking, and must be
+	// cancelled in turn
+	for !p.mu.TryLock() {
+		_ = windows.CancelIoEx(p.handle, nil)
+		time.Sleep(time.Millisecond)
+	}
+	defer p.mu.Unlock()
+	return windows.CloseHandle(p.handle)
+}
@@ -0,0 +1,64 @@
+//go:build windows
+
+package client
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewPipe verifies that what either end of a pipe writes reaches the
+// other, and that lazy-mcp's end reads EOF once the child's end is closed.
+func TestNewPipe(t *testing.T) {
+	child, own, err := newPipe(true)
+	require.NoError(t, err)
+	defer own.Close()
+	_, err = child.Write([]byte("from child"))
+	require.NoError(t, err)
+	require.NoError(t, child.Close())
+	out, err := io.ReadAll(own)
+	require.NoError(t, err)
+	assert.Equal(t, "from child", string(out))
+
+	child, own, err = newPipe(false)
+	require.NoError(t, err)
+	defer child.Close()
+	_, err = own.Write([]byte("to child"))
+	require.NoError(t, err)
+	require.NoError(t, own.Close())
+	out, err = io.ReadAll(child)
+	require.NoError(t, err)
+	assert.Equal(t, "to child", string(out))
+}
+
+// TestPipeCloseInterruptsRead verifies that closing lazy-mcp's end of a pipe
+// interrupts a read blocked on a child that writes nothing, which an
+// anonymous pipe would leave hanging.
+func TestPipeCloseInterruptsRead(t *testing.T) {
+	child, own, err := newPipe(true)
+	require.NoError(t, err)
+	defer child.Close()
+
+	read := make(chan error, 1)
+	go func() {
+		_, err := own.Read(make([]byte, 16))
+		read <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, own.Close())
+	select {
+	case err := <-read:
+		assert.ErrorIs(t, err, os.ErrClosed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("read was not interrupted by Close")
+	}
+
+	_, err = own.Read(make([]byte, 16))
+	assert.ErrorIs(t, err, os.ErrClosed)
+	assert.NoError(t, own.Close(), "closing again is a no-op")
+}
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/secrets"
+)
+
+// ProbeStage is a step of bringing up a server, as reported by Probe
+type ProbeStage string
+
+const (
+	ProbeStageStart      ProbeStage = "start"
+	ProbeStageInitialize ProbeStage = "initialize"
+	ProbeStageListTools  ProbeStage = "list tools"
+	ProbeStageDone       ProbeStage = "done"
+)
+
+// ProbeResult describes how a server behaved when launched by Probe
+type ProbeResult struct {
+	Server string
+	// Stage is the step that failed, or ProbeStageDone if all succeeded
+	Stage ProbeStage
+	Err   error
+	// Hung is set when the server stopped answering within the timeout, and
+	// Crashed when the connection was lost, e.g. because the process exited
+	Hung    bool
+	Crashed bool
+	// Stderr is the end of a stdio server's error output
+	Stderr string
+
+	StartupTime     time.Duration // Launching the server and the initialize handshake
+	ListToolsTime   time.Duration
+	ServerInfo      mcp.Implementation
+	ProtocolVersion string
+	Capabilities    mcp.ServerCapabilities
+	Tools           []mcp.Tool
+}
+
+// OK reports whether every stage succeeded
+func (r *ProbeResult) OK() bool {
+	return r.Stage == ProbeStageDone
+}
+
+// Probe launches the given server, performs the initialize handshake and
+// lists its tools, then shuts it down. Each stage must finish within timeout.
+// Unlike normal use, failures are not retried: the aim is to show exactly
+// where and how a server misbehaves.
+func Probe(ctx context.Context, name string, conf *config.MCPClientConfigV2, timeout time.Duration) *ProbeResult {
+	result := &ProbeResult{Server: name, Stage: ProbeStageStart}
+	start := time.Now()
+
+	conf, err := conf.WithSecrets(ctx, secrets.Resolve)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to resolve secrets: %w", err)
+		return result
+	}
+	mcpClient, err := NewMCPClient(name, conf)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer func() {
+		_ = mcpClient.Close()
+	}()
+
+	// fail records why the current stage did not complete
+	fail := func(stageCtx context.Context, err error) *ProbeResult {
+		switch {
+		case mcpClient.Disconnected():
+			result.Crashed = true
+			result.Err = mcpClient.Err()
+		case errors.Is(stageCtx.Err(), context.DeadlineExceeded):
+			result.Hung = true
+			result.Err = fmt.Errorf("no response within %s", timeout)
+		default:
+			result.Err = err
+		}
+		result.Stderr = mcpClient.Stderr()
+		return result
+	}
+
+	// Each stage gets its own deadline; a lost connection aborts it at once
+	stage := func() (context.Context, context.CancelFunc) {
+		stageCtx, cancelTimeout := context.WithTimeout(ctx, timeout)
+		stageCtx, cancelLost := mcpClient.abortOnLost(stageCtx)
+		return stageCtx, func() {
+			cancelLost(nil)
+			cancelTimeout()
+		}
+	}
+
+	if mcpClient.NeedManualStart() {
+		// The transport keeps using the context passed to Start, so it lives
+		// as long as the probe; the stage deadline only bounds the wait
+		connCtx, stopConn := context.WithCancel(context.WithoutCancel(ctx))
+		defer stopConn()
+		startCtx, cancel := stage()
+		started := make(chan error, 1)
+		go func() {
+			started <- mcpClient.GetClient().Start(connCtx)
+		}()
+		select {
+		case err = <-started:
+		case <-startCtx.Done():
+			err = context.Cause(startCtx)
+		}
+		if err != nil {
+			defer cancel()
+			return fail(startCtx, err)
+		}
+		cancel()
+	}
+
+	result.Stage = ProbeStageInitialize
+	initCtx, cancel := stage()
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{Name: "mcp-proxy-doctor"}
+	initResult, err := mcpClient.GetClient().Initialize(initCtx, initRequest)
+	if err != nil {
+		defer cancel()
+		return fail(initCtx, err)
+	}
+	cancel()
+	result.StartupTime = time.Since(start)
+	result.ServerInfo = initResult.ServerInfo
+	result.ProtocolVersion = initResult.ProtocolVersion
+	result.Capabilities = initResult.Capabilities
+
+	result.Stage = ProbeStageListTools
+	listStart := time.Now()
+	listCtx, cancel := stage()
+	defer cancel()
+	request := mcp.ListToolsRequest{}
+	for {
+		tools, err := mcpClient.GetClient().ListTools(listCtx, request)
+		if err != nil {
+			return fail(listCtx, err)
+		}
+		result.Tools = append(result.Tools, tools.Tools...)
+		if tools.NextCursor == "" {
+			break
+		}
+		request.Params.Cursor = tools.NextCursor
+	}
+	result.ListToolsTime = time.Since(listStart)
+
+	result.Stage = ProbeStageDone
+	result.Stderr = mcpClient.Stderr()
+	return result
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// probeServerEnv makes the test binary act as a stdio server that behaves as
+// the variable's value says: "ok", "crash" or "hang".
+const probeServerEnv = "LAZY_MCP_TEST_PROBE_SERVER"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(probeServerEnv) {
+	case "ok":
+		mcpServer := server.NewMCPServer("probe-server", "1.2.3")
+		mcpServer.AddTool(mcp.NewTool("noop"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultText(""), nil
+		})
+		_ = server.ServeStdio(mcpServer)
+		os.Exit(0)
+	case "crash":
+		fmt.Fprintln(os.Stderr, "fatal: missing API key")
+		os.Exit(2)
+	case "hang":
+		_, _ = io.Copy(io.Discard, os.Stdin)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func probeTestServer(t *testing.T, behaviour string, timeout time.Duration) *ProbeResult {
+	executable, err := os.Executable()
+	require.NoError(t, err)
+	return Probe(context.Background(), "probed", &config.MCPClientConfigV2{
+		Command: executable,
+		Env:     map[string]string{probeServerEnv: behaviour},
+		Options: &config.OptionsV2{},
+	}, timeout)
+}
+
+// TestProbeReportsHealthyServer verifies that a working server is reported
+// with its identity, capabilities and tools.
+func TestProbeReportsHealthyServer(t *testing.T) {
+	result := probeTestServer(t, "ok", 5*time.Second)
+	require.True(t, result.OK(), "probe failed at %s: %v", result.Stage, result.Err)
+
+	assert.Equal(t, "probe-server", result.ServerInfo.Name)
+	assert.Equal(t, "1.2.3", result.ServerInfo.Version)
+	assert.NotNil(t, result.Capabilities.Tools)
+	require.Len(t, result.Tools, 1)
+	assert.Equal(t, "noop", result.Tools[0].Name)
+	assert.Positive(t, result.StartupTime)
+}
+
+// TestProbeDetectsCrash verifies that a server exiting during startup is
+// flagged as crashed, with its stderr output.
+func TestProbeDetectsCrash(t *testing.T) {
+	result := probeTestServer(t, "crash", 5*time.Second)
+	assert.False(t, result.OK())
+	assert.True(t, result.Crashed)
+	assert.False(t, result.Hung)
+	assert.ErrorIs(t, result.Err, ErrProcessExited)
+	assert.Contains(t, result.Stderr, "missing API key")
+}
+
+// TestProbeDetectsHang verifies that a server which never answers is flagged
+// as hung at the stage it stalled.
+func TestProbeDetectsHang(t *testing.T) {
+	result := probeTestServer(t, "hang", 200*time.Millisecond)
+	assert.False(t, result.OK())
+	assert.True(t, result.Hung)
+	assert.Equal(t, ProbeStageInitialize, result.Stage)
+}
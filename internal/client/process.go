@@ -0,0 +1,237 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+	"github.com/voicetreelab/lazy-mcp/internal/orphans"
+)
+
+// ErrProcessExited is returned for calls that were cut short because the stdio
+// server process exited. It wraps ErrConnectionLost.
+var ErrProcessExited = fmt.Errorf("%w: process exited", ErrConnectionLost)
+
+// stderrTailSize is how much of a child's most recent stderr output is kept
+// for diagnosing crashes.
+const stderrTailSize = 4096
+
+// processStopTimeout is how long Close waits for a child to exit on its own
+// after its stdin is closed before sending it SIGTERM, and then again before
+// killing it.
+const processStopTimeout = 5 * time.Second
+
+// childProcess is a stdio MCP server subprocess. lazy-mcp spawns it itself,
+// rather than letting the transport do so, so that it can observe unexpected
+// exits and control how the process is torn down.
+type childProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr io.ReadCloser
+
+	stderrTail *tailBuffer   // Latest stderr output, read continuously so the child never blocks on a full pipe
+	stderrDone chan struct{} // Closed once stderr has been read to the end
+	done       chan struct{} // Closed once the process has exited
+	err        error         // Exit error, valid once done is closed
+
+	// onKill cleans up after the process once it had to be killed, if set
+	onKill func()
+}
+
+// startChildProcess launches command for the given server with the
+// environment processEnv returns for process and env, in process.Cwd, within
+// process.Limits, in process.Sandbox and through the egress proxy if
+// process.Egress is set. Its stderr is copied to stderrCopy, if not nil. It
+// is tracked as running the named Docker container, if container is not
+// empty, until it exits.
+func startChildProcess(server, container, command string, args []string, process config.ProcessConfig, env map[string]string, stderrCopy io.Writer) (*childProcess, error) {
+	var egressProxy string
+	if process.Egress != nil {
+		proxied, proxyURL, err := egressEnv(server, process.Egress, env, false)
+		if err != nil {
+			return nil, err
+		}
+		env, egressProxy = proxied, proxyURL.Host
+	}
+	envs, err := processEnv(process, env)
+	if err != nil {
+		return nil, err
+	}
+	if process.Sandbox != nil {
+		if command, args, err = sandboxCommand(process.Sandbox, command, args, process.Cwd, egressProxy); err != nil {
+			return nil, fmt.Errorf("failed to sandbox command: %w", err)
+		}
+	}
+	cmd := exec.Command(command, args...)
+	cmd.Env = envs
+	cmd.Dir = process.Cwd
+	limiter, err := limitProcess(server, cmd, process.Limits)
+	if err != nil {
+		return nil, err
+	}
+
+	configureChild(cmd)
+
+	// Rather than StdinPipe and the like, which exec closes as soon as the
+	// process exits, discarding a final response the transport has not read
+	// yet
+	childStdin, stdin, err := newPipe(false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	childStdout, stdout, err := newPipe(true)
+	if err != nil {
+		closeAll(childStdin, stdin)
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	childStderr, stderr, err := newPipe(true)
+	if err != nil {
+		closeAll(childStdin, stdin, childStdout, stdout)
+		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+	cmd.Stdin = childStdin
+	cmd.Stdout = childStdout
+	cmd.Stderr = childStderr
+
+	err = cmd.Start()
+	// The child holds its own copies of its ends
+	closeAll(childStdin, childStdout, childStderr)
+	if err != nil {
+		closeAll(stdin, stdout, stderr)
+		limiter.release()
+		return nil, fmt.Errorf("failed to start command: %w", err)
+	}
+	if err := limiter.started(cmd.Process); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		closeAll(stdin, stdout, stderr)
+		limiter.release()
+		return nil, fmt.Errorf("failed to limit process: %w", err)
+	}
+	untrack, err := orphans.Default.Track(server, cmd.Process.Pid, container)
+	if err != nil {
+		logging.ForServer(server).Warn("Failed to track process; it will not be cleaned up if lazy-mcp crashes", "error", err)
+	}
+
+	p := &childProcess{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+
+		stderrTail: &tailBuffer{max: stderrTailSize},
+		stderrDone: make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	var stderrSink io.Writer = p.stderrTail
+	if stderrCopy != nil {
+		stderrSink = io.MultiWriter(p.stderrTail, stderrCopy)
+	}
+	go func() {
+		_, _ = io.Copy(stderrSink, stderr)
+		close(p.stderrDone)
+	}()
+	go func() {
+		p.err = cmd.Wait()
+		limiter.release()
+		untrack()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// closeAll closes the ends of pipes
+func closeAll(ends ...io.Closer) {
+	for _, end := range ends {
+		_ = end.Close()
+	}
+}
+
+// Done returns a channel that is closed when the process exits
+func (p *childProcess) Done() <-chan struct{} {
+	return p.done
+}
+
+// exitError describes how the process exited. Only valid once Done is closed.
+func (p *childProcess) exitError() error {
+	if p.err != nil {
+		return fmt.Errorf("%w: %v", ErrProcessExited, p.err)
+	}
+	return ErrProcessExited
+}
+
+// stderrOutput returns the end of what the process has written to stderr. Once
+// the process has exited, it waits briefly for the final output to be read.
+func (p *childProcess) stderrOutput() string {
+	select {
+	case <-p.done:
+		select {
+		case <-p.stderrDone:
+		case <-time.After(100 * time.Millisecond):
+		}
+	default:
+	}
+	return p.stderrTail.String()
+}
+
+// tailBuffer is an io.Writer that keeps only the last max bytes written
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (b *tailBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, data...)
+	if excess := len(b.buf) - b.max; excess > 0 {
+		b.buf = append(b.buf[:0], b.buf[excess:]...)
+	}
+	return len(data), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+// stop waits for the process to exit after its stdin has been closed, as the
+// MCP stdio transport asks servers to, then interrupts it and at last kills
+// it, each after processStopTimeout, and releases its pipes.
+func (p *childProcess) stop() error {
+	defer p.stderr.Close()
+	defer p.stdout.Close()
+	defer p.stdin.Close()
+
+	select {
+	case <-p.done:
+		return nil
+	case <-time.After(processStopTimeout):
+	}
+
+	if err := interruptProcess(p.cmd.Process); err == nil {
+		select {
+		case <-p.done:
+			return nil
+		case <-time.After(processStopTimeout):
+		}
+	}
+
+	if err := p.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("failed to kill process: %w", err)
+	}
+	<-p.done
+	if p.onKill != nil {
+		p.onKill()
+	}
+	return nil
+}
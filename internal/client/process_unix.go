@@ -0,0 +1,24 @@
+//go:build unix
+
+package client
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// configureChild starts a child in a process group of its own, so that the
+// processes it starts can be told apart from lazy-mcp's, and killed with it
+// if lazy-mcp leaves it behind
+func configureChild(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// interruptProcess asks a child to exit, with SIGTERM
+func interruptProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	kernel32             = windows.NewLazySystemDLL("kernel32.dll")
+	procGetConsoleWindow = kernel32.NewProc("GetConsoleWindow")
+	procAttachConsole    = kernel32.NewProc("AttachConsole")
+	procFreeConsole      = kernel32.NewProc("FreeConsole")
+)
+
+// consoleMu serializes attaching to the consoles of children to interrupt
+// them, as a process has at most one console
+var consoleMu sync.Mutex
+
+// hasConsole reports whether lazy-mcp runs in a console, which it shares
+// with its children, rather than having been started by a GUI application.
+// It is checked once, before lazy-mcp attaches to any child's console.
+var hasConsole = sync.OnceValue(func() bool {
+	window, _, _ := procGetConsoleWindow.Call()
+	return window != 0
+})
+
+// configureChild starts a child in a process group of its own, so that it
+// can be interrupted alone, and without a console window of its own popping
+// up when lazy-mcp has no console to share
+func configureChild(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= windows.CREATE_NEW_PROCESS_GROUP
+	if !hasConsole() {
+		cmd.SysProcAttr.CreationFlags |= windows.CREATE_NO_WINDOW
+	}
+}
+
+// interruptProcess asks a child to exit, with CTRL_BREAK, which console
+// programs handle as Unix ones do SIGTERM. A child without lazy-mcp's
+// console has a hidden one of its own, which lazy-mcp attaches to long
+// enough to send it.
+func interruptProcess(process *os.Process) error {
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+	if !hasConsole() {
+		if ok, _, err := procAttachConsole.Call(uintptr(process.Pid)); ok == 0 {
+			return err
+		}
+		defer procFreeConsole.Call()
+	}
+	// The child's process group has the child's ID
+	return windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, uint32(process.Pid))
+}
@@ -0,0 +1,46 @@
+//go:build windows
+
+package client
+
+import (
+	"io"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"golang.org/x/sys/windows"
+)
+
+// TestConfigureChild verifies that children start in a process group of
+// their own, keeping the creation flags they were given.
+func TestConfigureChild(t *testing.T) {
+	cmd := exec.Command("cmd")
+	configureChild(cmd)
+	assert.NotZero(t, cmd.SysProcAttr.CreationFlags&windows.CREATE_NEW_PROCESS_GROUP)
+
+	cmd = exec.Command("cmd")
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: windows.CREATE_UNICODE_ENVIRONMENT}
+	configureChild(cmd)
+	assert.NotZero(t, cmd.SysProcAttr.CreationFlags&windows.CREATE_NEW_PROCESS_GROUP)
+	assert.NotZero(t, cmd.SysProcAttr.CreationFlags&windows.CREATE_UNICODE_ENVIRONMENT)
+}
+
+// TestStartChildProcess verifies that a server process reads its stdin and
+// writes its stdout through the named pipes, and stops once its stdin is
+// closed.
+func TestStartChildProcess(t *testing.T) {
+	process, err := startChildProcess("echo", "", "cmd", []string{"/c", "set /p line= & call echo %line%"},
+		config.ProcessConfig{InheritEnv: true}, nil, nil)
+	require.NoError(t, err)
+	_, err = process.stdin.Write([]byte("hello\r\n"))
+	require.NoError(t, err)
+	out, err := io.ReadAll(process.stdout)
+	require.NoError(t, err)
+	<-process.Done()
+	assert.Equal(t, "hello", strings.TrimSpace(string(out)))
+	require.NoError(t, process.stop())
+}
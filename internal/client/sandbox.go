@@ -0,0 +1,191 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// dockerSockets are the sockets of container engines, which would let a
+// server out of a sandbox that does not hide them
+var dockerSockets = []string{"/run/docker.sock", "/var/run/docker.sock", "/run/containerd/containerd.sock", "/run/podman/podman.sock"}
+
+// sandboxScope is what a sandboxed server may access, with absolute paths
+type sandboxScope struct {
+	home       string
+	readPaths  []string
+	writePaths []string
+	network    bool
+	proxy      string // host:port of the egress proxy, the only way out if set
+}
+
+// sandboxCommand returns the command line that runs command with args in
+// the given sandbox. The working directory, if any, is readable in it, and
+// the egress proxy at egressProxy, if any, reachable.
+func sandboxCommand(sandbox *config.SandboxConfig, command string, args []string, cwd, egressProxy string) (string, []string, error) {
+	tool, err := sandbox.SandboxTool()
+	if err != nil {
+		return "", nil, err
+	}
+	// The sandbox runs the command lazy-mcp would have
+	if path, err := exec.LookPath(command); err == nil {
+		command = path
+	}
+
+	scope := sandboxScope{network: sandbox.Network == config.SandboxNetworkHost, proxy: egressProxy}
+	scope.home, _ = os.UserHomeDir()
+	if cwd != "" {
+		scope.readPaths = append(scope.readPaths, cwd)
+	}
+	scope.readPaths = append(scope.readPaths, sandbox.ReadPaths...)
+	scope.writePaths = append(scope.writePaths, sandbox.WritePaths...)
+	for _, paths := range [][]string{scope.readPaths, scope.writePaths} {
+		for i, path := range paths {
+			if paths[i], err = sandboxPath(path, scope.home); err != nil {
+				return "", nil, err
+			}
+		}
+	}
+
+	switch tool {
+	case config.SandboxBubblewrap:
+		return tool, append(bwrapArgs(scope), append([]string{"--", command}, args...)...), nil
+	case config.SandboxFirejail:
+		return tool, append(firejailArgs(scope), append([]string{"--", command}, args...)...), nil
+	case config.SandboxSandboxExec:
+		return tool, append([]string{"-p", sandboxExecProfile(scope), command}, args...), nil
+	default:
+		return "", nil, fmt.Errorf("unknown sandbox tool %q", tool)
+	}
+}
+
+// sandboxPath makes a path of a sandbox's scope absolute, expanding ~
+func sandboxPath(path, home string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home == "" {
+			return "", fmt.Errorf("sandbox path %s: no home directory", path)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	return filepath.Abs(path)
+}
+
+// bwrapArgs returns the bubblewrap options of a sandbox, in its own
+// namespaces, that sees the file system read-only and without the home
+// directory, /tmp and /run, which hold the sockets of other services
+func bwrapArgs(scope sandboxScope) []string {
+	args := []string{"--die-with-parent", "--new-session", "--unshare-all"}
+	// The egress proxy listens on the host's loopback interface
+	if scope.network || scope.proxy != "" {
+		args = append(args, "--share-net")
+	}
+	args = append(args, "--ro-bind", "/", "/", "--dev", "/dev", "--proc", "/proc", "--tmpfs", "/tmp", "--tmpfs", "/run")
+	if scope.network {
+		// Where systemd-resolved keeps the resolv.conf /etc links to
+		args = append(args, "--ro-bind-try", "/run/systemd/resolve", "/run/systemd/resolve")
+	}
+	if scope.home != "" {
+		args = append(args, "--tmpfs", scope.home)
+	}
+	for _, path := range scope.readPaths {
+		args = append(args, "--ro-bind-try", path, path)
+	}
+	for _, path := range scope.writePaths {
+		args = append(args, "--bind-try", path, path)
+	}
+	return args
+}
+
+// firejailArgs returns the firejail options of a sandbox that sees only the
+// scope's paths of the home directory, a private /tmp, and no sockets of
+// container engines. Outside the home directory, it may write wherever the
+// user may.
+func firejailArgs(scope sandboxScope) []string {
+	args := []string{"--quiet", "--noprofile", "--caps.drop=all", "--nonewprivs", "--noroot", "--seccomp", "--private-tmp"}
+	if !scope.network && scope.proxy == "" {
+		args = append(args, "--net=none")
+	}
+	var homePaths []string
+	for _, path := range append(append([]string{}, scope.readPaths...), scope.writePaths...) {
+		if scope.home != "" && strings.HasPrefix(path, scope.home+string(filepath.Separator)) {
+			homePaths = append(homePaths, path)
+		}
+	}
+	if len(homePaths) == 0 {
+		args = append(args, "--private")
+	}
+	for _, path := range homePaths {
+		args = append(args, "--whitelist="+path)
+	}
+	for _, path := range scope.readPaths {
+		args = append(args, "--read-only="+path)
+	}
+	for _, socket := range dockerSockets {
+		args = append(args, "--blacklist="+socket)
+	}
+	return args
+}
+
+// sandboxExecProfile returns the sandbox-exec profile of a sandbox that
+// cannot read the home directory, or write outside of temporary
+// directories, but for the scope's paths. Later rules take precedence.
+func sandboxExecProfile(scope sandboxScope) string {
+	var profile strings.Builder
+	profile.WriteString("(version 1)\n(allow default)\n")
+	switch {
+	case scope.proxy != "":
+		_, port, _ := net.SplitHostPort(scope.proxy)
+		profile.WriteString("(deny network*)\n(allow network-outbound (remote ip " + sbplString("localhost:"+port) + "))\n")
+	case !scope.network:
+		profile.WriteString("(deny network*)\n")
+	}
+	tempDirs := []string{"/private/tmp", "/private/var/folders", realPath(os.TempDir())}
+	profile.WriteString("(deny file-write*)\n(allow file-write* (subpath \"/dev\")" + sbplSubpaths(tempDirs) + ")\n")
+	if scope.home != "" {
+		profile.WriteString("(deny file-read* (subpath " + sbplString(realPath(scope.home)) + "))\n")
+		// Runtimes look up the directories above the files they load
+		profile.WriteString("(allow file-read-metadata)\n")
+	}
+	scopePaths := func(paths []string) string {
+		real := make([]string, len(paths))
+		for i, path := range paths {
+			real[i] = realPath(path)
+		}
+		return sbplSubpaths(real)
+	}
+	if len(scope.readPaths)+len(scope.writePaths) > 0 {
+		profile.WriteString("(allow file-read*" + scopePaths(scope.readPaths) + scopePaths(scope.writePaths) + ")\n")
+	}
+	if len(scope.writePaths) > 0 {
+		profile.WriteString("(allow file-write*" + scopePaths(scope.writePaths) + ")\n")
+	}
+	return profile.String()
+}
+
+// realPath resolves the symbolic links of path, as sandbox-exec matches
+// paths once resolved, such as /tmp as /private/tmp
+func realPath(path string) string {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		return real
+	}
+	return path
+}
+
+// sbplSubpaths returns the subpath filters of a sandbox-exec rule
+func sbplSubpaths(paths []string) string {
+	var filters strings.Builder
+	for _, path := range paths {
+		filters.WriteString(" (subpath " + sbplString(path) + ")")
+	}
+	return filters.String()
+}
+
+// sbplString quotes s as a string of a sandbox-exec profile
+func sbplString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
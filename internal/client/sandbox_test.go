@@ -0,0 +1,65 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestSandboxCommand verifies that a sandboxed server runs under the
+// configured tool with its working directory and read paths read-only, its
+// write paths writable, ~ expanded, and the network cut off unless allowed,
+// but for the egress proxy.
+func TestSandboxCommand(t *testing.T) {
+	t.Setenv("HOME", "/home/dev")
+	sandbox := &config.SandboxConfig{
+		Tool:       config.SandboxBubblewrap,
+		ReadPaths:  []string{"/srv/docs"},
+		WritePaths: []string{"~/.npm"},
+	}
+	command, args, err := sandboxCommand(sandbox, "/opt/mcp/server", []string{"--stdio"}, "/srv/mcp", "")
+	require.NoError(t, err)
+	assert.Equal(t, "bwrap", command)
+	assert.Equal(t, []string{
+		"--die-with-parent", "--new-session", "--unshare-all",
+		"--ro-bind", "/", "/", "--dev", "/dev", "--proc", "/proc", "--tmpfs", "/tmp", "--tmpfs", "/run",
+		"--tmpfs", "/home/dev",
+		"--ro-bind-try", "/srv/mcp", "/srv/mcp", "--ro-bind-try", "/srv/docs", "/srv/docs",
+		"--bind-try", "/home/dev/.npm", "/home/dev/.npm",
+		"--", "/opt/mcp/server", "--stdio",
+	}, args)
+
+	sandbox.Tool = config.SandboxFirejail
+	sandbox.Network = config.SandboxNetworkHost
+	_, args, err = sandboxCommand(sandbox, "/opt/mcp/server", nil, "", "")
+	require.NoError(t, err)
+	assert.Contains(t, args, "--whitelist=/home/dev/.npm")
+	assert.Contains(t, args, "--read-only=/srv/docs")
+	assert.Contains(t, args, "--blacklist=/run/docker.sock")
+	assert.NotContains(t, args, "--net=none")
+	assert.NotContains(t, args, "--private")
+	assert.Equal(t, []string{"--", "/opt/mcp/server"}, args[len(args)-2:])
+
+	sandbox.Tool = config.SandboxSandboxExec
+	sandbox.Network = ""
+	command, args, err = sandboxCommand(sandbox, "/opt/mcp/server", nil, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "sandbox-exec", command)
+	require.Len(t, args, 3)
+	assert.Equal(t, "-p", args[0])
+	assert.Contains(t, args[1], "(deny network*)")
+	assert.Contains(t, args[1], `(deny file-read* (subpath "/home/dev"))`)
+	assert.Contains(t, args[1], `(allow file-write* (subpath "/home/dev/.npm"))`)
+	assert.Equal(t, "/opt/mcp/server", args[2])
+
+	// Only the egress proxy is reachable with an egress policy
+	_, args, err = sandboxCommand(sandbox, "/opt/mcp/server", nil, "", "127.0.0.1:4242")
+	require.NoError(t, err)
+	assert.Contains(t, args[1], "(deny network*)\n(allow network-outbound (remote ip \"localhost:4242\"))")
+
+	sandbox.Tool = "nsjail"
+	_, _, err = sandboxCommand(sandbox, "/opt/mcp/server", nil, "", "")
+	assert.ErrorContains(t, err, "unknown sandbox tool")
+}
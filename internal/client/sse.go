@@ -0,0 +1,47 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ErrConnectionLost is returned for calls that were cut short because the
+// connection to the server went away.
+var ErrConnectionLost = errors.New("MCP server connection lost")
+
+// streamWatcher is an http.RoundTripper that reports when a server-sent event
+// stream it returned ends, for whatever reason.
+type streamWatcher struct {
+	base  http.RoundTripper
+	onEnd func(error)
+}
+
+func (w *streamWatcher) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := w.base.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet {
+		return resp, err
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return resp, nil
+	}
+	resp.Body = &watchedBody{ReadCloser: resp.Body, onEnd: w.onEnd}
+	return resp, nil
+}
+
+// watchedBody calls onEnd the first time a read fails, including on EOF
+type watchedBody struct {
+	io.ReadCloser
+	onEnd func(error)
+	once  sync.Once
+}
+
+func (b *watchedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.once.Do(func() { b.onEnd(err) })
+	}
+	return n, err
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// baseTransport returns the round tripper that reaches the given server per
+// its tls and proxy settings, or the default one without them
+func baseTransport(name string, tlsConf *config.TLSConfig, proxy string) (http.RoundTripper, error) {
+	transport, err := config.HTTPTransport(tlsConf, proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transport config: %w", err)
+	}
+	if transport == nil {
+		return http.DefaultTransport, nil
+	}
+	if tlsConf != nil && tlsConf.InsecureSkipVerify {
+		logging.ForServer(name).Warn("TLS certificate verification is DISABLED; anyone on the network path can intercept this server's traffic", "option", "tls.insecureSkipVerify")
+	}
+	return transport, nil
+}
+
+// oauthHTTPClient returns the client the OAuth handler reaches the
+// authorization server with, nil for the default
+func oauthHTTPClient(base http.RoundTripper) *http.Client {
+	if base == http.DefaultTransport {
+		return nil
+	}
+	// The handler's own default, with the server's TLS and proxy settings
+	return &http.Client{Transport: base, Timeout: 30 * time.Second}
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestBaseTransportTrustsConfiguredCA verifies that a server whose
+// certificate is issued by the CA of tls.caFile is trusted, under the name of
+// tls.serverName, while it is not by default.
+func TestBaseTransportTrustsConfiguredCA(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600))
+
+	get := func(tlsConf *config.TLSConfig) error {
+		base, err := baseTransport("internal", tlsConf, "")
+		require.NoError(t, err)
+		resp, err := (&http.Client{Transport: base}).Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	assert.Error(t, get(nil), "the test CA is not trusted by default")
+	assert.NoError(t, get(&config.TLSConfig{CAFile: caFile}))
+	assert.NoError(t, get(&config.TLSConfig{CAFile: caFile, ServerName: "example.com"}))
+	assert.Error(t, get(&config.TLSConfig{CAFile: caFile, ServerName: "other.example.org"}))
+	assert.NoError(t, get(&config.TLSConfig{InsecureSkipVerify: true}))
+
+	_, err := baseTransport("internal", &config.TLSConfig{CertFile: caFile}, "")
+	assert.ErrorContains(t, err, "must be set together")
+}
+
+// TestBaseTransportRoutesThroughProxy verifies that requests to a server
+// with a proxy set go through the proxy.
+func TestBaseTransportRoutesThroughProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+	}))
+	defer proxy.Close()
+
+	base, err := baseTransport("internal", nil, proxy.URL)
+	require.NoError(t, err)
+	resp, err := (&http.Client{Transport: base}).Get("http://mcp.corp.example/mcp")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []string{"http://mcp.corp.example/mcp"}, proxied)
+
+	_, err = baseTransport("internal", nil, "ftp://proxy.corp.example")
+	assert.ErrorContains(t, err, "invalid proxy")
+}
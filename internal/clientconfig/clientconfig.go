@@ -0,0 +1,288 @@
+// Package clientconfig reads the MCP servers configured in other MCP clients,
+// such as Claude Desktop, Cursor and VS Code, as lazy-mcp server entries, and
+// installs lazy-mcp into them.
+package clientconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// Client is an MCP client whose config lists its servers
+type Client struct {
+	// Name identifies the client on the command line, e.g. "claude-desktop"
+	Name  string
+	Title string
+	// ServersKey is the member of the config holding the servers
+	ServersKey string
+	// path returns where the client keeps its config by default
+	path func() (string, error)
+}
+
+// Clients are the supported clients
+var Clients = []*Client{
+	{Name: "claude-desktop", Title: "Claude Desktop", ServersKey: "mcpServers", path: userConfigPath("Claude", "claude_desktop_config.json")},
+	{Name: "claude-code", Title: "Claude Code", ServersKey: "mcpServers", path: homePath(".claude.json")},
+	{Name: "cursor", Title: "Cursor", ServersKey: "mcpServers", path: homePath(".cursor", "mcp.json")},
+	{Name: "vscode", Title: "VS Code", ServersKey: "servers", path: userConfigPath("Code", "User", "mcp.json")},
+}
+
+// Lookup returns the client called name
+func Lookup(name string) (*Client, error) {
+	var names []string
+	for _, c := range Clients {
+		if c.Name == name {
+			return c, nil
+		}
+		names = append(names, c.Name)
+	}
+	return nil, fmt.Errorf("unknown client %q; expected one of %s", name, strings.Join(names, ", "))
+}
+
+// Path returns where the client keeps its config by default
+func (c *Client) Path() (string, error) {
+	return c.path()
+}
+
+func userConfigPath(elem ...string) func() (string, error) {
+	return func() (string, error) {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(append([]string{dir}, elem...)...), nil
+	}
+}
+
+func homePath(elem ...string) func() (string, error) {
+	return func() (string, error) {
+		dir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(append([]string{dir}, elem...)...), nil
+	}
+}
+
+// Servers are the servers read from a client's config
+type Servers struct {
+	// Names are the names of Entries, sorted
+	Names   []string
+	Entries map[string]*config.MCPClientConfigV2
+	// Skipped are the servers that were not read, with the reason why
+	Skipped map[string]string
+	// LazyMCP are the skipped servers that run lazy-mcp itself
+	LazyMCP []string
+	// Warnings are about parts of the entries lazy-mcp cannot carry over,
+	// such as VS Code's ${input:...} variables
+	Warnings []string
+}
+
+// server is a server entry as the clients write it
+type server struct {
+	Type     string            `json:"type"`
+	Command  string            `json:"command"`
+	Args     []string          `json:"args"`
+	Env      map[string]string `json:"env"`
+	EnvFile  string            `json:"envFile"`
+	Cwd      string            `json:"cwd"`
+	URL      string            `json:"url"`
+	Headers  map[string]string `json:"headers"`
+	Disabled bool              `json:"disabled"`
+}
+
+// Read reads the servers of the client's config at path. VS Code configs
+// may contain comments and trailing commas.
+func (c *Client) Read(path string) (*Servers, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(stripJSONC(data), &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	var raw map[string]json.RawMessage
+	if servers, ok := doc[c.ServersKey]; ok {
+		if err := json.Unmarshal(servers, &raw); err != nil {
+			return nil, fmt.Errorf("%s is not an object", c.ServersKey)
+		}
+	}
+
+	result := &Servers{
+		Entries: make(map[string]*config.MCPClientConfigV2),
+		Skipped: make(map[string]string),
+	}
+	for name, data := range raw {
+		var s server
+		if err := json.Unmarshal(data, &s); err != nil {
+			result.Skipped[name] = fmt.Sprintf("invalid entry: %v", err)
+			continue
+		}
+		entry, reason := c.entry(&s)
+		if entry == nil {
+			result.Skipped[name] = reason
+			if isLazyMCP(s.Command) {
+				result.LazyMCP = append(result.LazyMCP, name)
+			}
+			continue
+		}
+		result.Warnings = append(result.Warnings, c.warnings(name, &s, entry)...)
+		result.Entries[name] = entry
+		result.Names = append(result.Names, name)
+	}
+	sort.Strings(result.Names)
+	sort.Strings(result.LazyMCP)
+	sort.Strings(result.Warnings)
+	return result, nil
+}
+
+// entry converts a server to a lazy-mcp entry, or returns why it cannot
+func (c *Client) entry(s *server) (*config.MCPClientConfigV2, string) {
+	if s.Disabled {
+		return nil, "disabled in " + c.Title
+	}
+	if isLazyMCP(s.Command) {
+		return nil, "it is lazy-mcp itself"
+	}
+	entry := &config.MCPClientConfigV2{
+		Command: s.Command,
+		Args:    s.Args,
+		Env:     translateVars(s.Env),
+		EnvFile: translateVar(s.EnvFile),
+		Cwd:     translateVar(s.Cwd),
+		URL:     s.URL,
+		Headers: translateVars(s.Headers),
+	}
+	for i, arg := range entry.Args {
+		entry.Args[i] = translateVar(arg)
+	}
+	switch s.Type {
+	case "", "stdio":
+		if s.Command == "" && s.URL == "" {
+			return nil, "it has neither a command nor a url"
+		}
+		// The clients default to Streamable HTTP, lazy-mcp to SSE
+		if s.Command == "" && !strings.HasSuffix(strings.TrimRight(s.URL, "/"), "/sse") {
+			entry.Type = config.MCPClientTypeHTTP
+		}
+	case "http", "streamable-http", "streamableHttp":
+		entry.Type = config.MCPClientTypeHTTP
+	case "sse":
+		entry.Type = config.MCPClientTypeSSE
+	default:
+		return nil, fmt.Sprintf("unsupported type %q", s.Type)
+	}
+	return entry, ""
+}
+
+// warnings reports what of a server its lazy-mcp entry does not carry over
+func (c *Client) warnings(name string, s *server, entry *config.MCPClientConfigV2) []string {
+	var warnings []string
+	values := append([]string{entry.Command, entry.URL, entry.EnvFile, entry.Cwd}, entry.Args...)
+	for _, v := range entry.Env {
+		values = append(values, v)
+	}
+	for _, v := range entry.Headers {
+		values = append(values, v)
+	}
+	unresolved := make(map[string]bool)
+	for _, v := range values {
+		for _, m := range varPattern.FindAllStringSubmatch(v, -1) {
+			if !envVarPattern.MatchString(m[1]) {
+				unresolved[m[0]] = true
+			}
+		}
+	}
+	for v := range unresolved {
+		warnings = append(warnings, fmt.Sprintf("%s: %s is only resolved by %s; replace it in the config", name, v, c.Title))
+	}
+	return warnings
+}
+
+var (
+	varPattern    = regexp.MustCompile(`\$\{([^}]*)\}`)
+	envVarPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// translateVar rewrites the ${env:NAME} references of Cursor and VS Code as
+// the ${NAME} lazy-mcp expands
+func translateVar(s string) string {
+	return varPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name, ok := strings.CutPrefix(ref[2:len(ref)-1], "env:")
+		if ok && envVarPattern.MatchString(name) {
+			return "${" + name + "}"
+		}
+		return ref
+	})
+}
+
+func translateVars(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	out := make(map[string]string, len(values))
+	for k, v := range values {
+		out[k] = translateVar(v)
+	}
+	return out
+}
+
+// isLazyMCP reports whether command runs lazy-mcp
+func isLazyMCP(command string) bool {
+	base := strings.TrimSuffix(filepath.Base(command), ".exe")
+	return base == "mcp-proxy" || base == "lazy-mcp"
+}
+
+// stripJSONC removes the comments and trailing commas of JSON with comments
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+		ch := data[i]
+		switch {
+		case inString:
+			out = append(out, ch)
+			if ch == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if ch == '"' {
+				inString = false
+			}
+		case ch == '"':
+			inString = true
+			out = append(out, ch)
+		case ch == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			i--
+		case ch == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := strings.Index(string(data[i+2:]), "*/")
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+		case ch == '}' || ch == ']':
+			// Drop a comma before the closing delimiter
+			j := len(out) - 1
+			for j >= 0 && strings.ContainsRune(" \t\r\n", rune(out[j])) {
+				j--
+			}
+			if j >= 0 && out[j] == ',' {
+				out = append(out[:j], out[j+1:]...)
+			}
+			out = append(out, ch)
+		default:
+			out = append(out, ch)
+		}
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package clientconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// readConfig writes a client config and reads it as client
+func readConfig(t *testing.T, client, content string) *Servers {
+	c, err := Lookup(client)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "mcp.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	servers, err := c.Read(path)
+	require.NoError(t, err)
+	return servers
+}
+
+// TestReadClaudeDesktop verifies that stdio and remote servers are converted,
+// and that lazy-mcp's own entry is skipped.
+func TestReadClaudeDesktop(t *testing.T) {
+	servers := readConfig(t, "claude-desktop", `{
+		"mcpServers": {
+			"github": {"command": "npx", "args": ["-y", "@modelcontextprotocol/server-github"], "env": {"GITHUB_TOKEN": "abc"}},
+			"linear": {"url": "https://mcp.linear.app/mcp"},
+			"legacy": {"url": "https://example.com/sse"},
+			"lazy-mcp": {"command": "/usr/local/bin/mcp-proxy", "args": ["-config", "config.json"]}
+		},
+		"globalShortcut": "Ctrl+Space"
+	}`)
+
+	assert.Equal(t, []string{"github", "legacy", "linear"}, servers.Names)
+	assert.Equal(t, &config.MCPClientConfigV2{
+		Command: "npx",
+		Args:    []string{"-y", "@modelcontextprotocol/server-github"},
+		Env:     map[string]string{"GITHUB_TOKEN": "abc"},
+	}, servers.Entries["github"])
+	assert.Equal(t, config.MCPClientTypeStreamable, servers.Entries["linear"].Transport())
+	assert.Equal(t, config.MCPClientTypeSSE, servers.Entries["legacy"].Transport())
+	assert.Contains(t, servers.Skipped, "lazy-mcp")
+	assert.Empty(t, servers.Warnings)
+}
+
+// TestReadVSCode verifies that comments and trailing commas are accepted,
+// that ${env:NAME} becomes ${NAME}, also in envFile, and that other variables
+// are reported.
+func TestReadVSCode(t *testing.T) {
+	servers := readConfig(t, "vscode", `{
+		// Prompted for on first start
+		"inputs": [{"type": "promptString", "id": "token", "password": true}],
+		"servers": {
+			"search": {
+				"type": "http",
+				"url": "https://search.example.com/mcp",
+				"headers": {"Authorization": "Bearer ${input:token}"},
+			},
+			/* Local tools */
+			"files": {"type": "stdio", "command": "files-mcp", "env": {"ROOT": "${env:HOME}/src"}, "envFile": "${env:HOME}/.files.env"},
+			"broken": {"type": "websocket", "url": "wss://example.com"},
+		},
+	}`)
+
+	assert.Equal(t, []string{"files", "search"}, servers.Names)
+	assert.Equal(t, "${HOME}/src", servers.Entries["files"].Env["ROOT"])
+	assert.Equal(t, "${HOME}/.files.env", servers.Entries["files"].EnvFile)
+	assert.Equal(t, config.MCPClientTypeStreamable, servers.Entries["search"].Transport())
+	assert.Equal(t, "Bearer ${input:token}", servers.Entries["search"].Headers["Authorization"])
+	assert.Equal(t, []string{"search: ${input:token} is only resolved by VS Code; replace it in the config"}, servers.Warnings)
+	assert.Contains(t, servers.Skipped["broken"], "unsupported type")
+}
+
+// TestLookupUnknownClient verifies that an unknown client names the known ones.
+func TestLookupUnknownClient(t *testing.T) {
+	_, err := Lookup("zed")
+	assert.ErrorContains(t, err, "claude-desktop, claude-code, cursor, vscode")
+}
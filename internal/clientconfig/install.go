@@ -0,0 +1,47 @@
+package clientconfig
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// Install writes entry into the client's config at path as the server name,
+// replacing any server of that name and removing the servers in remove. The
+// config is first copied to a backup next to it, whose path is returned; it
+// is "" if there was no config yet.
+func (c *Client) Install(path, name string, entry *config.MCPClientConfigV2, remove []string) (string, error) {
+	backup, err := backUp(path, time.Now())
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return backup, err
+	}
+	entries := map[string]*config.MCPClientConfigV2{name: entry}
+	return backup, config.ReplaceServers(path, c.ServersKey, remove, []string{name}, entries)
+}
+
+// backUp copies the file at path to a backup named after it and now, keeping
+// its mode, and returns the backup's path
+func backUp(path string, now time.Time) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	backup := path + "." + now.Format("20060102-150405") + ".bak"
+	// The client's config may hold secrets, so only its owner may read the
+	// backup
+	return backup, os.WriteFile(backup, data, info.Mode().Perm()&0o600)
+}
@@ -0,0 +1,60 @@
+package clientconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestInstallReplacesServers verifies that the removed servers give way to
+// lazy-mcp, that the rest of the config is kept, and that the previous config
+// is backed up.
+func TestInstallReplacesServers(t *testing.T) {
+	c, err := Lookup("claude-desktop")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "claude_desktop_config.json")
+	previous := `{"mcpServers": {"github": {"command": "npx"}, "notes": {"command": "notes-mcp"}}, "theme": "dark"}`
+	require.NoError(t, os.WriteFile(path, []byte(previous), 0o644))
+
+	entry := &config.MCPClientConfigV2{Command: "/usr/local/bin/mcp-proxy", Args: []string{"-config", "/etc/lazy-mcp.yaml"}}
+	backup, err := c.Install(path, "lazy-mcp", entry, []string{"github"})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(backup)
+	require.NoError(t, err)
+	assert.Equal(t, previous, string(data))
+
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"mcpServers": {
+			"notes": {"command": "notes-mcp"},
+			"lazy-mcp": {"command": "/usr/local/bin/mcp-proxy", "args": ["-config", "/etc/lazy-mcp.yaml"]}
+		},
+		"theme": "dark"
+	}`, string(data))
+
+	servers, err := c.Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"lazy-mcp"}, servers.LazyMCP)
+}
+
+// TestInstallCreatesConfig verifies that a missing config is created, with no
+// backup.
+func TestInstallCreatesConfig(t *testing.T) {
+	c, err := Lookup("cursor")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), ".cursor", "mcp.json")
+
+	backup, err := c.Install(path, "lazy-mcp", &config.MCPClientConfigV2{Command: "mcp-proxy"}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, backup)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"mcpServers": {"lazy-mcp": {"command": "mcp-proxy"}}}`, string(data))
+}
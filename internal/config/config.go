@@ -1,15 +1,25 @@
 package config
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
 	nethttp "net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/TBXark/optional-go"
 	"github.com/go-sphere/confstore"
-	"github.com/go-sphere/confstore/codec"
 	"github.com/go-sphere/confstore/provider"
 	"github.com/go-sphere/confstore/provider/file"
 	"github.com/go-sphere/confstore/provider/http"
@@ -19,25 +29,141 @@ type StdioMCPClientConfig struct {
 	Command string            `json:"command"`
 	Env     map[string]string `json:"env"`
 	Args    []string          `json:"args"`
+	Process ProcessConfig     `json:"process"`
+}
+
+// ProcessConfig is the environment and working directory a stdio server's
+// process is spawned with
+type ProcessConfig struct {
+	// InheritEnv passes on lazy-mcp's whole environment; otherwise only
+	// BaseEnv and the variables matching EnvAllowlist
+	InheritEnv   bool     `json:"inheritEnv"`
+	EnvAllowlist []string `json:"envAllowlist"`
+	// EnvFile is a dotenv file of variables to set, below Env
+	EnvFile string `json:"envFile"`
+	// Cwd is the working directory; lazy-mcp's if empty
+	Cwd string `json:"cwd"`
+	// Limits bounds the resources of the process, if set
+	Limits *ResourceLimitsConfig `json:"limits"`
+	// Sandbox confines the process, if set
+	Sandbox *SandboxConfig `json:"sandbox"`
+	// Egress restricts the hosts the process may reach, if set
+	Egress *EgressConfig `json:"egress"`
+}
+
+// EgressConfig restricts the hosts a stdio or docker server may reach to
+// those of Allow, through lazy-mcp's egress proxy
+type EgressConfig struct {
+	// Allow lists domain names such as api.github.com, wildcards such as
+	// *.github.com matching their subdomains, IP addresses and CIDRs, each
+	// optionally with a :port
+	Allow []string `json:"allow"`
+}
+
+// Tools a stdio server can be sandboxed with
+const (
+	SandboxBubblewrap  = "bwrap"        // Linux
+	SandboxFirejail    = "firejail"     // Linux
+	SandboxSandboxExec = "sandbox-exec" // macOS
+)
+
+// Network access of a sandboxed server
+const (
+	SandboxNetworkNone = "none" // None at all, the default
+	SandboxNetworkHost = "host" // That of lazy-mcp
+)
+
+// SandboxConfig runs a stdio server in a sandbox that hides the home
+// directory but for ReadPaths and WritePaths, lets it write only to
+// WritePaths and a private /tmp, and cuts it off from the network
+type SandboxConfig struct {
+	// Tool is the sandbox used; defaults to bwrap, or firejail if bwrap is
+	// not installed, on Linux, and sandbox-exec on macOS
+	Tool string `json:"tool,omitempty"`
+	// ReadPaths are files and directories the server may read, and
+	// WritePaths those it may also write; ~ is the home directory
+	ReadPaths  []string `json:"readPaths,omitempty"`
+	WritePaths []string `json:"writePaths,omitempty"`
+	// Network is SandboxNetworkNone or SandboxNetworkHost
+	Network string `json:"network,omitempty"`
+}
+
+// SandboxTool returns the tool the sandbox is run with: Tool, or the default
+// for the platform
+func (s *SandboxConfig) SandboxTool() (string, error) {
+	if s.Tool != "" {
+		return s.Tool, nil
+	}
+	switch runtime.GOOS {
+	case "linux":
+		for _, tool := range []string{SandboxBubblewrap, SandboxFirejail} {
+			if _, err := exec.LookPath(tool); err == nil {
+				return tool, nil
+			}
+		}
+		return "", errors.New("neither bwrap nor firejail is installed")
+	case "darwin":
+		return SandboxSandboxExec, nil
+	default:
+		return "", fmt.Errorf("sandboxing is not supported on %s", runtime.GOOS)
+	}
+}
+
+// BaseEnv are the variables of lazy-mcp's environment a server process is
+// always passed, for its runtime to find its way around, even with
+// inheritEnv: false. LC_* matches the locale settings.
+var BaseEnv = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "LANG", "LC_*", "TERM", "TZ", "TMPDIR", "TEMP", "TMP",
+	// Windows
+	"SYSTEMROOT", "SYSTEMDRIVE", "WINDIR", "COMSPEC", "PATHEXT", "USERPROFILE", "APPDATA", "LOCALAPPDATA", "PROGRAMDATA", "PROGRAMFILES",
+}
+
+// When the image of a docker server is pulled
+const (
+	DockerPullMissing = "missing" // If it is not present locally
+	DockerPullAlways  = "always"
+	DockerPullNever   = "never"
+)
+
+// DockerMCPClientConfig is a stdio server run in a container
+type DockerMCPClientConfig struct {
+	Image   string            `json:"image"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env"`
+	Volumes []string          `json:"volumes"`
+	Network string            `json:"network"`
+	Pull    string            `json:"pull"`
+	// Process applies to docker itself; the container only ever sees Env
+	// and the EnvFile's variables
+	Process ProcessConfig `json:"process"`
 }
 
 type SSEMCPClientConfig struct {
 	URL     string            `json:"url"`
 	Headers map[string]string `json:"headers"`
+	OAuth   *OAuthConfig      `json:"oauth"`
+	TLS     *TLSConfig        `json:"tls"`
+	Proxy   string            `json:"proxy"`
 }
 
 type StreamableMCPClientConfig struct {
 	URL     string            `json:"url"`
 	Headers map[string]string `json:"headers"`
 	Timeout time.Duration     `json:"timeout"`
+	OAuth   *OAuthConfig      `json:"oauth"`
+	TLS     *TLSConfig        `json:"tls"`
+	Proxy   string            `json:"proxy"`
 }
 
 type MCPClientType string
 
 const (
 	MCPClientTypeStdio      MCPClientType = "stdio"
+	MCPClientTypeDocker     MCPClientType = "docker"
 	MCPClientTypeSSE        MCPClientType = "sse"
 	MCPClientTypeStreamable MCPClientType = "streamable-http"
+	// MCPClientTypeHTTP is shorthand for MCPClientTypeStreamable
+	MCPClientTypeHTTP MCPClientType = "http"
 )
 
 type MCPServerType string
@@ -62,43 +188,1237 @@ type ToolFilterConfig struct {
 	List []string       `json:"list,omitempty"`
 }
 
+// Classes of errors a RetryConfig may retry
+const (
+	RetryOnConnection = "connection" // The server crashed or its connection dropped
+	RetryOnTransport  = "transport"  // The request could not be sent or its response not read
+)
+
+// RetryConfig retries tool calls that failed for a transient reason
+type RetryConfig struct {
+	// MaxAttempts is the most calls made, including the first; defaults to 2
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	// Backoff is the delay before the first retry, doubled for each one after
+	// up to MaxBackoff
+	Backoff    Duration `json:"backoff,omitempty"`
+	MaxBackoff Duration `json:"maxBackoff,omitempty"`
+	// RetryOn lists the classes of errors retried; defaults to connection
+	RetryOn []string `json:"retryOn,omitempty"`
+}
+
+// CircuitBreakerConfig stops calling a server that keeps failing
+type CircuitBreakerConfig struct {
+	// FailureThreshold is how many calls in a row must fail for the circuit to
+	// open; defaults to 5, and 0 disables the breaker
+	FailureThreshold optional.Field[int] `json:"failureThreshold,omitempty"`
+	// OpenDuration is how long calls fail fast before one is let through to
+	// test the server; defaults to 30s
+	OpenDuration Duration `json:"openDuration,omitempty"`
+}
+
+// RateLimitConfig is a token bucket allowing Requests calls per Per, with
+// bursts of up to Burst calls, which defaults to Requests
+type RateLimitConfig struct {
+	Requests int      `json:"requests"`
+	Per      Duration `json:"per"`
+	Burst    int      `json:"burst,omitempty"`
+}
+
+// ResourceLimitsConfig bounds the resources a stdio or docker server's
+// process, and the processes it starts, may use. Zero leaves a resource
+// unbounded.
+type ResourceLimitsConfig struct {
+	// MemoryMB is the memory it may use, in MiB
+	MemoryMB int `json:"memoryMB,omitempty"`
+	// CPUs is how many CPUs' worth of time it may use, e.g. 0.5 for half of
+	// one
+	CPUs float64 `json:"cpus,omitempty"`
+	// OpenFiles is how many files it may have open, per process
+	OpenFiles int `json:"openFiles,omitempty"`
+	// Processes is how many processes and threads it may run
+	Processes int `json:"processes,omitempty"`
+}
+
+// IsZero reports whether no resource is bounded
+func (l *ResourceLimitsConfig) IsZero() bool {
+	return l == nil || *l == ResourceLimitsConfig{}
+}
+
+// ToolDescriptionConfig curates a tool's description: Description replaces
+// it, and Append is added to the end of it
+type ToolDescriptionConfig struct {
+	Description string `json:"description,omitempty"`
+	Append      string `json:"append,omitempty"`
+}
+
+// ToolArgumentsConfig sets arguments of a tool's calls: Defaults are passed
+// when a call leaves them out, and Force replaces what a call passes. Both
+// are hidden from the tool's input schema.
+type ToolArgumentsConfig struct {
+	Defaults map[string]any `json:"defaults,omitempty"`
+	Force    map[string]any `json:"force,omitempty"`
+}
+
+// ResultTransformConfig is one transform of a tool's results, set by one of
+// its fields
+type ResultTransformConfig struct {
+	// Extract keeps only what a path such as $.items[*].title selects from
+	// the result's structured content or JSON text
+	Extract string `json:"extract,omitempty"`
+	// Redact replaces the matches of a regular expression in the result
+	// with Replacement, by default [REDACTED]
+	Redact      string `json:"redact,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	// MaxLines cuts each of the result's texts down to this many lines
+	MaxLines int `json:"maxLines,omitempty"`
+	// HTMLToMarkdown converts the result's HTML texts to Markdown
+	HTMLToMarkdown bool `json:"htmlToMarkdown,omitempty"`
+}
+
 type OptionsV2 struct {
-	PanicIfInvalid    optional.Field[bool] `json:"panicIfInvalid,omitempty"`
-	LogEnabled        optional.Field[bool] `json:"logEnabled,omitempty"`
-	LazyLoad          optional.Field[bool] `json:"lazyLoad,omitempty"`
-	RecursiveLazyLoad optional.Field[bool] `json:"recursiveLazyLoad,omitempty"`
-	AuthTokens        []string             `json:"authTokens,omitempty"`
-	ToolFilter        *ToolFilterConfig    `json:"toolFilter,omitempty"`
+	PanicIfInvalid    optional.Field[bool]     `json:"panicIfInvalid,omitempty"`
+	LogEnabled        optional.Field[bool]     `json:"logEnabled,omitempty"`
+	LazyLoad          optional.Field[bool]     `json:"lazyLoad,omitempty"`
+	RecursiveLazyLoad optional.Field[bool]     `json:"recursiveLazyLoad,omitempty"`
+	AuthTokens        []string                 `json:"authTokens,omitempty"`
+	ToolFilter        *ToolFilterConfig        `json:"toolFilter,omitempty"`
+	MaxConcurrent     optional.Field[int]      `json:"maxConcurrent,omitempty"`
+	IdleTimeout       optional.Field[Duration] `json:"idleTimeout,omitempty"`
+	MaxRestarts       optional.Field[int]      `json:"maxRestarts,omitempty"`
+	// PingInterval is how often SSE and streamable HTTP servers are pinged to
+	// keep their connection alive and find out it was dropped; zero disables
+	// pinging
+	PingInterval optional.Field[Duration] `json:"pingInterval,omitempty"`
+	// IncludeTools and ExcludeTools are glob patterns of the server's tool
+	// names to expose and to hide; see ToolIncluded
+	IncludeTools []string `json:"includeTools,omitempty"`
+	ExcludeTools []string `json:"excludeTools,omitempty"`
+	// ToolNames exposes the named tools under other names, and ToolAliases
+	// under additional ones; both are keyed by the server's own tool names
+	ToolNames   map[string]string   `json:"toolNames,omitempty"`
+	ToolAliases map[string][]string `json:"toolAliases,omitempty"`
+	// ToolDescriptions replaces or extends the descriptions of the named
+	// tools, keyed by the server's own tool names
+	ToolDescriptions map[string]ToolDescriptionConfig `json:"toolDescriptions,omitempty"`
+	// ToolArguments sets arguments of the named tools' calls, keyed by the
+	// server's own tool names
+	ToolArguments map[string]ToolArgumentsConfig `json:"toolArguments,omitempty"`
+	// ToolTags adds tags to the named tools, keyed by the server's own tool
+	// names, to those of the server
+	ToolTags map[string][]string `json:"toolTags,omitempty"`
+	// LogLevel is the minimum level of records logged: debug, info, warn or
+	// error. Set on a server, it applies to records about that server.
+	LogLevel optional.Field[string] `json:"logLevel,omitempty"`
+	// CacheTTL caches the results of the named tools, or of every tool with
+	// "*", for the given time. Tools annotated as destructive are never cached.
+	CacheTTL map[string]Duration `json:"cacheTTL,omitempty"`
+	// AllowSampling lets servers ask the connected client to sample an LLM
+	// during a call; defaults to true
+	AllowSampling optional.Field[bool] `json:"allowSampling,omitempty"`
+	// DeclineElicitation answers servers asking the user for input with a
+	// decline instead of forwarding them to the client, for deployments
+	// without anyone to answer; defaults to false
+	DeclineElicitation optional.Field[bool] `json:"declineElicitation,omitempty"`
+	// LogMessageLevel is the minimum level of the log messages servers send
+	// to clients, from debug to emergency, in place of the one clients ask for
+	LogMessageLevel optional.Field[string] `json:"logMessageLevel,omitempty"`
+	// CallTimeout bounds a tool call, including waiting for the server's slot;
+	// a tool's own timeout in the hierarchy takes precedence
+	CallTimeout optional.Field[Duration] `json:"callTimeout,omitempty"`
+	// DeadlineMargin is kept back from the deadline of a caller that sets
+	// one, so that a call cut short by it is answered before the caller gives
+	// up; defaults to 100ms
+	DeadlineMargin optional.Field[Duration] `json:"deadlineMargin,omitempty"`
+	// HungCallTimeout is how long a call may stay stuck past its timeout,
+	// or its caller giving up, before the server's connection is closed and
+	// the server restarted; defaults to 10s, and zero never does
+	HungCallTimeout optional.Field[Duration] `json:"hungCallTimeout,omitempty"`
+	// ValidateArguments checks the arguments of tool calls against the tool's
+	// inputSchema in the hierarchy and fails those that do not match without
+	// calling, or starting, the server; defaults to true
+	ValidateArguments optional.Field[bool] `json:"validateArguments,omitempty"`
+	// OutputValidation is what is done with results that do not match the
+	// outputSchema their tool declares: warn (the default), reject or off
+	OutputValidation optional.Field[string] `json:"outputValidation,omitempty"`
+	// MaxResultBytes bounds the size of the tool results passed on to
+	// clients; zero, the default, leaves them whole
+	MaxResultBytes optional.Field[int] `json:"maxResultBytes,omitempty"`
+	// OversizedResults is what is done with results over MaxResultBytes:
+	// truncate (the default), or spill, which also saves the whole result to
+	// a temporary file and links it as a resource
+	OversizedResults optional.Field[string] `json:"oversizedResults,omitempty"`
+	// ResultTransforms slims the results of the named tools, or of every
+	// other tool with "*", by the given transforms in turn, before they are
+	// cut down to MaxResultBytes
+	ResultTransforms map[string][]ResultTransformConfig `json:"resultTransforms,omitempty"`
+	// MaxMessageBytes bounds the size of the JSON-RPC messages a stdio or
+	// Docker server may write; larger ones are dropped, and a response among
+	// them fails its call. Defaults to 64 MiB, and zero means no bound.
+	MaxMessageBytes optional.Field[int] `json:"maxMessageBytes,omitempty"`
+	// MaxImageDimension scales down images in tool results whose width or
+	// height is larger, keeping their aspect ratio; zero, the default, leaves
+	// them as they are
+	MaxImageDimension optional.Field[int] `json:"maxImageDimension,omitempty"`
+	// MaxInlineBinaryBytes saves images, audio and binary resources in tool
+	// results larger than this many bytes to temporary files and links them
+	// as resources instead; zero, the default, always inlines them
+	MaxInlineBinaryBytes optional.Field[int] `json:"maxInlineBinaryBytes,omitempty"`
+	// ForwardRequestID passes the request ID of each tool call on to the
+	// server in the call's _meta, for servers that log it; defaults to false
+	ForwardRequestID optional.Field[bool] `json:"forwardRequestId,omitempty"`
+	// SlowCallThreshold is how long a tool call may take before it is logged
+	// as slow; defaults to 10s, and zero never logs calls as slow
+	SlowCallThreshold optional.Field[Duration] `json:"slowCallThreshold,omitempty"`
+	// UnhealthyAfterTimeouts marks a server unhealthy after that many tool
+	// calls in a row time out; zero, the default, never does
+	UnhealthyAfterTimeouts optional.Field[int] `json:"unhealthyAfterTimeouts,omitempty"`
+	// Retry configures the retrying of failed tool calls
+	Retry *RetryConfig `json:"retry,omitempty"`
+	// CircuitBreaker configures failing fast for servers that keep failing
+	CircuitBreaker *CircuitBreakerConfig `json:"circuitBreaker,omitempty"`
+	// RateLimit limits the calls to all of a server's tools together, and
+	// ToolRateLimits those to each of the named tools
+	RateLimit      *RateLimitConfig           `json:"rateLimit,omitempty"`
+	ToolRateLimits map[string]RateLimitConfig `json:"toolRateLimits,omitempty"`
+	// Limits bounds the memory, CPU, open files and processes of a stdio or
+	// docker server
+	Limits *ResourceLimitsConfig `json:"limits,omitempty"`
+
+	// HealthCheckInterval is how often connected servers are pinged (mcpProxy only)
+	HealthCheckInterval optional.Field[Duration] `json:"healthCheckInterval,omitempty"`
+	// WatchConfig applies edits to a local config file's mcpServers without a
+	// restart; defaults to true (mcpProxy only)
+	WatchConfig optional.Field[bool] `json:"watchConfig,omitempty"`
+	// LogFormat is text or json; defaults to text (mcpProxy only)
+	LogFormat optional.Field[string] `json:"logFormat,omitempty"`
+	// ExposeExpandedTools adds the tools revealed by get_tools_in_category to
+	// the requesting client's own tool list (mcpProxy only)
+	ExposeExpandedTools optional.Field[bool] `json:"exposeExpandedTools,omitempty"`
+	// ToolTokenBudget is the estimated number of tokens a get_tools_in_category
+	// listing may take; subcategories are listed with their tools in full while
+	// they fit, most used first (mcpProxy only)
+	ToolTokenBudget optional.Field[int] `json:"toolTokenBudget,omitempty"`
+	// Exposure is how tools are offered to clients: hybrid (the default),
+	// hierarchical or flat (mcpProxy only)
+	Exposure optional.Field[string] `json:"exposure,omitempty"`
+	// Pin are glob patterns of the tool paths hybrid exposure always lists
+	// directly, such as "github.create_issue" or "trello.*" (mcpProxy only)
+	Pin []string `json:"pin,omitempty"`
+	// AdminTools offers the lazy_* meta-tools that let the model inspect and
+	// restart servers and reload the config (mcpProxy only)
+	AdminTools optional.Field[bool] `json:"adminTools,omitempty"`
+	// DryRun checks and logs tool calls, answering with what would have been
+	// executed instead of forwarding them (mcpProxy only)
+	DryRun optional.Field[bool] `json:"dryRun,omitempty"`
+	// ShutdownTimeout is how long shutting down waits for the tool calls in
+	// flight to finish; defaults to 30s (mcpProxy only)
+	ShutdownTimeout optional.Field[Duration] `json:"shutdownTimeout,omitempty"`
+	// PrefetchSchemas fetches the tool schemas the hierarchy lacks from every
+	// server in the background, once a client has first listed tools, and
+	// PrefetchConcurrency is how many servers it starts at a time; defaults
+	// to DefaultPrefetchConcurrency (mcpProxy only)
+	PrefetchSchemas     optional.Field[bool] `json:"prefetchSchemas,omitempty"`
+	PrefetchConcurrency optional.Field[int]  `json:"prefetchConcurrency,omitempty"`
+	// MaxTotalConcurrent bounds the tool calls in flight across all servers,
+	// on top of each server's MaxConcurrent; calls beyond it queue, taking
+	// turns between servers. Unset or 0 means no bound (mcpProxy only)
+	MaxTotalConcurrent optional.Field[int] `json:"maxTotalConcurrent,omitempty"`
+	// DuplicateTools is how tools of different servers listed under the
+	// same name are told apart: prefix (the default), priority or error
+	// (mcpProxy only)
+	DuplicateTools optional.Field[string] `json:"duplicateTools,omitempty"`
+	// ServerPriority orders servers for duplicateTools: priority, the first
+	// listed keeping the name; servers not listed come after, by name
+	// (mcpProxy only)
+	ServerPriority []string `json:"serverPriority,omitempty"`
+	// ExposeTags exposes only the tools with at least one of these tags,
+	// per their servers' tags and toolTags; none exposes every tool
+	// (mcpProxy only)
+	ExposeTags []string `json:"exposeTags,omitempty"`
+	// ToolNameSeparator joins the segments of tool paths in the names of the
+	// tools listed directly; defaults to _ (mcpProxy only)
+	ToolNameSeparator optional.Field[string] `json:"toolNameSeparator,omitempty"`
+	// ToolNameCase recases the words of each segment of those names:
+	// preserve (the default), snake, kebab or camel (mcpProxy only)
+	ToolNameCase optional.Field[string] `json:"toolNameCase,omitempty"`
+	// ClientProfile is the kind of client whose rules those names must
+	// follow: mcp (the default), openai, anthropic or cursor (mcpProxy only)
+	ClientProfile optional.Field[string] `json:"clientProfile,omitempty"`
+	// NotificationQueueSize is how many notifications are queued for a
+	// client that reads them slower than servers send them; defaults to
+	// DefaultNotificationQueueSize (mcpProxy only)
+	NotificationQueueSize optional.Field[int] `json:"notificationQueueSize,omitempty"`
+	// NotificationOverflow is what is done with a notification for a client
+	// whose queue is full: drop (the default) drops the oldest queued one, and
+	// pause holds up the sender until there is room (mcpProxy only)
+	NotificationOverflow optional.Field[string] `json:"notificationOverflow,omitempty"`
+}
+
+// DefaultNotificationQueueSize is how many notifications are queued for each
+// client, unless notificationQueueSize says otherwise
+const DefaultNotificationQueueSize = 256
+
+// Notification overflow modes
+const (
+	// NotificationOverflowDrop drops the oldest notification queued for the
+	// client to make room
+	NotificationOverflowDrop = "drop"
+	// NotificationOverflowPause waits for the client to read one
+	NotificationOverflowPause = "pause"
+)
+
+// DefaultPrefetchConcurrency is how many servers prefetchSchemas starts at a
+// time, unless prefetchConcurrency says otherwise
+const DefaultPrefetchConcurrency = 2
+
+// Exposure modes
+const (
+	// ExposureHybrid lists the pinned and most used tools directly, named by
+	// their paths, and hides the rest behind get_tools_in_category
+	ExposureHybrid = "hybrid"
+	// ExposureHierarchical hides every tool behind get_tools_in_category
+	ExposureHierarchical = "hierarchical"
+	// ExposureFlat lists every tool directly, named by its path
+	ExposureFlat = "flat"
+)
+
+// Output validation modes
+const (
+	// OutputValidationWarn logs results that do not match their tool's
+	// outputSchema and returns them as they are
+	OutputValidationWarn = "warn"
+	// OutputValidationReject fails calls whose results do not match their
+	// tool's outputSchema
+	OutputValidationReject = "reject"
+	// OutputValidationOff does not check results
+	OutputValidationOff = "off"
+)
+
+// Oversized result modes
+const (
+	// OversizedResultsTruncate cuts results down to maxResultBytes, saying so
+	OversizedResultsTruncate = "truncate"
+	// OversizedResultsSpill cuts results down to maxResultBytes and saves
+	// them whole to be read as a resource
+	OversizedResultsSpill = "spill"
+)
+
+// Duplicate tool strategies
+const (
+	// DuplicateToolsPrefix lists each of the tools as <server>_<name>
+	DuplicateToolsPrefix = "prefix"
+	// DuplicateToolsPriority lists only the tool of the server first in
+	// ServerPriority under the name
+	DuplicateToolsPriority = "priority"
+	// DuplicateToolsError refuses to load a config that leads to duplicates
+	DuplicateToolsError = "error"
+)
+
+// ToolPinned reports whether the tool at the given path matches a pattern of
+// Pin
+func (o *OptionsV2) ToolPinned(toolPath string) bool {
+	return o != nil && matchesAny(o.Pin, toolPath)
+}
+
+// ToolIncluded reports whether the named tool matches a pattern of
+// IncludeTools, or there are none, and no pattern of ExcludeTools. Malformed
+// patterns match nothing.
+func (o *OptionsV2) ToolIncluded(toolName string) bool {
+	if o == nil {
+		return true
+	}
+	if len(o.IncludeTools) > 0 && !matchesAny(o.IncludeTools, toolName) {
+		return false
+	}
+	return !matchesAny(o.ExcludeTools, toolName)
+}
+
+// ExposedToolName returns the name the named tool is exposed under
+func (o *OptionsV2) ExposedToolName(toolName string) string {
+	if o == nil {
+		return toolName
+	}
+	if exposed, ok := o.ToolNames[toolName]; ok && exposed != "" {
+		return exposed
+	}
+	return toolName
+}
+
+// ToolDescription returns the description to present for the named tool in
+// place of description
+func (o *OptionsV2) ToolDescription(toolName, description string) string {
+	if o == nil {
+		return description
+	}
+	override, ok := o.ToolDescriptions[toolName]
+	if !ok {
+		return description
+	}
+	if override.Description != "" {
+		description = override.Description
+	}
+	if override.Append != "" {
+		description = strings.TrimSpace(description + "\n\n" + override.Append)
+	}
+	return description
+}
+
+// ToolCallArguments returns the arguments to call the named tool with, given
+// those of a call: with its toolArguments merged in. The given arguments are
+// left as they are.
+func (o *OptionsV2) ToolCallArguments(toolName string, arguments map[string]any) map[string]any {
+	if o == nil {
+		return arguments
+	}
+	set, ok := o.ToolArguments[toolName]
+	if !ok || len(set.Defaults)+len(set.Force) == 0 {
+		return arguments
+	}
+	merged := make(map[string]any, len(arguments)+len(set.Defaults)+len(set.Force))
+	maps.Copy(merged, set.Defaults)
+	maps.Copy(merged, arguments)
+	maps.Copy(merged, set.Force)
+	return merged
+}
+
+// ToolSchema returns the input schema to present for the named tool in place
+// of schema: without the properties its toolArguments set
+func (o *OptionsV2) ToolSchema(toolName string, schema map[string]any) map[string]any {
+	if o == nil || schema == nil {
+		return schema
+	}
+	set, ok := o.ToolArguments[toolName]
+	if !ok {
+		return schema
+	}
+	hidden := func(name string) bool {
+		_, isDefault := set.Defaults[name]
+		_, isForced := set.Force[name]
+		return isDefault || isForced
+	}
+
+	exposed := maps.Clone(schema)
+	if properties, ok := schema["properties"].(map[string]any); ok {
+		kept := make(map[string]any, len(properties))
+		for name, property := range properties {
+			if !hidden(name) {
+				kept[name] = property
+			}
+		}
+		exposed["properties"] = kept
+	}
+	// Decoded from JSON the list is []any, but tools built in Go use []string
+	switch required := schema["required"].(type) {
+	case []any:
+		kept := make([]any, 0, len(required))
+		for _, name := range required {
+			if name, ok := name.(string); !ok || !hidden(name) {
+				kept = append(kept, name)
+			}
+		}
+		exposed["required"] = kept
+	case []string:
+		exposed["required"] = slices.DeleteFunc(slices.Clone(required), hidden)
+	}
+	return exposed
+}
+
+// OriginalToolName returns the server's own name for the tool exposed under
+// the given name or alias, if it is renamed or aliased
+func (o *OptionsV2) OriginalToolName(name string) (string, bool) {
+	if o == nil {
+		return "", false
+	}
+	for original, exposed := range o.ToolNames {
+		if exposed == name && original != name {
+			return original, true
+		}
+	}
+	for original, aliases := range o.ToolAliases {
+		if slices.Contains(aliases, name) {
+			return original, true
+		}
+	}
+	return "", false
+}
+
+// ParseLogLevel parses a logLevel option; the empty string means info
+func ParseLogLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", level)
 }
 
 type MCPProxyConfigV2 struct {
-	BaseURL       string        `json:"baseURL"`
-	Addr          string        `json:"addr"`
-	Name          string        `json:"name"`
-	Version       string        `json:"version"`
-	Type          MCPServerType `json:"type,omitempty"`
-	HierarchyPath string        `json:"hierarchyPath,omitempty"`
-	Options       *OptionsV2    `json:"options,omitempty"`
+	BaseURL       string            `json:"baseURL"`
+	Addr          string            `json:"addr"`
+	Name          string            `json:"name"`
+	Version       string            `json:"version"`
+	Type          MCPServerType     `json:"type,omitempty"`
+	HierarchyPath string            `json:"hierarchyPath,omitempty"`
+	Options       *OptionsV2        `json:"options,omitempty"`
+	Tracing       *TracingConfig    `json:"tracing,omitempty"`
+	Audit         *AuditConfig      `json:"audit,omitempty"`
+	Search        *SearchConfig     `json:"search,omitempty"`
+	Usage         *UsageConfig      `json:"usage,omitempty"`
+	Approval      *ApprovalConfig   `json:"approval,omitempty"`
+	Redaction     *RedactionConfig  `json:"redaction,omitempty"`
+	Auth          *AuthConfig       `json:"auth,omitempty"`
+	Cassette      *CassetteConfig   `json:"cassette,omitempty"`
+	ServerLogs    *ServerLogsConfig `json:"serverLogs,omitempty"`
+	// FanOut defines tools, by name, that each call several tools with the
+	// same arguments
+	FanOut map[string]FanOutConfig `json:"fanOut,omitempty"`
+	// Pipelines defines tools, by name, that each call several tools in turn
+	Pipelines map[string]PipelineConfig `json:"pipelines,omitempty"`
+	// VirtualServers defines categories at the root of the hierarchy, by
+	// name, that each list tools picked from other servers
+	VirtualServers map[string]VirtualServerConfig `json:"virtualServers,omitempty"`
+}
+
+// VirtualServerConfig is a category of tools picked from several servers,
+// such as a project-x listing github.create_issue and slack.post_message, so
+// that the hierarchy follows workflows rather than vendors
+type VirtualServerConfig struct {
+	// Overview describes the category, as a server's does
+	Overview string `json:"overview,omitempty"`
+	// Tools are the paths of the tools listed, as for execute_tool; each is
+	// listed under the last part of its path
+	Tools []string `json:"tools"`
+}
+
+// FanOutConfig is a tool that calls several tools, typically of different
+// servers, in parallel with its own arguments and merges their results, such
+// as a search_everywhere calling github.search and confluence.search
+type FanOutConfig struct {
+	// Description is the tool's description; defaults to one naming Tools
+	Description string `json:"description,omitempty"`
+	// Tools are the paths of the tools called, as for execute_tool
+	Tools []string `json:"tools"`
+	// InputSchema is the tool's input schema; defaults to any object
+	InputSchema map[string]any `json:"inputSchema,omitempty"`
+}
+
+// PipelineConfig is a tool that calls its Steps in turn, filling in their
+// arguments from its own arguments and what earlier steps returned, and
+// returns what the last step does
+type PipelineConfig struct {
+	// Description is the tool's description; defaults to one naming the
+	// steps' tools
+	Description string `json:"description,omitempty"`
+	// InputSchema is the tool's input schema; defaults to any object
+	InputSchema map[string]any       `json:"inputSchema,omitempty"`
+	Steps       []PipelineStepConfig `json:"steps"`
+}
+
+// PipelineStepConfig is a call made by a pipeline. Strings in its Arguments
+// may refer to the pipeline's arguments as {{input.name}}, and to the output
+// of an earlier step as {{step.field[0].name}}.
+type PipelineStepConfig struct {
+	// Name is what later steps refer to the step's output by; defaults to
+	// step1, step2 and so on
+	Name string `json:"name,omitempty"`
+	// Tool is the path of the tool called, as for execute_tool
+	Tool      string         `json:"tool"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+}
+
+// StepName returns the name of the i-th step, counting from 0
+func (c PipelineConfig) StepName(i int) string {
+	if c.Steps[i].Name != "" {
+		return c.Steps[i].Name
+	}
+	return fmt.Sprintf("step%d", i+1)
+}
+
+// DefaultAPIKeyHeader is the header HTTP clients may send their key in,
+// instead of as a bearer token
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// AuthConfig requires HTTP clients to authenticate with one of a set of
+// keys, sent as "Authorization: Bearer <key>" or in an API key header
+type AuthConfig struct {
+	Keys []string `json:"keys,omitempty"`
+	// KeysFile holds more keys, one per line; blank lines and lines starting
+	// with # are skipped. Edits apply without a restart.
+	KeysFile string `json:"keysFile,omitempty"`
+	// Header is the API key header; defaults to DefaultAPIKeyHeader
+	Header string `json:"header,omitempty"`
+}
+
+// APIKeyHeader returns the header HTTP clients may send their key in
+func (c *AuthConfig) APIKeyHeader() string {
+	if c == nil || c.Header == "" {
+		return DefaultAPIKeyHeader
+	}
+	return c.Header
+}
+
+// LoadKeys returns Keys and the keys in KeysFile
+func (c *AuthConfig) LoadKeys() ([]string, error) {
+	if c == nil {
+		return nil, nil
+	}
+	keys := slices.Clone(c.Keys)
+	if c.KeysFile == "" {
+		return keys, nil
+	}
+	data, err := os.ReadFile(c.KeysFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auth keys: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			keys = append(keys, line)
+		}
+	}
+	return keys, nil
+}
+
+// RedactionConfig masks secrets in logs and audit records, which is done by
+// default for the keys token, password and apiKey
+type RedactionConfig struct {
+	// Disabled logs and audits values as they are
+	Disabled bool `json:"disabled,omitempty"`
+	// Keys whose values are masked, in addition to the defaults. Keys match
+	// regardless of case, "_" and "-", and as suffixes, e.g. "secret" masks
+	// "clientSecret".
+	Keys []string `json:"keys,omitempty"`
+	// Patterns are regular expressions whose matches are masked within text,
+	// or only their groups if they have any, in addition to the defaults
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+// Ways of asking for the approval of tool calls
+const (
+	ApprovalViaElicitation = "elicitation" // Asks the user through the MCP client
+	ApprovalViaTerminal    = "terminal"    // Prompts on the terminal lazy-mcp runs in
+	ApprovalViaWebhook     = "webhook"     // Asks a URL, which answers for the user
+)
+
+// DefaultApprovalTimeout is how long a call waits for approval unless
+// configured otherwise
+const DefaultApprovalTimeout = 2 * time.Minute
+
+// ApprovalConfig holds back the calls of some tools until they are approved
+type ApprovalConfig struct {
+	// Via is how approval is asked for: "elicitation" (the default),
+	// "terminal" or "webhook"
+	Via string `json:"via,omitempty"`
+	// Destructive requires approval for the tools their server annotates as
+	// destructive; true by default
+	Destructive optional.Field[bool] `json:"destructive,omitempty"`
+	// Tools are patterns of <server>.<tool> whose calls require approval too,
+	// the tool named as its server names it
+	Tools []string `json:"tools,omitempty"`
+	// Webhook is asked with ApprovalViaWebhook
+	Webhook *WebhookConfig `json:"webhook,omitempty"`
+	// Timeout bounds the wait for an answer, after which the call is denied;
+	// defaults to DefaultApprovalTimeout
+	Timeout Duration `json:"timeout,omitempty"`
+}
+
+// WebhookConfig is an endpoint that is POSTed the calls to approve
+type WebhookConfig struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Required reports whether calls of the named tool of a server require
+// approval. A tool's own name is used, not the one it is exposed under.
+func (c *ApprovalConfig) Required(serverName, toolName string, destructive bool) bool {
+	if c == nil {
+		return false
+	}
+	return destructive && c.Destructive.OrElse(true) || matchesAny(c.Tools, serverName+"."+toolName)
+}
+
+// WaitTimeout returns how long a call waits for approval
+func (c *ApprovalConfig) WaitTimeout() time.Duration {
+	if c == nil || c.Timeout <= 0 {
+		return DefaultApprovalTimeout
+	}
+	return c.Timeout.Std()
+}
+
+// DefaultPromoteTools is how many of the most used tools are promoted unless
+// configured otherwise
+const DefaultPromoteTools = 5
+
+// UsageConfig configures the tracking of how often each tool is called, by
+// which the most used tools are listed directly on later runs
+type UsageConfig struct {
+	// Disabled stops tracking, saving and promoting
+	Disabled bool `json:"disabled,omitempty"`
+	// File keeps the stats across restarts; defaults to
+	// lazy-mcp/usage.json in the user's cache directory
+	File string `json:"file,omitempty"`
+	// PromoteTools is how many of the most used tools are listed directly;
+	// defaults to DefaultPromoteTools, and 0 tracks usage without promoting
+	PromoteTools optional.Field[int] `json:"promoteTools,omitempty"`
+}
+
+// Enabled reports whether tool usage is tracked, as it is by default
+func (c *UsageConfig) Enabled() bool {
+	return c == nil || !c.Disabled
+}
+
+// Promote returns how many of the most used tools to list directly
+func (c *UsageConfig) Promote() int {
+	if !c.Enabled() {
+		return 0
+	}
+	if c == nil {
+		return DefaultPromoteTools
+	}
+	return c.PromoteTools.OrElse(DefaultPromoteTools)
+}
+
+// StatsFile returns the file the usage stats are kept in
+func (c *UsageConfig) StatsFile() (string, error) {
+	if c != nil && c.File != "" {
+		return c.File, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the usage stats: %w", err)
+	}
+	return filepath.Join(dir, "lazy-mcp", "usage.json"), nil
+}
+
+// DefaultServerLogMaxSizeMB is the size at which a server's log file is
+// rotated, unless configured otherwise
+const DefaultServerLogMaxSizeMB = 10
+
+// ServerLogsConfig keeps what stdio and Docker servers write to stderr, one
+// file per server, for `mcp-proxy logs`
+type ServerLogsConfig struct {
+	// Disabled stops writing the files; the end of each server's stderr is
+	// still kept in memory
+	Disabled bool `json:"disabled,omitempty"`
+	// Dir receives a <server>.log file per server; defaults to lazy-mcp/logs
+	// in the user's cache directory
+	Dir string `json:"dir,omitempty"`
+	// MaxSizeMB is the size at which a file is rotated to <server>.log.1,
+	// replacing the previous one; defaults to DefaultServerLogMaxSizeMB
+	MaxSizeMB optional.Field[int] `json:"maxSizeMB,omitempty"`
+}
+
+// Enabled reports whether server logs are written, as they are by default
+func (c *ServerLogsConfig) Enabled() bool {
+	return c == nil || !c.Disabled
+}
+
+// MaxSize returns the size in bytes at which a file is rotated
+func (c *ServerLogsConfig) MaxSize() int64 {
+	sizeMB := DefaultServerLogMaxSizeMB
+	if c != nil {
+		sizeMB = c.MaxSizeMB.OrElse(sizeMB)
+	}
+	return int64(sizeMB) << 20
+}
+
+// LogDir returns the directory the server logs are written to
+func (c *ServerLogsConfig) LogDir() (string, error) {
+	if c != nil && c.Dir != "" {
+		return c.Dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the server logs: %w", err)
+	}
+	return filepath.Join(dir, "lazy-mcp", "logs"), nil
+}
+
+// Modes of search_tools
+const (
+	SearchModeFuzzy    = "fuzzy"    // Matches words of tool names and descriptions, allowing typos
+	SearchModeSemantic = "semantic" // Ranks tools by the similarity of embeddings
+)
+
+// SearchConfig selects how search_tools ranks tools
+type SearchConfig struct {
+	// Mode is "fuzzy" (the default) or "semantic"
+	Mode string `json:"mode,omitempty"`
+	// Embeddings configures the provider for semantic search
+	Embeddings *EmbeddingsConfig `json:"embeddings,omitempty"`
+}
+
+// EmbeddingsConfig selects the provider of embeddings for semantic search.
+// Without a URL, embeddings are computed locally by hashing words, which
+// needs no model but only finds tools sharing words with the query.
+type EmbeddingsConfig struct {
+	// URL is an OpenAI-compatible embeddings endpoint, such as
+	// https://api.openai.com/v1/embeddings or Ollama's
+	// http://localhost:11434/v1/embeddings
+	URL     string            `json:"url,omitempty"`
+	Model   string            `json:"model,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Timeout Duration          `json:"timeout,omitempty"`
+}
+
+// Semantic reports whether search_tools should rank tools by embeddings
+func (c *SearchConfig) Semantic() bool {
+	return c != nil && c.Mode == SearchModeSemantic
+}
+
+// Cassette modes
+const (
+	CassetteModeRecord = "record" // Forwards calls and saves them with their results
+	CassetteModeReplay = "replay" // Answers calls with saved results, starting no servers
+)
+
+// CassetteConfig records the tool calls forwarded to servers in a file, or
+// replays them from it, so tests of agents can run deterministically and
+// offline
+type CassetteConfig struct {
+	// File is the JSON cassette
+	File string `json:"file"`
+	// Mode is CassetteModeRecord or CassetteModeReplay
+	Mode string `json:"mode"`
+}
+
+// Replaying reports whether calls are answered from the cassette instead of
+// by the servers
+func (c *CassetteConfig) Replaying() bool {
+	return c != nil && c.Mode == CassetteModeReplay
+}
+
+// AuditConfig enables the audit log of proxied tool calls
+type AuditConfig struct {
+	// Dir receives one JSON Lines file per UTC day, named audit-YYYY-MM-DD.jsonl
+	Dir string `json:"dir"`
+	// RetentionDays is how many days of files are kept; 0 keeps them all
+	RetentionDays int `json:"retentionDays,omitempty"`
+}
+
+// TracingConfig exports OpenTelemetry traces of tool calls over OTLP/HTTP.
+// Unset fields fall back to the standard OTEL_EXPORTER_OTLP_* variables.
+type TracingConfig struct {
+	// Endpoint is the collector as host:port, or a full URL such as
+	// http://localhost:4318/v1/traces
+	Endpoint    string                  `json:"endpoint,omitempty"`
+	Insecure    bool                    `json:"insecure,omitempty"`
+	Headers     map[string]string       `json:"headers,omitempty"`
+	ServiceName string                  `json:"serviceName,omitempty"`
+	SampleRatio optional.Field[float64] `json:"sampleRatio,omitempty"`
 }
 
 type MCPClientConfigV2 struct {
 	TransportType MCPClientType `json:"transportType,omitempty"`
+	// Type is shorthand for TransportType, e.g. "type": "sse" or "type": "http"
+	Type MCPClientType `json:"type,omitempty"`
 
 	// Stdio
 	Command string            `json:"command,omitempty"`
 	Args    []string          `json:"args,omitempty"`
 	Env     map[string]string `json:"env,omitempty"`
+	// InheritEnv passes lazy-mcp's whole environment on to the process;
+	// defaults to true. Otherwise it only gets BaseEnv, the variables
+	// matching the glob patterns of EnvAllowlist, and its own.
+	InheritEnv   optional.Field[bool] `json:"inheritEnv,omitempty"`
+	EnvAllowlist []string             `json:"envAllowlist,omitempty"`
+	// EnvFile is a dotenv file of variables to set, read each time the
+	// process starts; Env takes precedence over it
+	EnvFile string `json:"envFile,omitempty"`
+	// Cwd is the working directory of the process; defaults to lazy-mcp's
+	Cwd string `json:"cwd,omitempty"`
+	// Sandbox runs the process with least privilege, for servers that are
+	// not trusted
+	Sandbox *SandboxConfig `json:"sandbox,omitempty"`
+	// Egress restricts the hosts the server, local or in a container, may
+	// reach
+	Egress *EgressConfig `json:"egress,omitempty"`
+
+	// Runner: a stdio server run from Package by npx or uvx, with Args and
+	// Env, instead of a Command
+	Runner string `json:"runner,omitempty"`
+	// Package is the npm or PyPI package, and Version pins it: a version, or
+	// a range such as "^1.2" for npx or ">=1.2,<2" for uvx
+	Package string `json:"package,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Bin is the package's executable, if it is not named after the package
+	Bin string `json:"bin,omitempty"`
+	// Install lets the runner install the package if it is not cached;
+	// defaults to true
+	Install optional.Field[bool] `json:"install,omitempty"`
+
+	// Docker: a stdio server run in a container of Image, with Args and Env
+	Image string `json:"image,omitempty"`
+	// Volumes are bind mounts as host:container[:ro]
+	Volumes []string `json:"volumes,omitempty"`
+	// Network is the container's network mode, e.g. "none" or "host"
+	Network string `json:"network,omitempty"`
+	// Pull is when the image is pulled: "missing" (the default), "always" or
+	// "never"
+	Pull string `json:"pull,omitempty"`
 
 	// SSE or Streamable HTTP
 	URL     string            `json:"url,omitempty"`
 	Headers map[string]string `json:"headers,omitempty"`
-	Timeout time.Duration     `json:"timeout,omitempty"`
+	Timeout Duration          `json:"timeout,omitempty"` // Per-request HTTP timeout, Streamable HTTP only
+	// OAuth authorizes with a server that requires it
+	OAuth *OAuthConfig `json:"oauth,omitempty"`
+	// TLS configures the connections to servers behind a private PKI
+	TLS *TLSConfig `json:"tls,omitempty"`
+	// Proxy is the http://, https:// or socks5:// proxy the server is reached
+	// through, or "direct"; defaults to HTTPS_PROXY and the like
+	Proxy string `json:"proxy,omitempty"`
+
+	// Prewarm connects the server and fetches its tool list at startup
+	// instead of waiting for the first tool call.
+	Prewarm bool `json:"prewarm,omitempty"`
+
+	// Group nests the server under a category of the hierarchy, with "/"
+	// separating levels, e.g. "productivity" or "work/devops". It is used by
+	// structure_generator when laying out the hierarchy.
+	Group string `json:"group,omitempty"`
+
+	// Tags label all of the server's tools, e.g. readonly or ci, for
+	// exposeTags and list_tools_by_tag
+	Tags []string `json:"tags,omitempty"`
 
 	Options *OptionsV2 `json:"options,omitempty"`
 }
 
+// ToolTags returns the tags of the server's named tool: the server's own
+// and those toolTags adds, in order, without duplicates
+func (c *MCPClientConfigV2) ToolTags(toolName string) []string {
+	tags := slices.Clone(c.Tags)
+	if c.Options != nil {
+		tags = append(tags, c.Options.ToolTags[toolName]...)
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// TLSConfig verifies a remote server against a private CA, and authenticates
+// lazy-mcp to it with a client certificate
+type TLSConfig struct {
+	// CAFile is a PEM bundle of CAs trusted in addition to the system's
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile and KeyFile are a PEM client certificate and its key, for mTLS
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	// ServerName is the name the server's certificate is verified against,
+	// and sent as SNI, if it differs from the URL's host
+	ServerName string `json:"serverName,omitempty"`
+	// InsecureSkipVerify accepts any certificate the server presents; only
+	// for testing
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// ClientConfig returns the tls.Config of c, reading its files
+func (c *TLSConfig) ClientConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls.caFile: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls.caFile %s contains no PEM certificates", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		if c.CertFile == "" || c.KeyFile == "" {
+			return nil, errors.New("tls.certFile and tls.keyFile must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// ProxyDirect as a server's proxy bypasses the proxy of the environment
+const ProxyDirect = "direct"
+
+// ParseProxy returns the URL of a server's proxy setting, or nil for
+// ProxyDirect
+func ParseProxy(proxy string) (*url.URL, error) {
+	if proxy == ProxyDirect {
+		return nil, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", proxy, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy %q: use an http://, https:// or socks5:// URL, or %q", proxy, ProxyDirect)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: no host", proxy)
+	}
+	return u, nil
+}
+
+// HTTPTransport returns the transport a remote server is reached with per
+// its tls and proxy settings: a clone of the default transport, or nil if
+// neither is set
+func HTTPTransport(tlsConf *TLSConfig, proxy string) (*nethttp.Transport, error) {
+	if tlsConf == nil && proxy == "" {
+		return nil, nil
+	}
+	transport := nethttp.DefaultTransport.(*nethttp.Transport).Clone()
+	if tlsConf != nil {
+		tlsConfig, err := tlsConf.ClientConfig()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	if proxy != "" {
+		proxyURL, err := ParseProxy(proxy)
+		if err != nil {
+			return nil, err
+		}
+		// A nil URL connects directly
+		transport.Proxy = nethttp.ProxyURL(proxyURL)
+	}
+	return transport, nil
+}
+
+// Token stores of OAuthConfig
+const (
+	OAuthStoreFile     = "file"     // A JSON file readable only by the user
+	OAuthStoreKeychain = "keychain" // The macOS keychain, or the Secret Service on Linux
+)
+
+// DefaultOAuthRedirectURI is where authorization servers send the user back
+// to once they have granted access
+const DefaultOAuthRedirectURI = "http://localhost:8765/oauth/callback"
+
+// OAuthConfig authorizes lazy-mcp with a remote server per the MCP
+// authorization spec: the user grants access once in the browser, and the
+// tokens are kept and refreshed. Without a ClientID, lazy-mcp registers
+// itself with the authorization server.
+type OAuthConfig struct {
+	ClientID     string   `json:"clientId,omitempty"`
+	ClientSecret string   `json:"clientSecret,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+	// RedirectURI is a localhost URL lazy-mcp listens on during
+	// authorization; defaults to DefaultOAuthRedirectURI
+	RedirectURI string `json:"redirectUri,omitempty"`
+	// MetadataURL is the authorization server's metadata; discovered from
+	// the server's URL if unset
+	MetadataURL string `json:"metadataUrl,omitempty"`
+	// Store keeps the registration and tokens: "file" (the default) or
+	// "keychain"
+	Store string `json:"store,omitempty"`
+	// TokenFile is the file store; defaults to lazy-mcp/oauth/<server>.json in
+	// the user's config directory
+	TokenFile string `json:"tokenFile,omitempty"`
+	// OpenBrowser opens the authorization page once it is needed; defaults
+	// to true
+	OpenBrowser optional.Field[bool] `json:"openBrowser,omitempty"`
+}
+
+// Redirect returns the URI the authorization is received on
+func (c *OAuthConfig) Redirect() string {
+	if c == nil || c.RedirectURI == "" {
+		return DefaultOAuthRedirectURI
+	}
+	return c.RedirectURI
+}
+
+// Transport returns the transport the server entry resolves to, following the
+// same rules as ParseMCPClientConfigV2. It returns "" for invalid entries.
+func (conf *MCPClientConfigV2) Transport() MCPClientType {
+	transportType := conf.transportType()
+	if conf.Command != "" || conf.Runner != "" || transportType == MCPClientTypeStdio {
+		return MCPClientTypeStdio
+	}
+	if conf.Image != "" || transportType == MCPClientTypeDocker {
+		return MCPClientTypeDocker
+	}
+	if conf.URL != "" {
+		if transportType == MCPClientTypeStreamable {
+			return MCPClientTypeStreamable
+		}
+		return MCPClientTypeSSE
+	}
+	return ""
+}
+
+// Serial reports whether the server takes one call at a time, as stdio and
+// Docker servers, which answer over a single pipe, do; maxConcurrent only
+// applies to remote servers
+func (conf *MCPClientConfigV2) Serial() bool {
+	transport := conf.Transport()
+	return transport != MCPClientTypeSSE && transport != MCPClientTypeStreamable
+}
+
+// Runners of packages
+const (
+	RunnerNpx = "npx"
+	RunnerUvx = "uvx"
+)
+
+// PackageSpec returns the package a runner entry runs, with its version, e.g.
+// "@modelcontextprotocol/server-github@^1.2"; "" for other entries
+func (conf *MCPClientConfigV2) PackageSpec() string {
+	if conf.Runner == "" || conf.Version == "" {
+		return conf.Package
+	}
+	return conf.Package + "@" + conf.Version
+}
+
+// runnerCommand returns the command line that runs the package of a runner
+// entry, followed by its args
+func (conf *MCPClientConfigV2) runnerCommand() (string, []string, error) {
+	if conf.Package == "" {
+		return "", nil, fmt.Errorf("package is required for runner %s", conf.Runner)
+	}
+	install := conf.Install.OrElse(true)
+	var args []string
+	switch conf.Runner {
+	case RunnerNpx:
+		if install {
+			args = append(args, "--yes")
+		} else {
+			// Fails rather than installing a package that is not cached
+			args = append(args, "--no")
+		}
+		if conf.Bin != "" {
+			args = append(args, "--package", conf.PackageSpec(), conf.Bin)
+		} else {
+			args = append(args, conf.PackageSpec())
+		}
+	case RunnerUvx:
+		if !install {
+			args = append(args, "--offline")
+		}
+		// uvx takes package@version for exact versions only; others are a
+		// requirement of --from
+		exact := conf.Version != "" && strings.IndexAny(conf.Version, "<>=!~,*") < 0
+		switch {
+		case conf.Version == "" && conf.Bin == "":
+			args = append(args, conf.Package)
+		case exact && conf.Bin == "":
+			args = append(args, conf.Package+"@"+conf.Version)
+		default:
+			requirement := conf.Package
+			if exact {
+				requirement += "==" + conf.Version
+			} else {
+				requirement += conf.Version
+			}
+			bin := conf.Bin
+			if bin == "" {
+				bin = conf.Package
+			}
+			args = append(args, "--from", requirement, bin)
+		}
+	default:
+		return "", nil, fmt.Errorf("unknown runner %q", conf.Runner)
+	}
+	return conf.Runner, append(args, conf.Args...), nil
+}
+
+// WithSecrets returns a copy of conf whose env and header values and args
+// are replaced by what resolve returns for them, which resolves secret
+// references
+func (conf *MCPClientConfigV2) WithSecrets(ctx context.Context, resolve func(ctx context.Context, value string) (string, error)) (*MCPClientConfigV2, error) {
+	resolved := *conf
+	var err error
+	resolveMap := func(values map[string]string, what string) map[string]string {
+		if values == nil || err != nil {
+			return values
+		}
+		out := make(map[string]string, len(values))
+		for key, value := range values {
+			if out[key], err = resolve(ctx, value); err != nil {
+				err = fmt.Errorf("%s %s: %w", what, key, err)
+				return nil
+			}
+		}
+		return out
+	}
+	resolved.Env = resolveMap(conf.Env, "env")
+	resolved.Headers = resolveMap(conf.Headers, "header")
+	if err != nil {
+		return nil, err
+	}
+	if conf.Args != nil {
+		resolved.Args = make([]string, len(conf.Args))
+		for i, arg := range conf.Args {
+			if resolved.Args[i], err = resolve(ctx, arg); err != nil {
+				return nil, fmt.Errorf("args[%d]: %w", i, err)
+			}
+		}
+	}
+	return &resolved, nil
+}
+
+// processConfig returns how the process of a stdio server is spawned
+func (conf *MCPClientConfigV2) processConfig() ProcessConfig {
+	process := ProcessConfig{
+		InheritEnv:   conf.InheritEnv.OrElse(true),
+		EnvAllowlist: conf.EnvAllowlist,
+		EnvFile:      conf.EnvFile,
+		Cwd:          conf.Cwd,
+		Sandbox:      conf.Sandbox,
+		Egress:       conf.Egress,
+	}
+	if conf.Options != nil {
+		process.Limits = conf.Options.Limits
+	}
+	return process
+}
+
+// transportType returns the explicitly configured transport, if any.
+// TransportType takes precedence over its Type shorthand.
+func (conf *MCPClientConfigV2) transportType() MCPClientType {
+	transportType := conf.TransportType
+	if transportType == "" {
+		transportType = conf.Type
+	}
+	if transportType == MCPClientTypeHTTP {
+		return MCPClientTypeStreamable
+	}
+	return transportType
+}
+
 func ParseMCPClientConfigV2(conf *MCPClientConfigV2) (any, error) {
-	if conf.Command != "" || conf.TransportType == MCPClientTypeStdio {
+	transportType := conf.transportType()
+	if conf.Runner != "" {
+		if conf.Command != "" {
+			return nil, errors.New("command and runner cannot both be set")
+		}
+		command, args, err := conf.runnerCommand()
+		if err != nil {
+			return nil, err
+		}
+		return &StdioMCPClientConfig{
+			Command: command,
+			Env:     conf.Env,
+			Args:    args,
+			Process: conf.processConfig(),
+		}, nil
+	}
+	if conf.Command != "" || transportType == MCPClientTypeStdio {
 		if conf.Command == "" {
 			return nil, errors.New("command is required for stdio transport")
 		}
@@ -106,19 +1426,43 @@ func ParseMCPClientConfigV2(conf *MCPClientConfigV2) (any, error) {
 			Command: conf.Command,
 			Env:     conf.Env,
 			Args:    conf.Args,
+			Process: conf.processConfig(),
+		}, nil
+	}
+	if conf.Image != "" || transportType == MCPClientTypeDocker {
+		if conf.Image == "" {
+			return nil, errors.New("image is required for docker transport")
+		}
+		return &DockerMCPClientConfig{
+			Image:   conf.Image,
+			Args:    conf.Args,
+			Env:     conf.Env,
+			Volumes: conf.Volumes,
+			Network: conf.Network,
+			Pull:    conf.Pull,
+			Process: conf.processConfig(),
 		}, nil
 	}
+	if conf.URL == "" && transportType != "" {
+		return nil, fmt.Errorf("url is required for %s transport", transportType)
+	}
 	if conf.URL != "" {
-		if conf.TransportType == MCPClientTypeStreamable {
+		if transportType == MCPClientTypeStreamable {
 			return &StreamableMCPClientConfig{
 				URL:     conf.URL,
 				Headers: conf.Headers,
-				Timeout: conf.Timeout,
+				Timeout: conf.Timeout.Std(),
+				OAuth:   conf.OAuth,
+				TLS:     conf.TLS,
+				Proxy:   conf.Proxy,
 			}, nil
 		} else {
 			return &SSEMCPClientConfig{
 				URL:     conf.URL,
 				Headers: conf.Headers,
+				OAuth:   conf.OAuth,
+				TLS:     conf.TLS,
+				Proxy:   conf.Proxy,
 			}, nil
 		}
 	}
@@ -130,6 +1474,32 @@ func ParseMCPClientConfigV2(conf *MCPClientConfigV2) (any, error) {
 type Config struct {
 	McpProxy   *MCPProxyConfigV2             `json:"mcpProxy"`
 	McpServers map[string]*MCPClientConfigV2 `json:"mcpServers"`
+
+	sources []string                // Local config file and include patterns it was loaded from
+	profile string                  // Profile applied, if any
+	reload  func() (*Config, error) // Loads the config again from the same source
+}
+
+// Sources returns the local config file and the resolved include patterns the
+// config was assembled from. A config fetched from a URL lists only its
+// includes.
+func (c *Config) Sources() []string {
+	return c.sources
+}
+
+// Profile returns the name of the profile applied to the config, or "" if
+// none was, for the caller to log once logging is set up.
+func (c *Config) Profile() string {
+	return c.profile
+}
+
+// Reload loads the config again from where it was originally loaded, with the
+// same options.
+func (c *Config) Reload() (*Config, error) {
+	if c.reload == nil {
+		return nil, errors.New("config was not loaded from a file or URL")
+	}
+	return c.reload()
 }
 
 type FullConfig struct {
@@ -138,9 +1508,16 @@ type FullConfig struct {
 
 	McpProxy   *MCPProxyConfigV2             `json:"mcpProxy"`
 	McpServers map[string]*MCPClientConfigV2 `json:"mcpServers"`
+
+	// Include lists files or glob patterns of config fragments whose
+	// mcpServers are merged into this config
+	Include []string `json:"include,omitempty"`
+
+	// Profiles are the named profiles one of which Load may apply
+	Profiles map[string]*ProfileConfig `json:"profiles,omitempty"`
 }
 
-func newConfProvider(path string, insecure, expandEnv bool, httpHeaders string, httpTimeout int) (provider.Provider, error) {
+func newConfProvider(path string, insecure bool, httpHeaders string, httpTimeout int) (provider.Provider, error) {
 	if http.IsRemoteURL(path) {
 		var opts []http.Option
 		httpClient := nethttp.DefaultClient
@@ -167,33 +1544,33 @@ func newConfProvider(path string, insecure, expandEnv bool, httpHeaders string,
 				}
 			}
 		}
-		pro := http.New(path, opts...)
-		if expandEnv {
-			return provider.NewExpandEnv(pro), nil
-		} else {
-			return pro, nil
-		}
+		return http.New(path, opts...), nil
 	}
 	if file.IsLocalPath(path) {
-		if expandEnv {
-			return provider.NewExpandEnv(file.New(path, file.WithExpandEnv())), nil
-		} else {
-			return file.New(path), nil
-		}
+		return file.New(path), nil
 	}
 	return nil, errors.New("unsupported config path")
 }
 
-func Load(path string, insecure, expandEnv bool, httpHeaders string, httpTimeout int) (*Config, error) {
-	pro, err := newConfProvider(path, insecure, expandEnv, httpHeaders, httpTimeout)
+// Load loads the config at path, with the named profile of its profiles
+// applied unless profile is empty
+func Load(path string, insecure, expandEnv bool, httpHeaders string, httpTimeout int, profile string) (*Config, error) {
+	pro, err := newConfProvider(path, insecure, httpHeaders, httpTimeout)
 	if err != nil {
 		return nil, err
 	}
-	conf, err := confstore.Load[FullConfig](pro, codec.JsonCodec())
+	conf, err := confstore.Load[FullConfig](pro, newConfCodec(path, expandEnv))
 	if err != nil {
 		return nil, err
 	}
 	adaptMCPClientConfigV1ToV2(conf)
+	includes, err := mergeIncludes(conf, path, expandEnv)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyProfile(conf, profile); err != nil {
+		return nil, err
+	}
 
 	if conf.McpProxy == nil {
 		return nil, errors.New("mcpProxy is required")
@@ -217,14 +1594,101 @@ func Load(path string, insecure, expandEnv bool, httpHeaders string, httpTimeout
 		if !clientConfig.Options.LazyLoad.Present() {
 			clientConfig.Options.LazyLoad = conf.McpProxy.Options.LazyLoad
 		}
+		if !clientConfig.Options.MaxConcurrent.Present() && !clientConfig.Serial() {
+			clientConfig.Options.MaxConcurrent = conf.McpProxy.Options.MaxConcurrent
+		}
+		if !clientConfig.Options.IdleTimeout.Present() {
+			clientConfig.Options.IdleTimeout = conf.McpProxy.Options.IdleTimeout
+		}
+		if !clientConfig.Options.MaxRestarts.Present() {
+			clientConfig.Options.MaxRestarts = conf.McpProxy.Options.MaxRestarts
+		}
+		if !clientConfig.Options.LogLevel.Present() {
+			clientConfig.Options.LogLevel = conf.McpProxy.Options.LogLevel
+		}
+		if clientConfig.Options.CacheTTL == nil {
+			clientConfig.Options.CacheTTL = conf.McpProxy.Options.CacheTTL
+		}
+		if !clientConfig.Options.AllowSampling.Present() {
+			clientConfig.Options.AllowSampling = conf.McpProxy.Options.AllowSampling
+		}
+		if !clientConfig.Options.DeclineElicitation.Present() {
+			clientConfig.Options.DeclineElicitation = conf.McpProxy.Options.DeclineElicitation
+		}
+		if !clientConfig.Options.LogMessageLevel.Present() {
+			clientConfig.Options.LogMessageLevel = conf.McpProxy.Options.LogMessageLevel
+		}
+		if !clientConfig.Options.CallTimeout.Present() {
+			clientConfig.Options.CallTimeout = conf.McpProxy.Options.CallTimeout
+		}
+		if !clientConfig.Options.DeadlineMargin.Present() {
+			clientConfig.Options.DeadlineMargin = conf.McpProxy.Options.DeadlineMargin
+		}
+		if !clientConfig.Options.HungCallTimeout.Present() {
+			clientConfig.Options.HungCallTimeout = conf.McpProxy.Options.HungCallTimeout
+		}
+		if !clientConfig.Options.ValidateArguments.Present() {
+			clientConfig.Options.ValidateArguments = conf.McpProxy.Options.ValidateArguments
+		}
+		if !clientConfig.Options.OutputValidation.Present() {
+			clientConfig.Options.OutputValidation = conf.McpProxy.Options.OutputValidation
+		}
+		if !clientConfig.Options.MaxResultBytes.Present() {
+			clientConfig.Options.MaxResultBytes = conf.McpProxy.Options.MaxResultBytes
+		}
+		if !clientConfig.Options.OversizedResults.Present() {
+			clientConfig.Options.OversizedResults = conf.McpProxy.Options.OversizedResults
+		}
+		if !clientConfig.Options.MaxMessageBytes.Present() {
+			clientConfig.Options.MaxMessageBytes = conf.McpProxy.Options.MaxMessageBytes
+		}
+		if !clientConfig.Options.MaxImageDimension.Present() {
+			clientConfig.Options.MaxImageDimension = conf.McpProxy.Options.MaxImageDimension
+		}
+		if !clientConfig.Options.MaxInlineBinaryBytes.Present() {
+			clientConfig.Options.MaxInlineBinaryBytes = conf.McpProxy.Options.MaxInlineBinaryBytes
+		}
+		if !clientConfig.Options.ForwardRequestID.Present() {
+			clientConfig.Options.ForwardRequestID = conf.McpProxy.Options.ForwardRequestID
+		}
+		if !clientConfig.Options.SlowCallThreshold.Present() {
+			clientConfig.Options.SlowCallThreshold = conf.McpProxy.Options.SlowCallThreshold
+		}
+		if !clientConfig.Options.UnhealthyAfterTimeouts.Present() {
+			clientConfig.Options.UnhealthyAfterTimeouts = conf.McpProxy.Options.UnhealthyAfterTimeouts
+		}
+		if clientConfig.Options.Retry == nil {
+			clientConfig.Options.Retry = conf.McpProxy.Options.Retry
+		}
+		if clientConfig.Options.CircuitBreaker == nil {
+			clientConfig.Options.CircuitBreaker = conf.McpProxy.Options.CircuitBreaker
+		}
+		if clientConfig.Options.RateLimit == nil {
+			clientConfig.Options.RateLimit = conf.McpProxy.Options.RateLimit
+		}
+		if clientConfig.Options.ToolRateLimits == nil {
+			clientConfig.Options.ToolRateLimits = conf.McpProxy.Options.ToolRateLimits
+		}
+		if clientConfig.Options.Limits == nil {
+			clientConfig.Options.Limits = conf.McpProxy.Options.Limits
+		}
 	}
 
 	if conf.McpProxy.Type == "" {
 		conf.McpProxy.Type = MCPServerTypeSSE // default to SSE
 	}
 
-	return &Config{
+	cfg := &Config{
 		McpProxy:   conf.McpProxy,
 		McpServers: conf.McpServers,
-	}, nil
+		profile:    profile,
+		reload: func() (*Config, error) {
+			return Load(path, insecure, expandEnv, httpHeaders, httpTimeout, profile)
+		},
+	}
+	if !http.IsRemoteURL(path) {
+		cfg.sources = append(cfg.sources, path)
+	}
+	cfg.sources = append(cfg.sources, includes...)
+	return cfg, nil
 }
@@ -94,10 +94,11 @@ func adaptMCPClientConfigV1ToV2(conf *FullConfig) {
 				}
 			case *StreamableMCPClientConfig:
 				conf.McpServers[name] = &MCPClientConfigV2{
-					URL:     v.URL,
-					Headers: v.Headers,
-					Timeout: v.Timeout,
-					Options: options,
+					TransportType: MCPClientTypeStreamable,
+					URL:           v.URL,
+					Headers:       v.Headers,
+					Timeout:       Duration(v.Timeout),
+					Options:       options,
 				}
 			default:
 				continue
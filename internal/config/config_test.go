@@ -0,0 +1,25 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMaxConcurrentIsNotInheritedByStdio verifies that mcpProxy's
+// maxConcurrent is inherited by remote servers only, as stdio servers take
+// one call at a time.
+func TestMaxConcurrentIsNotInheritedByStdio(t *testing.T) {
+	path := writeConfig(t, "config.json", `{
+  "mcpProxy": {"name": "proxy", "options": {"maxConcurrent": 4}},
+  "mcpServers": {
+    "stdio": {"command": "server"},
+    "remote": {"type": "http", "url": "http://localhost:1234/mcp"}
+  }
+}`)
+	cfg, err := Load(path, false, true, "", 10, "")
+	require.NoError(t, err)
+	assert.False(t, cfg.McpServers["stdio"].Options.MaxConcurrent.Present())
+	assert.Equal(t, 4, cfg.McpServers["remote"].Options.MaxConcurrent.OrElse(1))
+}
@@ -0,0 +1,263 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/go-sphere/confstore/provider/http"
+	"gopkg.in/yaml.v3"
+)
+
+// AddServer adds a server entry to the mcpServers of the config file at path,
+// creating the file if it does not exist. The rest of the file is kept as it
+// is, in order, and with its comments in YAML.
+func AddServer(path, name string, entry *MCPClientConfigV2) error {
+	return AddServers(path, []string{name}, map[string]*MCPClientConfigV2{name: entry})
+}
+
+// AddServers adds the entries of servers to the config file at path like
+// AddServer does, in the order of names. Nothing is written if any of them
+// already exists.
+func AddServers(path string, names []string, entries map[string]*MCPClientConfigV2) error {
+	return editServers(path, "mcpServers", func(servers *yaml.Node) error {
+		for _, name := range names {
+			if mappingValue(servers, name) != nil {
+				return fmt.Errorf("server %q already exists", name)
+			}
+			if err := setServer(servers, name, entries[name]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReplaceServers edits the servers under key of the file at path, such as
+// another MCP client's config, keeping the rest of it like AddServer does.
+// It removes the servers named in remove, then adds the entries of names,
+// replacing those that exist.
+func ReplaceServers(path, key string, remove, names []string, entries map[string]*MCPClientConfigV2) error {
+	return editServers(path, key, func(servers *yaml.Node) error {
+		for _, name := range remove {
+			removeMapping(servers, name)
+		}
+		for _, name := range names {
+			if err := setServer(servers, name, entries[name]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// editServers applies edit to the mapping under key of the file at path,
+// creating both if missing, and writes the file back
+func editServers(path, key string, edit func(servers *yaml.Node) error) error {
+	if http.IsRemoteURL(path) {
+		return fmt.Errorf("cannot edit a config fetched from a URL")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	yamlConfig := isYAMLPath(path)
+
+	var doc *yaml.Node
+	switch {
+	case len(bytes.TrimSpace(data)) == 0:
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	case yamlConfig:
+		doc = &yaml.Node{}
+		if err := yaml.Unmarshal(data, doc); err != nil {
+			return fmt.Errorf("invalid YAML: %w", err)
+		}
+	default:
+		root, err := decodeJSONNode(json.NewDecoder(bytes.NewReader(data)), false)
+		if err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("config is not an object")
+	}
+	// Editing would break the integrity check of sops
+	if mappingValue(root, "sops") != nil {
+		return fmt.Errorf("config is encrypted with sops; edit it with sops instead")
+	}
+
+	servers := mappingValue(root, key)
+	if servers == nil {
+		servers = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		root.Content = append(root.Content, stringNode(key), servers)
+	}
+	if servers.Kind == yaml.ScalarNode && servers.Tag == "!!null" {
+		// An empty mcpServers: in YAML
+		*servers = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	if servers.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s is not an object", key)
+	}
+	if err := edit(servers); err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if yamlConfig {
+		enc := yaml.NewEncoder(&out)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+		if err := enc.Close(); err != nil {
+			return err
+		}
+	} else {
+		writeJSONNode(&out, root, "")
+		out.WriteByte('\n')
+	}
+	return os.WriteFile(path, out.Bytes(), 0o644)
+}
+
+// setServer sets the entry of name in a servers mapping node, in place if it
+// exists and last otherwise
+func setServer(servers *yaml.Node, name string, entry *MCPClientConfigV2) error {
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// Unset optional fields marshal as null
+	entryNode, err := decodeJSONNode(json.NewDecoder(bytes.NewReader(entryJSON)), true)
+	if err != nil {
+		return err
+	}
+	if existing := mappingValue(servers, name); existing != nil {
+		*existing = *entryNode
+		return nil
+	}
+	servers.Content = append(servers.Content, stringNode(name), entryNode)
+	return nil
+}
+
+// removeMapping removes key and its value from a mapping node
+func removeMapping(mapping *yaml.Node, key string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func stringNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// decodeJSONNode decodes the next JSON value of dec into a node, keeping the
+// order of object keys. With dropNull, null members of objects are dropped.
+func decodeJSONNode(dec *json.Decoder, dropNull bool) (*yaml.Node, error) {
+	dec.UseNumber()
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch v := token.(type) {
+	case json.Delim:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if v == '[' {
+			node = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		}
+		for dec.More() {
+			var key string
+			if node.Kind == yaml.MappingNode {
+				keyToken, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, _ = keyToken.(string)
+			}
+			value, err := decodeJSONNode(dec, dropNull)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case node.Kind == yaml.SequenceNode:
+				node.Content = append(node.Content, value)
+			case !dropNull || value.Tag != "!!null":
+				node.Content = append(node.Content, stringNode(key), value)
+			}
+		}
+		if _, err := dec.Token(); err != nil { // The closing delimiter
+			return nil, err
+		}
+		return node, nil
+	case string:
+		return stringNode(v), nil
+	case json.Number:
+		// Untagged, so that YAML resolves it as an int or float
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: v.String()}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(v)}, nil
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+}
+
+// writeJSONNode writes a node decoded by decodeJSONNode as indented JSON
+func writeJSONNode(w *bytes.Buffer, node *yaml.Node, indent string) {
+	switch node.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		open, end, step := "{", "}", 2
+		if node.Kind == yaml.SequenceNode {
+			open, end, step = "[", "]", 1
+		}
+		if len(node.Content) == 0 {
+			w.WriteString(open + end)
+			return
+		}
+		w.WriteString(open + "\n")
+		inner := indent + "  "
+		for i := 0; i < len(node.Content); i += step {
+			w.WriteString(inner)
+			if step == 2 {
+				writeJSONString(w, node.Content[i].Value)
+				w.WriteString(": ")
+			}
+			writeJSONNode(w, node.Content[i+step-1], inner)
+			if i+step < len(node.Content) {
+				w.WriteByte(',')
+			}
+			w.WriteByte('\n')
+		}
+		w.WriteString(indent + end)
+	default:
+		if node.Tag == "!!str" {
+			writeJSONString(w, node.Value)
+		} else {
+			w.WriteString(node.Value)
+		}
+	}
+}
+
+func writeJSONString(w *bytes.Buffer, s string) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	w.Write(bytes.TrimRight(buf.Bytes(), "\n"))
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-sphere/confstore/codec"
+	"gopkg.in/yaml.v3"
+)
+
+// AgePrefix starts a config value encrypted with age, followed by the
+// ciphertext, ASCII-armored or base64-encoded
+const AgePrefix = "!age "
+
+// ageArmorHeader starts an ASCII-armored age ciphertext
+const ageArmorHeader = "-----BEGIN AGE ENCRYPTED FILE-----"
+
+// decryptingCodec decrypts a config, or its values encrypted with age,
+// before inner decodes it
+func decryptingCodec(inner codec.Codec, yamlFormat bool) codec.Codec {
+	return codec.NewCodec(inner.Marshal, func(data []byte, val any) error {
+		data, err := decryptConfig(data, yamlFormat)
+		if err != nil {
+			return err
+		}
+		return inner.Unmarshal(data, val)
+	})
+}
+
+// decryptConfig decrypts a config encrypted with sops, which becomes JSON,
+// then the values of it encrypted with age
+func decryptConfig(data []byte, yamlFormat bool) ([]byte, error) {
+	if sopsEncrypted(data) {
+		decrypted, err := sopsDecrypt(data, yamlFormat)
+		if err != nil {
+			return nil, err
+		}
+		data, yamlFormat = decrypted, false
+	}
+	if !bytes.Contains(data, []byte(strings.TrimSpace(AgePrefix))) {
+		return data, nil
+	}
+
+	// YAML is a superset of JSON, so both are decrypted as YAML
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := decryptAgeValues(&node); err != nil {
+		return nil, err
+	}
+	if yamlFormat {
+		return yaml.Marshal(&node)
+	}
+	var doc any
+	if err := node.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// sopsEncrypted reports whether a config was encrypted with sops, which
+// keeps its metadata under a top-level sops key
+func sopsEncrypted(data []byte) bool {
+	if !bytes.Contains(data, []byte("sops")) {
+		return false
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return false
+	}
+	metadata, ok := doc["sops"].(map[string]any)
+	if !ok {
+		return false
+	}
+	_, ok = metadata["mac"]
+	return ok
+}
+
+// sopsDecrypt decrypts a config with the sops CLI, and the keys it is
+// configured with, into JSON
+func sopsDecrypt(data []byte, yamlFormat bool) ([]byte, error) {
+	inputType := "json"
+	if yamlFormat {
+		inputType = "yaml"
+	}
+	// sops reads files, so the ciphertext is handed over in one
+	file, err := os.CreateTemp("", "lazy-mcp-*."+inputType)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("sops", "--decrypt", "--input-type", inputType, "--output-type", "json", file.Name())
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("config is encrypted with sops, which is not installed")
+		}
+		return nil, fmt.Errorf("failed to decrypt config with sops: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// decryptAgeValues replaces the values of node and its children that are
+// encrypted with age, written as "!age ..." strings or, in YAML, with an
+// !age tag, by their plaintext
+func decryptAgeValues(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		ciphertext, ok := "", false
+		switch {
+		case node.Tag == "!age":
+			ciphertext, ok = node.Value, true
+		case strings.HasPrefix(node.Value, AgePrefix):
+			ciphertext, ok = strings.TrimPrefix(node.Value, AgePrefix), true
+		}
+		if !ok {
+			return nil
+		}
+		plaintext, err := ageDecrypt(ciphertext)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		node.Tag, node.Value, node.Style = "!!str", plaintext, yaml.DoubleQuotedStyle
+		return nil
+	}
+	for _, child := range node.Content {
+		if err := decryptAgeValues(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ageIdentityFile returns the file of the age identities that decrypt
+// config values: LAZY_MCP_AGE_IDENTITY, SOPS_AGE_KEY_FILE, or the keys sops
+// uses by default
+func ageIdentityFile() (string, error) {
+	for _, name := range []string{"LAZY_MCP_AGE_IDENTITY", "SOPS_AGE_KEY_FILE"} {
+		if file := os.Getenv(name); file != "" {
+			return file, nil
+		}
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("no age identity: set LAZY_MCP_AGE_IDENTITY")
+	}
+	return filepath.Join(dir, "sops", "age", "keys.txt"), nil
+}
+
+// ageDecrypt decrypts a value with the age CLI
+func ageDecrypt(ciphertext string) (string, error) {
+	ciphertext = strings.TrimSpace(ciphertext)
+	input := []byte(ciphertext)
+	if !strings.HasPrefix(ciphertext, ageArmorHeader) {
+		decoded, err := base64.StdEncoding.DecodeString(ciphertext)
+		if err != nil {
+			return "", fmt.Errorf("age-encrypted value is neither armored nor base64: %w", err)
+		}
+		input = decoded
+	}
+	identity, err := ageIdentityFile()
+	if err != nil {
+		return "", err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("age", "--decrypt", "--identity", identity)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", fmt.Errorf("config has age-encrypted values, but age is not installed")
+		}
+		return "", fmt.Errorf("failed to decrypt value with age: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/go-sphere/confstore/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// shimCommands puts scripts named after the commands they stand in for,
+// such as sops and age, first on PATH
+func shimCommands(t *testing.T, scripts map[string]string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script shims need a Unix shell")
+	}
+	dir := t.TempDir()
+	for name, script := range scripts {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755))
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// fakeAge "decrypts" by prefixing its input, which is the ciphertext
+// decoded, with the identity file it is given
+const fakeAge = `[ "$1" = --decrypt ] && [ "$2" = --identity ] || exit 2
+printf '%s:' "$(basename "$3")"
+cat
+`
+
+func encrypted(plaintext string) string {
+	return base64.StdEncoding.EncodeToString([]byte(plaintext))
+}
+
+// TestSopsEncrypted verifies that configs encrypted with sops are told apart
+// by the mac of their sops metadata, in JSON and YAML alike.
+func TestSopsEncrypted(t *testing.T) {
+	for name, test := range map[string]struct {
+		config string
+		want   bool
+	}{
+		"JSON":               {config: `{"mcpServers": {}, "sops": {"mac": "ENC[...]", "version": "3.9.0"}}`, want: true},
+		"YAML":               {config: "mcpServers: {}\nsops:\n  mac: ENC[...]\n  version: 3.9.0\n", want: true},
+		"without mac":        {config: "sops:\n  version: 3.9.0\n"},
+		"sops as a value":    {config: `{"mcpServers": {"sops": {"command": "sops"}}}`},
+		"sops not an object": {config: "sops: mac\n"},
+		"invalid":            {config: "sops: [mac"},
+		"plain":              {config: `{"mcpProxy": {"name": "proxy"}}`},
+	} {
+		assert.Equal(t, test.want, sopsEncrypted([]byte(test.config)), name)
+	}
+}
+
+// TestDecryptAgeValues verifies that values tagged !age in YAML, or written
+// as "!age ..." strings in either format, are decrypted in place, leaving the
+// rest of the config and its format as they are.
+func TestDecryptAgeValues(t *testing.T) {
+	shimCommands(t, map[string]string{"age": fakeAge})
+	t.Setenv("LAZY_MCP_AGE_IDENTITY", "/keys/identity.txt")
+
+	yamlConfig := `mcpServers:
+  github:
+    command: github-mcp-server
+    env:
+      TOKEN: !age ` + encrypted("ghp_123") + `
+      OTHER: "!age ` + encrypted("other") + `"
+    args: ["!age ` + encrypted("arg") + `", plain]
+`
+	decrypted, err := decryptConfig([]byte(yamlConfig), true)
+	require.NoError(t, err)
+	var doc map[string]any
+	require.NoError(t, yaml.Unmarshal(decrypted, &doc), "YAML stays YAML")
+	github := doc["mcpServers"].(map[string]any)["github"].(map[string]any)
+	assert.Equal(t, map[string]any{"TOKEN": "identity.txt:ghp_123", "OTHER": "identity.txt:other"}, github["env"])
+	assert.Equal(t, []any{"identity.txt:arg", "plain"}, github["args"])
+	assert.Equal(t, "github-mcp-server", github["command"])
+
+	jsonConfig := `{"mcpServers": {"github": {"env": {"TOKEN": "!age ` + encrypted("ghp_123") + `"}}}}`
+	decrypted, err = decryptConfig([]byte(jsonConfig), false)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"mcpServers": {"github": {"env": {"TOKEN": "identity.txt:ghp_123"}}}}`, string(decrypted))
+
+	plain := []byte(`{"mcpServers": {}}`)
+	decrypted, err = decryptConfig(plain, false)
+	require.NoError(t, err)
+	assert.Equal(t, plain, decrypted, "configs without encrypted values are passed on as they are")
+
+	_, err = decryptConfig([]byte("a: 1\nb: !age not base64\n"), true)
+	assert.ErrorContains(t, err, "line 2: age-encrypted value is neither armored nor base64")
+
+	shimCommands(t, map[string]string{"age": "echo 'no identity matched' >&2\nexit 1\n"})
+	_, err = decryptConfig([]byte(jsonConfig), false)
+	assert.ErrorContains(t, err, "failed to decrypt value with age")
+	assert.ErrorContains(t, err, "no identity matched")
+
+	t.Setenv("PATH", t.TempDir())
+	_, err = decryptConfig([]byte(jsonConfig), false)
+	assert.ErrorContains(t, err, "age is not installed")
+}
+
+// TestDecryptSops verifies that a config encrypted with sops is decrypted to
+// JSON, whatever its format, before its age values are.
+func TestDecryptSops(t *testing.T) {
+	shimCommands(t, map[string]string{
+		"age": fakeAge,
+		// Checks that it is handed the ciphertext, then prints its
+		// "plaintext", which has an age value of its own
+		"sops": `grep -q mac "$6" || exit 1
+printf '{"mcpProxy": {"name": "from %s"}, "mcpServers": {"a": {"env": {"KEY": "!age ` + encrypted("key") + `"}}}}' "$3"
+`,
+	})
+	t.Setenv("LAZY_MCP_AGE_IDENTITY", "identity.txt")
+
+	decrypted, err := decryptConfig([]byte("mcpProxy: ENC[...]\nsops:\n  mac: ENC[...]\n"), true)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"mcpProxy": {"name": "from yaml"}, "mcpServers": {"a": {"env": {"KEY": "identity.txt:key"}}}}`, string(decrypted))
+
+	decrypted, err = decryptConfig([]byte(`{"mcpProxy": "ENC[...]", "sops": {"mac": "ENC[...]"}}`), false)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"mcpProxy": {"name": "from json"}, "mcpServers": {"a": {"env": {"KEY": "identity.txt:key"}}}}`, string(decrypted))
+
+	t.Setenv("PATH", t.TempDir())
+	_, err = decryptConfig([]byte(`{"sops": {"mac": "ENC[...]"}}`), false)
+	assert.ErrorContains(t, err, "sops, which is not installed")
+}
+
+// TestDecryptingCodecs verifies that a YAML config and its JSON twin, with
+// age-encrypted values, decode to the same config.
+func TestDecryptingCodecs(t *testing.T) {
+	shimCommands(t, map[string]string{"age": fakeAge})
+	t.Setenv("LAZY_MCP_AGE_IDENTITY", "identity.txt")
+
+	yamlConfig := `mcpProxy:
+  name: proxy
+  options:
+    lazyLoad: true
+mcpServers:
+  github:
+    command: github-mcp-server
+    args: [stdio]
+    env:
+      TOKEN: !age ` + encrypted("ghp_123") + `
+`
+	jsonConfig := `{
+  "mcpProxy": {"name": "proxy", "options": {"lazyLoad": true}},
+  "mcpServers": {
+    "github": {
+      "command": "github-mcp-server",
+      "args": ["stdio"],
+      "env": {"TOKEN": "!age ` + encrypted("ghp_123") + `"}
+    }
+  }
+}`
+	var fromYAML, fromJSON FullConfig
+	require.NoError(t, decryptingCodec(yamlCodec(), true).Unmarshal([]byte(yamlConfig), &fromYAML))
+	require.NoError(t, decryptingCodec(codec.JsonCodec(), false).Unmarshal([]byte(jsonConfig), &fromJSON))
+	assert.Equal(t, fromJSON, fromYAML)
+	assert.Equal(t, "identity.txt:ghp_123", fromYAML.McpServers["github"].Env["TOKEN"])
+}
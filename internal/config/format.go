@@ -0,0 +1,253 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/go-sphere/confstore/codec"
+	"github.com/voicetreelab/lazy-mcp/internal/secrets"
+	"gopkg.in/yaml.v3"
+)
+
+// isYAMLPath reports whether the config at the given file path or URL is YAML,
+// judging by its extension. Everything else is read as JSON.
+func isYAMLPath(configPath string) bool {
+	if u, err := url.Parse(configPath); err == nil && u.Scheme != "" && u.Scheme != "file" {
+		configPath = u.Path
+	}
+	switch strings.ToLower(path.Ext(configPath)) {
+	case ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+// newConfCodec returns the codec for the config at the given file path or
+// URL, which decrypts it first if it is encrypted, in whole or in part, and
+// before that expands environment variables in it if expandEnv is set
+func newConfCodec(configPath string, expandEnv bool) codec.Codec {
+	yamlFormat := isYAMLPath(configPath)
+	inner := codec.JsonCodec()
+	if yamlFormat {
+		inner = yamlCodec()
+	}
+	confCodec := decryptingCodec(inner, yamlFormat)
+	if expandEnv {
+		confCodec = expandingCodec(confCodec, yamlFormat)
+	}
+	return confCodec
+}
+
+// yamlCodec decodes YAML by converting it to JSON first, so that a YAML config
+// goes through the same json tags and unmarshalers as a JSON one.
+func yamlCodec() codec.Codec {
+	return codec.NewCodec(yaml.Marshal, func(data []byte, val any) error {
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return fmt.Errorf("invalid YAML: %w", err)
+		}
+		tagSecrets(&node)
+		var doc any
+		if err := node.Decode(&doc); err != nil {
+			return fmt.Errorf("invalid YAML: %w", err)
+		}
+		jsonData, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("unsupported YAML content: %w", err)
+		}
+		return json.Unmarshal(jsonData, val)
+	})
+}
+
+// tagSecrets turns the values tagged !secret, as in
+// "token: !secret keychain:github-token", into the strings JSON configs write
+// secret references as
+func tagSecrets(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!secret" {
+		node.Tag = "!!str"
+		node.Value = secrets.Prefix + node.Value
+	}
+	for _, child := range node.Content {
+		tagSecrets(child)
+	}
+}
+
+// expandEnv replaces $VAR and ${VAR} with the value of the environment
+// variable, and ${VAR:-default} with default when VAR is unset or empty. $$
+// is a literal $, and $ not followed by a variable name is left as it is.
+func expandEnv(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch next := s[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			name := ""
+			if end >= 0 {
+				name = s[i+2 : i+2+end]
+			}
+			if !isEnvName(strings.SplitN(name, ":-", 2)[0]) {
+				b.WriteByte('$')
+				continue
+			}
+			b.WriteString(lookupEnv(name))
+			i += 2 + end
+		case isEnvNameStart(next):
+			end := i + 2
+			for end < len(s) && (isEnvNameStart(s[end]) || '0' <= s[end] && s[end] <= '9') {
+				end++
+			}
+			b.WriteString(lookupEnv(s[i+1 : end]))
+			i = end - 1
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String()
+}
+
+// lookupEnv returns the value of the environment variable name, which may be
+// followed by :-default
+func lookupEnv(name string) string {
+	if name, fallback, ok := strings.Cut(name, ":-"); ok {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+		return fallback
+	}
+	return os.Getenv(name)
+}
+
+// isEnvName reports whether name can name an environment variable: a letter
+// or underscore, then letters, digits and underscores
+func isEnvName(name string) bool {
+	if name == "" || !isEnvNameStart(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if c := name[i]; !isEnvNameStart(c) && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func isEnvNameStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// envFields are the paths of the config values environment variables are
+// expanded in, "*" standing for any key or list item
+var envFields = [][]string{
+	{"mcpServers", "*", "command"},
+	{"mcpServers", "*", "args", "*"},
+	{"mcpServers", "*", "env", "*"},
+	{"mcpServers", "*", "url"},
+	{"mcpServers", "*", "headers", "*"},
+	{"mcpServers", "*", "proxy"},
+	{"profiles", "*", "mcpServers", "*", "command"},
+	{"profiles", "*", "mcpServers", "*", "args", "*"},
+	{"profiles", "*", "mcpServers", "*", "env", "*"},
+	{"profiles", "*", "mcpServers", "*", "url"},
+	{"profiles", "*", "mcpServers", "*", "headers", "*"},
+	{"profiles", "*", "mcpServers", "*", "proxy"},
+	{"mcpProxy", "auth", "keys", "*"},
+	{"mcpProxy", "search", "embeddings", "url"},
+	{"mcpProxy", "search", "embeddings", "headers", "*"},
+}
+
+// expandingCodec wraps inner so that environment variables are expanded in
+// the envFields of the config it decodes. The config is parsed first and
+// written back with the expanded values quoted, so that no value can break
+// or add to its structure. Configs encrypted with sops as a whole are left
+// as they are, as their message authentication code covers every value.
+func expandingCodec(inner codec.Codec, yamlFormat bool) codec.Codec {
+	return codec.NewCodec(inner.Marshal, func(data []byte, val any) error {
+		data, err := expandConfigEnv(data, yamlFormat)
+		if err != nil {
+			return err
+		}
+		return inner.Unmarshal(data, val)
+	})
+}
+
+// expandConfigEnv expands environment variables in the envFields of a JSON or
+// YAML config, returning it unchanged if none of them hold any
+func expandConfigEnv(data []byte, yamlFormat bool) ([]byte, error) {
+	if !bytes.Contains(data, []byte("$")) || sopsEncrypted(data) {
+		return data, nil
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		if yamlFormat {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if len(node.Content) == 0 {
+		return data, nil
+	}
+	expanded := false
+	for _, field := range envFields {
+		if expandNodeEnv(node.Content[0], field) {
+			expanded = true
+		}
+	}
+	if !expanded {
+		return data, nil
+	}
+	if yamlFormat {
+		return yaml.Marshal(&node)
+	}
+	var doc any
+	if err := node.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return json.Marshal(doc)
+}
+
+// expandNodeEnv expands environment variables in the string values at path
+// below node, and reports whether any of them changed
+func expandNodeEnv(node *yaml.Node, path []string) bool {
+	if len(path) == 0 {
+		if node.Kind != yaml.ScalarNode || node.Tag == "!age" || !strings.Contains(node.Value, "$") {
+			return false
+		}
+		value := expandEnv(node.Value)
+		if value == node.Value {
+			return false
+		}
+		node.Value, node.Style = value, yaml.DoubleQuotedStyle
+		return true
+	}
+	expanded := false
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if (path[0] == "*" || node.Content[i].Value == path[0]) && expandNodeEnv(node.Content[i+1], path[1:]) {
+				expanded = true
+			}
+		}
+	case yaml.SequenceNode:
+		if path[0] == "*" {
+			for _, item := range node.Content {
+				if expandNodeEnv(item, path[1:]) {
+					expanded = true
+				}
+			}
+		}
+	}
+	return expanded
+}
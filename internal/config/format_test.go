@@ -0,0 +1,184 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-sphere/confstore/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes a config file named name in a temporary directory and
+// returns its path
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// TestLoadExpandsEnv verifies that environment variables are expanded in the
+// fields meant for them, literally whatever their values hold, and that
+// other fields, such as the $1 of a redact replacement or the $ of a
+// JSONPath, are left as written.
+func TestLoadExpandsEnv(t *testing.T) {
+	t.Setenv("TRACKER_URL", "https://tracker.example.com/mcp")
+	t.Setenv("QUOTED", `ab"cd`)
+	t.Setenv("WINDOWS_PATH", `C:\tools\x\t`)
+	t.Setenv("INJECTED", `a", "evil`)
+	t.Setenv("PORT", "8080")
+
+	configs := map[string]string{
+		"config.yaml": `
+mcpProxy:
+  name: proxy
+mcpServers:
+  tracker:
+    url: ${TRACKER_URL}
+    headers:
+      Authorization: Bearer ${QUOTED}
+    options:
+      resultTransforms:
+        "*":
+          - extract: $.items[*]
+          - redact: (sk-\w{3})\w+
+            replacement: "$1-${QUOTED}"
+  tool:
+    command: ${WINDOWS_PATH}
+    args: [--token, "${INJECTED}", --port, $PORT, "$$HOME"]
+    env:
+      TOKEN: ${QUOTED}
+`,
+		"config.json": `{
+  "mcpProxy": {"name": "proxy"},
+  "mcpServers": {
+    "tracker": {
+      "url": "${TRACKER_URL}",
+      "headers": {"Authorization": "Bearer ${QUOTED}"},
+      "options": {"resultTransforms": {"*": [
+        {"extract": "$.items[*]"},
+        {"redact": "(sk-\\w{3})\\w+", "replacement": "$1-${QUOTED}"}
+      ]}}
+    },
+    "tool": {
+      "command": "${WINDOWS_PATH}",
+      "args": ["--token", "${INJECTED}", "--port", "$PORT", "$$HOME"],
+      "env": {"TOKEN": "${QUOTED}"}
+    }
+  }
+}`,
+	}
+	for name, content := range configs {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := Load(writeConfig(t, name, content), false, true, "", 10, "")
+			require.NoError(t, err)
+			tracker := cfg.McpServers["tracker"]
+			assert.Equal(t, "https://tracker.example.com/mcp", tracker.URL)
+			assert.Equal(t, `Bearer ab"cd`, tracker.Headers["Authorization"])
+			assert.Equal(t, []ResultTransformConfig{
+				{Extract: "$.items[*]"},
+				{Redact: `(sk-\w{3})\w+`, Replacement: "$1-${QUOTED}"},
+			}, tracker.Options.ResultTransforms["*"])
+
+			tool := cfg.McpServers["tool"]
+			assert.Equal(t, `C:\tools\x\t`, tool.Command)
+			assert.Equal(t, []string{"--token", `a", "evil`, "--port", "8080", "$HOME"}, tool.Args)
+			assert.Equal(t, map[string]string{"TOKEN": `ab"cd`}, tool.Env)
+		})
+	}
+
+	cfg, err := Load(writeConfig(t, "config.json", configs["config.json"]), false, false, "", 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, "${TRACKER_URL}", cfg.McpServers["tracker"].URL, "expansion can be turned off")
+}
+
+// TestExpandEnv verifies how variables are expanded: set, unset and empty
+// ones, defaults, which may hold colons, and the $ that is not a variable.
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("HOST", "example.com")
+	t.Setenv("EMPTY", "")
+	t.Setenv("UNSET", "")
+	os.Unsetenv("UNSET")
+
+	for input, want := range map[string]string{
+		"$HOST":                               "example.com",
+		"${HOST}":                             "example.com",
+		"https://${HOST}/mcp":                 "https://example.com/mcp",
+		"$HOST/mcp":                           "example.com/mcp",
+		"$UNSET":                              "",
+		"${UNSET}":                            "",
+		"${EMPTY}":                            "",
+		"${HOST:-localhost}":                  "example.com",
+		"${UNSET:-localhost}":                 "localhost",
+		"${EMPTY:-localhost}":                 "localhost",
+		"${UNSET:-http://localhost:8080/mcp}": "http://localhost:8080/mcp",
+		"${UNSET:-}":                          "",
+		"$$HOST":                              "$HOST",
+		"$1 costs $5":                         "$1 costs $5",
+		"$.items[*]":                          "$.items[*]",
+		"${1}":                                "${1}",
+		"${HOST":                              "${HOST",
+		"trailing $":                          "trailing $",
+		"no variables":                        "no variables",
+	} {
+		assert.Equal(t, want, expandEnv(input), input)
+	}
+}
+
+// TestYAMLCodec verifies that a YAML config decodes to the same config as
+// its JSON twin, !secret tags included.
+func TestYAMLCodec(t *testing.T) {
+	yamlConfig := `mcpProxy:
+  name: proxy
+  addr: :9090
+  options:
+    lazyLoad: true
+    logEnabled: null
+    maxConcurrent: 4
+mcpServers:
+  github:
+    command: github-mcp-server
+    args: [stdio, --read-only]
+    env:
+      GITHUB_TOKEN: !secret keychain:github-token
+      PORT: "8080"
+    options:
+      includeTools: [get_*]
+      toolFilter: {mode: allow, list: [get_issue]}
+  jira:
+    url: https://jira.example.com/mcp
+    headers:
+      Authorization: Bearer token
+include: [servers.d/*.yaml]
+`
+	jsonConfig := `{
+  "mcpProxy": {
+    "name": "proxy",
+    "addr": ":9090",
+    "options": {"lazyLoad": true, "logEnabled": null, "maxConcurrent": 4}
+  },
+  "mcpServers": {
+    "github": {
+      "command": "github-mcp-server",
+      "args": ["stdio", "--read-only"],
+      "env": {"GITHUB_TOKEN": "!secret keychain:github-token", "PORT": "8080"},
+      "options": {"includeTools": ["get_*"], "toolFilter": {"mode": "allow", "list": ["get_issue"]}}
+    },
+    "jira": {
+      "url": "https://jira.example.com/mcp",
+      "headers": {"Authorization": "Bearer token"}
+    }
+  },
+  "include": ["servers.d/*.yaml"]
+}`
+	var fromYAML, fromJSON FullConfig
+	require.NoError(t, yamlCodec().Unmarshal([]byte(yamlConfig), &fromYAML))
+	require.NoError(t, codec.JsonCodec().Unmarshal([]byte(jsonConfig), &fromJSON))
+	assert.Equal(t, fromJSON, fromYAML)
+	assert.Equal(t, 4, fromYAML.McpProxy.Options.MaxConcurrent.OrElse(0))
+
+	err := yamlCodec().Unmarshal([]byte("mcpServers:\n  a: {}\n  a: {}\n"), &fromYAML)
+	assert.ErrorContains(t, err, "invalid YAML")
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-sphere/confstore"
+	"github.com/go-sphere/confstore/provider/http"
+)
+
+// configFragment is a file pulled in by include. Fragments only define servers.
+type configFragment struct {
+	McpServers map[string]*MCPClientConfigV2 `json:"mcpServers"`
+}
+
+// resolveIncludePattern expands a leading ~ to the home directory and makes a
+// relative pattern relative to baseDir.
+func resolveIncludePattern(pattern, baseDir string) (string, error) {
+	if pattern == "~" || strings.HasPrefix(pattern, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to expand ~ in include %q: %w", pattern, err)
+		}
+		pattern = filepath.Join(home, pattern[1:])
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(baseDir, pattern)
+	}
+	return filepath.Clean(pattern), nil
+}
+
+// mergeIncludes loads the fragments matched by conf.Include into
+// conf.McpServers and returns the resolved include patterns. Patterns are
+// processed in order, and the files each one matches in lexical order.
+// Servers defined in the main config take precedence over included ones, and
+// two fragments defining the same server are an error.
+func mergeIncludes(conf *FullConfig, configPath string, expandEnv bool) ([]string, error) {
+	if len(conf.Include) == 0 {
+		return nil, nil
+	}
+
+	// Relative includes are resolved against the main config's directory,
+	// or the working directory for a config fetched from a URL
+	baseDir := "."
+	if !http.IsRemoteURL(configPath) {
+		baseDir = filepath.Dir(configPath)
+	}
+
+	if conf.McpServers == nil {
+		conf.McpServers = make(map[string]*MCPClientConfigV2)
+	}
+	definedIn := make(map[string]string) // Server name to the fragment that defined it
+	loaded := make(map[string]bool)
+	patterns := make([]string, 0, len(conf.Include))
+
+	for _, include := range conf.Include {
+		pattern, err := resolveIncludePattern(include, baseDir)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pattern)
+
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern %q: %w", include, err)
+		}
+		if len(files) == 0 && !hasGlobMeta(pattern) {
+			return nil, fmt.Errorf("included file not found: %s", pattern)
+		}
+		sort.Strings(files)
+
+		for _, file := range files {
+			if loaded[file] {
+				continue
+			}
+			loaded[file] = true
+
+			fragment, err := loadFragment(file, expandEnv)
+			if err != nil {
+				return nil, err
+			}
+			for name, server := range fragment.McpServers {
+				if other, exists := definedIn[name]; exists {
+					return nil, fmt.Errorf("server %s is defined in both %s and %s", name, other, file)
+				}
+				definedIn[name] = file
+				if _, exists := conf.McpServers[name]; exists {
+					slog.Info("Server from include is overridden by the main config", "server", name, "file", file)
+					continue
+				}
+				conf.McpServers[name] = server
+			}
+		}
+	}
+	return patterns, nil
+}
+
+// loadFragment reads one included file, as JSON or YAML by its extension
+func loadFragment(file string, expandEnv bool) (*configFragment, error) {
+	pro, err := newConfProvider(file, false, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	fragment, err := confstore.Load[configFragment](pro, newConfCodec(file, expandEnv))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load included config %s: %w", file, err)
+	}
+	return fragment, nil
+}
+
+// hasGlobMeta reports whether pattern contains any filepath.Match metacharacters
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
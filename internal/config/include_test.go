@@ -0,0 +1,91 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles writes files, by path relative to dir, creating directories as
+// needed
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+}
+
+// TestMergeIncludes verifies that included fragments, found relative to the
+// config's directory whatever the working directory, add their servers to
+// the main config's, which wins over them, and that the config's sources
+// are the config and the patterns it includes.
+func TestMergeIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.json": `{
+  "mcpProxy": {"name": "proxy"},
+  "mcpServers": {"github": {"command": "github-mcp-server", "args": ["--from-main"]}},
+  "include": ["servers.d/*.yaml", "extra.json", "none.d/*.json"]
+}`,
+		"servers.d/a.yaml": "mcpServers:\n  github: {command: github-mcp-server, args: [--from-fragment]}\n  jira: {url: https://jira.example.com/mcp}\n",
+		"servers.d/b.yaml": "mcpServers:\n  slack: {command: slack-mcp}\n",
+		"extra.json":       `{"mcpServers": {"notes": {"command": "notes-mcp"}}}`,
+	})
+	t.Chdir(t.TempDir())
+
+	cfg, err := Load(filepath.Join(dir, "config.json"), false, true, "", 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"github", "jira", "notes", "slack"}, keys(cfg.McpServers))
+	assert.Equal(t, []string{"--from-main"}, cfg.McpServers["github"].Args, "the main config wins")
+	assert.Equal(t, "https://jira.example.com/mcp", cfg.McpServers["jira"].URL)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "config.json"),
+		filepath.Join(dir, "servers.d", "*.yaml"),
+		filepath.Join(dir, "extra.json"),
+		filepath.Join(dir, "none.d", "*.json"),
+	}, cfg.Sources())
+}
+
+// TestMergeIncludesErrors verifies that two fragments defining the same
+// server, and included files that do not exist, fail the load.
+func TestMergeIncludesErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"duplicate.json":   `{"mcpProxy": {"name": "proxy"}, "mcpServers": {}, "include": ["servers.d/*.yaml"]}`,
+		"missing.json":     `{"mcpProxy": {"name": "proxy"}, "mcpServers": {}, "include": ["absent.json"]}`,
+		"servers.d/a.yaml": "mcpServers:\n  jira: {url: https://jira.example.com/mcp}\n",
+		"servers.d/b.yaml": "mcpServers:\n  jira: {url: https://issues.example.com/mcp}\n",
+	})
+
+	_, err := Load(filepath.Join(dir, "duplicate.json"), false, true, "", 10, "")
+	assert.ErrorContains(t, err, "server jira is defined in both "+filepath.Join(dir, "servers.d", "a.yaml")+" and "+filepath.Join(dir, "servers.d", "b.yaml"))
+
+	_, err = Load(filepath.Join(dir, "missing.json"), false, true, "", 10, "")
+	assert.ErrorContains(t, err, "included file not found: "+filepath.Join(dir, "absent.json"))
+}
+
+// TestResolveIncludePattern verifies that relative patterns are resolved
+// against the config's directory, and a leading ~ against the home
+// directory.
+func TestResolveIncludePattern(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	for pattern, want := range map[string]string{
+		"servers.d/*.yaml":    filepath.Join("/etc/lazy-mcp", "servers.d", "*.yaml"),
+		"../shared.json":      filepath.Join("/etc", "shared.json"),
+		"/opt/servers.json":   "/opt/servers.json",
+		"~/lazy-mcp/*.json":   filepath.Join(home, "lazy-mcp", "*.json"),
+		"~":                   home,
+		"~other/servers.json": filepath.Join("/etc/lazy-mcp", "~other", "servers.json"),
+	} {
+		got, err := resolveIncludePattern(pattern, "/etc/lazy-mcp")
+		require.NoError(t, err, pattern)
+		assert.Equal(t, want, got, pattern)
+	}
+}
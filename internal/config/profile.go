@@ -0,0 +1,93 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ProfileEnv names the environment variable choosing the profile when the
+// -profile flag is not given
+const ProfileEnv = "LAZY_MCP_PROFILE"
+
+// ProfileConfig adapts a config to one context, such as a machine or a kind
+// of work, so one config file serves several
+type ProfileConfig struct {
+	// Servers are the names of the config's servers the profile keeps; none
+	// keeps them all
+	Servers []string `json:"servers,omitempty"`
+	// McpProxy is merged into mcpProxy: the fields it sets replace those of
+	// mcpProxy, objects are merged field by field, and null unsets a field
+	McpProxy json.RawMessage `json:"mcpProxy,omitempty"`
+	// McpServers are merged into the kept servers of the same names the same
+	// way, or added as servers of their own
+	McpServers map[string]json.RawMessage `json:"mcpServers,omitempty"`
+}
+
+// applyProfile applies the named profile of conf.Profiles to conf: it drops
+// the servers the profile does not keep, then merges in its mcpProxy and
+// mcpServers. An empty name leaves conf as it is.
+func applyProfile(conf *FullConfig, name string) error {
+	if name == "" {
+		return nil
+	}
+	profile, ok := conf.Profiles[name]
+	if !ok || profile == nil {
+		return fmt.Errorf("unknown profile %q; the config has: %s", name, profileNames(conf.Profiles))
+	}
+
+	all := conf.McpServers
+	if len(profile.Servers) > 0 {
+		kept := make(map[string]*MCPClientConfigV2, len(profile.Servers))
+		for _, server := range profile.Servers {
+			clientConfig, ok := all[server]
+			if !ok {
+				return fmt.Errorf("profile %s keeps unknown server %s", name, server)
+			}
+			kept[server] = clientConfig
+		}
+		conf.McpServers = kept
+	}
+
+	if len(profile.McpProxy) > 0 {
+		if conf.McpProxy == nil {
+			conf.McpProxy = &MCPProxyConfigV2{}
+		}
+		// Unmarshaling into the existing config keeps the fields the
+		// profile does not set
+		if err := json.Unmarshal(profile.McpProxy, conf.McpProxy); err != nil {
+			return fmt.Errorf("invalid mcpProxy of profile %s: %w", name, err)
+		}
+	}
+	for server, raw := range profile.McpServers {
+		clientConfig, ok := conf.McpServers[server]
+		if !ok {
+			if _, dropped := all[server]; dropped {
+				return fmt.Errorf("profile %s overrides server %s, which it does not keep", name, server)
+			}
+			clientConfig = &MCPClientConfigV2{}
+		}
+		if err := json.Unmarshal(raw, clientConfig); err != nil {
+			return fmt.Errorf("invalid server %s of profile %s: %w", server, name, err)
+		}
+		if conf.McpServers == nil {
+			conf.McpServers = make(map[string]*MCPClientConfigV2)
+		}
+		conf.McpServers[server] = clientConfig
+	}
+	return nil
+}
+
+// profileNames lists the names of profiles for errors
+func profileNames(profiles map[string]*ProfileConfig) string {
+	if len(profiles) == 0 {
+		return "no profiles"
+	}
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
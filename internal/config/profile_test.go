@@ -0,0 +1,154 @@
+package config
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const profilesConfig = `
+mcpProxy:
+  name: proxy
+  options:
+    lazyLoad: true
+    logEnabled: true
+mcpServers:
+  github:
+    command: github-mcp-server
+    args: [stdio]
+    env: {GITHUB_HOST: github.com, LOG: info}
+    options:
+      includeTools: [get_*, list_*]
+  notes:
+    command: notes-mcp
+profiles:
+  work:
+    servers: [github]
+    mcpServers:
+      github:
+        args: [stdio, --read-only]
+        env: {GITHUB_HOST: github.example.com}
+      jira:
+        url: https://jira.example.com/mcp
+  quiet:
+    mcpProxy:
+      options: {logEnabled: null, lazyLoad: false}
+    mcpServers:
+      github:
+        options: {includeTools: null}
+  keepsUnknown:
+    servers: [github, gitlab]
+  overridesDropped:
+    servers: [github]
+    mcpServers:
+      notes: {args: [--verbose]}
+`
+
+// TestApplyProfile verifies each rule of applying a profile: servers keeps
+// some servers, objects merge key by key, lists are replaced whole, null
+// unsets a field, and a profile may add servers but not override ones it
+// drops.
+func TestApplyProfile(t *testing.T) {
+	path := writeConfig(t, "config.yaml", profilesConfig)
+
+	for name, test := range map[string]struct {
+		profile string
+		check   func(t *testing.T, cfg *Config)
+		err     string
+	}{
+		"no profile": {
+			check: func(t *testing.T, cfg *Config) {
+				assert.Len(t, cfg.McpServers, 2)
+				assert.Equal(t, []string{"stdio"}, cfg.McpServers["github"].Args)
+			},
+		},
+		"servers are kept, and added": {
+			profile: "work",
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, []string{"github", "jira"}, keys(cfg.McpServers))
+				assert.Equal(t, "https://jira.example.com/mcp", cfg.McpServers["jira"].URL)
+			},
+		},
+		"objects are merged": {
+			profile: "work",
+			check: func(t *testing.T, cfg *Config) {
+				github := cfg.McpServers["github"]
+				assert.Equal(t, map[string]string{"GITHUB_HOST": "github.example.com", "LOG": "info"}, github.Env)
+				assert.Equal(t, "github-mcp-server", github.Command)
+				assert.Equal(t, []string{"get_*", "list_*"}, github.Options.IncludeTools)
+			},
+		},
+		"lists are replaced": {
+			profile: "work",
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, []string{"stdio", "--read-only"}, cfg.McpServers["github"].Args)
+			},
+		},
+		"null unsets": {
+			profile: "quiet",
+			check: func(t *testing.T, cfg *Config) {
+				options := cfg.McpProxy.Options
+				assert.False(t, options.LogEnabled.Present())
+				assert.Equal(t, false, options.LazyLoad.OrElse(true))
+				assert.Nil(t, cfg.McpServers["github"].Options.IncludeTools)
+				assert.Len(t, cfg.McpServers, 2, "without servers, every server is kept")
+				assert.Equal(t, "proxy", cfg.McpProxy.Name)
+			},
+		},
+		"servers inherit the profile's options": {
+			profile: "quiet",
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, false, cfg.McpServers["notes"].Options.LazyLoad.OrElse(true))
+			},
+		},
+		"unknown profile":           {profile: "home", err: `unknown profile "home"; the config has: keepsUnknown, overridesDropped, quiet, work`},
+		"unknown server kept":       {profile: "keepsUnknown", err: "profile keepsUnknown keeps unknown server gitlab"},
+		"dropped server overridden": {profile: "overridesDropped", err: "profile overridesDropped overrides server notes, which it does not keep"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := Load(path, false, true, "", 10, test.profile)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.profile, cfg.Profile())
+			test.check(t, cfg)
+		})
+	}
+
+	noProfiles := writeConfig(t, "config.json", `{"mcpProxy": {"name": "proxy"}, "mcpServers": {}}`)
+	_, err := Load(noProfiles, false, true, "", 10, "work")
+	assert.EqualError(t, err, `unknown profile "work"; the config has: no profiles`)
+}
+
+// TestReloadKeepsProfile verifies that reloading a config applies the
+// profile it was loaded with to the edited file.
+func TestReloadKeepsProfile(t *testing.T) {
+	path := writeConfig(t, "config.yaml", profilesConfig)
+	cfg, err := Load(path, false, true, "", 10, "work")
+	require.NoError(t, err)
+
+	edited := strings.Replace(profilesConfig, "jira.example.com", "issues.example.com", 1)
+	require.NoError(t, os.WriteFile(path, []byte(edited), 0o600))
+	reloaded, err := cfg.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"github", "jira"}, keys(reloaded.McpServers))
+	assert.Equal(t, []string{"stdio", "--read-only"}, reloaded.McpServers["github"].Args)
+	assert.Equal(t, "https://issues.example.com/mcp", reloaded.McpServers["jira"].URL)
+	assert.Equal(t, "work", reloaded.Profile())
+}
+
+// keys returns the sorted keys of m
+func keys[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
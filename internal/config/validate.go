@@ -0,0 +1,1374 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	nethttp "net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/egress"
+	"github.com/voicetreelab/lazy-mcp/internal/pipeline"
+	"github.com/voicetreelab/lazy-mcp/internal/secrets"
+	"github.com/voicetreelab/lazy-mcp/internal/toolname"
+	"github.com/voicetreelab/lazy-mcp/internal/transform"
+)
+
+// Severity classifies a validation finding
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is one problem found by Validate
+type Diagnostic struct {
+	Severity Severity
+	Server   string // Empty for problems not tied to one server
+	Message  string
+	Hint     string // How to fix it, if known
+}
+
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	b.WriteString(string(d.Severity))
+	b.WriteString(": ")
+	if d.Server != "" {
+		fmt.Fprintf(&b, "server %q: ", d.Server)
+	}
+	b.WriteString(d.Message)
+	if d.Hint != "" {
+		b.WriteString("\n  hint: ")
+		b.WriteString(d.Hint)
+	}
+	return b.String()
+}
+
+// ValidateOptions controls the checks run by Validate
+type ValidateOptions struct {
+	// Offline skips checks that need the network, i.e. URL reachability
+	Offline bool
+	// Timeout bounds each reachability check; defaults to 5s
+	Timeout time.Duration
+}
+
+// Validate checks a loaded config for problems that would only surface once
+// a server is started: duplicate server names, commands missing from PATH,
+// unreachable or malformed URLs, malformed env entries and invalid tool
+// filters. Problems not tied to one server come first, then each server's in
+// name order.
+func Validate(ctx context.Context, cfg *Config, opts ValidateOptions) []Diagnostic {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+
+	var diags []Diagnostic
+	diags = append(diags, validateProxy(cfg.McpProxy)...)
+	diags = append(diags, validateDuplicateNames(cfg.Sources())...)
+	diags = append(diags, validateGroups(cfg.McpServers)...)
+	diags = append(diags, validateVirtualServers(cfg.McpProxy, cfg.McpServers)...)
+	diags = append(diags, validateServerPriority(cfg.McpProxy, cfg.McpServers)...)
+	diags = append(diags, validateExposeTags(cfg.McpProxy, cfg.McpServers)...)
+
+	names := make([]string, 0, len(cfg.McpServers))
+	for name := range cfg.McpServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Reachability checks run in parallel; they dominate the run time
+	results := make([][]Diagnostic, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i] = validateServer(ctx, name, cfg.McpServers[name], opts)
+			results[i] = append(results[i], validateLogLevel(name, cfg.McpServers[name], cfg.McpProxy)...)
+			results[i] = append(results[i], validateOutputValidation(name, cfg.McpServers[name], cfg.McpProxy)...)
+			results[i] = append(results[i], validateOversizedResults(name, cfg.McpServers[name], cfg.McpProxy)...)
+			results[i] = append(results[i], validateLogMessageLevel(name, cfg.McpServers[name], cfg.McpProxy)...)
+		}(i, name)
+	}
+	wg.Wait()
+	for _, result := range results {
+		diags = append(diags, result...)
+	}
+	return diags
+}
+
+const logLevelHint = "use debug, info, warn or error"
+
+const outputValidationHint = "use warn, reject or off"
+
+// validOutputValidation reports whether mode is a known outputValidation mode
+func validOutputValidation(mode string) bool {
+	switch mode {
+	case OutputValidationWarn, OutputValidationReject, OutputValidationOff:
+		return true
+	}
+	return false
+}
+
+const oversizedResultsHint = "use truncate or spill"
+
+// validOversizedResults reports whether mode is a known oversizedResults mode
+func validOversizedResults(mode string) bool {
+	return mode == OversizedResultsTruncate || mode == OversizedResultsSpill
+}
+
+const logMessageLevelHint = "use debug, info, notice, warning, error, critical, alert or emergency"
+
+// validLogMessageLevel reports whether level is one of the levels of MCP log
+// messages
+func validLogMessageLevel(level string) bool {
+	switch level {
+	case "debug", "info", "notice", "warning", "error", "critical", "alert", "emergency":
+		return true
+	}
+	return false
+}
+
+// validateToolNaming checks the naming of the tools listed directly: the
+// casing and client profile must be known, and the profile must allow the
+// separator
+func validateToolNaming(options *OptionsV2) []Diagnostic {
+	var diags []Diagnostic
+	if c := options.ToolNameCase.OrElse(toolname.CasePreserve); !toolname.ValidCase(c) {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("unknown mcpProxy.options.toolNameCase %q", c),
+			Hint:     "use preserve, snake, kebab or camel",
+		})
+	}
+	separator := options.ToolNameSeparator.OrElse(toolname.DefaultSeparator)
+	if separator == "" {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Message:  "mcpProxy.options.toolNameSeparator is empty",
+			Hint:     "tool paths need a separator to stay apart, e.g. _, __, - or .",
+		})
+	}
+	profile, ok := toolname.LookupProfile(options.ClientProfile.OrElse(""))
+	switch {
+	case !ok:
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("unknown mcpProxy.options.clientProfile %q", options.ClientProfile.OrElse("")),
+			Hint:     "use mcp, openai, anthropic or cursor",
+		})
+	case separator != "" && !profile.AllowsSeparator(separator):
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("mcpProxy.options.toolNameSeparator %q is not allowed in tool names by %s clients", separator, profile.Name),
+			Hint:     "use _, __ or -",
+		})
+	}
+	return diags
+}
+
+// validateProxy checks the mcpProxy section
+func validateProxy(proxy *MCPProxyConfigV2) []Diagnostic {
+	var diags []Diagnostic
+	switch proxy.Type {
+	case MCPServerTypeStdio, MCPServerTypeSSE, MCPServerTypeStreamable:
+	default:
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("unknown mcpProxy.type %q", proxy.Type),
+			Hint:     "use stdio, sse or streamable-http",
+		})
+	}
+	if proxy.Options != nil {
+		if _, err := ParseLogLevel(proxy.Options.LogLevel.OrElse("")); err != nil {
+			diags = append(diags, Diagnostic{Severity: SeverityError, Message: fmt.Sprintf("mcpProxy.options.logLevel: %v", err), Hint: logLevelHint})
+		}
+		if mode := proxy.Options.OutputValidation.OrElse(OutputValidationWarn); !validOutputValidation(mode) {
+			diags = append(diags, Diagnostic{Severity: SeverityError, Message: fmt.Sprintf("unknown mcpProxy.options.outputValidation %q", mode), Hint: outputValidationHint})
+		}
+		switch proxy.Options.DuplicateTools.OrElse(DuplicateToolsPrefix) {
+		case DuplicateToolsPrefix, DuplicateToolsPriority, DuplicateToolsError:
+		default:
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("unknown mcpProxy.options.duplicateTools %q", proxy.Options.DuplicateTools.OrElse("")),
+				Hint:     "use prefix, priority or error",
+			})
+		}
+		diags = append(diags, validateToolNaming(proxy.Options)...)
+		if level := proxy.Options.LogMessageLevel.OrElse("debug"); !validLogMessageLevel(level) {
+			diags = append(diags, Diagnostic{Severity: SeverityError, Message: fmt.Sprintf("unknown mcpProxy.options.logMessageLevel %q", level), Hint: logMessageLevelHint})
+		}
+		switch exposure := proxy.Options.Exposure.OrElse(ExposureHybrid); exposure {
+		case ExposureHybrid:
+		case ExposureHierarchical, ExposureFlat:
+			if len(proxy.Options.Pin) > 0 {
+				diags = append(diags, Diagnostic{
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("mcpProxy.options.pin has no effect with %s exposure", exposure),
+					Hint:     "remove the pins, or use hybrid exposure",
+				})
+			}
+		default:
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("unknown mcpProxy.options.exposure %q", exposure),
+				Hint:     "use hybrid, hierarchical or flat",
+			})
+		}
+		diags = append(diags, validateToolPatterns("", "mcpProxy.options.pin", proxy.Options.Pin)...)
+		switch strings.ToLower(proxy.Options.LogFormat.OrElse("text")) {
+		case "text", "json":
+		default:
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("unknown mcpProxy.options.logFormat %q", proxy.Options.LogFormat.OrElse("")),
+				Hint:     "use text or json",
+			})
+		}
+		if concurrency := proxy.Options.PrefetchConcurrency.OrElse(DefaultPrefetchConcurrency); concurrency <= 0 {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("mcpProxy.options.prefetchConcurrency must be positive, got %d", concurrency),
+				Hint:     "set how many servers prefetching schemas starts at a time",
+			})
+		}
+		if limit := proxy.Options.MaxTotalConcurrent.OrElse(0); limit < 0 {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("mcpProxy.options.maxTotalConcurrent must not be negative, got %d", limit),
+				Hint:     "use 0 to leave tool calls across servers unbounded",
+			})
+		}
+		if limit := proxy.Options.MaxResultBytes.OrElse(0); limit < 0 {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("mcpProxy.options.maxResultBytes must not be negative, got %d", limit),
+				Hint:     "use 0 to leave tool results whole",
+			})
+		}
+		if limit := proxy.Options.MaxMessageBytes.OrElse(0); limit < 0 {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("mcpProxy.options.maxMessageBytes must not be negative, got %d", limit),
+				Hint:     "use 0 to accept messages of any size",
+			})
+		}
+		if dimension := proxy.Options.MaxImageDimension.OrElse(0); dimension < 0 {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("mcpProxy.options.maxImageDimension must not be negative, got %d", dimension),
+				Hint:     "use 0 to leave images as they are",
+			})
+		}
+		if limit := proxy.Options.MaxInlineBinaryBytes.OrElse(0); limit < 0 {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("mcpProxy.options.maxInlineBinaryBytes must not be negative, got %d", limit),
+				Hint:     "use 0 to always inline binary content",
+			})
+		}
+		if mode := proxy.Options.OversizedResults.OrElse(OversizedResultsTruncate); !validOversizedResults(mode) {
+			diags = append(diags, Diagnostic{Severity: SeverityError, Message: fmt.Sprintf("unknown mcpProxy.options.oversizedResults %q", mode), Hint: oversizedResultsHint})
+		}
+		if size := proxy.Options.NotificationQueueSize.OrElse(DefaultNotificationQueueSize); size <= 0 {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("mcpProxy.options.notificationQueueSize must be positive, got %d", size),
+				Hint:     "set how many notifications are queued for a slow client",
+			})
+		}
+		switch mode := proxy.Options.NotificationOverflow.OrElse(NotificationOverflowDrop); mode {
+		case NotificationOverflowDrop, NotificationOverflowPause:
+		default:
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("unknown mcpProxy.options.notificationOverflow %q", mode),
+				Hint:     "use drop or pause",
+			})
+		}
+	}
+	if proxy.Audit != nil && proxy.Audit.Dir == "" {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Message:  "mcpProxy.audit.dir is required",
+			Hint:     "set the directory the audit log is written to, or remove the audit section",
+		})
+	}
+	if proxy.Search != nil {
+		switch proxy.Search.Mode {
+		case "", SearchModeFuzzy, SearchModeSemantic:
+		default:
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("unknown mcpProxy.search.mode %q", proxy.Search.Mode),
+				Hint:     "use fuzzy or semantic",
+			})
+		}
+		if embeddings := proxy.Search.Embeddings; embeddings != nil && embeddings.URL != "" && embeddings.Model == "" {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  "mcpProxy.search.embeddings.model is required with a url",
+				Hint:     "set the embedding model to request, e.g. text-embedding-3-small",
+			})
+		}
+	}
+	if approval := proxy.Approval; approval != nil {
+		switch approval.Via {
+		case "", ApprovalViaElicitation, ApprovalViaTerminal, ApprovalViaWebhook:
+		default:
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("unknown mcpProxy.approval.via %q", approval.Via),
+				Hint:     "use elicitation, terminal or webhook",
+			})
+		}
+		if approval.Via == ApprovalViaWebhook && (approval.Webhook == nil || approval.Webhook.URL == "") {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  "mcpProxy.approval.webhook.url is required to approve via webhook",
+				Hint:     "set the URL that is asked to approve calls",
+			})
+		}
+		if approval.Via == ApprovalViaTerminal && proxy.Type != MCPServerTypeStdio {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityWarning,
+				Message:  "mcpProxy.approval.via terminal prompts on the terminal lazy-mcp runs in, which HTTP clients may not see",
+				Hint:     "use elicitation or webhook for HTTP clients",
+			})
+		}
+		diags = append(diags, validateToolPatterns("", "mcpProxy.approval.tools", approval.Tools)...)
+	}
+	if proxy.Auth != nil {
+		keys, err := proxy.Auth.LoadKeys()
+		switch {
+		case err != nil:
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("mcpProxy.auth.keysFile: %v", err),
+				Hint:     "point keysFile at a file with one key per line",
+			})
+		case len(keys) == 0 && (proxy.Options == nil || len(proxy.Options.AuthTokens) == 0):
+			diags = append(diags, Diagnostic{
+				Severity: SeverityWarning,
+				Message:  "mcpProxy.auth has no keys, so every HTTP request is rejected",
+				Hint:     "add keys, or remove the auth section",
+			})
+		}
+	}
+	if proxy.Redaction != nil {
+		for _, pattern := range proxy.Redaction.Patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				diags = append(diags, Diagnostic{
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("mcpProxy.redaction.patterns entry %q is not a valid regular expression: %v", pattern, err),
+					Hint:     "use Go regular expression syntax, e.g. \\bsk-[A-Za-z0-9]+",
+				})
+			}
+		}
+	}
+	if cassette := proxy.Cassette; cassette != nil {
+		switch cassette.Mode {
+		case CassetteModeRecord, CassetteModeReplay:
+		default:
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("unknown mcpProxy.cassette.mode %q", cassette.Mode),
+				Hint:     "use record or replay",
+			})
+		}
+		if cassette.File == "" {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  "mcpProxy.cassette.file is required",
+				Hint:     "set the file calls are recorded to or replayed from, or remove the cassette section",
+			})
+		}
+	}
+	if logs := proxy.ServerLogs; logs != nil && logs.MaxSizeMB.OrElse(DefaultServerLogMaxSizeMB) <= 0 {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("mcpProxy.serverLogs.maxSizeMB must be positive, got %d", logs.MaxSizeMB.OrElse(0)),
+			Hint:     "set the size in MB at which a server's log file is rotated, or set disabled to stop writing them",
+		})
+	}
+	fanOutNames := make([]string, 0, len(proxy.FanOut))
+	for name := range proxy.FanOut {
+		fanOutNames = append(fanOutNames, name)
+	}
+	sort.Strings(fanOutNames)
+	for _, name := range fanOutNames {
+		if len(proxy.FanOut[name].Tools) == 0 {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("mcpProxy.fanOut.%s has no tools", name),
+				Hint:     "list the paths of the tools it calls, e.g. github.search",
+			})
+		}
+		if strings.TrimSpace(name) == "" {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  "mcpProxy.fanOut has a tool without a name",
+				Hint:     "name the tool, e.g. search_everywhere",
+			})
+		}
+	}
+	pipelineNames := make([]string, 0, len(proxy.Pipelines))
+	for name := range proxy.Pipelines {
+		pipelineNames = append(pipelineNames, name)
+	}
+	sort.Strings(pipelineNames)
+	for _, name := range pipelineNames {
+		if _, ok := proxy.FanOut[name]; ok {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("mcpProxy.pipelines.%s is also defined in mcpProxy.fanOut", name),
+				Hint:     "give the tools different names",
+			})
+		}
+		diags = append(diags, validatePipeline(name, proxy.Pipelines[name])...)
+	}
+	if proxy.HierarchyPath != "" {
+		if _, err := os.Stat(filepath.Join(proxy.HierarchyPath, "root.json")); err != nil {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("hierarchy at %s has no root.json", proxy.HierarchyPath),
+				Hint:     "point mcpProxy.hierarchyPath at a directory generated by structure_generator",
+			})
+		}
+	}
+	return diags
+}
+
+// validatePipeline checks that a pipeline has steps, each calling a tool,
+// and that their arguments only refer to the pipeline's input and to steps
+// before them
+func validatePipeline(name string, cfg PipelineConfig) []Diagnostic {
+	if len(cfg.Steps) == 0 {
+		return []Diagnostic{{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("mcpProxy.pipelines.%s has no steps", name),
+			Hint:     "list the tool calls it makes in turn",
+		}}
+	}
+	var diags []Diagnostic
+	earlier := map[string]bool{pipeline.Input: true}
+	for i, step := range cfg.Steps {
+		stepName := cfg.StepName(i)
+		where := fmt.Sprintf("mcpProxy.pipelines.%s step %s", name, stepName)
+		if step.Tool == "" {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  where + " has no tool",
+				Hint:     "set the path of the tool it calls, e.g. github.search",
+			})
+		}
+		paths, err := pipeline.References(map[string]any(step.Arguments))
+		if err != nil {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("%s: %v", where, err),
+				Hint:     "refer to values as {{input.name}} or {{step.field[0].name}}",
+			})
+		}
+		for _, path := range paths {
+			if !earlier[path.Root] {
+				diags = append(diags, Diagnostic{
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("%s refers to %s, which is not input or an earlier step", where, path),
+					Hint:     "steps can only use the output of the steps before them",
+				})
+			}
+		}
+		if earlier[stepName] {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("mcpProxy.pipelines.%s has more than one step named %s", name, stepName),
+				Hint:     "name each step differently, and none input",
+			})
+		}
+		earlier[stepName] = true
+	}
+	return diags
+}
+
+// validateDuplicateNames reports servers defined twice within one JSON file,
+// which JSON decoding would otherwise resolve silently in favour of the last.
+// YAML files already fail to load on duplicate keys.
+func validateDuplicateNames(sources []string) []Diagnostic {
+	var diags []Diagnostic
+	for _, source := range sources {
+		files, _ := filepath.Glob(source)
+		for _, file := range files {
+			if isYAMLPath(file) {
+				continue
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			for _, name := range duplicateServerNames(data) {
+				diags = append(diags, Diagnostic{
+					Severity: SeverityError,
+					Server:   name,
+					Message:  fmt.Sprintf("defined more than once in %s; only the last definition is used", file),
+					Hint:     "rename or remove one of the entries",
+				})
+			}
+		}
+	}
+	return diags
+}
+
+// validateGroups checks that server groups are valid hierarchy paths and
+// that no server shares its path with a group, which would merge the two
+func validateGroups(servers map[string]*MCPClientConfigV2) []Diagnostic {
+	var diags []Diagnostic
+	groups := make(map[string]bool)
+	for name, conf := range servers {
+		if conf == nil || conf.Group == "" {
+			continue
+		}
+		levels := strings.Split(strings.Trim(conf.Group, "/"), "/")
+		valid := true
+		for _, level := range levels {
+			if level == "" || strings.Contains(level, ".") {
+				valid = false
+			}
+		}
+		if !valid {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Server:   name,
+				Message:  fmt.Sprintf("group %q is not a valid hierarchy path", conf.Group),
+				Hint:     `separate levels with "/", e.g. "work/devops"; levels cannot be empty or contain dots`,
+			})
+			continue
+		}
+		for i := range levels {
+			groups[strings.Join(levels[:i+1], "/")] = true
+		}
+	}
+	for name, conf := range servers {
+		path := name
+		if conf != nil && conf.Group != "" {
+			path = strings.Trim(conf.Group, "/") + "/" + name
+		}
+		if groups[path] {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Server:   name,
+				Message:  fmt.Sprintf("server has the same hierarchy path as group %q", path),
+				Hint:     "rename the server or the group",
+			})
+		}
+	}
+	sort.Slice(diags, func(i, j int) bool {
+		if diags[i].Server != diags[j].Server {
+			return diags[i].Server < diags[j].Server
+		}
+		return diags[i].Message < diags[j].Message
+	})
+	return diags
+}
+
+// validateVirtualServers checks that virtual servers have valid names that
+// no server or group has at the root of the hierarchy, and tools with names
+// of their own
+func validateVirtualServers(proxy *MCPProxyConfigV2, servers map[string]*MCPClientConfigV2) []Diagnostic {
+	if proxy == nil {
+		return nil
+	}
+	taken := make(map[string]string)
+	for name, conf := range servers {
+		if conf != nil && conf.Group != "" {
+			taken[strings.Split(strings.Trim(conf.Group, "/"), "/")[0]] = "group"
+		} else {
+			taken[name] = "server"
+		}
+	}
+	names := make([]string, 0, len(proxy.VirtualServers))
+	for name := range proxy.VirtualServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var diags []Diagnostic
+	for _, name := range names {
+		virtual := proxy.VirtualServers[name]
+		if name == "" || strings.ContainsAny(name, "./") {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("mcpProxy.virtualServers name %q is not a valid category name", name),
+				Hint:     `names cannot be empty or contain "." or "/", e.g. project-x`,
+			})
+			continue
+		}
+		if kind, ok := taken[name]; ok {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("mcpProxy.virtualServers.%s has the same hierarchy path as a %s", name, kind),
+				Hint:     "rename the virtual server",
+			})
+		}
+		if len(virtual.Tools) == 0 {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("mcpProxy.virtualServers.%s has no tools", name),
+				Hint:     "list the paths of the tools it lists, e.g. github.create_issue",
+			})
+		}
+		listed := make(map[string]string)
+		for _, toolPath := range virtual.Tools {
+			toolName := toolPath[strings.LastIndex(toolPath, ".")+1:]
+			if other, ok := listed[toolName]; ok {
+				diags = append(diags, Diagnostic{
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("mcpProxy.virtualServers.%s lists %s and %s under the same name %q", name, other, toolPath, toolName),
+					Hint:     "list only one of them, or rename one with its server's toolNames",
+				})
+				continue
+			}
+			listed[toolName] = toolPath
+		}
+	}
+	return diags
+}
+
+// validateServerPriority checks that serverPriority names configured servers
+func validateServerPriority(proxy *MCPProxyConfigV2, servers map[string]*MCPClientConfigV2) []Diagnostic {
+	if proxy.Options == nil {
+		return nil
+	}
+	var diags []Diagnostic
+	for _, name := range proxy.Options.ServerPriority {
+		if _, exists := servers[name]; !exists {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("mcpProxy.options.serverPriority names unknown server %q", name),
+				Hint:     "remove it, or add the server to mcpServers",
+			})
+		}
+	}
+	return diags
+}
+
+// validateExposeTags checks that exposeTags names tags some server or tool
+// has, as a misspelled one hides every tool it would have exposed
+func validateExposeTags(proxy *MCPProxyConfigV2, servers map[string]*MCPClientConfigV2) []Diagnostic {
+	if proxy.Options == nil {
+		return nil
+	}
+	known := make(map[string]bool)
+	for _, conf := range servers {
+		if conf == nil {
+			continue
+		}
+		for _, tag := range conf.Tags {
+			known[tag] = true
+		}
+		if conf.Options != nil {
+			for _, tags := range conf.Options.ToolTags {
+				for _, tag := range tags {
+					known[tag] = true
+				}
+			}
+		}
+	}
+	var diags []Diagnostic
+	for _, tag := range proxy.Options.ExposeTags {
+		if !known[tag] {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("mcpProxy.options.exposeTags names tag %q, which no server or tool has", tag),
+				Hint:     "tag servers with tags, or their tools with toolTags",
+			})
+		}
+	}
+	return diags
+}
+
+// duplicateServerNames returns the keys that appear more than once in the
+// top-level mcpServers object of a JSON document, sorted.
+func duplicateServerNames(data []byte) []string {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+		if key != "mcpServers" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return nil
+			}
+			continue
+		}
+
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+			return nil
+		}
+		seen := make(map[string]int)
+		for dec.More() {
+			name, err := dec.Token()
+			if err != nil {
+				return nil
+			}
+			if s, ok := name.(string); ok {
+				seen[s]++
+			}
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return nil
+			}
+		}
+		var duplicates []string
+		for name, count := range seen {
+			if count > 1 {
+				duplicates = append(duplicates, name)
+			}
+		}
+		sort.Strings(duplicates)
+		return duplicates
+	}
+	return nil
+}
+
+// validateServer checks one mcpServers entry
+func validateServer(ctx context.Context, name string, conf *MCPClientConfigV2, opts ValidateOptions) []Diagnostic {
+	var diags []Diagnostic
+	report := func(severity Severity, hint, format string, args ...any) {
+		diags = append(diags, Diagnostic{Severity: severity, Server: name, Message: fmt.Sprintf(format, args...), Hint: hint})
+	}
+
+	parsed, err := ParseMCPClientConfigV2(conf)
+	if err != nil {
+		report(SeverityError, "set command for a stdio server, image for a docker one, or type and url for a remote one", "%v", err)
+		return diags
+	}
+
+	switch v := parsed.(type) {
+	case *StdioMCPClientConfig:
+		if _, err := exec.LookPath(v.Command); err != nil {
+			hint := "install it, add its directory to PATH, or use an absolute path"
+			if conf.Runner != "" {
+				hint = "install Node.js for npx, or uv for uvx"
+			}
+			report(SeverityError, hint, "command %q not found: %v", v.Command, err)
+		}
+		diags = append(diags, validateEnv(name, v.Env)...)
+		diags = append(diags, validateProcess(name, conf)...)
+	case *DockerMCPClientConfig:
+		if _, err := exec.LookPath("docker"); err != nil {
+			report(SeverityError, "install Docker, or add its directory to PATH", "docker not found: %v", err)
+		}
+		diags = append(diags, validateEnv(name, v.Env)...)
+		diags = append(diags, validateProcess(name, conf)...)
+		switch v.Pull {
+		case "", DockerPullMissing, DockerPullAlways, DockerPullNever:
+		default:
+			report(SeverityError, "use missing, always or never", "unknown pull %q", v.Pull)
+		}
+		for _, volume := range v.Volumes {
+			if parts := strings.Split(volume, ":"); len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+				report(SeverityError, "use host:container, or host:container:ro for read-only", "invalid volume %q", volume)
+			}
+		}
+	case *SSEMCPClientConfig:
+		diags = append(diags, validateURL(ctx, name, v.URL, v.Headers, v.TLS, v.Proxy, opts)...)
+	case *StreamableMCPClientConfig:
+		diags = append(diags, validateURL(ctx, name, v.URL, v.Headers, v.TLS, v.Proxy, opts)...)
+	}
+	if transport := conf.Transport(); transport != MCPClientTypeStdio && transport != MCPClientTypeDocker {
+		if conf.InheritEnv.Present() || len(conf.EnvAllowlist) > 0 || conf.EnvFile != "" || conf.Cwd != "" {
+			report(SeverityWarning, "remove them, or give the server a command", "inheritEnv, envAllowlist, envFile and cwd only apply to servers lazy-mcp spawns")
+		}
+	}
+	if transport := conf.Transport(); transport == MCPClientTypeStdio || transport == MCPClientTypeDocker {
+		if conf.OAuth != nil {
+			report(SeverityWarning, "remove oauth, or give the server a url", "oauth only applies to remote servers")
+		}
+		if conf.TLS != nil {
+			report(SeverityWarning, "remove tls, or give the server a url", "tls only applies to remote servers")
+		}
+		if conf.Proxy != "" {
+			report(SeverityWarning, "remove proxy, or give the server a url", "proxy only applies to remote servers")
+		}
+		if conf.Options != nil && conf.Options.MaxConcurrent.OrElse(1) > 1 {
+			report(SeverityWarning, "remove it; stdio servers take one call at a time", "maxConcurrent only applies to remote servers")
+		}
+	}
+	if conf.OAuth != nil {
+		diags = append(diags, validateOAuth(name, conf.OAuth)...)
+	}
+	diags = append(diags, validateSecrets(name, conf)...)
+	if conf.Sandbox != nil {
+		if conf.Transport() == MCPClientTypeStdio {
+			diags = append(diags, validateSandbox(name, conf.Sandbox)...)
+		} else {
+			report(SeverityWarning, "remove it; docker servers are confined to their container", "sandbox only applies to stdio servers")
+		}
+	}
+	if conf.Egress != nil {
+		diags = append(diags, validateEgress(name, conf)...)
+	}
+	if conf.Options != nil && conf.Options.Limits != nil {
+		diags = append(diags, validateLimits(name, conf.Transport(), conf.Options.Limits)...)
+	}
+
+	if conf.Options != nil && conf.Options.ToolFilter != nil {
+		diags = append(diags, validateToolFilter(name, conf.Options.ToolFilter)...)
+	}
+	if conf.Options != nil {
+		diags = append(diags, validateToolPatterns(name, "includeTools", conf.Options.IncludeTools)...)
+		diags = append(diags, validateToolPatterns(name, "excludeTools", conf.Options.ExcludeTools)...)
+		diags = append(diags, validateToolNames(name, conf.Options)...)
+		diags = append(diags, validateToolArguments(name, conf.Options)...)
+		diags = append(diags, validateResultTransforms(name, conf.Options)...)
+	}
+	return diags
+}
+
+// validateSecrets checks the syntax and schemes of the secret references in
+// a server's env, headers and args, without resolving them
+func validateSecrets(server string, conf *MCPClientConfigV2) []Diagnostic {
+	var diags []Diagnostic
+	check := func(what, value string) {
+		if err := secrets.Check(value); err != nil {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Server:   server,
+				Message:  fmt.Sprintf("%s: %v", what, err),
+				Hint:     `use e.g. "!secret keychain:github-token"`,
+			})
+		}
+	}
+	for _, key := range sortedKeys(conf.Env) {
+		check("env "+key, conf.Env[key])
+	}
+	for _, key := range sortedKeys(conf.Headers) {
+		check("header "+key, conf.Headers[key])
+	}
+	for i, arg := range conf.Args {
+		check(fmt.Sprintf("args[%d]", i), arg)
+	}
+	return diags
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateProcess checks how a spawned server's environment and working
+// directory are set up
+func validateProcess(server string, conf *MCPClientConfigV2) []Diagnostic {
+	var diags []Diagnostic
+	report := func(severity Severity, hint, format string, args ...any) {
+		diags = append(diags, Diagnostic{Severity: severity, Server: server, Message: fmt.Sprintf(format, args...), Hint: hint})
+	}
+	for _, pattern := range conf.EnvAllowlist {
+		if _, err := path.Match(pattern, ""); err != nil {
+			report(SeverityError, "use * and ? wildcards, e.g. AWS_*", "invalid envAllowlist pattern %q: %v", pattern, err)
+		}
+	}
+	if len(conf.EnvAllowlist) > 0 && conf.InheritEnv.OrElse(true) {
+		report(SeverityWarning, "set inheritEnv to false to pass on only the allowlisted variables", "envAllowlist has no effect while the server inherits lazy-mcp's whole environment")
+	}
+	if conf.EnvFile != "" {
+		if _, err := os.Stat(conf.EnvFile); err != nil {
+			report(SeverityError, "create it, or fix the path, which is relative to lazy-mcp's working directory", "envFile: %v", err)
+		}
+	}
+	if conf.Cwd != "" {
+		if info, err := os.Stat(conf.Cwd); err != nil {
+			report(SeverityError, "create it, or fix the path", "cwd: %v", err)
+		} else if !info.IsDir() {
+			report(SeverityError, "use a directory", "cwd %s is not a directory", conf.Cwd)
+		}
+	}
+	return diags
+}
+
+// validateSandbox checks that a server's sandbox can run on this platform,
+// and the paths it grants access to
+func validateSandbox(server string, sandbox *SandboxConfig) []Diagnostic {
+	var diags []Diagnostic
+	report := func(severity Severity, hint, format string, args ...any) {
+		diags = append(diags, Diagnostic{Severity: severity, Server: server, Message: fmt.Sprintf(format, args...), Hint: hint})
+	}
+	switch sandbox.Network {
+	case "", SandboxNetworkNone, SandboxNetworkHost:
+	default:
+		report(SeverityError, "use none or host", "unknown sandbox.network %q", sandbox.Network)
+	}
+	tool, err := sandbox.SandboxTool()
+	if err != nil {
+		report(SeverityError, "install bubblewrap or firejail on Linux, or remove sandbox", "sandbox: %v", err)
+		return diags
+	}
+	platform := map[string]string{SandboxBubblewrap: "linux", SandboxFirejail: "linux", SandboxSandboxExec: "darwin"}[tool]
+	switch {
+	case platform == "":
+		report(SeverityError, "use bwrap, firejail or sandbox-exec", "unknown sandbox.tool %q", tool)
+		return diags
+	case platform != runtime.GOOS:
+		report(SeverityError, "use bwrap or firejail on Linux, and sandbox-exec on macOS", "sandbox.tool %s does not run on %s", tool, runtime.GOOS)
+		return diags
+	}
+	if _, err := exec.LookPath(tool); err != nil {
+		report(SeverityError, "install it, or add its directory to PATH", "sandbox.tool %s not found: %v", tool, err)
+	}
+	for _, paths := range [][]string{sandbox.ReadPaths, sandbox.WritePaths} {
+		for _, path := range paths {
+			if strings.HasPrefix(path, "~/") {
+				if home, err := os.UserHomeDir(); err == nil {
+					path = filepath.Join(home, path[2:])
+				}
+			}
+			if _, err := os.Stat(path); err != nil {
+				report(SeverityWarning, "create it, or remove it from the sandbox", "sandbox path: %v", err)
+			}
+		}
+	}
+	return diags
+}
+
+// validateEgress checks a server's egress allowlist, and that the server
+// can reach the egress proxy
+func validateEgress(server string, conf *MCPClientConfigV2) []Diagnostic {
+	var diags []Diagnostic
+	report := func(severity Severity, hint, format string, args ...any) {
+		diags = append(diags, Diagnostic{Severity: severity, Server: server, Message: fmt.Sprintf(format, args...), Hint: hint})
+	}
+	for _, entry := range conf.Egress.Allow {
+		if err := egress.Check(entry); err != nil {
+			report(SeverityError, "use domains such as api.github.com or *.github.com, IP addresses or CIDRs, optionally with a :port", "%v", err)
+		}
+	}
+	if len(conf.Egress.Allow) == 0 {
+		report(SeverityWarning, "list the hosts the server may reach under egress.allow", "egress allows no host at all")
+	}
+	switch conf.Transport() {
+	case MCPClientTypeStdio:
+	case MCPClientTypeDocker:
+		if conf.Network == "none" {
+			report(SeverityError, "remove network, or use a network with a route to the host", "a container without network cannot reach the egress proxy")
+		}
+	default:
+		report(SeverityWarning, "remove it, or give the server a command or image", "egress only applies to servers lazy-mcp spawns")
+	}
+	return diags
+}
+
+// validateLimits checks a server's resource limits, and whether they can be
+// enforced on this platform
+func validateLimits(server string, transport MCPClientType, limits *ResourceLimitsConfig) []Diagnostic {
+	var diags []Diagnostic
+	report := func(severity Severity, hint, format string, args ...any) {
+		diags = append(diags, Diagnostic{Severity: severity, Server: server, Message: fmt.Sprintf(format, args...), Hint: hint})
+	}
+	if limits.MemoryMB < 0 || limits.CPUs < 0 || limits.OpenFiles < 0 || limits.Processes < 0 {
+		report(SeverityError, "use a positive limit, or 0 to leave a resource unbounded", "limits cannot be negative")
+	}
+	if transport != MCPClientTypeStdio {
+		// Docker enforces them all, and remote servers are not limited
+		return diags
+	}
+	switch runtime.GOOS {
+	case "linux", "windows":
+	default:
+		if limits.CPUs > 0 || limits.Processes > 0 {
+			report(SeverityWarning, "run the server with docker to enforce them", "limits.cpus and limits.processes are not enforced on %s", runtime.GOOS)
+		}
+	}
+	if runtime.GOOS == "windows" && limits.OpenFiles > 0 {
+		report(SeverityWarning, "run the server with docker to enforce it", "limits.openFiles is not enforced on windows")
+	}
+	return diags
+}
+
+// validateOAuth checks a server's oauth section
+func validateOAuth(server string, oauth *OAuthConfig) []Diagnostic {
+	var diags []Diagnostic
+	switch oauth.Store {
+	case "", OAuthStoreFile, OAuthStoreKeychain:
+	default:
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Server:   server,
+			Message:  fmt.Sprintf("unknown oauth.store %q", oauth.Store),
+			Hint:     "use file or keychain",
+		})
+	}
+	redirect, err := url.Parse(oauth.Redirect())
+	if err != nil || redirect.Scheme != "http" || (redirect.Hostname() != "localhost" && redirect.Hostname() != "127.0.0.1") || redirect.Port() == "" {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Server:   server,
+			Message:  fmt.Sprintf("oauth.redirectUri %q is not a localhost URL with a port", oauth.Redirect()),
+			Hint:     "lazy-mcp receives the authorization itself, e.g. " + DefaultOAuthRedirectURI,
+		})
+	}
+	return diags
+}
+
+// validateToolNames checks that toolNames and toolAliases do not expose two
+// tools under the same name, which would route calls to only one of them
+func validateToolNames(server string, options *OptionsV2) []Diagnostic {
+	owners := make(map[string][]string)
+	for original, exposed := range options.ToolNames {
+		owners[exposed] = append(owners[exposed], original)
+	}
+	for original, aliases := range options.ToolAliases {
+		for _, alias := range aliases {
+			owners[alias] = append(owners[alias], original)
+		}
+	}
+
+	var diags []Diagnostic
+	for exposed, originals := range owners {
+		if len(originals) < 2 {
+			continue
+		}
+		sort.Strings(originals)
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Server:   server,
+			Message:  fmt.Sprintf("tools %s are all exposed as %q", strings.Join(originals, ", "), exposed),
+			Hint:     "give each tool its own name in toolNames and toolAliases",
+		})
+	}
+	sort.Slice(diags, func(i, j int) bool { return diags[i].Message < diags[j].Message })
+	return diags
+}
+
+// validateToolArguments checks that toolArguments do not set an argument both
+// as a default and forced, as the default would never be used
+func validateToolArguments(server string, options *OptionsV2) []Diagnostic {
+	var diags []Diagnostic
+	for toolName, set := range options.ToolArguments {
+		var both []string
+		for argument := range set.Defaults {
+			if _, forced := set.Force[argument]; forced {
+				both = append(both, argument)
+			}
+		}
+		if len(both) == 0 {
+			continue
+		}
+		sort.Strings(both)
+		diags = append(diags, Diagnostic{
+			Severity: SeverityWarning,
+			Server:   server,
+			Message:  fmt.Sprintf("toolArguments of %s set %s both as defaults and forced", toolName, strings.Join(both, ", ")),
+			Hint:     "forced values are always passed; remove them from defaults",
+		})
+	}
+	sort.Slice(diags, func(i, j int) bool { return diags[i].Message < diags[j].Message })
+	return diags
+}
+
+// validateResultTransforms checks that each of a server's resultTransforms
+// sets one valid transform
+func validateResultTransforms(server string, options *OptionsV2) []Diagnostic {
+	toolNames := make([]string, 0, len(options.ResultTransforms))
+	for toolName := range options.ResultTransforms {
+		toolNames = append(toolNames, toolName)
+	}
+	sort.Strings(toolNames)
+
+	var diags []Diagnostic
+	for _, toolName := range toolNames {
+		for i, cfg := range options.ResultTransforms[toolName] {
+			where := fmt.Sprintf("resultTransforms.%s[%d]", toolName, i)
+			set := 0
+			for _, isSet := range []bool{cfg.Extract != "", cfg.Redact != "", cfg.MaxLines != 0, cfg.HTMLToMarkdown} {
+				if isSet {
+					set++
+				}
+			}
+			var err error
+			hint := ""
+			switch {
+			case set != 1:
+				err = fmt.Errorf("sets %d transforms", set)
+				hint = "set one of extract, redact, maxLines or htmlToMarkdown in each transform"
+			case cfg.Extract != "":
+				_, err = transform.Extract(cfg.Extract)
+				hint = "paths are as in jq or JSONPath, e.g. $.items[*].title"
+			case cfg.Redact != "":
+				_, err = transform.Redact(cfg.Redact, cfg.Replacement)
+			case cfg.MaxLines < 0:
+				err = fmt.Errorf("maxLines %d is negative", cfg.MaxLines)
+			}
+			if err != nil {
+				diags = append(diags, Diagnostic{
+					Severity: SeverityError,
+					Server:   server,
+					Message:  fmt.Sprintf("%s: %v", where, err),
+					Hint:     hint,
+				})
+			}
+		}
+	}
+	return diags
+}
+
+// validateToolPatterns checks that includeTools or excludeTools entries are
+// valid globs, as malformed ones silently match nothing
+func validateToolPatterns(server, option string, patterns []string) []Diagnostic {
+	var diags []Diagnostic
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Server:   server,
+				Message:  fmt.Sprintf("%s entry %q is not a valid pattern: %v", option, pattern, err),
+				Hint:     "use * and ? wildcards, or [...] character classes",
+			})
+		}
+	}
+	return diags
+}
+
+// validateLogLevel checks a server's own logLevel; an invalid one inherited
+// from mcpProxy is reported there
+func validateLogLevel(server string, conf *MCPClientConfigV2, proxy *MCPProxyConfigV2) []Diagnostic {
+	if conf.Options == nil || !conf.Options.LogLevel.Present() {
+		return nil
+	}
+	level := conf.Options.LogLevel.OrElse("")
+	if proxy.Options != nil && proxy.Options.LogLevel.Present() && level == proxy.Options.LogLevel.OrElse("") {
+		return nil
+	}
+	if _, err := ParseLogLevel(level); err != nil {
+		return []Diagnostic{{Severity: SeverityError, Server: server, Message: fmt.Sprintf("options.logLevel: %v", err), Hint: logLevelHint}}
+	}
+	return nil
+}
+
+// validateOutputValidation checks a server's own outputValidation; an invalid
+// one inherited from mcpProxy is reported there
+func validateOutputValidation(server string, conf *MCPClientConfigV2, proxy *MCPProxyConfigV2) []Diagnostic {
+	if conf.Options == nil || !conf.Options.OutputValidation.Present() {
+		return nil
+	}
+	mode := conf.Options.OutputValidation.OrElse("")
+	if proxy.Options != nil && proxy.Options.OutputValidation.Present() && mode == proxy.Options.OutputValidation.OrElse("") {
+		return nil
+	}
+	if !validOutputValidation(mode) {
+		return []Diagnostic{{Severity: SeverityError, Server: server, Message: fmt.Sprintf("unknown options.outputValidation %q", mode), Hint: outputValidationHint}}
+	}
+	return nil
+}
+
+// validateOversizedResults checks a server's own oversizedResults; an
+// invalid one inherited from mcpProxy is reported there
+func validateOversizedResults(server string, conf *MCPClientConfigV2, proxy *MCPProxyConfigV2) []Diagnostic {
+	if conf.Options == nil || !conf.Options.OversizedResults.Present() {
+		return nil
+	}
+	mode := conf.Options.OversizedResults.OrElse("")
+	if proxy.Options != nil && proxy.Options.OversizedResults.Present() && mode == proxy.Options.OversizedResults.OrElse("") {
+		return nil
+	}
+	if !validOversizedResults(mode) {
+		return []Diagnostic{{Severity: SeverityError, Server: server, Message: fmt.Sprintf("unknown options.oversizedResults %q", mode), Hint: oversizedResultsHint}}
+	}
+	return nil
+}
+
+// validateLogMessageLevel checks a server's own logMessageLevel; an invalid
+// one inherited from mcpProxy is reported there
+func validateLogMessageLevel(server string, conf *MCPClientConfigV2, proxy *MCPProxyConfigV2) []Diagnostic {
+	if conf.Options == nil || !conf.Options.LogMessageLevel.Present() {
+		return nil
+	}
+	level := conf.Options.LogMessageLevel.OrElse("")
+	if proxy.Options != nil && proxy.Options.LogMessageLevel.Present() && level == proxy.Options.LogMessageLevel.OrElse("") {
+		return nil
+	}
+	if !validLogMessageLevel(level) {
+		return []Diagnostic{{Severity: SeverityError, Server: server, Message: fmt.Sprintf("unknown options.logMessageLevel %q", level), Hint: logMessageLevelHint}}
+	}
+	return nil
+}
+
+// validateEnv checks that env entries can be passed to a child process
+func validateEnv(server string, env map[string]string) []Diagnostic {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var diags []Diagnostic
+	for _, key := range keys {
+		value := env[key]
+		switch {
+		case key == "":
+			diags = append(diags, Diagnostic{Severity: SeverityError, Server: server, Message: "env entry has an empty name"})
+		case strings.ContainsAny(key, "= \t\n\x00"):
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Server:   server,
+				Message:  fmt.Sprintf("env name %q contains '=', whitespace or NUL", key),
+				Hint:     "env is a map of NAME to value, e.g. {\"API_KEY\": \"...\"}",
+			})
+		}
+		if strings.ContainsRune(value, 0) {
+			diags = append(diags, Diagnostic{Severity: SeverityError, Server: server, Message: fmt.Sprintf("env %s contains a NUL byte", key)})
+		}
+		if strings.Contains(value, "${") {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityWarning,
+				Server:   server,
+				Message:  fmt.Sprintf("env %s contains an unexpanded variable: %s", key, value),
+				Hint:     "run with -expand-env, or check the ${...} syntax",
+			})
+		}
+	}
+	return diags
+}
+
+// validateURL checks that a remote server URL is well formed, that its tls
+// and proxy settings load and, unless offline, that something answers HTTP
+// there through them. Any HTTP response counts as reachable, since endpoints
+// often reject a bare GET.
+func validateURL(ctx context.Context, server, rawURL string, headers map[string]string, tlsConf *TLSConfig, proxy string, opts ValidateOptions) []Diagnostic {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return []Diagnostic{{
+			Severity: SeverityError,
+			Server:   server,
+			Message:  fmt.Sprintf("invalid url %q", rawURL),
+			Hint:     "use an absolute http:// or https:// URL",
+		}}
+	}
+	var diags []Diagnostic
+	if tlsConf != nil && tlsConf.InsecureSkipVerify {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityWarning,
+			Server:   server,
+			Message:  "tls.insecureSkipVerify accepts any certificate, leaving the connection open to interception",
+			Hint:     "set tls.caFile to the server's CA instead",
+		})
+	}
+	transport, err := HTTPTransport(tlsConf, proxy)
+	if err != nil {
+		return append(diags, Diagnostic{Severity: SeverityError, Server: server, Message: err.Error()})
+	}
+	httpClient := nethttp.DefaultClient
+	if transport != nil {
+		defer transport.CloseIdleConnections()
+		httpClient = &nethttp.Client{Transport: transport}
+	}
+	if opts.Offline {
+		return diags
+	}
+	return append(diags, probeURL(ctx, server, rawURL, headers, httpClient, opts)...)
+}
+
+// probeURL checks that something answers HTTP at rawURL
+func probeURL(ctx context.Context, server, rawURL string, headers map[string]string, httpClient *nethttp.Client, opts ValidateOptions) []Diagnostic {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, rawURL, nil)
+	if err != nil {
+		return []Diagnostic{{Severity: SeverityError, Server: server, Message: fmt.Sprintf("invalid url %q: %v", rawURL, err)}}
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Accept", "application/json, text/event-stream")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return []Diagnostic{{
+			Severity: SeverityError,
+			Server:   server,
+			Message:  fmt.Sprintf("url %s is unreachable: %v", rawURL, err),
+			Hint:     "check that the server is running and reachable from here, or pass -offline",
+		}}
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode == nethttp.StatusUnauthorized || resp.StatusCode == nethttp.StatusForbidden {
+		return []Diagnostic{{
+			Severity: SeverityWarning,
+			Server:   server,
+			Message:  fmt.Sprintf("url %s answered %s", rawURL, resp.Status),
+			Hint:     "check the credentials in headers",
+		}}
+	}
+	return nil
+}
+
+// validateToolFilter checks a toolFilter, which the client otherwise ignores
+// or applies partially without failing
+func validateToolFilter(server string, filter *ToolFilterConfig) []Diagnostic {
+	var diags []Diagnostic
+	switch ToolFilterMode(strings.ToLower(string(filter.Mode))) {
+	case ToolFilterModeAllow, ToolFilterModeBlock:
+	default:
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Server:   server,
+			Message:  fmt.Sprintf("unknown toolFilter.mode %q; the filter would be ignored", filter.Mode),
+			Hint:     "use allow or block",
+		})
+	}
+	if len(filter.List) == 0 {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityWarning,
+			Server:   server,
+			Message:  "toolFilter.list is empty; the filter has no effect",
+		})
+	}
+
+	seen := make(map[string]bool)
+	for _, toolName := range filter.List {
+		switch {
+		case strings.TrimSpace(toolName) == "":
+			diags = append(diags, Diagnostic{Severity: SeverityError, Server: server, Message: "toolFilter.list contains an empty tool name"})
+		case toolName != strings.TrimSpace(toolName):
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Server:   server,
+				Message:  fmt.Sprintf("toolFilter.list entry %q has surrounding whitespace and will never match", toolName),
+			})
+		case strings.ContainsAny(toolName, "*?["):
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Server:   server,
+				Message:  fmt.Sprintf("toolFilter.list entry %q looks like a pattern, but entries must be exact tool names", toolName),
+				Hint:     "use includeTools or excludeTools for patterns",
+			})
+		case seen[toolName]:
+			diags = append(diags, Diagnostic{Severity: SeverityWarning, Server: server, Message: fmt.Sprintf("toolFilter.list contains %q more than once", toolName)})
+		}
+		seen[toolName] = true
+	}
+	return diags
+}
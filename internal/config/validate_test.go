@@ -0,0 +1,262 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TBXark/optional-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// findings returns the severity and message of each of diags
+func findings(diags []Diagnostic) []string {
+	var got []string
+	for _, diag := range diags {
+		got = append(got, string(diag.Severity)+": "+diag.Message)
+	}
+	return got
+}
+
+// TestValidateDuplicateNames verifies that servers defined twice in a JSON
+// file, and only those, are reported, for every file a source globs.
+func TestValidateDuplicateNames(t *testing.T) {
+	main := writeConfig(t, "config.json", `{
+  "mcpProxy": {"mcpServers": {"ignored": {}, "ignored": {}}},
+  "mcpServers": {"github": {"command": "a"}, "notes": {}, "github": {"command": "b"}}
+}`)
+	fragment := writeConfig(t, "servers.d.json", `{"mcpServers": {"jira": {}, "jira": {}, "slack": {}}}`)
+	yamlConfig := writeConfig(t, "config.yaml", "mcpServers: {}\n")
+
+	diags := validateDuplicateNames([]string{main, fragment, yamlConfig})
+	assert.Equal(t, []string{
+		"error: defined more than once in " + main + "; only the last definition is used",
+		"error: defined more than once in " + fragment + "; only the last definition is used",
+	}, findings(diags))
+	assert.Equal(t, "github", diags[0].Server)
+	assert.Equal(t, "jira", diags[1].Server)
+
+	for name, test := range map[string]struct {
+		data string
+		want []string
+	}{
+		"none":           {data: `{"mcpServers": {"a": {}, "b": {}}}`},
+		"sorted":         {data: `{"mcpServers": {"b": {}, "a": {}, "b": {}, "a": {}, "a": {}}}`, want: []string{"a", "b"}},
+		"nested":         {data: `{"mcpServers": {"a": {"env": {"X": "1", "X": "2"}}}}`},
+		"not an object":  {data: `{"mcpServers": []}`},
+		"invalid":        {data: `{"mcpServers": {"a": {}, "a": `},
+		"no mcpServers":  {data: `{"mcpProxy": {}}`},
+		"top-level list": {data: `[]`},
+	} {
+		assert.Equal(t, test.want, duplicateServerNames([]byte(test.data)), name)
+	}
+}
+
+// TestValidateEnv verifies that env entries whose names the process
+// environment cannot hold, and values with NUL bytes or unexpanded
+// variables, are reported in name order.
+func TestValidateEnv(t *testing.T) {
+	for name, test := range map[string]struct {
+		env  map[string]string
+		want []string
+	}{
+		"valid":       {env: map[string]string{"API_KEY": "secret", "EMPTY": ""}},
+		"empty name":  {env: map[string]string{"": "x"}, want: []string{"error: env entry has an empty name"}},
+		"equals":      {env: map[string]string{"A=B": "x"}, want: []string{`error: env name "A=B" contains '=', whitespace or NUL`}},
+		"whitespace":  {env: map[string]string{"A B": "x"}, want: []string{`error: env name "A B" contains '=', whitespace or NUL`}},
+		"NUL value":   {env: map[string]string{"A": "x\x00y"}, want: []string{"error: env A contains a NUL byte"}},
+		"unexpanded":  {env: map[string]string{"TOKEN": "${GITHUB_TOKEN}"}, want: []string{"warning: env TOKEN contains an unexpanded variable: ${GITHUB_TOKEN}"}},
+		"bare dollar": {env: map[string]string{"PRICE": "$5"}},
+		"in name order": {env: map[string]string{"B": "${B}", "A": "${A}"}, want: []string{
+			"warning: env A contains an unexpanded variable: ${A}",
+			"warning: env B contains an unexpanded variable: ${B}",
+		}},
+	} {
+		assert.Equal(t, test.want, findings(validateEnv("server", test.env)), name)
+	}
+}
+
+// TestValidateToolFilter verifies that toolFilter modes, in any case, and
+// its list entries are checked, as the client would ignore or never match
+// invalid ones.
+func TestValidateToolFilter(t *testing.T) {
+	for name, test := range map[string]struct {
+		filter ToolFilterConfig
+		want   []string
+	}{
+		"allow":             {filter: ToolFilterConfig{Mode: "allow", List: []string{"get_issue"}}},
+		"block in any case": {filter: ToolFilterConfig{Mode: "BLOCK", List: []string{"get_issue"}}},
+		"unknown mode": {filter: ToolFilterConfig{Mode: "deny", List: []string{"get_issue"}}, want: []string{
+			`error: unknown toolFilter.mode "deny"; the filter would be ignored`,
+		}},
+		"empty list": {filter: ToolFilterConfig{Mode: "allow"}, want: []string{"warning: toolFilter.list is empty; the filter has no effect"}},
+		"entries": {filter: ToolFilterConfig{Mode: "block", List: []string{" ", " get_issue", "get_*", "list", "list"}}, want: []string{
+			"error: toolFilter.list contains an empty tool name",
+			`error: toolFilter.list entry " get_issue" has surrounding whitespace and will never match`,
+			`error: toolFilter.list entry "get_*" looks like a pattern, but entries must be exact tool names`,
+			`warning: toolFilter.list contains "list" more than once`,
+		}},
+	} {
+		assert.Equal(t, test.want, findings(validateToolFilter("server", &test.filter)), name)
+	}
+}
+
+// TestValidateURL verifies that malformed URLs are errors, that offline only
+// the URL and its TLS settings are checked, and that otherwise a server that
+// rejects the credentials is a warning and one that does not answer an error.
+func TestValidateURL(t *testing.T) {
+	ctx := context.Background()
+	offline := ValidateOptions{Offline: true}
+
+	for name, test := range map[string]struct {
+		url  string
+		tls  *TLSConfig
+		want []string
+	}{
+		"valid":        {url: "https://example.com/mcp"},
+		"relative":     {url: "/mcp", want: []string{`error: invalid url "/mcp"`}},
+		"other scheme": {url: "ftp://example.com/mcp", want: []string{`error: invalid url "ftp://example.com/mcp"`}},
+		"no host":      {url: "https:///mcp", want: []string{`error: invalid url "https:///mcp"`}},
+		"insecure": {url: "https://example.com/mcp", tls: &TLSConfig{InsecureSkipVerify: true}, want: []string{
+			"warning: tls.insecureSkipVerify accepts any certificate, leaving the connection open to interception",
+		}},
+	} {
+		assert.Equal(t, test.want, findings(validateURL(ctx, "server", test.url, nil, test.tls, "", offline)), name)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	online := ValidateOptions{Timeout: 5 * time.Second}
+	headers := map[string]string{"Authorization": "Bearer secret"}
+	assert.Empty(t, validateURL(ctx, "server", srv.URL, headers, nil, "", online), "any answer counts as reachable")
+	assert.Equal(t, []string{"warning: url " + srv.URL + " answered 401 Unauthorized"},
+		findings(validateURL(ctx, "server", srv.URL, nil, nil, "", online)))
+	srv.Close()
+	diags := validateURL(ctx, "server", srv.URL, headers, nil, "", online)
+	if assert.Len(t, diags, 1) {
+		assert.Equal(t, SeverityError, diags[0].Severity)
+		assert.Contains(t, diags[0].Message, "is unreachable")
+	}
+}
+
+// TestValidateResultTransforms verifies that each transform sets exactly
+// one of its kinds, and that paths and patterns compile.
+func TestValidateResultTransforms(t *testing.T) {
+	for name, test := range map[string]struct {
+		transforms []ResultTransformConfig
+		want       []string
+	}{
+		"valid": {transforms: []ResultTransformConfig{
+			{Extract: "$.items[*].title"},
+			{Redact: `ghp_(\w+)`, Replacement: "$1-***"},
+			{MaxLines: 10},
+			{HTMLToMarkdown: true},
+		}},
+		"none set":      {transforms: []ResultTransformConfig{{}}, want: []string{"error: resultTransforms.*[0]: sets 0 transforms"}},
+		"two set":       {transforms: []ResultTransformConfig{{MaxLines: 1, HTMLToMarkdown: true}}, want: []string{"error: resultTransforms.*[0]: sets 2 transforms"}},
+		"invalid path":  {transforms: []ResultTransformConfig{{MaxLines: 1}, {Extract: "$.items["}}, want: []string{"error: resultTransforms.*[1]: "}},
+		"invalid regex": {transforms: []ResultTransformConfig{{Redact: "ghp_("}}, want: []string{"error: resultTransforms.*[0]: "}},
+		"negative":      {transforms: []ResultTransformConfig{{MaxLines: -1}}, want: []string{"error: resultTransforms.*[0]: maxLines -1 is negative"}},
+	} {
+		got := findings(validateResultTransforms("server", &OptionsV2{ResultTransforms: map[string][]ResultTransformConfig{"*": test.transforms}}))
+		if assert.Len(t, got, len(test.want), name) {
+			for i, want := range test.want {
+				assert.Contains(t, got[i], want, name)
+			}
+		}
+	}
+}
+
+// TestValidateExposeTags verifies that exposeTags naming a tag no server or
+// tool has is a warning.
+func TestValidateExposeTags(t *testing.T) {
+	servers := map[string]*MCPClientConfigV2{
+		"github": {Tags: []string{"work"}},
+		"notes":  {Options: &OptionsV2{ToolTags: map[string][]string{"search": {"read"}}}},
+		"empty":  nil,
+	}
+	proxy := &MCPProxyConfigV2{Options: &OptionsV2{ExposeTags: []string{"work", "read", "wrok"}}}
+	assert.Equal(t, []string{`warning: mcpProxy.options.exposeTags names tag "wrok", which no server or tool has`},
+		findings(validateExposeTags(proxy, servers)))
+	assert.Empty(t, validateExposeTags(&MCPProxyConfigV2{}, servers))
+}
+
+// TestValidateExposure verifies that unknown exposure modes are rejected, and
+// pins only accepted with the hybrid exposure that lists them.
+func TestValidateExposure(t *testing.T) {
+	pin := []string{"github.create_issue"}
+	for name, test := range map[string]struct {
+		options OptionsV2
+		want    []string
+	}{
+		"default":      {options: OptionsV2{Pin: pin}},
+		"hybrid":       {options: OptionsV2{Exposure: optional.NewField(ExposureHybrid), Pin: pin}},
+		"hierarchical": {options: OptionsV2{Exposure: optional.NewField(ExposureHierarchical)}},
+		"pinned hierarchical": {options: OptionsV2{Exposure: optional.NewField(ExposureHierarchical), Pin: pin}, want: []string{
+			"warning: mcpProxy.options.pin has no effect with hierarchical exposure",
+		}},
+		"pinned flat": {options: OptionsV2{Exposure: optional.NewField(ExposureFlat), Pin: pin}, want: []string{
+			"warning: mcpProxy.options.pin has no effect with flat exposure",
+		}},
+		"unknown": {options: OptionsV2{Exposure: optional.NewField("lazy")}, want: []string{
+			`error: unknown mcpProxy.options.exposure "lazy"`,
+		}},
+	} {
+		assert.Equal(t, test.want, findings(validateProxy(&MCPProxyConfigV2{Type: MCPServerTypeStdio, Options: &test.options})), name)
+	}
+}
+
+// TestValidateVirtualServers verifies that virtual servers need a valid name
+// that no server or group has, and tools that do not share a name.
+func TestValidateVirtualServers(t *testing.T) {
+	servers := map[string]*MCPClientConfigV2{
+		"github": {},
+		"jira":   {Group: "/work/tracking/"},
+	}
+	for name, test := range map[string]struct {
+		virtual map[string]VirtualServerConfig
+		want    []string
+	}{
+		"valid": {virtual: map[string]VirtualServerConfig{"project-x": {Tools: []string{"github.create_issue", "work.tracking.jira.create_ticket"}}}},
+		"invalid name": {virtual: map[string]VirtualServerConfig{"project.x": {Tools: []string{"github.create_issue"}}}, want: []string{
+			`error: mcpProxy.virtualServers name "project.x" is not a valid category name`,
+		}},
+		"server's name": {virtual: map[string]VirtualServerConfig{"github": {Tools: []string{"github.create_issue"}}}, want: []string{
+			"error: mcpProxy.virtualServers.github has the same hierarchy path as a server",
+		}},
+		"group's name": {virtual: map[string]VirtualServerConfig{"work": {Tools: []string{"github.create_issue"}}}, want: []string{
+			"error: mcpProxy.virtualServers.work has the same hierarchy path as a group",
+		}},
+		"no tools": {virtual: map[string]VirtualServerConfig{"project-x": {}}, want: []string{
+			"error: mcpProxy.virtualServers.project-x has no tools",
+		}},
+		"same tool name": {virtual: map[string]VirtualServerConfig{"project-x": {Tools: []string{"github.create_issue", "gitlab.create_issue"}}}, want: []string{
+			`error: mcpProxy.virtualServers.project-x lists github.create_issue and gitlab.create_issue under the same name "create_issue"`,
+		}},
+		"in name order": {virtual: map[string]VirtualServerConfig{"b": {}, "a": {}}, want: []string{
+			"error: mcpProxy.virtualServers.a has no tools",
+			"error: mcpProxy.virtualServers.b has no tools",
+		}},
+	} {
+		proxy := &MCPProxyConfigV2{VirtualServers: test.virtual}
+		assert.Equal(t, test.want, findings(validateVirtualServers(proxy, servers)), name)
+	}
+	assert.Empty(t, validateVirtualServers(nil, servers))
+}
+
+// TestValidateMaxConcurrent verifies that maxConcurrent above one is only
+// accepted silently for remote servers.
+func TestValidateMaxConcurrent(t *testing.T) {
+	options := &OptionsV2{MaxConcurrent: optional.NewField(4)}
+	diags := validateServer(context.Background(), "local", &MCPClientConfigV2{Command: "sh", Options: options}, ValidateOptions{Offline: true})
+	assert.Equal(t, []string{"warning: maxConcurrent only applies to remote servers"}, findings(diags))
+	diags = validateServer(context.Background(), "remote", &MCPClientConfigV2{URL: "https://example.com/mcp", Options: options}, ValidateOptions{Offline: true})
+	assert.Empty(t, diags)
+}
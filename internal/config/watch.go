@@ -0,0 +1,113 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long Watch waits for a burst of file events to settle;
+// editors often save through several writes or a rename.
+const watchDebounce = 200 * time.Millisecond
+
+// Watch calls onChange each time a file matching one of the given paths or
+// glob patterns is written or created, until ctx is done. Parent directories
+// are watched rather than the files themselves so that saves which replace a
+// file, and files newly added to an included directory, are not missed.
+// Bursts of events are coalesced into a single call.
+func Watch(ctx context.Context, patterns []string, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	absPatterns := make([]string, 0, len(patterns))
+	watchedDirs := make(map[string]bool)
+	for _, pattern := range patterns {
+		absPattern, err := filepath.Abs(pattern)
+		if err != nil {
+			_ = watcher.Close()
+			return fmt.Errorf("failed to resolve %s: %w", pattern, err)
+		}
+		absPatterns = append(absPatterns, absPattern)
+
+		dir := filepath.Dir(absPattern)
+		if watchedDirs[dir] {
+			continue
+		}
+		watchedDirs[dir] = true
+		if err := watcher.Add(dir); err != nil {
+			// An include directory that does not exist yet has nothing to watch
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			_ = watcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+	if len(watcher.WatchList()) == 0 {
+		_ = watcher.Close()
+		return errors.New("none of the config directories exist")
+	}
+
+	// Calls never overlap, even if a change lands while onChange is running
+	var mu sync.Mutex
+	notify := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		onChange()
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var debounce *time.Timer
+		defer func() {
+			if debounce != nil {
+				debounce.Stop()
+			}
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !matchesAny(absPatterns, filepath.Clean(event.Name)) || !(event.Has(fsnotify.Write) || event.Has(fsnotify.Create)) {
+					continue
+				}
+				if debounce == nil {
+					debounce = time.AfterFunc(watchDebounce, notify)
+				} else {
+					debounce.Reset(watchDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("Error watching config files", "error", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// matchesAny reports whether name matches any of the glob patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
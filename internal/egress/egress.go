@@ -0,0 +1,111 @@
+// Package egress restricts the hosts spawned servers may reach. Their HTTP
+// and HTTPS traffic goes through a proxy built into lazy-mcp, which lets
+// each server through only to the hosts of its allowlist.
+package egress
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// rule is an entry of an allowlist: a domain name, a wildcard matching the
+// subdomains of one, or a range of IP addresses, on any port or on one
+type rule struct {
+	domain   string       // Lowercase, without the "*." of a wildcard
+	wildcard bool         // Matches the subdomains of domain, not domain itself
+	prefix   netip.Prefix // Valid for IP address rules
+	port     int          // Zero for any port
+}
+
+// Policy is the allowlist of the hosts a server may reach
+type Policy struct {
+	rules []rule
+}
+
+// NewPolicy compiles an allowlist of domain names such as api.github.com,
+// wildcards such as *.github.com, IP addresses and CIDRs, each optionally
+// with a :port
+func NewPolicy(allow []string) (*Policy, error) {
+	policy := &Policy{}
+	for _, entry := range allow {
+		r, err := parseRule(entry)
+		if err != nil {
+			return nil, err
+		}
+		policy.rules = append(policy.rules, r)
+	}
+	return policy, nil
+}
+
+// Check returns why entry is not a valid allowlist entry, or nil
+func Check(entry string) error {
+	_, err := parseRule(entry)
+	return err
+}
+
+func parseRule(entry string) (rule, error) {
+	var r rule
+	host := strings.TrimSpace(entry)
+	// A port follows a bracketed IPv6 address, or a host with no other colon
+	if strings.HasPrefix(host, "[") || strings.Count(host, ":") == 1 {
+		h, port, err := net.SplitHostPort(host)
+		if err != nil {
+			return r, fmt.Errorf("invalid egress entry %q: %w", entry, err)
+		}
+		if r.port, err = strconv.Atoi(port); err != nil || r.port < 1 || r.port > 65535 {
+			return r, fmt.Errorf("invalid port in egress entry %q", entry)
+		}
+		host = h
+	}
+	if host == "" {
+		return r, fmt.Errorf("empty egress entry %q", entry)
+	}
+	if prefix, err := netip.ParsePrefix(host); err == nil {
+		r.prefix = prefix.Masked()
+		return r, nil
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		r.prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		return r, nil
+	}
+	if strings.Contains(host, "/") {
+		return r, fmt.Errorf("invalid CIDR in egress entry %q", entry)
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if domain, ok := strings.CutPrefix(host, "*."); ok {
+		host, r.wildcard = domain, true
+	}
+	if host == "" || strings.ContainsAny(host, "*/ ") {
+		return r, fmt.Errorf("invalid domain in egress entry %q; use e.g. api.github.com or *.github.com", entry)
+	}
+	r.domain = host
+	return r, nil
+}
+
+// AllowsName reports whether the domain name may be reached on port
+func (p *Policy) AllowsName(name string, port int) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	for _, r := range p.rules {
+		if r.domain == "" || (r.port != 0 && r.port != port) {
+			continue
+		}
+		if r.wildcard && strings.HasSuffix(name, "."+r.domain) || !r.wildcard && name == r.domain {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAddr reports whether the IP address may be reached on port
+func (p *Policy) AllowsAddr(addr netip.Addr, port int) bool {
+	addr = addr.Unmap()
+	for _, r := range p.rules {
+		if r.prefix.IsValid() && r.prefix.Contains(addr) && (r.port == 0 || r.port == port) {
+			return true
+		}
+	}
+	return false
+}
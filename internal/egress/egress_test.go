@@ -0,0 +1,123 @@
+package egress
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPolicy verifies that domains match exactly, wildcards only their
+// subdomains, and addresses their CIDR, on any port or on the one given.
+func TestPolicy(t *testing.T) {
+	policy, err := NewPolicy([]string{"api.github.com", "*.googleapis.com:443", "10.0.0.0/8", "[2001:db8::1]:8443"})
+	require.NoError(t, err)
+
+	assert.True(t, policy.AllowsName("API.GitHub.com.", 80))
+	assert.False(t, policy.AllowsName("github.com", 443))
+	assert.True(t, policy.AllowsName("storage.googleapis.com", 443))
+	assert.False(t, policy.AllowsName("storage.googleapis.com", 80))
+	assert.False(t, policy.AllowsName("googleapis.com", 443))
+	assert.True(t, policy.AllowsAddr(netip.MustParseAddr("10.1.2.3"), 5432))
+	assert.True(t, policy.AllowsAddr(netip.MustParseAddr("::ffff:10.1.2.3"), 5432))
+	assert.False(t, policy.AllowsAddr(netip.MustParseAddr("192.168.1.1"), 80))
+	assert.True(t, policy.AllowsAddr(netip.MustParseAddr("2001:db8::1"), 8443))
+
+	for _, entry := range []string{"", "example.com:http", "10.0.0.0/33", "*.*.example.com", "example.com:70000"} {
+		assert.Error(t, Check(entry), entry)
+	}
+}
+
+// TestProxy verifies that the proxy lets a registered server through to the
+// hosts its policy allows, plain and tunneled, by name or by the addresses
+// names resolve to, and turns away other hosts and clients without the
+// server's credentials, and that it listens for containers on a single
+// address.
+func TestProxy(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello " + r.URL.Path))
+	}))
+	defer upstream.Close()
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Proxy-Authorization"))
+		_, _ = w.Write([]byte("plain " + r.URL.Path))
+	}))
+	defer plain.Close()
+
+	policy, err := NewPolicy([]string{"127.0.0.1"})
+	require.NoError(t, err)
+	proxy := &Proxy{}
+	proxyURL, err := proxy.Register("fetch", policy, false, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+
+	get := func(proxyURL *url.URL, target string) (int, string) {
+		transport := upstream.Client().Transport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(proxyURL)
+		resp, err := (&http.Client{Transport: transport}).Get(target)
+		if err != nil {
+			return 0, err.Error()
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	// connect opens a tunnel to target, a host:port, returning the status
+	connect := func(target string) int {
+		conn, err := net.Dial("tcp", proxyURL.Host)
+		require.NoError(t, err)
+		defer conn.Close()
+		password, _ := proxyURL.User.Password()
+		request := &http.Request{Method: http.MethodConnect, URL: &url.URL{Opaque: target}, Host: target, Header: http.Header{}}
+		request.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username()+":"+password)))
+		require.NoError(t, request.Write(conn))
+		resp, err := http.ReadResponse(bufio.NewReader(conn), request)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+	_, upstreamPort, _ := net.SplitHostPort(upstream.Listener.Addr().String())
+
+	status, body := get(proxyURL, upstream.URL+"/tunneled")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "hello /tunneled", body)
+	status, body = get(proxyURL, plain.URL+"/forwarded")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "plain /forwarded", body)
+
+	status, _ = get(proxyURL, "http://192.0.2.1/")
+	assert.Equal(t, http.StatusForbidden, status)
+	// Names the policy does not allow are let through to the addresses it does
+	assert.Equal(t, http.StatusOK, connect(net.JoinHostPort("localhost", upstreamPort)))
+
+	anonymous := *proxyURL
+	anonymous.User = nil
+	status, _ = get(&anonymous, plain.URL)
+	assert.Equal(t, http.StatusProxyAuthRequired, status)
+
+	// Registering again replaces the policy, with the same credentials
+	policy, err = NewPolicy([]string{"example.com"})
+	require.NoError(t, err)
+	again, err := proxy.Register("fetch", policy, false, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+	assert.Equal(t, proxyURL.String(), again.String())
+	status, _ = get(proxyURL, plain.URL)
+	assert.Equal(t, http.StatusForbidden, status)
+	// and tunnels to names resolving to addresses it denies are refused
+	assert.Equal(t, http.StatusForbidden, connect(net.JoinHostPort("localhost", upstreamPort)))
+
+	containerURL, err := proxy.Register("fetch", policy, true, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+	assert.Equal(t, ContainerHost, containerURL.Hostname())
+	listenAddr := netip.MustParseAddrPort(proxy.listeners[true].Addr().String())
+	assert.False(t, listenAddr.Addr().IsUnspecified(), "the proxy for containers does not listen on every interface")
+}
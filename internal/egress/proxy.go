@@ -0,0 +1,286 @@
+package egress
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ContainerHost is the name containers reach the host, and so the proxy, by
+const ContainerHost = "host.docker.internal"
+
+// dialTimeout bounds connecting to an allowed host
+const dialTimeout = 30 * time.Second
+
+// errDenied is returned for hosts a server's policy does not allow
+var errDenied = errors.New("host not allowed by egress policy")
+
+// hopHeaders are those of a single connection, not passed on
+var hopHeaders = []string{"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+
+// Proxy is an HTTP proxy, for plain requests and CONNECT tunnels, that lets
+// each registered server through to the hosts of its policy. Servers
+// authenticate with the token they were registered with.
+type Proxy struct {
+	mu        sync.Mutex
+	tokens    map[string]string        // Token of each server
+	servers   map[string]*registration // By token
+	listeners map[bool]net.Listener    // By whether containers can reach them
+}
+
+// registration is a server registered with the proxy
+type registration struct {
+	policy    *Policy
+	log       *slog.Logger // Receives the denied connections
+	transport *http.Transport
+}
+
+// Default is the proxy of lazy-mcp's servers
+var Default = &Proxy{}
+
+// Register lets server through the proxy to the hosts policy allows, in
+// place of any policy it had, and logs the connections denied to log. It
+// returns the URL of the proxy, with the server's credentials, for processes
+// on this host, or for containers if forContainer.
+func (p *Proxy) Register(server string, policy *Policy, forContainer bool, log *slog.Logger) (*url.URL, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	listener, err := p.listen(forContainer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start egress proxy: %w", err)
+	}
+
+	token, ok := p.tokens[server]
+	if !ok {
+		secret := make([]byte, 16)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		token = hex.EncodeToString(secret)
+		if p.tokens == nil {
+			p.tokens = map[string]string{}
+			p.servers = map[string]*registration{}
+		}
+		p.tokens[server] = token
+	}
+	reg := &registration{policy: policy, log: log}
+	reg.transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return reg.dial(ctx, addr)
+		},
+		ForceAttemptHTTP2:   true,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	if old := p.servers[token]; old != nil {
+		old.transport.CloseIdleConnections()
+	}
+	p.servers[token] = reg
+
+	host := "127.0.0.1"
+	if forContainer {
+		host = ContainerHost
+	}
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return &url.URL{Scheme: "http", User: url.UserPassword("lazy-mcp", token), Host: net.JoinHostPort(host, port)}, nil
+}
+
+// listen returns the proxy's listener on the loopback interface or, for
+// containers, on the address they reach the host by, starting it if need be.
+// The caller holds p.mu.
+func (p *Proxy) listen(forContainer bool) (net.Listener, error) {
+	if listener, ok := p.listeners[forContainer]; ok {
+		return listener, nil
+	}
+	host := "127.0.0.1"
+	if forContainer {
+		host = containerGateway()
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return nil, err
+	}
+	if p.listeners == nil {
+		p.listeners = map[bool]net.Listener{}
+	}
+	p.listeners[forContainer] = listener
+	server := &http.Server{Handler: p, ReadHeaderTimeout: 10 * time.Second}
+	go func() { _ = server.Serve(listener) }()
+	return listener, nil
+}
+
+// bridgeInterface is the interface of Docker's default bridge network on
+// Linux, whose address host.docker.internal maps to as host-gateway
+const bridgeInterface = "docker0"
+
+// containerGateway returns the address containers reach the host by: that of
+// the default bridge on Linux, from any network, rather than every interface,
+// which would expose the proxy to the LAN. Docker Desktop forwards
+// host.docker.internal to the host's loopback interface, which is used where
+// there is no bridge.
+func containerGateway() string {
+	if iface, err := net.InterfaceByName(bridgeInterface); err == nil {
+		if addrs, err := iface.Addrs(); err == nil {
+			for _, addr := range addrs {
+				if prefix, err := netip.ParsePrefix(addr.String()); err == nil && prefix.Addr().Is4() {
+					return prefix.Addr().String()
+				}
+			}
+		}
+	}
+	return "127.0.0.1"
+}
+
+// authorize returns the server a request comes from, per its credentials
+func (p *Proxy) authorize(r *http.Request) *registration {
+	credentials := &http.Request{Header: http.Header{"Authorization": r.Header.Values("Proxy-Authorization")}}
+	_, token, ok := credentials.BasicAuth()
+	if !ok {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.servers[token]
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reg := p.authorize(r)
+	if reg == nil {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="lazy-mcp egress"`)
+		http.Error(w, "proxy authentication required", http.StatusProxyAuthRequired)
+		return
+	}
+	if r.Method == http.MethodConnect {
+		reg.tunnel(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "not a proxy request", http.StatusBadRequest)
+		return
+	}
+	reg.forward(w, r)
+}
+
+// tunnel relays a CONNECT request's connection to its host
+func (reg *registration) tunnel(w http.ResponseWriter, r *http.Request) {
+	upstream, err := reg.dial(r.Context(), r.Host)
+	if err != nil {
+		reg.fail(w, r.Host, err)
+		return
+	}
+	defer upstream.Close()
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "tunneling not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		// What the client sent past the request may already be buffered
+		_, _ = io.Copy(upstream, buffered.Reader)
+		closeWrite(upstream)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(client, upstream)
+		closeWrite(client)
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+}
+
+// closeWrite signals the end of what is written to conn, where supported
+func closeWrite(conn net.Conn) {
+	if tcp, ok := conn.(interface{ CloseWrite() error }); ok {
+		_ = tcp.CloseWrite()
+	}
+}
+
+// forward passes a plain HTTP request on to its host
+func (reg *registration) forward(w http.ResponseWriter, r *http.Request) {
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, header := range hopHeaders {
+		out.Header.Del(header)
+	}
+	resp, err := reg.transport.RoundTrip(out)
+	if err != nil {
+		reg.fail(w, r.URL.Host, err)
+		return
+	}
+	defer resp.Body.Close()
+	for _, header := range hopHeaders {
+		resp.Header.Del(header)
+	}
+	for key, values := range resp.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+// fail answers a request that could not reach host
+func (reg *registration) fail(w http.ResponseWriter, host string, err error) {
+	if errors.Is(err, errDenied) {
+		reg.log.Warn("Egress denied", "host", host)
+		http.Error(w, fmt.Sprintf("%s: %v", host, errDenied), http.StatusForbidden)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
+
+// dial connects to addr, a host:port, if the policy allows it. Domain names
+// the policy does not allow by name are resolved, and the first address it
+// allows is connected to, so that the address checked is the one used.
+func (reg *registration) dial(ctx context.Context, addr string) (net.Conn, error) {
+	host, portText, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", portText)
+	}
+	dialer := &net.Dialer{Timeout: dialTimeout}
+
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if !reg.policy.AllowsAddr(ip, port) {
+			return nil, errDenied
+		}
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+	if reg.policy.AllowsName(host, port) {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if reg.policy.AllowsAddr(ip, port) {
+			return dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.Unmap().String(), portText))
+		}
+	}
+	return nil, errDenied
+}
@@ -0,0 +1,231 @@
+// Package embedding turns text into vectors whose similarity reflects that of
+// the text, for semantic search of tools.
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// Provider computes embeddings, one per text and in the same order
+type Provider interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// New returns the provider configured by cfg: the HTTP endpoint it names, or
+// the local Hash provider if it names none
+func New(cfg *config.EmbeddingsConfig) Provider {
+	if cfg == nil || cfg.URL == "" {
+		return NewHash(DefaultHashDimensions)
+	}
+	timeout := time.Duration(cfg.Timeout)
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &httpProvider{
+		url:     cfg.URL,
+		model:   cfg.Model,
+		headers: cfg.Headers,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Similarity returns the cosine similarity of two embeddings, from -1 to 1.
+// Embeddings of different lengths, from different providers, have none.
+func Similarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// DefaultHashDimensions is the length of Hash embeddings made by New
+const DefaultHashDimensions = 512
+
+// Hash embeds text locally by hashing its words, crudely stemmed, and their
+// character trigrams into a fixed number of dimensions. It needs no model,
+// and tolerates different forms and misspellings of a word, but texts are
+// only similar if they share words.
+type Hash struct {
+	dimensions int
+}
+
+// NewHash returns a Hash provider of embeddings of the given length
+func NewHash(dimensions int) *Hash {
+	return &Hash{dimensions: dimensions}
+}
+
+// Embed implements Provider
+func (h *Hash) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = h.embed(text)
+	}
+	return embeddings, nil
+}
+
+func (h *Hash) embed(text string) []float32 {
+	vector := make([]float32, h.dimensions)
+	add := func(feature string, weight float32) {
+		hash := fnv.New32a()
+		hash.Write([]byte(feature))
+		sum := hash.Sum32()
+		// The sign bit keeps colliding features from always adding up
+		if sum&(1<<31) != 0 {
+			weight = -weight
+		}
+		vector[int(sum%uint32(h.dimensions))] += weight
+	}
+	for _, word := range words(text) {
+		if stopWords[word] {
+			continue
+		}
+		word = stem(word)
+		add("w:"+word, 1)
+		padded := " " + word + " "
+		for i := 0; i+3 <= len(padded); i++ {
+			add("t:"+padded[i:i+3], 0.25)
+		}
+	}
+	return vector
+}
+
+// words splits text into lowercase words, breaking at camelCase boundaries
+func words(text string) []string {
+	var result []string
+	var word []rune
+	var prev rune
+	for _, r := range text {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) || unicode.IsUpper(r) && unicode.IsLower(prev) {
+			if len(word) > 0 {
+				result = append(result, string(word))
+				word = word[:0]
+			}
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			word = append(word, unicode.ToLower(r))
+		}
+		prev = r
+	}
+	if len(word) > 0 {
+		result = append(result, string(word))
+	}
+	return result
+}
+
+// stem strips common English suffixes, so that "creates" and "creating"
+// embed like "create"
+func stem(word string) string {
+	for _, suffix := range []string{"ing", "es", "ed", "s", "e"} {
+		if stemmed := strings.TrimSuffix(word, suffix); stemmed != word && len(stemmed) >= 3 {
+			return stemmed
+		}
+	}
+	return word
+}
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "of": true, "to": true, "in": true,
+	"on": true, "for": true, "with": true, "by": true, "or": true, "is": true, "it": true,
+}
+
+// maxBatch bounds the texts sent in one request, below the limits of
+// common providers
+const maxBatch = 100
+
+// httpProvider requests embeddings from an OpenAI-compatible endpoint
+type httpProvider struct {
+	url     string
+	model   string
+	headers map[string]string
+	client  *http.Client
+}
+
+type embeddingsRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingsResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed implements Provider
+func (p *httpProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += maxBatch {
+		end := min(start+maxBatch, len(texts))
+		batch, err := p.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, batch...)
+	}
+	return embeddings, nil
+}
+
+func (p *httpProvider) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(embeddingsRequest{Model: p.model, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embeddings request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range p.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request embeddings: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("embeddings request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var decoded embeddingsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode embeddings: %w", err)
+	}
+	embeddings := make([][]float32, len(texts))
+	for _, item := range decoded.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embeddings response has index %d for %d inputs", item.Index, len(texts))
+		}
+		embeddings[item.Index] = item.Embedding
+	}
+	for i, embedding := range embeddings {
+		if embedding == nil {
+			return nil, fmt.Errorf("embeddings response is missing input %d", i)
+		}
+	}
+	return embeddings, nil
+}
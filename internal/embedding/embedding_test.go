@@ -0,0 +1,61 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestHashEmbedsSharedWordsAlike verifies that texts sharing words, in any
+// form, are more similar than unrelated ones.
+func TestHashEmbedsSharedWordsAlike(t *testing.T) {
+	vectors, err := New(nil).Embed(context.Background(), []string{
+		"create a card",
+		"trello createCard: Creates a new card on a board",
+		"github delete_repository: Deletes a repository",
+	})
+	require.NoError(t, err)
+
+	related := Similarity(vectors[0], vectors[1])
+	unrelated := Similarity(vectors[0], vectors[2])
+	assert.Greater(t, related, 0.3)
+	assert.Less(t, unrelated, related/2)
+	assert.InDelta(t, 1, Similarity(vectors[1], vectors[1]), 1e-6)
+	assert.Zero(t, Similarity(vectors[0], []float32{1}), "embeddings of different lengths")
+}
+
+// TestHTTPProviderRequestsEmbeddings verifies the OpenAI-compatible request
+// and that embeddings are returned in input order.
+func TestHTTPProviderRequestsEmbeddings(t *testing.T) {
+	var request embeddingsRequest
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		// Out of order, as providers are allowed to respond
+		w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`))
+	}))
+	defer backend.Close()
+
+	provider := New(&config.EmbeddingsConfig{
+		URL:     backend.URL,
+		Model:   "text-embedding-3-small",
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+	})
+	vectors, err := provider.Embed(context.Background(), []string{"first", "second"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]float32{{1, 0}, {0, 1}}, vectors)
+	assert.Equal(t, embeddingsRequest{Model: "text-embedding-3-small", Input: []string{"first", "second"}}, request)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid model", http.StatusBadRequest)
+	}))
+	defer failing.Close()
+	_, err = New(&config.EmbeddingsConfig{URL: failing.URL, Model: "nope"}).Embed(context.Background(), []string{"first"})
+	assert.ErrorContains(t, err, "invalid model")
+}
@@ -0,0 +1,35 @@
+package hierarchy
+
+import "context"
+
+// toolHints are what a server's annotations of a tool tell of its side
+// effects, hints the server left out taking the defaults of the MCP spec
+type toolHints struct {
+	readOnly    bool // The tool does not modify its environment
+	destructive bool // The tool may destroy data; never for read-only tools
+	idempotent  bool // Calling the tool again with the same arguments has no further effect
+}
+
+// toolHints returns the hints of the tool as the server lists it, and false
+// if the server does not list it. Per the MCP spec, readOnlyHint and
+// idempotentHint default to false, and destructiveHint to true, but only
+// applies to tools that are not read-only.
+func (r *ServerRegistry) toolHints(ctx context.Context, serverName, toolName string) (toolHints, bool) {
+	tools, err := r.GetServerTools(ctx, serverName)
+	if err != nil {
+		return toolHints{}, false
+	}
+	for _, tool := range tools {
+		if tool.Name != toolName {
+			continue
+		}
+		annotations := tool.Annotations
+		readOnly := annotations.ReadOnlyHint != nil && *annotations.ReadOnlyHint
+		return toolHints{
+			readOnly:    readOnly,
+			destructive: !readOnly && (annotations.DestructiveHint == nil || *annotations.DestructiveHint),
+			idempotent:  annotations.IdempotentHint != nil && *annotations.IdempotentHint,
+		}, true
+	}
+	return toolHints{}, false
+}
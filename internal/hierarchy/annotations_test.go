@@ -0,0 +1,58 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestToolHints verifies that hints a server leaves out take the defaults of
+// the MCP spec, so that a tool without annotations counts as destructive, and
+// that a read-only tool never does.
+func TestToolHints(t *testing.T) {
+	hint := func(value bool) *bool { return &value }
+	mcpServer := server.NewMCPServer("docs", "1.0.0")
+	for _, tool := range []mcp.Tool{
+		{Name: "unannotated"},
+		{Name: "read", Annotations: mcp.ToolAnnotation{ReadOnlyHint: hint(true), DestructiveHint: hint(true)}},
+		{Name: "append", Annotations: mcp.ToolAnnotation{DestructiveHint: hint(false)}},
+		{Name: "upsert", Annotations: mcp.ToolAnnotation{IdempotentHint: hint(true)}},
+	} {
+		tool.InputSchema.Type = "object"
+		mcpServer.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultText("ok"), nil
+		})
+	}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"docs": {}},
+		map[string]*server.MCPServer{"docs": mcpServer},
+		nil,
+	)
+	defer registry.Close()
+	ctx := context.Background()
+
+	for toolName, want := range map[string]toolHints{
+		"unannotated": {destructive: true},
+		"read":        {readOnly: true},
+		"append":      {},
+		"upsert":      {destructive: true, idempotent: true},
+	} {
+		hints, listed := registry.toolHints(ctx, "docs", toolName)
+		assert.True(t, listed, toolName)
+		assert.Equal(t, want, hints, toolName)
+	}
+	_, listed := registry.toolHints(ctx, "docs", "missing")
+	assert.False(t, listed)
+
+	assert.False(t, registry.isCacheable(ctx, "docs", "unannotated"), "tools are destructive unless they say otherwise")
+	assert.True(t, registry.isCacheable(ctx, "docs", "append"))
+	assert.True(t, registry.isDestructive(ctx, "docs", "unannotated"))
+	assert.True(t, registry.isDestructive(ctx, "docs", "missing"))
+	assert.False(t, registry.isDestructive(ctx, "docs", "read"))
+	assert.True(t, registry.isIdempotent(ctx, "docs", "upsert"))
+	assert.False(t, registry.isIdempotent(ctx, "docs", "unannotated"))
+}
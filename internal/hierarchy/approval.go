@@ -0,0 +1,68 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/voicetreelab/lazy-mcp/internal/approval"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// ApprovalError is returned for calls that were not approved, which are not
+// forwarded to their server
+type ApprovalError struct {
+	Server string
+	Tool   string
+	Reason string
+}
+
+func (e *ApprovalError) Error() string {
+	return fmt.Sprintf("call to tool %s of server %s was not approved: %s", e.Tool, e.Server, e.Reason)
+}
+
+// SetApproval holds back the calls cfg requires approval for until approver
+// approves them
+func (h *Hierarchy) SetApproval(cfg *config.ApprovalConfig, approver approval.Approver) {
+	h.approval, h.approver = cfg, approver
+}
+
+// approveCall asks for approval of a resolved call, if it requires it
+func (h *Hierarchy) approveCall(ctx context.Context, registry *ServerRegistry, toolPath, serverName, toolName string, arguments map[string]interface{}) error {
+	if h.approver == nil {
+		return nil
+	}
+	destructive := h.approval.Destructive.OrElse(true) && registry.isDestructive(ctx, serverName, toolName)
+	if !h.approval.Required(serverName, toolName, destructive) {
+		return nil
+	}
+
+	// The wait for an answer is bounded on its own, before the call's timeout
+	// starts
+	approveCtx, cancel := context.WithTimeout(ctx, h.approval.WaitTimeout())
+	defer cancel()
+	decision, err := h.approver.Approve(approveCtx, approval.Request{
+		ToolPath:    toolPath,
+		Server:      serverName,
+		Tool:        toolName,
+		Arguments:   arguments,
+		Destructive: destructive,
+	})
+	if err != nil {
+		decision.Reason = err.Error()
+	}
+	if !decision.Approved {
+		slog.WarnContext(ctx, "Tool call not approved", "server", serverName, "tool", toolName, "reason", decision.Reason)
+		return &ApprovalError{Server: serverName, Tool: toolName, Reason: decision.Reason}
+	}
+	slog.InfoContext(ctx, "Tool call approved", "server", serverName, "tool", toolName)
+	return nil
+}
+
+// isDestructive reports whether the server's annotations of the tool hint it
+// is destructive. Tools the server does not list count as destructive, so
+// that approval errs on the side of asking.
+func (r *ServerRegistry) isDestructive(ctx context.Context, serverName, toolName string) bool {
+	hints, listed := r.toolHints(ctx, serverName, toolName)
+	return !listed || hints.destructive
+}
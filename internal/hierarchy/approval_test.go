@@ -0,0 +1,82 @@
+package hierarchy
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/approval"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// approverFunc adapts a func to approval.Approver
+type approverFunc func(ctx context.Context, request approval.Request) (approval.Decision, error)
+
+func (f approverFunc) Approve(ctx context.Context, request approval.Request) (approval.Decision, error) {
+	return f(ctx, request)
+}
+
+// TestApprovalHoldsBackDestructiveCalls verifies that calls of destructive
+// tools, and of tools matching approval.tools, reach their server only once
+// approved, while other calls are forwarded without asking.
+func TestApprovalHoldsBackDestructiveCalls(t *testing.T) {
+	var calls int32
+	handler := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		atomic.AddInt32(&calls, 1)
+		return mcp.NewToolResultText("done"), nil
+	}
+	mcpServer := server.NewMCPServer("db", "1.0.0")
+	mcpServer.AddTool(mcp.NewTool("query", mcp.WithReadOnlyHintAnnotation(true)), handler)
+	// mcp.NewTool marks tools destructive unless told otherwise
+	mcpServer.AddTool(mcp.NewTool("drop_table"), handler)
+	mcpServer.AddTool(mcp.NewTool("grant", mcp.WithDestructiveHintAnnotation(false)), handler)
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{
+			"query": {Server: "db"}, "drop_table": {Server: "db"}, "grant": {Server: "db"},
+		}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"db": {}},
+		map[string]*server.MCPServer{"db": mcpServer},
+		nil,
+	)
+	defer registry.Close()
+
+	var asked []approval.Request
+	approve := false
+	h.SetApproval(&config.ApprovalConfig{Tools: []string{"db.gr*"}}, approverFunc(func(ctx context.Context, request approval.Request) (approval.Decision, error) {
+		asked = append(asked, request)
+		return approval.Decision{Approved: approve, Reason: "not now"}, nil
+	}))
+	ctx := context.Background()
+
+	_, err := h.HandleExecuteTool(ctx, registry, "query", nil)
+	require.NoError(t, err)
+	assert.Empty(t, asked, "read-only tools need no approval")
+
+	_, err = h.HandleExecuteTool(ctx, registry, "drop_table", map[string]interface{}{"table": "users"})
+	var approvalErr *ApprovalError
+	require.ErrorAs(t, err, &approvalErr)
+	assert.Equal(t, "not now", approvalErr.Reason)
+	require.Len(t, asked, 1)
+	assert.Equal(t, approval.Request{
+		ToolPath: "drop_table", Server: "db", Tool: "drop_table",
+		Arguments: map[string]interface{}{"table": "users"}, Destructive: true,
+	}, asked[0])
+
+	_, err = h.HandleExecuteTool(ctx, registry, "grant", nil)
+	require.ErrorAs(t, err, &approvalErr)
+	require.Len(t, asked, 2)
+	assert.False(t, asked[1].Destructive, "grant is held back by its pattern")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "denied calls must not reach the server")
+
+	approve = true
+	_, err = h.HandleExecuteTool(ctx, registry, "drop_table", nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
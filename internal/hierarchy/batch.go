@@ -0,0 +1,53 @@
+package hierarchy
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// MaxBatchCalls bounds the number of calls in one batch
+const MaxBatchCalls = 50
+
+// BatchCall is one of the calls of a batch: the tool to call by path, as for
+// HandleExecuteTool, and its arguments
+type BatchCall struct {
+	ToolPath  string
+	Arguments map[string]interface{}
+}
+
+// BatchResult is the outcome of one call of a batch, as HandleExecuteTool
+// returned it
+type BatchResult struct {
+	Result *mcp.CallToolResult
+	Err    error
+}
+
+// HandleBatchCall executes calls as HandleExecuteTool does and returns their
+// outcomes in the same order. Calls to the same server are made one after
+// another, in the order given, so that a later call can rely on an earlier
+// one having been made; calls to different servers run in parallel. A call
+// that fails does not stop the others.
+func (h *Hierarchy) HandleBatchCall(ctx context.Context, registry *ServerRegistry, calls []BatchCall) []BatchResult {
+	results := make([]BatchResult, len(calls))
+	// Calls whose tool does not resolve, under "", fail at once
+	byServer := make(map[string][]int)
+	for i, call := range calls {
+		_, serverName, _, _ := h.resolveCall(call.ToolPath)
+		byServer[serverName] = append(byServer[serverName], i)
+	}
+
+	var wg sync.WaitGroup
+	for _, indexes := range byServer {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, i := range indexes {
+				results[i].Result, results[i].Err = h.HandleExecuteTool(ctx, registry, calls[i].ToolPath, calls[i].Arguments)
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,84 @@
+package hierarchy
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestBatchCallOrdersCallsPerServer verifies that a batch returns its results
+// in order, makes the calls to one server one after another in order while
+// calling other servers meanwhile, and fails unknown tools in place.
+func TestBatchCallOrdersCallsPerServer(t *testing.T) {
+	var mu sync.Mutex
+	var written []string
+	active := 0
+	readStarted := make(chan struct{})
+	var readOnce sync.Once
+
+	writer := server.NewMCPServer("writer", "1.0.0")
+	writer.AddTool(mcp.NewTool("write", mcp.WithString("text")), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		mu.Lock()
+		active++
+		overlapping := active > 1
+		mu.Unlock()
+		// The reader is called while the writer's calls are in progress
+		select {
+		case <-readStarted:
+		case <-time.After(5 * time.Second):
+			return mcp.NewToolResultError("reader was not called in parallel"), nil
+		}
+		mu.Lock()
+		active--
+		written = append(written, request.GetString("text", ""))
+		mu.Unlock()
+		if overlapping {
+			return mcp.NewToolResultError("calls to the writer overlapped"), nil
+		}
+		return mcp.NewToolResultText("wrote " + request.GetString("text", "")), nil
+	})
+	reader := server.NewMCPServer("reader", "1.0.0")
+	reader.AddTool(mcp.NewTool("read"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		readOnce.Do(func() { close(readStarted) })
+		return mcp.NewToolResultText("read"), nil
+	})
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"write": {Server: "writer"}, "read": {Server: "reader"}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"writer": {}, "reader": {}},
+		map[string]*server.MCPServer{"writer": writer, "reader": reader},
+		nil,
+	)
+	defer registry.Close()
+
+	results := h.HandleBatchCall(context.Background(), registry, []BatchCall{
+		{ToolPath: "write", Arguments: map[string]interface{}{"text": "first"}},
+		{ToolPath: "missing"},
+		{ToolPath: "write", Arguments: map[string]interface{}{"text": "second"}},
+		{ToolPath: "read"},
+		{ToolPath: "write", Arguments: map[string]interface{}{"text": "third"}},
+	})
+	require.Len(t, results, 5)
+
+	text := func(result BatchResult) string {
+		require.NoError(t, result.Err)
+		require.NotNil(t, result.Result)
+		require.False(t, result.Result.IsError, result.Result.Content)
+		return result.Result.Content[0].(mcp.TextContent).Text
+	}
+	assert.Equal(t, "wrote first", text(results[0]))
+	assert.Error(t, results[1].Err, "an unknown tool fails in place")
+	assert.Equal(t, "wrote second", text(results[2]))
+	assert.Equal(t, "read", text(results[3]))
+	assert.Equal(t, "wrote third", text(results[4]))
+	assert.Equal(t, []string{"first", "second", "third"}, written)
+}
@@ -0,0 +1,153 @@
+package hierarchy
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Decoding GIF images to scale them
+	"image/jpeg"
+	"image/png"
+	"log/slog"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// MaxImageDimension returns the largest width or height of images in results
+// of the given server's tools; larger ones are scaled down. Zero means images
+// are never scaled.
+func (r *ServerRegistry) MaxImageDimension(serverName string) int {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil {
+		return 0
+	}
+	return max(cfg.Options.MaxImageDimension.OrElse(0), 0)
+}
+
+// MaxInlineBinaryBytes returns how large images, audio and binary resources
+// in results of the given server's tools may be before they are saved and
+// linked instead of inlined. Zero means they are always inlined.
+func (r *ServerRegistry) MaxInlineBinaryBytes(serverName string) int {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil {
+		return 0
+	}
+	return max(cfg.Options.MaxInlineBinaryBytes.OrElse(0), 0)
+}
+
+// proxyContent prepares the content of a result of the given tool for the
+// client: resources the server embeds or links are namespaced, images larger
+// than its maxImageDimension are scaled down, and images, audio and binary
+// resources larger than its maxInlineBinaryBytes are saved and linked
+func (r *ServerRegistry) proxyContent(ctx context.Context, serverName, toolName string, result *mcp.CallToolResult) *mcp.CallToolResult {
+	if result == nil {
+		return nil
+	}
+	maxDimension := r.MaxImageDimension(serverName)
+	maxInline := r.MaxInlineBinaryBytes(serverName)
+	for i, content := range result.Content {
+		content = namespaceContent(serverName, content)
+		switch c := content.(type) {
+		case mcp.ImageContent:
+			if maxDimension > 0 {
+				data, mimeType, err := downscaleImage(c.Data, c.MIMEType, maxDimension)
+				if err != nil {
+					slog.DebugContext(ctx, "Failed to scale down image", "error", err)
+				}
+				c.Data, c.MIMEType = data, mimeType
+				content = c
+			}
+			content = r.inlineOrLink(ctx, content, c.Data, c.MIMEType, fmt.Sprintf("Image returned by tool %s on server %s", toolName, serverName), maxInline)
+		case mcp.AudioContent:
+			content = r.inlineOrLink(ctx, content, c.Data, c.MIMEType, fmt.Sprintf("Audio returned by tool %s on server %s", toolName, serverName), maxInline)
+		case mcp.EmbeddedResource:
+			if blob, ok := c.Resource.(mcp.BlobResourceContents); ok {
+				content = r.inlineOrLink(ctx, content, blob.Blob, blob.MIMEType, fmt.Sprintf("Resource %s returned by tool %s", blob.URI, toolName), maxInline)
+			}
+		}
+		result.Content[i] = content
+	}
+	return result
+}
+
+// inlineOrLink returns content as it is if its base64 data is within
+// maxInline bytes once decoded, and otherwise a link to the data, saved
+func (r *ServerRegistry) inlineOrLink(ctx context.Context, content mcp.Content, data, mimeType, description string, maxInline int) mcp.Content {
+	if maxInline == 0 || base64.StdEncoding.DecodedLen(len(data)) <= maxInline {
+		return content
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil || len(raw) <= maxInline {
+		return content
+	}
+	link, err := r.spills.save(fmt.Sprintf("%d bytes of %s", len(raw), mimeType), description, raw, mimeType, true)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to save binary tool result", "error", err)
+		return content
+	}
+	return link
+}
+
+// downscaleImage scales a base64 PNG, JPEG or GIF image down to fit within
+// maxDimension pixels each way, returning it and its MIME type. JPEG images
+// stay JPEG and others become PNG. Images that fit, and those that cannot be
+// decoded, are returned as they are.
+func downscaleImage(data, mimeType string, maxDimension int) (string, string, error) {
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return data, mimeType, fmt.Errorf("invalid base64 image data: %w", err)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return data, mimeType, fmt.Errorf("cannot decode %s image: %w", mimeType, err)
+	}
+	if cfg.Width <= maxDimension && cfg.Height <= maxDimension {
+		return data, mimeType, nil
+	}
+	img, format, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return data, mimeType, fmt.Errorf("cannot decode %s image: %w", mimeType, err)
+	}
+
+	longest := max(cfg.Width, cfg.Height)
+	scaled := scaleImage(img, max(cfg.Width*maxDimension/longest, 1), max(cfg.Height*maxDimension/longest, 1))
+	var buf bytes.Buffer
+	scaledType := "image/png"
+	if format == "jpeg" {
+		scaledType = "image/jpeg"
+		err = jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, scaled)
+	}
+	if err != nil {
+		return data, mimeType, fmt.Errorf("cannot encode scaled image: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), scaledType, nil
+}
+
+// scaleImage resizes an image to the given size, each pixel the average of
+// the pixels it covers
+func scaleImage(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(bounds.Min.Y+(y+1)*bounds.Dy()/height, y0+1)
+		for x := range width {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(bounds.Min.X+(x+1)*bounds.Dx()/width, x0+1)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
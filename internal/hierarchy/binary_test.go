@@ -0,0 +1,113 @@
+package hierarchy
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// pngData returns a base64 PNG image of the given size, white on the left
+// half and black on the right
+func pngData(t *testing.T, width, height int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			if x < width/2 {
+				img.Set(x, y, color.White)
+			} else {
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// newMediaServer returns a server whose screenshot tool returns the given
+// image, audio clip and linked resource
+func newMediaServer(imageData string, audio []byte) *server.MCPServer {
+	media := server.NewMCPServer("media", "1.0.0")
+	media.AddTool(mcp.NewTool("screenshot"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return &mcp.CallToolResult{Content: []mcp.Content{
+			mcp.NewImageContent(imageData, "image/png"),
+			mcp.NewAudioContent(base64.StdEncoding.EncodeToString(audio), "audio/wav"),
+			mcp.NewResourceLink("file:///screens/1.png", "screen", "", "image/png"),
+		}}, nil
+	})
+	return media
+}
+
+// TestProxyContent verifies that images are scaled down to
+// maxImageDimension, that binary content over maxInlineBinaryBytes is linked
+// instead, and that resource links are namespaced.
+func TestProxyContent(t *testing.T) {
+	audio := bytes.Repeat([]byte{1, 2, 3, 4}, 1000)
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"media": {Tools: map[string]*ToolDefinition{"screenshot": {Server: "media"}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"media": {Options: &config.OptionsV2{
+			MaxImageDimension:    optional.NewField(50),
+			MaxInlineBinaryBytes: optional.NewField(1000),
+		}}},
+		map[string]*server.MCPServer{"media": newMediaServer(pngData(t, 200, 100), audio)},
+		nil,
+	)
+	defer registry.Close()
+
+	result, err := h.HandleExecuteTool(context.Background(), registry, "media.screenshot", nil)
+	require.NoError(t, err)
+	require.Len(t, result.Content, 3)
+
+	scaled, ok := result.Content[0].(mcp.ImageContent)
+	require.True(t, ok)
+	raw, err := base64.StdEncoding.DecodeString(scaled.Data)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(raw))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 50, 25), img.Bounds())
+	r, _, _, _ := img.At(10, 10).RGBA()
+	assert.Equal(t, uint32(0xffff), r)
+	r, _, _, _ = img.At(40, 10).RGBA()
+	assert.Zero(t, r)
+
+	link, ok := result.Content[1].(mcp.ResourceLink)
+	require.True(t, ok)
+	assert.Equal(t, "audio/wav", link.MIMEType)
+	contents, err := registry.ReadResult(link.URI)
+	require.NoError(t, err)
+	require.Len(t, contents, 1)
+	blob := contents[0].(mcp.BlobResourceContents)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(audio), blob.Blob)
+
+	assert.Equal(t, ResourceURI("media", "file:///screens/1.png"), result.Content[2].(mcp.ResourceLink).URI)
+}
+
+// TestDownscaleImageLeavesOthers verifies that images that fit, and data
+// that is not an image, are returned as they are.
+func TestDownscaleImageLeavesOthers(t *testing.T) {
+	small := pngData(t, 20, 10)
+	data, mimeType, err := downscaleImage(small, "image/png", 50)
+	require.NoError(t, err)
+	assert.Equal(t, small, data)
+	assert.Equal(t, "image/png", mimeType)
+
+	webp := base64.StdEncoding.EncodeToString([]byte("RIFF....WEBP"))
+	data, mimeType, err = downscaleImage(webp, "image/webp", 50)
+	assert.Error(t, err)
+	assert.Equal(t, webp, data)
+	assert.Equal(t, "image/webp", mimeType)
+}
@@ -0,0 +1,102 @@
+package hierarchy
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// bytesPerToken approximates how much JSON makes up a token for most models
+const bytesPerToken = 4
+
+// estimateTokens estimates how many tokens v takes up as JSON
+func estimateTokens(v interface{}) int {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return (len(data) + bytesPerToken - 1) / bytesPerToken
+}
+
+// SetTokenBudget makes get_tools_in_category list subcategories in full,
+// with the definitions of all their tools, for as long as the listing stays
+// within an estimated tokens. Subcategories whose servers have been called
+// most are listed in full first; the others keep their summaries. Zero
+// always lists summaries.
+func (h *Hierarchy) SetTokenBudget(tokens int) {
+	h.tokenBudget = tokens
+}
+
+// applyTokenBudget adds the tools of the subcategories of the listing of
+// path that fit in the token budget. h.mu must be held.
+func (h *Hierarchy) applyTokenBudget(path string, response map[string]interface{}) {
+	children, _ := response["children"].(map[string]interface{})
+	if h.tokenBudget <= 0 || len(children) == 0 {
+		return
+	}
+
+	type candidate struct {
+		name  string
+		tools map[string]interface{}
+		cost  int
+		calls uint64
+	}
+	var candidates []candidate
+	for name, entry := range children {
+		info, ok := entry.(map[string]interface{})
+		if !ok || info["is_leaf"] == true {
+			continue // The tools of leaves are already listed
+		}
+		childPath := name
+		if path != "" {
+			childPath = path + "." + name
+		}
+		tools, servers := h.subtreeTools(childPath)
+		if len(tools) == 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			name:  name,
+			tools: tools,
+			cost:  estimateTokens(map[string]interface{}{"tools": tools}),
+			calls: h.usage.serverCalls(servers),
+		})
+	}
+	// Most used first; among equally used ones, the cheapest first so that as
+	// many as possible are listed in full
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].calls != candidates[j].calls {
+			return candidates[i].calls > candidates[j].calls
+		}
+		if candidates[i].cost != candidates[j].cost {
+			return candidates[i].cost < candidates[j].cost
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	used := estimateTokens(response)
+	for _, c := range candidates {
+		if used+c.cost > h.tokenBudget {
+			continue // A cheaper one may still fit
+		}
+		children[c.name].(map[string]interface{})["tools"] = c.tools
+		used += c.cost
+	}
+}
+
+// subtreeTools returns the full definitions of the tools in the category at
+// path and below, keyed by their paths relative to it, and the servers that
+// provide them. h.mu must be held.
+func (h *Hierarchy) subtreeTools(path string) (map[string]interface{}, map[string]bool) {
+	tools := make(map[string]interface{})
+	servers := make(map[string]bool)
+	for _, tool := range h.subtreeToolMatches(path) {
+		info := tool.tool.info()
+		if len(tool.tool.schema) > 0 {
+			info["inputSchema"] = tool.tool.schema
+		}
+		tools[strings.TrimPrefix(tool.tool.path, path+".")] = info
+		servers[tool.def.Server] = true
+	}
+	return tools, servers
+}
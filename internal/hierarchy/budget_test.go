@@ -0,0 +1,79 @@
+package hierarchy
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTokenBudgetListsMostUsedCategoriesInFull verifies that a category
+// listing includes the tools of as many subcategories as fit in the token
+// budget, the most used first, and leaves the others as summaries.
+func TestTokenBudgetListsMostUsedCategoriesInFull(t *testing.T) {
+	nodes := map[string]*HierarchyNode{
+		"":      {},
+		"big":   {Overview: "big: 40 tools"},
+		"gmail": {Overview: "gmail: 1 tool"},
+		"gmail.send_email": {Tools: map[string]*ToolDefinition{
+			"send_email": {Server: "gmail", Description: "Sends an email.", InputSchema: map[string]interface{}{"type": "object"}},
+		}},
+		"trello": {Overview: "trello: 1 tool"},
+		"trello.create_card": {Tools: map[string]*ToolDefinition{
+			"create_card": {Server: "trello", Description: "Creates a card."},
+		}},
+	}
+	for i := 0; i < 40; i++ {
+		name := fmt.Sprintf("tool_%d", i)
+		nodes["big."+name] = &HierarchyNode{Tools: map[string]*ToolDefinition{
+			name: {Server: "big", Description: strings.Repeat("Does something at length. ", 10)},
+		}}
+	}
+	h := &Hierarchy{nodes: nodes}
+
+	summary, err := h.HandleGetToolsInCategory("")
+	require.NoError(t, err)
+	cost := func(path string) int {
+		tools, _ := h.subtreeTools(path)
+		return estimateTokens(map[string]interface{}{"tools": tools})
+	}
+	base := estimateTokens(summary)
+	require.Greater(t, cost("big"), cost("gmail")+cost("trello"))
+
+	listedInFull := func() []string {
+		response, err := h.HandleGetToolsInCategory("")
+		require.NoError(t, err)
+		var names []string
+		for name, child := range response["children"].(map[string]interface{}) {
+			if _, ok := child.(map[string]interface{})["tools"]; ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+
+	// Room for both small servers but not the big one
+	h.SetTokenBudget(base + cost("gmail") + cost("trello") + 10)
+	assert.ElementsMatch(t, []string{"gmail", "trello"}, listedInFull())
+
+	// Room for one: the most used wins
+	h.SetTokenBudget(base + max(cost("gmail"), cost("trello")) + 1)
+	h.usage.record("trello.create_card", "trello")
+	assert.Equal(t, []string{"trello"}, listedInFull())
+	h.usage.record("gmail.send_email", "gmail")
+	h.usage.record("gmail.send_email", "gmail")
+	assert.Equal(t, []string{"gmail"}, listedInFull())
+
+	response, err := h.HandleGetToolsInCategory("")
+	require.NoError(t, err)
+	gmail := response["children"].(map[string]interface{})["gmail"].(map[string]interface{})
+	tool := gmail["tools"].(map[string]interface{})["send_email"].(map[string]interface{})
+	assert.Equal(t, "gmail.send_email", tool["tool_path"])
+	assert.Equal(t, map[string]interface{}{"type": "object"}, tool["inputSchema"])
+	assert.Equal(t, "gmail: 1 tool", gmail["overview"], "the summary is kept")
+
+	h.SetTokenBudget(base)
+	assert.Empty(t, listedInFull())
+}
@@ -0,0 +1,139 @@
+package hierarchy
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxCachedResults bounds the result cache; once it is full of unexpired
+// entries, new results are not cached
+const maxCachedResults = 1024
+
+// resultCache holds tool results for repeated identical calls
+type resultCache struct {
+	mu      sync.Mutex
+	entries map[resultKey]cachedResult
+	now     func() time.Time
+}
+
+// resultKey identifies a call: the arguments are canonical JSON, as
+// encoding/json sorts map keys
+type resultKey struct {
+	server    string
+	tool      string
+	arguments string
+}
+
+type cachedResult struct {
+	result  *mcp.CallToolResult
+	expires time.Time
+}
+
+func newResultCache() *resultCache {
+	return &resultCache{entries: make(map[resultKey]cachedResult), now: time.Now}
+}
+
+func newResultKey(serverName, toolName string, arguments map[string]interface{}) (resultKey, bool) {
+	data, err := json.Marshal(arguments)
+	if err != nil {
+		return resultKey{}, false
+	}
+	return resultKey{server: serverName, tool: toolName, arguments: string(data)}, true
+}
+
+func (c *resultCache) get(key resultKey) (*mcp.CallToolResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.result, true
+}
+
+func (c *resultCache) put(key resultKey, result *mcp.CallToolResult, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if len(c.entries) >= maxCachedResults {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCachedResults {
+			return
+		}
+	}
+	c.entries[key] = cachedResult{result: result, expires: now.Add(ttl)}
+}
+
+// forget drops the cached results of the given server
+func (c *resultCache) forget(serverName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key.server == serverName {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// CacheTTL returns how long results of the given tool may be served from the
+// cache, per the server's cacheTTL option: the tool's own entry, else "*".
+// Zero means the tool is not cached.
+func (r *ServerRegistry) CacheTTL(serverName, toolName string) time.Duration {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil {
+		return 0
+	}
+	ttl, ok := cfg.Options.CacheTTL[toolName]
+	if !ok {
+		ttl = cfg.Options.CacheTTL["*"]
+	}
+	return ttl.Std()
+}
+
+// CachedResult returns a cached result of an identical earlier call, if one
+// has not expired
+func (r *ServerRegistry) CachedResult(serverName, toolName string, arguments map[string]interface{}) (*mcp.CallToolResult, bool) {
+	if r.CacheTTL(serverName, toolName) <= 0 {
+		return nil, false
+	}
+	key, ok := newResultKey(serverName, toolName, arguments)
+	if !ok {
+		return nil, false
+	}
+	return r.results.get(key)
+}
+
+// CacheResult stores a successful result for the tool's cacheTTL. Error
+// results are not stored, nor are those of tools the server annotates as
+// destructive or does not list at all.
+func (r *ServerRegistry) CacheResult(ctx context.Context, serverName, toolName string, arguments map[string]interface{}, result *mcp.CallToolResult) {
+	ttl := r.CacheTTL(serverName, toolName)
+	if ttl <= 0 || result == nil || result.IsError {
+		return
+	}
+	if !r.isCacheable(ctx, serverName, toolName) {
+		return
+	}
+	if key, ok := newResultKey(serverName, toolName, arguments); ok {
+		r.results.put(key, result, ttl)
+	}
+}
+
+// isCacheable reports whether the server lists the tool with annotations
+// hinting it is not destructive
+func (r *ServerRegistry) isCacheable(ctx context.Context, serverName, toolName string) bool {
+	hints, listed := r.toolHints(ctx, serverName, toolName)
+	return listed && !hints.destructive
+}
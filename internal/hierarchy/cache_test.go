@@ -0,0 +1,66 @@
+package hierarchy
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestResultCacheSkipsDestructiveTools verifies that repeated identical calls
+// to a read-only tool are served from the cache until the TTL passes, while a
+// destructive tool always reaches the server.
+func TestResultCacheSkipsDestructiveTools(t *testing.T) {
+	var lookups, deletes int32
+	mcpServer := server.NewMCPServer("docs", "1.0.0")
+	mcpServer.AddTool(mcp.NewTool("lookup", mcp.WithReadOnlyHintAnnotation(true)), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		atomic.AddInt32(&lookups, 1)
+		return mcp.NewToolResultText("schema"), nil
+	})
+	// mcp.NewTool marks tools destructive unless told otherwise
+	mcpServer.AddTool(mcp.NewTool("delete"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		atomic.AddInt32(&deletes, 1)
+		return mcp.NewToolResultText("deleted"), nil
+	})
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"lookup": {Server: "docs"}, "delete": {Server: "docs"}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"docs": {Options: &config.OptionsV2{
+			CacheTTL: map[string]config.Duration{"*": config.Duration(time.Minute)},
+		}}},
+		map[string]*server.MCPServer{"docs": mcpServer},
+		nil,
+	)
+	defer registry.Close()
+	now := time.Now()
+	registry.results.now = func() time.Time { return now }
+
+	call := func(toolPath string, arguments map[string]interface{}) {
+		result, err := h.HandleExecuteTool(context.Background(), registry, toolPath, arguments)
+		require.NoError(t, err)
+		require.False(t, result.IsError)
+	}
+
+	call("lookup", map[string]interface{}{"table": "users", "verbose": true})
+	call("lookup", map[string]interface{}{"verbose": true, "table": "users"})
+	assert.Equal(t, int32(1), atomic.LoadInt32(&lookups), "identical arguments should hit the cache")
+
+	call("lookup", map[string]interface{}{"table": "orders"})
+	assert.Equal(t, int32(2), atomic.LoadInt32(&lookups), "different arguments should miss")
+
+	now = now.Add(2 * time.Minute)
+	call("lookup", map[string]interface{}{"table": "users", "verbose": true})
+	assert.Equal(t, int32(3), atomic.LoadInt32(&lookups), "expired results should miss")
+
+	call("delete", map[string]interface{}{"id": 1})
+	call("delete", map[string]interface{}{"id": 1})
+	assert.Equal(t, int32(2), atomic.LoadInt32(&deletes), "destructive tools must not be cached")
+}
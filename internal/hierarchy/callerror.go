@@ -0,0 +1,119 @@
+package hierarchy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/client"
+)
+
+// ErrorClass says what kind of failure a CallError is
+type ErrorClass string
+
+const (
+	ErrorClassUnavailable  ErrorClass = "server_unavailable" // The server could not be started or reached
+	ErrorClassConnection   ErrorClass = "connection_lost"    // The server crashed or dropped the connection mid-call
+	ErrorClassTransport    ErrorClass = "transport_error"    // The request or its response was lost in transit
+	ErrorClassBusy         ErrorClass = "server_busy"        // No call slot came free in time
+	ErrorClassCircuitOpen  ErrorClass = "circuit_open"       // The server keeps failing, so it is not called
+	ErrorClassShuttingDown ErrorClass = "shutting_down"      // lazy-mcp is shutting down
+	ErrorClassServer       ErrorClass = "server_error"       // The server answered with a JSON-RPC error
+)
+
+// CallError is returned for a tool call that failed on the way to its server
+// or was refused by it, saying which server, what kind of failure and whether
+// the call may succeed if made again, in place of the bare "EOF" or "broken
+// pipe" of the underlying error.
+type CallError struct {
+	Server    string
+	Tool      string
+	Class     ErrorClass
+	Code      int  // The JSON-RPC error code of server errors, where known
+	Retryable bool // Whether making the call again may succeed
+	Err       error
+}
+
+func (e *CallError) Error() string {
+	var what string
+	switch e.Class {
+	case ErrorClassUnavailable:
+		what = fmt.Sprintf("server %s could not be started or reached", e.Server)
+	case ErrorClassConnection:
+		what = fmt.Sprintf("server %s crashed or dropped the connection during the call", e.Server)
+	case ErrorClassTransport:
+		what = fmt.Sprintf("the call to server %s was lost in transit", e.Server)
+	case ErrorClassBusy:
+		what = fmt.Sprintf("server %s is busy with other calls", e.Server)
+	case ErrorClassCircuitOpen:
+		what = fmt.Sprintf("server %s is failing", e.Server)
+	case ErrorClassShuttingDown:
+		what = fmt.Sprintf("server %s was not called", e.Server)
+	default:
+		what = fmt.Sprintf("server %s returned an error", e.Server)
+	}
+	hint := "retrying will not help"
+	if e.Retryable {
+		hint = "retrying may succeed"
+	}
+	return fmt.Sprintf("tool %s failed: %s (%s): %v", e.Tool, what, hint, e.Err)
+}
+
+func (e *CallError) Unwrap() error {
+	return e.Err
+}
+
+// newCallError classifies err, which a call to the given tool failed with:
+// class is ErrorClassUnavailable for failures to get the server's client and
+// ErrorClassServer for those of the call itself
+func newCallError(serverName, toolName string, err error, class ErrorClass) *CallError {
+	callErr := &CallError{Server: serverName, Tool: toolName, Class: class, Err: err}
+	switch {
+	case errors.Is(err, ErrShuttingDown):
+		callErr.Class = ErrorClassShuttingDown
+	case errors.Is(err, ErrCircuitOpen):
+		callErr.Class = ErrorClassCircuitOpen
+		callErr.Retryable = true // Once the circuit lets calls through again
+	case errors.Is(err, ErrLockTimeout):
+		callErr.Class = ErrorClassBusy
+		callErr.Retryable = true
+	case class == ErrorClassUnavailable:
+		// A server that failed to start, crashed too often or was disabled
+		// stays that way until someone intervenes
+	case errors.Is(err, client.ErrConnectionLost):
+		// The server is restarted for the next call
+		callErr.Class = ErrorClassConnection
+		callErr.Retryable = true
+	case isTransportError(err):
+		callErr.Class = ErrorClassTransport
+		callErr.Retryable = true
+	default:
+		callErr.Class = ErrorClassServer
+		callErr.Code = jsonRPCCode(err)
+		callErr.Retryable = callErr.Code == mcp.REQUEST_INTERRUPTED
+	}
+	return callErr
+}
+
+// jsonRPCCode returns the JSON-RPC error code of an error a server answered
+// with, or zero if the client did not keep it
+func jsonRPCCode(err error) int {
+	codes := []struct {
+		sentinel error
+		code     int
+	}{
+		{mcp.ErrParseError, mcp.PARSE_ERROR},
+		{mcp.ErrInvalidRequest, mcp.INVALID_REQUEST},
+		{mcp.ErrMethodNotFound, mcp.METHOD_NOT_FOUND},
+		{mcp.ErrInvalidParams, mcp.INVALID_PARAMS},
+		{mcp.ErrInternalError, mcp.INTERNAL_ERROR},
+		{mcp.ErrRequestInterrupted, mcp.REQUEST_INTERRUPTED},
+		{mcp.ErrResourceNotFound, mcp.RESOURCE_NOT_FOUND},
+	}
+	for _, c := range codes {
+		if errors.Is(err, c.sentinel) {
+			return c.code
+		}
+	}
+	return 0
+}
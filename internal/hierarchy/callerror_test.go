@@ -0,0 +1,75 @@
+package hierarchy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/client"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestNewCallErrorClassifiesFailures verifies the class and retryability
+// given to the ways a call can fail.
+func TestNewCallErrorClassifiesFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		class     ErrorClass
+		want      ErrorClass
+		retryable bool
+	}{
+		{"crash", fmt.Errorf("%w: EOF", client.ErrConnectionLost), ErrorClassServer, ErrorClassConnection, true},
+		{"broken pipe", &transport.Error{Err: errors.New("write |1: broken pipe")}, ErrorClassServer, ErrorClassTransport, true},
+		{"busy", fmt.Errorf("%w: server echo", ErrLockTimeout), ErrorClassServer, ErrorClassBusy, true},
+		{"circuit", fmt.Errorf("%w: server echo is failing", ErrCircuitOpen), ErrorClassServer, ErrorClassCircuitOpen, true},
+		{"shutdown", ErrShuttingDown, ErrorClassUnavailable, ErrorClassShuttingDown, false},
+		{"start", errors.New("exec: \"echo-server\": executable file not found"), ErrorClassUnavailable, ErrorClassUnavailable, false},
+		{"crashed on start", fmt.Errorf("%w: EOF", client.ErrConnectionLost), ErrorClassUnavailable, ErrorClassUnavailable, false},
+		{"invalid params", fmt.Errorf("%w: missing message", mcp.ErrInvalidParams), ErrorClassServer, ErrorClassServer, false},
+		{"interrupted", mcp.ErrRequestInterrupted, ErrorClassServer, ErrorClassServer, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callErr := newCallError("echo", "echo", tt.err, tt.class)
+			assert.Equal(t, tt.want, callErr.Class)
+			assert.Equal(t, tt.retryable, callErr.Retryable)
+			assert.ErrorIs(t, callErr, tt.err)
+			assert.Contains(t, callErr.Error(), "server echo")
+		})
+	}
+	assert.Equal(t, mcp.INVALID_PARAMS, newCallError("echo", "echo", mcp.ErrInvalidParams, ErrorClassServer).Code)
+}
+
+// TestCallErrorNamesServer verifies that a call a server fails is reported
+// with the server's name and a retry hint rather than the bare error.
+func TestCallErrorNamesServer(t *testing.T) {
+	failing := server.NewMCPServer("failing", "1.0.0")
+	failing.AddTool(mcp.NewTool("fail"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return nil, errors.New("boom")
+	})
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"fail": {Server: "failing"}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"failing": {}},
+		map[string]*server.MCPServer{"failing": failing},
+		nil,
+	)
+	defer registry.Close()
+
+	_, err := h.HandleExecuteTool(context.Background(), registry, "fail", nil)
+	var callErr *CallError
+	require.ErrorAs(t, err, &callErr)
+	assert.Equal(t, "failing", callErr.Server)
+	assert.Equal(t, ErrorClassServer, callErr.Class)
+	assert.False(t, callErr.Retryable)
+	assert.Contains(t, err.Error(), "server failing returned an error (retrying will not help): ")
+	assert.Contains(t, err.Error(), "boom")
+}
@@ -0,0 +1,88 @@
+package hierarchy
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoCaller is returned when a server sends a request that must be answered
+// by a connected client while no client request to it is in flight
+var ErrNoCaller = errors.New("no client request in flight")
+
+// caller is a client request holding one of a server's call slots
+type caller struct {
+	ctx context.Context
+}
+
+// trackCaller records that ctx holds a call slot of the given server until
+// the returned function is called
+func (r *ServerRegistry) trackCaller(serverName string, ctx context.Context) func() {
+	c := &caller{ctx: ctx}
+	r.mu.Lock()
+	r.callers[serverName] = append(r.callers[serverName], c)
+	r.lastCallers[serverName] = ctx
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		callers := r.callers[serverName]
+		for i, other := range callers {
+			if other == c {
+				r.callers[serverName] = append(callers[:i:i], callers[i+1:]...)
+				break
+			}
+		}
+		if len(r.callers[serverName]) == 0 {
+			delete(r.callers, serverName)
+		}
+	}
+}
+
+// rememberCaller records ctx as the latest client request to reach the given
+// server without taking a call slot, e.g. the one that started it
+func (r *ServerRegistry) rememberCaller(serverName string, ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastCallers[serverName] = ctx
+}
+
+// currentCaller returns the context of the most recent client request in
+// flight to the given server. With maxConcurrent at 1 there is only ever one.
+func (r *ServerRegistry) currentCaller(serverName string) (context.Context, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	callers := r.callers[serverName]
+	if len(callers) == 0 {
+		return nil, false
+	}
+	return callers[len(callers)-1].ctx, true
+}
+
+// lastCaller is like currentCaller, but falls back to the latest client
+// request to reach the server if none is in flight. That request may be
+// finished, so its context is stripped of its cancellation.
+func (r *ServerRegistry) lastCaller(serverName string) (context.Context, bool) {
+	if ctx, ok := r.currentCaller(serverName); ok {
+		return ctx, true
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ctx, ok := r.lastCallers[serverName]
+	if !ok {
+		return nil, false
+	}
+	return context.WithoutCancel(ctx), true
+}
+
+// upstreamContext derives the context of a request forwarded to a client on
+// behalf of a server: callerCtx carries the session the request is sent to,
+// and the server giving up on its own request, serverCtx, cancels it
+func upstreamContext(callerCtx, serverCtx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(callerCtx)
+	stop := context.AfterFunc(serverCtx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
@@ -0,0 +1,136 @@
+package hierarchy
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// callQueue bounds the tool calls in flight across all servers. Calls that
+// find no free slot queue per server, first in first out, and each freed
+// slot goes to the next server with calls queued, in turn, so that a burst of
+// calls to one server cannot hold up those to the others.
+type callQueue struct {
+	limit int
+
+	mu     sync.Mutex
+	inUse  int
+	queues map[string][]chan struct{} // Calls waiting, per server
+	turns  []string                   // Servers with calls waiting, next first
+}
+
+func newCallQueue(limit int) *callQueue {
+	return &callQueue{limit: limit, queues: make(map[string][]chan struct{})}
+}
+
+// acquire blocks until a slot is free for a call to the given server or ctx
+// is done. A nil queue has no limit.
+func (q *callQueue) acquire(ctx context.Context, serverName string) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	if q.inUse < q.limit && len(q.turns) == 0 {
+		q.inUse++
+		q.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	if len(q.queues[serverName]) == 0 {
+		q.turns = append(q.turns, serverName)
+	}
+	q.queues[serverName] = append(q.queues[serverName], ready)
+	q.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+	q.mu.Lock()
+	select {
+	case <-ready:
+		// Handed a slot just as ctx ended: pass it on
+		q.mu.Unlock()
+		q.release()
+		return ctx.Err()
+	default:
+	}
+	q.dequeueLocked(serverName, ready)
+	q.mu.Unlock()
+	return ctx.Err()
+}
+
+// release frees a slot, handing it to the next call queued if any
+func (q *callQueue) release() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.turns) == 0 {
+		q.inUse--
+		return
+	}
+	serverName := q.turns[0]
+	q.turns = q.turns[1:]
+	ready := q.queues[serverName][0]
+	q.dequeueLocked(serverName, ready)
+	close(ready)
+}
+
+// dequeueLocked removes a call from its server's queue; the server's turn
+// moves to the back while it has calls left. The caller must hold q.mu.
+func (q *callQueue) dequeueLocked(serverName string, ready chan struct{}) {
+	queue := q.queues[serverName]
+	for i, queued := range queue {
+		if queued == ready {
+			queue = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+	for i, turn := range q.turns {
+		if turn == serverName {
+			q.turns = append(q.turns[:i:i], q.turns[i+1:]...)
+			break
+		}
+	}
+	if len(queue) == 0 {
+		delete(q.queues, serverName)
+		return
+	}
+	q.queues[serverName] = queue
+	q.turns = append(q.turns, serverName)
+}
+
+// queueWaitWindow is how many of a server's latest calls its queue wait is
+// reported over
+const queueWaitWindow = 100
+
+// queueWaits are how long a server's latest calls waited for a slot
+type queueWaits struct {
+	waits []time.Duration
+	next  int // Where the next wait goes once the window is full
+}
+
+func (w *queueWaits) record(wait time.Duration) {
+	if len(w.waits) < queueWaitWindow {
+		w.waits = append(w.waits, wait)
+		return
+	}
+	w.waits[w.next] = wait
+	w.next = (w.next + 1) % queueWaitWindow
+}
+
+// summary returns the average and longest of the waits
+func (w *queueWaits) summary() (average, longest time.Duration) {
+	if len(w.waits) == 0 {
+		return 0, 0
+	}
+	var total time.Duration
+	for _, wait := range w.waits {
+		total += wait
+		longest = max(longest, wait)
+	}
+	return total / time.Duration(len(w.waits)), longest
+}
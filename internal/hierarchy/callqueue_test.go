@@ -0,0 +1,146 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// waiting returns how many calls are queued
+func (q *callQueue) waiting() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	for _, queue := range q.queues {
+		n += len(queue)
+	}
+	return n
+}
+
+// queueCalls queues calls to the given servers in order, each sending its
+// label to admitted once it has a slot
+func queueCalls(t *testing.T, q *callQueue, admitted chan<- string, calls ...[2]string) {
+	t.Helper()
+	for _, call := range calls {
+		waiting := q.waiting()
+		go func() {
+			if err := q.acquire(context.Background(), call[0]); err == nil {
+				admitted <- call[1]
+			}
+		}()
+		require.Eventually(t, func() bool { return q.waiting() == waiting+1 }, time.Second, time.Millisecond)
+	}
+}
+
+// TestCallQueueTakesTurnsBetweenServers verifies that freed slots go to the
+// servers with calls queued in turn, and to each server's calls in order.
+func TestCallQueueTakesTurnsBetweenServers(t *testing.T) {
+	q := newCallQueue(1)
+	require.NoError(t, q.acquire(context.Background(), "slow"))
+
+	admitted := make(chan string, 4)
+	queueCalls(t, q, admitted,
+		[2]string{"slow", "slow 1"},
+		[2]string{"slow", "slow 2"},
+		[2]string{"slow", "slow 3"},
+		[2]string{"fast", "fast 1"},
+	)
+
+	var order []string
+	for range 4 {
+		q.release()
+		order = append(order, <-admitted)
+	}
+	assert.Equal(t, []string{"slow 1", "fast 1", "slow 2", "slow 3"}, order)
+
+	q.release()
+	assert.Zero(t, q.inUse)
+	assert.Empty(t, q.queues)
+	assert.Empty(t, q.turns)
+}
+
+// TestCallQueueGivesUpWithContext verifies that a call that gives up waiting
+// leaves the queue, and that a slot is not lost to it.
+func TestCallQueueGivesUpWithContext(t *testing.T) {
+	q := newCallQueue(1)
+	require.NoError(t, q.acquire(context.Background(), "echo"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.acquire(ctx, "echo"), context.DeadlineExceeded)
+	assert.Zero(t, q.waiting())
+	assert.Empty(t, q.turns)
+
+	q.release()
+	require.NoError(t, q.acquire(context.Background(), "echo"))
+	q.release()
+	assert.Zero(t, q.inUse)
+}
+
+// TestNilCallQueueIsUnbounded verifies that without a limit, slots are never
+// waited for.
+func TestNilCallQueueIsUnbounded(t *testing.T) {
+	var q *callQueue
+	for range 100 {
+		require.NoError(t, q.acquire(context.Background(), "echo"))
+	}
+	q.release()
+}
+
+// TestQueueWaitsSummarizeLatestCalls verifies that queue waits are reported
+// over the latest calls only.
+func TestQueueWaitsSummarizeLatestCalls(t *testing.T) {
+	var w queueWaits
+	average, longest := w.summary()
+	assert.Zero(t, average)
+	assert.Zero(t, longest)
+
+	w.record(time.Hour)
+	for range queueWaitWindow - 1 {
+		w.record(time.Second)
+	}
+	average, longest = w.summary()
+	assert.Equal(t, time.Hour, longest)
+	assert.Greater(t, average, time.Second)
+
+	w.record(time.Second)
+	average, longest = w.summary()
+	assert.Equal(t, time.Second, average)
+	assert.Equal(t, time.Second, longest)
+}
+
+// TestCallLimitSpansServers verifies that SetCallLimit bounds the calls in
+// flight across servers, and that the time calls queued is reported.
+func TestCallLimitSpansServers(t *testing.T) {
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{"a": {}, "b": {}})
+	defer registry.Close()
+	registry.SetCallLimit(1)
+
+	release, err := registry.AcquireSlot(context.Background(), "a")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = registry.AcquireSlot(ctx, "b")
+	assert.ErrorIs(t, err, ErrLockTimeout)
+
+	acquired := make(chan func())
+	go func() {
+		releaseB, err := registry.AcquireSlot(context.Background(), "b")
+		if err == nil {
+			acquired <- releaseB
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+	release()
+	(<-acquired)()
+
+	status, err := registry.ServerStatus("b")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, status.QueueWait.Std(), 25*time.Millisecond)
+	assert.Equal(t, status.QueueWait, status.MaxQueueWait)
+}
@@ -0,0 +1,73 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/client"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestCancelledCallFreesTheServer verifies that cancelling a call returns at
+// once, frees the server's slot and tells the server to stop.
+func TestCancelledCallFreesTheServer(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	cancelled := make(chan mcp.JSONRPCNotification, 1)
+	slow := server.NewMCPServer("slow", "1.0.0")
+	slow.AddNotificationHandler(client.MethodNotificationCancelled, func(ctx context.Context, notification mcp.JSONRPCNotification) {
+		cancelled <- notification
+	})
+	slow.AddTool(mcp.NewTool("build"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		started <- struct{}{}
+		<-release
+		return mcp.NewToolResultText("built"), nil
+	})
+	// In-process calls cannot be abandoned, so use SSE
+	sseServer := server.NewTestServer(slow)
+	defer sseServer.Close()
+	defer close(release)
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"build": {Server: "slow"}}},
+	}}
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"slow": {Type: config.MCPClientTypeSSE, URL: sseServer.URL + "/sse", Options: &config.OptionsV2{}},
+	})
+	defer registry.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := h.HandleExecuteTool(ctx, registry, "build", nil)
+		done <- err
+	}()
+
+	<-started
+	cancel()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled call did not return")
+	}
+
+	acquireCtx, cancelAcquire := context.WithTimeout(context.Background(), time.Second)
+	defer cancelAcquire()
+	releaseSlot, err := registry.AcquireSlot(acquireCtx, "slow")
+	require.NoError(t, err, "the slot is freed when the call is cancelled")
+	releaseSlot()
+
+	select {
+	case notification := <-cancelled:
+		assert.NotNil(t, notification.Params.AdditionalFields["requestId"])
+		assert.Equal(t, context.Canceled.Error(), notification.Params.AdditionalFields["reason"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("server was not told that the call was cancelled")
+	}
+}
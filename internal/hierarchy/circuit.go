@@ -0,0 +1,111 @@
+package hierarchy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/client"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// ErrCircuitOpen is returned without calling a server whose circuit breaker
+// is open after repeated failures
+var ErrCircuitOpen = errors.New("MCP server unavailable")
+
+const (
+	defaultCircuitFailureThreshold = 5
+	defaultCircuitOpenDuration     = 30 * time.Second
+)
+
+// circuit counts the consecutive failed calls to a server. Once open, calls
+// fail fast until openUntil, when one is let through to test the server.
+type circuit struct {
+	failures  int
+	openUntil time.Time // Zero while closed
+}
+
+// circuitSettings returns the failure threshold of the given server's circuit
+// breaker, zero if it is disabled, and how long the circuit stays open
+func (r *ServerRegistry) circuitSettings(serverName string) (int, time.Duration) {
+	threshold, openDuration := defaultCircuitFailureThreshold, defaultCircuitOpenDuration
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil || cfg.Options.CircuitBreaker == nil {
+		return threshold, openDuration
+	}
+	breaker := cfg.Options.CircuitBreaker
+	threshold = max(breaker.FailureThreshold.OrElse(threshold), 0)
+	if breaker.OpenDuration > 0 {
+		openDuration = breaker.OpenDuration.Std()
+	}
+	return threshold, openDuration
+}
+
+// checkCircuit fails with ErrCircuitOpen if calls to the given server should
+// not be made. When the circuit has been open for long enough, the call is
+// let through as a test and others keep failing until it completes.
+func (r *ServerRegistry) checkCircuit(serverName string) error {
+	_, openDuration := r.circuitSettings(serverName)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, exists := r.circuits[serverName]
+	if !exists || state.openUntil.IsZero() {
+		return nil
+	}
+	if now := time.Now(); !now.Before(state.openUntil) {
+		state.openUntil = now.Add(openDuration)
+		return nil
+	}
+	return fmt.Errorf("%w: server %s is failing, retrying at %s", ErrCircuitOpen, serverName, state.openUntil.Format(time.RFC3339))
+}
+
+// recordCircuit records whether a call to the given server failed because the
+// server was unavailable, opening its circuit after too many such failures in
+// a row and closing it when a call gets through.
+func (r *ServerRegistry) recordCircuit(serverName string, unavailable bool) {
+	threshold, openDuration := r.circuitSettings(serverName)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, exists := r.circuits[serverName]
+	if !unavailable {
+		if exists && !state.openUntil.IsZero() {
+			logging.ForServer(serverName).Info("Closing circuit breaker", "failures", state.failures)
+		}
+		delete(r.circuits, serverName)
+		return
+	}
+	if threshold == 0 {
+		return
+	}
+	if !exists {
+		state = &circuit{}
+		r.circuits[serverName] = state
+	}
+	state.failures++
+	if state.failures >= threshold {
+		state.openUntil = time.Now().Add(openDuration)
+		r.publish(context.Background(), Event{Type: EventServerUnhealthy, Server: serverName, Reason: ReasonCircuitOpen, Err: fmt.Errorf("%d calls in a row failed, failing fast until %s", state.failures, state.openUntil.Format(time.RFC3339))})
+	}
+}
+
+// isUnavailable reports whether a call failed because the server could not
+// be reached or did not answer in time, rather than answering with an error
+func isUnavailable(err error) bool {
+	return errors.Is(err, client.ErrConnectionLost) ||
+		errors.Is(err, ErrCallTimeout) ||
+		isTransportError(err)
+}
+
+// circuitOpenUntil returns when the given server's circuit lets a call through
+// again, or the zero time if it is closed. The caller must hold r.mu.
+func (r *ServerRegistry) circuitOpenUntil(serverName string) time.Time {
+	if state, exists := r.circuits[serverName]; exists {
+		return state.openUntil
+	}
+	return time.Time{}
+}
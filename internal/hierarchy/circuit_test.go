@@ -0,0 +1,67 @@
+package hierarchy
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestCircuitOpensAfterRepeatedFailures verifies that calls to a server fail
+// fast once enough calls in a row failed, and that a call let through after
+// openDuration closes the circuit again.
+func TestCircuitOpensAfterRepeatedFailures(t *testing.T) {
+	var calls atomic.Int32
+	var hanging atomic.Bool
+	hanging.Store(true)
+	flaky := server.NewMCPServer("flaky", "1.0.0")
+	flaky.AddTool(mcp.NewTool("query"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		calls.Add(1)
+		if hanging.Load() {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"query": {Server: "flaky", Timeout: config.Duration(20 * time.Millisecond)}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"flaky": {Options: &config.OptionsV2{CircuitBreaker: &config.CircuitBreakerConfig{
+				FailureThreshold: optional.NewField(2),
+				OpenDuration:     config.Duration(100 * time.Millisecond),
+			}}},
+		},
+		map[string]*server.MCPServer{"flaky": flaky},
+		nil,
+	)
+	defer registry.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, err := h.HandleExecuteTool(ctx, registry, "query", nil)
+		require.ErrorIs(t, err, ErrCallTimeout)
+	}
+
+	_, err := h.HandleExecuteTool(ctx, registry, "query", nil)
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Contains(t, err.Error(), "retrying at")
+	assert.Equal(t, int32(2), calls.Load(), "the server is not called while the circuit is open")
+	assert.False(t, registry.IsHealthy("flaky"))
+
+	hanging.Store(false)
+	time.Sleep(150 * time.Millisecond)
+	result, err := h.HandleExecuteTool(ctx, registry, "query", nil)
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+	assert.True(t, registry.IsHealthy("flaky"), "a call getting through closes the circuit")
+}
@@ -0,0 +1,71 @@
+package hierarchy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Types of the references of completion requests
+const (
+	refPrompt   = "ref/prompt"
+	refResource = "ref/resource"
+)
+
+// completionRef is what a completion request refers to: a prompt by its
+// name, or a resource by its URI or URI template
+type completionRef struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	URI  string `json:"uri,omitempty"`
+}
+
+// Complete forwards a request to complete an argument of a proxied prompt or
+// resource template to the server that owns it, starting the server if
+// needed. The request refers to the prompt by its namespaced name, or to the
+// resource by its namespaced URI or URI template. Like a tool call, it holds
+// one of the server's call slots.
+func (r *ServerRegistry) Complete(ctx context.Context, params mcp.CompleteParams) (*mcp.CompleteResult, error) {
+	// As decoded from JSON, or as built by callers
+	data, err := json.Marshal(params.Ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid completion reference: %w", err)
+	}
+	var ref completionRef
+	if err := json.Unmarshal(data, &ref); err != nil {
+		return nil, fmt.Errorf("invalid completion reference: %w", err)
+	}
+
+	var serverName string
+	switch ref.Type {
+	case refPrompt:
+		serverName, ref.Name, err = r.parsePromptName(ref.Name)
+		params.Ref = mcp.PromptReference{Type: ref.Type, Name: ref.Name}
+	case refResource:
+		serverName, ref.URI, err = ParseResourceURI(ref.URI)
+		params.Ref = mcp.ResourceReference{Type: ref.Type, URI: ref.URI}
+	default:
+		err = fmt.Errorf("unknown completion reference type %q", ref.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mcpClient, err := r.GetOrLoadServer(ctx, serverName)
+	if err != nil {
+		return nil, err
+	}
+	var result *mcp.CompleteResult
+	err = r.WithClientLock(ctx, serverName, func(ctx context.Context) error {
+		request := mcp.CompleteRequest{Params: params}
+		var err error
+		result, err = mcpClient.GetClient().Complete(ctx, request)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete argument %s on server %s: %w", params.Argument.Name, serverName, err)
+	}
+	return result, nil
+}
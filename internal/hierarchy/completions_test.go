@@ -0,0 +1,43 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestCompleteRoutesByReference verifies that completion requests are routed
+// to the server their prompt or resource reference names, and that those
+// naming no server fail without starting any.
+func TestCompleteRoutesByReference(t *testing.T) {
+	var launches int32
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"echo": {}},
+		map[string]*server.MCPServer{"echo": newEchoServer()},
+		&launches,
+	)
+	defer registry.Close()
+	ctx := context.Background()
+	complete := func(ref any) error {
+		params := mcp.CompleteParams{Ref: ref}
+		params.Argument.Name = "language"
+		_, err := registry.Complete(ctx, params)
+		return err
+	}
+
+	assert.ErrorIs(t, complete(mcp.PromptReference{Type: "ref/prompt", Name: "missing.review"}), ErrUnknownServer)
+	assert.ErrorContains(t, complete(map[string]any{"type": "ref/resource", "uri": "repo://issues/{number}"}), "not a lazy-mcp:// resource URI")
+	assert.ErrorContains(t, complete(map[string]any{"type": "ref/tool", "name": "echo.echo"}), `unknown completion reference type "ref/tool"`)
+	assert.Zero(t, launches)
+
+	// The echo server does not offer completions, but is asked
+	err := complete(map[string]any{"type": "ref/prompt", "name": "echo.review"})
+	assert.ErrorContains(t, err, "failed to complete argument language on server echo")
+	err = complete(mcp.ResourceReference{Type: "ref/resource", URI: "lazy-mcp://echo/repo://issues/{number}"})
+	assert.ErrorContains(t, err, "failed to complete argument language on server echo")
+	assert.Equal(t, int32(1), launches)
+}
@@ -1,72 +1,90 @@
 package hierarchy
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/TBXark/optional-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"golang.org/x/sync/semaphore"
 )
 
-// TestGetClientMutex_SameServer verifies that the same mutex is returned
+// trackMaxConcurrent runs fn while recording the highest number of
+// simultaneously active calls observed via activeCount/maxConcurrent.
+func trackMaxConcurrent(activeCount, maxConcurrent *int32, fn func()) {
+	current := atomic.AddInt32(activeCount, 1)
+	for {
+		old := atomic.LoadInt32(maxConcurrent)
+		if current <= old || atomic.CompareAndSwapInt32(maxConcurrent, old, current) {
+			break
+		}
+	}
+	fn()
+	atomic.AddInt32(activeCount, -1)
+}
+
+// TestGetClientSlots_SameServer verifies that the same semaphore is returned
 // for the same server name, ensuring proper serialization.
-func TestGetClientMutex_SameServer(t *testing.T) {
+func TestGetClientSlots_SameServer(t *testing.T) {
 	registry := NewServerRegistry(nil)
 
-	mutex1 := registry.GetClientMutex("trello")
-	mutex2 := registry.GetClientMutex("trello")
+	sem1 := registry.getClientSlots("trello")
+	sem2 := registry.getClientSlots("trello")
 
-	assert.Same(t, mutex1, mutex2, "Same server should return same mutex")
+	assert.Same(t, sem1, sem2, "Same server should return same semaphore")
 }
 
-// TestGetClientMutex_DifferentServers verifies that different mutexes are
+// TestGetClientSlots_DifferentServers verifies that different semaphores are
 // returned for different servers, allowing parallel execution across servers.
-func TestGetClientMutex_DifferentServers(t *testing.T) {
+func TestGetClientSlots_DifferentServers(t *testing.T) {
 	registry := NewServerRegistry(nil)
 
-	trelloMutex := registry.GetClientMutex("trello")
-	githubMutex := registry.GetClientMutex("github")
-	gmailMutex := registry.GetClientMutex("gmail")
+	trelloSem := registry.getClientSlots("trello")
+	githubSem := registry.getClientSlots("github")
+	gmailSem := registry.getClientSlots("gmail")
 
-	assert.NotSame(t, trelloMutex, githubMutex, "Different servers should have different mutexes")
-	assert.NotSame(t, trelloMutex, gmailMutex, "Different servers should have different mutexes")
-	assert.NotSame(t, githubMutex, gmailMutex, "Different servers should have different mutexes")
+	assert.NotSame(t, trelloSem, githubSem, "Different servers should have different semaphores")
+	assert.NotSame(t, trelloSem, gmailSem, "Different servers should have different semaphores")
+	assert.NotSame(t, githubSem, gmailSem, "Different servers should have different semaphores")
 }
 
-// TestGetClientMutex_ConcurrentAccess verifies thread-safety of GetClientMutex
-// when multiple goroutines request mutexes simultaneously.
-func TestGetClientMutex_ConcurrentAccess(t *testing.T) {
+// TestGetClientSlots_ConcurrentAccess verifies thread-safety of getClientSlots
+// when multiple goroutines request semaphores simultaneously.
+func TestGetClientSlots_ConcurrentAccess(t *testing.T) {
 	registry := NewServerRegistry(nil)
 
 	const numGoroutines = 100
 	var wg sync.WaitGroup
-	mutexes := make([]*sync.Mutex, numGoroutines)
+	results := make([]*semaphore.Weighted, numGoroutines)
 
-	// All goroutines request the same server's mutex concurrently
+	// All goroutines request the same server's semaphore concurrently
 	for i := 0; i < numGoroutines; i++ {
 		wg.Add(1)
 		go func(idx int) {
 			defer wg.Done()
-			mutexes[idx] = registry.GetClientMutex("shared-server")
+			results[idx] = registry.getClientSlots("shared-server")
 		}(i)
 	}
 
 	wg.Wait()
 
-	// All should be the same mutex
+	// All should be the same semaphore
 	for i := 1; i < numGoroutines; i++ {
-		assert.Same(t, mutexes[0], mutexes[i],
-			"All goroutines should receive the same mutex for the same server")
+		assert.Same(t, results[0], results[i],
+			"All goroutines should receive the same semaphore for the same server")
 	}
 }
 
-// TestMutexSerializesExecution verifies that the mutex actually serializes
-// concurrent operations, preventing interleaved execution.
-func TestMutexSerializesExecution(t *testing.T) {
+// TestAcquireSlotSerializesExecution verifies that the default of one slot
+// per server serializes concurrent operations, preventing interleaved execution.
+func TestAcquireSlotSerializesExecution(t *testing.T) {
 	registry := NewServerRegistry(nil)
-	mutex := registry.GetClientMutex("test-server")
 
 	const numOperations = 10
 	var executionOrder []int
@@ -81,27 +99,19 @@ func TestMutexSerializesExecution(t *testing.T) {
 		go func(opNum int) {
 			defer wg.Done()
 
-			mutex.Lock()
-			defer mutex.Unlock()
-
-			// Track concurrent executions
-			current := atomic.AddInt32(&activeCount, 1)
-			for {
-				old := atomic.LoadInt32(&maxConcurrent)
-				if current <= old || atomic.CompareAndSwapInt32(&maxConcurrent, old, current) {
-					break
-				}
-			}
-
-			// Simulate work
-			time.Sleep(1 * time.Millisecond)
+			release, err := registry.AcquireSlot(context.Background(), "test-server")
+			require.NoError(t, err)
+			defer release()
 
-			// Record execution order
-			orderMu.Lock()
-			executionOrder = append(executionOrder, opNum)
-			orderMu.Unlock()
+			trackMaxConcurrent(&activeCount, &maxConcurrent, func() {
+				// Simulate work
+				time.Sleep(1 * time.Millisecond)
 
-			atomic.AddInt32(&activeCount, -1)
+				// Record execution order
+				orderMu.Lock()
+				executionOrder = append(executionOrder, opNum)
+				orderMu.Unlock()
+			})
 		}(i)
 	}
 
@@ -109,47 +119,195 @@ func TestMutexSerializesExecution(t *testing.T) {
 
 	// Verify serialization: max concurrent should be 1
 	assert.Equal(t, int32(1), maxConcurrent,
-		"Only one operation should execute at a time (mutex serialization)")
+		"Only one operation should execute at a time (default slot count)")
 
 	// All operations should have completed
 	assert.Len(t, executionOrder, numOperations,
 		"All operations should complete")
 }
 
-// TestDifferentServersMutexesAllowParallel verifies that different servers
-// can execute in parallel (their mutexes don't block each other).
-func TestDifferentServersMutexesAllowParallel(t *testing.T) {
+// TestAcquireSlotHonorsMaxConcurrent verifies that a server configured with
+// maxConcurrent allows that many calls in flight, but no more.
+func TestAcquireSlotHonorsMaxConcurrent(t *testing.T) {
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"http-server": {
+			TransportType: config.MCPClientTypeStreamable,
+			URL:           "http://localhost:1234/mcp",
+			Options: &config.OptionsV2{
+				MaxConcurrent: optional.NewField(3),
+			},
+		},
+	})
+
+	var activeCount int32
+	var maxConcurrent int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 9; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			release, err := registry.AcquireSlot(context.Background(), "http-server")
+			require.NoError(t, err)
+			defer release()
+
+			trackMaxConcurrent(&activeCount, &maxConcurrent, func() {
+				time.Sleep(20 * time.Millisecond)
+			})
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, int32(3), maxConcurrent,
+		"At most maxConcurrent operations should execute at once")
+}
+
+// TestMaxConcurrentSerializesStdio verifies that stdio and Docker servers
+// take one call at a time, whatever their maxConcurrent.
+func TestMaxConcurrentSerializesStdio(t *testing.T) {
+	options := &config.OptionsV2{MaxConcurrent: optional.NewField(4)}
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"stdio":  {Command: "server", Options: options},
+		"docker": {Image: "server", Options: options},
+		"sse":    {URL: "http://localhost:1234/sse", Options: options},
+	})
+	defer registry.Close()
+
+	assert.Equal(t, 1, registry.MaxConcurrent("stdio"))
+	assert.Equal(t, 1, registry.MaxConcurrent("docker"))
+	assert.Equal(t, 4, registry.MaxConcurrent("sse"))
+}
+
+// TestReconfigureReplacesSlotsOnceDrained verifies that a server whose
+// maxConcurrent changed keeps its old slots until the calls holding them are
+// done, and only then takes as many calls as it now allows.
+func TestReconfigureReplacesSlotsOnceDrained(t *testing.T) {
+	serverConfig := func(maxConcurrent int) map[string]*config.MCPClientConfigV2 {
+		return map[string]*config.MCPClientConfigV2{
+			"remote": {URL: "http://localhost:1234/sse", Options: &config.OptionsV2{MaxConcurrent: optional.NewField(maxConcurrent)}},
+		}
+	}
+	registry := NewServerRegistry(serverConfig(1))
+	defer registry.Close()
+	acquire := func() (func(), error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		return registry.AcquireSlot(ctx, "remote")
+	}
+
+	inFlight, err := registry.AcquireSlot(context.Background(), "remote")
+	require.NoError(t, err)
+	registry.Reconfigure(serverConfig(2))
+	_, err = acquire()
+	assert.ErrorIs(t, err, ErrLockTimeout, "the call in flight still holds the only slot")
+
+	inFlight()
+	first, err := registry.AcquireSlot(context.Background(), "remote")
+	require.NoError(t, err)
+	defer first()
+	second, err := acquire()
+	require.NoError(t, err, "the new slots allow two calls")
+	defer second()
+	_, err = acquire()
+	assert.ErrorIs(t, err, ErrLockTimeout)
+}
+
+// TestAcquireSlotRespectsContext verifies that a caller waiting for a slot
+// gives up when its context is done instead of blocking forever.
+func TestAcquireSlotRespectsContext(t *testing.T) {
+	registry := NewServerRegistry(nil)
+
+	release, err := registry.AcquireSlot(context.Background(), "busy-server")
+	require.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = registry.AcquireSlot(ctx, "busy-server")
+	assert.ErrorIs(t, err, ErrLockTimeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// TestWithClientLockFailsFastOnHungServer verifies that when one call holds
+// a server's slot indefinitely, later callers fail with ErrLockTimeout once
+// their deadline passes, and fn is never run for them.
+func TestWithClientLockFailsFastOnHungServer(t *testing.T) {
 	registry := NewServerRegistry(nil)
 
-	server1Mutex := registry.GetClientMutex("server1")
-	server2Mutex := registry.GetClientMutex("server2")
+	hung := make(chan struct{})
+	entered := make(chan struct{})
+	go func() {
+		_ = registry.WithClientLock(context.Background(), "hung-server", func(ctx context.Context) error {
+			close(entered)
+			<-hung
+			return nil
+		})
+	}()
+	<-entered
+	defer close(hung)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	ran := false
+	start := time.Now()
+	err := registry.WithClientLock(ctx, "hung-server", func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+
+	assert.ErrorIs(t, err, ErrLockTimeout)
+	assert.False(t, ran, "fn should not run when the lock could not be acquired")
+	assert.Less(t, time.Since(start), time.Second, "caller should fail fast instead of blocking")
+}
+
+// TestWithClientLockReleasesSlot verifies that the slot is freed after fn
+// returns, including when fn returns an error.
+func TestWithClientLockReleasesSlot(t *testing.T) {
+	registry := NewServerRegistry(nil)
+
+	errBoom := errors.New("boom")
+	err := registry.WithClientLock(context.Background(), "server", func(ctx context.Context) error {
+		return errBoom
+	})
+	assert.ErrorIs(t, err, errBoom)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = registry.WithClientLock(ctx, "server", func(ctx context.Context) error {
+		return nil
+	})
+	assert.NoError(t, err, "slot should be available again after fn returns")
+}
+
+// TestDifferentServersSlotsAllowParallel verifies that different servers
+// can execute in parallel (their slots don't block each other).
+func TestDifferentServersSlotsAllowParallel(t *testing.T) {
+	registry := NewServerRegistry(nil)
 
 	var maxConcurrent int32
 	var activeCount int32
 	var wg sync.WaitGroup
 
 	// Simulate parallel execution on different servers
-	simulateWork := func(mutex *sync.Mutex, serverName string) {
+	simulateWork := func(serverName string) {
 		defer wg.Done()
 
-		mutex.Lock()
-		defer mutex.Unlock()
-
-		current := atomic.AddInt32(&activeCount, 1)
-		for {
-			old := atomic.LoadInt32(&maxConcurrent)
-			if current <= old || atomic.CompareAndSwapInt32(&maxConcurrent, old, current) {
-				break
-			}
-		}
+		release, err := registry.AcquireSlot(context.Background(), serverName)
+		require.NoError(t, err)
+		defer release()
 
-		time.Sleep(50 * time.Millisecond)
-		atomic.AddInt32(&activeCount, -1)
+		trackMaxConcurrent(&activeCount, &maxConcurrent, func() {
+			time.Sleep(50 * time.Millisecond)
+		})
 	}
 
 	wg.Add(2)
-	go simulateWork(server1Mutex, "server1")
-	go simulateWork(server2Mutex, "server2")
+	go simulateWork("server1")
+	go simulateWork("server2")
 
 	wg.Wait()
 
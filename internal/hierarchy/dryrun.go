@@ -0,0 +1,46 @@
+package hierarchy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// SetDryRun makes HandleExecuteTool check and log calls instead of forwarding
+// them, answering each with what would have been executed
+func (h *Hierarchy) SetDryRun(dryRun bool) {
+	h.dryRun = dryRun
+}
+
+// simulateCall answers call in dry-run mode: with what would have been
+// executed, or, if the arguments do not match the tool's input schema, with
+// an error listing the mismatches
+func (h *Hierarchy) simulateCall(ctx context.Context, toolDef *ToolDefinition, call *ToolCall) *mcp.CallToolResult {
+	problems := validateArguments(toolDef.InputSchema, call.Arguments)
+	arguments, _ := json.Marshal(call.Arguments)
+	logging.ForServer(call.Server).InfoContext(ctx, "Dry run: not executing tool",
+		"path", call.ToolPath, "tool", call.Tool, "arguments", string(arguments), "valid", len(problems) == 0)
+
+	structured := map[string]interface{}{
+		"dryRun":   true,
+		"toolPath": call.ToolPath,
+		"server":   call.Server,
+		"tool":     call.Tool,
+		"valid":    len(problems) == 0,
+	}
+	if call.Arguments != nil {
+		structured["arguments"] = call.Arguments
+	}
+	if len(problems) > 0 {
+		structured["problems"] = problems
+		result := mcp.NewToolResultStructured(structured, fmt.Sprintf("Dry run: %s.%s would be called with invalid arguments: %s",
+			call.Server, call.Tool, strings.Join(problems, "; ")))
+		result.IsError = true
+		return result
+	}
+	return mcp.NewToolResultStructured(structured, fmt.Sprintf("Dry run: would have executed %s.%s with %s", call.Server, call.Tool, arguments))
+}
@@ -0,0 +1,81 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestDryRunDoesNotForward verifies that dry-run calls are answered with
+// what would have been executed, or the arguments' problems, without
+// starting the server.
+func TestDryRunDoesNotForward(t *testing.T) {
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"echo": {
+			Server: "echo",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"message": map[string]interface{}{"type": "string"}},
+				"required":   []interface{}{"message"},
+			},
+		}}},
+	}}
+	h.SetDryRun(true)
+	var launches int32
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"echo": {}},
+		map[string]*server.MCPServer{"echo": newEchoServer()},
+		&launches,
+	)
+	defer registry.Close()
+	ctx := context.Background()
+
+	result, err := h.HandleExecuteTool(ctx, registry, "echo", map[string]interface{}{"message": "hi"})
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+	assert.Equal(t, `Dry run: would have executed echo.echo with {"message":"hi"}`, result.Content[0].(mcp.TextContent).Text)
+	assert.Equal(t, true, result.StructuredContent.(map[string]interface{})["valid"])
+
+	result, err = h.HandleExecuteTool(ctx, registry, "echo", map[string]interface{}{"message": 3})
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	assert.Equal(t, []string{"arguments.message: expected string, got integer"}, result.StructuredContent.(map[string]interface{})["problems"])
+	assert.Zero(t, launches)
+}
+
+func TestValidateArguments(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"count": map[string]interface{}{"type": "integer"},
+			"ratio": map[string]interface{}{"type": "number"},
+			"mode":  map[string]interface{}{"type": "string", "enum": []interface{}{"fast", "slow"}},
+			"tags":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+		"required":             []interface{}{"count"},
+		"additionalProperties": false,
+	}
+	tests := []struct {
+		name      string
+		arguments map[string]interface{}
+		want      []string
+	}{
+		{"valid", map[string]interface{}{"count": float64(2), "ratio": float64(2), "mode": "fast", "tags": []interface{}{"a"}}, nil},
+		{"missing", map[string]interface{}{}, []string{"arguments.count: is required"}},
+		{"fraction for integer", map[string]interface{}{"count": 1.5}, []string{"arguments.count: expected integer, got number"}},
+		{"not in enum", map[string]interface{}{"count": float64(1), "mode": "medium"}, []string{`arguments.mode: must be one of ["fast","slow"]`}},
+		{"bad item", map[string]interface{}{"count": float64(1), "tags": []interface{}{"a", true}}, []string{"arguments.tags[1]: expected string, got boolean"}},
+		{"unknown", map[string]interface{}{"count": float64(1), "extra": "x"}, []string{"arguments.extra: is not a known property"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, validateArguments(schema, tt.arguments))
+		})
+	}
+	assert.Empty(t, validateArguments(nil, map[string]interface{}{"anything": 1}))
+}
@@ -0,0 +1,291 @@
+package hierarchy
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// DuplicateTool is a name under which tools of more than one server are
+// listed in a category, by their own names, toolNames or toolAliases
+type DuplicateTool struct {
+	Path    string   // The category, "" for the root
+	Name    string   // The name or alias the tools share
+	Servers []string // The servers of the tools, in priority order
+}
+
+func (d DuplicateTool) String() string {
+	category := d.Path
+	if category == "" {
+		category = "the root category"
+	}
+	return fmt.Sprintf("%s in %s (servers %s)", d.Name, category, strings.Join(d.Servers, ", "))
+}
+
+// SetDuplicateTools sets how tools of different servers listed under the
+// same name are told apart, one of the config.DuplicateTools strategies, and
+// the order of servers that decides which keeps the name. With
+// config.DuplicateToolsError, duplicates that arise anyway are decided by
+// priority.
+func (h *Hierarchy) SetDuplicateTools(strategy string, priority []string) {
+	h.duplicates = strategy
+	h.priority = priority
+}
+
+// DuplicateTools returns the names under which tools of more than one server
+// would be listed with the given server configs, by category
+func (h *Hierarchy) DuplicateTools(servers map[string]*config.MCPClientConfigV2) []DuplicateTool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	view := &Hierarchy{
+		nodes:     h.nodes,
+		included:  serverOptions(servers).ToolIncluded,
+		overrides: serverOptions(servers),
+		priority:  h.priority,
+	}
+
+	paths := make([]string, 0, len(h.nodes))
+	for path := range h.nodes {
+		if path != "/" {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	var duplicates []DuplicateTool
+	for _, path := range paths {
+		tools := view.categoryTools(path)
+		claims := duplicateClaims(tools)
+		names := make([]string, 0, len(claims))
+		for name := range claims {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			view.sortClaims(claims[name], tools)
+			var servers []string
+			for _, c := range claims[name] {
+				if server := tools[c.tool].def.Server; !slices.Contains(servers, server) {
+					servers = append(servers, server)
+				}
+			}
+			duplicates = append(duplicates, DuplicateTool{Path: path, Name: name, Servers: servers})
+		}
+	}
+	return duplicates
+}
+
+// claim is a tool's claim to a name in a listing, by its name or an alias
+type claim struct {
+	tool  int // Index of the tool in the listing
+	alias int // Index of the alias, or -1 for the tool's name
+}
+
+// duplicateClaims returns the claims to each name of a listing that tools of
+// more than one server make. Meta-tools make none.
+func duplicateClaims(tools []toolMatch) map[string][]claim {
+	claims := make(map[string][]claim)
+	for i, tool := range tools {
+		if tool.def.Server == "" {
+			continue
+		}
+		claims[tool.tool.name] = append(claims[tool.tool.name], claim{tool: i, alias: -1})
+		for j, alias := range tool.tool.aliases {
+			claims[alias] = append(claims[alias], claim{tool: i, alias: j})
+		}
+	}
+	for name, nameClaims := range claims {
+		servers := make(map[string]bool)
+		for _, c := range nameClaims {
+			servers[tools[c.tool].def.Server] = true
+		}
+		if len(servers) < 2 {
+			delete(claims, name)
+		}
+	}
+	return claims
+}
+
+// sortClaims orders claims by the priority of their tools' servers, then by
+// server name, a tool's name before its aliases
+func (h *Hierarchy) sortClaims(claims []claim, tools []toolMatch) {
+	rank := func(server string) int {
+		if i := slices.Index(h.priority, server); i >= 0 {
+			return i
+		}
+		return len(h.priority)
+	}
+	sort.SliceStable(claims, func(i, j int) bool {
+		a, b := tools[claims[i].tool].def.Server, tools[claims[j].tool].def.Server
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		if a != b {
+			return a < b
+		}
+		return claims[i].alias < claims[j].alias
+	})
+}
+
+// settleDuplicates tells apart the tools of different servers listed under
+// the same name in the category at path. With the prefix strategy each
+// becomes <server>_<name>, and paths ending in the name in the category end
+// in the new name; otherwise the first server by priority keeps the name and
+// the other tools lose it, being left out if it is their own.
+func (h *Hierarchy) settleDuplicates(path string, tools []toolMatch) []toolMatch {
+	claims := duplicateClaims(tools)
+	if len(claims) == 0 {
+		return tools
+	}
+	// Aliases come from the config, so they are changed on copies
+	for _, nameClaims := range claims {
+		for _, c := range nameClaims {
+			tools[c.tool].tool.aliases = slices.Clone(tools[c.tool].tool.aliases)
+		}
+	}
+
+	hidden := make(map[int]bool)
+	dropped := make(map[claim]bool)
+	for name, nameClaims := range claims {
+		h.sortClaims(nameClaims, tools)
+		for i, c := range nameClaims {
+			tool := &tools[c.tool]
+			switch {
+			case h.duplicates == "" || h.duplicates == config.DuplicateToolsPrefix:
+				prefixed := tool.def.Server + "_" + name
+				if c.alias >= 0 {
+					tool.tool.aliases[c.alias] = prefixed
+					continue
+				}
+				tool.tool.name = prefixed
+				if tool.tool.path == searchToolPath(path, name) {
+					tool.tool.path = searchToolPath(path, prefixed)
+				}
+			case i == 0:
+			case c.alias >= 0:
+				dropped[c] = true
+			default:
+				hidden[c.tool] = true
+			}
+		}
+	}
+
+	settled := make([]toolMatch, 0, len(tools))
+	for i, tool := range tools {
+		if hidden[i] {
+			continue
+		}
+		if len(dropped) > 0 {
+			aliases := tool.tool.aliases[:0:0]
+			for j, alias := range tool.tool.aliases {
+				if !dropped[claim{tool: i, alias: j}] {
+					aliases = append(aliases, alias)
+				}
+			}
+			tool.tool.aliases = aliases
+		}
+		settled = append(settled, tool)
+	}
+	return settled
+}
+
+// categoryTools returns the tools get_tools_in_category lists for the
+// category at path, before duplicates are told apart: its own, or those of
+// its subcategories if all of them are leaves. They are ordered by name,
+// then server. h.mu must be held.
+func (h *Hierarchy) categoryTools(path string) []toolMatch {
+	node, exists := h.nodes[path]
+	if !exists {
+		return nil
+	}
+	var tools []toolMatch
+	add := func(toolName, toolPath string, toolDef *ToolDefinition) {
+		if h.isIncluded(toolName, toolDef) {
+			tool := h.exposeTool(toolName, toolPath, toolDef)
+			tools = append(tools, toolMatch{tool: tool, def: toolDef, original: toolName})
+		}
+	}
+	if len(node.Tools) > 0 {
+		for toolName, toolDef := range node.Tools {
+			if path == "" {
+				add(toolName, toolName, toolDef)
+			} else {
+				add(toolName, path+"."+toolName, toolDef)
+			}
+		}
+	} else {
+		for nodePath, child := range h.nodes {
+			if _, ok := childName(path, nodePath); !ok {
+				continue
+			}
+			if len(child.Tools) == 0 {
+				return nil // A subcategory is a branch
+			}
+			// In flat structure, nodePath already includes the tool name
+			// e.g., "everything.echo" not "everything.echo.echo"
+			for toolName, toolDef := range child.Tools {
+				add(toolName, nodePath, toolDef)
+			}
+		}
+	}
+	sortTools(tools)
+	return tools
+}
+
+// listedTools returns the tools listed in the category at path, with
+// duplicates told apart. h.mu must be held.
+func (h *Hierarchy) listedTools(path string) []toolMatch {
+	return h.settleDuplicates(path, h.categoryTools(path))
+}
+
+// listedTool returns the tool listed under the given name or alias in the
+// category the path leads to, if there is one
+func (h *Hierarchy) listedTool(toolPath string) (toolMatch, bool) {
+	category, name := "", toolPath
+	if i := strings.LastIndex(toolPath, "."); i >= 0 {
+		category, name = toolPath[:i], toolPath[i+1:]
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, tool := range h.listedTools(category) {
+		if tool.tool.name == name || slices.Contains(tool.tool.aliases, name) {
+			return tool, true
+		}
+	}
+	return toolMatch{}, false
+}
+
+// childName returns the name of the node at nodePath within the category at
+// path, if it is a direct subcategory of it
+func childName(path, nodePath string) (string, bool) {
+	if nodePath == path || nodePath == "" || nodePath == "/" {
+		return "", false
+	}
+	remainder := nodePath
+	if path != "" {
+		if !strings.HasPrefix(nodePath, path+".") {
+			return "", false
+		}
+		remainder = strings.TrimPrefix(nodePath, path+".")
+	}
+	if strings.Contains(remainder, ".") {
+		return "", false
+	}
+	return remainder, true
+}
+
+// sortTools orders tools by name, then server, then path
+func sortTools(tools []toolMatch) {
+	sort.Slice(tools, func(i, j int) bool {
+		a, b := tools[i], tools[j]
+		if a.tool.name != b.tool.name {
+			return a.tool.name < b.tool.name
+		}
+		if a.def.Server != b.def.Server {
+			return a.def.Server < b.def.Server
+		}
+		return a.tool.path < b.tool.path
+	})
+}
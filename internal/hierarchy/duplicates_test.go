@@ -0,0 +1,118 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// newDuplicateTestSetup returns a hierarchy whose tasks category lists a tool
+// of trello and one of jira, both renamed to add_task, and a registry serving
+// them
+func newDuplicateTestSetup(t *testing.T) (*Hierarchy, *ServerRegistry) {
+	t.Helper()
+	newServer := func(name, toolName string) *server.MCPServer {
+		mcpServer := server.NewMCPServer(name, "1.0.0")
+		mcpServer.AddTool(mcp.NewTool(toolName), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultText(name), nil
+		})
+		return mcpServer
+	}
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"":      {},
+		"tasks": {Overview: "Task trackers"},
+		"tasks.create_card": {Tools: map[string]*ToolDefinition{
+			"create_card": {Server: "trello", MapsTo: "create_card"},
+		}},
+		"tasks.create_issue": {Tools: map[string]*ToolDefinition{
+			"create_issue": {Server: "jira", MapsTo: "create_issue"},
+		}},
+	}}
+	servers := map[string]*config.MCPClientConfigV2{
+		"trello": {Options: &config.OptionsV2{ToolNames: map[string]string{"create_card": "add_task"}}},
+		"jira":   {Options: &config.OptionsV2{ToolNames: map[string]string{"create_issue": "add_task"}}},
+	}
+	registry := newTestRegistry(
+		servers,
+		map[string]*server.MCPServer{"trello": newServer("trello", "create_card"), "jira": newServer("jira", "create_issue")},
+		nil,
+	)
+	t.Cleanup(registry.Close)
+	h.SetToolFilter(registry.ToolIncluded)
+	h.SetToolOverrides(registry)
+	return h, registry
+}
+
+// TestDuplicateToolsArePrefixed verifies that by default tools of different
+// servers listed under the same name are listed and called under names
+// prefixed with their servers.
+func TestDuplicateToolsArePrefixed(t *testing.T) {
+	h, registry := newDuplicateTestSetup(t)
+
+	response, err := h.HandleGetToolsInCategory("tasks")
+	require.NoError(t, err)
+	tools := response["tools"].(map[string]interface{})
+	assert.Len(t, tools, 2)
+	require.Contains(t, tools, "trello_add_task")
+	require.Contains(t, tools, "jira_add_task")
+	assert.Equal(t, "tasks.jira_add_task", tools["jira_add_task"].(map[string]interface{})["tool_path"])
+
+	for _, server := range []string{"trello", "jira"} {
+		result, err := h.HandleExecuteTool(context.Background(), registry, "tasks."+server+"_add_task", nil)
+		require.NoError(t, err)
+		require.False(t, result.IsError, "%v", result.Content)
+		assert.Equal(t, server, result.Content[0].(mcp.TextContent).Text)
+	}
+}
+
+// TestDuplicateToolsByPriority verifies that with the priority strategy the
+// first server in serverPriority keeps the name and the other's tool is left
+// out, whatever the order of the servers' names.
+func TestDuplicateToolsByPriority(t *testing.T) {
+	h, registry := newDuplicateTestSetup(t)
+	h.SetDuplicateTools(config.DuplicateToolsPriority, []string{"trello"})
+
+	response, err := h.HandleGetToolsInCategory("tasks")
+	require.NoError(t, err)
+	tools := response["tools"].(map[string]interface{})
+	assert.Len(t, tools, 1)
+	require.Contains(t, tools, "add_task")
+	assert.Equal(t, "tasks.add_task", tools["add_task"].(map[string]interface{})["tool_path"])
+
+	result, err := h.HandleExecuteTool(context.Background(), registry, "tasks.add_task", nil)
+	require.NoError(t, err)
+	require.False(t, result.IsError, "%v", result.Content)
+	assert.Equal(t, "trello", result.Content[0].(mcp.TextContent).Text)
+
+	paths := make([]string, 0)
+	for _, tool := range h.searchableTools() {
+		paths = append(paths, tool.tool.path+"@"+tool.def.Server)
+	}
+	assert.Equal(t, []string{"tasks.add_task@trello"}, paths)
+}
+
+// TestDuplicateToolsAreReported verifies that DuplicateTools reports the
+// names tools of different servers would share with the given configs, and
+// none once they are renamed apart.
+func TestDuplicateToolsAreReported(t *testing.T) {
+	h, _ := newDuplicateTestSetup(t)
+	h.SetDuplicateTools(config.DuplicateToolsError, []string{"trello"})
+
+	servers := map[string]*config.MCPClientConfigV2{
+		"trello": {Options: &config.OptionsV2{ToolNames: map[string]string{"create_card": "add_task"}}},
+		"jira":   {Options: &config.OptionsV2{ToolNames: map[string]string{"create_issue": "add_task"}}},
+	}
+	duplicates := h.DuplicateTools(servers)
+	require.Len(t, duplicates, 1)
+	assert.Equal(t, DuplicateTool{Path: "tasks", Name: "add_task", Servers: []string{"trello", "jira"}}, duplicates[0])
+	assert.Equal(t, "add_task in tasks (servers trello, jira)", duplicates[0].String())
+
+	servers["jira"].Options.ToolNames["create_issue"] = "add_issue"
+	assert.Empty(t, h.DuplicateTools(servers))
+}
@@ -0,0 +1,59 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// ElicitationFunc sends an elicitation request to the client that made the
+// request ctx belongs to, returning the user's answer
+type ElicitationFunc func(ctx context.Context, request mcp.ElicitationRequest) (*mcp.ElicitationResult, error)
+
+// OnElicitationRequest registers fn to forward the elicitation requests of
+// servers to clients. Without it, servers are only offered elicitation if
+// they decline it. It only affects servers started afterwards.
+func (r *ServerRegistry) OnElicitationRequest(fn ElicitationFunc) {
+	r.elicitation.Store(&fn)
+}
+
+// DeclineElicitation reports whether the given server's elicitation requests
+// are declined rather than forwarded, per its declineElicitation option
+func (r *ServerRegistry) DeclineElicitation(serverName string) bool {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil {
+		return false
+	}
+	return cfg.Options.DeclineElicitation.OrElse(false)
+}
+
+// elicitationHandler answers a server's elicitation requests by forwarding
+// them to the client whose request the server is serving, or by declining
+// them for servers configured to
+type elicitationHandler struct {
+	registry *ServerRegistry
+	server   string
+}
+
+func (h elicitationHandler) Elicit(ctx context.Context, request mcp.ElicitationRequest) (*mcp.ElicitationResult, error) {
+	if h.registry.DeclineElicitation(h.server) {
+		logging.ForServer(h.server).DebugContext(ctx, "Declining elicitation request", "message", request.Params.Message)
+		return &mcp.ElicitationResult{ElicitationResponse: mcp.ElicitationResponse{Action: mcp.ElicitationResponseActionDecline}}, nil
+	}
+	fn := h.registry.elicitation.Load()
+	callerCtx, ok := h.registry.currentCaller(h.server)
+	if fn == nil || !ok {
+		return nil, fmt.Errorf("%w for server %s to elicit from", ErrNoCaller, h.server)
+	}
+	upstreamCtx, cancel := upstreamContext(callerCtx, ctx)
+	defer cancel()
+
+	logging.ForServer(h.server).DebugContext(upstreamCtx, "Forwarding elicitation request", "message", request.Params.Message)
+	result, err := (*fn)(upstreamCtx, request)
+	if err != nil {
+		return nil, fmt.Errorf("elicitation request of server %s failed: %w", h.server, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,80 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// newElicitingServer returns a server whose greet tool asks the user for
+// their name
+func newElicitingServer(name string) *server.MCPServer {
+	mcpServer := server.NewMCPServer(name, "1.0.0", server.WithElicitation())
+	mcpServer.AddTool(mcp.NewTool("greet"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := mcpServer.RequestElicitation(ctx, mcp.ElicitationRequest{Params: mcp.ElicitationParams{
+			Message:         "What is your name?",
+			RequestedSchema: map[string]any{"type": "object", "properties": map[string]any{"name": map[string]any{"type": "string"}}},
+		}})
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if result.Action != mcp.ElicitationResponseActionAccept {
+			return mcp.NewToolResultText("hello stranger, you " + string(result.Action) + "d"), nil
+		}
+		return mcp.NewToolResultText("hello " + result.Content.(map[string]any)["name"].(string)), nil
+	})
+	return mcpServer
+}
+
+// TestElicitationIsForwardedToTheCaller verifies that a server's elicitation
+// request reaches the client request it is serving and the user's answer
+// returns to it, and that servers with declineElicitation: true are declined
+// without asking.
+func TestElicitationIsForwardedToTheCaller(t *testing.T) {
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{
+			"greet":    {Server: "greeter", MapsTo: "greet"},
+			"headless": {Server: "headless", MapsTo: "greet"},
+		}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"greeter":  {},
+			"headless": {Options: &config.OptionsV2{DeclineElicitation: optional.NewField(true)}},
+		},
+		map[string]*server.MCPServer{"greeter": newElicitingServer("greeter"), "headless": newElicitingServer("headless")},
+		nil,
+	)
+	defer registry.Close()
+
+	var asked []string
+	registry.OnElicitationRequest(func(ctx context.Context, request mcp.ElicitationRequest) (*mcp.ElicitationResult, error) {
+		caller, _ := ctx.Value(callerKey{}).(string)
+		asked = append(asked, request.Params.Message)
+		return &mcp.ElicitationResult{ElicitationResponse: mcp.ElicitationResponse{
+			Action:  mcp.ElicitationResponseActionAccept,
+			Content: map[string]any{"name": caller},
+		}}, nil
+	})
+
+	ctx := context.WithValue(context.Background(), callerKey{}, "alice")
+	result, err := h.HandleExecuteTool(ctx, registry, "greet", nil)
+	require.NoError(t, err)
+	require.False(t, result.IsError, "%v", result.Content)
+	assert.Equal(t, "hello alice", result.Content[0].(mcp.TextContent).Text)
+
+	result, err = h.HandleExecuteTool(ctx, registry, "headless", nil)
+	require.NoError(t, err)
+	require.False(t, result.IsError, "%v", result.Content)
+	assert.Equal(t, "hello stranger, you declined", result.Content[0].(mcp.TextContent).Text)
+	assert.Equal(t, []string{"What is your name?"}, asked, "a declined elicitation must not reach the client")
+
+	_, err = elicitationHandler{registry: registry, server: "greeter"}.Elicit(context.Background(), mcp.ElicitationRequest{})
+	assert.ErrorIs(t, err, ErrNoCaller)
+}
@@ -0,0 +1,229 @@
+package hierarchy
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// EventType is the kind of a lifecycle event of the registry
+type EventType string
+
+const (
+	// EventServerStarted is published once a server has started and
+	// initialized, including restarts
+	EventServerStarted EventType = "server_started"
+	// EventServerStopped is published when a running server stops, for the
+	// Reason given
+	EventServerStopped EventType = "server_stopped"
+	// EventServerUnhealthy is published when a server fails a health check,
+	// times out too often, trips its circuit breaker or exceeds its restart
+	// limit, as Reason says
+	EventServerUnhealthy EventType = "server_unhealthy"
+	// EventToolCalled is published once a call made through
+	// HandleExecuteTool is done
+	EventToolCalled EventType = "tool_called"
+	// EventHierarchyChanged is published when the tools of Servers may have
+	// changed: a server reported a change, or the config was reloaded
+	EventHierarchyChanged EventType = "hierarchy_changed"
+)
+
+// Reasons of EventServerStopped and EventServerUnhealthy
+const (
+	ReasonIdle         = "idle"
+	ReasonReconfigured = "reconfigured"
+	ReasonRestart      = "restart"
+	ReasonCrashed      = "crashed"
+	ReasonHung         = "hung"
+	ReasonClosed       = "closed"
+	ReasonDisabled     = "disabled"
+	ReasonHealthCheck  = "health_check"
+	ReasonTimeouts     = "timeouts"
+	ReasonCircuitOpen  = "circuit_open"
+	ReasonRestartLimit = "restart_limit"
+)
+
+// Event is a lifecycle event of the registry
+type Event struct {
+	Type   EventType `json:"type"`
+	Time   time.Time `json:"time"`
+	Server string    `json:"server,omitempty"`
+	// Servers are those whose tools changed, of EventHierarchyChanged
+	Servers []string `json:"servers,omitempty"`
+	Reason  string   `json:"reason,omitempty"`
+	// Version is what a started server reports
+	Version string `json:"version,omitempty"`
+	// ToolPath and Tool are the tool called, in the hierarchy and as its
+	// server names it
+	ToolPath string `json:"toolPath,omitempty"`
+	Tool     string `json:"tool,omitempty"`
+	// Duration is how long a call took, or how long a stopped server ran
+	Duration time.Duration `json:"duration,omitempty"`
+	// Err is why a call failed, a server crashed or became unhealthy
+	Err error `json:"-"`
+	// Error is Err as text
+	Error string `json:"error,omitempty"`
+}
+
+// EventHandler handles the events a subscriber receives
+type EventHandler func(ctx context.Context, event Event)
+
+const (
+	// eventBuffer is how many events a subscriber may fall behind by before
+	// further events are dropped for it
+	eventBuffer = 256
+	// recentEvents is how many events are kept for Recent
+	recentEvents = 100
+)
+
+// EventBus delivers the registry's events to subscribers. Each subscriber
+// gets the events in order in its own goroutine, so a slow one holds up no
+// one but itself.
+type EventBus struct {
+	mu          sync.Mutex
+	subscribers map[*subscription]struct{}
+	recent      []Event
+}
+
+type subscription struct {
+	events chan publishedEvent
+}
+
+type publishedEvent struct {
+	ctx   context.Context
+	event Event
+}
+
+func newEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[*subscription]struct{})}
+}
+
+// Subscribe calls handler with every event published from now on, until the
+// returned func is called or the registry is closed
+func (b *EventBus) Subscribe(handler EventHandler) (unsubscribe func()) {
+	sub := &subscription{events: make(chan publishedEvent, eventBuffer)}
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+	go func() {
+		for published := range sub.events {
+			handler(published.ctx, published.event)
+		}
+	}()
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, subscribed := b.subscribers[sub]; subscribed {
+			delete(b.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Recent returns the latest events, oldest first: of the given server, or
+// all of them for ""
+func (b *EventBus) Recent(serverName string) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	events := make([]Event, 0, len(b.recent))
+	for _, event := range b.recent {
+		if serverName == "" || event.Server == serverName {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// publish delivers event to every subscriber without waiting for them. ctx is
+// the request the event happened in, if any; subscribers get its values but
+// not its cancellation.
+func (b *EventBus) publish(ctx context.Context, event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.Err != nil {
+		event.Error = event.Err.Error()
+	}
+	published := publishedEvent{ctx: context.WithoutCancel(ctx), event: event}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.recent) == recentEvents {
+		b.recent = append(b.recent[:0], b.recent[1:]...)
+	}
+	b.recent = append(b.recent, event)
+	for sub := range b.subscribers {
+		select {
+		case sub.events <- published:
+		default:
+			slog.Warn("Dropping event for slow subscriber", "type", event.Type, "server", event.Server)
+		}
+	}
+}
+
+// close ends every subscription once its subscriber has handled the events
+// already published
+func (b *EventBus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers {
+		delete(b.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// Events returns the bus the registry publishes its lifecycle events on
+func (r *ServerRegistry) Events() *EventBus {
+	return r.events
+}
+
+// publish logs event and publishes it on the registry's bus. The
+// subscriptions to the resources of a server that stopped go with it.
+func (r *ServerRegistry) publish(ctx context.Context, event Event) {
+	r.logEvent(ctx, event)
+	if event.Type == EventServerStopped {
+		r.dropSubscriptions(event.Server)
+	}
+	r.events.publish(ctx, event)
+}
+
+// logEvent logs an event as it happens
+func (r *ServerRegistry) logEvent(ctx context.Context, event Event) {
+	logger := logging.ForServer(event.Server)
+	attrs := []any{}
+	if event.Err != nil {
+		attrs = append(attrs, "error", event.Err)
+	}
+	switch event.Type {
+	case EventServerStarted:
+		attrs = append(attrs, "version", event.Version)
+		if cfg, exists := r.serverConfig(event.Server); exists && cfg.Runner != "" {
+			// What a version range resolved to
+			attrs = append(attrs, "package", cfg.PackageSpec())
+		}
+		logger.InfoContext(ctx, "Started MCP client", attrs...)
+	case EventServerStopped:
+		attrs = append(attrs, "reason", event.Reason, "uptime", event.Duration.Round(time.Second))
+		if event.Reason == ReasonCrashed {
+			logger.WarnContext(ctx, "MCP client disconnected unexpectedly", attrs...)
+		} else {
+			logger.InfoContext(ctx, "Stopped MCP client", attrs...)
+		}
+	case EventServerUnhealthy:
+		attrs = append(attrs, "reason", event.Reason)
+		if event.Reason == ReasonRestartLimit {
+			logger.ErrorContext(ctx, "MCP client failed too many times, not restarting", attrs...)
+		} else {
+			logger.WarnContext(ctx, "MCP server unhealthy", attrs...)
+		}
+	case EventToolCalled:
+		attrs = append(attrs, "path", event.ToolPath, "tool", event.Tool, "duration", event.Duration.Round(time.Millisecond))
+		logger.DebugContext(ctx, "Tool call done", attrs...)
+	case EventHierarchyChanged:
+		slog.InfoContext(ctx, "Tools changed", "servers", event.Servers)
+	}
+}
@@ -0,0 +1,83 @@
+package hierarchy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestEventBusDeliversInOrder verifies that subscribers get events in order
+// until they unsubscribe, and that Recent keeps them by server.
+func TestEventBusDeliversInOrder(t *testing.T) {
+	bus := newEventBus()
+	received := make(chan Event, 10)
+	unsubscribe := bus.Subscribe(func(ctx context.Context, event Event) {
+		received <- event
+	})
+
+	bus.publish(context.Background(), Event{Type: EventServerStarted, Server: "a"})
+	bus.publish(context.Background(), Event{Type: EventServerUnhealthy, Server: "b", Err: errors.New("down")})
+	for _, want := range []string{"a", "b"} {
+		select {
+		case event := <-received:
+			assert.Equal(t, want, event.Server)
+			assert.False(t, event.Time.IsZero())
+		case <-time.After(5 * time.Second):
+			t.Fatal("event was not delivered")
+		}
+	}
+
+	unsubscribe()
+	unsubscribe()
+	bus.publish(context.Background(), Event{Type: EventServerStopped, Server: "a"})
+	select {
+	case event := <-received:
+		t.Fatalf("got %s after unsubscribing", event.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	recent := bus.Recent("b")
+	require.Len(t, recent, 1)
+	assert.Equal(t, "down", recent[0].Error)
+	assert.Len(t, bus.Recent(""), 3)
+}
+
+// TestRegistryPublishesLifecycle verifies that starting, calling and closing
+// a server are published.
+func TestRegistryPublishesLifecycle(t *testing.T) {
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"echo": {Server: "echo"}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"echo": {}},
+		map[string]*server.MCPServer{"echo": newEchoServer()},
+		nil,
+	)
+	done := make(chan struct{})
+	var types []EventType
+	registry.Events().Subscribe(func(ctx context.Context, event Event) {
+		types = append(types, event.Type)
+		if event.Type == EventServerStopped {
+			assert.Equal(t, ReasonClosed, event.Reason)
+			close(done)
+		}
+	})
+
+	_, err := h.HandleExecuteTool(context.Background(), registry, "echo", map[string]interface{}{"message": "hi"})
+	require.NoError(t, err)
+	registry.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server stop was not published")
+	}
+	assert.Equal(t, []EventType{EventServerStarted, EventToolCalled, EventServerStopped}, types)
+	assert.Equal(t, "1.0.0", registry.Events().Recent("echo")[0].Version)
+}
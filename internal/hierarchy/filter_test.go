@@ -0,0 +1,38 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestExcludedToolsAreHidden verifies that tools matching a server's
+// excludeTools are left out of categories and cannot be executed.
+func TestExcludedToolsAreHidden(t *testing.T) {
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {},
+		"github": {Tools: map[string]*ToolDefinition{
+			"get_repo":    {Server: "github", MapsTo: "get_repo"},
+			"remove_repo": {Server: "github", MapsTo: "delete_repo"},
+		}},
+	}}
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"github": {Options: &config.OptionsV2{ExcludeTools: []string{"delete_*"}}},
+	})
+	defer registry.Close()
+	h.SetToolFilter(registry.ToolIncluded)
+
+	response, err := h.HandleGetToolsInCategory("")
+	require.NoError(t, err)
+	tools := response["tools"].(map[string]interface{})
+	assert.Contains(t, tools, "get_repo")
+	assert.NotContains(t, tools, "remove_repo", "filters apply to the server's own tool names")
+	children := response["children"].(map[string]interface{})
+	assert.Equal(t, 1, children["github"].(map[string]interface{})["tool_count"])
+
+	_, err = h.HandleExecuteTool(context.Background(), registry, "github.remove_repo", nil)
+	assert.ErrorContains(t, err, "excluded")
+}
@@ -0,0 +1,60 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	generator "github.com/voicetreelab/lazy-mcp/structure_generator"
+)
+
+// TestGroupedServersAreNested verifies that servers generated under a group
+// are listed beneath it rather than at the root, and that their tools are
+// called by their full path.
+func TestGroupedServersAreNested(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, generator.GenerateStructure([]generator.ServerTools{
+		{ServerName: "trello", Group: "productivity", Tools: []generator.Tool{{Name: "create_card", Description: "Creates a card."}}},
+		{ServerName: "gmail", Group: "productivity", Tools: []generator.Tool{{Name: "send_email", Description: "Sends an email."}}},
+		{ServerName: "github", Group: "work/devops", Tools: []generator.Tool{{Name: "create_issue", Description: "Creates an issue."}}},
+	}, dir))
+
+	h, err := LoadHierarchy(dir)
+	require.NoError(t, err)
+
+	root, err := h.HandleGetToolsInCategory("")
+	require.NoError(t, err)
+	children := root["children"].(map[string]interface{})
+	assert.ElementsMatch(t, []string{"productivity", "work"}, keys(children))
+
+	productivity, err := h.HandleGetToolsInCategory("productivity")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"trello", "gmail"}, keys(productivity["children"].(map[string]interface{})))
+
+	github := server.NewMCPServer("github", "1.0.0")
+	github.AddTool(mcp.NewTool("create_issue"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("created"), nil
+	})
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"github": {}},
+		map[string]*server.MCPServer{"github": github},
+		nil,
+	)
+	defer registry.Close()
+
+	result, err := h.HandleExecuteTool(context.Background(), registry, "work.devops.github.create_issue", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "created", result.Content[0].(mcp.TextContent).Text)
+}
+
+func keys(m map[string]interface{}) []string {
+	result := make([]string, 0, len(m))
+	for key := range m {
+		result = append(result, key)
+	}
+	return result
+}
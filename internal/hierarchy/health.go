@@ -0,0 +1,237 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// DefaultHealthCheckInterval is how often connected servers are pinged when
+// healthCheckInterval is not configured.
+const DefaultHealthCheckInterval = 30 * time.Second
+
+// healthPingTimeout bounds a single health check ping
+const healthPingTimeout = 10 * time.Second
+
+// ServerState describes whether a configured server currently has a live client
+type ServerState string
+
+const (
+	ServerStateStopped    ServerState = "stopped"
+	ServerStateRunning    ServerState = "running"
+	ServerStateRestarting ServerState = "restarting" // Crashed and waiting out its restart backoff
+	ServerStateFailed     ServerState = "failed"     // Crashed more than maxRestarts times
+	ServerStateDisabled   ServerState = "disabled"   // Taken out of service with DisableServer
+)
+
+// ServerStatus is a point-in-time snapshot of a configured server's health
+type ServerStatus struct {
+	Name                string      `json:"name"`
+	Transport           string      `json:"transport"`
+	Package             string      `json:"package,omitempty"` // Of npx and uvx servers, as configured
+	Version             string      `json:"version,omitempty"` // As the running server reports it
+	State               ServerState `json:"state"`
+	Healthy             bool        `json:"healthy"`
+	LastCheck           *time.Time  `json:"lastCheck,omitempty"`
+	LastError           string      `json:"lastError,omitempty"`
+	ConsecutiveFailures int         `json:"consecutiveFailures,omitempty"`
+	Restarts            int         `json:"restarts,omitempty"`
+	CircuitOpenUntil    *time.Time  `json:"circuitOpenUntil,omitempty"` // Calls fail fast until then
+	InFlight            int         `json:"inFlight,omitempty"`         // Tool calls holding a call slot
+	Queued              int         `json:"queued,omitempty"`           // Tool calls waiting for a call slot
+	// QueueWait and MaxQueueWait are the average and longest wait for a call
+	// slot over the server's latest calls
+	QueueWait    config.Duration `json:"queueWait,omitempty"`
+	MaxQueueWait config.Duration `json:"maxQueueWait,omitempty"`
+}
+
+// serverHealth is the mutable health record kept alongside a connected client
+type serverHealth struct {
+	lastCheck           time.Time
+	lastError           string
+	consecutiveFailures int
+	consecutiveTimeouts int // Of tool calls, see recordCallTimeout
+}
+
+// healthy reports whether the most recent check succeeded (or none has run yet)
+func (h *serverHealth) healthy() bool {
+	return h.consecutiveFailures == 0
+}
+
+// ServerStatuses returns the status of every configured server, sorted by name.
+// Servers that have not been started yet are reported as stopped and healthy.
+func (r *ServerRegistry) ServerStatuses() []ServerStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	configs := r.configs()
+	statuses := make([]ServerStatus, 0, len(configs))
+	for name, cfg := range configs {
+		status := ServerStatus{
+			Name:    name,
+			State:   ServerStateStopped,
+			Healthy: true,
+		}
+		if cfg != nil {
+			status.Transport = string(cfg.Transport())
+			if cfg.Runner != "" {
+				status.Package = cfg.PackageSpec()
+			}
+		}
+		if record, crashed := r.crashes[name]; crashed {
+			status.Restarts = record.count
+			if record.lastErr != nil {
+				status.LastError = record.lastErr.Error()
+			}
+			switch {
+			case record.count > r.MaxRestarts(name):
+				status.State = ServerStateFailed
+				status.Healthy = false
+			case time.Now().Before(record.nextRestart):
+				status.State = ServerStateRestarting
+			}
+		}
+		if state, exists := r.servers[name]; exists {
+			status.State = ServerStateRunning
+			status.Version = state.version
+			status.Healthy = state.health.healthy()
+			status.LastError = state.health.lastError
+			status.ConsecutiveFailures = state.health.consecutiveFailures
+			if !state.health.lastCheck.IsZero() {
+				lastCheck := state.health.lastCheck
+				status.LastCheck = &lastCheck
+			}
+		}
+		if openUntil := r.circuitOpenUntil(name); !openUntil.IsZero() {
+			status.Healthy = false
+			status.CircuitOpenUntil = &openUntil
+		}
+		if r.disabled[name] {
+			status.State = ServerStateDisabled
+		}
+		status.InFlight = len(r.callers[name])
+		status.Queued = r.queued[name]
+		if waits, exists := r.queueWaits[name]; exists {
+			average, longest := waits.summary()
+			status.QueueWait = config.Duration(average.Round(time.Millisecond))
+			status.MaxQueueWait = config.Duration(longest.Round(time.Millisecond))
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// ServerStatus returns the status of the given server, as ServerStatuses
+// reports it
+func (r *ServerRegistry) ServerStatus(serverName string) (ServerStatus, error) {
+	for _, status := range r.ServerStatuses() {
+		if status.Name == serverName {
+			return status, nil
+		}
+	}
+	return ServerStatus{}, fmt.Errorf("%w: %s", ErrUnknownServer, serverName)
+}
+
+// IsHealthy reports whether the given server is healthy. Servers that are not
+// running are considered healthy since they will be started on demand, unless
+// they have exceeded their restart limit. Servers whose circuit breaker is
+// open are unhealthy.
+func (r *ServerRegistry) IsHealthy(serverName string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if !r.circuitOpenUntil(serverName).IsZero() {
+		return false
+	}
+
+	if state, exists := r.servers[serverName]; exists {
+		return state.health.healthy()
+	}
+	record, crashed := r.crashes[serverName]
+	return !crashed || record.count <= r.MaxRestarts(serverName)
+}
+
+// StartHealthChecker pings every connected server on the given interval and
+// records the outcome in the registry. It returns when ctx is done.
+func (r *ServerRegistry) StartHealthChecker(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.checkHealth(ctx)
+		}
+	}
+}
+
+// checkHealth pings each connected server once. Servers that are busy serving
+// a tool call are skipped: they are evidently reachable and pinging would
+// interleave with the call on single-channel transports.
+func (r *ServerRegistry) checkHealth(ctx context.Context) {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.servers))
+	for name := range r.servers {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+
+	for _, name := range names {
+		sem := r.getClientSlots(name)
+		if !sem.TryAcquire(1) {
+			continue
+		}
+
+		state, exists := r.lookupQuiet(name)
+		if !exists {
+			sem.Release(1)
+			continue
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+		err := state.client.Ping(pingCtx)
+		cancel()
+		sem.Release(1)
+
+		r.recordHealth(name, state, err)
+	}
+}
+
+// recordHealth stores the outcome of a health check for the given client,
+// ignoring results for clients that have since been replaced or stopped.
+func (r *ServerRegistry) recordHealth(serverName string, checked *serverState, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, exists := r.servers[serverName]
+	if !exists || state != checked {
+		return
+	}
+
+	state.health.lastCheck = time.Now()
+	if err != nil {
+		state.health.consecutiveFailures++
+		state.health.lastError = err.Error()
+		r.publish(context.Background(), Event{Type: EventServerUnhealthy, Server: serverName, Reason: ReasonHealthCheck, Err: err})
+		return
+	}
+	if state.health.consecutiveFailures > 0 {
+		logging.ForServer(serverName).Info("Health check recovered", "failures", state.health.consecutiveFailures)
+	}
+	state.health.consecutiveFailures = 0
+	state.health.lastError = ""
+}
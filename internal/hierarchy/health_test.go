@@ -0,0 +1,61 @@
+package hierarchy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestHealthCheckRecordsStatus verifies that health checks only touch running
+// servers, record successful pings, and mark servers unhealthy on failure, and
+// that statuses show the package and version servers run.
+func TestHealthCheckRecordsStatus(t *testing.T) {
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"echo":    {Runner: config.RunnerNpx, Package: "echo-server", Version: "^1", Options: &config.OptionsV2{}},
+			"dormant": {URL: "http://localhost:1234/sse", Options: &config.OptionsV2{}},
+		},
+		map[string]*server.MCPServer{"echo": newEchoServer()},
+		nil,
+	)
+	defer registry.Close()
+
+	_, err := registry.GetOrLoadServer(context.Background(), "echo")
+	require.NoError(t, err)
+
+	registry.checkHealth(context.Background())
+
+	statuses := registry.ServerStatuses()
+	require.Len(t, statuses, 2)
+
+	assert.Equal(t, "dormant", statuses[0].Name)
+	assert.Equal(t, ServerStateStopped, statuses[0].State)
+	assert.Equal(t, "sse", statuses[0].Transport)
+	assert.True(t, statuses[0].Healthy, "stopped servers are healthy until tried")
+	assert.Nil(t, statuses[0].LastCheck)
+
+	assert.Equal(t, "echo", statuses[1].Name)
+	assert.Equal(t, ServerStateRunning, statuses[1].State)
+	assert.Equal(t, "stdio", statuses[1].Transport)
+	assert.True(t, statuses[1].Healthy)
+	assert.NotNil(t, statuses[1].LastCheck, "running servers should have been pinged")
+	assert.Equal(t, "echo-server@^1", statuses[1].Package)
+	assert.Equal(t, "1.0.0", statuses[1].Version, "the version the range resolved to")
+
+	state, _ := registry.lookupQuiet("echo")
+	registry.recordHealth("echo", state, errors.New("broken pipe"))
+
+	assert.False(t, registry.IsHealthy("echo"))
+	statuses = registry.ServerStatuses()
+	assert.False(t, statuses[1].Healthy)
+	assert.Equal(t, "broken pipe", statuses[1].LastError)
+	assert.Equal(t, 1, statuses[1].ConsecutiveFailures)
+
+	registry.checkHealth(context.Background())
+	assert.True(t, registry.IsHealthy("echo"), "a successful ping should clear the failure")
+}
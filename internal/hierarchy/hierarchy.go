@@ -3,17 +3,39 @@ package hierarchy
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
-	"github.com/voicetreelab/lazy-mcp/internal/client"
+	"github.com/voicetreelab/lazy-mcp/internal/approval"
+	"github.com/voicetreelab/lazy-mcp/internal/audit"
 	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records spans along the path of a proxied tool call
+var tracer = otel.Tracer("github.com/voicetreelab/lazy-mcp/internal/hierarchy")
+
+// Span attributes
+const (
+	attrToolPath  = attribute.Key("lazy_mcp.tool_path")
+	attrServer    = attribute.Key("lazy_mcp.server")
+	attrTool      = attribute.Key("lazy_mcp.tool")
+	attrAttempt   = attribute.Key("lazy_mcp.attempt")
+	attrColdStart = attribute.Key("lazy_mcp.cold_start")
+	attrCacheHit  = attribute.Key("lazy_mcp.cache_hit")
+	attrRequestID = attribute.Key("lazy_mcp.request_id")
 )
 
 // HierarchyNode represents a node in the tool hierarchy
@@ -30,6 +52,8 @@ type ToolDefinition struct {
 	MapsTo      string                 `json:"maps_to,omitempty"`
 	Server      string                 `json:"server,omitempty"`
 	InputSchema map[string]interface{} `json:"inputSchema,omitempty"`
+	// Timeout overrides the server's callTimeout for this tool
+	Timeout config.Duration `json:"timeout,omitempty"`
 }
 
 // HierarchyNodeData is used for unmarshaling JSON with flexible tool types
@@ -42,7 +66,7 @@ type HierarchyNodeData struct {
 // MCPServerRef contains MCP server configuration
 type MCPServerRef struct {
 	Name         string            `json:"name"`
-	Type         string            `json:"type"` // "stdio", "sse", "streamable-http"
+	Type         string            `json:"type"` // "stdio", "sse", "streamable-http" (or "http")
 	Command      string            `json:"command,omitempty"`
 	Args         []string          `json:"args,omitempty"`
 	Env          map[string]string `json:"env,omitempty"`
@@ -67,7 +91,7 @@ func (m *MCPServerRef) ToClientConfig() *config.MCPClientConfigV2 {
 		cfg.TransportType = config.MCPClientTypeSSE
 		cfg.URL = m.URL
 		cfg.Headers = m.Headers
-	case "streamable-http":
+	case "streamable-http", "http":
 		cfg.TransportType = config.MCPClientTypeStreamable
 		cfg.URL = m.URL
 		cfg.Headers = m.Headers
@@ -81,6 +105,53 @@ type Hierarchy struct {
 	rootPath string
 	nodes    map[string]*HierarchyNode
 	mu       sync.RWMutex
+	audit    *audit.Log // Records every call made through HandleExecuteTool
+	// included reports whether a server's tool may be listed and called
+	included func(serverName, toolName string) bool
+	// overrides renames tools and curates their descriptions, if set
+	overrides ToolOverrides
+	// semantic ranks search_tools results by embeddings, if set
+	semantic *semanticIndex
+	// tokenBudget bounds category listings expanded in full; see SetTokenBudget
+	tokenBudget int
+	usage       usageStats
+	// approver holds back the calls approval requires it for, if set
+	approval *config.ApprovalConfig
+	approver approval.Approver
+	// middlewares wrap every resolved call; see Use
+	middlewares []CallMiddleware
+	// dryRun answers calls without forwarding them; see SetDryRun
+	dryRun bool
+	// duplicates and priority tell apart tools of different servers listed
+	// under the same name; see SetDuplicateTools
+	duplicates string
+	priority   []string
+	// virtual are the names of the virtual servers added
+	virtual []string
+}
+
+// SetAuditLog makes HandleExecuteTool record every call in log
+func (h *Hierarchy) SetAuditLog(log *audit.Log) {
+	h.audit = log
+}
+
+// SetToolFilter hides the tools for which included returns false: they are
+// left out of categories and cannot be executed
+func (h *Hierarchy) SetToolFilter(included func(serverName, toolName string) bool) {
+	h.included = included
+}
+
+// isIncluded reports whether the tool with the given hierarchy name is not
+// hidden by the tool filter
+func (h *Hierarchy) isIncluded(toolName string, toolDef *ToolDefinition) bool {
+	if h.included == nil || toolDef.Server == "" {
+		return true
+	}
+	actualToolName := toolDef.MapsTo
+	if actualToolName == "" {
+		actualToolName = toolName
+	}
+	return h.included(toolDef.Server, actualToolName)
 }
 
 // LoadHierarchy loads the hierarchy from a directory structure
@@ -145,12 +216,12 @@ func LoadHierarchy(hierarchyPath string) (*Hierarchy, error) {
 
 		node, err := loadNode(path)
 		if err != nil {
-			log.Printf("Warning: failed to load node at %s: %v", path, err)
+			slog.Warn("Failed to load hierarchy node", "path", path, "error", err)
 			return nil // Continue loading other nodes
 		}
 
 		h.nodes[hierarchyKey] = node
-		log.Printf("Loaded hierarchy node: %s from %s", hierarchyKey, path)
+		slog.Debug("Loaded hierarchy node", "key", hierarchyKey, "path", path)
 		return nil
 	})
 
@@ -158,7 +229,7 @@ func LoadHierarchy(hierarchyPath string) (*Hierarchy, error) {
 		return nil, fmt.Errorf("failed to walk hierarchy: %w", err)
 	}
 
-	log.Printf("Loaded %d hierarchy nodes", len(h.nodes))
+	slog.Info("Loaded hierarchy", "nodes", len(h.nodes))
 	return h, nil
 }
 
@@ -200,6 +271,13 @@ func loadNode(path string) (*HierarchyNode, error) {
 			if schema, ok := toolMap["inputSchema"].(map[string]interface{}); ok {
 				tool.InputSchema = schema
 			}
+			if timeout, ok := toolMap["timeout"].(string); ok {
+				parsed, err := time.ParseDuration(timeout)
+				if err != nil {
+					return nil, fmt.Errorf("invalid timeout for tool %s: %w", toolName, err)
+				}
+				tool.Timeout = config.Duration(parsed)
+			}
 			node.Tools[toolName] = tool
 		}
 	}
@@ -214,6 +292,18 @@ func (h *Hierarchy) GetRootNode() *HierarchyNode {
 	return h.nodes[""]
 }
 
+// ToolPaths returns the paths of all tools that may be listed, as listed, in
+// order
+func (h *Hierarchy) ToolPaths() []string {
+	tools := h.searchableTools()
+	paths := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		paths = append(paths, tool.tool.path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
 // HandleGetToolsInCategory handles the get_tools_in_category meta-tool
 // Returns a map with path, overview, children info, and tools
 func (h *Hierarchy) HandleGetToolsInCategory(path string) (map[string]interface{}, error) {
@@ -243,64 +333,32 @@ func (h *Hierarchy) HandleGetToolsInCategory(path string) (map[string]interface{
 
 	// Find child nodes
 	children := make(map[string]interface{})
-	allChildrenAreLeaves := true
-	aggregatedTools := make(map[string]interface{})
-
-	for nodePath := range h.nodes {
-		if nodePath == path || nodePath == "" {
+	for nodePath, childNode := range h.nodes {
+		childName, ok := childName(path, nodePath)
+		if !ok {
 			continue
 		}
-
-		// Check if this node is a direct child of the current path
-		var isDirectChild bool
-		var childName string
-
-		if path == "" {
-			// Root level - direct children have no dots
-			if !strings.Contains(nodePath, ".") {
-				isDirectChild = true
-				childName = nodePath
-			}
-		} else {
-			// Non-root - check if path is a prefix and child is one level deeper
-			if strings.HasPrefix(nodePath, path+".") {
-				remainder := strings.TrimPrefix(nodePath, path+".")
-				if !strings.Contains(remainder, ".") {
-					isDirectChild = true
-					childName = remainder
+		if len(childNode.Tools) > 0 {
+			// Leaf node
+			toolCount := 0
+			for toolName, toolDef := range childNode.Tools {
+				if h.isIncluded(toolName, toolDef) {
+					toolCount++
 				}
 			}
-		}
-
-		if isDirectChild {
-			childNode := h.nodes[nodePath]
-			if len(childNode.Tools) > 0 {
-				// Leaf node
+			if toolCount > 0 {
 				children[childName] = map[string]interface{}{
 					"is_leaf":    true,
-					"tool_count": len(childNode.Tools),
-				}
-
-				// Aggregate tools from leaf children
-				for toolName, toolDef := range childNode.Tools {
-					// In flat structure, nodePath already includes the tool name
-					// e.g., "everything.echo" not "everything.echo.echo"
-					toolPath := nodePath
-
-					aggregatedTools[toolName] = map[string]interface{}{
-						"description": toolDef.Description,
-						"tool_path":   toolPath,
-					}
+					"tool_count": toolCount,
 				}
-			} else {
-				// Branch node
-				allChildrenAreLeaves = false
-				childInfo := map[string]interface{}{}
-				if childNode.Overview != "" {
-					childInfo["overview"] = childNode.Overview
-				}
-				children[childName] = childInfo
 			}
+		} else {
+			// Branch node
+			childInfo := map[string]interface{}{}
+			if childNode.Overview != "" {
+				childInfo["overview"] = childNode.Overview
+			}
+			children[childName] = childInfo
 		}
 	}
 
@@ -308,34 +366,31 @@ func (h *Hierarchy) HandleGetToolsInCategory(path string) (map[string]interface{
 		response["children"] = children
 	}
 
-	// If this node has direct tools or all children are leaves, include tools
-	if len(node.Tools) > 0 {
-		// Node has direct tools
-		toolsInfo := make(map[string]interface{})
-		for toolName, toolDef := range node.Tools {
-			var toolPath string
-			if path == "" {
-				toolPath = toolName
-			} else {
-				toolPath = path + "." + toolName
-			}
-
-			toolsInfo[toolName] = map[string]interface{}{
-				"description": toolDef.Description,
-				"tool_path":   toolPath,
-			}
+	// The node's own tools, or those of its children if all are leaves
+	toolsInfo := make(map[string]interface{})
+	for _, tool := range h.listedTools(path) {
+		if _, listed := toolsInfo[tool.tool.name]; !listed {
+			toolsInfo[tool.tool.name] = tool.tool.info()
 		}
-		response["tools"] = toolsInfo
-	} else if allChildrenAreLeaves && len(aggregatedTools) > 0 {
-		// All children are leaves - include their tools
-		response["tools"] = aggregatedTools
-	} else {
-		response["tools"] = make(map[string]interface{})
 	}
+	response["tools"] = toolsInfo
 
+	h.applyTokenBudget(path, response)
 	return response, nil
 }
 
+// info describes the tool in a get_tools_in_category response
+func (t exposedTool) info() map[string]interface{} {
+	info := map[string]interface{}{
+		"description": t.description,
+		"tool_path":   t.path,
+	}
+	if len(t.aliases) > 0 {
+		info["aliases"] = t.aliases
+	}
+	return info
+}
+
 // ResolveToolPath resolves a tool path to its definition and server name
 // Returns the tool definition, server name (empty for meta-tools or if not configured), and any error
 func (h *Hierarchy) ResolveToolPath(toolPath string) (*ToolDefinition, string, error) {
@@ -400,64 +455,244 @@ func (h *Hierarchy) ResolveToolPath(toolPath string) (*ToolDefinition, string, e
 
 // HandleExecuteTool handles the execute_tool meta-tool
 func (h *Hierarchy) HandleExecuteTool(ctx context.Context, registry *ServerRegistry, toolPath string, arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, span := tracer.Start(ctx, "execute_tool", trace.WithAttributes(attrToolPath.String(toolPath)))
+	defer span.End()
+	if id := logging.RequestID(ctx); id != "" {
+		span.SetAttributes(attrRequestID.String(id))
+	}
+
+	call := audit.Call{ToolPath: toolPath, Arguments: arguments, Start: time.Now()}
+	toolDef, serverName, actualToolName, err := h.resolveCall(toolPath)
+	if err == nil {
+		call.Server, call.Tool = serverName, actualToolName
+		arguments = h.toolArguments(serverName, actualToolName, arguments)
+		toolCall := &ToolCall{ToolPath: toolPath, Server: serverName, Tool: actualToolName, Arguments: arguments}
+		call.Result, err = h.handleCall(ctx, toolCall, func(ctx context.Context, toolCall *ToolCall) (*mcp.CallToolResult, error) {
+			call.Arguments = toolCall.Arguments
+			if h.dryRun {
+				return h.simulateCall(ctx, toolDef, toolCall), nil
+			}
+			if err := h.approveCall(ctx, registry, toolPath, serverName, actualToolName, toolCall.Arguments); err != nil {
+				return nil, err
+			}
+			h.usage.record(toolPath, serverName)
+			return h.executeTool(ctx, registry, toolDef, serverName, actualToolName, toolPath, toolCall.Arguments)
+		})
+	}
+	call.Err = err
+	h.audit.Record(ctx, call)
+	registry.publish(ctx, Event{Type: EventToolCalled, Server: call.Server, ToolPath: toolPath, Tool: call.Tool, Duration: time.Since(call.Start), Err: err})
+
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case call.Result != nil && call.Result.IsError:
+		span.SetStatus(codes.Error, "tool returned an error")
+	}
+	return call.Result, err
+}
+
+// resolveCall resolves toolPath to the tool's definition, its server and the
+// server's name for the tool
+func (h *Hierarchy) resolveCall(toolPath string) (*ToolDefinition, string, string, error) {
 	// Resolve the tool path to get tool definition and server name
-	toolDef, serverName, err := h.ResolveToolPath(toolPath)
+	toolDef, serverName, err := h.ResolveExposedToolPath(toolPath)
 	if err != nil {
-		return nil, err
+		return nil, "", "", err
 	}
 
 	if serverName == "" {
-		return nil, fmt.Errorf("no MCP server configured for tool: %s", toolPath)
-	}
-
-	// Get or load the MCP client for this server
-	client, err := registry.GetOrLoadServer(ctx, serverName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get MCP client: %w", err)
+		return nil, "", "", fmt.Errorf("no MCP server configured for tool: %s", toolPath)
 	}
 
 	// Use the mapped tool name
 	actualToolName := toolDef.MapsTo
+	if listed, ok := h.listedTool(toolPath); ok && actualToolName == "" {
+		actualToolName = listed.original
+	}
 	if actualToolName == "" {
+		if resolved, ok := h.resolveExposedToolPath(toolPath); ok {
+			toolPath = resolved
+		}
 		actualToolName = strings.Split(toolPath, ".")[len(strings.Split(toolPath, "."))-1]
 	}
+	if h.included != nil && !h.included(serverName, actualToolName) {
+		return nil, "", "", fmt.Errorf("tool %s is excluded by the configuration of server %s", toolPath, serverName)
+	}
+	return toolDef, serverName, actualToolName, nil
+}
 
-	log.Printf("Executing tool: hierarchy_path=%s, server=%s, tool=%s", toolPath, serverName, actualToolName)
-
-	// Create a context with 30-second timeout for tool execution
-	// (increased from 15s to account for queuing time when serializing requests)
-	// Note: We create the timeout BEFORE acquiring the lock to enforce a total deadline
-	// for the operation. If we waited for the lock first, a client could hang indefinitely.
-	toolCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+// executeTool forwards a resolved call to its server
+func (h *Hierarchy) executeTool(ctx context.Context, registry *ServerRegistry, toolDef *ToolDefinition, serverName, actualToolName, toolPath string, arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx = logging.WithTool(logging.WithServer(ctx, serverName), actualToolName)
+	slog.InfoContext(ctx, "Executing tool", "path", toolPath)
+	trace.SpanFromContext(ctx).SetAttributes(attrServer.String(serverName), attrTool.String(actualToolName))
+
+	// Note: We create the timeout BEFORE acquiring a slot to enforce a total deadline
+	// for the operation. If we waited for the slot first, a client could hang indefinitely.
+	// The timeout is the cause of the context ending, which the server is told.
+	// A caller's deadline shortens it, so that retries cannot outlast the caller.
+	timeout, budgeted := registry.callBudget(ctx, serverName, toolDef)
+	timeoutErr := &TimeoutError{Server: serverName, Tool: actualToolName, Timeout: timeout}
+	toolCtx, cancel := context.WithTimeoutCause(ctx, timeoutErr.Timeout, timeoutErr)
 	defer cancel()
 
-	// Serialize tool calls to the same server to prevent concurrent stdio access.
-	// Stdio is a single-channel transport that cannot handle interleaved messages.
-	// See: https://github.com/voicetreelab/lazy-mcp/issues/8
-	mutex := registry.GetClientMutex(serverName)
-	mutex.Lock()
-	defer mutex.Unlock()
+	if cached, ok := registry.CachedResult(serverName, actualToolName, arguments); ok {
+		slog.DebugContext(ctx, "Serving cached tool result")
+		trace.SpanFromContext(ctx).SetAttributes(attrCacheHit.Bool(true))
+		return cached, nil
+	}
+	// Calls that would fail anyway are answered without waking the server
+	if registry.ValidatesArguments(serverName) {
+		if problems := validateArguments(toolDef.InputSchema, arguments); len(problems) > 0 {
+			argsErr := &ArgumentsError{Server: serverName, Tool: actualToolName, Problems: problems, Schema: h.toolSchema(toolDef, serverName, actualToolName)}
+			slog.WarnContext(ctx, "Tool call failed", "error", strings.Join(problems, "; "))
+			return nil, argsErr
+		}
+	}
+	// Not worth starting with the caller about to give up
+	if timeout <= 0 {
+		slog.WarnContext(ctx, "Tool call failed", "error", timeoutErr)
+		return nil, timeoutErr
+	}
+
+	// Fail fast rather than queue behind a server that keeps failing
+	if err := registry.checkCircuit(serverName); err != nil {
+		slog.WarnContext(ctx, "Tool call failed", "error", err)
+		return nil, newCallError(serverName, actualToolName, err, ErrorClassServer)
+	}
+	if err := registry.takeRateLimit(serverName, actualToolName); err != nil {
+		slog.WarnContext(ctx, "Tool call failed", "error", err)
+		return nil, err
+	}
 
-	// Call the tool on the actual MCP server
 	callRequest := mcp.CallToolRequest{}
 	callRequest.Params.Name = actualToolName
 	callRequest.Params.Arguments = arguments
+	if report := progressFromContext(ctx); report != nil {
+		token, done := registry.progress.add(serverName, report)
+		defer done()
+		callRequest.Params.Meta = &mcp.Meta{ProgressToken: token}
+	}
+	if id := logging.RequestID(ctx); id != "" && registry.ForwardsRequestID(serverName) {
+		callRequest.Params.Meta = withMeta(callRequest.Params.Meta, MetaRequestID, id)
+	}
+
+	// If the server process dies or its connection drops mid-call, retry
+	// against the restarted server as the server's retry policy allows
+	policy := registry.retryPolicy(serverName)
+	var result *mcp.CallToolResult
+	var err error
+	timings := &callTimings{start: time.Now()}
+	defer func() { registry.recordLatency(ctx, serverName, actualToolName, timings, err) }()
+	for attempt := 1; ; attempt++ {
+		timings.attempts = attempt
+		// Servers that honour MetaTimeout, such as other instances, stop
+		// working on the call once it has timed out here
+		if deadline, ok := toolCtx.Deadline(); ok {
+			callRequest.Params.Meta = withMetaTimeout(callRequest.Params.Meta, time.Until(deadline))
+		}
+
+		// Get or load the MCP client for this server
+		loadStart := time.Now()
+		mcpClient, loadErr := registry.GetOrLoadServer(toolCtx, serverName)
+		timings.startup += time.Since(loadStart)
+		if loadErr != nil {
+			err = loadErr
+			slog.WarnContext(ctx, "Tool call failed", "error", loadErr)
+			// A server awaiting authorization is not failing
+			var authErr *AuthorizationRequiredError
+			if errors.As(loadErr, &authErr) {
+				return nil, loadErr
+			}
+			if ctx.Err() == nil && !errors.Is(loadErr, ErrShuttingDown) {
+				registry.recordCircuit(serverName, true)
+			}
+			return nil, newCallError(serverName, actualToolName, loadErr, ErrorClassUnavailable)
+		}
 
-	result, err := client.GetClient().CallTool(toolCtx, callRequest)
+		// Bound concurrent tool calls to the same server. Stdio is a single-channel
+		// transport that cannot handle interleaved messages, so servers default to
+		// one call at a time; HTTP servers can opt into more via maxConcurrent.
+		// See: https://github.com/voicetreelab/lazy-mcp/issues/8
+		waitStart := time.Now()
+		var called time.Time
+		err = registry.WithClientLock(toolCtx, serverName, func(ctx context.Context) error {
+			called = time.Now()
+			// Call the tool on the actual MCP server
+			ctx, span := tracer.Start(ctx, "call_tool", trace.WithAttributes(
+				attrServer.String(serverName), attrTool.String(actualToolName), attrAttempt.Int(attempt)))
+			defer span.End()
+
+			var callErr error
+			result, callErr = mcpClient.CallTool(ctx, callRequest)
+			if callErr != nil {
+				span.RecordError(callErr)
+				span.SetStatus(codes.Error, callErr.Error())
+			}
+			return callErr
+		})
+		if called.IsZero() {
+			timings.wait += time.Since(waitStart)
+		} else {
+			timings.wait += called.Sub(waitStart)
+			timings.downstream += time.Since(called)
+		}
+		if err == nil || !policy.retryable(toolCtx, err, attempt, registry.isIdempotent(toolCtx, serverName, actualToolName)) {
+			break
+		}
+		slog.WarnContext(ctx, "Tool call failed, retrying", "error", err, "attempt", attempt)
+		if policy.wait(toolCtx, attempt) != nil {
+			break
+		}
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Tool call failed", "error", err)
+	}
+	// Reported as they are, though the call's time may have run out meanwhile
+	if errors.Is(err, ErrLockTimeout) || errors.Is(err, ErrCallHung) {
+		return nil, newCallError(serverName, actualToolName, err, ErrorClassServer)
+	}
+	if err != nil && ctx.Err() == nil && context.Cause(toolCtx) == timeoutErr {
+		// Running out of the caller's budget says nothing about the server
+		if !budgeted {
+			registry.recordCallTimeout(serverName, timeoutErr)
+			registry.recordCircuit(serverName, true)
+		}
+		return nil, timeoutErr
+	}
+	registry.recordCallTimeout(serverName, nil)
+	if ctx.Err() == nil {
+		registry.recordCircuit(serverName, isUnavailable(err))
+	}
 	if err != nil {
+		// Canceled by the client, which is no longer waiting for an answer
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to call tool %s: %w", actualToolName, err)
+		}
+		callErr := newCallError(serverName, actualToolName, err, ErrorClassServer)
 		// Include inputSchema in error message to help LLMs self-correct parameter mistakes
-		if toolDef.InputSchema != nil {
-			schemaJSON, marshalErr := json.MarshalIndent(toolDef.InputSchema, "", "  ")
+		if callErr.Class == ErrorClassServer && toolDef.InputSchema != nil {
+			schemaJSON, marshalErr := json.MarshalIndent(h.toolSchema(toolDef, serverName, actualToolName), "", "  ")
 			if marshalErr == nil {
-				return nil, fmt.Errorf("failed to call tool %s: %w\n\nExpected inputSchema:\n%s", actualToolName, err, string(schemaJSON))
+				return nil, fmt.Errorf("%w\n\nExpected inputSchema:\n%s", callErr, string(schemaJSON))
 			}
 		}
-		return nil, fmt.Errorf("failed to call tool %s: %w", actualToolName, err)
+		return nil, callErr
+	}
+	if outputErr := registry.checkOutput(toolCtx, serverName, actualToolName, result); outputErr != nil {
+		slog.WarnContext(ctx, "Tool call failed", "error", outputErr)
+		return nil, outputErr
 	}
+	result = registry.proxyContent(toolCtx, serverName, actualToolName, result)
+	result = registry.transformResult(toolCtx, serverName, actualToolName, result)
+	result = registry.limitResult(toolCtx, serverName, actualToolName, result)
+	registry.CacheResult(toolCtx, serverName, actualToolName, arguments, result)
 
 	// Check if result has IsError set - append schema to help LLMs self-correct
 	if result != nil && result.IsError && toolDef.InputSchema != nil && len(result.Content) > 0 {
-		schemaJSON, marshalErr := json.MarshalIndent(toolDef.InputSchema, "", "  ")
+		schemaJSON, marshalErr := json.MarshalIndent(h.toolSchema(toolDef, serverName, actualToolName), "", "  ")
 		if marshalErr == nil {
 			// Append schema to the first text content item
 			// Note: TextContent is a value type, so we modify the copy and assign it back to the slice
@@ -469,116 +704,3 @@ func (h *Hierarchy) HandleExecuteTool(ctx context.Context, registry *ServerRegis
 	}
 	return result, nil
 }
-
-// ServerRegistry manages MCP client connections
-type ServerRegistry struct {
-	clients       map[string]*client.Client
-	clientMutex   map[string]*sync.Mutex // Per-client mutex for serializing tool calls
-	serverConfigs map[string]*config.MCPClientConfigV2
-	mu            sync.RWMutex
-}
-
-// NewServerRegistry creates a new server registry with server configurations
-func NewServerRegistry(serverConfigs map[string]*config.MCPClientConfigV2) *ServerRegistry {
-	return &ServerRegistry{
-		clients:       make(map[string]*client.Client),
-		clientMutex:   make(map[string]*sync.Mutex),
-		serverConfigs: serverConfigs,
-	}
-}
-
-// GetClientMutex returns a mutex for the given server, creating one if needed.
-// This mutex serializes tool calls to prevent concurrent stdio access.
-// Note: This map grows with the number of unique servers accessed. Since the set of
-// servers is bounded by the configuration/hierarchy, this is not a memory leak.
-func (r *ServerRegistry) GetClientMutex(serverName string) *sync.Mutex {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	if m, exists := r.clientMutex[serverName]; exists {
-		return m
-	}
-
-	m := &sync.Mutex{}
-	r.clientMutex[serverName] = m
-	return m
-}
-
-// GetOrLoadServer gets an existing client or creates and initializes a new one
-// This implements lazy loading - servers are only started when first accessed
-func (r *ServerRegistry) GetOrLoadServer(ctx context.Context, serverName string) (*client.Client, error) {
-	// First check with read lock
-	r.mu.RLock()
-	if client, exists := r.clients[serverName]; exists {
-		r.mu.RUnlock()
-		return client, nil
-	}
-	r.mu.RUnlock()
-
-	// Need to create, use write lock
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	// Check again in case another goroutine created it
-	if client, exists := r.clients[serverName]; exists {
-		return client, nil
-	}
-
-	// Look up the server config
-	cfg, exists := r.serverConfigs[serverName]
-	if !exists {
-		return nil, fmt.Errorf("server config not found: %s", serverName)
-	}
-
-	// Create the MCP client
-	mcpClient, err := client.NewMCPClient(serverName, cfg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create MCP client: %w", err)
-	}
-
-	// Start the client if needed
-	if mcpClient.NeedManualStart() {
-		err := mcpClient.GetClient().Start(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("failed to start MCP client: %w", err)
-		}
-	}
-
-	// Initialize the client
-	initRequest := mcp.InitializeRequest{}
-	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
-	initRequest.Params.ClientInfo = mcp.Implementation{Name: "mcp-proxy-recursive"}
-	initRequest.Params.Capabilities = mcp.ClientCapabilities{}
-
-	_, err = mcpClient.GetClient().Initialize(ctx, initRequest)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize MCP client: %w", err)
-	}
-
-	log.Printf("Created and initialized MCP client for server: %s", serverName)
-
-	// Store the client
-	r.clients[serverName] = mcpClient
-
-	// Start ping task if needed
-	if mcpClient.NeedPing() {
-		go mcpClient.StartPingTask(ctx)
-	}
-
-	return mcpClient, nil
-}
-
-// Close closes all clients in the registry
-func (r *ServerRegistry) Close() {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	for name, client := range r.clients {
-		log.Printf("Closing MCP client: %s", name)
-		_ = client.Close()
-	}
-
-	// Clear the clients and mutex maps
-	r.clients = make(map[string]*client.Client)
-	r.clientMutex = make(map[string]*sync.Mutex)
-}
@@ -0,0 +1,166 @@
+package hierarchy
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// DefaultSlowCallThreshold is how long a tool call may take before it is
+// logged as slow, when slowCallThreshold is not configured
+const DefaultSlowCallThreshold = 10 * time.Second
+
+// latencyBuckets are the upper bounds of the buckets of latency histograms:
+// 1ms doubling up to about two minutes, past which the last bucket takes all
+var latencyBuckets = func() []time.Duration {
+	var buckets []time.Duration
+	for bound := time.Millisecond; bound < 3*time.Minute; bound *= 2 {
+		buckets = append(buckets, bound)
+	}
+	return buckets
+}()
+
+// latencyHistogram counts the calls to a tool by how long they took
+type latencyHistogram struct {
+	counts  []int // Per bucket of latencyBuckets, and one for longer calls
+	calls   int
+	longest time.Duration
+}
+
+func (h *latencyHistogram) record(latency time.Duration) {
+	if h.counts == nil {
+		h.counts = make([]int, len(latencyBuckets)+1)
+	}
+	h.counts[sort.Search(len(latencyBuckets), func(i int) bool { return latencyBuckets[i] >= latency })]++
+	h.calls++
+	h.longest = max(h.longest, latency)
+}
+
+// percentile returns the latency within which the fraction p of calls took,
+// rounded up to its bucket's bound
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	rank := max(int(math.Ceil(p*float64(h.calls))), 1)
+	seen := 0
+	for i, count := range h.counts[:len(latencyBuckets)] {
+		seen += count
+		if seen >= rank {
+			return min(latencyBuckets[i], h.longest)
+		}
+	}
+	return h.longest
+}
+
+// ToolLatency is how long the calls to a tool of a server took since
+// lazy-mcp started
+type ToolLatency struct {
+	Server string          `json:"server"`
+	Tool   string          `json:"tool"` // As the server names it
+	Calls  int             `json:"calls"`
+	P50    config.Duration `json:"p50"`
+	P90    config.Duration `json:"p90"`
+	P99    config.Duration `json:"p99"`
+	Max    config.Duration `json:"max"`
+}
+
+// toolKey identifies a tool of a server
+type toolKey struct {
+	server string
+	tool   string
+}
+
+// latencies are the latency histograms of the tools called
+type latencies struct {
+	mu         sync.Mutex
+	histograms map[toolKey]*latencyHistogram
+}
+
+func newLatencies() *latencies {
+	return &latencies{histograms: make(map[toolKey]*latencyHistogram)}
+}
+
+func (l *latencies) record(serverName, toolName string, latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := toolKey{serverName, toolName}
+	histogram, exists := l.histograms[key]
+	if !exists {
+		histogram = &latencyHistogram{}
+		l.histograms[key] = histogram
+	}
+	histogram.record(latency)
+}
+
+// callTimings break down where the time of a tool call went
+type callTimings struct {
+	start      time.Time
+	startup    time.Duration // Getting the server's client, starting it if need be
+	wait       time.Duration // Waiting for call slots
+	downstream time.Duration // The server working on the call
+	attempts   int
+}
+
+// SlowCallThreshold returns how long a call to the given server may take
+// before it is logged as slow. Zero means calls are never logged as slow.
+func (r *ServerRegistry) SlowCallThreshold(serverName string) time.Duration {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil {
+		return DefaultSlowCallThreshold
+	}
+	return cfg.Options.SlowCallThreshold.OrElse(config.Duration(DefaultSlowCallThreshold)).Std()
+}
+
+// recordLatency adds a call that reached the given server to its tool's
+// latency histogram, and logs it if it was slow, with where its time went
+func (r *ServerRegistry) recordLatency(ctx context.Context, serverName, toolName string, timings *callTimings, err error) {
+	latency := time.Since(timings.start)
+	r.latencies.record(serverName, toolName, latency)
+
+	threshold := r.SlowCallThreshold(serverName)
+	if threshold <= 0 || latency < threshold {
+		return
+	}
+	attrs := []any{
+		"duration", latency.Round(time.Millisecond),
+		"threshold", threshold,
+		"startup", timings.startup.Round(time.Millisecond),
+		"wait", timings.wait.Round(time.Millisecond),
+		"downstream", timings.downstream.Round(time.Millisecond),
+		"attempts", timings.attempts,
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	slog.WarnContext(ctx, "Slow tool call", attrs...)
+}
+
+// ToolLatencies returns the latency percentiles of every tool called, by
+// server and tool
+func (r *ServerRegistry) ToolLatencies() []ToolLatency {
+	r.latencies.mu.Lock()
+	defer r.latencies.mu.Unlock()
+
+	result := make([]ToolLatency, 0, len(r.latencies.histograms))
+	for key, histogram := range r.latencies.histograms {
+		result = append(result, ToolLatency{
+			Server: key.server,
+			Tool:   key.tool,
+			Calls:  histogram.calls,
+			P50:    config.Duration(histogram.percentile(0.50).Round(time.Microsecond)),
+			P90:    config.Duration(histogram.percentile(0.90).Round(time.Microsecond)),
+			P99:    config.Duration(histogram.percentile(0.99).Round(time.Microsecond)),
+			Max:    config.Duration(histogram.longest.Round(time.Microsecond)),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Server != result[j].Server {
+			return result[i].Server < result[j].Server
+		}
+		return result[i].Tool < result[j].Tool
+	})
+	return result
+}
@@ -0,0 +1,94 @@
+package hierarchy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// TestLatencyHistogramPercentiles verifies that percentiles are read off the
+// buckets, bounded by the longest call.
+func TestLatencyHistogramPercentiles(t *testing.T) {
+	var h latencyHistogram
+	for range 98 {
+		h.record(3 * time.Millisecond)
+	}
+	h.record(100 * time.Millisecond)
+	h.record(5 * time.Minute)
+
+	assert.Equal(t, 100, h.calls)
+	assert.Equal(t, 4*time.Millisecond, h.percentile(0.50))
+	assert.Equal(t, 4*time.Millisecond, h.percentile(0.90))
+	assert.Equal(t, 128*time.Millisecond, h.percentile(0.99))
+	assert.Equal(t, 5*time.Minute, h.percentile(1))
+
+	var single latencyHistogram
+	single.record(1500 * time.Microsecond)
+	assert.Equal(t, 1500*time.Microsecond, single.percentile(0.99))
+}
+
+// TestSlowCallsAreLogged verifies that calls are added to their tool's
+// latencies, and that those over slowCallThreshold are logged with where
+// their time went.
+func TestSlowCallsAreLogged(t *testing.T) {
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	var buf bytes.Buffer
+	require.NoError(t, logging.Setup(&buf, &config.Config{McpProxy: &config.MCPProxyConfigV2{Options: &config.OptionsV2{
+		LogFormat: optional.NewField("json"),
+	}}}))
+
+	slow := server.NewMCPServer("slow", "1.0.0")
+	slow.AddTool(mcp.NewTool("nap"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		time.Sleep(60 * time.Millisecond)
+		return mcp.NewToolResultText("rested"), nil
+	})
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"nap": {Server: "slow"}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"slow": {Options: &config.OptionsV2{
+			SlowCallThreshold: optional.NewField(config.Duration(50 * time.Millisecond)),
+		}}},
+		map[string]*server.MCPServer{"slow": slow},
+		nil,
+	)
+	defer registry.Close()
+
+	_, err := h.HandleExecuteTool(context.Background(), registry, "nap", nil)
+	require.NoError(t, err)
+
+	latencies := registry.ToolLatencies()
+	require.Len(t, latencies, 1)
+	assert.Equal(t, "slow", latencies[0].Server)
+	assert.Equal(t, "nap", latencies[0].Tool)
+	assert.Equal(t, 1, latencies[0].Calls)
+	assert.GreaterOrEqual(t, latencies[0].Max.Std(), 60*time.Millisecond)
+
+	var logged map[string]interface{}
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var record map[string]interface{}
+		require.NoError(t, decoder.Decode(&record))
+		if record["msg"] == "Slow tool call" {
+			logged = record
+		}
+	}
+	require.NotNil(t, logged, "the slow call is logged")
+	assert.Equal(t, "slow", logged["server"])
+	assert.Equal(t, "nap", logged["tool"])
+	assert.EqualValues(t, 1, logged["attempts"])
+	assert.GreaterOrEqual(t, logged["downstream"], float64(60*time.Millisecond))
+	assert.Less(t, logged["wait"], float64(50*time.Millisecond))
+}
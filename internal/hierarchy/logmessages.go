@@ -0,0 +1,94 @@
+package hierarchy
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/client"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// MethodNotificationMessage is the method of log message notifications, for
+// which mcp-go has no constant
+const MethodNotificationMessage = "notifications/message"
+
+// LogMessageFunc relays a server's log message to the client session of the
+// request ctx belongs to
+type LogMessageFunc func(ctx context.Context, notification mcp.LoggingMessageNotification)
+
+// OnLogMessage registers fn to relay the log messages of servers to clients.
+// fn is called in the order the messages arrive, and must not block.
+func (r *ServerRegistry) OnLogMessage(fn LogMessageFunc) {
+	r.onLogMessage.Store(&fn)
+}
+
+// LogMessageLevel returns the level of log messages the given server is set
+// to send per its logMessageLevel option, if it has one
+func (r *ServerRegistry) LogMessageLevel(serverName string) (mcp.LoggingLevel, bool) {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil || !cfg.Options.LogMessageLevel.Present() {
+		return "", false
+	}
+	return mcp.LoggingLevel(cfg.Options.LogMessageLevel.OrElse("")), true
+}
+
+// SetLogMessageLevel sets the level of log messages clients asked for on
+// every running server that supports logging, and on those started later.
+// Servers with a logMessageLevel of their own keep it.
+func (r *ServerRegistry) SetLogMessageLevel(ctx context.Context, level mcp.LoggingLevel) {
+	r.logLevel.Store(&level)
+	r.mu.RLock()
+	clients := make(map[string]*client.Client, len(r.servers))
+	for name, state := range r.servers {
+		clients[name] = state.client
+	}
+	r.mu.RUnlock()
+	for name, mcpClient := range clients {
+		r.setServerLogLevel(ctx, name, mcpClient)
+	}
+}
+
+// setServerLogLevel sends a server the level of log messages to send, its
+// own or else the one clients asked for, if either is set and the server
+// supports logging
+func (r *ServerRegistry) setServerLogLevel(ctx context.Context, serverName string, mcpClient *client.Client) {
+	if mcpClient.GetClient().GetServerCapabilities().Logging == nil {
+		return
+	}
+	level, ok := r.LogMessageLevel(serverName)
+	if !ok {
+		requested := r.logLevel.Load()
+		if requested == nil {
+			return
+		}
+		level = *requested
+	}
+	request := mcp.SetLevelRequest{}
+	request.Params.Level = level
+	if err := mcpClient.GetClient().SetLevel(ctx, request); err != nil {
+		logging.ForServer(serverName).WarnContext(ctx, "Failed to set log message level", "level", level, "error", err)
+	}
+}
+
+// logMessage relays a log message from the given server to the client that
+// used it last, under a logger named after the server. Messages below the
+// server's logMessageLevel are dropped, for servers that send them anyway.
+func (r *ServerRegistry) logMessage(serverName string, notification mcp.JSONRPCNotification) {
+	fields := notification.Params.AdditionalFields
+	level, _ := fields["level"].(string)
+	if pinned, ok := r.LogMessageLevel(serverName); ok && !mcp.LoggingLevel(level).ShouldSendTo(pinned) {
+		return
+	}
+	logger := serverName
+	if name, _ := fields["logger"].(string); name != "" {
+		logger += "." + name
+	}
+	fn := r.onLogMessage.Load()
+	ctx, ok := r.lastCaller(serverName)
+	if fn == nil || !ok {
+		slog.Debug("Dropping log message with no client to relay it to", "server", serverName, "level", level)
+		return
+	}
+	(*fn)(ctx, mcp.NewLoggingMessageNotification(mcp.LoggingLevel(level), logger, fields["data"]))
+}
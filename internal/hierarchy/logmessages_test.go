@@ -0,0 +1,102 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// newLoggingServer returns a server whose work tool logs a message at each
+// level it is given
+func newLoggingServer(name string) *server.MCPServer {
+	mcpServer := server.NewMCPServer(name, "1.0.0", server.WithLogging())
+	mcpServer.AddTool(mcp.NewTool("work"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		for _, level := range request.GetStringSlice("levels", nil) {
+			notification := mcp.NewLoggingMessageNotification(mcp.LoggingLevel(level), "db", level+" from "+name)
+			if err := mcpServer.SendLogMessageToClient(ctx, notification); err != nil {
+				return nil, err
+			}
+		}
+		return mcp.NewToolResultText("done"), nil
+	})
+	return mcpServer
+}
+
+// TestLogMessagesAreRelayedToTheCaller verifies that a server's log messages
+// reach the client that called it, under a logger named after the server,
+// at the level the client set, and that a server's logMessageLevel takes
+// precedence over it.
+func TestLogMessagesAreRelayedToTheCaller(t *testing.T) {
+	chatty := server.NewTestServer(newLoggingServer("chatty"))
+	defer chatty.Close()
+	quiet := server.NewTestServer(newLoggingServer("quiet"))
+	defer quiet.Close()
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{
+			"chatty": {Server: "chatty", MapsTo: "work"},
+			"quiet":  {Server: "quiet", MapsTo: "work"},
+		}},
+	}}
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"chatty": {Type: config.MCPClientTypeSSE, URL: chatty.URL + "/sse", Options: &config.OptionsV2{}},
+		"quiet": {Type: config.MCPClientTypeSSE, URL: quiet.URL + "/sse", Options: &config.OptionsV2{
+			LogMessageLevel: optional.NewField("warning"),
+		}},
+	})
+	defer registry.Close()
+
+	messages := make(chan mcp.LoggingMessageNotification, 10)
+	registry.OnLogMessage(func(ctx context.Context, notification mcp.LoggingMessageNotification) {
+		caller, _ := ctx.Value(callerKey{}).(string)
+		assert.Equal(t, "alice", caller)
+		messages <- notification
+	})
+	registry.SetLogMessageLevel(context.Background(), mcp.LoggingLevelInfo)
+
+	receive := func() mcp.LoggingMessageNotification {
+		t.Helper()
+		select {
+		case message := <-messages:
+			return message
+		case <-time.After(time.Second):
+			t.Fatal("log message not relayed")
+			return mcp.LoggingMessageNotification{}
+		}
+	}
+
+	ctx := context.WithValue(context.Background(), callerKey{}, "alice")
+	levels := map[string]any{"levels": []any{"debug", "info", "warning"}}
+	result, err := h.HandleExecuteTool(ctx, registry, "chatty", levels)
+	require.NoError(t, err)
+	require.False(t, result.IsError, "%v", result.Content)
+	message := receive()
+	assert.Equal(t, mcp.LoggingLevelInfo, message.Params.Level)
+	assert.Equal(t, "chatty.db", message.Params.Logger)
+	assert.Equal(t, "info from chatty", message.Params.Data)
+	assert.Equal(t, mcp.LoggingLevelWarning, receive().Params.Level)
+
+	result, err = h.HandleExecuteTool(ctx, registry, "quiet", levels)
+	require.NoError(t, err)
+	require.False(t, result.IsError, "%v", result.Content)
+	message = receive()
+	assert.Equal(t, mcp.LoggingLevelWarning, message.Params.Level)
+	assert.Equal(t, "quiet.db", message.Params.Logger)
+
+	// Lowering the level reaches running servers, except those with their own
+	registry.SetLogMessageLevel(context.Background(), mcp.LoggingLevelDebug)
+	levels = map[string]any{"levels": []any{"debug"}}
+	_, err = h.HandleExecuteTool(ctx, registry, "quiet", levels)
+	require.NoError(t, err)
+	_, err = h.HandleExecuteTool(ctx, registry, "chatty", levels)
+	require.NoError(t, err)
+	assert.Equal(t, "debug from chatty", receive().Params.Data)
+	assert.Empty(t, messages)
+}
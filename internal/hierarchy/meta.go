@@ -0,0 +1,39 @@
+package hierarchy
+
+import (
+	"maps"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// MetaRequestID is the _meta field of a tools/call request that carries its
+// request ID, which a client may send for lazy-mcp to log the call under, and
+// which calls forwarded to servers with forwardRequestId carry
+const MetaRequestID = "requestId"
+
+// MetaRequestIDOf returns the MetaRequestID a request's _meta carries, if any
+func MetaRequestIDOf(meta *mcp.Meta) string {
+	if meta == nil {
+		return ""
+	}
+	id, _ := meta.AdditionalFields[MetaRequestID].(string)
+	return id
+}
+
+// withMeta returns a copy of meta with the given field set
+func withMeta(meta *mcp.Meta, key string, value any) *mcp.Meta {
+	copied := &mcp.Meta{AdditionalFields: map[string]any{}}
+	if meta != nil {
+		copied.ProgressToken = meta.ProgressToken
+		maps.Copy(copied.AdditionalFields, meta.AdditionalFields)
+	}
+	copied.AdditionalFields[key] = value
+	return copied
+}
+
+// ForwardsRequestID reports whether calls to the given server carry their
+// request ID in their _meta
+func (r *ServerRegistry) ForwardsRequestID(serverName string) bool {
+	cfg, exists := r.serverConfig(serverName)
+	return exists && cfg.Options != nil && cfg.Options.ForwardRequestID.OrElse(false)
+}
@@ -0,0 +1,48 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// TestRequestIDIsForwarded verifies that a call's request ID is passed on in
+// its _meta only to servers with forwardRequestId.
+func TestRequestIDIsForwarded(t *testing.T) {
+	received := make(map[string]string)
+	newServer := func(name string) *server.MCPServer {
+		s := server.NewMCPServer(name, "1.0.0")
+		s.AddTool(mcp.NewTool("whoami"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			received[name] = MetaRequestIDOf(request.Params.Meta)
+			return mcp.NewToolResultText(name), nil
+		})
+		return s
+	}
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"cooperating": {Tools: map[string]*ToolDefinition{"whoami": {Server: "cooperating"}}},
+		"plain":       {Tools: map[string]*ToolDefinition{"whoami": {Server: "plain"}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"cooperating": {Options: &config.OptionsV2{ForwardRequestID: optional.NewField(true)}},
+			"plain":       {},
+		},
+		map[string]*server.MCPServer{"cooperating": newServer("cooperating"), "plain": newServer("plain")},
+		nil,
+	)
+	defer registry.Close()
+
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	for _, toolPath := range []string{"cooperating.whoami", "plain.whoami"} {
+		_, err := h.HandleExecuteTool(ctx, registry, toolPath, nil)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, map[string]string{"cooperating": "req-1", "plain": ""}, received)
+}
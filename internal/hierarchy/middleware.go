@@ -0,0 +1,38 @@
+package hierarchy
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ToolCall is a call made through HandleExecuteTool, resolved to the server
+// and the server's own name for the tool
+type ToolCall struct {
+	ToolPath  string
+	Server    string
+	Tool      string
+	Arguments map[string]interface{}
+}
+
+// CallHandler handles a resolved tool call
+type CallHandler func(ctx context.Context, call *ToolCall) (*mcp.CallToolResult, error)
+
+// CallMiddleware wraps the handling of tool calls, e.g. to enforce a policy,
+// rewrite arguments or transform results. Only changes to the call's
+// Arguments are forwarded to the server.
+type CallMiddleware func(next CallHandler) CallHandler
+
+// Use makes HandleExecuteTool pass every resolved call through middlewares,
+// the first outermost, before approval is asked for and the call is forwarded
+func (h *Hierarchy) Use(middlewares ...CallMiddleware) {
+	h.middlewares = append(h.middlewares, middlewares...)
+}
+
+// handleCall passes call through the middlewares to next
+func (h *Hierarchy) handleCall(ctx context.Context, call *ToolCall, next CallHandler) (*mcp.CallToolResult, error) {
+	for i := len(h.middlewares) - 1; i >= 0; i-- {
+		next = h.middlewares[i](next)
+	}
+	return next(ctx, call)
+}
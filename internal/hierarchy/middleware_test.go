@@ -0,0 +1,65 @@
+package hierarchy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestMiddlewaresWrapCalls verifies that middlewares run outermost first, can
+// rewrite arguments and results, and can refuse calls before they reach the
+// server.
+func TestMiddlewaresWrapCalls(t *testing.T) {
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"echo": {Server: "echo"}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"echo": {}},
+		map[string]*server.MCPServer{"echo": newEchoServer()},
+		nil,
+	)
+	defer registry.Close()
+
+	var order []string
+	h.Use(
+		func(next CallHandler) CallHandler {
+			return func(ctx context.Context, call *ToolCall) (*mcp.CallToolResult, error) {
+				order = append(order, "outer")
+				if call.Arguments["message"] == "forbidden" {
+					return nil, errors.New("refused")
+				}
+				return next(ctx, call)
+			}
+		},
+		func(next CallHandler) CallHandler {
+			return func(ctx context.Context, call *ToolCall) (*mcp.CallToolResult, error) {
+				order = append(order, "inner")
+				assert.Equal(t, ToolCall{ToolPath: "echo", Server: "echo", Tool: "echo", Arguments: call.Arguments}, *call)
+				call.Arguments = map[string]interface{}{"message": "rewritten"}
+				result, err := next(ctx, call)
+				if err == nil {
+					result.Content = append(result.Content, mcp.NewTextContent("appended"))
+				}
+				return result, err
+			}
+		},
+	)
+	ctx := context.Background()
+
+	result, err := h.HandleExecuteTool(ctx, registry, "echo", map[string]interface{}{"message": "hi"})
+	require.NoError(t, err)
+	require.Len(t, result.Content, 2)
+	assert.Equal(t, "rewritten", result.Content[0].(mcp.TextContent).Text)
+	assert.Equal(t, "appended", result.Content[1].(mcp.TextContent).Text)
+	assert.Equal(t, []string{"outer", "inner"}, order)
+
+	_, err = h.HandleExecuteTool(ctx, registry, "echo", map[string]interface{}{"message": "forbidden"})
+	assert.EqualError(t, err, "refused")
+	assert.Equal(t, []string{"outer", "inner", "outer"}, order)
+}
@@ -0,0 +1,33 @@
+package hierarchy
+
+import (
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// AuthorizationRequiredError is returned for a server that requires OAuth
+// until the user grants access at URL
+type AuthorizationRequiredError struct {
+	Server string
+	URL    string
+}
+
+func (e *AuthorizationRequiredError) Error() string {
+	return fmt.Sprintf("server %s requires authorization: open %s to grant access, then retry", e.Server, e.URL)
+}
+
+// authorizationRequired turns the failure of a server to start because it
+// requires OAuth into an AuthorizationRequiredError, beginning the
+// authorization in the background. Other errors are returned as they are.
+func (r *ServerRegistry) authorizationRequired(serverName string, cfg *config.MCPClientConfigV2, err error) error {
+	if cfg.OAuth == nil || !client.IsOAuthAuthorizationRequiredError(err) {
+		return err
+	}
+	authURL, startErr := r.authorizer.Start(r.ctx, serverName, cfg.OAuth, client.GetOAuthHandler(err))
+	if startErr != nil {
+		return fmt.Errorf("server %s requires authorization: %w", serverName, startErr)
+	}
+	return &AuthorizationRequiredError{Server: serverName, URL: authURL}
+}
@@ -0,0 +1,94 @@
+package hierarchy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// OutputError is returned, with outputValidation "reject", for a tool call
+// whose result does not match the outputSchema the tool declares
+type OutputError struct {
+	Server   string
+	Tool     string
+	Problems []string
+}
+
+func (e *OutputError) Error() string {
+	return fmt.Sprintf("tool %s on server %s returned a result that does not match its outputSchema: %s", e.Tool, e.Server, strings.Join(e.Problems, "; "))
+}
+
+// OutputValidation returns what is done with results of the given server's
+// tools that do not match their outputSchema: one of config's
+// OutputValidation modes
+func (r *ServerRegistry) OutputValidation(serverName string) string {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil {
+		return config.OutputValidationWarn
+	}
+	return cfg.Options.OutputValidation.OrElse(config.OutputValidationWarn)
+}
+
+// checkOutput checks a successful result of the given tool against the
+// outputSchema the tool declares, if any. A mismatch is logged, or returned
+// as an OutputError if the server's outputValidation rejects them. The result
+// itself, structuredContent included, is never changed.
+func (r *ServerRegistry) checkOutput(ctx context.Context, serverName, toolName string, result *mcp.CallToolResult) error {
+	mode := r.OutputValidation(serverName)
+	if mode == config.OutputValidationOff || result == nil || result.IsError {
+		return nil
+	}
+	schema := r.outputSchema(ctx, serverName, toolName)
+	if schema == nil {
+		return nil
+	}
+	problems := validateOutput(schema, result.StructuredContent)
+	if len(problems) == 0 {
+		return nil
+	}
+	if mode == config.OutputValidationReject {
+		return &OutputError{Server: serverName, Tool: toolName, Problems: problems}
+	}
+	slog.WarnContext(ctx, "Tool result does not match its outputSchema", "problems", strings.Join(problems, "; "))
+	return nil
+}
+
+// outputSchema returns the outputSchema the given tool of a running server
+// declares in its listing, or nil
+func (r *ServerRegistry) outputSchema(ctx context.Context, serverName, toolName string) map[string]interface{} {
+	tools, err := r.GetServerTools(ctx, serverName)
+	if err != nil {
+		return nil
+	}
+	for _, tool := range tools {
+		if tool.Name == toolName {
+			return schemaOf(tool, "outputSchema")
+		}
+	}
+	return nil
+}
+
+// validateOutput checks a result's structured content against an output
+// schema, returning a problem for each mismatch as validateArguments does
+func validateOutput(schema map[string]interface{}, structured any) []string {
+	if structured == nil {
+		return []string{"structuredContent: is required by the outputSchema"}
+	}
+	// As decoded from JSON, whatever the client made of it
+	data, err := json.Marshal(structured)
+	if err != nil {
+		return []string{fmt.Sprintf("structuredContent: %v", err)}
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return []string{fmt.Sprintf("structuredContent: %v", err)}
+	}
+	var problems []string
+	validateValue(schema, "structuredContent", value, &problems)
+	return problems
+}
@@ -0,0 +1,76 @@
+package hierarchy
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// newWeatherServer returns a server whose forecast tool declares an
+// outputSchema and returns the given structured content
+func newWeatherServer(structured map[string]any) *server.MCPServer {
+	weather := server.NewMCPServer("weather", "1.0.0")
+	schema := json.RawMessage(`{"type": "object", "properties": {"celsius": {"type": "number"}}, "required": ["celsius"]}`)
+	weather.AddTool(mcp.NewTool("forecast", mcp.WithRawOutputSchema(schema)), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultStructured(structured, "forecast"), nil
+	})
+	return weather
+}
+
+// TestOutputValidation verifies that results matching the tool's outputSchema
+// pass through untouched, and that those that do not are logged or rejected
+// as outputValidation says.
+func TestOutputValidation(t *testing.T) {
+	valid := map[string]any{"celsius": 21.5, "note": "sunny"}
+	invalid := map[string]any{"celsius": "warm"}
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"valid":    {Tools: map[string]*ToolDefinition{"forecast": {Server: "valid"}}},
+		"warned":   {Tools: map[string]*ToolDefinition{"forecast": {Server: "warned"}}},
+		"rejected": {Tools: map[string]*ToolDefinition{"forecast": {Server: "rejected"}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"valid":    {Options: &config.OptionsV2{OutputValidation: optional.NewField(config.OutputValidationReject)}},
+			"warned":   {},
+			"rejected": {Options: &config.OptionsV2{OutputValidation: optional.NewField(config.OutputValidationReject)}},
+		},
+		map[string]*server.MCPServer{
+			"valid":    newWeatherServer(valid),
+			"warned":   newWeatherServer(invalid),
+			"rejected": newWeatherServer(invalid),
+		},
+		nil,
+	)
+	defer registry.Close()
+
+	result, err := h.HandleExecuteTool(context.Background(), registry, "valid.forecast", nil)
+	require.NoError(t, err)
+	assert.Equal(t, valid, result.StructuredContent)
+
+	result, err = h.HandleExecuteTool(context.Background(), registry, "warned.forecast", nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"celsius": "warm"}, result.StructuredContent)
+
+	_, err = h.HandleExecuteTool(context.Background(), registry, "rejected.forecast", nil)
+	var outputErr *OutputError
+	require.ErrorAs(t, err, &outputErr)
+	assert.Equal(t, "rejected", outputErr.Server)
+	assert.Equal(t, []string{"structuredContent.celsius: expected number, got string"}, outputErr.Problems)
+}
+
+// TestValidateOutputRequiresStructuredContent verifies that a tool declaring
+// an outputSchema must return structured content.
+func TestValidateOutputRequiresStructuredContent(t *testing.T) {
+	assert.Equal(t, []string{"structuredContent: is required by the outputSchema"},
+		validateOutput(map[string]interface{}{"type": "object"}, nil))
+	assert.Empty(t, validateOutput(map[string]interface{}{"type": "object"}, struct {
+		Celsius float64 `json:"celsius"`
+	}{21}))
+}
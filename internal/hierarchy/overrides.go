@@ -0,0 +1,264 @@
+package hierarchy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// ToolOverrides maps between the names servers give their tools and those
+// they are exposed under, per the servers' toolNames and toolAliases options,
+// curates their descriptions per toolDescriptions, sets their arguments per
+// toolArguments, and tags them per tags and toolTags
+type ToolOverrides interface {
+	// ExposedToolName returns the name the given tool is exposed under
+	ExposedToolName(serverName, toolName string) string
+	// ToolAliases returns the additional names of the given tool
+	ToolAliases(serverName, toolName string) []string
+	// OriginalToolNames returns, by server, the tools that are exposed
+	// under the given name or alias
+	OriginalToolNames(name string) map[string]string
+	// ToolDescription returns the description to present for the given
+	// tool, given the one it has
+	ToolDescription(serverName, toolName, description string) string
+	// ToolArguments returns the arguments to call the given tool with,
+	// given those of a call
+	ToolArguments(serverName, toolName string, arguments map[string]interface{}) map[string]interface{}
+	// ToolSchema returns the input schema to present for the given tool,
+	// given the one it has
+	ToolSchema(serverName, toolName string, schema map[string]interface{}) map[string]interface{}
+	// ToolTags returns the tags of the given tool
+	ToolTags(serverName, toolName string) []string
+}
+
+// serverOptions applies the tool options of server configs, for the registry
+// and for checking configs not yet in it
+type serverOptions map[string]*config.MCPClientConfigV2
+
+func (s serverOptions) ToolIncluded(serverName, toolName string) bool {
+	cfg, exists := s[serverName]
+	if !exists {
+		return true
+	}
+	return cfg.Options.ToolIncluded(toolName)
+}
+
+func (s serverOptions) ExposedToolName(serverName, toolName string) string {
+	cfg, exists := s[serverName]
+	if !exists {
+		return toolName
+	}
+	return cfg.Options.ExposedToolName(toolName)
+}
+
+func (s serverOptions) ToolAliases(serverName, toolName string) []string {
+	cfg, exists := s[serverName]
+	if !exists || cfg.Options == nil {
+		return nil
+	}
+	return cfg.Options.ToolAliases[toolName]
+}
+
+func (s serverOptions) OriginalToolNames(name string) map[string]string {
+	originals := make(map[string]string)
+	for serverName, cfg := range s {
+		if original, ok := cfg.Options.OriginalToolName(name); ok {
+			originals[serverName] = original
+		}
+	}
+	return originals
+}
+
+func (s serverOptions) ToolDescription(serverName, toolName, description string) string {
+	cfg, exists := s[serverName]
+	if !exists {
+		return description
+	}
+	return cfg.Options.ToolDescription(toolName, description)
+}
+
+func (s serverOptions) ToolArguments(serverName, toolName string, arguments map[string]interface{}) map[string]interface{} {
+	cfg, exists := s[serverName]
+	if !exists {
+		return arguments
+	}
+	return cfg.Options.ToolCallArguments(toolName, arguments)
+}
+
+func (s serverOptions) ToolSchema(serverName, toolName string, schema map[string]interface{}) map[string]interface{} {
+	cfg, exists := s[serverName]
+	if !exists {
+		return schema
+	}
+	return cfg.Options.ToolSchema(toolName, schema)
+}
+
+func (s serverOptions) ToolTags(serverName, toolName string) []string {
+	cfg, exists := s[serverName]
+	if !exists {
+		return nil
+	}
+	return cfg.ToolTags(toolName)
+}
+
+// ExposedToolName returns the name the given tool of the given server is
+// exposed under
+func (r *ServerRegistry) ExposedToolName(serverName, toolName string) string {
+	return serverOptions(r.configs()).ExposedToolName(serverName, toolName)
+}
+
+// ToolAliases returns the additional names of the given tool of the given server
+func (r *ServerRegistry) ToolAliases(serverName, toolName string) []string {
+	return serverOptions(r.configs()).ToolAliases(serverName, toolName)
+}
+
+// OriginalToolNames returns, by server, the tools that are exposed under the
+// given name or alias. Tools that keep their own name are not included.
+func (r *ServerRegistry) OriginalToolNames(name string) map[string]string {
+	return serverOptions(r.configs()).OriginalToolNames(name)
+}
+
+// ToolDescription returns the description to present for the given tool of
+// the given server in place of description
+func (r *ServerRegistry) ToolDescription(serverName, toolName, description string) string {
+	return serverOptions(r.configs()).ToolDescription(serverName, toolName, description)
+}
+
+// ToolArguments returns the arguments to call the given tool of the given
+// server with, given those of a call
+func (r *ServerRegistry) ToolArguments(serverName, toolName string, arguments map[string]interface{}) map[string]interface{} {
+	return serverOptions(r.configs()).ToolArguments(serverName, toolName, arguments)
+}
+
+// ToolSchema returns the input schema to present for the given tool of the
+// given server in place of schema
+func (r *ServerRegistry) ToolSchema(serverName, toolName string, schema map[string]interface{}) map[string]interface{} {
+	return serverOptions(r.configs()).ToolSchema(serverName, toolName, schema)
+}
+
+// ToolTags returns the tags of the given tool of the given server: the
+// server's tags and those its toolTags add
+func (r *ServerRegistry) ToolTags(serverName, toolName string) []string {
+	return serverOptions(r.configs()).ToolTags(serverName, toolName)
+}
+
+// SetToolOverrides makes categories list tools under their exposed names,
+// aliases, descriptions and schemas, and execute_tool accept the names and
+// set the tools' arguments
+func (h *Hierarchy) SetToolOverrides(overrides ToolOverrides) {
+	h.overrides = overrides
+}
+
+// exposedTool is how a tool of the hierarchy is listed
+type exposedTool struct {
+	name        string
+	path        string
+	description string
+	aliases     []string
+	schema      map[string]interface{}
+}
+
+// exposeTool returns how to list a tool of the hierarchy. A tool that is not
+// renamed keeps its hierarchy name.
+func (h *Hierarchy) exposeTool(toolName, toolPath string, toolDef *ToolDefinition) exposedTool {
+	tool := exposedTool{name: toolName, path: toolPath, description: toolDef.Description, schema: toolDef.InputSchema}
+	if h.overrides == nil || toolDef.Server == "" {
+		return tool
+	}
+	original := toolDef.MapsTo
+	if original == "" {
+		original = toolName
+	}
+	tool.description = h.overrides.ToolDescription(toolDef.Server, original, toolDef.Description)
+	tool.schema = h.overrides.ToolSchema(toolDef.Server, original, toolDef.InputSchema)
+	tool.aliases = h.overrides.ToolAliases(toolDef.Server, original)
+	exposed := h.overrides.ExposedToolName(toolDef.Server, original)
+	if exposed == original {
+		return tool
+	}
+	// Paths ending in the tool's name end in the exposed name instead
+	tool.name = exposed
+	prefix, last := "", toolPath
+	if i := strings.LastIndex(toolPath, "."); i >= 0 {
+		prefix, last = toolPath[:i+1], toolPath[i+1:]
+	}
+	if last == toolName {
+		tool.path = prefix + exposed
+	}
+	return tool
+}
+
+// ToolDescription returns the description to present for the tool at the
+// given path, per its server's toolDescriptions
+func (h *Hierarchy) ToolDescription(toolPath string, toolDef *ToolDefinition) string {
+	toolName := toolPath[strings.LastIndex(toolPath, ".")+1:]
+	return h.exposeTool(toolName, toolPath, toolDef).description
+}
+
+// ToolSchema returns the input schema to present for the tool at the given
+// path, per its server's toolArguments
+func (h *Hierarchy) ToolSchema(toolPath string, toolDef *ToolDefinition) map[string]interface{} {
+	toolName := toolPath[strings.LastIndex(toolPath, ".")+1:]
+	return h.exposeTool(toolName, toolPath, toolDef).schema
+}
+
+// toolSchema is ToolSchema for a resolved call, whose server calls the tool
+// toolName
+func (h *Hierarchy) toolSchema(toolDef *ToolDefinition, serverName, toolName string) map[string]interface{} {
+	if h.overrides == nil {
+		return toolDef.InputSchema
+	}
+	return h.overrides.ToolSchema(serverName, toolName, toolDef.InputSchema)
+}
+
+// toolArguments returns the arguments to call the given tool with, per its
+// server's toolArguments
+func (h *Hierarchy) toolArguments(serverName, toolName string, arguments map[string]interface{}) map[string]interface{} {
+	if h.overrides == nil {
+		return arguments
+	}
+	return h.overrides.ToolArguments(serverName, toolName, arguments)
+}
+
+// ResolveExposedToolPath is ResolveToolPath for paths that may end in a name
+// or alias a tool is exposed under, as listed by get_tools_in_category
+func (h *Hierarchy) ResolveExposedToolPath(toolPath string) (*ToolDefinition, string, error) {
+	if listed, ok := h.listedTool(toolPath); ok {
+		return listed.def, listed.def.Server, nil
+	}
+	toolDef, serverName, err := h.ResolveToolPath(toolPath)
+	if err == nil {
+		return toolDef, serverName, nil
+	}
+	if resolved, ok := h.resolveExposedToolPath(toolPath); ok {
+		return h.ResolveToolPath(resolved)
+	}
+	return nil, "", err
+}
+
+// resolveExposedToolPath returns the path in the hierarchy of the tool whose
+// path ends in the given exposed name or alias, if there is one
+func (h *Hierarchy) resolveExposedToolPath(toolPath string) (string, bool) {
+	if h.overrides == nil {
+		return "", false
+	}
+	prefix, name := "", toolPath
+	if i := strings.LastIndex(toolPath, "."); i >= 0 {
+		prefix, name = toolPath[:i+1], toolPath[i+1:]
+	}
+
+	originals := h.overrides.OriginalToolNames(name)
+	servers := make([]string, 0, len(originals))
+	for serverName := range originals {
+		servers = append(servers, serverName)
+	}
+	sort.Strings(servers)
+	for _, serverName := range servers {
+		candidate := prefix + originals[serverName]
+		if _, resolvedServer, err := h.ResolveToolPath(candidate); err == nil && resolvedServer == serverName {
+			return candidate, true
+		}
+	}
+	return "", false
+}
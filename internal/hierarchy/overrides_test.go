@@ -0,0 +1,120 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestRenamedToolsAreListedAndRouted verifies that a tool renamed by its
+// server's toolNames is listed under the new name with its aliases and
+// curated description, and that calls by either reach the tool under its own
+// name.
+func TestRenamedToolsAreListedAndRouted(t *testing.T) {
+	trello := server.NewMCPServer("trello", "1.0.0")
+	trello.AddTool(mcp.NewTool("create_card"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("created"), nil
+	})
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"":       {},
+		"trello": {Overview: "Trello boards"},
+		"trello.create_card": {Tools: map[string]*ToolDefinition{
+			"create_card": {Server: "trello", MapsTo: "create_card", Description: "Creates a card."},
+		}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"trello": {Options: &config.OptionsV2{
+				ToolNames:   map[string]string{"create_card": "add_task"},
+				ToolAliases: map[string][]string{"create_card": {"new_task"}},
+				ToolDescriptions: map[string]config.ToolDescriptionConfig{
+					"create_card": {Append: "Use this for todo items."},
+				},
+			}},
+		},
+		map[string]*server.MCPServer{"trello": trello},
+		nil,
+	)
+	defer registry.Close()
+	h.SetToolOverrides(registry)
+
+	response, err := h.HandleGetToolsInCategory("trello")
+	require.NoError(t, err)
+	tools := response["tools"].(map[string]interface{})
+	require.Contains(t, tools, "add_task")
+	assert.NotContains(t, tools, "create_card")
+	info := tools["add_task"].(map[string]interface{})
+	assert.Equal(t, "trello.add_task", info["tool_path"])
+	assert.Equal(t, []string{"new_task"}, info["aliases"])
+	assert.Equal(t, "Creates a card.\n\nUse this for todo items.", info["description"])
+
+	for _, toolPath := range []string{"trello.add_task", "trello.new_task"} {
+		result, err := h.HandleExecuteTool(context.Background(), registry, toolPath, nil)
+		require.NoError(t, err, toolPath)
+		assert.Equal(t, "created", result.Content[0].(mcp.TextContent).Text)
+	}
+}
+
+// TestToolArgumentsAreSetAndHidden verifies that a server's toolArguments
+// fill in and force arguments of its tool's calls, and that the arguments
+// they set are left out of the schema the tool is listed with.
+func TestToolArgumentsAreSetAndHidden(t *testing.T) {
+	tracker := server.NewMCPServer("tracker", "1.0.0")
+	var received map[string]any
+	tracker.AddTool(mcp.NewTool("create_issue",
+		mcp.WithString("title", mcp.Required()),
+		mcp.WithString("workspace_id", mcp.Required()),
+		mcp.WithBoolean("dry_run"),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		received = request.GetArguments()
+		return mcp.NewToolResultText("created"), nil
+	})
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"create_issue": {Server: "tracker", MapsTo: "create_issue", InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"title":        map[string]interface{}{"type": "string"},
+				"workspace_id": map[string]interface{}{"type": "string"},
+				"dry_run":      map[string]interface{}{"type": "boolean"},
+			},
+			"required": []interface{}{"title", "workspace_id"},
+		}}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"tracker": {Options: &config.OptionsV2{
+				ValidateArguments: optional.NewField(true),
+				ToolArguments: map[string]config.ToolArgumentsConfig{
+					"create_issue": {
+						Defaults: map[string]any{"workspace_id": "ws-1"},
+						Force:    map[string]any{"dry_run": false},
+					},
+				},
+			}},
+		},
+		map[string]*server.MCPServer{"tracker": tracker},
+		nil,
+	)
+	defer registry.Close()
+	h.SetToolOverrides(registry)
+
+	_, err := h.HandleExecuteTool(context.Background(), registry, "create_issue", map[string]interface{}{"title": "Crash", "dry_run": true})
+	require.NoError(t, err, "the default fills in a required argument")
+	assert.Equal(t, map[string]any{"title": "Crash", "workspace_id": "ws-1", "dry_run": false}, received)
+
+	_, err = h.HandleExecuteTool(context.Background(), registry, "create_issue", map[string]interface{}{"title": "Crash", "workspace_id": "ws-2"})
+	require.NoError(t, err)
+	assert.Equal(t, "ws-2", received["workspace_id"], "a call's own argument beats the default")
+
+	schema := h.ToolSchema("create_issue", h.nodes[""].Tools["create_issue"])
+	assert.Equal(t, map[string]interface{}{"title": map[string]interface{}{"type": "string"}}, schema["properties"])
+	assert.Equal(t, []interface{}{"title"}, schema["required"])
+}
@@ -0,0 +1,56 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/pipeline"
+)
+
+// StepError is returned for a pipeline whose step could not be made, or
+// failed, wrapping what HandleExecuteTool returned
+type StepError struct {
+	Step string
+	Tool string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("pipeline step %s (%s): %v", e.Step, e.Tool, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// HandlePipeline calls the steps of cfg in turn as HandleExecuteTool does,
+// filling in the arguments of each from input and the outputs of the steps
+// before it, and returns the result of the last step. It stops at the first
+// step that fails: its error is returned as a StepError, and a result that
+// is an error is returned headed by the step it came from.
+func (h *Hierarchy) HandlePipeline(ctx context.Context, registry *ServerRegistry, cfg config.PipelineConfig, input map[string]interface{}) (*mcp.CallToolResult, error) {
+	vars := map[string]any{pipeline.Input: input}
+	var result *mcp.CallToolResult
+	for i, step := range cfg.Steps {
+		name := cfg.StepName(i)
+		arguments, err := pipeline.Expand(map[string]any(step.Arguments), vars)
+		if err != nil {
+			return nil, &StepError{Step: name, Tool: step.Tool, Err: err}
+		}
+		result, err = h.HandleExecuteTool(ctx, registry, step.Tool, arguments.(map[string]any))
+		if err != nil {
+			return nil, &StepError{Step: name, Tool: step.Tool, Err: err}
+		}
+		if result.IsError {
+			header := mcp.NewTextContent(fmt.Sprintf("Pipeline step %s (%s) failed:", name, step.Tool))
+			return &mcp.CallToolResult{
+				Content: append([]mcp.Content{header}, result.Content...),
+				IsError: true,
+			}, nil
+		}
+		vars[name] = pipeline.Output(result)
+	}
+	return result, nil
+}
@@ -0,0 +1,71 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestPipelinePassesOutputsOn verifies that each step's arguments are filled
+// in from the input and earlier outputs, that the last step's result is
+// returned, and that a failing step stops the pipeline.
+func TestPipelinePassesOutputsOn(t *testing.T) {
+	tracker := server.NewMCPServer("tracker", "1.0.0")
+	tracker.AddTool(mcp.NewTool("search", mcp.WithString("query")), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if request.GetString("query", "") == "fail" {
+			return mcp.NewToolResultError("search is down"), nil
+		}
+		return mcp.NewToolResultStructured(map[string]any{
+			"issues": []any{map[string]any{"id": 42, "title": "Crash on " + request.GetString("query", "")}},
+		}, "1 issue"), nil
+	})
+	chat := server.NewMCPServer("chat", "1.0.0")
+	var posted map[string]any
+	chat.AddTool(mcp.NewTool("post"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		posted = request.GetArguments()
+		return mcp.NewToolResultText("posted"), nil
+	})
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"search": {Server: "tracker"}, "post": {Server: "chat"}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"tracker": {}, "chat": {}},
+		map[string]*server.MCPServer{"tracker": tracker, "chat": chat},
+		nil,
+	)
+	defer registry.Close()
+
+	pipeline := config.PipelineConfig{Steps: []config.PipelineStepConfig{
+		{Name: "found", Tool: "search", Arguments: map[string]any{"query": "{{input.query}}"}},
+		{Tool: "post", Arguments: map[string]any{
+			"issue": "{{found.issues[0].id}}",
+			"text":  "#{{found.issues[0].id}}: {{found.issues[0].title}}",
+		}},
+	}}
+
+	result, err := h.HandlePipeline(context.Background(), registry, pipeline, map[string]interface{}{"query": "startup"})
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	assert.Equal(t, "posted", result.Content[0].(mcp.TextContent).Text)
+	assert.Equal(t, map[string]any{"issue": float64(42), "text": "#42: Crash on startup"}, posted)
+
+	posted = nil
+	result, err = h.HandlePipeline(context.Background(), registry, pipeline, map[string]interface{}{"query": "fail"})
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.Content[0].(mcp.TextContent).Text, "step found (search) failed")
+	assert.Nil(t, posted, "the steps after a failed one are not called")
+
+	_, err = h.HandlePipeline(context.Background(), registry, config.PipelineConfig{Steps: []config.PipelineStepConfig{
+		{Tool: "post", Arguments: map[string]any{"text": "{{input.missing}}"}},
+	}}, map[string]interface{}{})
+	var stepErr *StepError
+	require.ErrorAs(t, err, &stepErr)
+	assert.Equal(t, "step1", stepErr.Step)
+}
@@ -0,0 +1,129 @@
+package hierarchy
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+	"golang.org/x/sync/semaphore"
+)
+
+// PrefetchSchemas fetches the tool listings of the servers whose tools lack
+// an inputSchema in the hierarchy, starting at most concurrency of them at a
+// time, and fills the schemas in, so that search_tools and expanded tools
+// show them. Servers that fail are logged and skipped. It blocks until all
+// servers are done and returns how many tools it filled in.
+func (h *Hierarchy) PrefetchSchemas(ctx context.Context, registry *ServerRegistry, concurrency int) int {
+	sem := semaphore.NewWeighted(int64(max(concurrency, 1)))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	filled := 0
+	for _, serverName := range h.serversLackingSchemas() {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer sem.Release(1)
+			tools, err := registry.GetServerTools(ctx, serverName)
+			if err != nil {
+				logging.ForServer(serverName).Warn("Failed to prefetch tool schemas", "error", err)
+				return
+			}
+			n := h.fillSchemas(serverName, tools)
+			mu.Lock()
+			filled += n
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return filled
+}
+
+// serversLackingSchemas returns the servers with tools that may be listed but
+// have no inputSchema, in order
+func (h *Hierarchy) serversLackingSchemas() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var servers []string
+	for _, node := range h.nodes {
+		for toolName, toolDef := range node.Tools {
+			if toolDef.Server == "" || toolDef.InputSchema != nil || seen[toolDef.Server] || !h.isIncluded(toolName, toolDef) {
+				continue
+			}
+			seen[toolDef.Server] = true
+			servers = append(servers, toolDef.Server)
+		}
+	}
+	sort.Strings(servers)
+	return servers
+}
+
+// fillSchemas sets the inputSchema of the given server's tools that have
+// none from the server's listing, and returns how many it set. Definitions
+// are replaced rather than changed, as callers read them without the lock.
+func (h *Hierarchy) fillSchemas(serverName string, tools []mcp.Tool) int {
+	schemas := make(map[string]map[string]interface{}, len(tools))
+	for _, tool := range tools {
+		if schema := inputSchemaOf(tool); schema != nil {
+			schemas[tool.Name] = schema
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	filled := make(map[*ToolDefinition]*ToolDefinition)
+	for _, node := range h.nodes {
+		for toolName, toolDef := range node.Tools {
+			if toolDef.Server != serverName || toolDef.InputSchema != nil {
+				continue
+			}
+			replacement, exists := filled[toolDef]
+			if !exists {
+				downstreamName := toolDef.MapsTo
+				if downstreamName == "" {
+					downstreamName = toolName
+				}
+				schema, listed := schemas[downstreamName]
+				if !listed {
+					continue
+				}
+				copied := *toolDef
+				copied.InputSchema = schema
+				replacement = &copied
+				filled[toolDef] = replacement
+			}
+			node.Tools[toolName] = replacement
+		}
+	}
+	return len(filled)
+}
+
+// inputSchemaOf returns the input schema of tool as hierarchy files hold it
+func inputSchemaOf(tool mcp.Tool) map[string]interface{} {
+	return schemaOf(tool, "inputSchema")
+}
+
+// schemaOf returns the schema tool lists under the given field, decoded as
+// generic JSON, or nil if it lists none
+func schemaOf(tool mcp.Tool, field string) map[string]interface{} {
+	data, err := json.Marshal(tool)
+	if err != nil {
+		return nil
+	}
+	var listed map[string]json.RawMessage
+	if err := json.Unmarshal(data, &listed); err != nil {
+		return nil
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(listed[field], &schema); err != nil {
+		return nil
+	}
+	return schema
+}
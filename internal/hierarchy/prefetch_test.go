@@ -0,0 +1,50 @@
+package hierarchy
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestPrefetchSchemasFillsMissingSchemas verifies that prefetching starts only
+// the servers with tools lacking a schema, and makes search_tools show the
+// schemas they list.
+func TestPrefetchSchemasFillsMissingSchemas(t *testing.T) {
+	known := map[string]interface{}{"type": "object"}
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"echo.echo": {Tools: map[string]*ToolDefinition{
+			"echo": {Server: "echo", MapsTo: "echo", Description: "Echoes a message."},
+		}},
+		"known.echo": {Tools: map[string]*ToolDefinition{
+			"echo": {Server: "known", MapsTo: "echo", Description: "Echoes a message.", InputSchema: known},
+		}},
+	}}
+	var launches int32
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"echo": {}, "known": {}},
+		map[string]*server.MCPServer{"echo": newEchoServer(), "known": newEchoServer()},
+		&launches,
+	)
+	defer registry.Close()
+
+	assert.Equal(t, 1, h.PrefetchSchemas(context.Background(), registry, 2))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&launches), "only the server lacking schemas is started")
+
+	response, err := h.HandleSearchTools(context.Background(), "echo", 0)
+	require.NoError(t, err)
+	schemas := make(map[string]interface{})
+	for _, match := range response["matches"].([]map[string]interface{}) {
+		schemas[match["tool_path"].(string)] = match["inputSchema"]
+	}
+	require.IsType(t, map[string]interface{}{}, schemas["echo.echo"])
+	assert.Contains(t, schemas["echo.echo"].(map[string]interface{})["properties"], "message")
+	assert.Equal(t, known, schemas["known.echo"])
+
+	// Nothing is left to fetch
+	assert.Zero(t, h.PrefetchSchemas(context.Background(), registry, 2))
+}
@@ -0,0 +1,85 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// MethodNotificationProgress is the method of progress notifications, for
+// which mcp-go has no constant
+const MethodNotificationProgress = "notifications/progress"
+
+// ProgressFunc reports the progress of a call to the client that made it
+type ProgressFunc func(progress, total float64, message string)
+
+type progressKey struct{}
+
+// WithProgress returns a context whose tool calls relay the progress
+// notifications of their server to report
+func WithProgress(ctx context.Context, report ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, report)
+}
+
+func progressFromContext(ctx context.Context) ProgressFunc {
+	report, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return report
+}
+
+// progressRoutes maps the progress tokens sent with calls to servers back to
+// the calls. Tokens are our own, as those of different clients may collide.
+type progressRoutes struct {
+	mu     sync.Mutex
+	next   uint64
+	routes map[progressRoute]ProgressFunc
+}
+
+type progressRoute struct {
+	server string
+	token  string
+}
+
+func newProgressRoutes() *progressRoutes {
+	return &progressRoutes{routes: make(map[progressRoute]ProgressFunc)}
+}
+
+// add returns a new token whose progress goes to report until done is called
+func (p *progressRoutes) add(serverName string, report ProgressFunc) (token string, done func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.next++
+	route := progressRoute{server: serverName, token: fmt.Sprintf("lazy-mcp-%d", p.next)}
+	p.routes[route] = report
+	return route.token, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.routes, route)
+	}
+}
+
+func (p *progressRoutes) get(serverName, token string) (ProgressFunc, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	report, ok := p.routes[progressRoute{server: serverName, token: token}]
+	return report, ok
+}
+
+// progressReported relays a progress notification from the given server to
+// the call it belongs to. Notifications for finished calls are dropped.
+func (r *ServerRegistry) progressReported(serverName string, notification mcp.JSONRPCNotification) {
+	fields := notification.Params.AdditionalFields
+	token, ok := fields["progressToken"].(string)
+	if !ok {
+		return
+	}
+	report, ok := r.progress.get(serverName, token)
+	if !ok {
+		return
+	}
+	progress, _ := fields["progress"].(float64)
+	total, _ := fields["total"].(float64)
+	message, _ := fields["message"].(string)
+	report(progress, total, message)
+}
@@ -0,0 +1,66 @@
+package hierarchy
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestProgressIsRelayedToTheCall verifies that progress notifications sent by
+// a server while serving a call reach the call's ProgressFunc.
+func TestProgressIsRelayedToTheCall(t *testing.T) {
+	slow := server.NewMCPServer("slow", "1.0.0")
+	slow.AddTool(mcp.NewTool("index"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+			return mcp.NewToolResultError("no progress token"), nil
+		}
+		for i := 1; i <= 2; i++ {
+			err := server.ServerFromContext(ctx).SendNotificationToClient(ctx, MethodNotificationProgress, map[string]any{
+				"progressToken": request.Params.Meta.ProgressToken,
+				"progress":      i,
+				"total":         2,
+				"message":       "indexing",
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		return mcp.NewToolResultText("indexed"), nil
+	})
+	// In-process clients are not sent notifications, so use SSE
+	sseServer := server.NewTestServer(slow)
+	defer sseServer.Close()
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"index": {Server: "slow"}}},
+	}}
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"slow": {Type: config.MCPClientTypeSSE, URL: sseServer.URL + "/sse", Options: &config.OptionsV2{}},
+	})
+	defer registry.Close()
+
+	var mu sync.Mutex
+	var reports []float64
+	ctx := WithProgress(context.Background(), func(progress, total float64, message string) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, float64(2), total)
+		assert.Equal(t, "indexing", message)
+		reports = append(reports, progress)
+	})
+
+	result, err := h.HandleExecuteTool(ctx, registry, "index", nil)
+	require.NoError(t, err)
+	require.False(t, result.IsError, "%v", result.Content)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []float64{1, 2}, reports)
+	assert.Empty(t, registry.progress.routes, "the token is dropped when the call ends")
+}
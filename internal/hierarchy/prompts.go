@@ -0,0 +1,99 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// PromptName namespaces a downstream prompt name with its server's name
+func PromptName(serverName, name string) string {
+	return serverName + "." + name
+}
+
+// parsePromptName splits a namespaced prompt name into the server name and
+// the name the server knows the prompt by. Server names may contain dots, so
+// the longest configured server name that prefixes the name wins.
+func (r *ServerRegistry) parsePromptName(name string) (serverName, downstreamName string, err error) {
+	for configured := range r.configs() {
+		rest, ok := strings.CutPrefix(name, configured+".")
+		if ok && rest != "" && len(configured) > len(serverName) {
+			serverName, downstreamName = configured, rest
+		}
+	}
+	if serverName == "" {
+		return "", "", fmt.Errorf("%w: no server for prompt %s", ErrUnknownServer, name)
+	}
+	return serverName, downstreamName, nil
+}
+
+// ListPrompts returns the prompts of the given server, starting it if needed,
+// with their names namespaced. Servers without the prompts capability have
+// none.
+func (r *ServerRegistry) ListPrompts(ctx context.Context, serverName string) ([]mcp.Prompt, error) {
+	mcpClient, err := r.GetOrLoadServer(ctx, serverName)
+	if err != nil {
+		return nil, err
+	}
+	if mcpClient.GetClient().GetServerCapabilities().Prompts == nil {
+		return nil, nil
+	}
+
+	var prompts []mcp.Prompt
+	request := mcp.ListPromptsRequest{}
+	for {
+		result, err := mcpClient.GetClient().ListPrompts(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list prompts for server %s: %w", serverName, err)
+		}
+		for _, prompt := range result.Prompts {
+			prompt.Name = PromptName(serverName, prompt.Name)
+			prompts = append(prompts, prompt)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		request.Params.Cursor = result.NextCursor
+	}
+	return prompts, nil
+}
+
+// GetPrompt renders a prompt by its namespaced name on the server that owns
+// it, starting the server if needed. Resources the messages refer to are
+// given their lazy-mcp:// URIs, so clients can read them through the proxy.
+func (r *ServerRegistry) GetPrompt(ctx context.Context, name string, arguments map[string]string) (*mcp.GetPromptResult, error) {
+	serverName, downstreamName, err := r.parsePromptName(name)
+	if err != nil {
+		return nil, err
+	}
+	mcpClient, err := r.GetOrLoadServer(ctx, serverName)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *mcp.GetPromptResult
+	err = r.WithClientLock(ctx, serverName, func(ctx context.Context) error {
+		request := mcp.GetPromptRequest{}
+		request.Params.Name = downstreamName
+		request.Params.Arguments = arguments
+		var err error
+		result, err = mcpClient.GetClient().GetPrompt(ctx, request)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt %s from server %s: %w", downstreamName, serverName, err)
+	}
+
+	for i, message := range result.Messages {
+		result.Messages[i].Content = namespaceContent(serverName, message.Content)
+	}
+	return result, nil
+}
+
+// OnPromptListChanged registers fn to be called whenever the prompts of a
+// server may have changed, in the same cases as OnResourceListChanged
+func (r *ServerRegistry) OnPromptListChanged(fn func(serverName string)) {
+	r.onPromptListChanged.Store(&fn)
+}
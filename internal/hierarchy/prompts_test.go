@@ -0,0 +1,60 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestPromptsAreNamespacedByServer verifies that prompt names are prefixed
+// with their server, that getting one is routed back to the longest matching
+// server name, and that resources the messages refer to get lazy-mcp:// URIs.
+func TestPromptsAreNamespacedByServer(t *testing.T) {
+	github := server.NewMCPServer("github", "1.0.0")
+	github.AddPrompt(mcp.NewPrompt("review", mcp.WithArgument("pr", mcp.RequiredArgument())), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		return mcp.NewGetPromptResult("Review a PR", []mcp.PromptMessage{
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("Review PR "+request.Params.Arguments["pr"])),
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewEmbeddedResource(mcp.TextResourceContents{URI: "repo://diff", Text: "+1 -1"})),
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewResourceLink("repo://readme", "readme", "", "")),
+		}), nil
+	})
+	enterprise := server.NewMCPServer("github.enterprise", "1.0.0")
+	enterprise.AddPrompt(mcp.NewPrompt("review"), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		return mcp.NewGetPromptResult("Enterprise review", []mcp.PromptMessage{mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("Review"))}), nil
+	})
+
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"github": {}, "github.enterprise": {}},
+		map[string]*server.MCPServer{"github": github, "github.enterprise": enterprise},
+		nil,
+	)
+	defer registry.Close()
+
+	ctx := context.Background()
+	prompts, err := registry.ListPrompts(ctx, "github")
+	require.NoError(t, err)
+	require.Len(t, prompts, 1)
+	assert.Equal(t, "github.review", prompts[0].Name)
+	require.Len(t, prompts[0].Arguments, 1)
+	assert.Equal(t, "pr", prompts[0].Arguments[0].Name)
+
+	result, err := registry.GetPrompt(ctx, "github.review", map[string]string{"pr": "42"})
+	require.NoError(t, err)
+	require.Len(t, result.Messages, 3)
+	assert.Equal(t, "Review PR 42", result.Messages[0].Content.(mcp.TextContent).Text)
+	embedded := result.Messages[1].Content.(mcp.EmbeddedResource)
+	assert.Equal(t, "lazy-mcp://github/repo://diff", embedded.Resource.(mcp.TextResourceContents).URI)
+	assert.Equal(t, "lazy-mcp://github/repo://readme", result.Messages[2].Content.(mcp.ResourceLink).URI)
+
+	result, err = registry.GetPrompt(ctx, "github.enterprise.review", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Enterprise review", result.Description)
+
+	_, err = registry.GetPrompt(ctx, "gitlab.review", nil)
+	assert.ErrorIs(t, err, ErrUnknownServer)
+}
@@ -0,0 +1,150 @@
+package hierarchy
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// ErrRateLimited is matched by the RateLimitError of a call refused by a rate limit
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitError is returned for a call that would exceed a rate limit of its
+// server, without making it. Tool is empty for the server's overall limit.
+type RateLimitError struct {
+	Server     string
+	Tool       string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	limited := "server " + e.Server
+	if e.Tool != "" {
+		limited = fmt.Sprintf("tool %s on server %s", e.Tool, e.Server)
+	}
+	return fmt.Sprintf("rate limited: %s, retry after %s", limited, e.RetryAfter.Round(time.Millisecond))
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// rateLimits holds the token buckets of the configured rate limits, shared by
+// all clients
+type rateLimits struct {
+	mu      sync.Mutex
+	buckets map[rateLimitKey]*tokenBucket
+	now     func() time.Time
+}
+
+// rateLimitKey identifies a bucket; tool is empty for a server's overall limit
+type rateLimitKey struct {
+	server string
+	tool   string
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimits() *rateLimits {
+	return &rateLimits{buckets: make(map[rateLimitKey]*tokenBucket), now: time.Now}
+}
+
+// refill returns the bucket for key with the tokens accrued since it was last
+// used. The caller must hold l.mu.
+func (l *rateLimits) refill(key rateLimitKey, limit config.RateLimitConfig, now time.Time) *tokenBucket {
+	capacity := float64(burst(limit))
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: capacity, last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = min(capacity, bucket.tokens+now.Sub(bucket.last).Seconds()*ratePerSecond(limit))
+	bucket.last = now
+	return bucket
+}
+
+// take spends a token from every bucket of the call, or none if any of them
+// is empty, in which case it returns the key of the empty bucket that takes
+// longest to get a token, and how long that is.
+func (l *rateLimits) take(limits map[rateLimitKey]config.RateLimitConfig) (rateLimitKey, time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	buckets := make([]*tokenBucket, 0, len(limits))
+	var limited rateLimitKey
+	var longest time.Duration
+	for key, limit := range limits {
+		bucket := l.refill(key, limit, now)
+		if bucket.tokens >= 1 {
+			buckets = append(buckets, bucket)
+			continue
+		}
+		if wait := time.Duration((1 - bucket.tokens) / ratePerSecond(limit) * float64(time.Second)); wait > longest {
+			limited, longest = key, wait
+		}
+	}
+	if len(buckets) < len(limits) {
+		return limited, longest, false
+	}
+	for _, bucket := range buckets {
+		bucket.tokens--
+	}
+	return rateLimitKey{}, 0, true
+}
+
+// forget drops the buckets of the given server
+func (l *rateLimits) forget(serverName string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key := range l.buckets {
+		if key.server == serverName {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func burst(limit config.RateLimitConfig) int {
+	if limit.Burst > 0 {
+		return limit.Burst
+	}
+	return limit.Requests
+}
+
+func ratePerSecond(limit config.RateLimitConfig) float64 {
+	return float64(limit.Requests) / limit.Per.Std().Seconds()
+}
+
+func validRateLimit(limit config.RateLimitConfig) bool {
+	return limit.Requests > 0 && limit.Per > 0
+}
+
+// takeRateLimit admits a call to the given tool under the server's rateLimit
+// and the tool's entry in toolRateLimits, or returns a RateLimitError.
+func (r *ServerRegistry) takeRateLimit(serverName, toolName string) error {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil {
+		return nil
+	}
+	limits := make(map[rateLimitKey]config.RateLimitConfig, 2)
+	if limit := cfg.Options.RateLimit; limit != nil && validRateLimit(*limit) {
+		limits[rateLimitKey{server: serverName}] = *limit
+	}
+	if limit, ok := cfg.Options.ToolRateLimits[toolName]; ok && validRateLimit(limit) {
+		limits[rateLimitKey{server: serverName, tool: toolName}] = limit
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+	key, wait, ok := r.rateLimits.take(limits)
+	if ok {
+		return nil
+	}
+	return &RateLimitError{Server: serverName, Tool: key.tool, RetryAfter: wait}
+}
@@ -0,0 +1,47 @@
+package hierarchy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestRateLimitsAreSharedAndRefill verifies that calls beyond a tool's or its
+// server's limit are refused with the time until the next token, that a
+// refused call spends no tokens, and that buckets refill over time.
+func TestRateLimitsAreSharedAndRefill(t *testing.T) {
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"github": {Options: &config.OptionsV2{
+			RateLimit: &config.RateLimitConfig{Requests: 3, Per: config.Duration(time.Minute)},
+			ToolRateLimits: map[string]config.RateLimitConfig{
+				"search_code": {Requests: 1, Per: config.Duration(10 * time.Second)},
+			},
+		}},
+	})
+	defer registry.Close()
+	now := time.Now()
+	registry.rateLimits.now = func() time.Time { return now }
+
+	require.NoError(t, registry.takeRateLimit("github", "search_code"))
+
+	err := registry.takeRateLimit("github", "search_code")
+	var limited *RateLimitError
+	require.ErrorAs(t, err, &limited)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, "search_code", limited.Tool)
+	assert.Equal(t, 10*time.Second, limited.RetryAfter)
+
+	// The refused call left the server's bucket alone
+	require.NoError(t, registry.takeRateLimit("github", "get_issue"))
+	require.NoError(t, registry.takeRateLimit("github", "get_issue"))
+	err = registry.takeRateLimit("github", "get_issue")
+	require.ErrorAs(t, err, &limited)
+	assert.Empty(t, limited.Tool, "the server's own limit was hit")
+	assert.Equal(t, 20*time.Second, limited.RetryAfter)
+
+	now = now.Add(20 * time.Second)
+	assert.NoError(t, registry.takeRateLimit("github", "search_code"))
+}
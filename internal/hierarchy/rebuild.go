@@ -0,0 +1,150 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// RebuildServers rebuilds the nodes of the given servers, which Reconfigure
+// reported changed, from their tool listings, then tells the registry's
+// subscribers that their tools changed. Servers still configured are
+// started if need be and laid out as the structure generator lays them out:
+// a node at the server's path, under its group, and one per tool below it.
+// Removed servers lose their nodes; servers that fail to list their tools
+// keep theirs, so that they can still be called once they start. Virtual
+// servers are added again after, as they may list the servers' tools.
+func (h *Hierarchy) RebuildServers(ctx context.Context, registry *ServerRegistry, servers []string, virtualServers map[string]config.VirtualServerConfig) {
+	listings := make([][]mcp.Tool, len(servers))
+	listed := make([]bool, len(servers))
+	var wg sync.WaitGroup
+	for i, name := range servers {
+		if _, exists := registry.serverConfig(name); !exists {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tools, err := registry.GetServerTools(ctx, name)
+			if err != nil {
+				logging.ForServer(name).Warn("Failed to list the tools of reconfigured MCP client, keeping its hierarchy", "error", err)
+				return
+			}
+			listings[i], listed[i] = tools, true
+		}()
+	}
+	wg.Wait()
+
+	h.mu.Lock()
+	for i, name := range servers {
+		cfg, exists := registry.serverConfig(name)
+		if exists && !listed[i] {
+			continue
+		}
+		h.removeServer(name)
+		if exists && len(listings[i]) > 0 {
+			h.addServer(name, cfg.Group, listings[i])
+		}
+	}
+	h.nodes["/"] = h.nodes[""]
+	h.mu.Unlock()
+
+	h.AddVirtualServers(virtualServers)
+	registry.publish(ctx, Event{Type: EventHierarchyChanged, Servers: servers})
+}
+
+// removeServer drops the tools of the given server from every node, and the
+// nodes that leaves without tools, along with those above them that have no
+// tools below them any more. Nodes are replaced rather than changed, as
+// callers read them without the lock. h.mu must be held.
+func (h *Hierarchy) removeServer(serverName string) {
+	var emptied []string
+	for path, node := range h.nodes {
+		if path == "/" {
+			continue // The root, under its other path
+		}
+		kept := make(map[string]*ToolDefinition, len(node.Tools))
+		for toolName, toolDef := range node.Tools {
+			if toolDef.Server != serverName {
+				kept[toolName] = toolDef
+			}
+		}
+		if len(kept) == len(node.Tools) {
+			continue
+		}
+		h.nodes[path] = &HierarchyNode{Overview: node.Overview, Tools: kept, MCPServer: node.MCPServer}
+		if len(kept) == 0 {
+			emptied = append(emptied, path)
+		}
+	}
+
+	for _, path := range emptied {
+		for ; path != "" && !h.hasToolsBelow(path); path = parentPath(path) {
+			for nodePath := range h.nodes {
+				if nodePath == path || strings.HasPrefix(nodePath, path+".") {
+					delete(h.nodes, nodePath)
+				}
+			}
+		}
+	}
+}
+
+// hasToolsBelow reports whether the node at path, or one below it, has
+// tools. h.mu must be held.
+func (h *Hierarchy) hasToolsBelow(path string) bool {
+	for nodePath, node := range h.nodes {
+		if (nodePath == path || strings.HasPrefix(nodePath, path+".")) && len(node.Tools) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// parentPath returns the path of the node above the one at path
+func parentPath(path string) string {
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// addServer adds nodes for the given server's tools: one at its path, under
+// the nodes of its group, which are added if missing, and one per tool below
+// it. h.mu must be held.
+func (h *Hierarchy) addServer(serverName, group string, tools []mcp.Tool) {
+	path := serverName
+	if group = strings.Trim(group, "/"); group != "" {
+		levels := strings.Split(group, "/")
+		for i := range levels {
+			if groupPath := strings.Join(levels[:i+1], "."); h.nodes[groupPath] == nil {
+				h.nodes[groupPath] = &HierarchyNode{Overview: fmt.Sprintf("Servers in group %s", strings.Join(levels[:i+1], "/"))}
+			}
+		}
+		path = strings.Join(levels, ".") + "." + serverName
+	}
+
+	names := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+		h.nodes[path+"."+tool.Name] = &HierarchyNode{Tools: map[string]*ToolDefinition{
+			tool.Name: {
+				Description: tool.Description,
+				MapsTo:      tool.Name,
+				Server:      serverName,
+				InputSchema: inputSchemaOf(tool),
+			},
+		}}
+	}
+	sort.Strings(names)
+	overview := fmt.Sprintf("%s: %d tools; %s", serverName, len(tools), strings.Join(names, ", "))
+	if len(tools) == 1 {
+		overview = fmt.Sprintf("%s: 1 tool; %s", serverName, names[0])
+	}
+	h.nodes[path] = &HierarchyNode{Overview: overview}
+}
@@ -0,0 +1,94 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestRebuildServers verifies that after a reconfiguration the hierarchy
+// lists the tools of added and edited servers, under their groups, no longer
+// lists those of removed ones, and lists them in virtual servers, before
+// subscribers hear of it.
+func TestRebuildServers(t *testing.T) {
+	root := &HierarchyNode{Overview: "Tools"}
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"":                 root,
+		"/":                root,
+		"github":           {Overview: "github: 1 tool; get_issue"},
+		"github.get_issue": {Tools: map[string]*ToolDefinition{"get_issue": {MapsTo: "get_issue", Server: "github"}}},
+		"old":              {Overview: "old: 1 tool; echo"},
+		"old.echo":         {Tools: map[string]*ToolDefinition{"echo": {MapsTo: "echo", Server: "old"}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"github": {Command: "github"},
+			"old":    {Command: "old"},
+		},
+		map[string]*server.MCPServer{"github": newEchoServer(), "added": newEchoServer()},
+		nil,
+	)
+	defer registry.Close()
+	virtualServers := map[string]config.VirtualServerConfig{
+		"mine": {Tools: []string{"work.dev.added.echo", "github.echo"}},
+	}
+	h.AddVirtualServers(virtualServers)
+	assert.NotContains(t, h.nodes, "mine", "none of its tools resolve yet")
+
+	changed := make(chan []string, 1)
+	registry.Events().Subscribe(func(ctx context.Context, event Event) {
+		if event.Type == EventHierarchyChanged {
+			assert.Contains(t, h.ToolPaths(), "work.dev.added.echo", "the hierarchy is rebuilt first")
+			changed <- event.Servers
+		}
+	})
+
+	servers := registry.Reconfigure(map[string]*config.MCPClientConfigV2{
+		"github": {Command: "github", Args: []string{"--read-only"}},
+		"added":  {Command: "added", Group: "work/dev"},
+	})
+	require.Equal(t, []string{"added", "github", "old"}, servers)
+	h.RebuildServers(context.Background(), registry, servers, virtualServers)
+
+	assert.ElementsMatch(t, []string{"github.echo", "work.dev.added.echo", "mine.echo"}, h.ToolPaths())
+	assert.NotContains(t, h.nodes, "old", "removed servers leave no empty categories")
+	assert.Same(t, h.nodes[""], h.nodes["/"])
+	assert.Equal(t, "added: 1 tool; echo", h.nodes["work.dev.added"].Overview)
+	work, err := h.HandleGetToolsInCategory("work")
+	require.NoError(t, err)
+	assert.Contains(t, work["children"], "dev")
+	toolDef, serverName, err := h.ResolveToolPath("work.dev.added.echo")
+	require.NoError(t, err)
+	assert.Equal(t, "added", serverName)
+	assert.Contains(t, toolDef.InputSchema["properties"], "message")
+
+	select {
+	case servers := <-changed:
+		assert.Equal(t, []string{"added", "github", "old"}, servers)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the change was not published")
+	}
+}
+
+// TestRebuildServersKeepsServersThatFail verifies that a server that fails
+// to list its tools after a reconfiguration keeps its nodes.
+func TestRebuildServersKeepsServersThatFail(t *testing.T) {
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"":              {},
+		"broken":        {Overview: "broken: 1 tool; echo"},
+		"broken.echo":   {Tools: map[string]*ToolDefinition{"echo": {MapsTo: "echo", Server: "broken"}}},
+		"unrelated":     {},
+		"unrelated.foo": {Tools: map[string]*ToolDefinition{"foo": {MapsTo: "foo", Server: "unrelated"}}},
+	}}
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{"broken": {Command: "lazy-mcp-test-missing-command"}})
+	defer registry.Close()
+
+	servers := registry.Reconfigure(map[string]*config.MCPClientConfigV2{"broken": {Command: "lazy-mcp-test-still-missing-command"}})
+	h.RebuildServers(context.Background(), registry, servers, nil)
+	assert.ElementsMatch(t, []string{"broken.echo", "unrelated.foo"}, h.ToolPaths())
+}
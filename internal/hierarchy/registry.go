@@ -0,0 +1,941 @@
+package hierarchy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/client"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+	"github.com/voicetreelab/lazy-mcp/internal/oauth"
+	"github.com/voicetreelab/lazy-mcp/internal/secrets"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrLockTimeout is returned when a caller gives up waiting for a server's call slot
+// because its context was cancelled or its deadline passed.
+var ErrLockTimeout = errors.New("timed out waiting for server lock")
+
+// ErrUnknownServer is returned for a server that is not in the configuration
+var ErrUnknownServer = errors.New("server config not found")
+
+// serverState tracks a connected MCP client and its background tasks
+type serverState struct {
+	client   *client.Client
+	started  time.Time
+	lastUsed time.Time
+	stop     context.CancelFunc // Stops background tasks (e.g. pinging) for this client
+	tools    []mcp.Tool         // Cached tools/list result, nil until first fetched
+	health   serverHealth
+	version  string // As the server reported it at initialization
+}
+
+// ServerRegistry manages MCP client connections
+type ServerRegistry struct {
+	servers       map[string]*serverState
+	clientSlots   map[string]*semaphore.Weighted                       // Per-client semaphore bounding concurrent tool calls
+	loadMu        map[string]*sync.Mutex                               // Per-client mutex held while the server starts
+	starts        singleflight.Group                                   // Startups in progress, shared by their callers
+	startups      map[string]*startup                                  // Callers waiting on each startup in progress
+	startMu       sync.Mutex                                           // Guards startups
+	serverConfigs atomic.Pointer[map[string]*config.MCPClientConfigV2] // Replaced wholesale by Reconfigure
+	transforms    atomic.Pointer[map[string]*serverTransforms]         // Compiled resultTransforms, replaced with serverConfigs
+	crashes       map[string]*crashRecord                              // Recent unexpected exits, driving restart backoff
+	results       *resultCache                                         // Tool results kept per cacheTTL
+	callers       map[string][]*caller                                 // Client requests holding each server's call slots
+	lastCallers   map[string]context.Context                           // Latest client request to reach each server
+	progress      *progressRoutes                                      // Progress tokens of calls in flight
+	circuits      map[string]*circuit                                  // Circuit breakers of servers that failed recently
+	rateLimits    *rateLimits                                          // Token buckets of rateLimit and toolRateLimits
+	queued        map[string]int                                       // Calls waiting for each server's call slots
+	slotWaiters   map[string]map[*slotWaiter]struct{}                  // The same, failed by the watchdog if a call hangs
+	calls         *callQueue                                           // Call slots shared by all servers, set by SetCallLimit
+	queueWaits    map[string]*queueWaits                               // How long each server's latest calls waited for slots
+	latencies     *latencies                                           // Latency histograms of the tools called
+	spills        *spills                                              // Oversized results saved to files
+	subscriptions *subscriptions                                       // Client sessions subscribed to resources
+	disabled      map[string]bool                                      // Servers taken out of service by DisableServer
+	draining      bool                                                 // Set by Drain; no more calls are taken
+	exposeTags    []string                                             // Tags of the tools exposed, set by SetExposeTags
+	mu            sync.RWMutex
+
+	// events are published as servers start, stop and change
+	events *EventBus
+	// onResourceListChanged and onPromptListChanged are called when a server's
+	// resources or prompts may have changed
+	onResourceListChanged atomic.Pointer[func(serverName string)]
+	onPromptListChanged   atomic.Pointer[func(serverName string)]
+	// onResourceUpdated is called when a resource a session subscribed to
+	// was updated
+	onResourceUpdated atomic.Pointer[ResourceUpdatedFunc]
+	// onLogMessage relays the log messages of servers, at logLevel, the
+	// latest level clients asked for
+	onLogMessage atomic.Pointer[LogMessageFunc]
+	logLevel     atomic.Pointer[mcp.LoggingLevel]
+	// sampling, elicitation and roots forward requests from servers to
+	// clients
+	sampling    atomic.Pointer[SamplingFunc]
+	elicitation atomic.Pointer[ElicitationFunc]
+	roots       atomic.Pointer[RootsFunc]
+	// stderr receives what stdio and Docker servers write to stderr
+	stderr atomic.Pointer[StderrFunc]
+	// authorizer authorizes with servers that require OAuth
+	authorizer *oauth.Authorizer
+
+	// ctx is cancelled by Close, stopping pending restarts
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// newClient creates the MCP client for a server; replaced in tests
+	newClient func(name string, cfg *config.MCPClientConfigV2, options ...client.Option) (*client.Client, error)
+	// restartBaseDelay is the backoff before the first restart; shortened in tests
+	restartBaseDelay time.Duration
+}
+
+// NewServerRegistry creates a new server registry with server configurations
+func NewServerRegistry(serverConfigs map[string]*config.MCPClientConfigV2) *ServerRegistry {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &ServerRegistry{
+		servers:          make(map[string]*serverState),
+		clientSlots:      make(map[string]*semaphore.Weighted),
+		loadMu:           make(map[string]*sync.Mutex),
+		startups:         make(map[string]*startup),
+		crashes:          make(map[string]*crashRecord),
+		results:          newResultCache(),
+		callers:          make(map[string][]*caller),
+		lastCallers:      make(map[string]context.Context),
+		progress:         newProgressRoutes(),
+		circuits:         make(map[string]*circuit),
+		rateLimits:       newRateLimits(),
+		queued:           make(map[string]int),
+		slotWaiters:      make(map[string]map[*slotWaiter]struct{}),
+		queueWaits:       make(map[string]*queueWaits),
+		latencies:        newLatencies(),
+		spills:           newSpills(),
+		subscriptions:    newSubscriptions(),
+		disabled:         make(map[string]bool),
+		authorizer:       oauth.NewAuthorizer(),
+		events:           newEventBus(),
+		ctx:              ctx,
+		cancel:           cancel,
+		newClient:        client.NewMCPClient,
+		restartBaseDelay: restartBaseDelay,
+	}
+	transforms := compileResultTransforms(serverConfigs)
+	r.transforms.Store(&transforms)
+	r.serverConfigs.Store(&serverConfigs)
+	return r
+}
+
+// configs returns the current server configurations. The map must not be modified.
+func (r *ServerRegistry) configs() map[string]*config.MCPClientConfigV2 {
+	return *r.serverConfigs.Load()
+}
+
+// serverConfig returns the current configuration of the given server
+func (r *ServerRegistry) serverConfig(serverName string) (*config.MCPClientConfigV2, bool) {
+	cfg, exists := r.configs()[serverName]
+	return cfg, exists
+}
+
+// MaxConcurrent returns how many tool calls the given server may have in flight,
+// which is its maxConcurrent for remote servers and 1 otherwise.
+func (r *ServerRegistry) MaxConcurrent(serverName string) int {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil || cfg.Serial() {
+		return 1
+	}
+	if n := cfg.Options.MaxConcurrent.OrElse(1); n > 1 {
+		return n
+	}
+	return 1
+}
+
+// IdleTimeout returns how long the given server may go without a tool call before
+// it is shut down. Zero means the server is never reaped.
+func (r *ServerRegistry) IdleTimeout(serverName string) time.Duration {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil {
+		return 0
+	}
+	return cfg.Options.IdleTimeout.OrElse(0).Std()
+}
+
+// SetCallLimit bounds the tool calls in flight across all servers; calls
+// beyond it queue fairly between servers. Zero, the default, means no bound.
+// It must be called before any call is made.
+func (r *ServerRegistry) SetCallLimit(limit int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = nil
+	if limit > 0 {
+		r.calls = newCallQueue(limit)
+	}
+}
+
+// AcquireSlot blocks until a call slot is available for the given server or ctx is done.
+// A slot is taken from both the server's maxConcurrent and the limit set by SetCallLimit.
+// On success the returned release function must be called exactly once to free the slot.
+// If ctx ends first, the returned error wraps both ErrLockTimeout and the context error.
+// Note: The semaphore map grows with the number of unique servers accessed. Since the set of
+// servers is bounded by the configuration/hierarchy, this is not a memory leak.
+func (r *ServerRegistry) AcquireSlot(ctx context.Context, serverName string) (func(), error) {
+	_, span := tracer.Start(ctx, "acquire_slot", trace.WithAttributes(attrServer.String(serverName)))
+	defer span.End()
+
+	if r.Draining() {
+		return nil, ErrShuttingDown
+	}
+	sem := r.getClientSlots(serverName)
+	start := time.Now()
+	r.mu.Lock()
+	r.queued[serverName]++
+	calls := r.calls
+	r.mu.Unlock()
+	waitCtx, stopWaiting := r.addSlotWaiter(ctx, serverName)
+	err := sem.Acquire(waitCtx, 1)
+	for err == nil && !r.currentSlots(serverName, sem) {
+		// Replaced by Reconfigure while the call waited
+		sem.Release(1)
+		sem = r.getClientSlots(serverName)
+		err = sem.Acquire(waitCtx, 1)
+	}
+	if err == nil {
+		if err = calls.acquire(waitCtx, serverName); err != nil {
+			sem.Release(1)
+		}
+	}
+	hungErr := hungCause(waitCtx)
+	stopWaiting()
+	wait := time.Since(start)
+	r.mu.Lock()
+	r.queued[serverName]--
+	if err == nil {
+		r.recordQueueWaitLocked(serverName, wait)
+	}
+	draining := r.draining
+	r.mu.Unlock()
+	if err != nil && hungErr != nil {
+		span.SetStatus(codes.Error, hungErr.Error())
+		return nil, hungErr
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("%w: server %s after %s: %w", ErrLockTimeout, serverName, wait.Round(time.Millisecond), err)
+	}
+	// Calls that queued up before Drain are not let through after it
+	if draining {
+		calls.release()
+		sem.Release(1)
+		return nil, ErrShuttingDown
+	}
+	r.touch(serverName)
+	untrack := r.trackCaller(serverName, ctx)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			untrack()
+			r.touch(serverName)
+			calls.release()
+			sem.Release(1)
+		})
+	}, nil
+}
+
+// recordQueueWaitLocked records how long a call to the given server waited
+// for its slot. The caller must hold r.mu.
+func (r *ServerRegistry) recordQueueWaitLocked(serverName string, wait time.Duration) {
+	waits, exists := r.queueWaits[serverName]
+	if !exists {
+		waits = &queueWaits{}
+		r.queueWaits[serverName] = waits
+	}
+	waits.record(wait)
+}
+
+// WithClientLock runs fn while holding a call slot for the given server.
+// Unlike a bare mutex, waiting for the slot honours ctx cancellation and deadlines,
+// so a hung server fails subsequent callers fast with ErrLockTimeout instead of
+// blocking them forever. The slot is released as soon as fn returns, or once
+// the watchdog gives up on it; see watchCall.
+func (r *ServerRegistry) WithClientLock(ctx context.Context, serverName string, fn func(ctx context.Context) error) error {
+	release, err := r.AcquireSlot(ctx, serverName)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return r.watchCall(ctx, serverName, fn)
+}
+
+// getClientSlots returns the semaphore for the given server, creating one if needed.
+func (r *ServerRegistry) getClientSlots(serverName string) *semaphore.Weighted {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if sem, exists := r.clientSlots[serverName]; exists {
+		return sem
+	}
+
+	sem := semaphore.NewWeighted(int64(r.MaxConcurrent(serverName)))
+	r.clientSlots[serverName] = sem
+	return sem
+}
+
+// touch records that the given server was just used, deferring idle shutdown
+func (r *ServerRegistry) touch(serverName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if state, exists := r.servers[serverName]; exists {
+		state.lastUsed = time.Now()
+	}
+}
+
+// lookup returns the state of a connected server, touching it so the idle
+// reaper stays away from a client that a caller is about to use.
+func (r *ServerRegistry) lookup(serverName string) (*serverState, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, exists := r.servers[serverName]
+	if exists {
+		state.lastUsed = time.Now()
+	}
+	return state, exists
+}
+
+// lookupQuiet returns the state of a connected server without counting as use,
+// for background tasks that must not keep an otherwise idle server alive.
+func (r *ServerRegistry) lookupQuiet(serverName string) (*serverState, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	state, exists := r.servers[serverName]
+	return state, exists
+}
+
+// getLoadMutex returns the mutex held while the given server starts, which
+// stopping it waits for
+func (r *ServerRegistry) getLoadMutex(serverName string) *sync.Mutex {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if m, exists := r.loadMu[serverName]; exists {
+		return m
+	}
+	m := &sync.Mutex{}
+	r.loadMu[serverName] = m
+	return m
+}
+
+// clientOptions returns the options for a new client of the given server
+func (r *ServerRegistry) clientOptions(serverName string) []client.Option {
+	var options []client.Option
+	if r.sampling.Load() != nil && r.AllowSampling(serverName) {
+		options = append(options, client.WithSamplingHandler(samplingHandler{registry: r, server: serverName}))
+	}
+	if r.elicitation.Load() != nil || r.DeclineElicitation(serverName) {
+		options = append(options, client.WithElicitationHandler(elicitationHandler{registry: r, server: serverName}))
+	}
+	if r.roots.Load() != nil {
+		options = append(options, client.WithRootsHandler(rootsHandler{registry: r, server: serverName}))
+	}
+	if stderr := r.stderr.Load(); stderr != nil {
+		options = append(options, client.WithStderr((*stderr)(serverName)))
+	}
+	return options
+}
+
+// StderrFunc returns where a start of the named server writes its stderr
+type StderrFunc func(server string) io.Writer
+
+// OnStderr copies what stdio and Docker servers write to stderr to the writer
+// fn returns for each start of them. It only affects servers started
+// afterwards.
+func (r *ServerRegistry) OnStderr(fn StderrFunc) {
+	r.stderr.Store(&fn)
+}
+
+// GetOrLoadServer gets an existing client or creates and initializes a new one
+// This implements lazy loading - servers are only started when first accessed.
+// Different servers start in parallel. Concurrent callers for the same server
+// share one startup, which connects, initializes and lists the tools once;
+// each waits for it only as long as its ctx allows, and it is aborted once all
+// of them have given up.
+func (r *ServerRegistry) GetOrLoadServer(ctx context.Context, serverName string) (*client.Client, error) {
+	for {
+		if r.Draining() {
+			return nil, ErrShuttingDown
+		}
+		if state, exists := r.lookupLive(serverName); exists {
+			return state.client, nil
+		}
+
+		// A server that just crashed is not relaunched before its backoff expires
+		if err := r.waitForRestart(ctx, serverName); err != nil {
+			return nil, err
+		}
+
+		// The caller's span shows whether its call paid for a cold start
+		trace.SpanFromContext(ctx).SetAttributes(attrColdStart.Bool(true))
+		mcpClient, err := r.awaitStartup(ctx, serverName)
+		if errors.Is(err, context.Canceled) && ctx.Err() == nil && r.ctx.Err() == nil {
+			// Joined a startup the callers waiting on it had just given up
+			// on, so start the server afresh
+			continue
+		}
+		return mcpClient, err
+	}
+}
+
+// startup is a server startup shared by the callers waiting on it
+type startup struct {
+	ctx     context.Context // The first caller's, without its cancellation
+	cancel  context.CancelFunc
+	waiters int
+}
+
+// awaitStartup starts the given server, or joins the startup in progress, and
+// waits for it or for ctx to be done
+func (r *ServerRegistry) awaitStartup(ctx context.Context, serverName string) (*client.Client, error) {
+	r.startMu.Lock()
+	start, exists := r.startups[serverName]
+	if !exists {
+		startCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		start = &startup{ctx: startCtx, cancel: cancel}
+		r.startups[serverName] = start
+	}
+	start.waiters++
+	result := r.starts.DoChan(serverName, func() (interface{}, error) {
+		return r.startServer(start.ctx, serverName)
+	})
+	r.startMu.Unlock()
+
+	defer func() {
+		r.startMu.Lock()
+		defer r.startMu.Unlock()
+		start.waiters--
+		if start.waiters == 0 {
+			start.cancel()
+			if r.startups[serverName] == start {
+				delete(r.startups, serverName)
+			}
+		}
+	}()
+
+	select {
+	case res := <-result:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*client.Client), nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to start MCP client: %w", ctx.Err())
+	}
+}
+
+// startServer creates and initializes a client of the given server and lists
+// its tools, unless another startup already did. Giving up on ctx before the
+// startup completes aborts it.
+func (r *ServerRegistry) startServer(ctx context.Context, serverName string) (*client.Client, error) {
+	loadMu := r.getLoadMutex(serverName)
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	// Check again in case another startup created it
+	if state, exists := r.lookupLive(serverName); exists {
+		return state.client, nil
+	}
+	if r.Draining() {
+		return nil, ErrShuttingDown
+	}
+
+	// Look up the server config
+	cfg, exists := r.serverConfig(serverName)
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownServer, serverName)
+	}
+	if r.isDisabled(serverName) {
+		return nil, fmt.Errorf("%w: %s", ErrServerDisabled, serverName)
+	}
+
+	// A server may ask for roots before the request that started it gets a slot
+	r.rememberCaller(serverName, ctx)
+
+	ctx, span := tracer.Start(ctx, "start_server", trace.WithAttributes(attrServer.String(serverName)))
+	defer span.End()
+
+	// Secrets are fetched at each start, so that rotated ones are picked up
+	cfg, err := cfg.WithSecrets(ctx, secrets.Resolve)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+
+	// Create the MCP client
+	mcpClient, err := r.newClient(serverName, cfg, r.clientOptions(serverName)...)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("failed to create MCP client: %w", err)
+	}
+
+	// A server that reports a change to its tools invalidates our copy, and
+	// the progress of a call is relayed to the client that made it
+	mcpClient.GetClient().OnNotification(func(notification mcp.JSONRPCNotification) {
+		switch notification.Method {
+		case mcp.MethodNotificationToolsListChanged:
+			r.toolsChanged(serverName, mcpClient)
+		case mcp.MethodNotificationResourcesListChanged:
+			r.listChanged(&r.onResourceListChanged, serverName, mcpClient)
+		case mcp.MethodNotificationResourceUpdated:
+			r.resourceUpdated(serverName, notification)
+		case mcp.MethodNotificationPromptsListChanged:
+			r.listChanged(&r.onPromptListChanged, serverName, mcpClient)
+		case MethodNotificationProgress:
+			r.progressReported(serverName, notification)
+		case MethodNotificationMessage:
+			r.logMessage(serverName, notification)
+		}
+	})
+
+	// The connection and background tasks outlive the request that triggered
+	// the load, so they get their own context which is cancelled when the
+	// client is closed. Until startup completes, giving up on ctx aborts it.
+	taskCtx, stop := context.WithCancel(context.Background())
+	abortOnCancel := context.AfterFunc(ctx, stop)
+	fail := func(format string, err error) (*client.Client, error) {
+		stop()
+		_ = mcpClient.Close()
+		err = fmt.Errorf(format, err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	// Start the client if needed
+	if mcpClient.NeedManualStart() {
+		err := mcpClient.GetClient().Start(taskCtx)
+		if err != nil {
+			return fail("failed to start MCP client: %w", r.authorizationRequired(serverName, cfg, err))
+		}
+	}
+
+	// Initialize the client
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{Name: "mcp-proxy-recursive"}
+	initRequest.Params.Capabilities = mcp.ClientCapabilities{}
+
+	initResult, err := mcpClient.GetClient().Initialize(ctx, initRequest)
+	if err != nil {
+		return fail("failed to initialize MCP client: %w", r.authorizationRequired(serverName, cfg, err))
+	}
+	// Listed along with the startup, so that callers waiting on it find the
+	// tools cached. GetServerTools tries again if this fails.
+	var tools []mcp.Tool
+	if initResult.Capabilities.Tools != nil {
+		tools, err = r.listTools(ctx, serverName, mcpClient)
+		if err != nil && ctx.Err() == nil {
+			logging.ForServer(serverName).Warn("Failed to list tools at startup", "error", err)
+		}
+	}
+	r.setServerLogLevel(ctx, serverName, mcpClient)
+	if !abortOnCancel() {
+		return fail("failed to start MCP client: %w", ctx.Err())
+	}
+
+	// Store the client
+	now := time.Now()
+	state := &serverState{
+		client:   mcpClient,
+		started:  now,
+		lastUsed: now,
+		stop:     stop,
+		tools:    tools,
+		version:  initResult.ServerInfo.Version,
+	}
+	r.mu.Lock()
+	r.servers[serverName] = state
+	r.mu.Unlock()
+	r.listChanged(&r.onResourceListChanged, serverName, mcpClient)
+	r.listChanged(&r.onPromptListChanged, serverName, mcpClient)
+	r.publish(ctx, Event{Type: EventServerStarted, Server: serverName, Version: state.version})
+
+	// Start ping task if needed
+	if mcpClient.NeedPing() {
+		go mcpClient.StartPingTask(taskCtx)
+	}
+	if mcpClient.Done() != nil {
+		go r.supervise(taskCtx, serverName, state)
+	}
+
+	return mcpClient, nil
+}
+
+// ToolIncluded reports whether the given tool of the given server is exposed
+// per the server's includeTools and excludeTools options, and has one of the
+// tags set by SetExposeTags
+func (r *ServerRegistry) ToolIncluded(serverName, toolName string) bool {
+	if !serverOptions(r.configs()).ToolIncluded(serverName, toolName) {
+		return false
+	}
+	r.mu.RLock()
+	exposeTags := r.exposeTags
+	r.mu.RUnlock()
+	if len(exposeTags) == 0 {
+		return true
+	}
+	for _, tag := range r.ToolTags(serverName, toolName) {
+		if slices.Contains(exposeTags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetExposeTags exposes only the tools with at least one of tags, per
+// ToolTags; with none, every tool is exposed
+func (r *ServerRegistry) SetExposeTags(tags []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exposeTags = slices.Clone(tags)
+}
+
+// GetServerTools returns the tools exposed by the given server, starting it if
+// needed, without those hidden by includeTools and excludeTools. The listing
+// is fetched once per connection and cached.
+func (r *ServerRegistry) GetServerTools(ctx context.Context, serverName string) ([]mcp.Tool, error) {
+	mcpClient, err := r.GetOrLoadServer(ctx, serverName)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	state, exists := r.servers[serverName]
+	if exists && state.client == mcpClient && state.tools != nil {
+		tools := state.tools
+		r.mu.RUnlock()
+		return tools, nil
+	}
+	r.mu.RUnlock()
+
+	tools, err := r.listTools(ctx, serverName, mcpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	if state, exists := r.servers[serverName]; exists && state.client == mcpClient {
+		state.tools = tools
+	}
+	r.mu.Unlock()
+
+	return tools, nil
+}
+
+// listTools fetches all pages of mcpClient's tools, without those hidden by
+// includeTools and excludeTools
+func (r *ServerRegistry) listTools(ctx context.Context, serverName string, mcpClient *client.Client) ([]mcp.Tool, error) {
+	tools := make([]mcp.Tool, 0)
+	toolsRequest := mcp.ListToolsRequest{}
+	for {
+		result, err := mcpClient.GetClient().ListTools(ctx, toolsRequest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tools for server %s: %w", serverName, err)
+		}
+		for _, tool := range result.Tools {
+			if r.ToolIncluded(serverName, tool.Name) {
+				tools = append(tools, tool)
+			}
+		}
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		toolsRequest.Params.Cursor = result.NextCursor
+	}
+}
+
+// toolsChanged handles a tools/list_changed notification from mcpClient,
+// dropping its cached tool list and results before publishing the change
+func (r *ServerRegistry) toolsChanged(serverName string, mcpClient *client.Client) {
+	r.mu.Lock()
+	state, exists := r.servers[serverName]
+	current := exists && state.client == mcpClient
+	if current {
+		state.tools = nil
+	}
+	r.mu.Unlock()
+	// Before the client is stored nothing has been cached for it yet
+	if !current {
+		return
+	}
+
+	r.results.forget(serverName)
+	r.publish(context.Background(), Event{Type: EventHierarchyChanged, Servers: []string{serverName}})
+}
+
+// listChanged calls listener, if any, unless mcpClient is no longer the
+// server's current client
+func (r *ServerRegistry) listChanged(listener *atomic.Pointer[func(serverName string)], serverName string, mcpClient *client.Client) {
+	if state, exists := r.lookupQuiet(serverName); !exists || state.client != mcpClient {
+		return
+	}
+	notify(listener, serverName)
+}
+
+// notify calls listener, if any, in its own goroutine
+func notify(listener *atomic.Pointer[func(serverName string)], serverName string) {
+	if fn := listener.Load(); fn != nil {
+		go (*fn)(serverName)
+	}
+}
+
+// Prewarm starts every server configured with prewarm: true and fetches its tool
+// list, all in parallel. Failures are logged and leave the server to be loaded
+// lazily on first use. It blocks until all servers are done, so callers that must
+// not delay serving should run it in a goroutine.
+func (r *ServerRegistry) Prewarm(ctx context.Context) {
+	var wg sync.WaitGroup
+	for name, cfg := range r.configs() {
+		if cfg == nil || !cfg.Prewarm {
+			continue
+		}
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			start := time.Now()
+			tools, err := r.GetServerTools(ctx, name)
+			if err != nil {
+				logging.ForServer(name).Warn("Failed to prewarm MCP client", "error", err)
+				return
+			}
+			logging.ForServer(name).Info("Prewarmed MCP client", "tools", len(tools), "duration", time.Since(start).Round(time.Millisecond))
+		}(name)
+	}
+	wg.Wait()
+}
+
+// StartIdleReaper periodically shuts down servers that have not handled a tool call
+// within their configured idleTimeout. Reaped servers are relaunched transparently
+// by GetOrLoadServer on their next call. It returns when ctx is done.
+func (r *ServerRegistry) StartIdleReaper(ctx context.Context) {
+	interval := r.reapInterval()
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reapIdle(time.Now())
+		}
+	}
+}
+
+// reapInterval picks how often to scan for idle servers: half the shortest
+// configured idleTimeout, clamped to [1s, 1m]. Zero means no server is reapable.
+func (r *ServerRegistry) reapInterval() time.Duration {
+	var shortest time.Duration
+	for name := range r.configs() {
+		if timeout := r.IdleTimeout(name); timeout > 0 && (shortest == 0 || timeout < shortest) {
+			shortest = timeout
+		}
+	}
+	if shortest == 0 {
+		return 0
+	}
+	return min(max(shortest/2, time.Second), time.Minute)
+}
+
+// reapIdle closes every server that has been idle longer than its idleTimeout
+// as of now. Servers with calls in flight, or resources clients subscribed to,
+// are never reaped. The servers are closed after the lock is released, as
+// each may take a while to exit.
+func (r *ServerRegistry) reapIdle(now time.Time) {
+	type reaped struct {
+		name   string
+		state  *serverState
+		slots  *semaphore.Weighted
+		weight int64
+	}
+	var idle []reaped
+
+	r.mu.Lock()
+	for name, state := range r.servers {
+		timeout := r.IdleTimeout(name)
+		if timeout <= 0 || now.Sub(state.lastUsed) < timeout || r.subscriptions.subscribed(name) {
+			continue
+		}
+
+		// Claim every slot so no call can be in flight while we close the
+		// client; they are released once it is closed
+		sem := r.clientSlots[name]
+		weight := int64(r.MaxConcurrent(name))
+		if sem != nil && !sem.TryAcquire(weight) {
+			continue
+		}
+		delete(r.servers, name)
+		idle = append(idle, reaped{name: name, state: state, slots: sem, weight: weight})
+	}
+	r.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, idleServer := range idle {
+		r.publish(context.Background(), Event{Type: EventServerStopped, Server: idleServer.name, Reason: ReasonIdle, Duration: now.Sub(idleServer.state.started)})
+		idleServer.state.stop()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = idleServer.state.client.Close()
+			if idleServer.slots != nil {
+				idleServer.slots.Release(idleServer.weight)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Reconfigure replaces the server configurations, e.g. after the config file
+// was edited. Servers whose entry was added, removed or changed are stopped,
+// and those that were running and are still configured are relaunched in the
+// background; unchanged servers keep their connections. It returns the names
+// of the servers that changed, sorted, whose nodes Hierarchy.RebuildServers
+// then rebuilds.
+func (r *ServerRegistry) Reconfigure(serverConfigs map[string]*config.MCPClientConfigV2) []string {
+	previous := r.configs()
+	var changed []string
+	for name, cfg := range serverConfigs {
+		if old, exists := previous[name]; !exists || !reflect.DeepEqual(old, cfg) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range previous {
+		if _, exists := serverConfigs[name]; !exists {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	// The slots of the changed servers are as many as they allowed before
+	weights := make(map[string]int64, len(changed))
+	for _, name := range changed {
+		weights[name] = int64(r.MaxConcurrent(name))
+	}
+	transforms := compileResultTransforms(serverConfigs)
+	r.transforms.Store(&transforms)
+	r.serverConfigs.Store(&serverConfigs)
+
+	var relaunch []string
+	for _, name := range changed {
+		// Wait out a startup in progress so it cannot store a client built
+		// from the old configuration
+		loadMu := r.getLoadMutex(name)
+		loadMu.Lock()
+		r.mu.Lock()
+		state, running := r.servers[name]
+		delete(r.servers, name)
+		delete(r.crashes, name)
+		delete(r.lastCallers, name)
+		delete(r.circuits, name)
+		slots, hasSlots := r.clientSlots[name]
+		r.mu.Unlock()
+		loadMu.Unlock()
+		if hasSlots {
+			go r.replaceSlots(name, slots, weights[name]) // maxConcurrent may have changed
+		}
+		r.results.forget(name)
+		r.rateLimits.forget(name) // The limits may have changed
+		if _, exists := serverConfigs[name]; !exists {
+			notify(&r.onResourceListChanged, name)
+			notify(&r.onPromptListChanged, name)
+		}
+
+		if !running {
+			continue
+		}
+		r.publish(context.Background(), Event{Type: EventServerStopped, Server: name, Reason: ReasonReconfigured, Duration: time.Since(state.started)})
+		state.stop()
+		_ = state.client.Close()
+		if _, exists := serverConfigs[name]; exists {
+			relaunch = append(relaunch, name)
+		}
+	}
+
+	for _, name := range relaunch {
+		go func(name string) {
+			if _, err := r.GetServerTools(r.ctx, name); err != nil {
+				logging.ForServer(name).Warn("Failed to relaunch reconfigured MCP client", "error", err)
+			}
+		}(name)
+	}
+	return changed
+}
+
+// replaceSlots drops the given call slots of a server once the calls holding
+// them are done, so that the next calls get slots sized by its current
+// maxConcurrent without running alongside more calls than either allows.
+// Calls made meanwhile wait for the old slots, and give them up for the new
+// ones once they get them.
+func (r *ServerRegistry) replaceSlots(serverName string, slots *semaphore.Weighted, weight int64) {
+	if err := slots.Acquire(r.ctx, weight); err != nil {
+		return // Closed
+	}
+	r.mu.Lock()
+	if r.clientSlots[serverName] == slots {
+		delete(r.clientSlots, serverName)
+	}
+	r.mu.Unlock()
+	slots.Release(weight)
+}
+
+// currentSlots reports whether slots are still the given server's call slots
+func (r *ServerRegistry) currentSlots(serverName string, slots *semaphore.Weighted) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.clientSlots[serverName] == slots
+}
+
+// Close closes all clients in the registry
+func (r *ServerRegistry) Close() {
+	r.cancel()
+	r.authorizer.Close()
+
+	// Clear the server and semaphore maps, closing the servers after the
+	// lock is released
+	r.mu.Lock()
+	servers := r.servers
+	r.servers = make(map[string]*serverState)
+	r.clientSlots = make(map[string]*semaphore.Weighted)
+	r.mu.Unlock()
+
+	// In parallel, as each may take a while to exit
+	var wg sync.WaitGroup
+	for name, state := range servers {
+		r.publish(context.Background(), Event{Type: EventServerStopped, Server: name, Reason: ReasonClosed, Duration: time.Since(state.started)})
+		state.stop()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = state.client.Close()
+		}()
+	}
+	wg.Wait()
+
+	r.events.close()
+	r.spills.remove()
+}
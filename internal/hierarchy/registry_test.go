@@ -0,0 +1,413 @@
+package hierarchy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/client"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// newEchoServer returns an in-process MCP server exposing a single "echo" tool
+func newEchoServer() *server.MCPServer {
+	mcpServer := server.NewMCPServer("echo-server", "1.0.0")
+	mcpServer.AddTool(mcp.NewTool("echo", mcp.WithString("message")), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(request.GetString("message", "")), nil
+	})
+	return mcpServer
+}
+
+// newTestRegistry creates a registry whose clients connect to in-process MCP servers
+// instead of spawning processes. launches counts how many clients were created.
+func newTestRegistry(configs map[string]*config.MCPClientConfigV2, servers map[string]*server.MCPServer, launches *int32) *ServerRegistry {
+	registry := NewServerRegistry(configs)
+	registry.newClient = func(name string, cfg *config.MCPClientConfigV2, options ...client.Option) (*client.Client, error) {
+		if launches != nil {
+			atomic.AddInt32(launches, 1)
+		}
+		return client.NewInProcessMCPClient(name, servers[name], cfg.Options, options...)
+	}
+	return registry
+}
+
+// TestIdleReaperStopsAndRelaunches verifies that an idle server is shut down once
+// its idleTimeout elapses and relaunched transparently on the next lookup.
+func TestIdleReaperStopsAndRelaunches(t *testing.T) {
+	var launches int32
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"echo": {Options: &config.OptionsV2{IdleTimeout: optional.NewField(config.Duration(time.Minute))}},
+		},
+		map[string]*server.MCPServer{"echo": newEchoServer()},
+		&launches,
+	)
+	defer registry.Close()
+
+	ctx := context.Background()
+	_, err := registry.GetOrLoadServer(ctx, "echo")
+	require.NoError(t, err)
+
+	// Not idle long enough yet
+	registry.reapIdle(time.Now().Add(30 * time.Second))
+	assert.Contains(t, registry.servers, "echo")
+
+	registry.reapIdle(time.Now().Add(2 * time.Minute))
+	assert.NotContains(t, registry.servers, "echo", "idle server should be reaped")
+
+	_, err = registry.GetOrLoadServer(ctx, "echo")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&launches), "server should be relaunched on next use")
+}
+
+// TestIdleReaperSkipsBusyServers verifies that servers with calls in flight are
+// never reaped, regardless of how long ago they were last touched.
+func TestIdleReaperSkipsBusyServers(t *testing.T) {
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"echo": {Options: &config.OptionsV2{IdleTimeout: optional.NewField(config.Duration(time.Minute))}},
+		},
+		map[string]*server.MCPServer{"echo": newEchoServer()},
+		nil,
+	)
+	defer registry.Close()
+
+	_, err := registry.GetOrLoadServer(context.Background(), "echo")
+	require.NoError(t, err)
+
+	release, err := registry.AcquireSlot(context.Background(), "echo")
+	require.NoError(t, err)
+
+	registry.reapIdle(time.Now().Add(time.Hour))
+	assert.Contains(t, registry.servers, "echo", "busy server must not be reaped")
+
+	release()
+	registry.reapIdle(time.Now().Add(time.Hour))
+	assert.NotContains(t, registry.servers, "echo")
+}
+
+// TestIdleReaperClosesOutsideLock verifies that a server slow to close does
+// not hold up lookups of other servers while it is reaped.
+func TestIdleReaperClosesOutsideLock(t *testing.T) {
+	closing := make(chan struct{})
+	unblock := make(chan struct{})
+	mcpHandler := server.NewStreamableHTTPServer(newEchoServer(), server.WithStateful(true))
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodDelete {
+			close(closing)
+			<-unblock
+		}
+		mcpHandler.ServeHTTP(w, req)
+	}))
+	defer httpServer.Close()
+
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"slow": {Type: config.MCPClientTypeStreamable, URL: httpServer.URL, Options: &config.OptionsV2{IdleTimeout: optional.NewField(config.Duration(time.Minute))}},
+	})
+	defer registry.Close()
+	_, err := registry.GetOrLoadServer(context.Background(), "slow")
+	require.NoError(t, err)
+
+	reaped := make(chan struct{})
+	go func() {
+		registry.reapIdle(time.Now().Add(time.Hour))
+		close(reaped)
+	}()
+	<-closing
+	start := time.Now()
+	release, err := registry.AcquireSlot(context.Background(), "other")
+	require.NoError(t, err)
+	release()
+	_, running := registry.lookupQuiet("slow")
+	assert.False(t, running, "the server is gone while it closes")
+	assert.Less(t, time.Since(start), time.Second, "other servers are not held up")
+
+	close(unblock)
+	<-reaped
+}
+
+// TestReapInterval verifies the scan interval derives from the shortest idleTimeout
+func TestReapInterval(t *testing.T) {
+	idle := func(d time.Duration) *config.MCPClientConfigV2 {
+		return &config.MCPClientConfigV2{Options: &config.OptionsV2{IdleTimeout: optional.NewField(config.Duration(d))}}
+	}
+
+	assert.Equal(t, time.Duration(0), NewServerRegistry(nil).reapInterval(), "no idleTimeout means no reaping")
+	assert.Equal(t, time.Minute, NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"a": idle(10 * time.Minute),
+		"b": idle(5 * time.Minute),
+	}).reapInterval(), "interval is capped at one minute")
+	assert.Equal(t, 15*time.Second, NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"a": idle(10 * time.Minute),
+		"b": idle(30 * time.Second),
+	}).reapInterval(), "interval is half the shortest idleTimeout")
+	assert.Equal(t, time.Second, NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"a": idle(time.Second),
+	}).reapInterval(), "interval is at least one second")
+}
+
+// TestPrewarmStartsOnlyFlaggedServers verifies that Prewarm connects servers marked
+// prewarm: true and caches their tool lists, while leaving other servers lazy.
+func TestPrewarmStartsOnlyFlaggedServers(t *testing.T) {
+	var launches int32
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"warm": {Prewarm: true, Options: &config.OptionsV2{}},
+			"lazy": {Options: &config.OptionsV2{}},
+		},
+		map[string]*server.MCPServer{"warm": newEchoServer(), "lazy": newEchoServer()},
+		&launches,
+	)
+	defer registry.Close()
+
+	registry.Prewarm(context.Background())
+
+	require.Contains(t, registry.servers, "warm")
+	assert.NotContains(t, registry.servers, "lazy", "servers without prewarm should stay lazy")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&launches))
+
+	tools := registry.servers["warm"].tools
+	require.Len(t, tools, 1, "prewarm should cache the tool list")
+	assert.Equal(t, "echo", tools[0].Name)
+}
+
+// TestReconfigureRestartsOnlyChangedServers verifies that reconfiguring keeps
+// unchanged servers connected, relaunches edited ones and stops removed ones.
+func TestReconfigureRestartsOnlyChangedServers(t *testing.T) {
+	servers := map[string]*server.MCPServer{"same": newEchoServer(), "edited": newEchoServer(), "removed": newEchoServer()}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"same":    {Command: "same", Options: &config.OptionsV2{}},
+			"edited":  {Command: "old", Options: &config.OptionsV2{}},
+			"removed": {Command: "removed", Options: &config.OptionsV2{}},
+		},
+		servers,
+		nil,
+	)
+	defer registry.Close()
+
+	ctx := context.Background()
+	clients := make(map[string]*client.Client)
+	for name := range servers {
+		mcpClient, err := registry.GetOrLoadServer(ctx, name)
+		require.NoError(t, err)
+		clients[name] = mcpClient
+	}
+
+	changed := registry.Reconfigure(map[string]*config.MCPClientConfigV2{
+		"same":   {Command: "same", Options: &config.OptionsV2{}},
+		"edited": {Command: "new", Options: &config.OptionsV2{}},
+		"added":  {Command: "added", Options: &config.OptionsV2{}},
+	})
+	assert.Equal(t, []string{"added", "edited", "removed"}, changed)
+
+	state, running := registry.lookupQuiet("same")
+	require.True(t, running)
+	assert.Same(t, clients["same"], state.client, "unchanged server should keep its connection")
+
+	assert.Eventually(t, func() bool {
+		state, running := registry.lookupQuiet("edited")
+		return running && state.client != clients["edited"]
+	}, 5*time.Second, 10*time.Millisecond, "edited server should be relaunched")
+
+	_, running = registry.lookupQuiet("removed")
+	assert.False(t, running, "removed server should be stopped")
+	_, err := registry.GetOrLoadServer(ctx, "removed")
+	assert.Error(t, err)
+	_, running = registry.lookupQuiet("added")
+	assert.False(t, running, "added servers stay lazy")
+}
+
+// TestToolListChangedInvalidatesTools verifies that a server's
+// tools/list_changed notification drops the cached tool list, so the next
+// listing shows the new tools, and is published as a hierarchy change.
+func TestToolListChangedInvalidatesTools(t *testing.T) {
+	// In-process clients are not sent notifications, so use SSE
+	echoServer := newEchoServer()
+	sseServer := server.NewTestServer(echoServer)
+	defer sseServer.Close()
+
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"echo": {Type: config.MCPClientTypeSSE, URL: sseServer.URL + "/sse", Options: &config.OptionsV2{}},
+	})
+	defer registry.Close()
+
+	changed := make(chan []string, 1)
+	registry.Events().Subscribe(func(ctx context.Context, event Event) {
+		if event.Type == EventHierarchyChanged {
+			changed <- event.Servers
+		}
+	})
+
+	tools, err := registry.GetServerTools(context.Background(), "echo")
+	require.NoError(t, err)
+	require.Len(t, tools, 1)
+
+	echoServer.AddTool(mcp.NewTool("reverse"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(""), nil
+	})
+	select {
+	case servers := <-changed:
+		assert.Equal(t, []string{"echo"}, servers)
+	case <-time.After(5 * time.Second):
+		t.Fatal("tools/list_changed was not passed on")
+	}
+
+	tools, err = registry.GetServerTools(context.Background(), "echo")
+	require.NoError(t, err)
+	assert.Len(t, tools, 2)
+}
+
+// TestServerStatusCountsInFlightAndQueuedCalls verifies that the status shows
+// the calls holding a slot and those waiting for one.
+func TestServerStatusCountsInFlightAndQueuedCalls(t *testing.T) {
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"echo": {}},
+		map[string]*server.MCPServer{"echo": newEchoServer()},
+		nil,
+	)
+	defer registry.Close()
+
+	release, err := registry.AcquireSlot(context.Background(), "echo")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	waited := make(chan error)
+	go func() {
+		_, err := registry.AcquireSlot(ctx, "echo")
+		waited <- err
+	}()
+
+	assert.Eventually(t, func() bool {
+		status, err := registry.ServerStatus("echo")
+		return err == nil && status.InFlight == 1 && status.Queued == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.Error(t, <-waited)
+	release()
+	status, err := registry.ServerStatus("echo")
+	require.NoError(t, err)
+	assert.Zero(t, status.InFlight)
+	assert.Zero(t, status.Queued)
+}
+
+// TestConcurrentCallersShareOneStartup verifies that callers hitting a cold
+// server at once start it, and list its tools, exactly once.
+func TestConcurrentCallersShareOneStartup(t *testing.T) {
+	var lists int32
+	hooks := &server.Hooks{}
+	hooks.AddBeforeListTools(func(ctx context.Context, id any, message *mcp.ListToolsRequest) {
+		atomic.AddInt32(&lists, 1)
+	})
+	echoServer := server.NewMCPServer("echo-server", "1.0.0", server.WithHooks(hooks))
+	echoServer.AddTool(mcp.NewTool("echo"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(""), nil
+	})
+
+	var launches int32
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"echo": {}},
+		map[string]*server.MCPServer{"echo": echoServer},
+		&launches,
+	)
+	defer registry.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tools, err := registry.GetServerTools(context.Background(), "echo")
+			assert.NoError(t, err)
+			assert.Len(t, tools, 1)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&launches))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&lists))
+}
+
+// TestStartupOutlivesCallerThatGivesUp verifies that a caller giving up on a
+// startup does not fail it for the others waiting on it.
+func TestStartupOutlivesCallerThatGivesUp(t *testing.T) {
+	var launches int32
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"echo": {}},
+		map[string]*server.MCPServer{"echo": newEchoServer()},
+		&launches,
+	)
+	defer registry.Close()
+	proceed := make(chan struct{})
+	newClient := registry.newClient
+	registry.newClient = func(name string, cfg *config.MCPClientConfigV2, options ...client.Option) (*client.Client, error) {
+		<-proceed
+		return newClient(name, cfg, options...)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	gaveUp := make(chan error)
+	go func() {
+		_, err := registry.GetOrLoadServer(ctx, "echo")
+		gaveUp <- err
+	}()
+	waited := make(chan error)
+	go func() {
+		_, err := registry.GetOrLoadServer(context.Background(), "echo")
+		waited <- err
+	}()
+	assert.Eventually(t, func() bool {
+		registry.startMu.Lock()
+		defer registry.startMu.Unlock()
+		start, exists := registry.startups["echo"]
+		return exists && start.waiters == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-gaveUp, context.Canceled)
+	close(proceed)
+	assert.NoError(t, <-waited)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&launches))
+}
+
+// TestDifferentServersStartInParallel verifies that a slow startup does not
+// hold up that of another server.
+func TestDifferentServersStartInParallel(t *testing.T) {
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"slow": {}, "fast": {}},
+		map[string]*server.MCPServer{"slow": newEchoServer(), "fast": newEchoServer()},
+		nil,
+	)
+	defer registry.Close()
+	proceed := make(chan struct{})
+	newClient := registry.newClient
+	registry.newClient = func(name string, cfg *config.MCPClientConfigV2, options ...client.Option) (*client.Client, error) {
+		if name == "slow" {
+			<-proceed
+		}
+		return newClient(name, cfg, options...)
+	}
+
+	slow := make(chan error)
+	go func() {
+		_, err := registry.GetOrLoadServer(context.Background(), "slow")
+		slow <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := registry.GetOrLoadServer(ctx, "fast")
+	assert.NoError(t, err)
+
+	close(proceed)
+	assert.NoError(t, <-slow)
+}
@@ -0,0 +1,181 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+	"github.com/yosida95/uritemplate/v3"
+)
+
+// ResourceScheme prefixes the URIs of proxied resources, which take the form
+// lazy-mcp://<server>/<downstream URI>
+const ResourceScheme = "lazy-mcp://"
+
+// ResourceURI namespaces a downstream resource URI with its server's name
+func ResourceURI(serverName, uri string) string {
+	return ResourceScheme + serverName + "/" + uri
+}
+
+// ParseResourceURI splits a namespaced URI into the server name and the URI
+// the server knows the resource by
+func ParseResourceURI(uri string) (serverName, downstreamURI string, err error) {
+	rest, ok := strings.CutPrefix(uri, ResourceScheme)
+	if !ok {
+		return "", "", fmt.Errorf("not a %s resource URI: %s", ResourceScheme, uri)
+	}
+	serverName, downstreamURI, ok = strings.Cut(rest, "/")
+	if !ok || serverName == "" || downstreamURI == "" {
+		return "", "", fmt.Errorf("resource URI has no server name: %s", uri)
+	}
+	return serverName, downstreamURI, nil
+}
+
+// ListResources returns the resources of the given server, starting it if
+// needed, with their URIs namespaced. Servers without the resources
+// capability have none.
+func (r *ServerRegistry) ListResources(ctx context.Context, serverName string) ([]mcp.Resource, error) {
+	mcpClient, err := r.GetOrLoadServer(ctx, serverName)
+	if err != nil {
+		return nil, err
+	}
+	if mcpClient.GetClient().GetServerCapabilities().Resources == nil {
+		return nil, nil
+	}
+
+	var resources []mcp.Resource
+	request := mcp.ListResourcesRequest{}
+	for {
+		result, err := mcpClient.GetClient().ListResources(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list resources for server %s: %w", serverName, err)
+		}
+		for _, resource := range result.Resources {
+			resource.URI = ResourceURI(serverName, resource.URI)
+			resources = append(resources, resource)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		request.Params.Cursor = result.NextCursor
+	}
+	return resources, nil
+}
+
+// ListResourceTemplates returns the resource templates of the given server,
+// starting it if needed, with their URI templates namespaced like resource
+// URIs, so that the URIs they expand to are read from the server
+func (r *ServerRegistry) ListResourceTemplates(ctx context.Context, serverName string) ([]mcp.ResourceTemplate, error) {
+	mcpClient, err := r.GetOrLoadServer(ctx, serverName)
+	if err != nil {
+		return nil, err
+	}
+	if mcpClient.GetClient().GetServerCapabilities().Resources == nil {
+		return nil, nil
+	}
+
+	var templates []mcp.ResourceTemplate
+	request := mcp.ListResourceTemplatesRequest{}
+	for {
+		result, err := mcpClient.GetClient().ListResourceTemplates(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list resource templates for server %s: %w", serverName, err)
+		}
+		for _, template := range result.ResourceTemplates {
+			if template.URITemplate == nil {
+				continue
+			}
+			namespaced, err := uritemplate.New(ResourceURI(serverName, template.URITemplate.Raw()))
+			if err != nil {
+				logging.ForServer(serverName).WarnContext(ctx, "Skipping resource template", "template", template.URITemplate.Raw(), "error", err)
+				continue
+			}
+			template.URITemplate = &mcp.URITemplate{Template: namespaced}
+			templates = append(templates, template)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		request.Params.Cursor = result.NextCursor
+	}
+	return templates, nil
+}
+
+// ReadResource reads a resource by its namespaced URI, whether listed or
+// expanded from a template, from the server that owns it, starting the
+// server if needed. Like a tool call, the read holds one of the server's call
+// slots.
+func (r *ServerRegistry) ReadResource(ctx context.Context, uri string) ([]mcp.ResourceContents, error) {
+	serverName, downstreamURI, err := ParseResourceURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	mcpClient, err := r.GetOrLoadServer(ctx, serverName)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *mcp.ReadResourceResult
+	err = r.WithClientLock(ctx, serverName, func(ctx context.Context) error {
+		request := mcp.ReadResourceRequest{}
+		request.Params.URI = downstreamURI
+		var err error
+		result, err = mcpClient.GetClient().ReadResource(ctx, request)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resource %s from server %s: %w", downstreamURI, serverName, err)
+	}
+
+	contents := make([]mcp.ResourceContents, 0, len(result.Contents))
+	for _, content := range result.Contents {
+		contents = append(contents, namespaceContents(serverName, content))
+	}
+	return contents, nil
+}
+
+// namespaceContents rewrites the URI of read contents so clients can tell
+// which proxied resource they belong to
+func namespaceContents(serverName string, content mcp.ResourceContents) mcp.ResourceContents {
+	switch c := content.(type) {
+	case mcp.TextResourceContents:
+		c.URI = ResourceURI(serverName, c.URI)
+		return c
+	case *mcp.TextResourceContents:
+		copied := *c
+		copied.URI = ResourceURI(serverName, c.URI)
+		return copied
+	case mcp.BlobResourceContents:
+		c.URI = ResourceURI(serverName, c.URI)
+		return c
+	case *mcp.BlobResourceContents:
+		copied := *c
+		copied.URI = ResourceURI(serverName, c.URI)
+		return copied
+	}
+	return content
+}
+
+// namespaceContent rewrites the URIs of resources a server embeds in or links
+// from prompts and tool results, so that clients read them through lazy-mcp
+func namespaceContent(serverName string, content mcp.Content) mcp.Content {
+	switch c := content.(type) {
+	case mcp.EmbeddedResource:
+		c.Resource = namespaceContents(serverName, c.Resource)
+		return c
+	case mcp.ResourceLink:
+		c.URI = ResourceURI(serverName, c.URI)
+		return c
+	}
+	return content
+}
+
+// OnResourceListChanged registers fn to be called whenever the resources of
+// a server may have changed: it was started, reported a change, or was
+// removed from the config. fn runs in its own goroutine, so it may call back
+// into the registry.
+func (r *ServerRegistry) OnResourceListChanged(fn func(serverName string)) {
+	r.onResourceListChanged.Store(&fn)
+}
@@ -0,0 +1,100 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/yosida95/uritemplate/v3"
+)
+
+// TestResourcesAreNamespacedByServer verifies that resources are listed under
+// lazy-mcp:// URIs and that reading one is routed back to its server, and
+// that the listener hears about servers as they start.
+func TestResourcesAreNamespacedByServer(t *testing.T) {
+	docs := server.NewMCPServer("docs", "1.0.0")
+	docs.AddResource(mcp.NewResource("file:///readme.md", "readme", mcp.WithMIMEType("text/markdown")), func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return []mcp.ResourceContents{mcp.TextResourceContents{URI: request.Params.URI, MIMEType: "text/markdown", Text: "# Docs"}}, nil
+	})
+
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"docs": {}, "echo": {}},
+		map[string]*server.MCPServer{"docs": docs, "echo": newEchoServer()},
+		nil,
+	)
+	defer registry.Close()
+
+	changed := make(chan string, 2)
+	registry.OnResourceListChanged(func(serverName string) { changed <- serverName })
+
+	ctx := context.Background()
+	resources, err := registry.ListResources(ctx, "docs")
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	assert.Equal(t, "lazy-mcp://docs/file:///readme.md", resources[0].URI)
+	assert.Equal(t, "text/markdown", resources[0].MIMEType)
+
+	select {
+	case serverName := <-changed:
+		assert.Equal(t, "docs", serverName)
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener was not called when the server started")
+	}
+
+	resources, err = registry.ListResources(ctx, "echo")
+	require.NoError(t, err)
+	assert.Empty(t, resources, "servers without the resources capability have none")
+
+	contents, err := registry.ReadResource(ctx, "lazy-mcp://docs/file:///readme.md")
+	require.NoError(t, err)
+	require.Len(t, contents, 1)
+	text, ok := contents[0].(mcp.TextResourceContents)
+	require.True(t, ok)
+	assert.Equal(t, "lazy-mcp://docs/file:///readme.md", text.URI)
+	assert.Equal(t, "# Docs", text.Text)
+
+	_, err = registry.ReadResource(ctx, "file:///readme.md")
+	assert.ErrorContains(t, err, "not a lazy-mcp:// resource URI")
+	_, err = registry.ReadResource(ctx, "lazy-mcp://missing/file:///readme.md")
+	assert.ErrorIs(t, err, ErrUnknownServer)
+}
+
+// TestResourceTemplatesAreNamespacedByServer verifies that resource templates
+// are listed under lazy-mcp:// URI templates, and that the URIs they expand
+// to are read from their server.
+func TestResourceTemplatesAreNamespacedByServer(t *testing.T) {
+	tracker := server.NewMCPServer("tracker", "1.0.0")
+	tracker.AddResourceTemplate(mcp.NewResourceTemplate("issues://{id}", "issue", mcp.WithTemplateMIMEType("text/plain")), func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return []mcp.ResourceContents{mcp.TextResourceContents{URI: request.Params.URI, MIMEType: "text/plain", Text: "Issue " + request.Params.URI}}, nil
+	})
+
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"tracker": {}, "echo": {}},
+		map[string]*server.MCPServer{"tracker": tracker, "echo": newEchoServer()},
+		nil,
+	)
+	defer registry.Close()
+
+	ctx := context.Background()
+	templates, err := registry.ListResourceTemplates(ctx, "tracker")
+	require.NoError(t, err)
+	require.Len(t, templates, 1)
+	assert.Equal(t, "lazy-mcp://tracker/issues://{id}", templates[0].URITemplate.Raw())
+	assert.Equal(t, "text/plain", templates[0].MIMEType)
+
+	uri, err := templates[0].URITemplate.Expand(uritemplate.Values{"id": uritemplate.String("42")})
+	require.NoError(t, err)
+	contents, err := registry.ReadResource(ctx, uri)
+	require.NoError(t, err)
+	require.Len(t, contents, 1)
+	assert.Equal(t, mcp.TextResourceContents{URI: "lazy-mcp://tracker/issues://42", MIMEType: "text/plain", Text: "Issue issues://42"}, contents[0])
+
+	templates, err = registry.ListResourceTemplates(ctx, "echo")
+	require.NoError(t, err)
+	assert.Empty(t, templates, "servers without the resources capability have none")
+}
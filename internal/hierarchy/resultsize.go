@@ -0,0 +1,256 @@
+package hierarchy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// ResultScheme prefixes the URIs of tool results, and binary content of them,
+// saved rather than returned inline, which take the form
+// lazy-mcp-result://<id>
+const ResultScheme = "lazy-mcp-result://"
+
+// MaxResultBytes returns how large a result of the given server's tools may
+// be before it is cut down. Zero means results are never cut down.
+func (r *ServerRegistry) MaxResultBytes(serverName string) int {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil {
+		return 0
+	}
+	return max(cfg.Options.MaxResultBytes.OrElse(0), 0)
+}
+
+// OversizedResults returns what is done with results of the given server's
+// tools over MaxResultBytes: one of config's OversizedResults modes
+func (r *ServerRegistry) OversizedResults(serverName string) string {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil {
+		return config.OversizedResultsTruncate
+	}
+	return cfg.Options.OversizedResults.OrElse(config.OversizedResultsTruncate)
+}
+
+// limitResult returns the result of the given tool as it is if it is within
+// the server's maxResultBytes, and otherwise cut down to it with a notice
+// saying so. With oversizedResults "spill", the whole result is saved first
+// and linked from the notice, to be read as a resource.
+func (r *ServerRegistry) limitResult(ctx context.Context, serverName, toolName string, result *mcp.CallToolResult) *mcp.CallToolResult {
+	limit := r.MaxResultBytes(serverName)
+	if limit == 0 || result == nil {
+		return result
+	}
+	size := resultSize(result)
+	if size <= limit {
+		return result
+	}
+
+	notice := fmt.Sprintf("[lazy-mcp: result of %d bytes truncated to the %d byte limit of server %s]", size, limit, serverName)
+	var link *mcp.ResourceLink
+	if r.OversizedResults(serverName) == config.OversizedResultsSpill {
+		spilled, err := r.spills.saveResult(serverName, toolName, result)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to save oversized tool result", "error", err)
+		} else {
+			link = &spilled
+			notice = fmt.Sprintf("[lazy-mcp: result of %d bytes truncated to the %d byte limit of server %s; read resource %s for all of it]", size, limit, serverName, spilled.URI)
+		}
+	}
+	slog.InfoContext(ctx, "Truncated oversized tool result", "size", size, "limit", limit, "spilled", link != nil)
+
+	truncated := truncateResult(result, limit)
+	truncated.Content = append(truncated.Content, mcp.NewTextContent(notice))
+	if link != nil {
+		truncated.Content = append(truncated.Content, *link)
+	}
+	return truncated
+}
+
+// resultSize returns the size of a result as its client receives it: the
+// length of its texts, and that of the JSON of everything else
+func resultSize(result *mcp.CallToolResult) int {
+	size := 0
+	for _, content := range result.Content {
+		size += contentSize(content)
+	}
+	if result.StructuredContent != nil {
+		if data, err := json.Marshal(result.StructuredContent); err == nil {
+			size += len(data)
+		}
+	}
+	return size
+}
+
+func contentSize(content mcp.Content) int {
+	if text, ok := content.(mcp.TextContent); ok {
+		return len(text.Text)
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// truncateResult returns a copy of a result cut down to limit bytes: its
+// content in order up to the limit, the text that reaches it cut short. What
+// follows is dropped, as is content other than text that does not fit and
+// structured content, which cannot be cut short.
+func truncateResult(result *mcp.CallToolResult, limit int) *mcp.CallToolResult {
+	truncated := &mcp.CallToolResult{Result: result.Result, IsError: result.IsError}
+	remaining := limit
+	for _, content := range result.Content {
+		size := contentSize(content)
+		if size <= remaining {
+			truncated.Content = append(truncated.Content, content)
+			remaining -= size
+			continue
+		}
+		if text, ok := content.(mcp.TextContent); ok {
+			// Not splitting a UTF-8 sequence
+			text.Text = strings.ToValidUTF8(text.Text[:remaining], "")
+			if text.Text != "" {
+				truncated.Content = append(truncated.Content, text)
+			}
+		}
+		break
+	}
+	return truncated
+}
+
+// spills are the oversized results saved to files, in a directory created
+// on the first and removed with the registry
+type spills struct {
+	mu    sync.Mutex
+	dir   string
+	files map[string]spilledResult // By ID
+}
+
+type spilledResult struct {
+	path     string
+	mimeType string
+	binary   bool // Read back as a blob rather than text
+}
+
+func newSpills() *spills {
+	return &spills{files: make(map[string]spilledResult)}
+}
+
+// saveResult writes a result to a file and returns a link to it: the texts
+// of its content if it has nothing else, and the JSON of the whole result
+// otherwise
+func (s *spills) saveResult(serverName, toolName string, result *mcp.CallToolResult) (mcp.ResourceLink, error) {
+	data, mimeType := spillData(result)
+	return s.save(toolName+" result", fmt.Sprintf("Result of tool %s on server %s", toolName, serverName), data, mimeType, false)
+}
+
+func spillData(result *mcp.CallToolResult) (data []byte, mimeType string) {
+	texts := make([]string, 0, len(result.Content))
+	for _, content := range result.Content {
+		text, ok := content.(mcp.TextContent)
+		if !ok {
+			texts = nil
+			break
+		}
+		texts = append(texts, text.Text)
+	}
+	if texts != nil && result.StructuredContent == nil {
+		return []byte(strings.Join(texts, "\n")), "text/plain"
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return []byte(strings.Join(texts, "\n")), "text/plain"
+	}
+	return data, "application/json"
+}
+
+// save writes data to a file and returns a link to it, named and described
+// as given, the description followed by the file's path for clients on the
+// same machine
+func (s *spills) save(name, description string, data []byte, mimeType string, binary bool) (mcp.ResourceLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dir == "" {
+		dir, err := os.MkdirTemp("", "lazy-mcp-results-")
+		if err != nil {
+			return mcp.ResourceLink{}, fmt.Errorf("failed to create directory for results: %w", err)
+		}
+		s.dir = dir
+	}
+	id := logging.NewRequestID()
+	path := filepath.Join(s.dir, id+extension(mimeType))
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return mcp.ResourceLink{}, fmt.Errorf("failed to save result: %w", err)
+	}
+	s.files[id] = spilledResult{path: path, mimeType: mimeType, binary: binary}
+	return mcp.NewResourceLink(ResultScheme+id, name, fmt.Sprintf("%s, also saved as %s", description, path), mimeType), nil
+}
+
+// commonExtensions are the extensions of saved files of the commonest types,
+// for which mime knows several
+var commonExtensions = map[string]string{
+	"text/plain": ".txt",
+	"image/jpeg": ".jpg",
+	"audio/mpeg": ".mp3",
+}
+
+// extension returns the file name extension for a MIME type
+func extension(mimeType string) string {
+	if ext, ok := commonExtensions[mimeType]; ok {
+		return ext
+	}
+	if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+// read returns the contents of a saved result by its URI
+func (s *spills) read(uri string) ([]mcp.ResourceContents, error) {
+	id, ok := strings.CutPrefix(uri, ResultScheme)
+	if !ok {
+		return nil, fmt.Errorf("not a %s resource URI: %s", ResultScheme, uri)
+	}
+	s.mu.Lock()
+	spilled, exists := s.files[id]
+	s.mu.Unlock()
+	if !exists {
+		return nil, fmt.Errorf("no saved result %s", uri)
+	}
+	data, err := os.ReadFile(spilled.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read saved result %s: %w", uri, err)
+	}
+	if spilled.binary {
+		return []mcp.ResourceContents{mcp.BlobResourceContents{URI: uri, MIMEType: spilled.mimeType, Blob: base64.StdEncoding.EncodeToString(data)}}, nil
+	}
+	return []mcp.ResourceContents{mcp.TextResourceContents{URI: uri, MIMEType: spilled.mimeType, Text: string(data)}}, nil
+}
+
+// remove deletes the saved results
+func (s *spills) remove() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dir != "" {
+		_ = os.RemoveAll(s.dir)
+		s.dir = ""
+	}
+	s.files = make(map[string]spilledResult)
+}
+
+// ReadResult reads a tool result saved by oversizedResults "spill", or
+// binary content saved by maxInlineBinaryBytes, by its lazy-mcp-result:// URI
+func (r *ServerRegistry) ReadResult(uri string) ([]mcp.ResourceContents, error) {
+	return r.spills.read(uri)
+}
@@ -0,0 +1,112 @@
+package hierarchy
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// newDumpServer returns a server whose dump tool returns text of the given
+// size
+func newDumpServer(size int) *server.MCPServer {
+	dump := server.NewMCPServer("dump", "1.0.0")
+	dump.AddTool(mcp.NewTool("dump"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(strings.Repeat("x", size)), nil
+	})
+	return dump
+}
+
+// TestMaxResultBytes verifies that results within maxResultBytes pass
+// through whole, and that larger ones are truncated, or also spilled to a
+// resource, as oversizedResults says.
+func TestMaxResultBytes(t *testing.T) {
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"small":     {Tools: map[string]*ToolDefinition{"dump": {Server: "small"}}},
+		"truncated": {Tools: map[string]*ToolDefinition{"dump": {Server: "truncated"}}},
+		"spilled":   {Tools: map[string]*ToolDefinition{"dump": {Server: "spilled"}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"small":     {Options: &config.OptionsV2{MaxResultBytes: optional.NewField(100)}},
+			"truncated": {Options: &config.OptionsV2{MaxResultBytes: optional.NewField(100)}},
+			"spilled": {Options: &config.OptionsV2{
+				MaxResultBytes:   optional.NewField(100),
+				OversizedResults: optional.NewField(config.OversizedResultsSpill),
+			}},
+		},
+		map[string]*server.MCPServer{
+			"small":     newDumpServer(100),
+			"truncated": newDumpServer(1000),
+			"spilled":   newDumpServer(1000),
+		},
+		nil,
+	)
+
+	result, err := h.HandleExecuteTool(context.Background(), registry, "small.dump", nil)
+	require.NoError(t, err)
+	require.Len(t, result.Content, 1)
+	assert.Len(t, result.Content[0].(mcp.TextContent).Text, 100)
+
+	result, err = h.HandleExecuteTool(context.Background(), registry, "truncated.dump", nil)
+	require.NoError(t, err)
+	require.Len(t, result.Content, 2)
+	assert.Equal(t, strings.Repeat("x", 100), result.Content[0].(mcp.TextContent).Text)
+	assert.Contains(t, result.Content[1].(mcp.TextContent).Text, "result of 1000 bytes truncated to the 100 byte limit")
+
+	result, err = h.HandleExecuteTool(context.Background(), registry, "spilled.dump", nil)
+	require.NoError(t, err)
+	require.Len(t, result.Content, 3)
+	assert.Equal(t, strings.Repeat("x", 100), result.Content[0].(mcp.TextContent).Text)
+	link, ok := result.Content[2].(mcp.ResourceLink)
+	require.True(t, ok)
+	assert.True(t, strings.HasPrefix(link.URI, ResultScheme))
+	assert.Contains(t, result.Content[1].(mcp.TextContent).Text, link.URI)
+
+	contents, err := registry.ReadResult(link.URI)
+	require.NoError(t, err)
+	require.Len(t, contents, 1)
+	text := contents[0].(mcp.TextResourceContents)
+	assert.Equal(t, "text/plain", text.MIMEType)
+	assert.Equal(t, strings.Repeat("x", 1000), text.Text)
+
+	// Saved results go with the registry
+	dir := registry.spills.dir
+	registry.Close()
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+	_, err = registry.ReadResult(link.URI)
+	assert.Error(t, err)
+}
+
+// TestTruncateResult verifies that truncation keeps content in order up to
+// the limit, does not split UTF-8 sequences and drops what follows.
+func TestTruncateResult(t *testing.T) {
+	result := &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.NewTextContent("héllo"),
+			mcp.NewImageContent(strings.Repeat("A", 100), "image/png"),
+			mcp.NewTextContent("world"),
+		},
+		StructuredContent: map[string]any{"greeting": "héllo world"},
+	}
+	assert.Greater(t, resultSize(result), 100)
+
+	truncated := truncateResult(result, 2)
+	assert.Equal(t, []mcp.Content{mcp.NewTextContent("h")}, truncated.Content)
+	assert.Nil(t, truncated.StructuredContent)
+
+	// The image does not fit, and neither does what follows it
+	truncated = truncateResult(result, 50)
+	assert.Equal(t, []mcp.Content{mcp.NewTextContent("héllo")}, truncated.Content)
+
+	truncated = truncateResult(result, resultSize(result)-1)
+	assert.Equal(t, []mcp.Content{result.Content[0], result.Content[1], mcp.NewTextContent("world")}, truncated.Content)
+}
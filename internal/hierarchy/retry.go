@@ -0,0 +1,115 @@
+package hierarchy
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/voicetreelab/lazy-mcp/internal/client"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+const (
+	defaultRetryAttempts   = 2
+	defaultRetryBackoff    = 200 * time.Millisecond
+	defaultRetryMaxBackoff = 5 * time.Second
+)
+
+// retryPolicy is a server's resolved retry configuration
+type retryPolicy struct {
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	connection  bool
+	transport   bool
+}
+
+// retryPolicy returns the retry policy of the given server. Without one
+// configured, a call cut short by a lost connection is retried once.
+func (r *ServerRegistry) retryPolicy(serverName string) retryPolicy {
+	policy := retryPolicy{
+		maxAttempts: defaultRetryAttempts,
+		backoff:     defaultRetryBackoff,
+		maxBackoff:  defaultRetryMaxBackoff,
+		connection:  true,
+	}
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil || cfg.Options.Retry == nil {
+		return policy
+	}
+
+	retry := cfg.Options.Retry
+	if retry.MaxAttempts > 0 {
+		policy.maxAttempts = retry.MaxAttempts
+	}
+	if retry.Backoff > 0 {
+		policy.backoff = retry.Backoff.Std()
+	}
+	if retry.MaxBackoff > 0 {
+		policy.maxBackoff = retry.MaxBackoff.Std()
+	}
+	if retry.RetryOn != nil {
+		policy.connection = slices.Contains(retry.RetryOn, config.RetryOnConnection)
+		policy.transport = slices.Contains(retry.RetryOn, config.RetryOnTransport)
+	}
+	return policy
+}
+
+// retryable reports whether a call that failed with err on the given attempt
+// should be made again. Only idempotent tools are retried, except that any
+// tool is retried once after its server crashed, as it did before retries
+// were configurable.
+func (p retryPolicy) retryable(ctx context.Context, err error, attempt int, idempotent bool) bool {
+	if err == nil || attempt >= p.maxAttempts || ctx.Err() != nil {
+		return false
+	}
+	switch {
+	case errors.Is(err, client.ErrConnectionLost):
+		return p.connection && (idempotent || attempt == 1)
+	case isTransportError(err):
+		return p.transport && idempotent
+	}
+	return false
+}
+
+// isTransportError reports whether err is a failure to exchange a request
+// with the server, as opposed to an error the server answered with.
+func isTransportError(err error) bool {
+	var transportErr *transport.Error
+	return errors.As(err, &transportErr) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// wait blocks for the backoff before the retry following the given attempt,
+// returning early with an error if ctx is done first or would be by then
+func (p retryPolicy) wait(ctx context.Context, attempt int) error {
+	delay := p.backoff
+	for i := 1; i < attempt && delay < p.maxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.maxBackoff)
+	// Equal jitter, as for restarts
+	delay = delay/2 + rand.N(delay/2+1)
+	// A retry the call's deadline would cut short is not made
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+		return context.DeadlineExceeded
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isIdempotent reports whether the server annotates the tool as read-only or
+// idempotent, so that calling it again is safe.
+func (r *ServerRegistry) isIdempotent(ctx context.Context, serverName, toolName string) bool {
+	hints, listed := r.toolHints(ctx, serverName, toolName)
+	return listed && (hints.readOnly || hints.idempotent)
+}
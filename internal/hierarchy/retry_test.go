@@ -0,0 +1,83 @@
+package hierarchy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestTransportErrorsAreRetriedForIdempotentTools verifies that a call whose
+// request fails in transit is retried when the tool is idempotent, and is not
+// when the tool may have side effects.
+func TestTransportErrorsAreRetriedForIdempotentTools(t *testing.T) {
+	flaky := server.NewMCPServer("flaky", "1.0.0")
+	flaky.AddTool(mcp.NewTool("lookup", mcp.WithReadOnlyHintAnnotation(true)), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("found"), nil
+	})
+	flaky.AddTool(mcp.NewTool("charge", mcp.WithReadOnlyHintAnnotation(false), mcp.WithIdempotentHintAnnotation(false)), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("charged"), nil
+	})
+
+	// Every other tool call is answered with a gateway error
+	var calls atomic.Int32
+	handler := server.NewStreamableHTTPServer(flaky)
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if bytes.Contains(body, []byte(`"tools/call"`)) && calls.Add(1)%2 == 1 {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer httpServer.Close()
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{
+			"lookup": {Server: "flaky"},
+			"charge": {Server: "flaky"},
+		}},
+	}}
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"flaky": {Type: config.MCPClientTypeStreamable, URL: httpServer.URL, Options: &config.OptionsV2{
+			Retry: &config.RetryConfig{
+				MaxAttempts: 3,
+				Backoff:     config.Duration(time.Millisecond),
+				RetryOn:     []string{config.RetryOnConnection, config.RetryOnTransport},
+			},
+		}},
+	})
+	defer registry.Close()
+
+	result, err := h.HandleExecuteTool(context.Background(), registry, "lookup", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "found", result.Content[0].(mcp.TextContent).Text)
+	assert.Equal(t, int32(2), calls.Load())
+
+	_, err = h.HandleExecuteTool(context.Background(), registry, "charge", nil)
+	assert.Error(t, err, "a tool that is not idempotent is not called again")
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+// TestRetryWaitRespectsDeadline verifies that no retry is waited for that the
+// call's deadline would cut short.
+func TestRetryWaitRespectsDeadline(t *testing.T) {
+	policy := retryPolicy{maxAttempts: 3, backoff: time.Minute, maxBackoff: time.Minute}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	assert.ErrorIs(t, policy.wait(ctx, 1), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
@@ -0,0 +1,64 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// RootsFunc asks the client that made the request ctx belongs to for its roots
+type RootsFunc func(ctx context.Context, request mcp.ListRootsRequest) (*mcp.ListRootsResult, error)
+
+// OnRootsRequest registers fn to answer the roots/list requests of servers.
+// Without it, servers are not offered roots. It only affects servers started
+// afterwards.
+func (r *ServerRegistry) OnRootsRequest(fn RootsFunc) {
+	r.roots.Store(&fn)
+}
+
+// RootsChanged tells every running server that was offered roots that the
+// client's roots changed, so that they list them again
+func (r *ServerRegistry) RootsChanged(ctx context.Context) {
+	if r.roots.Load() == nil {
+		return
+	}
+
+	r.mu.RLock()
+	states := make(map[string]*serverState, len(r.servers))
+	for name, state := range r.servers {
+		states[name] = state
+	}
+	r.mu.RUnlock()
+
+	for name, state := range states {
+		if err := state.client.GetClient().RootListChanges(ctx); err != nil {
+			logging.ForServer(name).WarnContext(ctx, "Failed to forward roots change", "error", err)
+		}
+	}
+}
+
+// rootsHandler answers a server's roots/list requests by asking the client
+// whose request the server is serving or, between requests, the client that
+// used it last
+type rootsHandler struct {
+	registry *ServerRegistry
+	server   string
+}
+
+func (h rootsHandler) ListRoots(ctx context.Context, request mcp.ListRootsRequest) (*mcp.ListRootsResult, error) {
+	fn := h.registry.roots.Load()
+	callerCtx, ok := h.registry.lastCaller(h.server)
+	if fn == nil || !ok {
+		return nil, fmt.Errorf("%w for server %s to list roots on behalf of", ErrNoCaller, h.server)
+	}
+	upstreamCtx, cancel := upstreamContext(callerCtx, ctx)
+	defer cancel()
+
+	result, err := (*fn)(upstreamCtx, request)
+	if err != nil {
+		return nil, fmt.Errorf("roots request of server %s failed: %w", h.server, err)
+	}
+	return result, nil
+}
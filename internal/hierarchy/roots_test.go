@@ -0,0 +1,69 @@
+package hierarchy
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestRootsAreForwardedToTheCaller verifies that a server listing roots gets
+// those of the client it serves, and hears when they change.
+func TestRootsAreForwardedToTheCaller(t *testing.T) {
+	changed := make(chan struct{}, 1)
+	files := server.NewMCPServer("files", "1.0.0", server.WithRoots())
+	files.AddNotificationHandler(mcp.MethodNotificationRootsListChanged, func(ctx context.Context, notification mcp.JSONRPCNotification) {
+		changed <- struct{}{}
+	})
+	files.AddTool(mcp.NewTool("workspace"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := files.RequestRoots(ctx, mcp.ListRootsRequest{})
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		uris := make([]string, 0, len(result.Roots))
+		for _, root := range result.Roots {
+			uris = append(uris, root.URI)
+		}
+		return mcp.NewToolResultText(strings.Join(uris, ",")), nil
+	})
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"workspace": {Server: "files"}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"files": {}},
+		map[string]*server.MCPServer{"files": files},
+		nil,
+	)
+	defer registry.Close()
+
+	registry.OnRootsRequest(func(ctx context.Context, request mcp.ListRootsRequest) (*mcp.ListRootsResult, error) {
+		caller, _ := ctx.Value(callerKey{}).(string)
+		return &mcp.ListRootsResult{Roots: []mcp.Root{{URI: "file:///home/" + caller, Name: caller}}}, nil
+	})
+
+	ctx := context.WithValue(context.Background(), callerKey{}, "alice")
+	result, err := h.HandleExecuteTool(ctx, registry, "workspace", nil)
+	require.NoError(t, err)
+	require.False(t, result.IsError, "%v", result.Content)
+	assert.Equal(t, "file:///home/alice", result.Content[0].(mcp.TextContent).Text)
+
+	// Between calls, the roots are those of the client that used the server last
+	roots, err := rootsHandler{registry: registry, server: "files"}.ListRoots(context.Background(), mcp.ListRootsRequest{})
+	require.NoError(t, err)
+	require.Len(t, roots.Roots, 1)
+	assert.Equal(t, "alice", roots.Roots[0].Name)
+
+	registry.RootsChanged(ctx)
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server was not told that the roots changed")
+	}
+}
@@ -0,0 +1,54 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// SamplingFunc sends a sampling request to the client that made the request
+// ctx belongs to
+type SamplingFunc func(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error)
+
+// OnSamplingRequest registers fn to forward the sampling requests of servers
+// that are allowed to sample. Without it, servers are not offered sampling.
+// It only affects servers started afterwards.
+func (r *ServerRegistry) OnSamplingRequest(fn SamplingFunc) {
+	r.sampling.Store(&fn)
+}
+
+// AllowSampling reports whether the given server may ask clients to sample,
+// per its allowSampling option
+func (r *ServerRegistry) AllowSampling(serverName string) bool {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil {
+		return true
+	}
+	return cfg.Options.AllowSampling.OrElse(true)
+}
+
+// samplingHandler answers a server's sampling requests by forwarding them to
+// the client whose request the server is serving
+type samplingHandler struct {
+	registry *ServerRegistry
+	server   string
+}
+
+func (h samplingHandler) CreateMessage(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	fn := h.registry.sampling.Load()
+	callerCtx, ok := h.registry.currentCaller(h.server)
+	if fn == nil || !ok {
+		return nil, fmt.Errorf("%w for server %s to sample on behalf of", ErrNoCaller, h.server)
+	}
+	upstreamCtx, cancel := upstreamContext(callerCtx, ctx)
+	defer cancel()
+
+	logging.ForServer(h.server).DebugContext(upstreamCtx, "Forwarding sampling request", "messages", len(request.Messages))
+	result, err := (*fn)(upstreamCtx, request)
+	if err != nil {
+		return nil, fmt.Errorf("sampling request of server %s failed: %w", h.server, err)
+	}
+	return result, nil
+}
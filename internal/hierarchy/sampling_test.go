@@ -0,0 +1,75 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+type callerKey struct{}
+
+// newSamplingServer returns a server whose summarize tool asks the client to
+// sample a summary
+func newSamplingServer(name string) *server.MCPServer {
+	mcpServer := server.NewMCPServer(name, "1.0.0")
+	mcpServer.EnableSampling()
+	mcpServer.AddTool(mcp.NewTool("summarize"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := mcpServer.RequestSampling(ctx, mcp.CreateMessageRequest{CreateMessageParams: mcp.CreateMessageParams{
+			Messages:  []mcp.SamplingMessage{{Role: mcp.RoleUser, Content: mcp.NewTextContent("Summarize")}},
+			MaxTokens: 100,
+		}})
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result.Content.(mcp.TextContent).Text), nil
+	})
+	return mcpServer
+}
+
+// TestSamplingIsForwardedToTheCaller verifies that a server's sampling request
+// is answered on behalf of the client request it is serving, and that servers
+// with allowSampling: false are not offered sampling.
+func TestSamplingIsForwardedToTheCaller(t *testing.T) {
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{
+			"summarize": {Server: "notes", MapsTo: "summarize"},
+			"private":   {Server: "private", MapsTo: "summarize"},
+		}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"notes":   {},
+			"private": {Options: &config.OptionsV2{AllowSampling: optional.NewField(false)}},
+		},
+		map[string]*server.MCPServer{"notes": newSamplingServer("notes"), "private": newSamplingServer("private")},
+		nil,
+	)
+	defer registry.Close()
+
+	registry.OnSamplingRequest(func(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		caller, _ := ctx.Value(callerKey{}).(string)
+		return &mcp.CreateMessageResult{
+			SamplingMessage: mcp.SamplingMessage{Role: mcp.RoleAssistant, Content: mcp.NewTextContent("summary for " + caller)},
+			Model:           "test",
+		}, nil
+	})
+
+	ctx := context.WithValue(context.Background(), callerKey{}, "alice")
+	result, err := h.HandleExecuteTool(ctx, registry, "summarize", nil)
+	require.NoError(t, err)
+	require.False(t, result.IsError, "%v", result.Content)
+	assert.Equal(t, "summary for alice", result.Content[0].(mcp.TextContent).Text)
+
+	result, err = h.HandleExecuteTool(ctx, registry, "private", nil)
+	require.NoError(t, err)
+	assert.True(t, result.IsError, "a server that may not sample must not reach the client")
+
+	_, err = samplingHandler{registry: registry, server: "notes"}.CreateMessage(context.Background(), mcp.CreateMessageRequest{})
+	assert.ErrorIs(t, err, ErrNoCaller)
+}
@@ -0,0 +1,161 @@
+package hierarchy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ArgumentsError is returned for a tool call whose arguments do not match the
+// tool's input schema, without calling the server
+type ArgumentsError struct {
+	Server   string
+	Tool     string
+	Problems []string
+	Schema   map[string]interface{}
+}
+
+func (e *ArgumentsError) Error() string {
+	msg := fmt.Sprintf("invalid arguments for tool %s on server %s: %s", e.Tool, e.Server, strings.Join(e.Problems, "; "))
+	// The schema helps LLMs self-correct
+	if schemaJSON, err := json.MarshalIndent(e.Schema, "", "  "); err == nil {
+		msg += "\n\nExpected inputSchema:\n" + string(schemaJSON)
+	}
+	return msg
+}
+
+// ValidatesArguments reports whether the arguments of calls to the given
+// server are checked against the tool's input schema before they are sent
+func (r *ServerRegistry) ValidatesArguments(serverName string) bool {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil {
+		return true
+	}
+	return cfg.Options.ValidateArguments.OrElse(true)
+}
+
+// validateArguments checks arguments against a tool's JSON input schema,
+// returning a problem for each mismatch. It covers what tool schemas commonly
+// use: type, required, properties, additionalProperties, enum and items.
+func validateArguments(schema map[string]interface{}, arguments map[string]interface{}) []string {
+	if len(schema) == 0 {
+		return nil
+	}
+	var problems []string
+	validateValue(schema, "arguments", arguments, &problems)
+	return problems
+}
+
+func validateValue(schema map[string]interface{}, path string, value interface{}, problems *[]string) {
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		actual := jsonType(value)
+		matched := false
+		for _, t := range types {
+			if t == actual || (t == "number" && actual == "integer") {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			*problems = append(*problems, fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(types, " or "), actual))
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !inEnum(enum, value) {
+		allowed, _ := json.Marshal(enum)
+		*problems = append(*problems, fmt.Sprintf("%s: must be one of %s", path, allowed))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		validateObject(schema, path, v, problems)
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateValue(items, fmt.Sprintf("%s[%d]", path, i), item, problems)
+			}
+		}
+	}
+}
+
+func validateObject(schema map[string]interface{}, path string, object map[string]interface{}, problems *[]string) {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, present := object[name]; !present {
+					*problems = append(*problems, fmt.Sprintf("%s.%s: is required", path, name))
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if property, ok := properties[name].(map[string]interface{}); ok {
+			validateValue(property, path+"."+name, object[name], problems)
+			continue
+		}
+		if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+			*problems = append(*problems, fmt.Sprintf("%s.%s: is not a known property", path, name))
+		}
+	}
+}
+
+// schemaTypes returns the types a schema's "type" allows
+func schemaTypes(value interface{}) []string {
+	switch t := value.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var types []string
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// jsonType returns the JSON schema type of a decoded JSON value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case int, int64:
+		return "integer"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if reflect.DeepEqual(allowed, value) {
+			return true
+		}
+	}
+	return false
+}
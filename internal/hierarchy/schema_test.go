@@ -0,0 +1,54 @@
+package hierarchy
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestInvalidArgumentsDoNotStartServer verifies that a call whose arguments do
+// not match the tool's schema fails without starting its server, unless
+// validateArguments is off.
+func TestInvalidArgumentsDoNotStartServer(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"message": map[string]interface{}{"type": "string"}},
+		"required":   []interface{}{"message"},
+	}
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"checked":   {Tools: map[string]*ToolDefinition{"echo": {Server: "checked", InputSchema: schema}}},
+		"unchecked": {Tools: map[string]*ToolDefinition{"echo": {Server: "unchecked", InputSchema: schema}}},
+	}}
+	var launches int32
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"checked":   {},
+			"unchecked": {Options: &config.OptionsV2{ValidateArguments: optional.NewField(false)}},
+		},
+		map[string]*server.MCPServer{"checked": newEchoServer(), "unchecked": newEchoServer()},
+		&launches,
+	)
+	defer registry.Close()
+
+	_, err := h.HandleExecuteTool(context.Background(), registry, "checked.echo", map[string]interface{}{"message": 42})
+	var argsErr *ArgumentsError
+	require.ErrorAs(t, err, &argsErr)
+	assert.Equal(t, "checked", argsErr.Server)
+	assert.Equal(t, []string{"arguments.message: expected string, got integer"}, argsErr.Problems)
+	assert.Contains(t, err.Error(), "Expected inputSchema")
+
+	_, err = h.HandleExecuteTool(context.Background(), registry, "checked.echo", nil)
+	require.ErrorAs(t, err, &argsErr)
+	assert.Equal(t, []string{"arguments.message: is required"}, argsErr.Problems)
+	assert.Zero(t, atomic.LoadInt32(&launches), "the server is not started")
+
+	_, err = h.HandleExecuteTool(context.Background(), registry, "unchecked.echo", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&launches))
+}
@@ -0,0 +1,257 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// DefaultSearchLimit is the number of matches search_tools returns by default
+const DefaultSearchLimit = 10
+
+// toolMatch is a tool found by HandleSearchTools
+type toolMatch struct {
+	tool     exposedTool
+	def      *ToolDefinition
+	original string  // The tool's name in its node of the hierarchy
+	score    float64 // Fuzzy score, or similarity from 0 to 1
+}
+
+// info describes the match in a search_tools response
+func (m toolMatch) info() map[string]interface{} {
+	info := m.tool.info()
+	info["name"] = m.tool.name
+	info["server"] = m.def.Server
+	info["score"] = m.score
+	if len(m.tool.schema) > 0 {
+		info["inputSchema"] = m.tool.schema
+	}
+	return info
+}
+
+// HandleSearchTools handles the search_tools meta-tool. It matches query
+// against the names, paths and descriptions of all tools in the hierarchy
+// and returns the best limit matches, best first. Matching is fuzzy, or
+// semantic once IndexEmbeddings has run. Only the hierarchy is read, so
+// servers are not started to search their tools.
+func (h *Hierarchy) HandleSearchTools(ctx context.Context, query string, limit int) (map[string]interface{}, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("query must contain a word to search for")
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+
+	tools := h.searchableTools()
+	var matches []toolMatch
+	mode := config.SearchModeFuzzy
+	if h.semantic.ready() {
+		var err error
+		if matches, err = h.semantic.rank(ctx, query, tools); err == nil {
+			mode = config.SearchModeSemantic
+		} else {
+			slog.Warn("Semantic search failed, falling back to fuzzy search", "error", err)
+		}
+	}
+	if mode == config.SearchModeFuzzy {
+		for _, tool := range tools {
+			if score := scoreTool(terms, tool.tool); score > 0 {
+				matches = append(matches, toolMatch{tool: tool.tool, def: tool.def, score: float64(score)})
+			}
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].tool.path < matches[j].tool.path
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	results := make([]map[string]interface{}, 0, len(matches))
+	for _, match := range matches {
+		results = append(results, match.info())
+	}
+	return map[string]interface{}{
+		"query":   query,
+		"mode":    mode,
+		"matches": results,
+	}, nil
+}
+
+// searchableTools returns the tools that may be listed, as listed, in the
+// order of their nodes
+func (h *Hierarchy) searchableTools() []toolMatch {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.subtreeToolMatches("")
+}
+
+// subtreeToolMatches returns the tools of the category at path and below as
+// searchableTools does. h.mu must be held.
+func (h *Hierarchy) subtreeToolMatches(path string) []toolMatch {
+	nodePaths := make([]string, 0, len(h.nodes))
+	for nodePath := range h.nodes {
+		if nodePath == "/" {
+			continue // Alias of the root
+		}
+		if path == "" || nodePath == path || strings.HasPrefix(nodePath, path+".") {
+			nodePaths = append(nodePaths, nodePath)
+		}
+	}
+	sort.Strings(nodePaths)
+
+	var tools []toolMatch
+	seen := make(map[string]bool)
+	for _, nodePath := range nodePaths {
+		var nodeTools []toolMatch
+		for toolName, toolDef := range h.nodes[nodePath].Tools {
+			// Meta-tools listed in the hierarchy have no server
+			if toolDef.Server == "" || !h.isIncluded(toolName, toolDef) {
+				continue
+			}
+			tool := h.exposeTool(toolName, searchToolPath(nodePath, toolName), toolDef)
+			nodeTools = append(nodeTools, toolMatch{tool: tool, def: toolDef, original: toolName})
+		}
+		sortTools(nodeTools)
+		for _, tool := range h.settleDuplicates(nodePath, nodeTools) {
+			if seen[tool.tool.path] {
+				continue
+			}
+			seen[tool.tool.path] = true
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+// searchToolPath returns the path of a tool of the node at nodePath. In the
+// flat structure, the node's path already ends in the tool's name.
+func searchToolPath(nodePath, toolName string) string {
+	if nodePath == "" {
+		return toolName
+	}
+	if nodePath == toolName || strings.HasSuffix(nodePath, "."+toolName) {
+		return nodePath
+	}
+	return nodePath + "." + toolName
+}
+
+// searchTerms splits text into lowercase words, breaking at punctuation and
+// camelCase boundaries so that "createCard" and "create_card" match alike
+func searchTerms(text string) []string {
+	var terms []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			terms = append(terms, string(word))
+			word = word[:0]
+		}
+	}
+	var prev rune
+	for _, r := range text {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && unicode.IsLower(prev):
+			flush()
+			word = append(word, unicode.ToLower(r))
+		default:
+			word = append(word, unicode.ToLower(r))
+		}
+		prev = r
+	}
+	flush()
+	return terms
+}
+
+// Weights of where a query term matches, best first
+const (
+	scoreNameWord        = 10 // a word of the tool's name or alias
+	scoreNamePrefix      = 6  // the start of a word of the tool's name
+	scoreNameTypo        = 4  // a word of the tool's name, give or take a typo
+	scorePathWord        = 3  // a category or server the tool is in
+	scoreDescriptionWord = 2  // a word of the description, or its start
+	scoreDescriptionTypo = 1  // a word of the description, give or take a typo
+)
+
+// scoreTool scores each query term by its best match against the tool, and
+// sums the scores. Tools matching none of the terms score 0.
+func scoreTool(terms []string, tool exposedTool) int {
+	names := searchTerms(tool.name)
+	for _, alias := range tool.aliases {
+		names = append(names, searchTerms(alias)...)
+	}
+	path := searchTerms(tool.path)
+	description := searchTerms(tool.description)
+
+	total := 0
+	for _, term := range terms {
+		best := 0
+		for _, word := range names {
+			switch {
+			case word == term:
+				best = max(best, scoreNameWord)
+			case strings.HasPrefix(word, term):
+				best = max(best, scoreNamePrefix)
+			case isTypo(term, word):
+				best = max(best, scoreNameTypo)
+			}
+		}
+		for _, word := range path {
+			if word == term {
+				best = max(best, scorePathWord)
+			}
+		}
+		for _, word := range description {
+			switch {
+			case strings.HasPrefix(word, term):
+				best = max(best, scoreDescriptionWord)
+			case isTypo(term, word):
+				best = max(best, scoreDescriptionTypo)
+			}
+		}
+		total += best
+	}
+	return total
+}
+
+// isTypo reports whether term is word with one character inserted, deleted,
+// substituted or two adjacent ones swapped. Short terms must match exactly,
+// as too many words are one edit away from them.
+func isTypo(term, word string) bool {
+	a, b := []rune(term), []rune(word)
+	if len(a) < 4 || len(b) < 4 {
+		return false
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(b)-len(a) > 1 {
+		return false
+	}
+
+	i := 0
+	for i < len(a) && a[i] == b[i] {
+		i++
+	}
+	if i == len(a) {
+		return len(a) != len(b) // Equal words are not typos of each other
+	}
+	if len(a) < len(b) {
+		return string(a[i:]) == string(b[i+1:])
+	}
+	if string(a[i+1:]) == string(b[i+1:]) {
+		return true
+	}
+	return i+1 < len(a) && a[i] == b[i+1] && a[i+1] == b[i] && string(a[i+2:]) == string(b[i+2:])
+}
@@ -0,0 +1,105 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSearchToolsRanksFuzzyMatches verifies that search_tools finds tools by
+// name, typo or description across servers, best match first, and leaves out
+// excluded tools.
+func TestSearchToolsRanksFuzzyMatches(t *testing.T) {
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{
+			"get_tools_in_category": {Description: "Navigate the hierarchy"},
+		}},
+		"trello": {Overview: "Trello boards"},
+		"trello.create_card": {Tools: map[string]*ToolDefinition{
+			"create_card": {Server: "trello", Description: "Creates a card on a board.", InputSchema: map[string]interface{}{"type": "object"}},
+		}},
+		"github.create_issue": {Tools: map[string]*ToolDefinition{
+			"create_issue": {Server: "github", Description: "Opens an issue in a repository."},
+		}},
+		"github.delete_repository": {Tools: map[string]*ToolDefinition{
+			"delete_repository": {Server: "github", Description: "Deletes a repository."},
+		}},
+	}}
+	h.SetToolFilter(func(serverName, toolName string) bool { return toolName != "delete_repository" })
+
+	paths := func(query string) []string {
+		response, err := h.HandleSearchTools(context.Background(), query, 0)
+		require.NoError(t, err)
+		var result []string
+		for _, match := range response["matches"].([]map[string]interface{}) {
+			result = append(result, match["tool_path"].(string))
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"trello.create_card", "github.create_issue"}, paths("create card"))
+	assert.Equal(t, []string{"trello.create_card", "github.create_issue"}, paths("craete crad"), "typos are tolerated")
+	assert.Equal(t, []string{"github.create_issue"}, paths("repository"), "excluded tools are not found")
+	assert.Empty(t, paths("navigate"), "meta-tools are not found")
+
+	response, err := h.HandleSearchTools(context.Background(), "createCard", 1)
+	require.NoError(t, err)
+	matches := response["matches"].([]map[string]interface{})
+	require.Len(t, matches, 1)
+	assert.Equal(t, "trello", matches[0]["server"])
+	assert.Equal(t, map[string]interface{}{"type": "object"}, matches[0]["inputSchema"])
+
+	_, err = h.HandleSearchTools(context.Background(), "  ", 0)
+	assert.Error(t, err)
+}
+
+// conceptEmbedder embeds texts by the concepts their words stand for, like a
+// real model would
+type conceptEmbedder map[string]int
+
+func (c conceptEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = make([]float32, 4)
+		for _, word := range searchTerms(text) {
+			if concept, ok := c[word]; ok {
+				vectors[i][concept]++
+			}
+		}
+	}
+	return vectors, nil
+}
+
+// TestSearchToolsRanksSemantically verifies that once tools are indexed,
+// search_tools finds them by meaning rather than by shared words.
+func TestSearchToolsRanksSemantically(t *testing.T) {
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"trello.create_list": {Tools: map[string]*ToolDefinition{
+			"create_list": {Server: "trello", Description: "Adds a list to a board."},
+		}},
+		"trello.create_card": {Tools: map[string]*ToolDefinition{
+			"create_card": {Server: "trello", Description: "Adds a card to a list."},
+		}},
+		"github.create_issue": {Tools: map[string]*ToolDefinition{
+			"create_issue": {Server: "github", Description: "Opens an issue."},
+		}},
+	}}
+	h.SetEmbeddings(conceptEmbedder{"column": 0, "list": 0, "board": 1, "trello": 1, "card": 2, "issue": 3})
+
+	response, err := h.HandleSearchTools(context.Background(), "make a board column", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "fuzzy", response["mode"], "tools are matched fuzzily until indexed")
+
+	require.NoError(t, h.IndexEmbeddings(context.Background()))
+	response, err = h.HandleSearchTools(context.Background(), "make a board column", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "semantic", response["mode"])
+	matches := response["matches"].([]map[string]interface{})
+	require.NotEmpty(t, matches)
+	assert.Equal(t, "trello.create_list", matches[0]["tool_path"])
+	for _, match := range matches {
+		assert.NotEqual(t, "github.create_issue", match["tool_path"], "unrelated tools are left out")
+	}
+}
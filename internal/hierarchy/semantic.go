@@ -0,0 +1,104 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/voicetreelab/lazy-mcp/internal/embedding"
+)
+
+// minSimilarity is the similarity below which semantic search considers a
+// tool unrelated to the query
+const minSimilarity = 0.1
+
+// semanticIndex holds the embeddings of the hierarchy's tools, by path. A nil
+// *semanticIndex is never ready.
+type semanticIndex struct {
+	provider embedding.Provider
+
+	mu      sync.RWMutex
+	vectors map[string][]float32
+}
+
+// SetEmbeddings makes search_tools rank tools by the similarity of their
+// embeddings from provider to that of the query, once IndexEmbeddings has
+// computed them
+func (h *Hierarchy) SetEmbeddings(provider embedding.Provider) {
+	h.semantic = &semanticIndex{provider: provider}
+}
+
+// IndexEmbeddings computes the embeddings of all tools for semantic search.
+// Until it has, search_tools matches fuzzily.
+func (h *Hierarchy) IndexEmbeddings(ctx context.Context) error {
+	if h.semantic == nil {
+		return nil
+	}
+	tools := h.searchableTools()
+	texts := make([]string, len(tools))
+	for i, tool := range tools {
+		texts[i] = embeddingText(tool.tool)
+	}
+	vectors, err := h.semantic.provider.Embed(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("failed to embed tools: %w", err)
+	}
+	if len(vectors) != len(tools) {
+		return fmt.Errorf("failed to embed tools: got %d embeddings for %d tools", len(vectors), len(tools))
+	}
+
+	index := make(map[string][]float32, len(tools))
+	for i, tool := range tools {
+		index[tool.tool.path] = vectors[i]
+	}
+	h.semantic.mu.Lock()
+	h.semantic.vectors = index
+	h.semantic.mu.Unlock()
+	return nil
+}
+
+// embeddingText is the text embedded for a tool: its name, the categories it
+// is in, and its description
+func embeddingText(tool exposedTool) string {
+	return strings.ReplaceAll(tool.path, ".", " ") + ": " + tool.description
+}
+
+func (s *semanticIndex) ready() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.vectors != nil
+}
+
+// rank scores the tools by their similarity to query, leaving out unrelated
+// ones and those indexed under another path
+func (s *semanticIndex) rank(ctx context.Context, query string, tools []toolMatch) ([]toolMatch, error) {
+	vectors, err := s.provider.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("failed to embed query: got %d embeddings", len(vectors))
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var matches []toolMatch
+	for _, tool := range tools {
+		vector, ok := s.vectors[tool.tool.path]
+		if !ok {
+			continue
+		}
+		similarity := embedding.Similarity(vectors[0], vector)
+		if similarity < minSimilarity {
+			continue
+		}
+		tool.score = math.Round(similarity*1000) / 1000
+		matches = append(matches, tool)
+	}
+	return matches, nil
+}
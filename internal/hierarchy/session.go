@@ -0,0 +1,145 @@
+package hierarchy
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultSessionTTL is how long the state of a client that has gone quiet is
+// kept. Transports that report disconnects free it sooner.
+const DefaultSessionTTL = time.Hour
+
+// Session is the lazy-loading state of one connected client: the categories
+// it has expanded and the tools those expansions revealed to it.
+type Session struct {
+	ID string
+
+	mu         sync.Mutex
+	expanded   map[string]bool
+	discovered map[string]bool
+	lastSeen   time.Time
+}
+
+// Expand records that the client listed the category at path and was shown
+// toolPaths. It returns the tool paths the client had not discovered before,
+// sorted.
+func (s *Session) Expand(path string, toolPaths []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expanded[path] = true
+	var revealed []string
+	for _, toolPath := range toolPaths {
+		if !s.discovered[toolPath] {
+			s.discovered[toolPath] = true
+			revealed = append(revealed, toolPath)
+		}
+	}
+	sort.Strings(revealed)
+	return revealed
+}
+
+// Expanded returns the category paths the client has listed, sorted
+func (s *Session) Expanded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedKeys(s.expanded)
+}
+
+// Discovered returns the tool paths revealed to the client, sorted
+func (s *Session) Discovered() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedKeys(s.discovered)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SessionManager keeps per-client lazy-loading state keyed by MCP session ID,
+// so that one client's expansions never leak into another's view.
+type SessionManager struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+	ttl      time.Duration
+}
+
+// NewSessionManager creates a session manager that forgets sessions unused
+// for ttl. A ttl of zero uses DefaultSessionTTL.
+func NewSessionManager(ttl time.Duration) *SessionManager {
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	return &SessionManager{
+		sessions: make(map[string]*Session),
+		ttl:      ttl,
+	}
+}
+
+// Get returns the session with the given ID, creating it on first use
+func (m *SessionManager) Get(sessionID string) *Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		session = &Session{
+			ID:         sessionID,
+			expanded:   make(map[string]bool),
+			discovered: make(map[string]bool),
+		}
+		m.sessions[sessionID] = session
+	}
+	session.lastSeen = time.Now()
+	return session
+}
+
+// Remove forgets the session with the given ID, e.g. when its client disconnects
+func (m *SessionManager) Remove(sessionID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, sessionID)
+}
+
+// Len returns the number of tracked sessions
+func (m *SessionManager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
+
+// StartExpiry periodically forgets sessions that have been unused for longer
+// than the TTL. It returns when ctx is done.
+func (m *SessionManager) StartExpiry(ctx context.Context) {
+	ticker := time.NewTicker(min(max(m.ttl/2, time.Second), time.Minute))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.expire(time.Now())
+		}
+	}
+}
+
+// expire forgets every session last used more than the TTL before now
+func (m *SessionManager) expire(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, session := range m.sessions {
+		if now.Sub(session.lastSeen) > m.ttl {
+			delete(m.sessions, id)
+		}
+	}
+}
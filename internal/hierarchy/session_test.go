@@ -0,0 +1,46 @@
+package hierarchy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSessionsAreIsolated verifies that expansions by one client are not
+// visible in another client's session.
+func TestSessionsAreIsolated(t *testing.T) {
+	sessions := NewSessionManager(0)
+
+	revealed := sessions.Get("a").Expand("github", []string{"github.create_issue", "github.list_issues"})
+	assert.Equal(t, []string{"github.create_issue", "github.list_issues"}, revealed)
+
+	// Expanding again reveals nothing new
+	assert.Empty(t, sessions.Get("a").Expand("github", []string{"github.list_issues"}))
+
+	b := sessions.Get("b")
+	assert.Empty(t, b.Expanded(), "other sessions start unexpanded")
+	assert.Empty(t, b.Discovered())
+	assert.Equal(t, []string{"github.list_issues"}, b.Expand("github", []string{"github.list_issues"}),
+		"tools discovered by another session are still new to this one")
+
+	assert.Equal(t, []string{"github"}, sessions.Get("a").Expanded())
+	assert.Len(t, sessions.Get("a").Discovered(), 2)
+}
+
+// TestSessionExpiry verifies that sessions are forgotten once unused for the
+// TTL, or on Remove.
+func TestSessionExpiry(t *testing.T) {
+	sessions := NewSessionManager(time.Minute)
+	sessions.Get("idle")
+	sessions.Get("gone")
+
+	sessions.Remove("gone")
+	assert.Equal(t, 1, sessions.Len())
+
+	sessions.expire(time.Now().Add(30 * time.Second))
+	assert.Equal(t, 1, sessions.Len(), "session within its TTL is kept")
+
+	sessions.expire(time.Now().Add(2 * time.Minute))
+	assert.Equal(t, 0, sessions.Len())
+}
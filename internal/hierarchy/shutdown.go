@@ -0,0 +1,56 @@
+package hierarchy
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrShuttingDown is returned for calls made after Drain, while lazy-mcp
+// shuts down
+var ErrShuttingDown = errors.New("lazy-mcp is shutting down")
+
+// drainPollInterval is how often Drain checks for calls still in flight
+const drainPollInterval = 50 * time.Millisecond
+
+// Drain stops the registry from taking calls, so new ones fail with
+// ErrShuttingDown and no server is started, then waits for the calls in
+// flight to finish or ctx to be done. It returns how many calls were still in
+// flight, which Close cuts short.
+func (r *ServerRegistry) Drain(ctx context.Context) int {
+	r.mu.Lock()
+	r.draining = true
+	r.mu.Unlock()
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		inFlight := r.inFlight()
+		if inFlight == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return inFlight
+		case <-ticker.C:
+		}
+	}
+}
+
+// Draining reports whether Drain was called
+func (r *ServerRegistry) Draining() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.draining
+}
+
+// inFlight returns how many calls hold a call slot, across servers
+func (r *ServerRegistry) inFlight() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	total := 0
+	for _, callers := range r.callers {
+		total += len(callers)
+	}
+	return total
+}
@@ -0,0 +1,73 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestDrainWaitsForCallsInFlight verifies that Drain refuses new and queued
+// calls and returns once the call in flight is released.
+func TestDrainWaitsForCallsInFlight(t *testing.T) {
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"echo": {}},
+		map[string]*server.MCPServer{"echo": newEchoServer()},
+		nil,
+	)
+	defer registry.Close()
+
+	ctx := context.Background()
+	release, err := registry.AcquireSlot(ctx, "echo")
+	require.NoError(t, err)
+	queued := make(chan error)
+	go func() {
+		_, err := registry.AcquireSlot(ctx, "echo")
+		queued <- err
+	}()
+	assert.Eventually(t, func() bool {
+		status, err := registry.ServerStatus("echo")
+		return err == nil && status.Queued == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	drained := make(chan int)
+	go func() { drained <- registry.Drain(ctx) }()
+	assert.Eventually(t, registry.Draining, 5*time.Second, 10*time.Millisecond)
+
+	_, err = registry.AcquireSlot(ctx, "echo")
+	assert.ErrorIs(t, err, ErrShuttingDown)
+	_, err = registry.GetOrLoadServer(ctx, "echo")
+	assert.ErrorIs(t, err, ErrShuttingDown)
+
+	select {
+	case <-drained:
+		t.Fatal("Drain returned with a call in flight")
+	case <-time.After(2 * drainPollInterval):
+	}
+	release()
+	assert.ErrorIs(t, <-queued, ErrShuttingDown)
+	assert.Zero(t, <-drained)
+}
+
+// TestDrainGivesUpWhenContextDone verifies that Drain reports the calls still
+// in flight once ctx is done.
+func TestDrainGivesUpWhenContextDone(t *testing.T) {
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"echo": {}},
+		map[string]*server.MCPServer{"echo": newEchoServer()},
+		nil,
+	)
+	defer registry.Close()
+
+	release, err := registry.AcquireSlot(context.Background(), "echo")
+	require.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, 1, registry.Drain(ctx))
+}
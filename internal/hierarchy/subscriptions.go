@@ -0,0 +1,215 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ResourceUpdatedFunc tells a client session that a resource it subscribed
+// to, by its namespaced URI, was updated
+type ResourceUpdatedFunc func(sessionID, uri string)
+
+// subscriptions are the client sessions subscribed to each resource. A
+// server is subscribed to a resource once, for its first subscriber, and
+// unsubscribed once its last one unsubscribes.
+type subscriptions struct {
+	mu       sync.Mutex
+	sessions map[string]map[string]struct{} // Session IDs by namespaced URI
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{sessions: make(map[string]map[string]struct{})}
+}
+
+// add subscribes a session to a resource, reporting whether it is the
+// resource's first subscriber
+func (s *subscriptions) add(uri, sessionID string) (first bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions, exists := s.sessions[uri]
+	if !exists {
+		sessions = make(map[string]struct{})
+		s.sessions[uri] = sessions
+	}
+	sessions[sessionID] = struct{}{}
+	return !exists
+}
+
+// remove unsubscribes a session from a resource, reporting whether it was
+// the resource's last subscriber
+func (s *subscriptions) remove(uri, sessionID string) (last bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions, exists := s.sessions[uri]
+	if !exists {
+		return false
+	}
+	if _, subscribed := sessions[sessionID]; !subscribed {
+		return false
+	}
+	delete(sessions, sessionID)
+	if len(sessions) > 0 {
+		return false
+	}
+	delete(s.sessions, uri)
+	return true
+}
+
+// subscribers returns the sessions subscribed to a resource
+func (s *subscriptions) subscribers(uri string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessionIDs := make([]string, 0, len(s.sessions[uri]))
+	for sessionID := range s.sessions[uri] {
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	return sessionIDs
+}
+
+// of returns the resources a session is subscribed to
+func (s *subscriptions) of(sessionID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var uris []string
+	for uri, sessions := range s.sessions {
+		if _, subscribed := sessions[sessionID]; subscribed {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
+
+// subscribed reports whether any session is subscribed to a resource of
+// the given server
+func (s *subscriptions) subscribed(serverName string) bool {
+	prefix := ResourceURI(serverName, "")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for uri := range s.sessions {
+		if strings.HasPrefix(uri, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// drop forgets the subscriptions to the resources of a server, returning
+// them by URI
+func (s *subscriptions) drop(serverName string) map[string][]string {
+	prefix := ResourceURI(serverName, "")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dropped := make(map[string][]string)
+	for uri, sessions := range s.sessions {
+		if !strings.HasPrefix(uri, prefix) {
+			continue
+		}
+		for sessionID := range sessions {
+			dropped[uri] = append(dropped[uri], sessionID)
+		}
+		delete(s.sessions, uri)
+	}
+	return dropped
+}
+
+// Subscribe subscribes a client session to a resource by its namespaced URI,
+// starting its server if needed, so that the session hears when it is
+// updated. Servers without resource subscriptions refuse.
+func (r *ServerRegistry) Subscribe(ctx context.Context, sessionID, uri string) error {
+	serverName, downstreamURI, err := ParseResourceURI(uri)
+	if err != nil {
+		return err
+	}
+	mcpClient, err := r.GetOrLoadServer(ctx, serverName)
+	if err != nil {
+		return err
+	}
+	if caps := mcpClient.GetClient().GetServerCapabilities().Resources; caps == nil || !caps.Subscribe {
+		return fmt.Errorf("server %s does not support resource subscriptions", serverName)
+	}
+	if !r.subscriptions.add(uri, sessionID) {
+		return nil
+	}
+	request := mcp.SubscribeRequest{}
+	request.Params.URI = downstreamURI
+	if err := mcpClient.GetClient().Subscribe(ctx, request); err != nil {
+		r.subscriptions.remove(uri, sessionID)
+		return fmt.Errorf("failed to subscribe to resource %s on server %s: %w", downstreamURI, serverName, err)
+	}
+	return nil
+}
+
+// Unsubscribe unsubscribes a client session from a resource by its
+// namespaced URI. The server is unsubscribed once no session is subscribed,
+// if it is still running.
+func (r *ServerRegistry) Unsubscribe(ctx context.Context, sessionID, uri string) error {
+	serverName, downstreamURI, err := ParseResourceURI(uri)
+	if err != nil {
+		return err
+	}
+	if !r.subscriptions.remove(uri, sessionID) {
+		return nil
+	}
+	state, running := r.lookupQuiet(serverName)
+	if !running {
+		return nil
+	}
+	request := mcp.UnsubscribeRequest{}
+	request.Params.URI = downstreamURI
+	if err := state.client.GetClient().Unsubscribe(ctx, request); err != nil {
+		return fmt.Errorf("failed to unsubscribe from resource %s on server %s: %w", downstreamURI, serverName, err)
+	}
+	return nil
+}
+
+// UnsubscribeSession unsubscribes a client session that went away from every
+// resource it subscribed to
+func (r *ServerRegistry) UnsubscribeSession(ctx context.Context, sessionID string) {
+	for _, uri := range r.subscriptions.of(sessionID) {
+		if err := r.Unsubscribe(ctx, sessionID, uri); err != nil {
+			slog.WarnContext(ctx, "Failed to unsubscribe from resource", "uri", uri, "error", err)
+		}
+	}
+}
+
+// OnResourceUpdated registers fn to be called whenever a resource a session
+// subscribed to is updated. fn runs in its own goroutine.
+func (r *ServerRegistry) OnResourceUpdated(fn ResourceUpdatedFunc) {
+	r.onResourceUpdated.Store(&fn)
+}
+
+// resourceUpdated relays a server's notification that a resource was updated
+// to the sessions subscribed to it
+func (r *ServerRegistry) resourceUpdated(serverName string, notification mcp.JSONRPCNotification) {
+	downstreamURI, _ := notification.Params.AdditionalFields["uri"].(string)
+	if downstreamURI == "" {
+		return
+	}
+	uri := ResourceURI(serverName, downstreamURI)
+	for _, sessionID := range r.subscriptions.subscribers(uri) {
+		r.notifyUpdated(sessionID, uri)
+	}
+}
+
+// dropSubscriptions forgets the subscriptions to the resources of a server
+// that stopped, and with them its own. Their sessions are told the resources
+// were updated, to read them again, which starts the server, and subscribe
+// again if they still want to hear about them.
+func (r *ServerRegistry) dropSubscriptions(serverName string) {
+	for uri, sessionIDs := range r.subscriptions.drop(serverName) {
+		for _, sessionID := range sessionIDs {
+			r.notifyUpdated(sessionID, uri)
+		}
+	}
+}
+
+func (r *ServerRegistry) notifyUpdated(sessionID, uri string) {
+	if fn := r.onResourceUpdated.Load(); fn != nil {
+		go (*fn)(sessionID, uri)
+	}
+}
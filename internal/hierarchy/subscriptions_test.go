@@ -0,0 +1,93 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestSubscriptionsCountSubscribers verifies that a resource's first
+// subscriber and its last unsubscribing are told apart, and that dropping a
+// server's subscriptions leaves other servers'.
+func TestSubscriptionsCountSubscribers(t *testing.T) {
+	s := newSubscriptions()
+	notes := ResourceURI("notes", "file:///a.md")
+	assert.True(t, s.add(notes, "one"))
+	assert.False(t, s.add(notes, "two"))
+	assert.False(t, s.add(notes, "two"))
+	assert.ElementsMatch(t, []string{"one", "two"}, s.subscribers(notes))
+	assert.Equal(t, []string{notes}, s.of("one"))
+
+	assert.False(t, s.remove(notes, "one"))
+	assert.False(t, s.remove(notes, "one"))
+	assert.True(t, s.remove(notes, "two"))
+	assert.Empty(t, s.subscribers(notes))
+
+	s.add(notes, "one")
+	s.add(ResourceURI("notebook", "file:///b.md"), "one")
+	assert.True(t, s.subscribed("notes"))
+	assert.Equal(t, map[string][]string{notes: {"one"}}, s.drop("notes"))
+	assert.False(t, s.subscribed("notes"))
+	assert.True(t, s.subscribed("notebook"))
+}
+
+// TestResourceUpdatesReachSubscribers verifies that a server's resource
+// updates are relayed to the sessions subscribed to the resource, and that
+// they are told once more when the server stops and the subscription ends.
+func TestResourceUpdatesReachSubscribers(t *testing.T) {
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{"notes": {}})
+	defer registry.Close()
+	updates := make(chan [2]string, 10)
+	registry.OnResourceUpdated(func(sessionID, uri string) {
+		updates <- [2]string{sessionID, uri}
+	})
+	uri := ResourceURI("notes", "file:///a.md")
+	registry.subscriptions.add(uri, "one")
+
+	notification := mcp.JSONRPCNotification{}
+	notification.Method = mcp.MethodNotificationResourceUpdated
+	notification.Params.AdditionalFields = map[string]any{"uri": "file:///a.md"}
+	registry.resourceUpdated("notes", notification)
+	notification.Params.AdditionalFields = map[string]any{"uri": "file:///b.md"}
+	registry.resourceUpdated("notes", notification)
+
+	select {
+	case update := <-updates:
+		assert.Equal(t, [2]string{"one", uri}, update)
+	case <-time.After(time.Second):
+		t.Fatal("update not relayed")
+	}
+
+	registry.publish(context.Background(), Event{Type: EventServerStopped, Server: "notes", Reason: ReasonIdle})
+	select {
+	case update := <-updates:
+		assert.Equal(t, [2]string{"one", uri}, update)
+	case <-time.After(time.Second):
+		t.Fatal("stop not relayed")
+	}
+	assert.False(t, registry.subscriptions.subscribed("notes"))
+	assert.Empty(t, updates)
+}
+
+// TestSubscribeRequiresCapability verifies that servers that do not offer
+// resource subscriptions refuse them, and that no subscription is kept.
+func TestSubscribeRequiresCapability(t *testing.T) {
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"echo": {}},
+		map[string]*server.MCPServer{"echo": newEchoServer()},
+		nil,
+	)
+	defer registry.Close()
+
+	err := registry.Subscribe(context.Background(), "one", ResourceURI("echo", "file:///a.md"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not support resource subscriptions")
+	assert.False(t, registry.subscriptions.subscribed("echo"))
+	assert.NoError(t, registry.Unsubscribe(context.Background(), "one", ResourceURI("echo", "file:///a.md")))
+}
@@ -0,0 +1,279 @@
+package hierarchy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrRestartLimit is returned for a server that crashed more than maxRestarts
+// times in a row and is no longer restarted.
+var ErrRestartLimit = errors.New("MCP server exceeded its restart limit")
+
+// ErrServerDisabled is returned for a server taken out of service with
+// DisableServer
+var ErrServerDisabled = errors.New("MCP server disabled")
+
+// DefaultMaxRestarts is how many consecutive crashes a server may have before
+// lazy-mcp stops restarting it, when maxRestarts is not configured.
+const DefaultMaxRestarts = 5
+
+const (
+	restartBaseDelay = time.Second
+	restartMaxDelay  = 30 * time.Second
+
+	// crashResetAfter is how long a server must stay connected for its next
+	// crash to count as the first one again
+	crashResetAfter = time.Minute
+)
+
+// crashRecord tracks consecutive unexpected exits of a server
+type crashRecord struct {
+	count       int
+	nextRestart time.Time
+	lastErr     error
+}
+
+// MaxRestarts returns how many consecutive crashes the given server may have
+// before it is left stopped. Zero disables automatic restarts.
+func (r *ServerRegistry) MaxRestarts(serverName string) int {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil {
+		return DefaultMaxRestarts
+	}
+	return max(cfg.Options.MaxRestarts.OrElse(DefaultMaxRestarts), 0)
+}
+
+// lookupLive is lookup for callers about to use the client: a server whose
+// connection was lost is handled as a crash and reported as not connected.
+func (r *ServerRegistry) lookupLive(serverName string) (*serverState, bool) {
+	state, exists := r.lookup(serverName)
+	if exists && state.client.Disconnected() {
+		r.handleExit(serverName, state)
+		return nil, false
+	}
+	return state, exists
+}
+
+// supervise waits for the server's connection to be lost: its process exiting
+// or its event stream ending. Disconnects after ctx is done are deliberate
+// (idle shutdown, Close) and are not treated as crashes.
+func (r *ServerRegistry) supervise(ctx context.Context, serverName string, state *serverState) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-state.client.Done():
+	}
+	if ctx.Err() == nil {
+		r.handleExit(serverName, state)
+	}
+}
+
+// handleExit removes a server whose connection was lost unexpectedly and
+// schedules its restart (a reconnect, for remote servers). A session the
+// server expired is no crash: it is reopened at once, without backoff or
+// counting against maxRestarts. It is a no-op if the state has already been
+// replaced or removed.
+func (r *ServerRegistry) handleExit(serverName string, state *serverState) {
+	r.mu.Lock()
+	if current, exists := r.servers[serverName]; !exists || current != state {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.servers, serverName)
+	state.stop()
+	r.publish(context.Background(), Event{Type: EventServerStopped, Server: serverName, Reason: ReasonCrashed, Err: state.client.Err(), Duration: time.Since(state.started)})
+	restart := true
+	if err := state.client.Err(); errors.Is(err, transport.ErrSessionTerminated) {
+		logging.ForServer(serverName).Info("Reopening MCP session the server expired")
+	} else {
+		restart = r.recordCrashLocked(serverName, err, time.Since(state.started), state.client.Remote())
+	}
+	r.mu.Unlock()
+
+	_ = state.client.Close()
+	if restart {
+		go r.restart(serverName)
+	}
+}
+
+// recordCrashLocked counts a failure of the given server and sets when it may
+// next be started. A remote server that dropped its connection is reconnected
+// at once the first time, since that is usually a network blip, so the call
+// that found it dropped need not wait. It returns false once the restart
+// limit is exceeded. The caller must hold r.mu.
+func (r *ServerRegistry) recordCrashLocked(serverName string, err error, uptime time.Duration, remote bool) bool {
+	record := r.crashes[serverName]
+	if record == nil || uptime >= crashResetAfter {
+		record = &crashRecord{}
+		r.crashes[serverName] = record
+	}
+	record.count++
+	record.lastErr = err
+
+	maxRestarts := r.MaxRestarts(serverName)
+	if record.count > maxRestarts {
+		r.publish(context.Background(), Event{Type: EventServerUnhealthy, Server: serverName, Reason: ReasonRestartLimit, Err: err})
+		return false
+	}
+
+	if remote && record.count == 1 {
+		record.nextRestart = time.Now()
+		logging.ForServer(serverName).Warn("Reconnecting MCP client", "error", err)
+		return true
+	}
+	delay := r.restartDelay(record.count)
+	record.nextRestart = time.Now().Add(delay)
+	logging.ForServer(serverName).Warn("Restarting MCP client",
+		"error", err, "delay", delay.Round(time.Millisecond), "attempt", record.count, "maxRestarts", maxRestarts)
+	return true
+}
+
+// restartDelay returns the backoff before the given restart attempt: the base
+// delay doubled per attempt up to restartMaxDelay, with jitter so that servers
+// which crashed together do not restart in lockstep.
+func (r *ServerRegistry) restartDelay(attempt int) time.Duration {
+	delay := r.restartBaseDelay
+	for i := 1; i < attempt && delay < restartMaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, restartMaxDelay)
+	// Equal jitter: somewhere between half and all of the delay
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// waitForRestart blocks until the given server may be started again after a
+// crash, or fails if it has exceeded its restart limit or ctx is done first.
+func (r *ServerRegistry) waitForRestart(ctx context.Context, serverName string) error {
+	r.mu.RLock()
+	record, crashed := r.crashes[serverName]
+	var count int
+	var nextRestart time.Time
+	var lastErr error
+	if crashed {
+		count, nextRestart, lastErr = record.count, record.nextRestart, record.lastErr
+	}
+	r.mu.RUnlock()
+
+	if !crashed {
+		return nil
+	}
+	if count > r.MaxRestarts(serverName) {
+		return fmt.Errorf("%w: server %s failed %d times, last error: %v", ErrRestartLimit, serverName, count, lastErr)
+	}
+
+	wait := time.Until(nextRestart)
+	if wait <= 0 {
+		return nil
+	}
+	_, span := tracer.Start(ctx, "wait_for_restart", trace.WithAttributes(attrServer.String(serverName)))
+	defer span.End()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("server %s is restarting after a crash: %w", serverName, ctx.Err())
+	}
+}
+
+// restart relaunches a crashed server once its backoff expires, retrying with
+// further backoff while startup fails, until the restart limit is reached or
+// the registry is closed.
+func (r *ServerRegistry) restart(serverName string) {
+	for {
+		_, err := r.GetOrLoadServer(r.ctx, serverName)
+		if err == nil {
+			logging.ForServer(serverName).Info("Restarted MCP client")
+			return
+		}
+		if errors.Is(err, ErrRestartLimit) || errors.Is(err, ErrShuttingDown) || r.ctx.Err() != nil {
+			return
+		}
+
+		r.mu.Lock()
+		retry := r.recordCrashLocked(serverName, err, 0, false)
+		r.mu.Unlock()
+		if !retry {
+			return
+		}
+	}
+}
+
+// RestartServer stops the given server, if it is running, and starts it
+// again. Its crashes and open circuit breaker are forgotten, so this also
+// recovers a server that exceeded its restart limit.
+func (r *ServerRegistry) RestartServer(ctx context.Context, serverName string) error {
+	if _, exists := r.serverConfig(serverName); !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownServer, serverName)
+	}
+	// Wait out a startup in progress, which would otherwise store its client
+	// after the old one is stopped
+	loadMu := r.getLoadMutex(serverName)
+	loadMu.Lock()
+	r.mu.Lock()
+	state, running := r.servers[serverName]
+	delete(r.servers, serverName)
+	delete(r.crashes, serverName)
+	delete(r.circuits, serverName)
+	r.mu.Unlock()
+	loadMu.Unlock()
+	r.results.forget(serverName)
+
+	if running {
+		r.publish(ctx, Event{Type: EventServerStopped, Server: serverName, Reason: ReasonRestart, Duration: time.Since(state.started)})
+		state.stop()
+		_ = state.client.Close()
+	}
+	_, err := r.GetServerTools(ctx, serverName)
+	return err
+}
+
+// DisableServer stops the given server if it is running, and fails its calls
+// with ErrServerDisabled instead of starting it until EnableServer is called
+func (r *ServerRegistry) DisableServer(ctx context.Context, serverName string) error {
+	if _, exists := r.serverConfig(serverName); !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownServer, serverName)
+	}
+	loadMu := r.getLoadMutex(serverName)
+	loadMu.Lock()
+	r.mu.Lock()
+	r.disabled[serverName] = true
+	state, running := r.servers[serverName]
+	delete(r.servers, serverName)
+	delete(r.crashes, serverName)
+	r.mu.Unlock()
+	loadMu.Unlock()
+
+	if running {
+		r.publish(ctx, Event{Type: EventServerStopped, Server: serverName, Reason: ReasonDisabled, Duration: time.Since(state.started)})
+		state.stop()
+		_ = state.client.Close()
+	}
+	return nil
+}
+
+// EnableServer lets a server disabled with DisableServer start again on its
+// next call
+func (r *ServerRegistry) EnableServer(serverName string) error {
+	if _, exists := r.serverConfig(serverName); !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownServer, serverName)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.disabled, serverName)
+	return nil
+}
+
+func (r *ServerRegistry) isDisabled(serverName string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.disabled[serverName]
+}
@@ -0,0 +1,283 @@
+package hierarchy
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/client"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// stdioServerEnv makes the test binary act as a stdio MCP server, so tests can
+// supervise a real child process.
+const stdioServerEnv = "LAZY_MCP_TEST_STDIO_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(stdioServerEnv) == "1" {
+		mcpServer := newEchoServer()
+		mcpServer.AddTool(mcp.NewTool("crash"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			os.Exit(3)
+			return nil, nil
+		})
+		if err := server.ServeStdio(mcpServer); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// newStdioTestRegistry creates a registry whose "crashy" server is a child
+// process that exits when its "crash" tool is called.
+func newStdioTestRegistry(t *testing.T, options *config.OptionsV2, launches *int32) *ServerRegistry {
+	executable, err := os.Executable()
+	require.NoError(t, err)
+
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"crashy": {
+			Command: executable,
+			Env:     map[string]string{stdioServerEnv: "1"},
+			Options: options,
+		},
+	})
+	registry.restartBaseDelay = 10 * time.Millisecond
+	newClient := registry.newClient
+	registry.newClient = func(name string, cfg *config.MCPClientConfigV2, options ...client.Option) (*client.Client, error) {
+		atomic.AddInt32(launches, 1)
+		return newClient(name, cfg, options...)
+	}
+	return registry
+}
+
+func callCrash(t *testing.T, registry *ServerRegistry) error {
+	mcpClient, err := registry.GetOrLoadServer(context.Background(), "crashy")
+	require.NoError(t, err)
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "crash"
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = mcpClient.CallTool(ctx, request)
+	return err
+}
+
+// TestSupervisorRestartsCrashedServer verifies that a call in flight when the
+// process dies fails fast with ErrProcessExited, and that the server is then
+// restarted in the background.
+func TestSupervisorRestartsCrashedServer(t *testing.T) {
+	var launches int32
+	registry := newStdioTestRegistry(t, &config.OptionsV2{}, &launches)
+	defer registry.Close()
+
+	err := callCrash(t, registry)
+	assert.ErrorIs(t, err, client.ErrProcessExited)
+
+	assert.Eventually(t, func() bool {
+		_, running := registry.lookupQuiet("crashy")
+		return running && atomic.LoadInt32(&launches) == 2
+	}, 5*time.Second, 10*time.Millisecond, "crashed server should be restarted")
+
+	statuses := registry.ServerStatuses()
+	require.Len(t, statuses, 1)
+	assert.Equal(t, ServerStateRunning, statuses[0].State)
+	assert.Equal(t, 1, statuses[0].Restarts)
+}
+
+// TestSupervisorStopsAtRestartLimit verifies that a server which keeps
+// crashing is left stopped once it exceeds maxRestarts.
+func TestSupervisorStopsAtRestartLimit(t *testing.T) {
+	var launches int32
+	registry := newStdioTestRegistry(t, &config.OptionsV2{MaxRestarts: optional.NewField(1)}, &launches)
+	defer registry.Close()
+
+	assert.ErrorIs(t, callCrash(t, registry), client.ErrProcessExited)
+	assert.ErrorIs(t, callCrash(t, registry), client.ErrProcessExited)
+
+	assert.Eventually(t, func() bool {
+		return !registry.IsHealthy("crashy")
+	}, 5*time.Second, 10*time.Millisecond, "server should be marked failed")
+
+	_, err := registry.GetOrLoadServer(context.Background(), "crashy")
+	assert.ErrorIs(t, err, ErrRestartLimit)
+	assert.Equal(t, ServerStateFailed, registry.ServerStatuses()[0].State)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&launches))
+}
+
+// TestSupervisorReconnectsSSEServer verifies that when the event stream of a
+// `type: sse` server drops, the client is marked disconnected and reconnected
+// at once, without waiting out a restart backoff.
+func TestSupervisorReconnectsSSEServer(t *testing.T) {
+	sseServer := server.NewTestServer(newEchoServer())
+	defer sseServer.Close()
+
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"remote": {Type: config.MCPClientTypeSSE, URL: sseServer.URL + "/sse", Options: &config.OptionsV2{}},
+	})
+	registry.restartBaseDelay = time.Hour
+	defer registry.Close()
+
+	// The stream must outlive the request that started the server
+	loadCtx, cancelLoad := context.WithCancel(context.Background())
+	first, err := registry.GetOrLoadServer(loadCtx, "remote")
+	require.NoError(t, err)
+	cancelLoad()
+	echo(t, first)
+	assert.False(t, first.Disconnected())
+
+	sseServer.CloseClientConnections()
+	select {
+	case <-first.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("dropped SSE stream was not detected")
+	}
+	assert.ErrorIs(t, first.Err(), client.ErrConnectionLost)
+
+	assert.Eventually(t, func() bool {
+		state, running := registry.lookupQuiet("remote")
+		return running && state.client != first
+	}, 5*time.Second, 10*time.Millisecond, "SSE server should be reconnected")
+
+	second, err := registry.GetOrLoadServer(context.Background(), "remote")
+	require.NoError(t, err)
+	echo(t, second)
+}
+
+// TestSupervisorResumesExpiredSession verifies that when a `type: http` server
+// no longer recognises our session, the call reports a lost connection and a
+// new session is established at once, however often it happens, as expired
+// sessions are not counted as crashes.
+func TestSupervisorResumesExpiredSession(t *testing.T) {
+	httpServer := server.NewTestStreamableHTTPServer(newEchoServer(), server.WithStateful(true))
+	defer httpServer.Close()
+
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"remote": {Type: config.MCPClientTypeHTTP, URL: httpServer.URL + "/mcp", Options: &config.OptionsV2{MaxRestarts: optional.NewField(1)}},
+	})
+	registry.restartBaseDelay = time.Hour // Any backoff outlasts the test
+	defer registry.Close()
+
+	current, err := registry.GetOrLoadServer(context.Background(), "remote")
+	require.NoError(t, err)
+	echo(t, current)
+
+	for range 3 {
+		sessionID := current.GetClient().GetSessionId()
+		require.NotEmpty(t, sessionID, "server should assign a session")
+
+		// Expire the session on the server side
+		req, err := http.NewRequest(http.MethodDelete, httpServer.URL+"/mcp", nil)
+		require.NoError(t, err)
+		req.Header.Set(server.HeaderKeySessionID, sessionID)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+
+		request := mcp.CallToolRequest{}
+		request.Params.Name = "echo"
+		_, err = current.CallTool(context.Background(), request)
+		assert.ErrorIs(t, err, client.ErrConnectionLost)
+
+		previous := current
+		assert.Eventually(t, func() bool {
+			state, running := registry.lookupQuiet("remote")
+			return running && state.client != previous
+		}, 5*time.Second, 10*time.Millisecond, "a new session should be established")
+
+		current, err = registry.GetOrLoadServer(context.Background(), "remote")
+		require.NoError(t, err)
+		assert.NotEqual(t, sessionID, current.GetClient().GetSessionId())
+		echo(t, current)
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	assert.NotContains(t, registry.crashes, "remote", "expired sessions are not crashes")
+}
+
+// echo calls the echo tool and checks that it answers
+func echo(t *testing.T, c *client.Client) {
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "echo"
+	request.Params.Arguments = map[string]any{"message": "hi"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := c.CallTool(ctx, request)
+	require.NoError(t, err)
+	assert.Equal(t, "hi", result.Content[0].(mcp.TextContent).Text)
+}
+
+// TestRestartDelay verifies that the backoff doubles per attempt, is capped,
+// and is jittered within [delay/2, delay].
+func TestRestartDelay(t *testing.T) {
+	registry := NewServerRegistry(nil)
+
+	for attempt, want := range map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		4:  8 * time.Second,
+		10: restartMaxDelay,
+	} {
+		delay := registry.restartDelay(attempt)
+		assert.GreaterOrEqual(t, delay, want/2, "attempt %d", attempt)
+		assert.LessOrEqual(t, delay, want, "attempt %d", attempt)
+	}
+}
+
+// TestRestartServerRecoversFailedServer verifies that a restart on request
+// starts a server that exceeded its restart limit again.
+func TestRestartServerRecoversFailedServer(t *testing.T) {
+	var launches int32
+	registry := newStdioTestRegistry(t, &config.OptionsV2{MaxRestarts: optional.NewField(0)}, &launches)
+	defer registry.Close()
+
+	assert.ErrorIs(t, callCrash(t, registry), client.ErrProcessExited)
+	assert.Eventually(t, func() bool {
+		return !registry.IsHealthy("crashy")
+	}, 5*time.Second, 10*time.Millisecond, "server should be marked failed")
+
+	require.NoError(t, registry.RestartServer(context.Background(), "crashy"))
+	status, err := registry.ServerStatus("crashy")
+	require.NoError(t, err)
+	assert.Equal(t, ServerStateRunning, status.State)
+	assert.Zero(t, status.Restarts)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&launches))
+
+	assert.ErrorIs(t, registry.RestartServer(context.Background(), "missing"), ErrUnknownServer)
+}
+
+// TestDisableServerStopsAndRefusesCalls verifies that a disabled server is
+// stopped and not started again until it is enabled.
+func TestDisableServerStopsAndRefusesCalls(t *testing.T) {
+	var launches int32
+	registry := newStdioTestRegistry(t, &config.OptionsV2{}, &launches)
+	defer registry.Close()
+
+	ctx := context.Background()
+	_, err := registry.GetOrLoadServer(ctx, "crashy")
+	require.NoError(t, err)
+
+	require.NoError(t, registry.DisableServer(ctx, "crashy"))
+	status, err := registry.ServerStatus("crashy")
+	require.NoError(t, err)
+	assert.Equal(t, ServerStateDisabled, status.State)
+	_, err = registry.GetOrLoadServer(ctx, "crashy")
+	assert.ErrorIs(t, err, ErrServerDisabled)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&launches))
+
+	require.NoError(t, registry.EnableServer("crashy"))
+	_, err = registry.GetOrLoadServer(ctx, "crashy")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&launches))
+
+	assert.ErrorIs(t, registry.DisableServer(ctx, "missing"), ErrUnknownServer)
+	assert.ErrorIs(t, registry.EnableServer("missing"), ErrUnknownServer)
+}
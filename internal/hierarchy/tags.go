@@ -0,0 +1,50 @@
+package hierarchy
+
+import "slices"
+
+// toolTags returns the tags of the listed tool, per its server's tags and
+// toolTags
+func (h *Hierarchy) toolTags(tool toolMatch) []string {
+	if h.overrides == nil {
+		return nil
+	}
+	original := tool.def.MapsTo
+	if original == "" {
+		original = tool.original
+	}
+	return h.overrides.ToolTags(tool.def.Server, original)
+}
+
+// HandleListToolsByTag handles the list_tools_by_tag meta-tool. With a tag,
+// it returns the tools that have it, by path, with their inputSchema ready
+// for execute_tool; without one, the tags that tools have, with how many
+// have each.
+func (h *Hierarchy) HandleListToolsByTag(tag string) map[string]interface{} {
+	tools := h.searchableTools()
+	if tag == "" {
+		counts := make(map[string]int)
+		for _, tool := range tools {
+			for _, t := range h.toolTags(tool) {
+				counts[t]++
+			}
+		}
+		return map[string]interface{}{"tags": counts}
+	}
+
+	tagged := make([]map[string]interface{}, 0)
+	for _, tool := range tools {
+		tags := h.toolTags(tool)
+		if !slices.Contains(tags, tag) {
+			continue
+		}
+		info := tool.tool.info()
+		info["name"] = tool.tool.name
+		info["server"] = tool.def.Server
+		info["tags"] = tags
+		if len(tool.tool.schema) > 0 {
+			info["inputSchema"] = tool.tool.schema
+		}
+		tagged = append(tagged, info)
+	}
+	return map[string]interface{}{"tag": tag, "tools": tagged}
+}
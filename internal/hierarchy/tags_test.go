@@ -0,0 +1,49 @@
+package hierarchy
+
+import (
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestToolTags verifies that tools are tagged by their servers' tags and
+// toolTags, that list_tools_by_tag lists them by tag, and that exposeTags
+// hides the tools without any of its tags.
+func TestToolTags(t *testing.T) {
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"":       {},
+		"github": {Tools: map[string]*ToolDefinition{"search_issues": {Server: "github"}, "delete_repo": {Server: "github"}}},
+		"notes":  {Tools: map[string]*ToolDefinition{"read_note": {Server: "notes"}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"github": {Tags: []string{"work"}, Options: &config.OptionsV2{
+				ToolTags: map[string][]string{"search_issues": {"readonly", "work"}},
+			}},
+			"notes": {Tags: []string{"personal", "readonly"}},
+		},
+		map[string]*server.MCPServer{},
+		nil,
+	)
+	defer registry.Close()
+	h.SetToolFilter(registry.ToolIncluded)
+	h.SetToolOverrides(registry)
+
+	assert.Equal(t, map[string]interface{}{"tags": map[string]int{"personal": 1, "readonly": 2, "work": 2}}, h.HandleListToolsByTag(""))
+
+	response := h.HandleListToolsByTag("readonly")
+	tools := response["tools"].([]map[string]interface{})
+	require.Len(t, tools, 2)
+	assert.Equal(t, "github.search_issues", tools[0]["tool_path"])
+	assert.Equal(t, []string{"readonly", "work"}, tools[0]["tags"])
+	assert.Equal(t, "notes.read_note", tools[1]["tool_path"])
+
+	registry.SetExposeTags([]string{"readonly"})
+	listing, err := h.HandleGetToolsInCategory("github")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"search_issues"}, keys(listing["tools"].(map[string]interface{})), "delete_repo is not tagged readonly")
+	assert.Equal(t, map[string]interface{}{"tags": map[string]int{"personal": 1, "readonly": 2, "work": 1}}, h.HandleListToolsByTag(""))
+}
@@ -0,0 +1,151 @@
+package hierarchy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// DefaultCallTimeout bounds a tool call, including waiting for the server's
+// slot, when neither the tool nor its server configures a timeout.
+const DefaultCallTimeout = 30 * time.Second
+
+// DefaultDeadlineMargin is how long before the caller's deadline a tool call
+// is given up when deadlineMargin is not configured, leaving the proxy time to
+// answer before the caller stops waiting
+const DefaultDeadlineMargin = 100 * time.Millisecond
+
+// MetaTimeout is the _meta field of a tools/call request in which a client may
+// send how long it will wait for the result, as a duration such as "10s". Calls
+// forwarded to servers carry what is left of it.
+const MetaTimeout = "timeout"
+
+// ErrCallTimeout is matched by the TimeoutError of a call that ran out of time
+var ErrCallTimeout = errors.New("tool call timed out")
+
+// TimeoutError is returned for a tool call that did not complete within its
+// timeout. The call to the server is cancelled.
+type TimeoutError struct {
+	Server  string
+	Tool    string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("tool %s on server %s timed out after %s", e.Tool, e.Server, e.Timeout)
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrCallTimeout
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// CallTimeout returns how long a call to the given tool of the given server
+// may take: the tool's timeout if set, else the server's callTimeout.
+func (r *ServerRegistry) CallTimeout(serverName string, toolDef *ToolDefinition) time.Duration {
+	if toolDef != nil && toolDef.Timeout > 0 {
+		return toolDef.Timeout.Std()
+	}
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil {
+		return DefaultCallTimeout
+	}
+	if timeout := cfg.Options.CallTimeout.OrElse(0).Std(); timeout > 0 {
+		return timeout
+	}
+	return DefaultCallTimeout
+}
+
+// DeadlineMargin returns how long before the caller's deadline a call to the
+// given server is given up
+func (r *ServerRegistry) DeadlineMargin(serverName string) time.Duration {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil {
+		return DefaultDeadlineMargin
+	}
+	return max(cfg.Options.DeadlineMargin.OrElse(config.Duration(DefaultDeadlineMargin)).Std(), 0)
+}
+
+// callBudget returns how long a call to the given tool may take: its
+// CallTimeout, cut short to end DeadlineMargin before ctx's deadline if that
+// comes first, in which case budgeted is set
+func (r *ServerRegistry) callBudget(ctx context.Context, serverName string, toolDef *ToolDefinition) (timeout time.Duration, budgeted bool) {
+	timeout = r.CallTimeout(serverName, toolDef)
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return timeout, false
+	}
+	if remaining := time.Until(deadline) - r.DeadlineMargin(serverName); remaining < timeout {
+		return max(remaining, 0), true
+	}
+	return timeout, false
+}
+
+// MetaTimeoutOf returns the MetaTimeout a request's _meta carries, or zero if
+// it carries none
+func MetaTimeoutOf(meta *mcp.Meta) (time.Duration, error) {
+	if meta == nil {
+		return 0, nil
+	}
+	raw, exists := meta.AdditionalFields[MetaTimeout]
+	if !exists {
+		return 0, nil
+	}
+	value, ok := raw.(string)
+	if !ok {
+		return 0, fmt.Errorf("_meta.%s must be a duration string such as \"10s\", got %v", MetaTimeout, raw)
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("_meta.%s must be a positive duration such as \"10s\", got %q", MetaTimeout, value)
+	}
+	return timeout, nil
+}
+
+// withMetaTimeout returns a copy of meta carrying timeout as its MetaTimeout
+func withMetaTimeout(meta *mcp.Meta, timeout time.Duration) *mcp.Meta {
+	return withMeta(meta, MetaTimeout, max(timeout, time.Millisecond).Round(time.Millisecond).String())
+}
+
+// UnhealthyAfterTimeouts returns how many calls to the given server must time
+// out in a row for it to be marked unhealthy. Zero means never.
+func (r *ServerRegistry) UnhealthyAfterTimeouts(serverName string) int {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil {
+		return 0
+	}
+	return max(cfg.Options.UnhealthyAfterTimeouts.OrElse(0), 0)
+}
+
+// recordCallTimeout counts consecutive timed out calls to the given server and
+// marks it unhealthy once there are too many, until a health check passes. It
+// is passed nil for a call that completed in time, which resets the count.
+func (r *ServerRegistry) recordCallTimeout(serverName string, timeoutErr *TimeoutError) {
+	limit := r.UnhealthyAfterTimeouts(serverName)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, exists := r.servers[serverName]
+	if !exists {
+		return
+	}
+	if timeoutErr == nil {
+		state.health.consecutiveTimeouts = 0
+		return
+	}
+	state.health.consecutiveTimeouts++
+	if limit == 0 || state.health.consecutiveTimeouts < limit {
+		return
+	}
+	state.health.consecutiveFailures++
+	state.health.lastError = timeoutErr.Error()
+	r.publish(context.Background(), Event{Type: EventServerUnhealthy, Server: serverName, Reason: ReasonTimeouts, Err: timeoutErr})
+}
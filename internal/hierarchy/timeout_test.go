@@ -0,0 +1,128 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestCallTimeoutMarksServerUnhealthy verifies that a call running past its
+// tool's timeout is cancelled with a TimeoutError, and that the server is
+// marked unhealthy after unhealthyAfterTimeouts of them in a row.
+func TestCallTimeoutMarksServerUnhealthy(t *testing.T) {
+	stuck := server.NewMCPServer("stuck", "1.0.0")
+	stuck.AddTool(mcp.NewTool("wait"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"wait": {Server: "stuck", Timeout: config.Duration(50 * time.Millisecond)}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"stuck": {Options: &config.OptionsV2{
+				CallTimeout:            optional.NewField(config.Duration(time.Minute)),
+				UnhealthyAfterTimeouts: optional.NewField(2),
+			}},
+		},
+		map[string]*server.MCPServer{"stuck": stuck},
+		nil,
+	)
+	defer registry.Close()
+
+	for i := 0; i < 2; i++ {
+		assert.True(t, registry.IsHealthy("stuck"))
+		start := time.Now()
+		_, err := h.HandleExecuteTool(context.Background(), registry, "wait", nil)
+		assert.Less(t, time.Since(start), 5*time.Second, "the tool's timeout wins over the server's")
+
+		var timeoutErr *TimeoutError
+		require.ErrorAs(t, err, &timeoutErr)
+		assert.ErrorIs(t, err, ErrCallTimeout)
+		assert.Equal(t, "wait", timeoutErr.Tool)
+		assert.Equal(t, 50*time.Millisecond, timeoutErr.Timeout)
+	}
+	assert.False(t, registry.IsHealthy("stuck"))
+}
+
+// TestCallerDeadlineBoundsCall verifies that a call is given up deadlineMargin
+// before its caller's deadline, without holding it against the server, and
+// that servers are sent what is left of the caller's time.
+func TestCallerDeadlineBoundsCall(t *testing.T) {
+	forwarded := make(chan time.Duration, 1)
+	stuck := server.NewMCPServer("stuck", "1.0.0")
+	stuck.AddTool(mcp.NewTool("wait"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		timeout, err := MetaTimeoutOf(request.Params.Meta)
+		if err == nil {
+			forwarded <- timeout
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"wait": {Server: "stuck"}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"stuck": {Options: &config.OptionsV2{
+				CallTimeout:            optional.NewField(config.Duration(time.Minute)),
+				DeadlineMargin:         optional.NewField(config.Duration(200 * time.Millisecond)),
+				UnhealthyAfterTimeouts: optional.NewField(1),
+			}},
+		},
+		map[string]*server.MCPServer{"stuck": stuck},
+		nil,
+	)
+	defer registry.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := h.HandleExecuteTool(ctx, registry, "wait", nil)
+	assert.NoError(t, ctx.Err(), "the call is answered before the caller's deadline")
+	var timeoutErr *TimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.LessOrEqual(t, timeoutErr.Timeout, 800*time.Millisecond)
+
+	timeout := <-forwarded
+	assert.LessOrEqual(t, timeout, 800*time.Millisecond)
+	assert.Greater(t, timeout, 0*time.Millisecond)
+	assert.True(t, registry.IsHealthy("stuck"), "running out of the caller's time is not the server's fault")
+
+	// A caller about to give up is answered at once
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = h.HandleExecuteTool(ctx, registry, "wait", nil)
+	assert.ErrorIs(t, err, ErrCallTimeout)
+	assert.Empty(t, forwarded)
+}
+
+// TestMetaTimeoutOf verifies the parsing of the timeout clients send in _meta.
+func TestMetaTimeoutOf(t *testing.T) {
+	timeout, err := MetaTimeoutOf(nil)
+	assert.NoError(t, err)
+	assert.Zero(t, timeout)
+
+	timeout, err = MetaTimeoutOf(&mcp.Meta{AdditionalFields: map[string]any{MetaTimeout: "1.5s"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1500*time.Millisecond, timeout)
+
+	for _, invalid := range []any{"soon", "-1s", 10} {
+		_, err = MetaTimeoutOf(&mcp.Meta{AdditionalFields: map[string]any{MetaTimeout: invalid}})
+		assert.Error(t, err, "%v", invalid)
+	}
+
+	meta := withMetaTimeout(&mcp.Meta{ProgressToken: "p"}, 2*time.Second)
+	assert.Equal(t, mcp.ProgressToken("p"), meta.ProgressToken)
+	timeout, err = MetaTimeoutOf(meta)
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Second, timeout)
+}
@@ -0,0 +1,76 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestExecuteToolSpans verifies that a proxied call records the lock wait,
+// the lazy start and the downstream call under one execute_tool span, and
+// that only the first call is marked as a cold start. The calls carry their
+// request IDs.
+func TestExecuteToolSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"echo": {Server: "echo"}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"echo": {Options: &config.OptionsV2{}}},
+		map[string]*server.MCPServer{"echo": newEchoServer()},
+		nil,
+	)
+	defer registry.Close()
+
+	for i := range 2 {
+		ctx := logging.WithRequestID(context.Background(), fmt.Sprintf("req-%d", i))
+		_, err := h.HandleExecuteTool(ctx, registry, "echo", map[string]interface{}{"message": "hi"})
+		require.NoError(t, err)
+	}
+
+	spans := exporter.GetSpans()
+	byName := make(map[string][]tracetest.SpanStub)
+	for _, span := range spans {
+		byName[span.Name] = append(byName[span.Name], span)
+	}
+	require.Len(t, byName["execute_tool"], 2)
+	require.Len(t, byName["start_server"], 1, "only the first call starts the server")
+	require.Len(t, byName["acquire_slot"], 2)
+	require.Len(t, byName["call_tool"], 2)
+
+	first := byName["execute_tool"][0]
+	for _, name := range []string{"start_server", "acquire_slot", "call_tool"} {
+		child := byName[name][0]
+		assert.Equal(t, first.SpanContext.TraceID(), child.SpanContext.TraceID(), name)
+		assert.Equal(t, first.SpanContext.SpanID(), child.Parent.SpanID(), "%s should be a child of execute_tool", name)
+	}
+
+	coldStart := func(span tracetest.SpanStub) bool {
+		for _, attr := range span.Attributes {
+			if attr.Key == attrColdStart {
+				return attr.Value.AsBool()
+			}
+		}
+		return false
+	}
+	assert.True(t, coldStart(first))
+	assert.False(t, coldStart(byName["execute_tool"][1]))
+
+	for i, span := range byName["execute_tool"] {
+		assert.Contains(t, span.Attributes, attrRequestID.String(fmt.Sprintf("req-%d", i)))
+	}
+}
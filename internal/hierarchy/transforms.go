@@ -0,0 +1,109 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/transform"
+)
+
+// serverTransforms are the compiled resultTransforms of one server, by tool
+// name or "*". err is set if any of them is invalid, in which case none is.
+type serverTransforms struct {
+	tools map[string][]transform.Transform
+	err   error
+}
+
+// CheckResultTransforms compiles the resultTransforms of each server,
+// returning the first that is invalid, in server name order. The proxy
+// refuses to start, or reload, with such a config, as a redaction that cannot
+// be applied would leave results unredacted.
+func CheckResultTransforms(serverConfigs map[string]*config.MCPClientConfigV2) error {
+	compiled := compileResultTransforms(serverConfigs)
+	names := make([]string, 0, len(compiled))
+	for name := range compiled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := compiled[name].err; err != nil {
+			return fmt.Errorf("server %s: invalid resultTransforms: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// compileResultTransforms compiles the resultTransforms of each server that
+// has any, so that results are transformed without compiling patterns on
+// every call
+func compileResultTransforms(serverConfigs map[string]*config.MCPClientConfigV2) map[string]*serverTransforms {
+	compiled := make(map[string]*serverTransforms)
+	for name, cfg := range serverConfigs {
+		if cfg == nil || cfg.Options == nil || len(cfg.Options.ResultTransforms) == 0 {
+			continue
+		}
+		transforms := &serverTransforms{tools: make(map[string][]transform.Transform, len(cfg.Options.ResultTransforms))}
+		for tool, cfgs := range cfg.Options.ResultTransforms {
+			for _, cfg := range cfgs {
+				t, err := newTransform(cfg)
+				if err != nil {
+					transforms = &serverTransforms{err: fmt.Errorf("%s: %w", tool, err)}
+					break
+				}
+				transforms.tools[tool] = append(transforms.tools[tool], t)
+			}
+			if transforms.err != nil {
+				break
+			}
+		}
+		compiled[name] = transforms
+	}
+	return compiled
+}
+
+// transformResult slims a result of the given tool by the server's
+// resultTransforms: the tool's own entry, else "*". Transforms that fail,
+// such as extracting from a result that is not JSON, are skipped. If the
+// server's transforms are invalid, which CheckResultTransforms keeps the
+// proxy from starting with, the result is withheld rather than passed on
+// unredacted.
+func (r *ServerRegistry) transformResult(ctx context.Context, serverName, toolName string, result *mcp.CallToolResult) *mcp.CallToolResult {
+	transforms := (*r.transforms.Load())[serverName]
+	if transforms == nil || result == nil {
+		return result
+	}
+	if transforms.err != nil {
+		slog.ErrorContext(ctx, "Withholding tool result, as the server's resultTransforms are invalid", "server", serverName, "error", transforms.err)
+		return mcp.NewToolResultError(fmt.Sprintf("lazy-mcp withheld the result, as the resultTransforms of server %s are invalid: %v", serverName, transforms.err))
+	}
+	toolTransforms, ok := transforms.tools[toolName]
+	if !ok {
+		toolTransforms = transforms.tools["*"]
+	}
+	transformed, errs := transform.Apply(result, toolTransforms)
+	for _, err := range errs {
+		slog.WarnContext(ctx, "Failed to transform tool result", "error", err)
+	}
+	return transformed
+}
+
+// newTransform returns the transform cfg configures
+func newTransform(cfg config.ResultTransformConfig) (transform.Transform, error) {
+	switch {
+	case cfg.Extract != "":
+		return transform.Extract(cfg.Extract)
+	case cfg.Redact != "":
+		return transform.Redact(cfg.Redact, cfg.Replacement)
+	case cfg.MaxLines > 0:
+		return transform.MaxLines(cfg.MaxLines), nil
+	case cfg.HTMLToMarkdown:
+		return transform.HTMLToMarkdown, nil
+	}
+	return func(result *mcp.CallToolResult) (*mcp.CallToolResult, error) {
+		return result, nil
+	}, nil
+}
@@ -0,0 +1,89 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestResultTransforms verifies that a tool's own resultTransforms slim its
+// results in turn, that the "*" entry applies to the server's other tools,
+// and that a transform that fails is skipped.
+func TestResultTransforms(t *testing.T) {
+	tracker := server.NewMCPServer("tracker", "1.0.0")
+	tracker.AddTool(mcp.NewTool("list_issues"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(`{"items": [{"title": "Leaked key sk-123"}, {"title": "Crash"}, {"title": "Docs"}]}`), nil
+	})
+	tracker.AddTool(mcp.NewTool("get_page"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("<h1>Issue</h1><p>Token <b>sk-456</b></p>"), nil
+	})
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"list_issues": {Server: "tracker"}, "get_page": {Server: "tracker"}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"tracker": {Options: &config.OptionsV2{
+				ResultTransforms: map[string][]config.ResultTransformConfig{
+					"list_issues": {
+						{Extract: "items[*].title"},
+						{Extract: "items"},
+						{Redact: `sk-\d+`},
+					},
+					"*": {
+						{HTMLToMarkdown: true},
+						{Redact: `sk-\d+`, Replacement: "sk-***"},
+						{MaxLines: 1},
+					},
+				},
+			}},
+		},
+		map[string]*server.MCPServer{"tracker": tracker},
+		nil,
+	)
+	defer registry.Close()
+
+	result, err := h.HandleExecuteTool(context.Background(), registry, "list_issues", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `["Leaked key [REDACTED]","Crash","Docs"]`, result.Content[0].(mcp.TextContent).Text,
+		"extracting items from the titles fails, and is skipped")
+
+	result, err = h.HandleExecuteTool(context.Background(), registry, "get_page", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "# Issue\n[lazy-mcp: 2 more lines cut]", result.Content[0].(mcp.TextContent).Text)
+}
+
+// TestInvalidResultTransforms verifies that invalid resultTransforms are
+// reported before the proxy starts, and that results are withheld rather
+// than passed on unredacted if a registry has them anyway.
+func TestInvalidResultTransforms(t *testing.T) {
+	configs := map[string]*config.MCPClientConfigV2{
+		"tracker": {Options: &config.OptionsV2{
+			ResultTransforms: map[string][]config.ResultTransformConfig{
+				"*": {{Redact: `sk-(\d+`}},
+			},
+		}},
+	}
+	assert.ErrorContains(t, CheckResultTransforms(configs), "server tracker: invalid resultTransforms: *: invalid redact pattern")
+	assert.NoError(t, CheckResultTransforms(map[string]*config.MCPClientConfigV2{"tracker": {}}))
+
+	tracker := server.NewMCPServer("tracker", "1.0.0")
+	tracker.AddTool(mcp.NewTool("get_key"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("sk-123"), nil
+	})
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {Tools: map[string]*ToolDefinition{"get_key": {Server: "tracker"}}},
+	}}
+	registry := newTestRegistry(configs, map[string]*server.MCPServer{"tracker": tracker}, nil)
+	defer registry.Close()
+
+	result, err := h.HandleExecuteTool(context.Background(), registry, "get_key", nil)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	assert.NotContains(t, result.Content[0].(mcp.TextContent).Text, "sk-123")
+}
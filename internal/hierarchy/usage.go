@@ -0,0 +1,198 @@
+package hierarchy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// usageHalfLife is how long it takes for a call to count half as much
+// towards a tool's promotion, so that tools no longer used fall behind
+const usageHalfLife = 7 * 24 * time.Hour
+
+// ToolUsage is how often and how recently a tool has been called
+type ToolUsage struct {
+	Server   string    `json:"server"`
+	Calls    uint64    `json:"calls"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// usageStats records the calls made to each tool, by the path it was called
+// by. Stats loaded from a file survive restarts.
+type usageStats struct {
+	mu    sync.Mutex
+	tools map[string]*ToolUsage
+	file  string // Where the stats are saved; empty keeps them in memory
+	dirty bool
+	now   func() time.Time
+}
+
+func (u *usageStats) record(toolPath, serverName string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.tools == nil {
+		u.tools = make(map[string]*ToolUsage)
+	}
+	usage := u.tools[toolPath]
+	if usage == nil {
+		usage = &ToolUsage{}
+		u.tools[toolPath] = usage
+	}
+	usage.Server = serverName
+	usage.Calls++
+	usage.LastUsed = u.time()
+	u.dirty = true
+}
+
+func (u *usageStats) time() time.Time {
+	if u.now != nil {
+		return u.now()
+	}
+	return time.Now()
+}
+
+// serverCalls returns the calls made to the given servers altogether
+func (u *usageStats) serverCalls(servers map[string]bool) uint64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var total uint64
+	for _, usage := range u.tools {
+		if usage != nil && servers[usage.Server] {
+			total += usage.Calls
+		}
+	}
+	return total
+}
+
+// scores returns how much each tool path has been used, with the calls of
+// last week counting half as much as today's
+func (u *usageStats) scores() map[string]float64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := u.time()
+	scores := make(map[string]float64, len(u.tools))
+	for toolPath, usage := range u.tools {
+		if usage == nil {
+			continue
+		}
+		age := now.Sub(usage.LastUsed)
+		scores[toolPath] = float64(usage.Calls) * math.Pow(0.5, float64(age)/float64(usageHalfLife))
+	}
+	return scores
+}
+
+// LoadUsage makes the hierarchy keep its usage stats in file, loading those
+// saved there before. A missing file starts the stats afresh.
+func (h *Hierarchy) LoadUsage(file string) error {
+	h.usage.mu.Lock()
+	defer h.usage.mu.Unlock()
+	h.usage.file = file
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read usage stats: %w", err)
+	}
+	var tools map[string]*ToolUsage
+	if err := json.Unmarshal(data, &tools); err != nil {
+		return fmt.Errorf("failed to parse usage stats %s: %w", file, err)
+	}
+	h.usage.tools = tools
+	return nil
+}
+
+// SaveUsage writes the usage stats to the file given to LoadUsage, if they
+// changed since last saved
+func (h *Hierarchy) SaveUsage() error {
+	h.usage.mu.Lock()
+	defer h.usage.mu.Unlock()
+	if h.usage.file == "" || !h.usage.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(h.usage.tools, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.usage.file), 0o700); err != nil {
+		return fmt.Errorf("failed to create usage stats directory: %w", err)
+	}
+	// Write then rename, so that a crash never leaves a truncated file
+	tmp := h.usage.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write usage stats: %w", err)
+	}
+	if err := os.Rename(tmp, h.usage.file); err != nil {
+		return fmt.Errorf("failed to write usage stats: %w", err)
+	}
+	h.usage.dirty = false
+	return nil
+}
+
+// StartSavingUsage saves the usage stats at the given interval until ctx is
+// done
+func (h *Hierarchy) StartSavingUsage(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := h.SaveUsage(); err != nil {
+				slog.Warn("Failed to save usage stats", "error", err)
+			}
+		}
+	}
+}
+
+// MostUsedTools returns the paths of the n tools used most, and most
+// recently, as listed. Tools no longer in the hierarchy, or hidden, are left
+// out.
+func (h *Hierarchy) MostUsedTools(n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	tools := h.searchableTools()
+	pathOf := make(map[*ToolDefinition]string, len(tools))
+	for _, tool := range tools {
+		pathOf[tool.def] = tool.tool.path
+	}
+
+	// Calls by different names of a tool add up
+	scores := make(map[string]float64)
+	for toolPath, score := range h.usage.scores() {
+		toolDef, _, err := h.ResolveExposedToolPath(toolPath)
+		if err != nil {
+			continue
+		}
+		if listed, ok := pathOf[toolDef]; ok {
+			scores[listed] += score
+		}
+	}
+
+	paths := make([]string, 0, len(scores))
+	for toolPath := range scores {
+		paths = append(paths, toolPath)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if scores[paths[i]] != scores[paths[j]] {
+			return scores[paths[i]] > scores[paths[j]]
+		}
+		return paths[i] < paths[j]
+	})
+	if len(paths) > n {
+		paths = paths[:n]
+	}
+	return paths
+}
@@ -0,0 +1,55 @@
+package hierarchy
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newUsageTestHierarchy() *Hierarchy {
+	return &Hierarchy{nodes: map[string]*HierarchyNode{
+		"": {},
+		"github.create_issue": {Tools: map[string]*ToolDefinition{
+			"create_issue": {Server: "github"},
+		}},
+		"github.list_issues": {Tools: map[string]*ToolDefinition{
+			"list_issues": {Server: "github"},
+		}},
+		"trello.create_card": {Tools: map[string]*ToolDefinition{
+			"create_card": {Server: "trello"},
+		}},
+	}}
+}
+
+// TestMostUsedToolsSurviveRestarts verifies that the most used tools are
+// ranked by calls, fading with age, and that the stats are saved and loaded.
+func TestMostUsedToolsSurviveRestarts(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "lazy-mcp", "usage.json")
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+	h := newUsageTestHierarchy()
+	require.NoError(t, h.LoadUsage(file), "a missing file starts afresh")
+	h.usage.now = func() time.Time { return now.Add(-30 * 24 * time.Hour) }
+	for i := 0; i < 10; i++ {
+		h.usage.record("github.list_issues", "github")
+	}
+	h.usage.now = func() time.Time { return now }
+	h.usage.record("github.create_issue", "github")
+	h.usage.record("create_issue", "github") // Same tool, by its bare name
+	h.usage.record("trello.create_card", "trello")
+	h.usage.record("slack.post", "slack") // No longer in the hierarchy
+
+	// Ten calls a month ago count less than two today
+	assert.Equal(t, []string{"github.create_issue", "trello.create_card", "github.list_issues"}, h.MostUsedTools(5))
+	assert.Equal(t, []string{"github.create_issue"}, h.MostUsedTools(1))
+	assert.Empty(t, h.MostUsedTools(0))
+
+	require.NoError(t, h.SaveUsage())
+	restarted := newUsageTestHierarchy()
+	require.NoError(t, restarted.LoadUsage(file))
+	restarted.usage.now = func() time.Time { return now }
+	assert.Equal(t, h.MostUsedTools(5), restarted.MostUsedTools(5))
+}
@@ -0,0 +1,87 @@
+package hierarchy
+
+import (
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// AddVirtualServers adds a category at the root of the hierarchy for each of
+// virtualServers, listing the tools at its paths under the last part of
+// each, as if they were one server's. Calls to them go to the servers the
+// tools are of. Tools that do not resolve, or are hidden, and virtual servers
+// whose names the hierarchy already has are skipped with a warning. Virtual
+// servers added before are replaced.
+//
+// It is called once the tool filter and overrides are set, which decide the
+// tools that resolve, and again once servers are rebuilt.
+func (h *Hierarchy) AddVirtualServers(virtualServers map[string]config.VirtualServerConfig) {
+	h.mu.Lock()
+	for _, name := range h.virtual {
+		delete(h.nodes, name)
+	}
+	h.virtual = nil
+	h.mu.Unlock()
+
+	names := make([]string, 0, len(virtualServers))
+	for name := range virtualServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nodes := make(map[string]*HierarchyNode, len(names))
+	for _, name := range names {
+		virtual := virtualServers[name]
+		node := &HierarchyNode{Overview: virtual.Overview, Tools: make(map[string]*ToolDefinition, len(virtual.Tools))}
+		for _, toolPath := range virtual.Tools {
+			toolDef, serverName, actualToolName, err := h.resolveCall(toolPath)
+			if err != nil {
+				slog.Warn("Not listing tool in virtual server", "server", name, "path", toolPath, "error", err)
+				continue
+			}
+			toolName := toolPath[strings.LastIndex(toolPath, ".")+1:]
+			if _, listed := node.Tools[toolName]; listed {
+				slog.Warn("Not listing tool in virtual server, as another tool has its name", "server", name, "path", toolPath)
+				continue
+			}
+			// A copy, as prefetching replaces definitions to fill in schemas
+			node.Tools[toolName] = &ToolDefinition{
+				Description: toolDef.Description,
+				MapsTo:      actualToolName,
+				Server:      serverName,
+				InputSchema: toolDef.InputSchema,
+				Timeout:     toolDef.Timeout,
+			}
+		}
+		nodes[name] = node
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, name := range names {
+		if len(nodes[name].Tools) == 0 {
+			slog.Warn("Not adding virtual server without tools", "server", name)
+			continue
+		}
+		if h.hasCategory(name) {
+			slog.Warn("Not adding virtual server, as the hierarchy has a category with its name", "server", name)
+			continue
+		}
+		h.nodes[name] = nodes[name]
+		h.virtual = append(h.virtual, name)
+		slog.Debug("Added virtual server", "server", name, "tools", len(nodes[name].Tools))
+	}
+}
+
+// hasCategory reports whether the hierarchy has a node at path, or below it.
+// h.mu must be held.
+func (h *Hierarchy) hasCategory(path string) bool {
+	for nodePath := range h.nodes {
+		if nodePath == path || strings.HasPrefix(nodePath, path+".") {
+			return true
+		}
+	}
+	return false
+}
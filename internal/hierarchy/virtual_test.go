@@ -0,0 +1,65 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestVirtualServers verifies that a virtual server lists the tools it picks
+// from several servers as a category of its own, that calls to them reach
+// their servers, and that tools that do not resolve are left out.
+func TestVirtualServers(t *testing.T) {
+	newServer := func(name, tool string) *server.MCPServer {
+		s := server.NewMCPServer(name, "1.0.0")
+		s.AddTool(mcp.NewTool(tool), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultText(name + " " + tool), nil
+		})
+		return s
+	}
+
+	h := &Hierarchy{nodes: map[string]*HierarchyNode{
+		"":       {},
+		"github": {Tools: map[string]*ToolDefinition{"create_issue": {Server: "github", Description: "Creates an issue."}, "delete_repo": {Server: "github"}}},
+		"slack":  {Tools: map[string]*ToolDefinition{"post_message": {Server: "slack", Description: "Posts a message."}}},
+	}}
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{"github": {}, "slack": {}},
+		map[string]*server.MCPServer{"github": newServer("github", "create_issue"), "slack": newServer("slack", "post_message")},
+		nil,
+	)
+	defer registry.Close()
+	h.SetToolOverrides(registry)
+
+	h.AddVirtualServers(map[string]config.VirtualServerConfig{
+		"project-x": {Overview: "Project X workflow", Tools: []string{"github.create_issue", "slack.post_message", "jira.search"}},
+		"github":    {Tools: []string{"slack.post_message"}},
+	})
+
+	root, err := h.HandleGetToolsInCategory("")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"is_leaf": true, "tool_count": 2}, root["children"].(map[string]interface{})["project-x"])
+
+	response, err := h.HandleGetToolsInCategory("project-x")
+	require.NoError(t, err)
+	assert.Equal(t, "Project X workflow", response["overview"])
+	tools := response["tools"].(map[string]interface{})
+	require.Len(t, tools, 2)
+	assert.Equal(t, "project-x.create_issue", tools["create_issue"].(map[string]interface{})["tool_path"])
+	assert.Equal(t, "Posts a message.", tools["post_message"].(map[string]interface{})["description"])
+
+	for toolPath, want := range map[string]string{"project-x.create_issue": "github create_issue", "project-x.post_message": "slack post_message"} {
+		result, err := h.HandleExecuteTool(context.Background(), registry, toolPath, nil)
+		require.NoError(t, err, toolPath)
+		assert.Equal(t, want, result.Content[0].(mcp.TextContent).Text)
+	}
+
+	response, err = h.HandleGetToolsInCategory("github")
+	require.NoError(t, err)
+	assert.Len(t, response["tools"], 2, "a virtual server does not replace a category")
+}
@@ -0,0 +1,169 @@
+package hierarchy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/client"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// DefaultHungCallTimeout is how long a call may stay stuck past its timeout
+// before its server is restarted, when hungCallTimeout is not configured
+const DefaultHungCallTimeout = 10 * time.Second
+
+// ErrCallHung is matched by the HungCallError of the calls cut short because
+// a call to their server hung. It wraps client.ErrConnectionLost, as the
+// server is restarted for the next call.
+var ErrCallHung = fmt.Errorf("%w: call hung", client.ErrConnectionLost)
+
+// HungCallError is returned for a call that did not return within
+// hungCallTimeout of its deadline, such as one stuck writing to a dead pipe,
+// and for the calls waiting for its server's slots, once the watchdog closed
+// the server's connection to restart it.
+type HungCallError struct {
+	Server  string
+	Timeout time.Duration
+}
+
+func (e *HungCallError) Error() string {
+	return fmt.Sprintf("server %s was restarted after a call hung for %s past its deadline", e.Server, e.Timeout)
+}
+
+func (e *HungCallError) Is(target error) bool {
+	return target == ErrCallHung
+}
+
+func (e *HungCallError) Unwrap() error {
+	return client.ErrConnectionLost
+}
+
+// HungCallTimeout returns how long a call to the given server may stay stuck
+// once its context is done. Zero means calls are waited for however long
+// they take.
+func (r *ServerRegistry) HungCallTimeout(serverName string) time.Duration {
+	cfg, exists := r.serverConfig(serverName)
+	if !exists || cfg.Options == nil {
+		return DefaultHungCallTimeout
+	}
+	return max(cfg.Options.HungCallTimeout.OrElse(config.Duration(DefaultHungCallTimeout)).Std(), 0)
+}
+
+// slotWaiter is a call waiting for one of a server's call slots, which the
+// watchdog fails if a call holding one hangs
+type slotWaiter struct {
+	cancel context.CancelCauseFunc
+}
+
+// watchCall runs fn, a call to the given server, and returns what it does.
+// If fn is still running HungCallTimeout after ctx is done, its server is
+// restarted, which closes the connection fn is stuck on, and watchCall
+// returns a HungCallError without waiting for fn any longer.
+func (r *ServerRegistry) watchCall(ctx context.Context, serverName string, fn func(ctx context.Context) error) error {
+	timeout := r.HungCallTimeout(serverName)
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+
+	type outcome struct {
+		err      error
+		panicked any
+	}
+	done := make(chan outcome, 1)
+	called := time.Now()
+	go func() {
+		var result outcome
+		defer func() {
+			result.panicked = recover()
+			done <- result
+		}()
+		result.err = fn(ctx)
+	}()
+	wait := func(result outcome) error {
+		if result.panicked != nil {
+			panic(result.panicked)
+		}
+		return result.err
+	}
+
+	select {
+	case result := <-done:
+		return wait(result)
+	case <-ctx.Done():
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case result := <-done:
+		return wait(result)
+	case <-timer.C:
+	}
+	hungErr := &HungCallError{Server: serverName, Timeout: timeout}
+	r.restartHung(serverName, called, hungErr)
+	return hungErr
+}
+
+// restartHung fails the calls waiting for the given server's slots with
+// hungErr, and closes its connection and restarts it, like one that crashed,
+// unless it was restarted since the hung call was made
+func (r *ServerRegistry) restartHung(serverName string, called time.Time, hungErr *HungCallError) {
+	r.mu.Lock()
+	waiters := r.slotWaiters[serverName]
+	delete(r.slotWaiters, serverName)
+	state, running := r.servers[serverName]
+	running = running && state.started.Before(called)
+	restart := false
+	if running {
+		delete(r.servers, serverName)
+		state.stop()
+		r.publish(context.Background(), Event{Type: EventServerStopped, Server: serverName, Reason: ReasonHung, Err: hungErr, Duration: time.Since(state.started)})
+		restart = r.recordCrashLocked(serverName, hungErr, time.Since(state.started), state.client.Remote())
+	}
+	r.mu.Unlock()
+
+	for waiter := range waiters {
+		waiter.cancel(hungErr)
+	}
+	if !running {
+		return
+	}
+	// Closing a stdio server waits for it to exit, which a hung one may be
+	// slow to
+	go func() { _ = state.client.Close() }()
+	if restart {
+		go r.restart(serverName)
+	}
+}
+
+// addSlotWaiter registers a call about to wait for one of the given server's
+// slots, returning the context it waits with, which is cancelled with a
+// HungCallError if a call to the server hangs, and a func to unregister it
+func (r *ServerRegistry) addSlotWaiter(ctx context.Context, serverName string) (context.Context, func()) {
+	waitCtx, cancel := context.WithCancelCause(ctx)
+	waiter := &slotWaiter{cancel: cancel}
+	r.mu.Lock()
+	if r.slotWaiters[serverName] == nil {
+		r.slotWaiters[serverName] = make(map[*slotWaiter]struct{})
+	}
+	r.slotWaiters[serverName][waiter] = struct{}{}
+	r.mu.Unlock()
+	return waitCtx, func() {
+		r.mu.Lock()
+		delete(r.slotWaiters[serverName], waiter)
+		if len(r.slotWaiters[serverName]) == 0 {
+			delete(r.slotWaiters, serverName)
+		}
+		r.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// hungCause returns the HungCallError a slot wait was cancelled with, if any
+func hungCause(waitCtx context.Context) error {
+	if cause := context.Cause(waitCtx); errors.Is(cause, ErrCallHung) {
+		return cause
+	}
+	return nil
+}
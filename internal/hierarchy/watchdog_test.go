@@ -0,0 +1,113 @@
+package hierarchy
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/client"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestWatchdogRestartsServerOfHungCall verifies that a call still running
+// hungCallTimeout past its deadline is given up, that the calls waiting for
+// its slot fail with the same clear error rather than time out, and that the
+// server is restarted with its slot free again.
+func TestWatchdogRestartsServerOfHungCall(t *testing.T) {
+	var launches int32
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"echo": {Options: &config.OptionsV2{HungCallTimeout: optional.NewField(config.Duration(100 * time.Millisecond))}},
+		},
+		map[string]*server.MCPServer{"echo": newEchoServer()},
+		&launches,
+	)
+	registry.restartBaseDelay = 10 * time.Millisecond
+	defer registry.Close()
+
+	ctx := context.Background()
+	_, err := registry.GetOrLoadServer(ctx, "echo")
+	require.NoError(t, err)
+	stopped := make(chan Event, 1)
+	registry.Events().Subscribe(func(ctx context.Context, event Event) {
+		if event.Type == EventServerStopped {
+			stopped <- event
+		}
+	})
+
+	// Stuck past its deadline, as on a pipe the server no longer reads
+	called, unblock := make(chan struct{}), make(chan struct{})
+	defer close(unblock)
+	hungCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	hung := make(chan error, 1)
+	go func() {
+		hung <- registry.WithClientLock(hungCtx, "echo", func(ctx context.Context) error {
+			close(called)
+			<-unblock
+			return nil
+		})
+	}()
+	<-called
+
+	waitCtx, cancelWait := context.WithTimeout(ctx, 5*time.Second)
+	defer cancelWait()
+	start := time.Now()
+	err = registry.WithClientLock(waitCtx, "echo", func(ctx context.Context) error { return nil })
+	assert.ErrorIs(t, err, ErrCallHung)
+	assert.Less(t, time.Since(start), 2*time.Second, "the waiting call is failed as soon as the watchdog fires")
+
+	err = <-hung
+	var hungErr *HungCallError
+	require.ErrorAs(t, err, &hungErr)
+	assert.Equal(t, "echo", hungErr.Server)
+	assert.ErrorIs(t, err, client.ErrConnectionLost, "the call may be retried against the restarted server")
+
+	select {
+	case event := <-stopped:
+		assert.Equal(t, ReasonHung, event.Reason)
+	case <-time.After(time.Second):
+		t.Fatal("server was not stopped")
+	}
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&launches) == 2 }, 2*time.Second, 10*time.Millisecond)
+
+	callCtx, cancelCall := context.WithTimeout(ctx, time.Second)
+	defer cancelCall()
+	assert.NoError(t, registry.WithClientLock(callCtx, "echo", func(ctx context.Context) error { return nil }),
+		"the hung call's slot is free again")
+}
+
+// TestWatchdogWaitsOutSlowCalls verifies that a call returning within
+// hungCallTimeout of its deadline is waited for, leaving its server alone.
+func TestWatchdogWaitsOutSlowCalls(t *testing.T) {
+	var launches int32
+	registry := newTestRegistry(
+		map[string]*config.MCPClientConfigV2{
+			"echo": {Options: &config.OptionsV2{HungCallTimeout: optional.NewField(config.Duration(time.Second))}},
+		},
+		map[string]*server.MCPServer{"echo": newEchoServer()},
+		&launches,
+	)
+	defer registry.Close()
+
+	_, err := registry.GetOrLoadServer(context.Background(), "echo")
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	slow := errors.New("done late")
+	err = registry.WithClientLock(ctx, "echo", func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		return slow
+	})
+	assert.ErrorIs(t, err, slow)
+	_, running := registry.lookupQuiet("echo")
+	assert.True(t, running)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&launches))
+}
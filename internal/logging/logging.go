@@ -0,0 +1,258 @@
+// Package logging sets up structured logging, with the minimum level
+// configurable per server.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/redact"
+)
+
+// Keys of the attributes identifying what a record is about
+const (
+	KeyServer    = "server"
+	KeyTool      = "tool"
+	KeyRequestID = "request_id"
+)
+
+// levels holds the minimum level of records per server, as configured
+var levels atomic.Pointer[levelTable]
+
+type levelTable struct {
+	fallback slog.Level // For records not about a server, or about an unknown one
+	servers  map[string]slog.Level
+}
+
+func (t *levelTable) forServer(name string) slog.Level {
+	if t == nil {
+		return slog.LevelInfo
+	}
+	if level, ok := t.servers[name]; ok {
+		return level
+	}
+	return t.fallback
+}
+
+// Setup installs the default slog logger, which also receives the output of
+// the log package. Records are written to w in the configured logFormat,
+// filtered by the logLevel of the server they are about and with secrets
+// redacted.
+func Setup(w io.Writer, cfg *config.Config) error {
+	format := "text"
+	if cfg.McpProxy.Options != nil {
+		format = strings.ToLower(cfg.McpProxy.Options.LogFormat.OrElse(format))
+	}
+
+	// Filtering happens in handler.Enabled, so the inner handler takes everything
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var inner slog.Handler
+	switch format {
+	case "text":
+		inner = slog.NewTextHandler(w, opts)
+	case "json":
+		inner = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+
+	if err := SetLevels(cfg); err != nil {
+		return err
+	}
+	redactor, err := redact.New(cfg.McpProxy.Redaction)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(&handler{inner: inner, redactor: redactor}))
+	return nil
+}
+
+// SetLevels applies the logLevel options of cfg, e.g. after the config was
+// reloaded. Nothing changes if any of them is invalid.
+func SetLevels(cfg *config.Config) error {
+	table := &levelTable{servers: make(map[string]slog.Level, len(cfg.McpServers))}
+	if cfg.McpProxy.Options != nil {
+		level, err := config.ParseLogLevel(cfg.McpProxy.Options.LogLevel.OrElse(""))
+		if err != nil {
+			return fmt.Errorf("mcpProxy: %w", err)
+		}
+		table.fallback = level
+	}
+	for name, serverConfig := range cfg.McpServers {
+		if serverConfig.Options == nil {
+			continue
+		}
+		level, err := config.ParseLogLevel(serverConfig.Options.LogLevel.OrElse(""))
+		if err != nil {
+			return fmt.Errorf("server %s: %w", name, err)
+		}
+		table.servers[name] = level
+	}
+	levels.Store(table)
+	return nil
+}
+
+// ForServer returns the default logger with records attributed to the named
+// server, and so filtered by its logLevel.
+func ForServer(name string) *slog.Logger {
+	return slog.Default().With(KeyServer, name)
+}
+
+type fieldsKey struct{}
+
+// fields identify what the records logged with a context are about
+type fields struct {
+	server    string
+	tool      string
+	requestID string
+}
+
+func fieldsFrom(ctx context.Context) fields {
+	f, _ := ctx.Value(fieldsKey{}).(fields)
+	return f
+}
+
+// WithServer returns ctx with records logged through it attributed to the
+// named server
+func WithServer(ctx context.Context, server string) context.Context {
+	f := fieldsFrom(ctx)
+	f.server = server
+	return context.WithValue(ctx, fieldsKey{}, f)
+}
+
+// WithTool returns ctx with records logged through it attributed to the named
+// tool
+func WithTool(ctx context.Context, tool string) context.Context {
+	f := fieldsFrom(ctx)
+	f.tool = tool
+	return context.WithValue(ctx, fieldsKey{}, f)
+}
+
+// WithRequestID returns ctx with records logged through it tagged with the
+// given request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	f := fieldsFrom(ctx)
+	f.requestID = id
+	return context.WithValue(ctx, fieldsKey{}, f)
+}
+
+// RequestID returns the request ID carried by ctx, if any
+func RequestID(ctx context.Context) string {
+	return fieldsFrom(ctx).requestID
+}
+
+// NewRequestID returns a random ID for correlating the records of one request
+func NewRequestID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// handler adds the server, tool and request ID carried by the context to each
+// record, drops records below the level of the server they are about, and
+// masks secrets.
+type handler struct {
+	inner    slog.Handler
+	redactor *redact.Redactor
+	// Set by Logger.With, in which case the context value is not repeated
+	server       string
+	hasTool      bool
+	hasRequestID bool
+	grouped      bool
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	server := h.server
+	if server == "" {
+		server = fieldsFrom(ctx).server
+	}
+	return level >= levels.Load().forServer(server)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	f := fieldsFrom(ctx)
+	var attrs []slog.Attr
+	if f.server != "" && h.server == "" {
+		attrs = append(attrs, slog.String(KeyServer, f.server))
+	}
+	if f.tool != "" && !h.hasTool {
+		attrs = append(attrs, slog.String(KeyTool, f.tool))
+	}
+	if f.requestID != "" && !h.hasRequestID {
+		attrs = append(attrs, slog.String(KeyRequestID, f.requestID))
+	}
+	if len(attrs) == 0 && h.redactor == nil {
+		return h.inner.Handle(ctx, r)
+	}
+
+	// Put them ahead of the record's own attributes
+	record := slog.NewRecord(r.Time, r.Level, h.redactor.String(r.Message), r.PC)
+	record.AddAttrs(attrs...)
+	r.Attrs(func(attr slog.Attr) bool {
+		record.AddAttrs(redactAttr(h.redactor, attr))
+		return true
+	})
+	return h.inner.Handle(ctx, record)
+}
+
+// redactAttr masks the secrets of attr: all of its value if its key is
+// sensitive, else those within it
+func redactAttr(redactor *redact.Redactor, attr slog.Attr) slog.Attr {
+	if redactor == nil {
+		return attr
+	}
+	if redactor.SensitiveKey(attr.Key) {
+		return slog.String(attr.Key, redact.Mask)
+	}
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, redactor.String(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, member := range group {
+			redacted[i] = redactAttr(redactor, member)
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		return slog.Any(attr.Key, redactor.Value(attr.Key, value.Any()))
+	}
+	return attr
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = redactAttr(h.redactor, attr)
+	}
+	clone.inner = h.inner.WithAttrs(redacted)
+	if !h.grouped {
+		for _, attr := range attrs {
+			switch attr.Key {
+			case KeyServer:
+				clone.server = attr.Value.String()
+			case KeyTool:
+				clone.hasTool = true
+			case KeyRequestID:
+				clone.hasRequestID = true
+			}
+		}
+	}
+	return &clone
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.inner = h.inner.WithGroup(name)
+	clone.grouped = true
+	return &clone
+}
@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/TBXark/optional-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestPerServerLevelsAndContextFields verifies that records are filtered by
+// the level of the server they are about, and carry the server, tool and
+// request ID from the context.
+func TestPerServerLevelsAndContextFields(t *testing.T) {
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	var buf bytes.Buffer
+	cfg := &config.Config{
+		McpProxy: &config.MCPProxyConfigV2{Options: &config.OptionsV2{
+			LogLevel:  optional.NewField("warn"),
+			LogFormat: optional.NewField("json"),
+		}},
+		McpServers: map[string]*config.MCPClientConfigV2{
+			"flaky":  {Options: &config.OptionsV2{LogLevel: optional.NewField("debug")}},
+			"stable": {Options: &config.OptionsV2{LogLevel: optional.NewField("warn")}},
+		},
+	}
+	require.NoError(t, Setup(&buf, cfg))
+
+	ctx := WithRequestID(WithTool(context.Background(), "search"), "req-1")
+	slog.InfoContext(ctx, "dropped: proxy is at warn")
+	slog.DebugContext(WithServer(ctx, "flaky"), "kept: flaky is at debug")
+	ForServer("stable").InfoContext(ctx, "dropped: stable is at warn")
+	ForServer("flaky").DebugContext(WithServer(ctx, "stable"), "kept: bound server wins", "count", 1)
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 2)
+
+	assert.Equal(t, "kept: flaky is at debug", records[0]["msg"])
+	assert.Equal(t, "flaky", records[0][KeyServer])
+	assert.Equal(t, "search", records[0][KeyTool])
+	assert.Equal(t, "req-1", records[0][KeyRequestID])
+
+	assert.Equal(t, "kept: bound server wins", records[1]["msg"])
+	assert.Equal(t, "flaky", records[1][KeyServer])
+	assert.Equal(t, "req-1", records[1][KeyRequestID])
+	assert.EqualValues(t, 1, records[1]["count"])
+}
+
+// TestSetLevelsRejectsUnknownLevel verifies that an invalid level leaves the
+// current levels in place.
+func TestSetLevelsRejectsUnknownLevel(t *testing.T) {
+	cfg := &config.Config{
+		McpProxy: &config.MCPProxyConfigV2{Options: &config.OptionsV2{}},
+		McpServers: map[string]*config.MCPClientConfigV2{
+			"flaky": {Options: &config.OptionsV2{LogLevel: optional.NewField("debug")}},
+		},
+	}
+	require.NoError(t, SetLevels(cfg))
+
+	cfg.McpServers["flaky"].Options.LogLevel = optional.NewField("verbose")
+	assert.ErrorContains(t, SetLevels(cfg), `unknown log level "verbose"`)
+	assert.Equal(t, slog.LevelDebug, levels.Load().forServer("flaky"))
+}
+
+// TestSecretsAreRedacted verifies that the values of sensitive attributes,
+// and secrets within messages, errors and bound attributes, are masked by
+// default.
+func TestSecretsAreRedacted(t *testing.T) {
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	var buf bytes.Buffer
+	cfg := &config.Config{McpProxy: &config.MCPProxyConfigV2{Options: &config.OptionsV2{LogFormat: optional.NewField("json")}}}
+	require.NoError(t, Setup(&buf, cfg))
+
+	slog.With("env", map[string]interface{}{"GITHUB_TOKEN": "ghp_abc", "HOME": "/root"}).Info(
+		"Calling with Bearer abc.def",
+		"password", "hunter2",
+		"error", errors.New("auth failed: apiKey=sk-123"),
+		"tokens", 42,
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"ghp_abc", "abc.def", "hunter2", "sk-123"} {
+		assert.NotContains(t, out, secret)
+	}
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "Calling with Bearer [REDACTED]", record["msg"])
+	assert.Equal(t, "auth failed: apiKey=[REDACTED]", record["error"])
+	assert.Equal(t, map[string]any{"GITHUB_TOKEN": "[REDACTED]", "HOME": "/root"}, record["env"])
+	assert.EqualValues(t, 42, record["tokens"])
+}
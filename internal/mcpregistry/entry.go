@@ -0,0 +1,151 @@
+package mcpregistry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// InputKind is what an Input is passed to the server as
+type InputKind string
+
+const (
+	InputEnv      InputKind = "environment variable"
+	InputHeader   InputKind = "header"
+	InputArgument InputKind = "argument"
+)
+
+// Resolve returns the value of a required input the server does not fix,
+// typically by asking the user
+type Resolve func(kind InputKind, in Input) (string, error)
+
+// runners are the runners of the package registries lazy-mcp can run from, in
+// order of preference
+var runners = []struct {
+	registryType string
+	runner       string
+}{
+	{"npm", config.RunnerNpx},
+	{"pypi", config.RunnerUvx},
+	{"oci", ""},
+}
+
+// Entry returns a server entry that runs the server: from its npm, PyPI or
+// OCI package, in that order of preference, or else at its remote endpoint.
+// Required inputs are filled in by resolve.
+func Entry(server *Server, resolve Resolve) (*config.MCPClientConfigV2, error) {
+	for _, r := range runners {
+		for i := range server.Packages {
+			pkg := &server.Packages[i]
+			if pkg.RegistryType != r.registryType || (pkg.Transport.Type != "" && pkg.Transport.Type != "stdio") {
+				continue
+			}
+			entry := &config.MCPClientConfigV2{}
+			if r.runner != "" {
+				entry.Runner, entry.Package, entry.Version = r.runner, pkg.Identifier, pkg.Version
+			} else {
+				entry.Image = imageReference(pkg.Identifier, pkg.Version)
+			}
+			var err error
+			if entry.Args, err = packageArgs(pkg.PackageArguments, resolve); err != nil {
+				return nil, err
+			}
+			if entry.Env, err = inputValues(InputEnv, pkg.EnvironmentVariables, resolve); err != nil {
+				return nil, err
+			}
+			return entry, nil
+		}
+	}
+
+	for _, remote := range server.Remotes {
+		entry := &config.MCPClientConfigV2{URL: remote.URL}
+		switch remote.Type {
+		case "streamable-http":
+			entry.Type = config.MCPClientTypeHTTP
+		case "sse":
+			entry.Type = config.MCPClientTypeSSE
+		default:
+			continue
+		}
+		var err error
+		if entry.Headers, err = inputValues(InputHeader, remote.Headers, resolve); err != nil {
+			return nil, err
+		}
+		return entry, nil
+	}
+	return nil, fmt.Errorf("server %s publishes no npm, PyPI or OCI package, nor a remote endpoint, that lazy-mcp can run", server.Name)
+}
+
+// imageReference returns the image of an OCI package, tagged with its version
+// unless the identifier carries a tag or digest
+func imageReference(identifier, version string) string {
+	last := identifier[strings.LastIndex(identifier, "/")+1:]
+	if version == "" || strings.ContainsAny(last, ":@") {
+		return identifier
+	}
+	return identifier + ":" + version
+}
+
+// packageArgs returns the command-line arguments of a package
+func packageArgs(arguments []Argument, resolve Resolve) ([]string, error) {
+	var args []string
+	for _, arg := range arguments {
+		in := arg.Input
+		if in.Name == "" {
+			in.Name = arg.ValueHint
+		}
+		value, err := inputValue(InputArgument, in, resolve)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case arg.Type == "named" && value != "":
+			args = append(args, arg.Name, value)
+		case value != "":
+			args = append(args, value)
+		}
+	}
+	return args, nil
+}
+
+// inputValues returns the values of the inputs that are set, by name
+func inputValues(kind InputKind, inputs []Input, resolve Resolve) (map[string]string, error) {
+	var values map[string]string
+	for _, in := range inputs {
+		value, err := inputValue(kind, in, resolve)
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			continue
+		}
+		if values == nil {
+			values = make(map[string]string)
+		}
+		values[in.Name] = value
+	}
+	return values, nil
+}
+
+// inputValue returns the value of an input: the one the server fixes, or for
+// a required input its default or what resolve says. Optional inputs are left
+// to the server's defaults.
+func inputValue(kind InputKind, in Input, resolve Resolve) (string, error) {
+	switch {
+	case in.Value != "":
+		return in.Value, nil
+	case !in.IsRequired:
+		return "", nil
+	case in.Default != "":
+		return in.Default, nil
+	}
+	value, err := resolve(kind, in)
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return "", fmt.Errorf("%s %s is required", kind, in.Name)
+	}
+	return value, nil
+}
@@ -0,0 +1,148 @@
+// Package mcpregistry looks up servers in the public MCP registry, and turns
+// what they publish into lazy-mcp server entries.
+package mcpregistry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// DefaultURL is the public MCP registry
+const DefaultURL = "https://registry.modelcontextprotocol.io"
+
+// Server is a server as the registry publishes it
+type Server struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Version     string    `json:"version"`
+	Packages    []Package `json:"packages"`
+	Remotes     []Remote  `json:"remotes"`
+}
+
+// Package is a package the server can be run from
+type Package struct {
+	// RegistryType is where the package is published: "npm", "pypi" or
+	// "oci"; others are not supported
+	RegistryType string `json:"registryType"`
+	Identifier   string `json:"identifier"`
+	Version      string `json:"version"`
+	Transport    struct {
+		Type string `json:"type"`
+	} `json:"transport"`
+	PackageArguments     []Argument `json:"packageArguments"`
+	EnvironmentVariables []Input    `json:"environmentVariables"`
+}
+
+// Remote is an endpoint the server is hosted at
+type Remote struct {
+	// Type is "streamable-http" or "sse"
+	Type    string  `json:"type"`
+	URL     string  `json:"url"`
+	Headers []Input `json:"headers"`
+}
+
+// Input is a value the server takes: an environment variable, a header, or
+// an argument
+type Input struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	IsRequired  bool   `json:"isRequired"`
+	IsSecret    bool   `json:"isSecret"`
+	Default     string `json:"default"`
+	// Value is fixed by the server, if set
+	Value string `json:"value"`
+}
+
+// Argument is an argument of a package: a "positional" one, or a "named"
+// flag such as --port
+type Argument struct {
+	Input
+	Type      string `json:"type"`
+	ValueHint string `json:"valueHint"`
+}
+
+// listResponse is the registry's response to a search
+type listResponse struct {
+	Servers []struct {
+		Server Server `json:"server"`
+	} `json:"servers"`
+}
+
+// Client looks up servers in a registry
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient returns a client of the registry at baseURL, DefaultURL if empty
+func NewClient(baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultURL
+	}
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Lookup returns the latest version of the named server. The name is either
+// the full registry name, e.g. "io.github.owner/server", or its last part if
+// that is unambiguous.
+func (c *Client) Lookup(ctx context.Context, name string) (*Server, error) {
+	query := url.Values{"search": {name}, "version": {"latest"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/v0/servers?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the MCP registry: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("MCP registry returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var list listResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("invalid response from the MCP registry: %w", err)
+	}
+
+	// The search matches substrings, so pick the server actually named
+	var matches []*Server
+	for i := range list.Servers {
+		server := &list.Servers[i].Server
+		if server.Name == name {
+			return server, nil
+		}
+		if path.Base(server.Name) == name {
+			matches = append(matches, server)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		var names []string
+		for _, s := range list.Servers {
+			names = append(names, s.Server.Name)
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no server named %q in the MCP registry", name)
+		}
+		return nil, fmt.Errorf("no server named %q in the MCP registry; similar: %s", name, strings.Join(names, ", "))
+	case 1:
+		return matches[0], nil
+	}
+	var names []string
+	for _, s := range matches {
+		names = append(names, s.Name)
+	}
+	return nil, fmt.Errorf("%q is ambiguous, use the full name: %s", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,119 @@
+package mcpregistry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// newRegistry starts a registry whose searches return servers
+func newRegistry(t *testing.T, servers ...Server) *Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v0/servers", r.URL.Path)
+		assert.Equal(t, "latest", r.URL.Query().Get("version"))
+		var list listResponse
+		for _, s := range servers {
+			list.Servers = append(list.Servers, struct {
+				Server Server `json:"server"`
+			}{s})
+		}
+		_ = json.NewEncoder(w).Encode(list)
+	}))
+	t.Cleanup(srv.Close)
+	return NewClient(srv.URL)
+}
+
+// TestLookupPicksNamedServer verifies that a server is found by its full name
+// or an unambiguous last part, among the servers the search matches.
+func TestLookupPicksNamedServer(t *testing.T) {
+	ctx := context.Background()
+	registry := newRegistry(t,
+		Server{Name: "io.github.acme/github-issues"},
+		Server{Name: "io.github.acme/github"},
+		Server{Name: "io.github.other/github"},
+	)
+
+	server, err := registry.Lookup(ctx, "io.github.other/github")
+	require.NoError(t, err)
+	assert.Equal(t, "io.github.other/github", server.Name)
+
+	server, err = registry.Lookup(ctx, "github-issues")
+	require.NoError(t, err)
+	assert.Equal(t, "io.github.acme/github-issues", server.Name)
+
+	_, err = registry.Lookup(ctx, "github")
+	assert.ErrorContains(t, err, "ambiguous")
+	_, err = registry.Lookup(ctx, "gitlab")
+	assert.ErrorContains(t, err, "no server named")
+}
+
+// TestEntryRunsPreferredPackage verifies that the entry runs the npm package
+// over the others, with its arguments and required environment variables.
+func TestEntryRunsPreferredPackage(t *testing.T) {
+	server := &Server{Name: "io.github.acme/tracker", Packages: []Package{
+		{RegistryType: "oci", Identifier: "ghcr.io/acme/tracker", Version: "1.2.0"},
+		{
+			RegistryType: "npm", Identifier: "@acme/tracker", Version: "1.2.0",
+			PackageArguments: []Argument{
+				{Type: "positional", Input: Input{Value: "stdio"}},
+				{Type: "named", Input: Input{Name: "--region", IsRequired: true, Default: "eu"}},
+				{Type: "named", Input: Input{Name: "--verbose"}},
+			},
+			EnvironmentVariables: []Input{
+				{Name: "TRACKER_TOKEN", IsRequired: true, IsSecret: true},
+				{Name: "TRACKER_DEBUG"},
+			},
+		},
+	}}
+	var asked []string
+	entry, err := Entry(server, func(kind InputKind, in Input) (string, error) {
+		asked = append(asked, in.Name)
+		return "${" + in.Name + "}", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &config.MCPClientConfigV2{
+		Runner:  config.RunnerNpx,
+		Package: "@acme/tracker",
+		Version: "1.2.0",
+		Args:    []string{"stdio", "--region", "eu"},
+		Env:     map[string]string{"TRACKER_TOKEN": "${TRACKER_TOKEN}"},
+	}, entry)
+	assert.Equal(t, []string{"TRACKER_TOKEN"}, asked, "only required inputs without a value are asked for")
+
+	_, err = Entry(server, func(InputKind, Input) (string, error) { return "", nil })
+	assert.ErrorContains(t, err, "TRACKER_TOKEN is required")
+}
+
+// TestEntryFallsBackToImageOrRemote verifies that servers without an npm or
+// PyPI package run in a container, or at their remote endpoint.
+func TestEntryFallsBackToImageOrRemote(t *testing.T) {
+	noInputs := func(InputKind, Input) (string, error) { return "", nil }
+
+	entry, err := Entry(&Server{Packages: []Package{
+		{RegistryType: "nuget", Identifier: "Acme.Tracker"},
+		{RegistryType: "oci", Identifier: "ghcr.io/acme/tracker", Version: "1.2.0"},
+	}}, noInputs)
+	require.NoError(t, err)
+	assert.Equal(t, "ghcr.io/acme/tracker:1.2.0", entry.Image)
+	assert.Equal(t, config.MCPClientTypeDocker, entry.Transport())
+
+	entry, err = Entry(&Server{Remotes: []Remote{{
+		Type: "streamable-http", URL: "https://mcp.acme.com/mcp",
+		Headers: []Input{{Name: "X-Api-Key", IsRequired: true}},
+	}}}, func(kind InputKind, in Input) (string, error) {
+		assert.Equal(t, InputHeader, kind)
+		return "key", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, config.MCPClientTypeStreamable, entry.Transport())
+	assert.Equal(t, map[string]string{"X-Api-Key": "key"}, entry.Headers)
+
+	_, err = Entry(&Server{Name: "io.github.acme/desktop", Packages: []Package{{RegistryType: "mcpb"}}}, noInputs)
+	assert.Error(t, err)
+}
@@ -0,0 +1,322 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// clientName is what lazy-mcp registers itself as
+const clientName = "lazy-mcp"
+
+// flowTimeout is how long the user has to grant access before the
+// authorization is abandoned
+const flowTimeout = 10 * time.Minute
+
+// TransportConfig returns the OAuth configuration of the transports to the
+// given server: PKCE, and the client and tokens of cfg or its store
+func TransportConfig(ctx context.Context, server string, cfg *config.OAuthConfig) (transport.OAuthConfig, error) {
+	store, err := NewStore(server, cfg)
+	if err != nil {
+		return transport.OAuthConfig{}, err
+	}
+	id, secret := cfg.ClientID, cfg.ClientSecret
+	if id == "" {
+		if id, secret, err = store.Client(ctx); err != nil {
+			return transport.OAuthConfig{}, err
+		}
+	}
+	return transport.OAuthConfig{
+		ClientID:              id,
+		ClientSecret:          secret,
+		RedirectURI:           cfg.Redirect(),
+		Scopes:                cfg.Scopes,
+		TokenStore:            store,
+		AuthServerMetadataURL: cfg.MetadataURL,
+		PKCEEnabled:           true,
+	}, nil
+}
+
+// NewHandler returns an OAuth handler for the given remote server, as its
+// transport would create
+func NewHandler(ctx context.Context, server string, conf *config.MCPClientConfigV2) (*transport.OAuthHandler, error) {
+	oauthConfig, err := TransportConfig(ctx, server, conf.OAuth)
+	if err != nil {
+		return nil, err
+	}
+	httpTransport, err := config.HTTPTransport(conf.TLS, conf.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	if httpTransport != nil {
+		oauthConfig.HTTPClient = &http.Client{Transport: httpTransport, Timeout: 30 * time.Second}
+	}
+	u, err := url.Parse(conf.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL: %w", err)
+	}
+	handler := transport.NewOAuthHandler(oauthConfig)
+	handler.SetBaseURL(u.Scheme + "://" + u.Host)
+	return handler, nil
+}
+
+// flow is an authorization underway
+type flow struct {
+	url  string
+	done chan struct{}
+	err  error // Set before done is closed
+}
+
+// callback is the result of an authorization, as the browser delivers it
+type callback struct {
+	code string
+	err  error
+}
+
+// listener receives the authorizations sent to a redirect URI's address
+type listener struct {
+	server  *http.Server
+	pending map[string]chan callback // By state
+}
+
+// Authorizer runs the authorization flows of servers, one at a time per
+// server. Flows run in the background until the user grants access in the
+// browser, or they time out.
+type Authorizer struct {
+	mu        sync.Mutex
+	flows     map[string]*flow     // By server
+	listeners map[string]*listener // By address
+	// openBrowser opens a URL for the user; replaced in tests
+	openBrowser func(url string) error
+}
+
+// NewAuthorizer returns an Authorizer with no flows underway
+func NewAuthorizer() *Authorizer {
+	return &Authorizer{
+		flows:       make(map[string]*flow),
+		listeners:   make(map[string]*listener),
+		openBrowser: openBrowser,
+	}
+}
+
+// Start begins authorizing with the given server through handler, unless a
+// flow is already underway, and returns the URL the user grants access at.
+// Without a client ID, lazy-mcp first registers itself, and the registration
+// is stored. The browser is opened at the URL unless cfg says otherwise.
+func (a *Authorizer) Start(ctx context.Context, server string, cfg *config.OAuthConfig, handler *transport.OAuthHandler) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if f, exists := a.flows[server]; exists {
+		return f.url, nil
+	}
+	log := logging.ForServer(server)
+
+	if handler.GetClientID() == "" {
+		if err := handler.RegisterClient(ctx, clientName); err != nil {
+			return "", fmt.Errorf("failed to register with the authorization server: %w", err)
+		}
+		store, err := NewStore(server, cfg)
+		if err != nil {
+			return "", err
+		}
+		if err := store.SaveClient(ctx, handler.GetClientID(), handler.GetClientSecret()); err != nil {
+			return "", fmt.Errorf("failed to store client registration: %w", err)
+		}
+		log.InfoContext(ctx, "Registered OAuth client", "client_id", handler.GetClientID())
+	}
+
+	verifier, err := transport.GenerateCodeVerifier()
+	if err != nil {
+		return "", err
+	}
+	state, err := transport.GenerateState()
+	if err != nil {
+		return "", err
+	}
+	authURL, err := handler.GetAuthorizationURL(ctx, state, transport.GenerateCodeChallenge(verifier))
+	if err != nil {
+		return "", fmt.Errorf("failed to build authorization URL: %w", err)
+	}
+	callbacks, err := a.listen(cfg.Redirect(), state)
+	if err != nil {
+		return "", err
+	}
+
+	f := &flow{url: authURL, done: make(chan struct{})}
+	a.flows[server] = f
+	go func() {
+		defer close(f.done)
+		defer func() {
+			a.mu.Lock()
+			delete(a.flows, server)
+			a.unlisten(cfg.Redirect(), state)
+			a.mu.Unlock()
+		}()
+		select {
+		case result := <-callbacks:
+			f.err = result.err
+			if f.err == nil {
+				exchangeCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				f.err = handler.ProcessAuthorizationResponse(exchangeCtx, result.code, state, verifier)
+				cancel()
+			}
+		case <-time.After(flowTimeout):
+			f.err = errors.New("authorization timed out")
+		}
+		if f.err != nil {
+			log.Warn("OAuth authorization failed", "error", f.err)
+			return
+		}
+		log.Info("Authorized with server")
+	}()
+
+	if cfg.OpenBrowser.OrElse(true) {
+		if err := a.openBrowser(authURL); err != nil {
+			log.WarnContext(ctx, "Failed to open browser", "error", err)
+		}
+	}
+	log.InfoContext(ctx, "Authorization required, waiting for access to be granted", "url", authURL)
+	return authURL, nil
+}
+
+// Wait waits for the flow underway for the given server, if any, and returns
+// its error
+func (a *Authorizer) Wait(ctx context.Context, server string) error {
+	a.mu.Lock()
+	f, exists := a.flows[server]
+	a.mu.Unlock()
+	if !exists {
+		return nil
+	}
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops receiving authorizations, failing the flows underway
+func (a *Authorizer) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for addr, l := range a.listeners {
+		for _, callbacks := range l.pending {
+			select {
+			case callbacks <- callback{err: errors.New("authorizer closed")}:
+			default: // Already received
+			}
+		}
+		_ = l.server.Close()
+		delete(a.listeners, addr)
+	}
+}
+
+// listen routes the authorization of state, sent to redirect, to the
+// returned channel. The caller must hold a.mu.
+func (a *Authorizer) listen(redirect, state string) (<-chan callback, error) {
+	u, err := url.Parse(redirect)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect URI: %w", err)
+	}
+	l, exists := a.listeners[u.Host]
+	if !exists {
+		ln, err := net.Listen("tcp", u.Host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen for the authorization: %w", err)
+		}
+		l = &listener{pending: make(map[string]chan callback)}
+		mux := http.NewServeMux()
+		path := u.Path
+		if path == "" {
+			path = "/"
+		}
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			a.receive(w, r, l)
+		})
+		l.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := l.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Warn("OAuth callback listener failed", "error", err)
+			}
+		}()
+		a.listeners[u.Host] = l
+	}
+	callbacks := make(chan callback, 1)
+	l.pending[state] = callbacks
+	return callbacks, nil
+}
+
+// unlisten stops routing the authorization of state, and closes the
+// listener once nothing is pending. The caller must hold a.mu.
+func (a *Authorizer) unlisten(redirect, state string) {
+	u, err := url.Parse(redirect)
+	if err != nil {
+		return
+	}
+	l, exists := a.listeners[u.Host]
+	if !exists {
+		return
+	}
+	delete(l.pending, state)
+	if len(l.pending) == 0 {
+		// Let the browser have its answer
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = l.server.Shutdown(ctx)
+		delete(a.listeners, u.Host)
+	}
+}
+
+// receive hands an authorization the browser was redirected with to its flow
+func (a *Authorizer) receive(w http.ResponseWriter, r *http.Request, l *listener) {
+	query := r.URL.Query()
+	a.mu.Lock()
+	callbacks, exists := l.pending[query.Get("state")]
+	delete(l.pending, query.Get("state"))
+	a.mu.Unlock()
+	if !exists {
+		http.Error(w, "Unknown or expired authorization", http.StatusBadRequest)
+		return
+	}
+
+	result := callback{code: query.Get("code")}
+	if oauthErr := query.Get("error"); oauthErr != "" {
+		result.err = fmt.Errorf("authorization denied: %s %s", oauthErr, query.Get("error_description"))
+	} else if result.code == "" {
+		result.err = errors.New("authorization response carries no code")
+	}
+	callbacks <- result
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if result.err != nil {
+		fmt.Fprintf(w, "<p>lazy-mcp was not authorized: %s</p>", html.EscapeString(result.err.Error()))
+		return
+	}
+	fmt.Fprint(w, "<p>lazy-mcp is authorized. You can close this window.</p>")
+}
+
+// openBrowser opens url in the user's browser
+func openBrowser(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	default:
+		return exec.Command("xdg-open", url).Start()
+	}
+}
@@ -0,0 +1,137 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// newAuthServer starts an authorization server that registers clients,
+// grants every authorization, and issues access-<n> tokens
+func newAuthServer(t *testing.T) *httptest.Server {
+	issued := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/register", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]string{"client_id": "registered-client"})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		assert.Equal(t, "registered-client", query.Get("client_id"))
+		assert.Equal(t, "S256", query.Get("code_challenge_method"))
+		http.Redirect(w, r, query.Get("redirect_uri")+"?code=the-code&state="+url.QueryEscape(query.Get("state")), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			assert.Equal(t, "the-code", r.Form.Get("code"))
+			assert.NotEmpty(t, r.Form.Get("code_verifier"))
+		case "refresh_token":
+			assert.Equal(t, "refresh", r.Form.Get("refresh_token"))
+		}
+		issued++
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("access-%d", issued),
+			"token_type":    "bearer",
+			"refresh_token": "refresh",
+			"expires_in":    3600,
+		})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// freeRedirectURI returns a localhost redirect URI on a port nothing listens on
+func freeRedirectURI(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return "http://" + ln.Addr().String() + "/oauth/callback"
+}
+
+// TestAuthorizerRegistersAndStoresTokens verifies that authorizing registers
+// a client, exchanges the code the browser is redirected with for tokens,
+// and keeps both for later connections, which refresh expired tokens.
+func TestAuthorizerRegistersAndStoresTokens(t *testing.T) {
+	srv := newAuthServer(t)
+	cfg := &config.OAuthConfig{
+		RedirectURI: freeRedirectURI(t),
+		TokenFile:   filepath.Join(t.TempDir(), "oauth", "remote.json"),
+	}
+	ctx := context.Background()
+
+	handler, err := NewHandler(ctx, "remote", &config.MCPClientConfigV2{URL: srv.URL + "/mcp", OAuth: cfg})
+	require.NoError(t, err)
+	authorizer := NewAuthorizer()
+	defer authorizer.Close()
+	authorizer.openBrowser = func(authURL string) error {
+		// The user grants access, and the browser follows the redirect back
+		go func() {
+			resp, err := http.Get(authURL)
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+			}
+		}()
+		return nil
+	}
+	authURL, err := authorizer.Start(ctx, "remote", cfg, handler)
+	require.NoError(t, err)
+	again, err := authorizer.Start(ctx, "remote", cfg, handler)
+	require.NoError(t, err)
+	assert.Equal(t, authURL, again, "a flow underway is not started twice")
+	require.NoError(t, authorizer.Wait(ctx, "remote"))
+
+	info, err := os.Stat(cfg.TokenFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// A later connection uses the stored registration and token
+	oauthConfig, err := TransportConfig(ctx, "remote", cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "registered-client", oauthConfig.ClientID)
+	token, err := oauthConfig.TokenStore.GetToken(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "access-1", token.AccessToken)
+
+	// and refreshes the token once it expires
+	token.ExpiresAt = time.Now().Add(-time.Minute)
+	require.NoError(t, oauthConfig.TokenStore.SaveToken(ctx, token))
+	handler, err = NewHandler(ctx, "remote", &config.MCPClientConfigV2{URL: srv.URL + "/mcp", OAuth: cfg})
+	require.NoError(t, err)
+	header, err := handler.GetAuthorizationHeader(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer access-2", header)
+}
+
+// TestStoreWithoutTokenReportsNoToken verifies that an empty store reports
+// transport.ErrNoToken, as the transports expect before authorizing.
+func TestStoreWithoutTokenReportsNoToken(t *testing.T) {
+	store, err := NewStore("remote", &config.OAuthConfig{TokenFile: filepath.Join(t.TempDir(), "remote.json")})
+	require.NoError(t, err)
+	_, err = store.GetToken(context.Background())
+	assert.True(t, errors.Is(err, transport.ErrNoToken))
+
+	require.NoError(t, store.SaveClient(context.Background(), "id", "secret"))
+	_, err = store.GetToken(context.Background())
+	assert.True(t, errors.Is(err, transport.ErrNoToken), "a registration alone is no token")
+
+	_, err = NewStore("remote", &config.OAuthConfig{Store: "vault"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,214 @@
+// Package oauth authorizes lazy-mcp with remote MCP servers that require
+// OAuth, per the MCP authorization spec: lazy-mcp registers itself as a client
+// if it has no client ID, the user grants access once in the browser, and the
+// registration and tokens are kept so that later connections, and token
+// refreshes, need no one's attention.
+package oauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// keychainService names the keychain items of lazy-mcp
+const keychainService = "lazy-mcp"
+
+// storeMu serializes updates of stores, which rewrite the whole record
+var storeMu sync.Mutex
+
+// record is what a Store keeps for a server
+type record struct {
+	ClientID     string           `json:"client_id,omitempty"`
+	ClientSecret string           `json:"client_secret,omitempty"`
+	Token        *transport.Token `json:"token,omitempty"`
+}
+
+// backend reads and writes a record; load fails with fs.ErrNotExist if there
+// is none yet
+type backend interface {
+	load() ([]byte, error)
+	save(data []byte) error
+}
+
+// Store keeps the client registration and tokens of a server, in a file or
+// the keychain. It implements transport.TokenStore.
+type Store struct {
+	backend backend
+}
+
+// NewStore returns the store the given server's oauth section configures
+func NewStore(server string, cfg *config.OAuthConfig) (*Store, error) {
+	switch cfg.Store {
+	case "", config.OAuthStoreFile:
+		path := cfg.TokenFile
+		if path == "" {
+			dir, err := os.UserConfigDir()
+			if err != nil {
+				return nil, fmt.Errorf("failed to locate the token file: %w", err)
+			}
+			path = filepath.Join(dir, "lazy-mcp", "oauth", server+".json")
+		}
+		return &Store{backend: fileBackend(path)}, nil
+	case config.OAuthStoreKeychain:
+		return &Store{backend: keychainBackend(server)}, nil
+	}
+	return nil, fmt.Errorf("unknown oauth store %q", cfg.Store)
+}
+
+func (s *Store) load(ctx context.Context) (record, error) {
+	var rec record
+	if err := ctx.Err(); err != nil {
+		return rec, err
+	}
+	data, err := s.backend.load()
+	if errors.Is(err, fs.ErrNotExist) {
+		return rec, nil
+	}
+	if err != nil {
+		return rec, err
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return rec, fmt.Errorf("failed to read oauth store: %w", err)
+	}
+	return rec, nil
+}
+
+// update applies change to the stored record
+func (s *Store) update(ctx context.Context, change func(*record)) error {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	rec, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+	change(&rec)
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.backend.save(data)
+}
+
+// GetToken returns the stored token, or transport.ErrNoToken
+func (s *Store) GetToken(ctx context.Context) (*transport.Token, error) {
+	rec, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Token == nil {
+		return nil, transport.ErrNoToken
+	}
+	return rec.Token, nil
+}
+
+// SaveToken stores token
+func (s *Store) SaveToken(ctx context.Context, token *transport.Token) error {
+	return s.update(ctx, func(rec *record) {
+		rec.Token = token
+	})
+}
+
+// Client returns the stored client registration, empty if there is none
+func (s *Store) Client(ctx context.Context) (id, secret string, err error) {
+	rec, err := s.load(ctx)
+	return rec.ClientID, rec.ClientSecret, err
+}
+
+// SaveClient stores a client registration
+func (s *Store) SaveClient(ctx context.Context, id, secret string) error {
+	return s.update(ctx, func(rec *record) {
+		rec.ClientID, rec.ClientSecret = id, secret
+	})
+}
+
+// fileBackend keeps the record in a file only the user can read
+type fileBackend string
+
+func (f fileBackend) load() ([]byte, error) {
+	return os.ReadFile(string(f))
+}
+
+func (f fileBackend) save(data []byte) error {
+	dir := filepath.Dir(string(f))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create token directory: %w", err)
+	}
+	// Written aside and renamed, so that a crash leaves the old tokens intact
+	tmp, err := os.CreateTemp(dir, filepath.Base(string(f))+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), string(f)); err != nil {
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	return nil
+}
+
+// keychainBackend keeps the record of a server in the macOS keychain, or in
+// the Secret Service on Linux
+type keychainBackend string
+
+func (k keychainBackend) load() ([]byte, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keychainService, "-a", string(k), "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", keychainService, "account", string(k))
+	default:
+		return nil, fmt.Errorf("the keychain oauth store is not supported on %s", runtime.GOOS)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// Both tools exit with an error if there is no such item
+			return nil, fs.ErrNotExist
+		}
+		return nil, fmt.Errorf("failed to read keychain: %w", err)
+	}
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return nil, fs.ErrNotExist
+	}
+	return out, nil
+}
+
+func (k keychainBackend) save(data []byte) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// security only takes the secret as an argument
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", keychainService, "-a", string(k), "-w", string(data))
+	case "linux":
+		cmd = exec.Command("secret-tool", "store", "--label", "lazy-mcp: "+string(k), "service", keychainService, "account", string(k))
+		cmd.Stdin = bytes.NewReader(data)
+	default:
+		return fmt.Errorf("the keychain oauth store is not supported on %s", runtime.GOOS)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to write keychain: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
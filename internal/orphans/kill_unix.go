@@ -0,0 +1,21 @@
+//go:build unix
+
+package orphans
+
+import (
+	"errors"
+	"syscall"
+)
+
+// killProcessGroup kills process pid and, if it leads a process group, as
+// lazy-mcp's children do, the processes of its group
+func killProcessGroup(pid int) error {
+	target := pid
+	if group, err := syscall.Getpgid(pid); err == nil && group == pid {
+		target = -pid
+	}
+	if err := syscall.Kill(target, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+	return nil
+}
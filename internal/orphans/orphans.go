@@ -0,0 +1,246 @@
+// Package orphans keeps track of the processes lazy-mcp spawns, in a state
+// file per running lazy-mcp, so that those a crashed lazy-mcp left behind can
+// be found and killed, by the next lazy-mcp to start or by `mcp-proxy
+// cleanup`. Processes are told apart from later ones reusing their PIDs by
+// their start times, which are known on Linux, macOS and Windows only.
+package orphans
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// errUnsupported is returned for the start times of processes on platforms
+// lazy-mcp cannot read them on
+var errUnsupported = errors.New("process start times are not supported on this platform")
+
+// child is a process lazy-mcp spawned, as its state file records it
+type child struct {
+	Server    string `json:"server"`
+	PID       int    `json:"pid"`
+	Start     uint64 `json:"start"`
+	Container string `json:"container,omitempty"` // Docker container the process runs, if any
+}
+
+// state is the content of a lazy-mcp's state file
+type state struct {
+	PID      int     `json:"pid"`
+	Start    uint64  `json:"start"`
+	Children []child `json:"children"`
+}
+
+// Dir returns the directory of the state files, lazy-mcp/run in the user's
+// cache directory
+func Dir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the process state files: %w", err)
+	}
+	return filepath.Join(dir, "lazy-mcp", "run"), nil
+}
+
+// ownStart is the start time of this lazy-mcp
+var ownStart = sync.OnceValues(func() (uint64, error) {
+	return processStart(os.Getpid())
+})
+
+// Tracker records the running children of this lazy-mcp in its state file,
+// which it removes whenever there are none
+type Tracker struct {
+	dir string // Dir() if empty
+
+	mu       sync.Mutex
+	children map[int]child
+}
+
+// Default is the tracker of lazy-mcp's servers
+var Default = &Tracker{}
+
+// NewTracker returns a tracker keeping its state file in dir
+func NewTracker(dir string) *Tracker {
+	return &Tracker{dir: dir}
+}
+
+// Track records the child process pid of server, which runs the named Docker
+// container if container is not empty. The returned func forgets it, and is
+// to be called once it has exited. Nothing is recorded on platforms where
+// orphans could not be told apart from later processes.
+func (t *Tracker) Track(server string, pid int, container string) (func(), error) {
+	start, err := processStart(pid)
+	if errors.Is(err, errUnsupported) || errors.Is(err, os.ErrProcessDone) {
+		return func() {}, nil
+	}
+	if err != nil {
+		return func() {}, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.children == nil {
+		t.children = make(map[int]child)
+	}
+	t.children[pid] = child{Server: server, PID: pid, Start: start, Container: container}
+	if err := t.save(); err != nil {
+		delete(t.children, pid)
+		return func() {}, err
+	}
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.children, pid)
+		_ = t.save()
+	}, nil
+}
+
+// save writes the state file, or removes it if there are no children. The
+// caller holds t.mu.
+func (t *Tracker) save() error {
+	dir := t.dir
+	if dir == "" {
+		var err error
+		if dir, err = Dir(); err != nil {
+			return err
+		}
+	}
+	path := filepath.Join(dir, strconv.Itoa(os.Getpid())+".json")
+	if len(t.children) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove process state file: %w", err)
+		}
+		return nil
+	}
+
+	start, err := ownStart()
+	if err != nil {
+		return err
+	}
+	s := state{PID: os.Getpid(), Start: start}
+	for _, c := range t.children {
+		s.Children = append(s.Children, c)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create process state directory: %w", err)
+	}
+	// Write then rename, so that a crash never leaves a truncated file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write process state file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write process state file: %w", err)
+	}
+	return nil
+}
+
+// Orphan is a process, or Docker container, that a lazy-mcp which is no
+// longer running left behind
+type Orphan struct {
+	Server    string
+	PID       int    // Zero for a container whose docker run has exited
+	Container string // Empty for a process that runs no container
+	Owner     int    // PID of the lazy-mcp that started it
+}
+
+func (o Orphan) String() string {
+	if o.PID == 0 {
+		return fmt.Sprintf("container %s of %s, started by lazy-mcp %d", o.Container, o.Server, o.Owner)
+	}
+	if o.Container != "" {
+		return fmt.Sprintf("process %d of %s, running container %s, started by lazy-mcp %d", o.PID, o.Server, o.Container, o.Owner)
+	}
+	return fmt.Sprintf("process %d of %s, started by lazy-mcp %d", o.PID, o.Server, o.Owner)
+}
+
+// Cleanup finds the orphans recorded in the state files in dir by lazy-mcps
+// that are no longer running and, unless dryRun, kills them along with their
+// process groups, removes their containers and deletes those state files.
+// It returns the orphans found, and the errors met cleaning them up.
+func Cleanup(dir string, dryRun bool) ([]Orphan, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read process state files: %w", err)
+	}
+
+	var orphans []Orphan
+	var errs []error
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".json") || name == strconv.Itoa(os.Getpid())+".json" {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var s state
+		if err := json.Unmarshal(data, &s); err != nil {
+			errs = append(errs, fmt.Errorf("invalid process state file %s: %w", path, err))
+			continue
+		}
+		if running(s.PID, s.Start) {
+			continue
+		}
+
+		for _, c := range s.Children {
+			orphan := Orphan{Server: c.Server, Container: c.Container, Owner: s.PID}
+			if running(c.PID, c.Start) {
+				orphan.PID = c.PID
+			}
+			if c.Container != "" && !containerExists(c.Container) {
+				orphan.Container = ""
+			}
+			if orphan.PID == 0 && orphan.Container == "" {
+				continue
+			}
+			orphans = append(orphans, orphan)
+			if dryRun {
+				continue
+			}
+			if orphan.PID != 0 {
+				if err := killProcessGroup(orphan.PID); err != nil {
+					errs = append(errs, fmt.Errorf("failed to kill process %d of %s: %w", orphan.PID, orphan.Server, err))
+				}
+			}
+			if orphan.Container != "" {
+				if out, err := exec.Command("docker", "rm", "-f", orphan.Container).CombinedOutput(); err != nil {
+					errs = append(errs, fmt.Errorf("failed to remove container %s of %s: %w: %s", orphan.Container, orphan.Server, err, strings.TrimSpace(string(out))))
+				}
+			}
+		}
+		if !dryRun {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return orphans, errors.Join(errs...)
+}
+
+// running reports whether the process pid that started at start is still
+// running, rather than having exited, its PID possibly reused since
+func running(pid int, start uint64) bool {
+	current, err := processStart(pid)
+	return err == nil && current == start
+}
+
+// containerExists reports whether the named Docker container exists
+func containerExists(container string) bool {
+	return exec.Command("docker", "container", "inspect", "--format", "{{.Id}}", container).Run() == nil
+}
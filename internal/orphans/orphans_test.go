@@ -0,0 +1,111 @@
+//go:build linux || darwin
+
+package orphans
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startSleep starts a process that runs until killed, in a process group of
+// its own as lazy-mcp's children are
+func startSleep(t *testing.T) *exec.Cmd {
+	cmd := exec.Command("sleep", "60")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	return cmd
+}
+
+// exitedPID returns the PID of a process that has exited
+func exitedPID(t *testing.T) int {
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Run())
+	return cmd.Process.Pid
+}
+
+func writeState(t *testing.T, dir string, s state) string {
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+	path := filepath.Join(dir, strconv.Itoa(s.PID)+".json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+// TestTrackerKeepsStateWhileChildrenRun verifies that the state file lists
+// the running children, and is removed once none are left.
+func TestTrackerKeepsStateWhileChildrenRun(t *testing.T) {
+	dir := t.TempDir()
+	tracker := NewTracker(dir)
+	first, second := startSleep(t), startSleep(t)
+
+	untrackFirst, err := tracker.Track("fs", first.Process.Pid, "")
+	require.NoError(t, err)
+	untrackSecond, err := tracker.Track("github", second.Process.Pid, "lazy-mcp-github-1234")
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, strconv.Itoa(os.Getpid())+".json")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var s state
+	require.NoError(t, json.Unmarshal(data, &s))
+	assert.Equal(t, os.Getpid(), s.PID)
+	assert.NotZero(t, s.Start)
+	assert.ElementsMatch(t, []string{"fs", "github"}, []string{s.Children[0].Server, s.Children[1].Server})
+
+	untrackFirst()
+	require.FileExists(t, path)
+	untrackSecond()
+	assert.NoFileExists(t, path)
+}
+
+// TestCleanupKillsOrphans verifies that the children of a lazy-mcp that is no
+// longer running are killed, and its state file removed, but that those of
+// running ones, and processes that reused the PIDs of exited ones, are left
+// alone.
+func TestCleanupKillsOrphans(t *testing.T) {
+	dir := t.TempDir()
+	orphan, reused, owner, owned := startSleep(t), startSleep(t), startSleep(t), startSleep(t)
+	start := func(cmd *exec.Cmd) uint64 {
+		start, err := processStart(cmd.Process.Pid)
+		require.NoError(t, err)
+		return start
+	}
+
+	deadOwner := exitedPID(t)
+	deadState := writeState(t, dir, state{PID: deadOwner, Start: 1, Children: []child{
+		{Server: "fs", PID: orphan.Process.Pid, Start: start(orphan)},
+		{Server: "github", PID: reused.Process.Pid, Start: start(reused) + 1},
+	}})
+	liveState := writeState(t, dir, state{PID: owner.Process.Pid, Start: start(owner), Children: []child{
+		{Server: "fs", PID: owned.Process.Pid, Start: start(owned)},
+	}})
+
+	found, err := Cleanup(dir, true)
+	require.NoError(t, err)
+	assert.Equal(t, []Orphan{{Server: "fs", PID: orphan.Process.Pid, Owner: deadOwner}}, found)
+	assert.FileExists(t, deadState)
+	assert.True(t, running(orphan.Process.Pid, start(orphan)), "a dry run kills nothing")
+
+	found, err = Cleanup(dir, false)
+	require.NoError(t, err)
+	assert.Equal(t, []Orphan{{Server: "fs", PID: orphan.Process.Pid, Owner: deadOwner}}, found)
+	assert.Error(t, orphan.Wait(), "the orphan was killed")
+	assert.NoFileExists(t, deadState)
+	assert.FileExists(t, liveState)
+	for _, cmd := range []*exec.Cmd{reused, owner, owned} {
+		_, err := processStart(cmd.Process.Pid)
+		assert.NoError(t, err, "process %d is still running", cmd.Process.Pid)
+	}
+}
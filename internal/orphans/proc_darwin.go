@@ -0,0 +1,28 @@
+package orphans
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// zombieState is the p_stat of a process that exited but was not waited for
+const zombieState = 5
+
+// processStart returns the start time of process pid, in microseconds since
+// the epoch, or os.ErrProcessDone if it is not running
+func processStart(pid int) (uint64, error) {
+	info, err := unix.SysctlKinfoProc("kern.proc.pid", pid)
+	if err != nil {
+		if errors.Is(unix.Kill(pid, 0), unix.ESRCH) {
+			return 0, os.ErrProcessDone
+		}
+		return 0, err
+	}
+	if int(info.Proc.P_pid) != pid || info.Proc.P_stat == zombieState {
+		return 0, os.ErrProcessDone
+	}
+	start := info.Proc.P_starttime
+	return uint64(start.Sec)*1e6 + uint64(start.Usec), nil
+}
@@ -0,0 +1,33 @@
+package orphans
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+)
+
+// processStart returns the start time of process pid, in clock ticks since
+// boot, or os.ErrProcessDone if it is not running
+func processStart(pid int) (uint64, error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, os.ErrProcessDone
+	}
+	if err != nil {
+		return 0, err
+	}
+	// The command name, in parentheses, may contain spaces; the state is the
+	// third field, and the start time the 22nd
+	end := bytes.LastIndexByte(stat, ')')
+	fields := bytes.Fields(stat[end+1:])
+	if end < 0 || len(fields) < 20 {
+		return 0, fmt.Errorf("unexpected format of /proc/%d/stat", pid)
+	}
+	if state := string(fields[0]); state == "Z" || state == "X" {
+		return 0, os.ErrProcessDone
+	}
+	return strconv.ParseUint(string(fields[19]), 10, 64)
+}
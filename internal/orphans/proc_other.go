@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package orphans
+
+// processStart is not supported on this platform
+func processStart(pid int) (uint64, error) {
+	return 0, errUnsupported
+}
@@ -0,0 +1,51 @@
+package orphans
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// stillActive is the exit code of processes that have not exited
+const stillActive = 259
+
+// processStart returns the creation time of process pid, in 100-nanosecond
+// intervals since 1601, or os.ErrProcessDone if it is not running
+func processStart(pid int) (uint64, error) {
+	process, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if errors.Is(err, windows.ERROR_INVALID_PARAMETER) {
+		return 0, os.ErrProcessDone
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseHandle(process)
+
+	var exitCode uint32
+	if err := windows.GetExitCodeProcess(process, &exitCode); err != nil {
+		return 0, err
+	}
+	if exitCode != stillActive {
+		return 0, os.ErrProcessDone
+	}
+	var creation, exit, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(process, &creation, &exit, &kernel, &user); err != nil {
+		return 0, err
+	}
+	return uint64(creation.HighDateTime)<<32 | uint64(creation.LowDateTime), nil
+}
+
+// killProcessGroup kills process pid. The processes it started are killed
+// along with it by the Job Object lazy-mcp put it in, if any.
+func killProcessGroup(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	defer process.Release()
+	if err := process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
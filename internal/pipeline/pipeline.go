@@ -0,0 +1,251 @@
+// Package pipeline fills in the arguments of the steps of pipeline tools,
+// which chain tool calls, from the pipeline's input and the outputs of the
+// steps before them.
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Input is the root of the references to a pipeline's own arguments
+const Input = "input"
+
+// reference matches a reference in an argument, such as {{search.items[0].id}}
+var reference = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
+
+// segmentPattern matches one field of a path with its indexes, as in items[0]
+var segmentPattern = regexp.MustCompile(`^([^.\[\]]+)((?:\[\d+\])*)$`)
+
+// Path refers to a value in the input or the output of a step: the root,
+// input or a step's name, followed by the fields and indexes to look up in
+// it, as in search.items[0].id. A leading $. is allowed, as in JSONPath.
+type Path struct {
+	Root string
+	raw  string
+	// Each a field name, or an index into an array
+	segments []any
+}
+
+// ParsePath parses a path such as search.items[0].id
+func ParsePath(s string) (Path, error) {
+	p := Path{raw: s}
+	trimmed := strings.TrimPrefix(s, "$.")
+	if trimmed == "" {
+		return p, fmt.Errorf("empty reference")
+	}
+	for i, part := range strings.Split(trimmed, ".") {
+		match := segmentPattern.FindStringSubmatch(part)
+		if match == nil {
+			return p, fmt.Errorf("invalid reference %q", s)
+		}
+		if i == 0 {
+			p.Root = match[1]
+		} else {
+			p.segments = append(p.segments, match[1])
+		}
+		for _, index := range strings.Split(strings.Trim(match[2], "[]"), "][") {
+			if index == "" {
+				continue
+			}
+			n, err := strconv.Atoi(index)
+			if err != nil {
+				return p, fmt.Errorf("invalid reference %q", s)
+			}
+			p.segments = append(p.segments, n)
+		}
+	}
+	return p, nil
+}
+
+func (p Path) String() string {
+	return p.raw
+}
+
+// Lookup returns the value the path refers to among vars, keyed by root
+func (p Path) Lookup(vars map[string]any) (any, error) {
+	value, ok := vars[p.Root]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown step %q", p, p.Root)
+	}
+	for _, segment := range p.segments {
+		switch key := segment.(type) {
+		case string:
+			object, ok := value.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: cannot look up %q in %s", p, key, kind(value))
+			}
+			if value, ok = object[key]; !ok {
+				return nil, fmt.Errorf("%s: no field %q", p, key)
+			}
+		case int:
+			array, ok := value.([]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: cannot index %s", p, kind(value))
+			}
+			if key >= len(array) {
+				return nil, fmt.Errorf("%s: index %d out of range of %d items", p, key, len(array))
+			}
+			value = array[key]
+		}
+	}
+	return value, nil
+}
+
+// kind names the JSON type of value for errors
+func kind(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	default:
+		return "a number"
+	}
+}
+
+// Expand returns value, an argument or all of a step's arguments, with the
+// references in its strings filled in from vars. A string that is only a
+// reference is replaced by the value referred to, whatever its type; other
+// references are written into their string, as JSON unless they are strings
+// themselves.
+func Expand(value any, vars map[string]any) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		expanded := make(map[string]any, len(v))
+		for key, item := range v {
+			item, err := Expand(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			expanded[key] = item
+		}
+		return expanded, nil
+	case []any:
+		expanded := make([]any, len(v))
+		for i, item := range v {
+			item, err := Expand(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			expanded[i] = item
+		}
+		return expanded, nil
+	case string:
+		return expandString(v, vars)
+	default:
+		return value, nil
+	}
+}
+
+func expandString(s string, vars map[string]any) (any, error) {
+	matches := reference.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) {
+		path, err := ParsePath(s[matches[0][2]:matches[0][3]])
+		if err != nil {
+			return nil, err
+		}
+		return path.Lookup(vars)
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		b.WriteString(s[last:match[0]])
+		last = match[1]
+		path, err := ParsePath(s[match[2]:match[3]])
+		if err != nil {
+			return nil, err
+		}
+		value, err := path.Lookup(vars)
+		if err != nil {
+			return nil, err
+		}
+		if text, ok := value.(string); ok {
+			b.WriteString(text)
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		b.Write(data)
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// References returns the paths referred to in the strings of value
+func References(value any) ([]Path, error) {
+	var paths []Path
+	var walk func(value any) error
+	walk = func(value any) error {
+		switch v := value.(type) {
+		case map[string]any:
+			for _, item := range v {
+				if err := walk(item); err != nil {
+					return err
+				}
+			}
+		case []any:
+			for _, item := range v {
+				if err := walk(item); err != nil {
+					return err
+				}
+			}
+		case string:
+			for _, match := range reference.FindAllStringSubmatch(v, -1) {
+				path, err := ParsePath(match[1])
+				if err != nil {
+					return err
+				}
+				paths = append(paths, path)
+			}
+		}
+		return nil
+	}
+	err := walk(value)
+	return paths, err
+}
+
+// Output returns what later steps may refer to of a step's result: its
+// structured content if it has any, else its text parsed as JSON, else the
+// text as it is
+func Output(result *mcp.CallToolResult) any {
+	if result.StructuredContent != nil {
+		// As decoded from JSON, whatever type the tool returned it as
+		data, err := json.Marshal(result.StructuredContent)
+		if err == nil {
+			var output any
+			if json.Unmarshal(data, &output) == nil {
+				return output
+			}
+		}
+	}
+	var texts []string
+	for _, content := range result.Content {
+		if text, ok := mcp.AsTextContent(content); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	text := strings.Join(texts, "\n")
+	var output any
+	if json.Unmarshal([]byte(text), &output) == nil {
+		return output
+	}
+	return text
+}
@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpand(t *testing.T) {
+	vars := map[string]any{
+		"input": map[string]any{"query": "lazy", "limit": float64(3)},
+		"search": map[string]any{"items": []any{
+			map[string]any{"id": float64(42), "labels": []any{"bug"}},
+		}},
+	}
+
+	expanded, err := Expand(map[string]any{
+		"id":      "{{search.items[0].id}}",
+		"labels":  "{{ $.search.items[0].labels }}",
+		"title":   "Issue {{search.items[0].id}} for {{input.query}}",
+		"nested":  []any{"{{input.limit}}", "plain"},
+		"literal": true,
+	}, vars)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"id":      float64(42),
+		"labels":  []any{"bug"},
+		"title":   "Issue 42 for lazy",
+		"nested":  []any{float64(3), "plain"},
+		"literal": true,
+	}, expanded, "a whole reference keeps its type, one in a string is written into it")
+
+	for reference, problem := range map[string]string{
+		"{{missing.id}}":           `unknown step "missing"`,
+		"{{search.items[1].id}}":   "index 1 out of range of 1 items",
+		"{{search.items.id}}":      `cannot look up "id" in an array`,
+		"{{search.total}}":         `no field "total"`,
+		"{{input.query[0]}}":       "cannot index a string",
+		"{{search..items}}":        "invalid reference",
+		"{{search.items[first]}}":  "invalid reference",
+		"before {{input.nope}} on": `no field "nope"`,
+	} {
+		_, err := Expand(map[string]any{"value": reference}, vars)
+		assert.ErrorContains(t, err, problem, reference)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	paths, err := References(map[string]any{
+		"a": "{{input.query}} and {{search.items[0].id}}",
+		"b": []any{map[string]any{"c": "{{fetch}}"}},
+		"d": "no reference",
+	})
+	require.NoError(t, err)
+	var roots []string
+	for _, path := range paths {
+		roots = append(roots, path.Root)
+	}
+	assert.ElementsMatch(t, []string{"input", "search", "fetch"}, roots)
+}
+
+func TestOutput(t *testing.T) {
+	structured := mcp.NewToolResultStructured(struct {
+		Items []int `json:"items"`
+	}{Items: []int{1}}, "1 item")
+	assert.Equal(t, map[string]any{"items": []any{float64(1)}}, Output(structured))
+
+	assert.Equal(t, map[string]any{"id": float64(7)}, Output(mcp.NewToolResultText(`{"id": 7}`)), "JSON text is parsed")
+	assert.Equal(t, "created issue 7", Output(mcp.NewToolResultText("created issue 7")))
+}
@@ -0,0 +1,142 @@
+// Package redact masks secrets, such as tokens passed as tool arguments,
+// before they are logged or written to the audit log.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// Mask replaces redacted values
+const Mask = "[REDACTED]"
+
+// DefaultKeys are the keys whose values are always redacted, unless
+// redaction is disabled
+var DefaultKeys = []string{"token", "password", "apiKey"}
+
+// defaultPatterns mask the secrets of DefaultKeys, and bearer tokens, within
+// text such as error messages and server output
+var defaultPatterns = []string{
+	`(?i)\bbearer\s+([A-Za-z0-9._~+/-]+=*)`,
+	`(?i)(?:token|password|api[_-]?key)["']?\s*[:=]\s*["']?([^\s"'&,;]+)`,
+}
+
+// Redactor masks the values of sensitive keys, and the matches of patterns
+// within text. A nil *Redactor masks nothing.
+type Redactor struct {
+	keys     []string // Normalized
+	patterns []*regexp.Regexp
+}
+
+// New returns the redactor configured by cfg: the default keys and patterns,
+// and those of cfg. It returns nil if redaction is disabled.
+func New(cfg *config.RedactionConfig) (*Redactor, error) {
+	if cfg != nil && cfg.Disabled {
+		return nil, nil
+	}
+	r := &Redactor{}
+	keys, patterns := DefaultKeys, defaultPatterns
+	if cfg != nil {
+		keys = append(keys[:len(keys):len(keys)], cfg.Keys...)
+		patterns = append(patterns[:len(patterns):len(patterns)], cfg.Patterns...)
+	}
+	for _, key := range keys {
+		r.keys = append(r.keys, normalize(key))
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// normalize makes "api_key", "API-Key" and "apiKey" alike
+func normalize(key string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+}
+
+// SensitiveKey reports whether the values of key are secret: its name is, or
+// ends with, one of the keys, so that "githubToken" is but "tokens" is not
+func (r *Redactor) SensitiveKey(key string) bool {
+	if r == nil {
+		return false
+	}
+	key = normalize(key)
+	for _, sensitive := range r.keys {
+		if strings.HasSuffix(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// String masks the matches of the patterns within s. Of patterns with
+// groups, only the groups are masked, so that "token=abc" becomes
+// "token=[REDACTED]".
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, re := range r.patterns {
+		if re.NumSubexp() == 0 {
+			s = re.ReplaceAllLiteralString(s, Mask)
+			continue
+		}
+		s = re.ReplaceAllStringFunc(s, func(match string) string {
+			// Mask the groups of this match, from the last so that indexes hold
+			loc := re.FindStringSubmatchIndex(match)
+			for i := len(loc)/2 - 1; i > 0; i-- {
+				if start, end := loc[2*i], loc[2*i+1]; start >= 0 {
+					match = match[:start] + Mask + match[end:]
+				}
+			}
+			return match
+		})
+	}
+	return s
+}
+
+// Value returns v, found under key, with its secrets masked: all of it if key
+// is sensitive, else the values of sensitive keys of maps and the matches of
+// patterns in strings, at any depth. v itself is not modified.
+func (r *Redactor) Value(key string, v interface{}) interface{} {
+	if r == nil {
+		return v
+	}
+	if r.SensitiveKey(key) {
+		return Mask
+	}
+	switch v := v.(type) {
+	case string:
+		return r.String(v)
+	case map[string]interface{}:
+		return r.Map(v)
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, elem := range v {
+			redacted[i] = r.Value("", elem)
+		}
+		return redacted
+	case error:
+		return r.String(v.Error())
+	}
+	return v
+}
+
+// Map returns a copy of m with its secrets masked, as Value does
+func (r *Redactor) Map(m map[string]interface{}) map[string]interface{} {
+	if r == nil || m == nil {
+		return m
+	}
+	redacted := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		redacted[key] = r.Value(key, value)
+	}
+	return redacted
+}
@@ -0,0 +1,66 @@
+package redact
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestValueMasksSensitiveKeysAtAnyDepth verifies that the values of the
+// default and configured keys are masked wherever they are nested, matching
+// regardless of case and separators, and that the input is left unchanged.
+func TestValueMasksSensitiveKeysAtAnyDepth(t *testing.T) {
+	r, err := New(&config.RedactionConfig{Keys: []string{"secret"}})
+	require.NoError(t, err)
+
+	arguments := map[string]interface{}{
+		"query":        "open issues",
+		"api_key":      "sk-123",
+		"githubToken":  "ghp_abc",
+		"max_tokens":   100,
+		"clientSecret": "hush",
+		"auth":         map[string]interface{}{"Password": "hunter2", "user": "bob"},
+		"steps":        []interface{}{map[string]interface{}{"TOKEN": "t"}},
+	}
+	assert.Equal(t, map[string]interface{}{
+		"query":        "open issues",
+		"api_key":      Mask,
+		"githubToken":  Mask,
+		"max_tokens":   100,
+		"clientSecret": Mask,
+		"auth":         map[string]interface{}{"Password": Mask, "user": "bob"},
+		"steps":        []interface{}{map[string]interface{}{"TOKEN": Mask}},
+	}, r.Map(arguments))
+	assert.Equal(t, "sk-123", arguments["api_key"], "the input must not be modified")
+}
+
+// TestStringMasksPatternMatches verifies that the default patterns mask
+// secrets within text but keep what names them, and that configured
+// patterns without groups mask their whole match.
+func TestStringMasksPatternMatches(t *testing.T) {
+	r, err := New(&config.RedactionConfig{Patterns: []string{`\bsk-[A-Za-z0-9]+`}})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Authorization: Bearer [REDACTED]", r.String("Authorization: Bearer eyJhbGciOi.J9"))
+	assert.Equal(t, `connect failed: password=[REDACTED] user=bob`, r.String("connect failed: password=hunter2 user=bob"))
+	assert.Equal(t, `{"apiKey": "[REDACTED]"}`, r.String(`{"apiKey": "abc123"}`))
+	assert.Equal(t, "using key [REDACTED]", r.String("using key sk-live42"))
+	assert.Equal(t, "nothing to hide", r.String("nothing to hide"))
+	assert.Equal(t, "bad token: [REDACTED]", r.Value("error", errors.New("bad token: abc")))
+
+	_, err = New(&config.RedactionConfig{Patterns: []string{"("}})
+	assert.Error(t, err)
+}
+
+// TestDisabledRedactsNothing verifies that a disabled redactor is nil, which
+// passes everything through.
+func TestDisabledRedactsNothing(t *testing.T) {
+	r, err := New(&config.RedactionConfig{Disabled: true})
+	require.NoError(t, err)
+	assert.Nil(t, r)
+	assert.Equal(t, "token=abc", r.String("token=abc"))
+	assert.Equal(t, "abc", r.Value("token", "abc"))
+}
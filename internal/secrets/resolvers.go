@@ -0,0 +1,150 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// resolveKeychain reads keychain:<service>[#<account>] from the macOS
+// keychain, or the Secret Service on Linux
+func resolveKeychain(ctx context.Context, ref string) (string, error) {
+	service, account := splitField(ref)
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		args := []string{"find-generic-password", "-s", service, "-w"}
+		if account != "" {
+			args = append(args, "-a", account)
+		}
+		cmd = exec.CommandContext(ctx, "security", args...)
+	case "linux":
+		args := []string{"lookup", "service", service}
+		if account != "" {
+			args = append(args, "account", account)
+		}
+		cmd = exec.CommandContext(ctx, "secret-tool", args...)
+	default:
+		return "", fmt.Errorf("the keychain is not supported on %s", runtime.GOOS)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// Both tools exit with an error if there is no such item
+			return "", fmt.Errorf("no keychain item for service %q", service)
+		}
+		return "", fmt.Errorf("failed to read keychain: %w", err)
+	}
+	secret := strings.TrimRight(string(out), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("no keychain item for service %q", service)
+	}
+	return secret, nil
+}
+
+// vaultTimeout bounds a request to Vault
+const vaultTimeout = 10 * time.Second
+
+// resolveVault reads vault:<path>[#<field>] with Vault's HTTP API, at
+// VAULT_ADDR with VAULT_TOKEN or the token of ~/.vault-token, and in
+// VAULT_NAMESPACE if set. The path is that of the API, e.g. kv/data/mcp for
+// version 2 of the KV secrets engine.
+func resolveVault(ctx context.Context, ref string) (string, error) {
+	secretPath, field := splitField(ref)
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			if data, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+				token = strings.TrimSpace(string(data))
+			}
+		}
+	}
+	if token == "" {
+		return "", fmt.Errorf("VAULT_TOKEN is not set, and there is no ~/.vault-token")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, vaultTimeout)
+	defer cancel()
+	url := strings.TrimRight(addr, "/") + "/v1/" + strings.TrimLeft(secretPath, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var secret struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", fmt.Errorf("invalid response from vault: %w", err)
+	}
+	fields := secret.Data
+	// Version 2 of the KV engine nests the secret under data, beside metadata
+	if nested, ok := fields["data"].(map[string]any); ok {
+		if _, ok := fields["metadata"]; ok {
+			fields = nested
+		}
+	}
+	return pickField(fields, field)
+}
+
+// resolveAWSSecretsManager reads aws-sm:<secret id or ARN>[#<field>] with
+// the AWS CLI, and its credentials. The field picks a key of a secret stored
+// as JSON; without one, the secret string is used whole.
+func resolveAWSSecretsManager(ctx context.Context, ref string) (string, error) {
+	secretID, field := splitField(ref)
+	args := []string{"secretsmanager", "get-secret-value", "--secret-id", secretID, "--query", "SecretString", "--output", "text"}
+	// The secret lives in the region of its ARN, whatever the default one
+	if parts := strings.Split(secretID, ":"); len(parts) > 3 && parts[0] == "arn" {
+		args = append(args, "--region", parts[3])
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "aws", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%w: %s", err, message)
+		}
+		return "", err
+	}
+	secret := strings.TrimRight(string(out), "\r\n")
+	if field == "" {
+		return secret, nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("the secret is not a JSON object, so it has no field %q", field)
+	}
+	return pickField(fields, field)
+}
@@ -0,0 +1,142 @@
+// Package secrets resolves the secret references of config values, such as
+// "!secret keychain:github-token", from the OS keychain and external secret
+// managers. References are resolved each time a server starts, so that
+// secrets are only fetched for the servers used, and rotated ones are picked
+// up on restart.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Prefix starts a secret reference, followed by the scheme of its resolver
+// and the reference proper, as in "!secret vault:kv/data/mcp#token"
+const Prefix = "!secret "
+
+// Resolver fetches the secret a reference points to. ref is what follows the
+// scheme and colon.
+type Resolver interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// ResolverFunc adapts a function to a Resolver
+type ResolverFunc func(ctx context.Context, ref string) (string, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+var (
+	mu        sync.RWMutex
+	resolvers = map[string]Resolver{
+		"keychain": ResolverFunc(resolveKeychain),
+		"vault":    ResolverFunc(resolveVault),
+		"aws-sm":   ResolverFunc(resolveAWSSecretsManager),
+	}
+)
+
+// Register makes resolver resolve the references of the given scheme, in
+// place of any registered before
+func Register(scheme string, resolver Resolver) {
+	mu.Lock()
+	defer mu.Unlock()
+	resolvers[scheme] = resolver
+}
+
+// Schemes returns the schemes of the registered resolvers, sorted
+func Schemes() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	schemes := make([]string, 0, len(resolvers))
+	for scheme := range resolvers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// IsRef reports whether value is a secret reference
+func IsRef(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// parse splits a secret reference into the resolver of its scheme and its
+// reference proper
+func parse(value string) (Resolver, string, error) {
+	scheme, ref, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(value, Prefix)), ":")
+	if !ok || ref == "" {
+		return nil, "", fmt.Errorf("secret reference %q is not of the form %s<scheme>:<ref>", value, Prefix)
+	}
+	mu.RLock()
+	resolver, ok := resolvers[scheme]
+	mu.RUnlock()
+	if !ok {
+		return nil, "", fmt.Errorf("unknown secret scheme %q, use %s", scheme, strings.Join(Schemes(), ", "))
+	}
+	return resolver, ref, nil
+}
+
+// Check returns why value is not a valid secret reference, without resolving
+// it, or nil if it is one or is no reference at all
+func Check(value string) error {
+	if !IsRef(value) {
+		return nil
+	}
+	_, _, err := parse(value)
+	return err
+}
+
+// Resolve returns the secret value refers to, or value itself if it is no
+// secret reference
+func Resolve(ctx context.Context, value string) (string, error) {
+	if !IsRef(value) {
+		return value, nil
+	}
+	resolver, ref, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	secret, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", strings.TrimSpace(value), err)
+	}
+	return secret, nil
+}
+
+// splitField splits the #field off a reference
+func splitField(ref string) (string, string) {
+	if i := strings.LastIndex(ref, "#"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+// pickField returns the field of a JSON object of secrets, or its only field
+// if none is named
+func pickField(fields map[string]any, field string) (string, error) {
+	if field == "" {
+		if len(fields) != 1 {
+			return "", fmt.Errorf("the secret has %d fields; name one with #<field>", len(fields))
+		}
+		for name := range fields {
+			field = name
+		}
+	}
+	value, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("the secret has no field %q", field)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,74 @@
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestResolve verifies that plain values pass through, that references go
+// to the resolver of their scheme, and that malformed references and unknown
+// schemes are reported.
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	value, err := Resolve(ctx, "plain value")
+	require.NoError(t, err)
+	assert.Equal(t, "plain value", value)
+
+	Register("test", ResolverFunc(func(ctx context.Context, ref string) (string, error) {
+		return "secret of " + ref, nil
+	}))
+	value, err = Resolve(ctx, "!secret test:db/password")
+	require.NoError(t, err)
+	assert.Equal(t, "secret of db/password", value)
+
+	assert.ErrorContains(t, Check("!secret nothing"), "not of the form")
+	assert.ErrorContains(t, Check("!secret gopass:token"), "unknown secret scheme")
+	assert.NoError(t, Check("!secret keychain:github-token"))
+	assert.NoError(t, Check("not a reference"))
+}
+
+// TestResolveVault verifies that secrets are read from Vault's HTTP API with
+// the configured token, unwrapping version 2 of the KV engine, and that the
+// field is required when a secret has several.
+func TestResolveVault(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/data/mcp":
+			_, _ = w.Write([]byte(`{"data": {"data": {"token": "abc", "user": "bot"}, "metadata": {"version": 3}}}`))
+		case "/v1/secret/legacy":
+			_, _ = w.Write([]byte(`{"data": {"password": "hunter2"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer vault.Close()
+	t.Setenv("VAULT_ADDR", vault.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+
+	ctx := context.Background()
+	value, err := Resolve(ctx, "!secret vault:kv/data/mcp#token")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", value)
+
+	value, err = Resolve(ctx, "!secret vault:secret/legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", value)
+
+	_, err = Resolve(ctx, "!secret vault:kv/data/mcp")
+	assert.ErrorContains(t, err, "name one with #<field>")
+	_, err = Resolve(ctx, "!secret vault:kv/data/mcp#missing")
+	assert.ErrorContains(t, err, `no field "missing"`)
+
+	t.Setenv("VAULT_TOKEN", "wrong")
+	_, err = Resolve(ctx, "!secret vault:kv/data/mcp#token")
+	assert.ErrorContains(t, err, "403")
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+	"github.com/voicetreelab/lazy-mcp/internal/serverlog"
+)
+
+// statusStderrLines is how many of the last lines a server wrote to stderr
+// lazy_server_status includes
+const statusStderrLines = 50
+
+// serverArgument is the input schema of the admin tools that act on a server
+var serverArgument = mcp.ToolInputSchema{
+	Type: "object",
+	Properties: map[string]interface{}{
+		"server": map[string]interface{}{
+			"type":        "string",
+			"description": "Name of the server, as listed by lazy_list_servers",
+		},
+	},
+	Required: []string{"server"},
+}
+
+// addAdminTools registers the lazy_* meta-tools, which let the model inspect
+// and recover lazy-mcp itself. They are only offered with options.adminTools,
+// since they can restart servers and reload the config.
+func addAdminTools(cfg *config.Config, mcpServer *server.MCPServer, h *hierarchy.Hierarchy, registry *hierarchy.ServerRegistry, serverLogs *serverlog.Logs) {
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "lazy_list_servers",
+		Description: "List the MCP servers lazy-mcp is configured with, with their transport, state (stopped, running, restarting or failed) and health.",
+		InputSchema: mcp.ToolInputSchema{Type: "object", Properties: map[string]interface{}{}},
+	}, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return newJSONResult(map[string]interface{}{
+			"servers": registry.ServerStatuses(),
+		})
+	})
+
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "lazy_server_status",
+		Description: "Get the status of one MCP server: its state, health, latest error, restart count and version, with its recent events such as starts, crashes and failed health checks, and the end of what it wrote to stderr.",
+		InputSchema: serverArgument,
+	}, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("server")
+		if err != nil {
+			return nil, err
+		}
+		status, err := registry.ServerStatus(name)
+		if err != nil {
+			return nil, err
+		}
+		return newJSONResult(struct {
+			hierarchy.ServerStatus
+			Events []hierarchy.Event `json:"events"`
+			Stderr string            `json:"stderr,omitempty"`
+		}{status, registry.Events().Recent(name), serverLogs.Tail(name, statusStderrLines)})
+	})
+
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "lazy_restart_server",
+		Description: "Restart an MCP server that is misbehaving or has failed, and return its status once it is running again.",
+		InputSchema: serverArgument,
+	}, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("server")
+		if err != nil {
+			return nil, err
+		}
+		if err := registry.RestartServer(ctx, name); err != nil {
+			return nil, fmt.Errorf("failed to restart %s: %w", name, err)
+		}
+		status, err := registry.ServerStatus(name)
+		if err != nil {
+			return nil, err
+		}
+		return newJSONResult(status)
+	})
+
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "lazy_reload_config",
+		Description: "Reload lazy-mcp's config file, applying added, removed and edited servers. Returns the servers that changed.",
+		InputSchema: mcp.ToolInputSchema{Type: "object", Properties: map[string]interface{}{}},
+	}, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		changed, err := reloadConfig(cfg, h, registry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reload config: %w", err)
+		}
+		if changed == nil {
+			changed = []string{}
+		}
+		return newJSONResult(map[string]interface{}{
+			"changed": changed,
+		})
+	})
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// authKeys is the set of keys HTTP clients may authenticate with. Keys are
+// looked up by digest, so that the time a lookup takes reveals nothing about
+// how close a wrong key is.
+type authKeys struct {
+	digests atomic.Pointer[map[[sha256.Size]byte]struct{}]
+}
+
+func (k *authKeys) set(keys []string) {
+	digests := make(map[[sha256.Size]byte]struct{}, len(keys))
+	for _, key := range keys {
+		digests[sha256.Sum256([]byte(key))] = struct{}{}
+	}
+	k.digests.Store(&digests)
+}
+
+func (k *authKeys) valid(key string) bool {
+	_, ok := (*k.digests.Load())[sha256.Sum256([]byte(key))]
+	return ok
+}
+
+// loadAuthKeys returns the keys HTTP clients must authenticate with: the
+// authTokens option and those of mcpProxy.auth, whose keys file is reloaded
+// when it changes until ctx is done. It returns nil if neither is set.
+func loadAuthKeys(ctx context.Context, cfg *config.Config) (*authKeys, error) {
+	var tokens []string
+	if cfg.McpProxy.Options != nil {
+		tokens = cfg.McpProxy.Options.AuthTokens
+	}
+	auth := cfg.McpProxy.Auth
+	if auth == nil && len(tokens) == 0 {
+		return nil, nil
+	}
+	load := func() ([]string, error) {
+		keys, err := auth.LoadKeys()
+		return append(keys, tokens...), err
+	}
+	all, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if len(all) == 0 {
+		slog.Warn("No auth keys configured, rejecting every HTTP request")
+	}
+	keys := &authKeys{}
+	keys.set(all)
+
+	if auth != nil && auth.KeysFile != "" {
+		err := config.Watch(ctx, []string{auth.KeysFile}, func() {
+			all, err := load()
+			if err != nil {
+				slog.Warn("Ignoring auth keys change", "error", err)
+				return
+			}
+			keys.set(all)
+			slog.Info("Reloaded auth keys", "keys", len(all))
+		})
+		if err != nil {
+			slog.Warn("Not watching auth keys file for changes", "error", err)
+		}
+	}
+	return keys, nil
+}
+
+// authorized reports whether r carries one of the keys, either as a bearer
+// token or in header
+func (k *authKeys) authorized(r *http.Request, header string) bool {
+	key := strings.TrimSpace(r.Header.Get(header))
+	if key == "" {
+		// The scheme is case-insensitive, as per RFC 7235
+		scheme, token, _ := strings.Cut(strings.TrimSpace(r.Header.Get("Authorization")), " ")
+		if strings.EqualFold(scheme, "Bearer") {
+			key = strings.TrimSpace(token)
+		}
+	}
+	return key != "" && k.valid(key)
+}
+
+// newAuthMiddleware rejects requests that do not carry one of keys, either
+// as a bearer token or in header
+func newAuthMiddleware(keys *authKeys, header string) MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !keys.authorized(r, header) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="lazy-mcp"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+)
+
+// authStatus returns the status the auth middleware answers req with, and
+// its WWW-Authenticate header
+func authStatus(keys *authKeys, req *http.Request) (int, string) {
+	handler := newAuthMiddleware(keys, config.DefaultAPIKeyHeader)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code, rec.Header().Get("WWW-Authenticate")
+}
+
+// TestAuthMiddleware verifies that requests need one of the keys, as a bearer
+// token of any case or in the API key header, and that rejected requests are
+// told the scheme to use.
+func TestAuthMiddleware(t *testing.T) {
+	keys := &authKeys{}
+	keys.set([]string{"secret"})
+
+	for name, test := range map[string]struct {
+		headers map[string]string
+		want    int
+	}{
+		"missing":                {want: http.StatusUnauthorized},
+		"wrong bearer":           {headers: map[string]string{"Authorization": "Bearer guess"}, want: http.StatusUnauthorized},
+		"valid bearer":           {headers: map[string]string{"Authorization": "Bearer secret"}, want: http.StatusNoContent},
+		"lowercase bearer":       {headers: map[string]string{"Authorization": "bearer secret"}, want: http.StatusNoContent},
+		"other scheme":           {headers: map[string]string{"Authorization": "Basic secret"}, want: http.StatusUnauthorized},
+		"bare key":               {headers: map[string]string{"Authorization": "secret"}, want: http.StatusUnauthorized},
+		"valid API key":          {headers: map[string]string{"X-API-Key": "secret"}, want: http.StatusNoContent},
+		"wrong API key":          {headers: map[string]string{"X-API-Key": "guess"}, want: http.StatusUnauthorized},
+		"API key before bearer":  {headers: map[string]string{"X-API-Key": "guess", "Authorization": "Bearer secret"}, want: http.StatusUnauthorized},
+		"bearer without API key": {headers: map[string]string{"X-API-Key": " ", "Authorization": "Bearer secret"}, want: http.StatusNoContent},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		for header, value := range test.headers {
+			req.Header.Set(header, value)
+		}
+		status, challenge := authStatus(keys, req)
+		assert.Equal(t, test.want, status, name)
+		if status == http.StatusUnauthorized {
+			assert.Equal(t, `Bearer realm="lazy-mcp"`, challenge, name)
+		} else {
+			assert.Empty(t, challenge, name)
+		}
+	}
+}
+
+// TestLoadAuthKeys verifies that the keys of auth, its keys file and
+// authTokens are all accepted, and that rewriting the keys file adds and
+// revokes keys without a restart.
+func TestLoadAuthKeys(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	keysFile := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(keysFile, []byte("# Alice\nalice\n\n"), 0o600))
+	cfg := &config.Config{McpProxy: &config.MCPProxyConfigV2{
+		Auth:    &config.AuthConfig{Keys: []string{"ci"}, KeysFile: keysFile},
+		Options: &config.OptionsV2{AuthTokens: []string{"legacy"}},
+	}}
+	keys, err := loadAuthKeys(ctx, cfg)
+	require.NoError(t, err)
+	for _, key := range []string{"ci", "alice", "legacy"} {
+		assert.True(t, keys.valid(key), key)
+	}
+	assert.False(t, keys.valid("# Alice"), "comments are not keys")
+	assert.False(t, keys.valid("bob"))
+
+	require.NoError(t, os.WriteFile(keysFile, []byte("bob\n"), 0o600))
+	assert.Eventually(t, func() bool {
+		return keys.valid("bob") && !keys.valid("alice")
+	}, 5*time.Second, 20*time.Millisecond, "the rewritten keys file applies")
+	assert.True(t, keys.valid("ci"))
+
+	keys, err = loadAuthKeys(ctx, &config.Config{McpProxy: &config.MCPProxyConfigV2{}})
+	require.NoError(t, err)
+	assert.Nil(t, keys, "without auth, requests are not authenticated")
+
+	_, err = loadAuthKeys(ctx, &config.Config{McpProxy: &config.MCPProxyConfigV2{
+		Auth: &config.AuthConfig{KeysFile: filepath.Join(t.TempDir(), "missing")},
+	}})
+	assert.ErrorContains(t, err, "failed to read auth keys")
+}
+
+// TestHealthHandlerWithAuth verifies that /healthz answers probes without a
+// key, but tells the servers' statuses only to requests with one.
+func TestHealthHandlerWithAuth(t *testing.T) {
+	registry := hierarchy.NewServerRegistry(map[string]*config.MCPClientConfigV2{"github": {Command: "github-mcp-server"}})
+	defer registry.Close()
+	keys := &authKeys{}
+	keys.set([]string{"secret"})
+	handler := newHealthHandler(registry, func(r *http.Request) bool {
+		return keys.authorized(r, config.DefaultAPIKeyHeader)
+	})
+
+	get := func(headers map[string]string) map[string]any {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		for header, value := range headers {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body
+	}
+
+	assert.Equal(t, map[string]any{"healthy": true}, get(nil))
+	assert.Equal(t, map[string]any{"healthy": true}, get(map[string]string{"Authorization": "Bearer guess"}))
+	body := get(map[string]string{"Authorization": "Bearer secret"})
+	assert.Equal(t, true, body["healthy"])
+	assert.Len(t, body["servers"], 1)
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// errCancelledByClient is the cause of a call's context ending because its
+// client sent notifications/cancelled
+var errCancelledByClient = errors.New("request cancelled by client")
+
+// requestIDField carries a tool call's JSON-RPC ID from the beforeCallTool
+// hook, which sees it, to the tool handler middleware, which does not
+const requestIDField = "lazy-mcp/jsonrpcId"
+
+// cancellations lets clients cancel tool calls in flight with
+// notifications/cancelled, which mcp-go does not act on itself. Cancelling a
+// call's context aborts the downstream call and frees its server's slot.
+type cancellations struct {
+	mu    sync.Mutex
+	calls map[callKey]context.CancelCauseFunc
+}
+
+// callKey identifies a request: IDs are only unique within a session
+type callKey struct {
+	session string
+	id      string
+}
+
+func newCancellations() *cancellations {
+	return &cancellations{calls: make(map[callKey]context.CancelCauseFunc)}
+}
+
+// addHooks records the ID of every tool call for the middleware
+func (c *cancellations) addHooks(hooks *server.Hooks) {
+	hooks.AddBeforeCallTool(func(ctx context.Context, id any, request *mcp.CallToolRequest) {
+		if request.Params.Meta == nil {
+			request.Params.Meta = &mcp.Meta{}
+		}
+		if request.Params.Meta.AdditionalFields == nil {
+			request.Params.Meta.AdditionalFields = make(map[string]any)
+		}
+		request.Params.Meta.AdditionalFields[requestIDField] = fmt.Sprint(id)
+	})
+}
+
+// middleware makes tool calls cancellable by their client
+func (c *cancellations) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if request.Params.Meta == nil {
+			return next(ctx, request)
+		}
+		id, ok := request.Params.Meta.AdditionalFields[requestIDField].(string)
+		if !ok {
+			return next(ctx, request)
+		}
+
+		ctx, cancel := context.WithCancelCause(ctx)
+		key := callKey{session: sessionIDFromContext(ctx), id: id}
+		c.mu.Lock()
+		c.calls[key] = cancel
+		c.mu.Unlock()
+		defer func() {
+			c.mu.Lock()
+			delete(c.calls, key)
+			c.mu.Unlock()
+			cancel(nil)
+		}()
+		return next(ctx, request)
+	}
+}
+
+// cancelled handles a notifications/cancelled from a client. Cancelling a
+// call that already finished, or was never made, is a no-op.
+func (c *cancellations) cancelled(ctx context.Context, notification mcp.JSONRPCNotification) {
+	id, ok := notification.Params.AdditionalFields["requestId"]
+	if !ok {
+		return
+	}
+	key := callKey{session: sessionIDFromContext(ctx), id: fmt.Sprint(id)}
+	c.mu.Lock()
+	cancel, ok := c.calls[key]
+	c.mu.Unlock()
+	if ok {
+		cancel(errCancelledByClient)
+	}
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+)
+
+// methodComplete requests completions of an argument, which mcp-go's server
+// does not handle
+const methodComplete = "completion/complete"
+
+// addCompletions answers completion/complete through intercept, forwarding
+// the request to the server of the prompt or resource template whose
+// argument is to be completed
+func addCompletions(intercept *interceptor, registry *hierarchy.ServerRegistry) {
+	intercept.handle(methodComplete, func(ctx context.Context, sessionID string, params json.RawMessage) (any, error) {
+		var complete mcp.CompleteParams
+		if err := json.Unmarshal(params, &complete); err != nil {
+			return nil, err
+		}
+		return registry.Complete(ctx, complete)
+	})
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+)
+
+const (
+	// dashboardRateWindow is the window the dashboard's call rates are over
+	dashboardRateWindow = time.Minute
+	// dashboardErrors is how many recent errors the dashboard keeps
+	dashboardErrors = 20
+)
+
+// DashboardStatus is what GET /admin/status returns, and what `mcp-proxy
+// top` shows
+type DashboardStatus struct {
+	Time    time.Time                `json:"time"`
+	Servers []hierarchy.ServerStatus `json:"servers"`
+	Tools   []ToolRate               `json:"tools"`
+	Errors  []hierarchy.Event        `json:"errors"`
+	// Latencies are of every tool called since lazy-mcp started
+	Latencies []hierarchy.ToolLatency `json:"latencies"`
+}
+
+// ToolRate is how often a tool was called over the last minute
+type ToolRate struct {
+	ToolPath string `json:"toolPath"`
+	Server   string `json:"server"`
+	Tool     string `json:"tool"` // As the server names it
+	Calls    int    `json:"calls"`
+	Errors   int    `json:"errors"`
+}
+
+// dashboard serves the /admin endpoints `mcp-proxy top` polls and acts
+// through. It follows the registry's events to keep the call rates and the
+// recent errors the statuses do not hold.
+type dashboard struct {
+	registry    *hierarchy.ServerRegistry
+	unsubscribe func()
+
+	mu     sync.Mutex
+	calls  map[string][]toolCallSample
+	errors []hierarchy.Event
+}
+
+type toolCallSample struct {
+	at     time.Time
+	server string
+	tool   string
+	failed bool
+}
+
+func newDashboard(registry *hierarchy.ServerRegistry) *dashboard {
+	d := &dashboard{
+		registry: registry,
+		calls:    make(map[string][]toolCallSample),
+	}
+	d.unsubscribe = registry.Events().Subscribe(d.record)
+	return d
+}
+
+func (d *dashboard) close() {
+	d.unsubscribe()
+}
+
+func (d *dashboard) record(ctx context.Context, event hierarchy.Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if event.Type == hierarchy.EventToolCalled {
+		samples := pruneSamples(d.calls[event.ToolPath], event.Time)
+		d.calls[event.ToolPath] = append(samples, toolCallSample{at: event.Time, server: event.Server, tool: event.Tool, failed: event.Error != ""})
+	}
+	if event.Error != "" {
+		d.errors = append(d.errors, event)
+		if len(d.errors) > dashboardErrors {
+			d.errors = d.errors[len(d.errors)-dashboardErrors:]
+		}
+	}
+}
+
+// status returns the servers' statuses with the call rates and the recent
+// errors, newest first
+func (d *dashboard) status() DashboardStatus {
+	now := time.Now()
+	status := DashboardStatus{
+		Time:      now,
+		Servers:   d.registry.ServerStatuses(),
+		Tools:     []ToolRate{},
+		Latencies: d.registry.ToolLatencies(),
+	}
+
+	d.mu.Lock()
+	for path, samples := range d.calls {
+		samples = pruneSamples(samples, now)
+		if len(samples) == 0 {
+			delete(d.calls, path)
+			continue
+		}
+		d.calls[path] = samples
+		latest := samples[len(samples)-1]
+		rate := ToolRate{ToolPath: path, Server: latest.server, Tool: latest.tool, Calls: len(samples)}
+		for _, sample := range samples {
+			if sample.failed {
+				rate.Errors++
+			}
+		}
+		status.Tools = append(status.Tools, rate)
+	}
+	status.Errors = make([]hierarchy.Event, 0, len(d.errors))
+	for i := len(d.errors) - 1; i >= 0; i-- {
+		status.Errors = append(status.Errors, d.errors[i])
+	}
+	d.mu.Unlock()
+
+	sort.Slice(status.Tools, func(i, j int) bool {
+		if status.Tools[i].Calls != status.Tools[j].Calls {
+			return status.Tools[i].Calls > status.Tools[j].Calls
+		}
+		return status.Tools[i].ToolPath < status.Tools[j].ToolPath
+	})
+	return status
+}
+
+// pruneSamples drops the samples older than the rate window
+func pruneSamples(samples []toolCallSample, now time.Time) []toolCallSample {
+	cutoff := now.Add(-dashboardRateWindow)
+	i := 0
+	for i < len(samples) && samples[i].at.Before(cutoff) {
+		i++
+	}
+	return samples[i:]
+}
+
+// handler serves GET /admin/status and POST
+// /admin/servers/{name}/{restart,disable,enable}
+func (d *dashboard) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, d.status())
+	})
+	mux.HandleFunc("POST /admin/servers/{name}/{action}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		var err error
+		switch r.PathValue("action") {
+		case "restart":
+			err = d.registry.RestartServer(r.Context(), name)
+		case "disable":
+			err = d.registry.DisableServer(r.Context(), name)
+		case "enable":
+			err = d.registry.EnableServer(name)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, hierarchy.ErrUnknownServer) {
+				code = http.StatusNotFound
+			}
+			writeJSON(w, code, map[string]string{"error": err.Error()})
+			return
+		}
+		status, err := d.registry.ServerStatus(name)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
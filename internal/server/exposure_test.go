@@ -0,0 +1,85 @@
+package server
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+)
+
+// newExposureTestServer creates the proxy server for a hierarchy of github
+// and trello tools, with the given options and usage promotion off, and
+// returns it with what it logged
+func newExposureTestServer(t *testing.T, options *config.OptionsV2) (*server.MCPServer, *bytes.Buffer) {
+	t.Helper()
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	h := loadTestHierarchy(t, map[string]string{
+		"github": `{"tools": {
+			"create_issue": {"description": "Create an issue", "maps_to": "create_issue", "server": "github"},
+			"get_issue": {"description": "Get an issue", "maps_to": "get_issue", "server": "github"}
+		}}`,
+		"trello": `{"tools": {
+			"add_card": {"description": "Add a card", "maps_to": "add_card", "server": "trello"}
+		}}`,
+	})
+	registry := hierarchy.NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"github": {Command: "github-mcp-server"},
+		"trello": {Command: "trello-mcp-server"},
+	})
+	t.Cleanup(registry.Close)
+	cfg := &config.Config{McpProxy: &config.MCPProxyConfigV2{
+		Name:    "lazy-mcp",
+		Version: "1.0.0",
+		Options: options,
+		Usage:   &config.UsageConfig{Disabled: true},
+	}}
+	mcpServer := newProxyMCPServer(cfg, h, registry, hierarchy.NewSessionManager(0), nil, nil, newNotifier(options), make(chan struct{}))
+	return mcpServer, &logs
+}
+
+// TestPinnedTools verifies that hybrid exposure, the default, lists the
+// pinned tools alongside the meta-tools, named by their paths, and that pins
+// matching no tool are warned about.
+func TestPinnedTools(t *testing.T) {
+	mcpServer, logs := newExposureTestServer(t, &config.OptionsV2{Pin: []string{"github.create_issue", "trello.*"}})
+	tools := keys(mcpServer.ListTools())
+	assert.Contains(t, tools, "github_create_issue")
+	assert.Contains(t, tools, "trello_add_card")
+	assert.NotContains(t, tools, "github_get_issue", "unpinned tools stay hidden")
+	assert.Contains(t, tools, "get_tools_in_category")
+	assert.Contains(t, tools, "execute_tool")
+	assert.NotContains(t, logs.String(), "No tools match")
+
+	mcpServer, logs = newExposureTestServer(t, &config.OptionsV2{Pin: []string{"jira.*"}})
+	assert.NotContains(t, keys(mcpServer.ListTools()), "jira_create_issue")
+	assert.Contains(t, logs.String(), "No tools match the pinned tool patterns")
+
+	mcpServer, _ = newExposureTestServer(t, &config.OptionsV2{
+		Exposure: optional.NewField(config.ExposureHierarchical),
+		Pin:      []string{"github.create_issue"},
+	})
+	tools = keys(mcpServer.ListTools())
+	assert.NotContains(t, tools, "github_create_issue", "hierarchical exposure lists no tools directly")
+	assert.Contains(t, tools, "get_tools_in_category")
+}
+
+// TestFlatExposure verifies that flat exposure lists every tool of the
+// hierarchy under its prefixed name, leaving out the browsing meta-tools.
+func TestFlatExposure(t *testing.T) {
+	mcpServer, _ := newExposureTestServer(t, &config.OptionsV2{Exposure: optional.NewField(config.ExposureFlat)})
+	tools := keys(mcpServer.ListTools())
+	assert.Subset(t, tools, []string{"github_create_issue", "github_get_issue", "trello_add_card"})
+	for _, metaTool := range []string{"get_tools_in_category", "execute_tool", "search_tools"} {
+		assert.NotContains(t, tools, metaTool)
+	}
+	assert.Equal(t, "Create an issue", mcpServer.GetTool("github_create_issue").Tool.Description)
+}
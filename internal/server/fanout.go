@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+)
+
+// addFanOutTools registers the tools of mcpProxy.fanOut, each of which calls
+// several tools with its arguments and merges their results
+func addFanOutTools(cfg *config.Config, mcpServer *server.MCPServer, h *hierarchy.Hierarchy, registry *hierarchy.ServerRegistry) {
+	for _, name := range slices.Sorted(maps.Keys(cfg.McpProxy.FanOut)) {
+		fanOut := cfg.McpProxy.FanOut[name]
+		if mcpServer.GetTool(name) != nil {
+			slog.Warn("Not listing fan-out tool, as another tool has its name", "tool", name)
+			continue
+		}
+
+		description := fanOut.Description
+		if description == "" {
+			description = fmt.Sprintf("Call %s in parallel with the same arguments and return all of their results, each headed by the tool and server it came from.", strings.Join(fanOut.Tools, ", "))
+		}
+		tool := newConfiguredTool(name, description, fanOut.InputSchema)
+
+		toolPaths := fanOut.Tools
+		mcpServer.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return callFanOut(ctx, h, registry, toolPaths, request.GetArguments()), nil
+		})
+	}
+}
+
+// newConfiguredTool returns a tool defined in the config, which accepts any
+// object unless it has an input schema
+func newConfiguredTool(name, description string, inputSchema map[string]any) mcp.Tool {
+	tool := mcp.Tool{
+		Name:        name,
+		Description: description,
+		InputSchema: mcp.ToolInputSchema{Type: "object", Properties: map[string]interface{}{}},
+	}
+	if inputSchema != nil {
+		if schema, err := json.Marshal(inputSchema); err == nil {
+			tool.InputSchema = mcp.ToolInputSchema{}
+			tool.RawInputSchema = schema
+		}
+	}
+	return tool
+}
+
+// callFanOut calls the tools at toolPaths with arguments, in parallel across
+// servers, and merges their results: the content of each, headed by where it
+// came from, and in structuredContent the sources with the errors of those
+// that failed. It is only an error if every call failed.
+func callFanOut(ctx context.Context, h *hierarchy.Hierarchy, registry *hierarchy.ServerRegistry, toolPaths []string, arguments map[string]interface{}) *mcp.CallToolResult {
+	calls := make([]hierarchy.BatchCall, len(toolPaths))
+	for i, toolPath := range toolPaths {
+		// Middleware may rewrite a call's arguments
+		calls[i] = hierarchy.BatchCall{ToolPath: toolPath, Arguments: maps.Clone(arguments)}
+	}
+
+	merged := &mcp.CallToolResult{}
+	sources := make([]map[string]interface{}, len(toolPaths))
+	failed := 0
+	for i, outcome := range h.HandleBatchCall(ctx, registry, calls) {
+		toolPath := toolPaths[i]
+		source := map[string]interface{}{"tool_path": toolPath}
+		from := toolPath
+		if _, serverName, err := h.ResolveExposedToolPath(toolPath); err == nil {
+			source["server"] = serverName
+			from = fmt.Sprintf("%s (server %s)", toolPath, serverName)
+		}
+		sources[i] = source
+
+		result, err := toolResult(outcome.Result, outcome.Err)
+		if err != nil {
+			failed++
+			source["error"] = err.Error()
+			merged.Content = append(merged.Content, mcp.NewTextContent(fmt.Sprintf("%s failed: %v", from, err)))
+			continue
+		}
+		if result.StructuredContent != nil {
+			source["structuredContent"] = result.StructuredContent
+		}
+		if result.IsError {
+			failed++
+			source["error"] = "the tool returned an error"
+			merged.Content = append(merged.Content, mcp.NewTextContent(from+" failed:"))
+		} else {
+			merged.Content = append(merged.Content, mcp.NewTextContent("Results of "+from+":"))
+		}
+		merged.Content = append(merged.Content, result.Content...)
+	}
+	merged.StructuredContent = map[string]interface{}{"sources": sources, "failed": failed}
+	merged.IsError = failed == len(toolPaths)
+	return merged
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+)
+
+// newSearchServer serves a search tool over streamable HTTP, answered by
+// handler, and returns its URL
+func newSearchServer(t *testing.T, handler server.ToolHandlerFunc) string {
+	t.Helper()
+	mcpServer := server.NewMCPServer("search", "1.0.0", server.WithToolCapabilities(false))
+	mcpServer.AddTool(mcp.NewTool("search"), handler)
+	httpServer := httptest.NewServer(server.NewStreamableHTTPServer(mcpServer))
+	t.Cleanup(httpServer.Close)
+	return httpServer.URL
+}
+
+// TestCallFanOut verifies that a fan-out call merges the results of every
+// tool, each headed by its tool and server, keeps each one's structured
+// content and error apart, and is only an error when every call failed.
+func TestCallFanOut(t *testing.T) {
+	docs := newSearchServer(t, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultStructured(map[string]any{"hits": 2}, "2 pages match "+request.GetString("query", "")), nil
+	})
+	wiki := newSearchServer(t, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultError("index is rebuilding"), nil
+	})
+	tickets := newSearchServer(t, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return nil, errors.New("backend down")
+	})
+
+	node := `{"tools": {"search": {"description": "Search", "maps_to": "search", "server": "%s"}}}`
+	h := loadTestHierarchy(t, map[string]string{
+		"docs":    fmt.Sprintf(node, "docs"),
+		"wiki":    fmt.Sprintf(node, "wiki"),
+		"tickets": fmt.Sprintf(node, "tickets"),
+	})
+	registry := hierarchy.NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"docs":    {Type: config.MCPClientTypeStreamable, URL: docs},
+		"wiki":    {Type: config.MCPClientTypeStreamable, URL: wiki},
+		"tickets": {Type: config.MCPClientTypeStreamable, URL: tickets},
+	})
+	defer registry.Close()
+	ctx := context.Background()
+	arguments := map[string]interface{}{"query": "deploy"}
+
+	result := callFanOut(ctx, h, registry, []string{"docs.search", "wiki.search", "tickets.search"}, arguments)
+	assert.False(t, result.IsError, "some calls succeeded")
+	require.GreaterOrEqual(t, len(result.Content), 5)
+	assert.Equal(t, "Results of docs.search (server docs):", result.Content[0].(mcp.TextContent).Text)
+	assert.Equal(t, "2 pages match deploy", result.Content[1].(mcp.TextContent).Text)
+	assert.Equal(t, "wiki.search (server wiki) failed:", result.Content[2].(mcp.TextContent).Text)
+	assert.Equal(t, "index is rebuilding", result.Content[3].(mcp.TextContent).Text)
+
+	structured := result.StructuredContent.(map[string]interface{})
+	assert.Equal(t, 2, structured["failed"])
+	sources := structured["sources"].([]map[string]interface{})
+	require.Len(t, sources, 3)
+	assert.Equal(t, map[string]interface{}{
+		"tool_path":         "docs.search",
+		"server":            "docs",
+		"structuredContent": map[string]interface{}{"hits": float64(2)},
+	}, sources[0])
+	assert.Equal(t, "wiki", sources[1]["server"])
+	assert.Equal(t, "the tool returned an error", sources[1]["error"])
+	assert.Equal(t, "tickets", sources[2]["server"])
+	assert.NotEmpty(t, sources[2]["error"])
+	assert.NotContains(t, sources[1], "structuredContent", "sources keep their own structured content")
+
+	result = callFanOut(ctx, h, registry, []string{"wiki.search", "tickets.search", "missing.search"}, arguments)
+	assert.True(t, result.IsError, "every call failed")
+	structured = result.StructuredContent.(map[string]interface{})
+	assert.Equal(t, 3, structured["failed"])
+	missing := structured["sources"].([]map[string]interface{})[2]
+	assert.Equal(t, "missing.search", missing["tool_path"])
+	assert.NotContains(t, missing, "server", "a tool that does not resolve has no server")
+	assert.NotEmpty(t, missing["error"])
+}
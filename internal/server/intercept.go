@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// stdioSessionID is the ID of mcp-go's one stdio session
+const stdioSessionID = "stdio"
+
+// interceptedHandler answers a request from the given session, returning its
+// result
+type interceptedHandler func(ctx context.Context, sessionID string, params json.RawMessage) (any, error)
+
+// interceptor answers requests that mcp-go's server does not handle, such as
+// resources/subscribe, ahead of it on the stdio and Streamable HTTP
+// transports, passing other messages on. The SSE transport answers on its
+// event stream, which only the MCP server writes to, so its clients are left
+// without them.
+type interceptor struct {
+	handlers map[string]interceptedHandler // By method
+}
+
+func newInterceptor() *interceptor {
+	return &interceptor{handlers: make(map[string]interceptedHandler)}
+}
+
+// handle registers handler to answer requests of the given method
+func (i *interceptor) handle(method string, handler interceptedHandler) {
+	i.handlers[method] = handler
+}
+
+// request returns the ID and handler of message if it is a request the
+// interceptor answers
+func (i *interceptor) request(message []byte) (id any, method string, params json.RawMessage, handler interceptedHandler) {
+	var request struct {
+		ID     any             `json:"id"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(message, &request); err != nil || request.ID == nil {
+		return nil, "", nil, nil
+	}
+	return request.ID, request.Method, request.Params, i.handlers[request.Method]
+}
+
+// answer runs the handler of a request and returns its response
+func (i *interceptor) answer(ctx context.Context, sessionID string, id any, method string, params json.RawMessage, handler interceptedHandler) mcp.JSONRPCMessage {
+	result, err := handler(ctx, sessionID, params)
+	if err != nil {
+		slog.WarnContext(ctx, "Request failed", "method", method, "error", err)
+		return mcp.NewJSONRPCError(mcp.NewRequestId(id), mcp.INVALID_PARAMS, err.Error(), nil)
+	}
+	return mcp.NewJSONRPCResultResponse(mcp.NewRequestId(id), result)
+}
+
+// middleware answers the requests posted to a Streamable HTTP handler that
+// the interceptor handles, passing others on
+func (i *interceptor) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessionID := r.Header.Get(server.HeaderKeySessionID)
+		if r.Method != http.MethodPost || sessionID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		id, method, params, handler := i.request(body)
+		if handler == nil {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+			return
+		}
+		response := i.answer(httpContext(r.Context(), r), sessionID, id, method, params, handler)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(server.HeaderKeySessionID, sessionID)
+		_ = json.NewEncoder(w).Encode(response)
+	})
+}
+
+// stdio returns a reader of the messages read from in that the interceptor
+// does not answer, and a writer to out that it answers on too, for the stdio
+// server to use in their place. Requests are answered in their own
+// goroutines, as they may start servers.
+func (i *interceptor) stdio(ctx context.Context, in io.Reader, out io.Writer) (io.Reader, io.Writer) {
+	writer := &lockedWriter{w: out}
+	reader, pipe := io.Pipe()
+	go func() {
+		lines := bufio.NewReader(in)
+		for {
+			line, err := lines.ReadBytes('\n')
+			if len(line) > 0 {
+				if id, method, params, handler := i.request(line); handler != nil {
+					go func() {
+						data, err := json.Marshal(i.answer(ctx, stdioSessionID, id, method, params, handler))
+						if err == nil {
+							_, _ = writer.Write(append(data, '\n'))
+						}
+					}()
+				} else if _, err := pipe.Write(line); err != nil {
+					return
+				}
+			}
+			if err != nil {
+				_ = pipe.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return reader, writer
+}
+
+// lockedWriter serializes writes, each of which is a whole message
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
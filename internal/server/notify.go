@@ -0,0 +1,277 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// errSessionClosed is returned to a sender paused for room in the queue of a
+// client that disconnected
+var errSessionClosed = errors.New("client session closed")
+
+// notifier relays notifications to clients through a bounded queue per
+// session, so that a client reading its stream slowly neither makes lazy-mcp
+// buffer without limit nor loses the latest notifications, as mcp-go's own
+// sends, which give up once its small buffer is full, would. While a
+// session's queue is empty a notification goes straight to the session, so
+// that it keeps its place among the responses; otherwise it is queued, and
+// fed to the session as the client reads. Once the queue is full, the oldest
+// notification in it is dropped, or with pause the sender waits for room.
+type notifier struct {
+	size  int
+	pause bool
+
+	mu     sync.Mutex
+	queues map[string]*notificationQueue // By session ID
+}
+
+// notificationQueue holds the notifications for a session that its
+// transport has no room for yet
+type notificationQueue struct {
+	session server.ClientSession
+
+	mu      sync.Mutex
+	pending []mcp.JSONRPCNotification
+	feeding bool          // A notification taken from pending is being fed
+	dropped int           // Since the queue was last empty
+	ready   chan struct{} // Signalled when a notification is queued
+	room    chan struct{} // Signalled when one is taken from pending
+	done    chan struct{} // Closed once the session is unregistered
+}
+
+func newNotifier(options *config.OptionsV2) *notifier {
+	n := &notifier{size: config.DefaultNotificationQueueSize, queues: make(map[string]*notificationQueue)}
+	if options != nil {
+		n.size = options.NotificationQueueSize.OrElse(config.DefaultNotificationQueueSize)
+		n.pause = options.NotificationOverflow.OrElse(config.NotificationOverflowDrop) == config.NotificationOverflowPause
+	}
+	return n
+}
+
+// addHooks gives every session mcp-go registers, which lasts as long as the
+// client's stream, a queue of its own
+func (n *notifier) addHooks(hooks *server.Hooks) {
+	hooks.AddOnRegisterSession(func(ctx context.Context, session server.ClientSession) {
+		q := &notificationQueue{
+			session: session,
+			ready:   make(chan struct{}, 1),
+			room:    make(chan struct{}, 1),
+			done:    make(chan struct{}),
+		}
+		n.mu.Lock()
+		if previous := n.queues[session.SessionID()]; previous != nil {
+			close(previous.done)
+		}
+		n.queues[session.SessionID()] = q
+		n.mu.Unlock()
+		go q.feed()
+	})
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		if q := n.queues[session.SessionID()]; q != nil && q.session == session {
+			delete(n.queues, session.SessionID())
+			close(q.done)
+		}
+	})
+}
+
+// sendToClient sends a notification to the client of the request ctx
+// belongs to
+func (n *notifier) sendToClient(ctx context.Context, method string, params map[string]any) error {
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil || !session.Initialized() {
+		return server.ErrNotificationNotInitialized
+	}
+	return n.send(ctx, session, newNotification(method, params), n.pause)
+}
+
+// sendLogMessage sends a log message to the client of the request ctx
+// belongs to, if it asked for messages of its level
+func (n *notifier) sendLogMessage(ctx context.Context, notification mcp.LoggingMessageNotification) error {
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil || !session.Initialized() {
+		return server.ErrNotificationNotInitialized
+	}
+	logging, ok := session.(server.SessionWithLogging)
+	if !ok {
+		return server.ErrSessionDoesNotSupportLogging
+	}
+	if !notification.Params.Level.ShouldSendTo(logging.GetLogLevel()) {
+		return nil
+	}
+	return n.send(ctx, session, newNotification(notification.Method, map[string]any{
+		"level":  notification.Params.Level,
+		"logger": notification.Params.Logger,
+		"data":   notification.Params.Data,
+	}), n.pause)
+}
+
+// sendToSession sends a notification to the client of the given session
+func (n *notifier) sendToSession(sessionID, method string, params map[string]any) error {
+	n.mu.Lock()
+	q := n.queues[sessionID]
+	n.mu.Unlock()
+	if q == nil {
+		return server.ErrSessionNotFound
+	}
+	if !q.session.Initialized() {
+		return server.ErrSessionNotInitialized
+	}
+	return n.send(context.Background(), q.session, newNotification(method, params), n.pause)
+}
+
+// sendToAll sends a notification to every client with a session. It never
+// pauses, even with pause set, as a client that stopped reading would hold up
+// every client after it, and whatever broadcasts with it: once a client's
+// queue is full, its oldest notification is dropped.
+func (n *notifier) sendToAll(method string, params map[string]any) {
+	n.mu.Lock()
+	sessions := make([]server.ClientSession, 0, len(n.queues))
+	for _, q := range n.queues {
+		sessions = append(sessions, q.session)
+	}
+	n.mu.Unlock()
+	for _, session := range sessions {
+		if !session.Initialized() {
+			continue
+		}
+		if err := n.send(context.Background(), session, newNotification(method, params), false); err != nil {
+			slog.Debug("Failed to send notification", "method", method, "session", session.SessionID(), "error", err)
+		}
+	}
+}
+
+// send sends notification to session, through its queue if it has one. With
+// pause, a full queue holds up the sender; otherwise its oldest notification
+// is dropped.
+func (n *notifier) send(ctx context.Context, session server.ClientSession, notification mcp.JSONRPCNotification, pause bool) error {
+	// A Streamable HTTP request being answered turns into a stream to carry it
+	if streamable, ok := session.(server.SessionWithStreamableHTTPConfig); ok {
+		streamable.UpgradeToSSEWhenReceiveNotification()
+	}
+	n.mu.Lock()
+	q := n.queues[session.SessionID()]
+	n.mu.Unlock()
+	if q == nil || q.session != session {
+		// Sessions that only last as long as a request, like those of
+		// Streamable HTTP clients without a stream of their own, are not
+		// worth a queue
+		select {
+		case session.NotificationChannel() <- notification:
+			return nil
+		default:
+			return server.ErrNotificationChannelBlocked
+		}
+	}
+	return q.send(ctx, notification, n.size, pause)
+}
+
+// send queues notification, or with pause waits for room for it until ctx is
+// done or the session ends
+func (q *notificationQueue) send(ctx context.Context, notification mcp.JSONRPCNotification, size int, pause bool) error {
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 && !q.feeding {
+			select {
+			case q.session.NotificationChannel() <- notification:
+				q.mu.Unlock()
+				return nil
+			default:
+			}
+		}
+		// Notifications without params, such as tools/list_changed, say the
+		// same thing however many are queued
+		if withoutParams(notification) && slices.ContainsFunc(q.pending, func(queued mcp.JSONRPCNotification) bool {
+			return queued.Method == notification.Method && withoutParams(queued)
+		}) {
+			q.mu.Unlock()
+			return nil
+		}
+		if len(q.pending) < size || !pause {
+			if len(q.pending) >= size {
+				q.pending[0] = mcp.JSONRPCNotification{}
+				q.pending = q.pending[1:]
+				q.dropped++
+				if q.dropped == 1 {
+					slog.Warn("Dropping the oldest notifications queued for a client that reads them slowly", "session", q.session.SessionID(), "queued", size)
+				}
+			}
+			q.pending = append(q.pending, notification)
+			q.mu.Unlock()
+			wake(q.ready)
+			return nil
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.room:
+		case <-q.done:
+			return errSessionClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// feed passes the queued notifications on to the session as the client
+// reads them, until the session ends
+func (q *notificationQueue) feed() {
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			if q.dropped > 0 {
+				slog.Info("Client caught up with its notifications", "session", q.session.SessionID(), "dropped", q.dropped)
+			}
+			q.feeding, q.dropped = false, 0
+			q.mu.Unlock()
+			select {
+			case <-q.ready:
+				continue
+			case <-q.done:
+				return
+			}
+		}
+		notification := q.pending[0]
+		q.pending[0] = mcp.JSONRPCNotification{}
+		q.pending = q.pending[1:]
+		q.feeding = true
+		q.mu.Unlock()
+		wake(q.room)
+
+		select {
+		case q.session.NotificationChannel() <- notification:
+		case <-q.done:
+			return
+		}
+	}
+}
+
+// wake wakes whoever waits on ch, a channel with room for one signal
+func wake(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func newNotification(method string, params map[string]any) mcp.JSONRPCNotification {
+	return mcp.JSONRPCNotification{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		Notification: mcp.Notification{
+			Method: method,
+			Params: mcp.NotificationParams{AdditionalFields: params},
+		},
+	}
+}
+
+func withoutParams(notification mcp.JSONRPCNotification) bool {
+	return len(notification.Params.AdditionalFields) == 0 && notification.Params.Meta == nil
+}
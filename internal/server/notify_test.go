@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// fakeSession is a client session whose stream holds one notification, and
+// which only the test reads
+type fakeSession struct {
+	id string
+	ch chan mcp.JSONRPCNotification
+}
+
+func newFakeSession(id string) *fakeSession {
+	return &fakeSession{id: id, ch: make(chan mcp.JSONRPCNotification, 1)}
+}
+
+func (s *fakeSession) Initialize()                                         {}
+func (s *fakeSession) Initialized() bool                                   { return true }
+func (s *fakeSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return s.ch }
+func (s *fakeSession) SessionID() string                                   { return s.id }
+
+// newTestQueue returns a queue for session without feeding it, so that what
+// is queued stays queued
+func newTestQueue(session server.ClientSession) *notificationQueue {
+	return &notificationQueue{
+		session: session,
+		ready:   make(chan struct{}, 1),
+		room:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+func progress(n int) mcp.JSONRPCNotification {
+	return newNotification("notifications/progress", map[string]any{"progress": n})
+}
+
+// methods returns the progress of each of notifications, or the method of
+// those without progress
+func methods(notifications []mcp.JSONRPCNotification) []any {
+	var got []any
+	for _, notification := range notifications {
+		if progress, ok := notification.Params.AdditionalFields["progress"]; ok {
+			got = append(got, progress)
+		} else {
+			got = append(got, notification.Method)
+		}
+	}
+	return got
+}
+
+// TestNotificationQueueSendsDirectly verifies that a notification goes
+// straight to a session whose queue is empty, and is queued once its stream
+// is full.
+func TestNotificationQueueSendsDirectly(t *testing.T) {
+	session := newFakeSession("a")
+	q := newTestQueue(session)
+
+	require.NoError(t, q.send(context.Background(), progress(1), 2, false))
+	assert.Empty(t, q.pending)
+	assert.Equal(t, []any{1}, methods([]mcp.JSONRPCNotification{<-session.ch}))
+
+	session.ch <- progress(0)
+	require.NoError(t, q.send(context.Background(), progress(2), 2, false))
+	assert.Equal(t, []any{2}, methods(q.pending))
+}
+
+// TestNotificationQueueDropsOldest verifies that a full queue drops its
+// oldest notification for a new one, and that notifications without params
+// are only queued once.
+func TestNotificationQueueDropsOldest(t *testing.T) {
+	session := newFakeSession("a")
+	session.ch <- progress(0)
+	q := newTestQueue(session)
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, q.send(context.Background(), progress(i), 2, false))
+	}
+	assert.Equal(t, []any{2, 3}, methods(q.pending))
+	assert.Equal(t, 1, q.dropped)
+
+	listChanged := newNotification(mcp.MethodNotificationToolsListChanged, nil)
+	require.NoError(t, q.send(context.Background(), listChanged, 2, false))
+	require.NoError(t, q.send(context.Background(), listChanged, 2, false))
+	assert.Equal(t, []any{3, mcp.MethodNotificationToolsListChanged}, methods(q.pending),
+		"tools/list_changed is queued once")
+}
+
+// TestNotificationQueuePauses verifies that with pause a sender waits for
+// room in a full queue, and gives up once the session ends or its context is
+// done.
+func TestNotificationQueuePauses(t *testing.T) {
+	session := newFakeSession("a")
+	session.ch <- progress(0)
+	q := newTestQueue(session)
+	require.NoError(t, q.send(context.Background(), progress(1), 1, true))
+
+	sent := make(chan error, 1)
+	go func() { sent <- q.send(context.Background(), progress(2), 1, true) }()
+	select {
+	case err := <-sent:
+		t.Fatalf("send did not pause: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Taken as feed takes it
+	q.mu.Lock()
+	q.pending = q.pending[1:]
+	q.feeding = true
+	q.mu.Unlock()
+	wake(q.room)
+	select {
+	case err := <-sent:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("send did not resume once there was room")
+	}
+	assert.Equal(t, []any{2}, methods(q.pending))
+
+	go func() { sent <- q.send(context.Background(), progress(3), 1, true) }()
+	close(q.done)
+	select {
+	case err := <-sent:
+		assert.ErrorIs(t, err, errSessionClosed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("send did not give up once the session ended")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	q.done = make(chan struct{})
+	assert.ErrorIs(t, q.send(ctx, progress(4), 1, true), context.DeadlineExceeded)
+}
+
+// TestNotifierFeedsInOrder verifies that notifications queued for a session
+// reach it in order as the client reads them.
+func TestNotifierFeedsInOrder(t *testing.T) {
+	n := newNotifier(nil)
+	hooks := &server.Hooks{}
+	n.addHooks(hooks)
+	session := newFakeSession("a")
+	hooks.RegisterSession(context.Background(), session)
+	defer hooks.UnregisterSession(context.Background(), session)
+
+	session.ch <- progress(0)
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, n.sendToSession("a", "notifications/progress", map[string]any{"progress": i}))
+	}
+	var got []mcp.JSONRPCNotification
+	for range 4 {
+		select {
+		case notification := <-session.ch:
+			got = append(got, notification)
+		case <-time.After(5 * time.Second):
+			t.Fatal("queued notifications were not fed")
+		}
+	}
+	assert.Equal(t, []any{0, 1, 2, 3}, methods(got))
+
+	assert.ErrorIs(t, n.sendToSession("b", "notifications/progress", nil), server.ErrSessionNotFound)
+}
+
+// TestNotifierBroadcastDoesNotPause verifies that a client that stopped
+// reading does not hold up notifications to every client, even with pause.
+func TestNotifierBroadcastDoesNotPause(t *testing.T) {
+	n := newNotifier(&config.OptionsV2{
+		NotificationQueueSize: optional.NewField(1),
+		NotificationOverflow:  optional.NewField(config.NotificationOverflowPause),
+	})
+	hooks := &server.Hooks{}
+	n.addHooks(hooks)
+	stalled, reading := newFakeSession("stalled"), newFakeSession("reading")
+	hooks.RegisterSession(context.Background(), stalled)
+	hooks.RegisterSession(context.Background(), reading)
+	defer hooks.UnregisterSession(context.Background(), stalled)
+	defer hooks.UnregisterSession(context.Background(), reading)
+	stalled.ch <- progress(0)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 1; i <= 5; i++ {
+			n.sendToAll("notifications/progress", map[string]any{"progress": i})
+			<-reading.ch
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a stalled client held up the broadcast")
+	}
+}
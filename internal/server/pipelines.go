@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+)
+
+// addPipelineTools registers the tools of mcpProxy.pipelines, each of which
+// calls several tools in turn, passing on what the earlier ones returned
+func addPipelineTools(cfg *config.Config, mcpServer *server.MCPServer, h *hierarchy.Hierarchy, registry *hierarchy.ServerRegistry) {
+	for _, name := range slices.Sorted(maps.Keys(cfg.McpProxy.Pipelines)) {
+		pipeline := cfg.McpProxy.Pipelines[name]
+		if mcpServer.GetTool(name) != nil {
+			slog.Warn("Not listing pipeline tool, as another tool has its name", "tool", name)
+			continue
+		}
+
+		description := pipeline.Description
+		if description == "" {
+			tools := make([]string, len(pipeline.Steps))
+			for i, step := range pipeline.Steps {
+				tools[i] = step.Tool
+			}
+			description = fmt.Sprintf("Call %s in turn and return the result of the last.", strings.Join(tools, ", then "))
+		}
+		mcpServer.AddTool(newConfiguredTool(name, description, pipeline.InputSchema), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return toolResult(h.HandlePipeline(ctx, registry, pipeline, request.GetArguments()))
+		})
+	}
+}
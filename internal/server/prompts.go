@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// promptProxy mirrors the prompts of downstream servers into the proxy's
+// prompt list, named <server>.<prompt>. Like resources, a server's prompts are
+// listed once it has been started and stay listed until it is removed from
+// the config.
+type promptProxy struct {
+	mcpServer *server.MCPServer
+	registry  *hierarchy.ServerRegistry
+
+	mu    sync.Mutex
+	names map[string][]string // Namespaced prompt names listed per server
+}
+
+func newPromptProxy(mcpServer *server.MCPServer, registry *hierarchy.ServerRegistry) *promptProxy {
+	p := &promptProxy{mcpServer: mcpServer, registry: registry, names: make(map[string][]string)}
+	registry.OnPromptListChanged(func(serverName string) {
+		p.sync(context.Background(), serverName)
+	})
+	return p
+}
+
+// sync replaces the listed prompts of the given server with its current ones
+func (p *promptProxy) sync(ctx context.Context, serverName string) {
+	prompts, err := p.registry.ListPrompts(ctx, serverName)
+	if errors.Is(err, hierarchy.ErrUnknownServer) {
+		prompts, err = nil, nil
+	}
+	if err != nil {
+		logging.ForServer(serverName).WarnContext(ctx, "Failed to list prompts", "error", err)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if old := p.names[serverName]; len(old) > 0 {
+		p.mcpServer.DeletePrompts(old...)
+	}
+	delete(p.names, serverName)
+	if len(prompts) == 0 {
+		return
+	}
+
+	entries := make([]server.ServerPrompt, 0, len(prompts))
+	names := make([]string, 0, len(prompts))
+	for _, prompt := range prompts {
+		entries = append(entries, server.ServerPrompt{Prompt: prompt, Handler: p.get})
+		names = append(names, prompt.Name)
+	}
+	p.mcpServer.AddPrompts(entries...)
+	p.names[serverName] = names
+}
+
+func (p *promptProxy) get(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	return p.registry.GetPrompt(ctx, request.Params.Name, request.Params.Arguments)
+}
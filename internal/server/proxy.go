@@ -0,0 +1,229 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/voicetreelab/lazy-mcp/internal/audit"
+	"github.com/voicetreelab/lazy-mcp/internal/cassette"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+	"github.com/voicetreelab/lazy-mcp/internal/orphans"
+	"github.com/voicetreelab/lazy-mcp/internal/redact"
+	"github.com/voicetreelab/lazy-mcp/internal/serverlog"
+)
+
+// ProxyOptions customize a Proxy beyond its config, for programs embedding
+// lazy-mcp
+type ProxyOptions struct {
+	// Middlewares wrap every tool call; see hierarchy.Hierarchy.Use
+	Middlewares []hierarchy.CallMiddleware
+	// OnEvent is subscribed to the registry's events before any server
+	// starts; see hierarchy.EventBus
+	OnEvent hierarchy.EventHandler
+}
+
+// Proxy is the MCP server exposing the hierarchy, with the registry of the
+// servers behind it and its background tasks
+type Proxy struct {
+	MCPServer *server.MCPServer
+	Hierarchy *hierarchy.Hierarchy
+	Registry  *hierarchy.ServerRegistry
+
+	cfg       *config.Config
+	dashboard *dashboard
+	cancel    context.CancelFunc
+	closers   []func()
+
+	// intercept answers the requests mcp-go's server does not handle
+	intercept *interceptor
+}
+
+// NewProxy loads the hierarchy and sets up the proxy for cfg. Its background
+// tasks run until ctx is done or the proxy is closed.
+func NewProxy(ctx context.Context, cfg *config.Config, options ProxyOptions) (*Proxy, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &Proxy{cfg: cfg, cancel: cancel}
+	fail := func(err error) (*Proxy, error) {
+		p.Close()
+		return nil, err
+	}
+
+	// Load hierarchy from filesystem
+	slog.Info("Loading hierarchy", "path", cfg.McpProxy.HierarchyPath)
+	h, err := hierarchy.LoadHierarchy(cfg.McpProxy.HierarchyPath)
+	if err != nil {
+		return fail(fmt.Errorf("failed to load hierarchy: %w", err))
+	}
+	p.Hierarchy = h
+
+	redactor, err := redact.New(cfg.McpProxy.Redaction)
+	if err != nil {
+		return fail(err)
+	}
+	auditLog, err := audit.Open(cfg.McpProxy.Audit, redactor)
+	if err != nil {
+		return fail(err)
+	}
+	p.onClose(func() { _ = auditLog.Close() })
+	h.SetAuditLog(auditLog)
+	h.Use(options.Middlewares...)
+	// Innermost, so middlewares see replayed calls like real ones
+	recording, err := cassette.Open(cfg.McpProxy.Cassette)
+	if err != nil {
+		return fail(err)
+	}
+	if recording != nil {
+		h.Use(recording)
+	}
+
+	// Opened first so it is closed last, after the servers' final output
+	serverLogs, err := serverlog.Open(cfg.McpProxy.ServerLogs)
+	if err != nil {
+		return fail(err)
+	}
+	p.onClose(serverLogs.Close)
+
+	// Before any server starts, whose earlier processes a crashed lazy-mcp
+	// may have left running
+	cleanupOrphans()
+
+	// Create server registry for lazy-loaded MCP clients
+	p.Registry = hierarchy.NewServerRegistry(cfg.McpServers)
+	p.onClose(p.Registry.Close)
+	p.Registry.OnStderr(serverLogs.Writer)
+	if options.OnEvent != nil {
+		p.Registry.Events().Subscribe(options.OnEvent)
+	}
+	h.SetToolFilter(p.Registry.ToolIncluded)
+	h.SetToolOverrides(p.Registry)
+	if cfg.McpProxy.Options != nil {
+		h.SetTokenBudget(cfg.McpProxy.Options.ToolTokenBudget.OrElse(0))
+		h.SetDryRun(cfg.McpProxy.Options.DryRun.OrElse(false))
+		p.Registry.SetCallLimit(cfg.McpProxy.Options.MaxTotalConcurrent.OrElse(0))
+		h.SetDuplicateTools(cfg.McpProxy.Options.DuplicateTools.OrElse(config.DuplicateToolsPrefix), cfg.McpProxy.Options.ServerPriority)
+		p.Registry.SetExposeTags(cfg.McpProxy.Options.ExposeTags)
+	}
+	h.AddVirtualServers(cfg.McpProxy.VirtualServers)
+	if err := checkDuplicateTools(cfg, h, cfg.McpServers); err != nil {
+		return fail(err)
+	}
+	if err := hierarchy.CheckResultTransforms(cfg.McpServers); err != nil {
+		return fail(err)
+	}
+	startSearchIndex(ctx, cfg, h)
+	p.onClose(startUsageTracking(ctx, cfg, h))
+
+	sessions := hierarchy.NewSessionManager(0)
+	go sessions.StartExpiry(ctx)
+
+	// The proxy server hooks into the registry before any server starts.
+	// listed is closed once a client has first listed tools, which schemas
+	// are only prefetched after, so as not to delay it.
+	listed := make(chan struct{})
+	// Notifications reach each client through a bounded queue of its own
+	notify := newNotifier(cfg.McpProxy.Options)
+	p.MCPServer = newProxyMCPServer(cfg, h, p.Registry, sessions, serverLogs, redactor, notify, listed)
+	p.intercept = newInterceptor()
+	addSubscriptions(p.intercept, notify, p.Registry)
+	addCompletions(p.intercept, p.Registry)
+	if cfg.McpProxy.Options != nil && cfg.McpProxy.Options.AdminTools.OrElse(false) {
+		p.dashboard = newDashboard(p.Registry)
+		p.onClose(p.dashboard.close)
+	}
+	// Replaying must not start servers, not even to prewarm them
+	if !cfg.McpProxy.Cassette.Replaying() {
+		startRegistryTasks(ctx, cfg, p.Registry)
+		startSchemaPrefetch(ctx, cfg, h, p.Registry, listed)
+	}
+	watchConfig(ctx, cfg, h, p.Registry)
+	return p, nil
+}
+
+// HTTPHandler serves the proxy over HTTP as StartHTTPServer does, with the
+// server statuses at /healthz and, with options.adminTools, the dashboard's
+// endpoints under /admin
+func (p *Proxy) HTTPHandler(ctx context.Context) (http.Handler, error) {
+	var admin http.Handler
+	if p.dashboard != nil {
+		admin = p.dashboard.handler()
+	}
+	return newHTTPHandler(ctx, p.cfg, p.MCPServer, p.Registry, p.intercept, admin)
+}
+
+// DefaultShutdownTimeout is how long shutting down waits for the tool calls
+// in flight, unless options.shutdownTimeout says otherwise
+const DefaultShutdownTimeout = 30 * time.Second
+
+// Drain refuses new tool calls and waits for those in flight to finish, or
+// for ctx to be done
+func (p *Proxy) Drain(ctx context.Context) {
+	slog.Info("Waiting for tool calls in flight to finish")
+	if remaining := p.Registry.Drain(ctx); remaining > 0 {
+		slog.Warn("Shutting down with tool calls still in flight", "calls", remaining)
+	}
+}
+
+// Shutdown drains the proxy, giving the tool calls in flight until ctx is
+// done, then closes it
+func (p *Proxy) Shutdown(ctx context.Context) {
+	p.Drain(ctx)
+	p.Close()
+}
+
+// drainOnSignal drains the proxy for up to options.shutdownTimeout, or until
+// another signal arrives on signals
+func (p *Proxy) drainOnSignal(signals <-chan os.Signal) {
+	timeout := DefaultShutdownTimeout
+	if p.cfg.McpProxy.Options != nil {
+		timeout = p.cfg.McpProxy.Options.ShutdownTimeout.OrElse(config.Duration(timeout)).Std()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-signals:
+			slog.Warn("Second shutdown signal received, not waiting for tool calls")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	p.Drain(ctx)
+}
+
+// Close stops the proxy's servers and background tasks, and saves its tool
+// usage stats
+func (p *Proxy) Close() {
+	p.cancel()
+	// In reverse, like deferred calls
+	for i := len(p.closers) - 1; i >= 0; i-- {
+		p.closers[i]()
+	}
+	p.closers = nil
+}
+
+func (p *Proxy) onClose(fn func()) {
+	p.closers = append(p.closers, fn)
+}
+
+// cleanupOrphans kills the server processes, and removes the containers,
+// that lazy-mcps which are no longer running left behind
+func cleanupOrphans() {
+	dir, err := orphans.Dir()
+	if err != nil {
+		slog.Warn("Failed to clean up orphaned servers", "error", err)
+		return
+	}
+	found, err := orphans.Cleanup(dir, false)
+	for _, orphan := range found {
+		slog.Warn("Cleaned up orphaned server", "server", orphan.Server, "pid", orphan.PID, "container", orphan.Container, "owner", orphan.Owner)
+	}
+	if err != nil {
+		slog.Warn("Failed to clean up orphaned servers", "error", err)
+	}
+}
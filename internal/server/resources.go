@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+)
+
+// resourceProxy mirrors the resources and resource templates of downstream
+// servers into the proxy's lists. A server's resources are listed once it has
+// been started and stay listed until it is removed from the config; reading
+// one, or a URI expanded from one of its templates, restarts the server if
+// it was stopped in the meantime.
+type resourceProxy struct {
+	mcpServer *server.MCPServer
+	registry  *hierarchy.ServerRegistry
+
+	mu        sync.Mutex
+	uris      map[string][]string                        // Namespaced URIs listed per server
+	templates map[string][]server.ServerResourceTemplate // Namespaced templates listed per server
+}
+
+func newResourceProxy(mcpServer *server.MCPServer, registry *hierarchy.ServerRegistry) *resourceProxy {
+	p := &resourceProxy{
+		mcpServer: mcpServer,
+		registry:  registry,
+		uris:      make(map[string][]string),
+		templates: make(map[string][]server.ServerResourceTemplate),
+	}
+	p.setTemplates()
+	registry.OnResourceListChanged(func(serverName string) {
+		p.sync(context.Background(), serverName)
+	})
+	return p
+}
+
+// sync replaces the listed resources and resource templates of the given
+// server with its current ones
+func (p *resourceProxy) sync(ctx context.Context, serverName string) {
+	resources, err := p.registry.ListResources(ctx, serverName)
+	if errors.Is(err, hierarchy.ErrUnknownServer) {
+		resources, err = nil, nil
+	}
+	if err != nil {
+		logging.ForServer(serverName).WarnContext(ctx, "Failed to list resources", "error", err)
+		return
+	}
+	templates, err := p.registry.ListResourceTemplates(ctx, serverName)
+	if errors.Is(err, hierarchy.ErrUnknownServer) {
+		templates, err = nil, nil
+	}
+	if err != nil {
+		// Servers may list resources without offering templates
+		logging.ForServer(serverName).DebugContext(ctx, "Failed to list resource templates", "error", err)
+		templates = nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if old := p.uris[serverName]; len(old) > 0 {
+		p.mcpServer.DeleteResources(old...)
+	}
+	delete(p.uris, serverName)
+	if len(resources) > 0 {
+		entries := make([]server.ServerResource, 0, len(resources))
+		uris := make([]string, 0, len(resources))
+		for _, resource := range resources {
+			entries = append(entries, server.ServerResource{Resource: resource, Handler: p.read})
+			uris = append(uris, resource.URI)
+		}
+		p.mcpServer.AddResources(entries...)
+		p.uris[serverName] = uris
+	}
+
+	if len(templates) == 0 && len(p.templates[serverName]) == 0 {
+		return
+	}
+	delete(p.templates, serverName)
+	for _, template := range templates {
+		p.templates[serverName] = append(p.templates[serverName], server.ServerResourceTemplate{Template: template, Handler: p.read})
+	}
+	p.setTemplates()
+}
+
+// setTemplates lists the templates of every server, and that of tool results
+// saved rather than returned inline. As templates can only be replaced all
+// at once, the caller must hold p.mu unless no server's are listed yet.
+func (p *resourceProxy) setTemplates() {
+	entries := []server.ServerResourceTemplate{{
+		Template: mcp.NewResourceTemplate(hierarchy.ResultScheme+"{id}", "Tool result", mcp.WithTemplateDescription("A tool result, or binary content of one, saved rather than returned inline")),
+		Handler: func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			return p.registry.ReadResult(request.Params.URI)
+		},
+	}}
+	for _, templates := range p.templates {
+		entries = append(entries, templates...)
+	}
+	p.mcpServer.SetResourceTemplates(entries...)
+}
+
+func (p *resourceProxy) read(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	return p.registry.ReadResource(ctx, request.Params.URI)
+}
@@ -5,18 +5,27 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
-	"github.com/voicetreelab/lazy-mcp/internal/config"
-	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/voicetreelab/lazy-mcp/internal/approval"
+	"github.com/voicetreelab/lazy-mcp/internal/client"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/embedding"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+	"github.com/voicetreelab/lazy-mcp/internal/logging"
+	"github.com/voicetreelab/lazy-mcp/internal/redact"
+	"github.com/voicetreelab/lazy-mcp/internal/serverlog"
+	"github.com/voicetreelab/lazy-mcp/internal/telemetry"
 )
 
 type MiddlewareFunc func(http.Handler) http.Handler
@@ -28,34 +37,10 @@ func chainMiddleware(h http.Handler, middlewares ...MiddlewareFunc) http.Handler
 	return h
 }
 
-func newAuthMiddleware(tokens []string) MiddlewareFunc {
-	tokenSet := make(map[string]struct{}, len(tokens))
-	for _, token := range tokens {
-		tokenSet[token] = struct{}{}
-	}
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if len(tokens) != 0 {
-				token := r.Header.Get("Authorization")
-				token = strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
-				if token == "" {
-					http.Error(w, "Unauthorized", http.StatusUnauthorized)
-					return
-				}
-				if _, ok := tokenSet[token]; !ok {
-					http.Error(w, "Unauthorized", http.StatusUnauthorized)
-					return
-				}
-			}
-			next.ServeHTTP(w, r)
-		})
-	}
-}
-
 func loggerMiddleware(prefix string) MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			log.Printf("<%s> Request [%s] %s", prefix, r.Method, r.URL.Path)
+			slog.InfoContext(r.Context(), "HTTP request", "handler", prefix, "method", r.Method, "path", r.URL.Path)
 			next.ServeHTTP(w, r)
 		})
 	}
@@ -66,7 +51,7 @@ func recoverMiddleware(prefix string) MiddlewareFunc {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if err := recover(); err != nil {
-					log.Printf("<%s> Recovered from panic: %v", prefix, err)
+					slog.ErrorContext(r.Context(), "Recovered from panic", "handler", prefix, "panic", err)
 					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				}
 			}()
@@ -75,28 +60,113 @@ func recoverMiddleware(prefix string) MiddlewareFunc {
 	}
 }
 
-// StartStdioServer starts the stdio server with the given configuration
-func StartStdioServer(cfg *config.Config) error {
-	// Load hierarchy from filesystem
-	log.Printf("Loading hierarchy from %s", cfg.McpProxy.HierarchyPath)
-	h, err := hierarchy.LoadHierarchy(cfg.McpProxy.HierarchyPath)
-	if err != nil {
-		return fmt.Errorf("failed to load hierarchy: %w", err)
+// httpContext prepares the context of a request from an HTTP client: tool
+// calls join the client's trace, if it sent one, and keep its X-Request-ID
+func httpContext(ctx context.Context, r *http.Request) context.Context {
+	ctx = telemetry.ExtractHTTP(ctx, r)
+	if id := r.Header.Get("X-Request-ID"); id != "" {
+		ctx = logging.WithRequestID(ctx, id)
+	}
+	return ctx
+}
+
+// withRequestID gives every tool call a request ID, so that all records
+// logged while handling it can be correlated: the one the client sent in the
+// call's _meta, else its X-Request-ID, else a new one
+func withRequestID(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if id := hierarchy.MetaRequestIDOf(request.Params.Meta); id != "" {
+			ctx = logging.WithRequestID(ctx, id)
+		} else if logging.RequestID(ctx) == "" {
+			ctx = logging.WithRequestID(ctx, logging.NewRequestID())
+		}
+		return next(ctx, request)
 	}
+}
 
-	// Create server registry for lazy-loaded MCP clients
-	registry := hierarchy.NewServerRegistry(cfg.McpServers)
-	defer registry.Close()
+// withProgress relays the progress notifications of the server a tool call
+// goes to through notify, if the client asked for progress
+func withProgress(notify *notifier) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+				return next(ctx, request)
+			}
+			token := request.Params.Meta.ProgressToken
+			ctx = hierarchy.WithProgress(ctx, func(progress, total float64, message string) {
+				params := map[string]any{"progressToken": token, "progress": progress}
+				if total > 0 {
+					params["total"] = total
+				}
+				if message != "" {
+					params["message"] = message
+				}
+				if err := notify.sendToClient(ctx, hierarchy.MethodNotificationProgress, params); err != nil {
+					slog.DebugContext(ctx, "Failed to relay progress", "error", err)
+				}
+			})
+			return next(ctx, request)
+		}
+	}
+}
 
-	// Create ONE MCP server with 2 meta-tools
+// withClientTimeout bounds a tool call by the timeout the client sent in its
+// _meta, which then also bounds the call's retries
+func withClientTimeout(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		timeout, err := hierarchy.MetaTimeoutOf(request.Params.Meta)
+		if err != nil {
+			slog.WarnContext(ctx, "Ignoring the client's timeout", "error", err)
+		}
+		if timeout <= 0 {
+			return next(ctx, request)
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return next(ctx, request)
+	}
+}
+
+// newProxyMCPServer creates the MCP server exposing the hierarchy meta-tools
+func newProxyMCPServer(cfg *config.Config, h *hierarchy.Hierarchy, registry *hierarchy.ServerRegistry, sessions *hierarchy.SessionManager, serverLogs *serverlog.Logs, redactor *redact.Redactor, notify *notifier, listed chan<- struct{}) *server.MCPServer {
+	// Forget a client's lazy-loading state and resource subscriptions as soon
+	// as it disconnects
+	hooks := &server.Hooks{}
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		sessions.Remove(session.SessionID())
+		registry.UnsubscribeSession(ctx, session.SessionID())
+	})
+	cancels := newCancellations()
+	cancels.addHooks(hooks)
+	notify.addHooks(hooks)
+	// A client setting the level of log messages sets it on the servers too
+	hooks.AddAfterSetLevel(func(ctx context.Context, id any, message *mcp.SetLevelRequest, result *mcp.EmptyResult) {
+		go registry.SetLogMessageLevel(context.WithoutCancel(ctx), message.Params.Level)
+	})
+	var listedOnce sync.Once
+	hooks.AddAfterListTools(func(ctx context.Context, id any, message *mcp.ListToolsRequest, result *mcp.ListToolsResult) {
+		listedOnce.Do(func() { close(listed) })
+	})
+
+	// Create ONE MCP server with the meta-tools
 	serverOpts := []server.ServerOption{
+		server.WithToolCapabilities(true),
 		server.WithResourceCapabilities(true, true),
+		server.WithPromptCapabilities(true),
+		server.WithRoots(),
 		server.WithRecovery(),
+		server.WithHooks(hooks),
+		server.WithToolHandlerMiddleware(withRequestID),
+		server.WithToolHandlerMiddleware(withProgress(notify)),
+		server.WithToolHandlerMiddleware(withClientTimeout),
+		server.WithToolHandlerMiddleware(cancels.middleware),
 	}
 
-	if cfg.McpProxy.Options != nil && cfg.McpProxy.Options.LogEnabled.OrElse(false) {
-		serverOpts = append(serverOpts, server.WithLogging())
-	}
+	// Log messages of downstream servers are relayed to clients, which set
+	// their level
+	serverOpts = append(serverOpts, server.WithLogging())
+	// Approvals and downstream servers both elicit from clients
+	serverOpts = append(serverOpts, server.WithElicitation())
 
 	mcpServer := server.NewMCPServer(
 		cfg.McpProxy.Name,
@@ -104,6 +174,138 @@ func StartStdioServer(cfg *config.Config) error {
 		serverOpts...,
 	)
 
+	// A client cancelling a call aborts it downstream too
+	mcpServer.AddNotificationHandler(client.MethodNotificationCancelled, cancels.cancelled)
+
+	// Servers may sample the LLM of the client whose request they are serving
+	mcpServer.EnableSampling()
+	registry.OnSamplingRequest(mcpServer.RequestSampling)
+
+	// Servers may ask the user of the client whose request they are serving
+	// for input, unless they are configured to be declined
+	registry.OnElicitationRequest(hierarchy.ElicitationFunc(elicitFrom(mcpServer)))
+
+	// Servers' log messages go to the client that used them last, subject to
+	// its own level
+	registry.OnLogMessage(func(ctx context.Context, notification mcp.LoggingMessageNotification) {
+		if err := notify.sendLogMessage(ctx, notification); err != nil {
+			slog.Debug("Failed to relay log message", "logger", notification.Params.Logger, "error", err)
+		}
+	})
+
+	// Servers see the roots of the client they serve, and hear when they change
+	registry.OnRootsRequest(mcpServer.RequestRoots)
+	mcpServer.AddNotificationHandler(mcp.MethodNotificationRootsListChanged, func(ctx context.Context, notification mcp.JSONRPCNotification) {
+		registry.RootsChanged(ctx)
+	})
+
+	// Calls that require approval wait for an answer, by default from the
+	// user of the client making them
+	if cfg.McpProxy.Approval != nil {
+		h.SetApproval(cfg.McpProxy.Approval, approval.New(cfg.McpProxy.Approval, elicitFrom(mcpServer), redactor))
+	}
+
+	// Resources and resource templates of downstream servers are listed under
+	// lazy-mcp:// URIs, along with tool results saved under lazy-mcp-result://
+	// ones, and their prompts as <server>.<prompt>
+	newResourceProxy(mcpServer, registry)
+	newPromptProxy(mcpServer, registry)
+
+	// Flat exposure lists every tool directly, and hierarchical exposure none,
+	// hiding them behind the meta-tools. Hybrid exposure hides all but the
+	// pinned and most used tools, saving a round trip to them.
+	exposure := config.ExposureHybrid
+	if cfg.McpProxy.Options != nil {
+		exposure = cfg.McpProxy.Options.Exposure.OrElse(config.ExposureHybrid)
+	}
+	// Tools listed directly are named after their paths, which clients may
+	// not all accept
+	naming := newToolNaming(cfg)
+	exposeExpandedTools := cfg.McpProxy.Options != nil && cfg.McpProxy.Options.ExposeExpandedTools.OrElse(false)
+	var direct *toolList
+	switch exposure {
+	case config.ExposureFlat:
+		naming.check(h.ToolPaths())
+		direct = newToolList(mcpServer, h, registry, naming, h.ToolPaths)
+	case config.ExposureHierarchical:
+		addBrowsingTools(cfg, mcpServer, h, registry, sessions)
+		if exposeExpandedTools {
+			naming.check(h.ToolPaths())
+		}
+		direct = newToolList(mcpServer, h, registry, naming, func() []string { return nil })
+	default:
+		addBrowsingTools(cfg, mcpServer, h, registry, sessions)
+		// The tools used most on earlier runs are promoted like pinned ones
+		promoted := h.MostUsedTools(cfg.McpProxy.Usage.Promote())
+		pinnedPaths := func() []string {
+			var pinned []string
+			toolPaths := h.ToolPaths()
+			for _, toolPath := range toolPaths {
+				if cfg.McpProxy.Options.ToolPinned(toolPath) {
+					pinned = append(pinned, toolPath)
+				}
+			}
+			for _, toolPath := range promoted {
+				if !slices.Contains(pinned, toolPath) && slices.Contains(toolPaths, toolPath) {
+					pinned = append(pinned, toolPath)
+				}
+			}
+			return pinned
+		}
+		if cfg.McpProxy.Options != nil && len(cfg.McpProxy.Options.Pin) > 0 && !slices.ContainsFunc(h.ToolPaths(), cfg.McpProxy.Options.ToolPinned) {
+			slog.Warn("No tools match the pinned tool patterns", "pin", cfg.McpProxy.Options.Pin)
+		}
+		if exposeExpandedTools {
+			naming.check(h.ToolPaths())
+		} else {
+			naming.check(pinnedPaths())
+		}
+		direct = newToolList(mcpServer, h, registry, naming, pinnedPaths)
+		if count := direct.count(); count > 0 {
+			slog.Info("Listing tools directly", "tools", count, "promoted", len(promoted))
+		}
+	}
+
+	// Clients re-list tools when a downstream server reports that its changed,
+	// or the config changed, by then without tools that are no longer listed.
+	// mcp-go tells them itself when the tools listed directly changed.
+	registry.Events().Subscribe(func(ctx context.Context, event hierarchy.Event) {
+		if event.Type == hierarchy.EventHierarchyChanged && !direct.sync() {
+			notify.sendToAll(mcp.MethodNotificationToolsListChanged, nil)
+		}
+	})
+
+	// Register list_servers meta-tool
+	listServersTool := mcp.Tool{
+		Name:        "list_servers",
+		Description: "List the configured MCP servers with their transport, whether they are running, and their latest health check result.",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]interface{}{},
+		},
+	}
+
+	mcpServer.AddTool(listServersTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return newJSONResult(map[string]interface{}{
+			"servers": registry.ServerStatuses(),
+		})
+	})
+
+	if cfg.McpProxy.Options != nil && cfg.McpProxy.Options.AdminTools.OrElse(false) {
+		addAdminTools(cfg, mcpServer, h, registry, serverLogs)
+	}
+	addFanOutTools(cfg, mcpServer, h, registry)
+	addPipelineTools(cfg, mcpServer, h, registry)
+
+	return mcpServer
+}
+
+// addBrowsingTools registers the meta-tools for finding and calling the
+// tools of the hierarchy
+func addBrowsingTools(cfg *config.Config, mcpServer *server.MCPServer, h *hierarchy.Hierarchy, registry *hierarchy.ServerRegistry, sessions *hierarchy.SessionManager) {
+	exposeExpandedTools := cfg.McpProxy.Options != nil && cfg.McpProxy.Options.ExposeExpandedTools.OrElse(false)
+	naming := newToolNaming(cfg)
+
 	// Register get_tools_in_category meta-tool
 	// Build description from root overview
 	description := "You have MCP tools hidden within categories. You MUST use get_tools_in_category to learn more about what available tools you have within these categories. Returns children categories, and tools at the specified path. Call initially with an empty string to get root categories."
@@ -143,16 +345,13 @@ func StartStdioServer(cfg *config.Config) error {
 			return nil, err
 		}
 
-		jsonBytes, err := json.MarshalIndent(response, "", "  ")
-		if err != nil {
-			return nil, err
+		// Expansions are tracked per client so they never show up for others
+		revealed := sessions.Get(sessionIDFromContext(ctx)).Expand(path, toolPathsOf(response))
+		if exposeExpandedTools && len(revealed) > 0 {
+			exposeTools(ctx, mcpServer, h, registry, naming, revealed)
 		}
 
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.NewTextContent(string(jsonBytes)),
-			},
-		}, nil
+		return newJSONResult(response)
 	})
 
 	// Register execute_tool meta-tool
@@ -195,156 +394,544 @@ func StartStdioServer(cfg *config.Config) error {
 			return nil, fmt.Errorf("tool_path is required")
 		}
 
-		return h.HandleExecuteTool(ctx, registry, toolPath, arguments)
+		return toolResult(h.HandleExecuteTool(ctx, registry, toolPath, arguments))
 	})
 
-	// Serve via stdio
-	log.Printf("Starting hierarchical MCP proxy (stdio server)")
-	return server.ServeStdio(mcpServer)
-}
-
-// StartHTTPServer starts the HTTP server with the given configuration
-func StartHTTPServer(cfg *config.Config) error {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Load hierarchy from filesystem
-	log.Printf("Loading hierarchy from %s", cfg.McpProxy.HierarchyPath)
-	h, err := hierarchy.LoadHierarchy(cfg.McpProxy.HierarchyPath)
-	if err != nil {
-		return fmt.Errorf("failed to load hierarchy: %w", err)
-	}
-
-	// Create server registry for lazy-loaded MCP clients
-	registry := hierarchy.NewServerRegistry(cfg.McpServers)
-	defer registry.Close()
-
-	// Create ONE MCP server with 2 meta-tools
-	serverOpts := []server.ServerOption{
-		server.WithResourceCapabilities(true, true),
-		server.WithRecovery(),
-	}
-
-	if cfg.McpProxy.Options != nil && cfg.McpProxy.Options.LogEnabled.OrElse(false) {
-		serverOpts = append(serverOpts, server.WithLogging())
+	// Register batch_call meta-tool
+	batchCallTool := mcp.Tool{
+		Name:        "batch_call",
+		Description: fmt.Sprintf("Execute several tools by their full paths in one call, like execute_tool, and return their results in the same order. Calls to tools of the same server run one after another, in order; calls to different servers run in parallel. A failed call does not stop the others. At most %d calls.", hierarchy.MaxBatchCalls),
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"calls": map[string]interface{}{
+					"type":        "array",
+					"description": "The tool calls to make",
+					"maxItems":    hierarchy.MaxBatchCalls,
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"tool_path": map[string]interface{}{
+								"type":        "string",
+								"description": "Full tool path using dot notation, as for execute_tool",
+							},
+							"arguments": map[string]interface{}{
+								"type":                 "object",
+								"description":          "Arguments to pass to the tool",
+								"additionalProperties": true,
+							},
+						},
+						"required": []string{"tool_path"},
+					},
+				},
+			},
+			Required: []string{"calls"},
+		},
 	}
 
-	mcpServer := server.NewMCPServer(
-		cfg.McpProxy.Name,
-		cfg.McpProxy.Version,
-		serverOpts...,
-	)
-
-	// Register get_tools_in_category meta-tool
-	// Build description from root overview
-	description := "You have MCP tools hidden within categories. You MUST use get_tools_in_category to learn more about what available tools you have within these categories. Returns children categories, and tools at the specified path. Call initially with an empty string to get root categories."
+	mcpServer.AddTool(batchCallTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var calls []hierarchy.BatchCall
+		if argsMap, ok := request.Params.Arguments.(map[string]interface{}); ok {
+			callsVal, _ := argsMap["calls"].([]interface{})
+			for _, callVal := range callsVal {
+				callMap, _ := callVal.(map[string]interface{})
+				toolPath, _ := callMap["tool_path"].(string)
+				if toolPath == "" {
+					return nil, fmt.Errorf("tool_path is required for every call")
+				}
+				arguments, _ := callMap["arguments"].(map[string]interface{})
+				if arguments == nil {
+					arguments = make(map[string]interface{})
+				}
+				calls = append(calls, hierarchy.BatchCall{ToolPath: toolPath, Arguments: arguments})
+			}
+		}
+		if len(calls) == 0 {
+			return nil, fmt.Errorf("calls is required")
+		}
+		if len(calls) > hierarchy.MaxBatchCalls {
+			return nil, fmt.Errorf("at most %d calls may be batched, got %d", hierarchy.MaxBatchCalls, len(calls))
+		}
 
-	// Get root node and use its overview
-	if rootNode := h.GetRootNode(); rootNode != nil && rootNode.Overview != "" {
-		description += fmt.Sprintf("\n\n%s", rootNode.Overview)
-	}
+		// Each call's outcome is reported as execute_tool would have
+		results := make([]map[string]interface{}, len(calls))
+		for i, outcome := range h.HandleBatchCall(ctx, registry, calls) {
+			results[i] = map[string]interface{}{"tool_path": calls[i].ToolPath}
+			result, err := toolResult(outcome.Result, outcome.Err)
+			if err != nil {
+				results[i]["error"] = err.Error()
+			} else {
+				results[i]["result"] = result
+			}
+		}
+		return newJSONResult(map[string]interface{}{"results": results})
+	})
 
-	getToolsInCategoryTool := mcp.Tool{
-		Name:        "get_tools_in_category",
-		Description: description,
+	// Register search_tools meta-tool
+	searchToolsTool := mcp.Tool{
+		Name:        "search_tools",
+		Description: "Search all tools across all categories by name and description, tolerating typos. Returns the best matches with their tool_path and inputSchema, ready for execute_tool, without browsing categories.",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]interface{}{
-				"path": map[string]interface{}{
+				"query": map[string]interface{}{
 					"type":        "string",
-					"description": "Category path using dot notation (e.g., 'coding_tools' or 'coding_tools.serena.search'). Use empty string or '/' for root.",
+					"description": "Words describing the tool (e.g., 'create issue' or 'send email')",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Maximum number of matches to return (default %d)", hierarchy.DefaultSearchLimit),
 				},
 			},
-			Required: []string{"path"},
+			Required: []string{"query"},
 		},
 	}
 
-	mcpServer.AddTool(getToolsInCategoryTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		path := ""
+	mcpServer.AddTool(searchToolsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		query := ""
+		limit := hierarchy.DefaultSearchLimit
 		if request.Params.Arguments != nil {
 			if argsMap, ok := request.Params.Arguments.(map[string]interface{}); ok {
-				if pathVal, ok := argsMap["path"].(string); ok {
-					path = pathVal
+				if queryVal, ok := argsMap["query"].(string); ok {
+					query = queryVal
+				}
+				if limitVal, ok := argsMap["limit"].(float64); ok {
+					limit = int(limitVal)
 				}
 			}
 		}
 
-		response, err := h.HandleGetToolsInCategory(path)
+		response, err := h.HandleSearchTools(ctx, query, limit)
 		if err != nil {
 			return nil, err
 		}
-
-		jsonBytes, err := json.MarshalIndent(response, "", "  ")
-		if err != nil {
-			return nil, err
-		}
-
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.NewTextContent(string(jsonBytes)),
-			},
-		}, nil
+		return newJSONResult(response)
 	})
 
-	// Register execute_tool meta-tool
-	executeToolTool := mcp.Tool{
-		Name:        "execute_tool",
-		Description: "Execute a tool by its full path. Automatically proxies the request to the appropriate MCP server.",
+	// Register list_tools_by_tag meta-tool
+	listToolsByTagTool := mcp.Tool{
+		Name:        "list_tools_by_tag",
+		Description: "List the tools with a tag, such as readonly or ci, with their tool_path and inputSchema, ready for execute_tool. Without a tag, lists the tags tools have and how many have each.",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]interface{}{
-				"tool_path": map[string]interface{}{
+				"tag": map[string]interface{}{
 					"type":        "string",
-					"description": "Full tool path using dot notation (e.g., 'coding_tools.serena.search.search_symbol') or just tool name if unique",
-				},
-				"arguments": map[string]interface{}{
-					"type":                 "object",
-					"description":          "Arguments to pass to the tool",
-					"additionalProperties": true,
+					"description": "The tag to list the tools of; omit to list the tags",
 				},
 			},
-			Required: []string{"tool_path", "arguments"},
 		},
 	}
 
-	mcpServer.AddTool(executeToolTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		toolPath := ""
-		arguments := make(map[string]interface{})
+	mcpServer.AddTool(listToolsByTagTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return newJSONResult(h.HandleListToolsByTag(request.GetString("tag", "")))
+	})
+}
 
-		if request.Params.Arguments != nil {
-			if argsMap, ok := request.Params.Arguments.(map[string]interface{}); ok {
-				if pathVal, ok := argsMap["tool_path"].(string); ok {
-					toolPath = pathVal
-				}
-				if argsVal, ok := argsMap["arguments"].(map[string]interface{}); ok {
-					arguments = argsVal
+// sessionIDFromContext returns the MCP session ID of the client making the request
+func sessionIDFromContext(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
+
+// elicitFrom elicits from the client making the request through mcpServer,
+// failing fast for clients that did not declare support for elicitation
+func elicitFrom(mcpServer *server.MCPServer) approval.ElicitFunc {
+	return func(ctx context.Context, request mcp.ElicitationRequest) (*mcp.ElicitationResult, error) {
+		session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithClientInfo)
+		if ok && session.GetClientCapabilities().Elicitation == nil {
+			return nil, fmt.Errorf("client does not support elicitation")
+		}
+		return mcpServer.RequestElicitation(ctx, request)
+	}
+}
+
+// toolPathsOf extracts the tool paths listed in a get_tools_in_category response
+func toolPathsOf(response map[string]interface{}) []string {
+	var paths []string
+	addPaths := func(tools map[string]interface{}) {
+		for _, info := range tools {
+			if infoMap, ok := info.(map[string]interface{}); ok {
+				if toolPath, ok := infoMap["tool_path"].(string); ok {
+					paths = append(paths, toolPath)
 				}
 			}
 		}
+	}
+	tools, _ := response["tools"].(map[string]interface{})
+	addPaths(tools)
+	// Subcategories listed in full under the token budget reveal their tools too
+	children, _ := response["children"].(map[string]interface{})
+	for _, child := range children {
+		if childMap, ok := child.(map[string]interface{}); ok {
+			childTools, _ := childMap["tools"].(map[string]interface{})
+			addPaths(childTools)
+		}
+	}
+	return paths
+}
 
-		if toolPath == "" {
-			return nil, fmt.Errorf("tool_path is required")
+// exposeTools adds the given hierarchy tools to the requesting client's tool
+// list, proxying calls like execute_tool. Clients on transports with
+// per-session tool lists see only their own expansions; single-client
+// transports such as stdio share the server's tool list.
+func exposeTools(ctx context.Context, mcpServer *server.MCPServer, h *hierarchy.Hierarchy, registry *hierarchy.ServerRegistry, naming toolNaming, toolPaths []string) {
+	tools := proxiedTools(h, registry, naming, toolPaths)
+	session := server.ClientSessionFromContext(ctx)
+	if _, ok := session.(server.SessionWithTools); !ok {
+		mcpServer.AddTools(tools...)
+		return
+	}
+	if err := mcpServer.AddSessionTools(session.SessionID(), tools...); err != nil {
+		slog.WarnContext(ctx, "Failed to expose tools to session", "tools", len(tools), "session", session.SessionID(), "error", err)
+	}
+}
+
+// proxiedTools returns the given hierarchy tools as MCP tools named by
+// naming that proxy calls like execute_tool, skipping those that no longer
+// resolve and those whose names clients would reject or an earlier tool took
+func proxiedTools(h *hierarchy.Hierarchy, registry *hierarchy.ServerRegistry, naming toolNaming, toolPaths []string) []server.ServerTool {
+	tools := make([]server.ServerTool, 0, len(toolPaths))
+	named := make(map[string]bool, len(toolPaths))
+	for _, toolPath := range toolPaths {
+		toolDef, _, err := h.ResolveExposedToolPath(toolPath)
+		if err != nil {
+			continue
+		}
+		name, ok := naming.name(toolPath)
+		if !ok || named[name] {
+			continue
+		}
+		named[name] = true
+
+		tool := mcp.Tool{
+			Name:        name,
+			Description: h.ToolDescription(toolPath, toolDef),
+			InputSchema: mcp.ToolInputSchema{Type: "object", Properties: map[string]interface{}{}},
+		}
+		if inputSchema := h.ToolSchema(toolPath, toolDef); inputSchema != nil {
+			if schema, err := json.Marshal(inputSchema); err == nil {
+				tool.InputSchema = mcp.ToolInputSchema{}
+				tool.RawInputSchema = schema
+			}
+		}
+
+		tools = append(tools, server.ServerTool{
+			Tool: tool,
+			Handler: func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				return toolResult(h.HandleExecuteTool(ctx, registry, toolPath, request.GetArguments()))
+			},
+		})
+	}
+	return tools
+}
+
+// toolResult returns the outcome of a proxied tool call. Timeouts, rate
+// limits, denied approvals, pending authorizations, invalid arguments and
+// results, and failures to reach or call the server are reported as tool
+// errors with structured content, so that clients can tell them apart and
+// know whether to retry; other errors are returned as is.
+func toolResult(result *mcp.CallToolResult, err error) (*mcp.CallToolResult, error) {
+	var structured map[string]any
+	var timeoutErr *hierarchy.TimeoutError
+	var rateLimitErr *hierarchy.RateLimitError
+	var approvalErr *hierarchy.ApprovalError
+	var authErr *hierarchy.AuthorizationRequiredError
+	var callErr *hierarchy.CallError
+	var argsErr *hierarchy.ArgumentsError
+	var outputErr *hierarchy.OutputError
+	switch {
+	case errors.As(err, &timeoutErr):
+		structured = map[string]any{
+			"error":     "timeout",
+			"server":    timeoutErr.Server,
+			"tool":      timeoutErr.Tool,
+			"timeout":   timeoutErr.Timeout.String(),
+			"retryable": true,
+		}
+	case errors.As(err, &rateLimitErr):
+		structured = map[string]any{
+			"error":      "rate_limited",
+			"server":     rateLimitErr.Server,
+			"retryAfter": rateLimitErr.RetryAfter.Round(time.Millisecond).String(),
+			"retryable":  true,
+		}
+		if rateLimitErr.Tool != "" {
+			structured["tool"] = rateLimitErr.Tool
+		}
+	case errors.As(err, &approvalErr):
+		structured = map[string]any{
+			"error":     "not_approved",
+			"server":    approvalErr.Server,
+			"tool":      approvalErr.Tool,
+			"reason":    approvalErr.Reason,
+			"retryable": false,
+		}
+	case errors.As(err, &authErr):
+		structured = map[string]any{
+			"error":            "authorization_required",
+			"server":           authErr.Server,
+			"authorizationUrl": authErr.URL,
+			"retryable":        false,
+		}
+	case errors.As(err, &argsErr):
+		structured = map[string]any{
+			"error":     "invalid_arguments",
+			"server":    argsErr.Server,
+			"tool":      argsErr.Tool,
+			"problems":  argsErr.Problems,
+			"retryable": false,
+		}
+	case errors.As(err, &outputErr):
+		structured = map[string]any{
+			"error":     "invalid_result",
+			"server":    outputErr.Server,
+			"tool":      outputErr.Tool,
+			"problems":  outputErr.Problems,
+			"retryable": false,
+		}
+	case errors.As(err, &callErr):
+		structured = map[string]any{
+			"error":     string(callErr.Class),
+			"server":    callErr.Server,
+			"tool":      callErr.Tool,
+			"retryable": callErr.Retryable,
+			"cause":     callErr.Err.Error(),
+		}
+		if callErr.Code != 0 {
+			structured["code"] = callErr.Code
+		}
+	default:
+		return result, err
+	}
+	result = mcp.NewToolResultStructured(structured, err.Error())
+	result.IsError = true
+	return result, nil
+}
+
+// newJSONResult wraps v as indented JSON text content
+func newJSONResult(v interface{}) (*mcp.CallToolResult, error) {
+	jsonBytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.NewTextContent(string(jsonBytes)),
+		},
+	}, nil
+}
+
+// startRegistryTasks launches the registry's background maintenance goroutines.
+// They stop when ctx is done.
+func startRegistryTasks(ctx context.Context, cfg *config.Config, registry *hierarchy.ServerRegistry) {
+	var healthInterval time.Duration
+	if cfg.McpProxy.Options != nil {
+		healthInterval = cfg.McpProxy.Options.HealthCheckInterval.OrElse(0).Std()
+	}
+
+	go registry.StartIdleReaper(ctx)
+	go registry.StartHealthChecker(ctx, healthInterval)
+	go registry.Prewarm(ctx)
+}
+
+// usageSaveInterval is how often changed tool usage stats are saved
+const usageSaveInterval = time.Minute
+
+// startUsageTracking loads the tool usage stats saved by earlier runs, and
+// saves them as they change. The returned func saves them a last time.
+func startUsageTracking(ctx context.Context, cfg *config.Config, h *hierarchy.Hierarchy) func() {
+	if !cfg.McpProxy.Usage.Enabled() {
+		return func() {}
+	}
+	file, err := cfg.McpProxy.Usage.StatsFile()
+	if err == nil {
+		err = h.LoadUsage(file)
+	}
+	if err != nil {
+		slog.Warn("Tool usage stats unavailable", "error", err)
+		return func() {}
+	}
+	go h.StartSavingUsage(ctx, usageSaveInterval)
+	return func() {
+		if err := h.SaveUsage(); err != nil {
+			slog.Warn("Failed to save usage stats", "error", err)
+		}
+	}
+}
+
+// startSearchIndex embeds the hierarchy's tools in the background when
+// search_tools is configured to rank semantically. Until it is done, or if it
+// fails, search_tools matches fuzzily.
+func startSearchIndex(ctx context.Context, cfg *config.Config, h *hierarchy.Hierarchy) {
+	if !cfg.McpProxy.Search.Semantic() {
+		return
+	}
+	h.SetEmbeddings(embedding.New(cfg.McpProxy.Search.Embeddings))
+	go func() {
+		start := time.Now()
+		if err := h.IndexEmbeddings(ctx); err != nil {
+			slog.Warn("Semantic search unavailable", "error", err)
+			return
+		}
+		slog.Info("Indexed tools for semantic search", "duration", time.Since(start))
+	}()
+}
+
+// startSchemaPrefetch fetches the tool schemas the hierarchy lacks in the
+// background when options.prefetchSchemas is set, once listed is closed, so
+// that it never delays the first tools/list
+func startSchemaPrefetch(ctx context.Context, cfg *config.Config, h *hierarchy.Hierarchy, registry *hierarchy.ServerRegistry, listed <-chan struct{}) {
+	if cfg.McpProxy.Options == nil || !cfg.McpProxy.Options.PrefetchSchemas.OrElse(false) {
+		return
+	}
+	concurrency := cfg.McpProxy.Options.PrefetchConcurrency.OrElse(config.DefaultPrefetchConcurrency)
+	go func() {
+		select {
+		case <-listed:
+		case <-ctx.Done():
+			return
+		}
+		start := time.Now()
+		filled := h.PrefetchSchemas(ctx, registry, concurrency)
+		slog.Info("Prefetched tool schemas", "tools", filled, "duration", time.Since(start).Round(time.Millisecond))
+	}()
+}
+
+// watchConfig applies edits to the local config file and its includes while
+// running: only servers whose entry changed are restarted, and connected
+// clients are told to refresh their tool lists. Changes to mcpProxy still
+// need a restart.
+func watchConfig(ctx context.Context, cfg *config.Config, h *hierarchy.Hierarchy, registry *hierarchy.ServerRegistry) {
+	sources := cfg.Sources()
+	if len(sources) == 0 || (cfg.McpProxy.Options != nil && !cfg.McpProxy.Options.WatchConfig.OrElse(true)) {
+		return
+	}
+
+	err := config.Watch(ctx, sources, func() {
+		if _, err := reloadConfig(cfg, h, registry); err != nil {
+			slog.Warn("Ignoring config change", "error", err)
 		}
+	})
+	if err != nil {
+		slog.Warn("Not watching config file for changes", "error", err)
+	}
+}
 
-		return h.HandleExecuteTool(ctx, registry, toolPath, arguments)
+// reloadConfig loads the config again and applies its mcpServers and log
+// levels, rebuilding the hierarchy of the servers that changed and telling
+// connected clients to refresh their tool lists. It returns the names of the
+// servers that changed. With
+// duplicateTools: "error", a config listing tools of different servers under
+// the same name is rejected, as is one with invalid resultTransforms.
+func reloadConfig(cfg *config.Config, h *hierarchy.Hierarchy, registry *hierarchy.ServerRegistry) ([]string, error) {
+	newCfg, err := cfg.Reload()
+	if err == nil {
+		err = checkDuplicateTools(cfg, h, newCfg.McpServers)
+	}
+	if err == nil {
+		err = hierarchy.CheckResultTransforms(newCfg.McpServers)
+	}
+	if err == nil {
+		err = logging.SetLevels(newCfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	changed := registry.Reconfigure(newCfg.McpServers)
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	h.RebuildServers(context.Background(), registry, changed, cfg.McpProxy.VirtualServers)
+	slog.Info("Reloaded config", "changed", strings.Join(changed, ", "))
+	return changed, nil
+}
+
+// checkDuplicateTools fails with duplicateTools: "error" if the hierarchy
+// would list tools of different servers under the same name with the given
+// server configs. The other strategies tell them apart instead.
+func checkDuplicateTools(cfg *config.Config, h *hierarchy.Hierarchy, servers map[string]*config.MCPClientConfigV2) error {
+	if cfg.McpProxy.Options == nil || cfg.McpProxy.Options.DuplicateTools.OrElse(config.DuplicateToolsPrefix) != config.DuplicateToolsError {
+		return nil
+	}
+	duplicates := h.DuplicateTools(servers)
+	if len(duplicates) == 0 {
+		return nil
+	}
+	names := make([]string, len(duplicates))
+	for i, duplicate := range duplicates {
+		names[i] = duplicate.String()
+	}
+	return fmt.Errorf("tools of different servers share a name, see duplicateTools: %s", strings.Join(names, "; "))
+}
+
+// newHealthHandler serves the registry's server statuses as JSON. It responds
+// 503 when any running server failed its latest health check. Requests that
+// detailed, if not nil, rejects get only whether the proxy is healthy, as the
+// statuses tell what servers run and how they fail.
+func newHealthHandler(registry *hierarchy.ServerRegistry, detailed func(*http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		statuses := registry.ServerStatuses()
+		draining := registry.Draining()
+		healthy := !draining
+		for _, status := range statuses {
+			if !status.Healthy {
+				healthy = false
+				break
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		body := map[string]interface{}{
+			"healthy": healthy,
+		}
+		if detailed == nil || detailed(r) {
+			body["servers"] = statuses
+		}
+		if draining {
+			body["draining"] = true
+		}
+		_ = json.NewEncoder(w).Encode(body)
 	})
+}
+
+// newHTTPHandler serves mcpServer over both HTTP transports so that any mix of
+// clients can share one instance: Streamable HTTP at /mcp and SSE at /sse and
+// /message. Other paths go to the transport selected by mcpProxy.type. admin,
+// if not nil, serves /admin/ behind the same auth. /healthz serves the
+// registry's statuses without auth, for probes, but only in detail to
+// requests that carry a key.
+func newHTTPHandler(ctx context.Context, cfg *config.Config, mcpServer *server.MCPServer, registry *hierarchy.ServerRegistry, intercept *interceptor, admin http.Handler) (http.Handler, error) {
+	sseHandler := server.NewSSEServer(
+		mcpServer,
+		server.WithStaticBasePath(""),
+		server.WithBaseURL(cfg.McpProxy.BaseURL),
+		server.WithSSEContextFunc(httpContext),
+	)
+	// Streamable HTTP clients keep a session ID so their lazy-loading state
+	// stays their own across requests
+	streamableHandler := intercept.middleware(server.NewStreamableHTTPServer(mcpServer, server.WithHTTPContextFunc(httpContext)))
 
-	// Set up HTTP handler (SSE or Streamable)
-	var handler http.Handler
+	mux := http.NewServeMux()
+	mux.Handle("/sse", sseHandler)
+	mux.Handle("/message", sseHandler)
+	mux.Handle("/mcp", streamableHandler)
+	if admin != nil {
+		mux.Handle("/admin/", admin)
+	}
 	switch cfg.McpProxy.Type {
 	case config.MCPServerTypeSSE:
-		handler = server.NewSSEServer(
-			mcpServer,
-			server.WithStaticBasePath(""),
-			server.WithBaseURL(cfg.McpProxy.BaseURL),
-		)
+		mux.Handle("/", sseHandler)
 	case config.MCPServerTypeStreamable:
-		handler = server.NewStreamableHTTPServer(
-			mcpServer,
-			server.WithStateLess(true),
-		)
+		mux.Handle("/", streamableHandler)
 	default:
-		return fmt.Errorf("unknown server type: %s", cfg.McpProxy.Type)
+		return nil, fmt.Errorf("unknown server type: %s", cfg.McpProxy.Type)
 	}
 
 	// Apply middleware
@@ -353,41 +940,143 @@ func StartHTTPServer(cfg *config.Config) error {
 	if cfg.McpProxy.Options != nil && cfg.McpProxy.Options.LogEnabled.OrElse(false) {
 		middlewares = append(middlewares, loggerMiddleware("mcp-proxy"))
 	}
-	if cfg.McpProxy.Options != nil && len(cfg.McpProxy.Options.AuthTokens) > 0 {
-		middlewares = append(middlewares, newAuthMiddleware(cfg.McpProxy.Options.AuthTokens))
+	keys, err := loadAuthKeys(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	var detailed func(*http.Request) bool
+	if keys != nil {
+		header := cfg.McpProxy.Auth.APIKeyHeader()
+		middlewares = append(middlewares, newAuthMiddleware(keys, header))
+		detailed = func(r *http.Request) bool {
+			return keys.authorized(r, header)
+		}
 	}
-	handler = chainMiddleware(handler, middlewares...)
 
-	// Start HTTP server
-	httpMux := http.NewServeMux()
-	httpMux.Handle("/", handler)
+	outer := http.NewServeMux()
+	outer.Handle("/healthz", newHealthHandler(registry, detailed))
+	outer.Handle("/", chainMiddleware(mux, middlewares...))
+	return outer, nil
+}
+
+// StartStdioServer starts the stdio server with the given configuration
+func StartStdioServer(cfg *config.Config) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shutdown, err := setupObservability(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+
+	proxy, err := NewProxy(ctx, cfg, ProxyOptions{})
+	if err != nil {
+		return err
+	}
+	defer proxy.Close()
+
+	// On a signal, keep serving until the calls in flight have answered
+	listenCtx, stopListening := context.WithCancel(ctx)
+	defer stopListening()
+	sigChan := make(chan os.Signal, 2)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		select {
+		case <-sigChan:
+		case <-listenCtx.Done():
+			return
+		}
+		slog.Info("Shutdown signal received")
+		proxy.drainOnSignal(sigChan)
+		stopListening()
+	}()
+
+	// Serve via stdio
+	slog.Info("Starting hierarchical MCP proxy", "type", config.MCPServerTypeStdio)
+	stdin, stdout := proxy.intercept.stdio(listenCtx, os.Stdin, os.Stdout)
+	err = server.NewStdioServer(proxy.MCPServer).Listen(listenCtx, stdin, stdout)
+	// As it stops once drained after a signal
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// StartHTTPServer starts the HTTP server with the given configuration
+func StartHTTPServer(cfg *config.Config) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
+	shutdown, err := setupObservability(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+
+	proxy, err := NewProxy(ctx, cfg, ProxyOptions{})
+	if err != nil {
+		return err
+	}
+	defer proxy.Close()
+
+	handler, err := proxy.HTTPHandler(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Start HTTP server
 	httpServer := &http.Server{
 		Addr:    cfg.McpProxy.Addr,
-		Handler: httpMux,
+		Handler: handler,
 	}
 
 	go func() {
-		log.Printf("Starting hierarchical MCP proxy (%s server)", cfg.McpProxy.Type)
-		log.Printf("%s server listening on %s", cfg.McpProxy.Type, cfg.McpProxy.Addr)
+		slog.Info("Starting hierarchical MCP proxy", "type", cfg.McpProxy.Type, "addr", cfg.McpProxy.Addr)
 		hErr := httpServer.ListenAndServe()
 		if hErr != nil && !errors.Is(hErr, http.ErrServerClosed) {
-			log.Fatalf("Failed to start server: %v", hErr)
+			slog.Error("Failed to start server", "error", hErr)
+			os.Exit(1)
 		}
 	}()
 
-	sigChan := make(chan os.Signal, 1)
+	sigChan := make(chan os.Signal, 2)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
 
 	<-sigChan
-	log.Println("Shutdown signal received")
+	slog.Info("Shutdown signal received")
+	// New tool calls fail and /healthz reports draining meanwhile, while the
+	// calls in flight can still answer
+	proxy.drainOnSignal(sigChan)
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(ctx, 5*time.Second)
 	defer shutdownCancel()
 
 	err = httpServer.Shutdown(shutdownCtx)
+	// SSE streams never go idle, so they are cut once the timeout is up
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = httpServer.Close()
+	}
 	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 	return nil
 }
+
+// setupObservability sets up logging to stderr and tracing as cfg configures
+// them. The returned func flushes pending spans.
+func setupObservability(ctx context.Context, cfg *config.Config) (func(), error) {
+	if err := logging.Setup(os.Stderr, cfg); err != nil {
+		return nil, err
+	}
+	if profile := cfg.Profile(); profile != "" {
+		slog.Info("Applied config profile", "profile", profile, "servers", len(cfg.McpServers))
+	}
+	shutdownTracing, err := telemetry.Setup(ctx, cfg.McpProxy.Tracing, cfg.McpProxy.Version)
+	if err != nil {
+		return nil, err
+	}
+	return func() { _ = shutdownTracing(context.Background()) }, nil
+}
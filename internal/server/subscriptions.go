@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+)
+
+// Requests for resource subscriptions, which mcp-go's server does not handle
+const (
+	methodSubscribe   = "resources/subscribe"
+	methodUnsubscribe = "resources/unsubscribe"
+)
+
+// addSubscriptions answers resources/subscribe and resources/unsubscribe
+// through intercept, and relays resources/updated notifications to the
+// sessions that subscribed
+func addSubscriptions(intercept *interceptor, notify *notifier, registry *hierarchy.ServerRegistry) {
+	registry.OnResourceUpdated(func(sessionID, uri string) {
+		err := notify.sendToSession(sessionID, mcp.MethodNotificationResourceUpdated, map[string]any{"uri": uri})
+		if err != nil {
+			slog.Debug("Failed to relay resource update", "session", sessionID, "uri", uri, "error", err)
+		}
+	})
+	intercept.handle(methodSubscribe, func(ctx context.Context, sessionID string, params json.RawMessage) (any, error) {
+		var subscribe mcp.SubscribeParams
+		if err := json.Unmarshal(params, &subscribe); err != nil {
+			return nil, err
+		}
+		return mcp.EmptyResult{}, registry.Subscribe(ctx, sessionID, subscribe.URI)
+	})
+	intercept.handle(methodUnsubscribe, func(ctx context.Context, sessionID string, params json.RawMessage) (any, error) {
+		var unsubscribe mcp.UnsubscribeParams
+		if err := json.Unmarshal(params, &unsubscribe); err != nil {
+			return nil, err
+		}
+		return mcp.EmptyResult{}, registry.Unsubscribe(ctx, sessionID, unsubscribe.URI)
+	})
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+)
+
+// toolList keeps the tools listed directly, by flat exposure or pinning, in
+// step with the hierarchy. As the config changes which tools are included,
+// and under which names, those no longer listed are removed and new ones
+// added, so that clients re-listing tools do not see stale names.
+type toolList struct {
+	mcpServer *server.MCPServer
+	h         *hierarchy.Hierarchy
+	registry  *hierarchy.ServerRegistry
+	naming    toolNaming
+	paths     func() []string // The paths of the tools to list
+
+	mu     sync.Mutex
+	listed map[string]mcp.Tool // The tools listed, by name
+}
+
+func newToolList(mcpServer *server.MCPServer, h *hierarchy.Hierarchy, registry *hierarchy.ServerRegistry, naming toolNaming, paths func() []string) *toolList {
+	l := &toolList{mcpServer: mcpServer, h: h, registry: registry, naming: naming, paths: paths}
+	l.sync()
+	return l
+}
+
+// sync lists the tools paths returns, in place of those listed before, and
+// reports whether that changed the list. Only tools that were added,
+// removed or changed are passed to mcp-go, which tells clients of each such
+// change itself.
+func (l *toolList) sync() bool {
+	tools := proxiedTools(l.h, l.registry, l.naming, l.paths())
+	listed := make(map[string]mcp.Tool, len(tools))
+	var changed []server.ServerTool
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, tool := range tools {
+		listed[tool.Tool.Name] = tool.Tool
+		if previous, ok := l.listed[tool.Tool.Name]; !ok || !reflect.DeepEqual(previous, tool.Tool) {
+			changed = append(changed, tool)
+		}
+	}
+	var stale []string
+	for name := range l.listed {
+		if _, ok := listed[name]; !ok {
+			stale = append(stale, name)
+		}
+	}
+	if len(stale) > 0 {
+		l.mcpServer.DeleteTools(stale...)
+	}
+	if len(changed) > 0 {
+		l.mcpServer.AddTools(changed...)
+	}
+	l.listed = listed
+	return len(stale) > 0 || len(changed) > 0
+}
+
+// count returns the number of tools listed
+func (l *toolList) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.listed)
+}
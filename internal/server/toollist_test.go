@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+)
+
+// loadTestHierarchy loads a hierarchy of the given servers' nodes, as
+// written to server/server.json
+func loadTestHierarchy(t *testing.T, nodes map[string]string) *hierarchy.Hierarchy {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "root.json"), []byte(`{"overview": "Tools"}`), 0o600))
+	for name, node := range nodes {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, name), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name, name+".json"), []byte(node), 0o600))
+	}
+	h, err := hierarchy.LoadHierarchy(dir)
+	require.NoError(t, err)
+	return h
+}
+
+// TestToolListSync verifies that syncing the tools listed directly adds and
+// deletes only those that changed, so that clients are told once per change
+// and not at all when nothing changed.
+func TestToolListSync(t *testing.T) {
+	h := loadTestHierarchy(t, map[string]string{"github": `{"tools": {
+		"get_issue": {"description": "Get an issue", "maps_to": "get_issue", "server": "github"},
+		"list_issues": {"description": "List issues", "maps_to": "list_issues", "server": "github"},
+		"get_pr": {"description": "Get a pull request", "maps_to": "get_pr", "server": "github"}
+	}}`})
+	registry := hierarchy.NewServerRegistry(map[string]*config.MCPClientConfigV2{"github": {Command: "github-mcp-server"}})
+	defer registry.Close()
+
+	mcpServer := server.NewMCPServer("test", "1.0.0", server.WithToolCapabilities(true))
+	session := &fakeSession{id: "a", ch: make(chan mcp.JSONRPCNotification, 10)}
+	require.NoError(t, mcpServer.RegisterSession(context.Background(), session))
+	notified := func() int {
+		n := len(session.ch)
+		for range n {
+			<-session.ch
+		}
+		return n
+	}
+
+	paths := []string{"github.get_issue", "github.list_issues"}
+	l := newToolList(mcpServer, h, registry, newToolNaming(&config.Config{McpProxy: &config.MCPProxyConfigV2{}}), func() []string {
+		return paths
+	})
+	assert.Equal(t, 2, l.count())
+	notified()
+
+	assert.False(t, l.sync(), "nothing changed")
+	assert.Zero(t, notified())
+
+	paths = []string{"github.get_issue", "github.get_pr"}
+	assert.True(t, l.sync())
+	assert.Equal(t, 2, notified(), "one for the deleted tool, one for the added one")
+	assert.Equal(t, []string{"github_get_issue", "github_get_pr"}, keys(mcpServer.ListTools()))
+
+	paths = nil
+	assert.True(t, l.sync())
+	assert.Equal(t, 1, notified())
+	assert.Empty(t, mcpServer.ListTools())
+	assert.Zero(t, l.count())
+}
+
+// keys returns the sorted keys of m
+func keys[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"log/slog"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	"github.com/voicetreelab/lazy-mcp/internal/toolname"
+)
+
+// toolNaming names the tools listed directly, by flat exposure, pinning or
+// exposeExpandedTools, after their paths per toolNameSeparator and
+// toolNameCase, and checks the names against the clientProfile
+type toolNaming struct {
+	scheme  toolname.Scheme
+	profile toolname.Profile
+}
+
+func newToolNaming(cfg *config.Config) toolNaming {
+	n := toolNaming{}
+	n.profile, _ = toolname.LookupProfile("")
+	if options := cfg.McpProxy.Options; options != nil {
+		n.scheme = toolname.Scheme{
+			Separator: options.ToolNameSeparator.OrElse(toolname.DefaultSeparator),
+			Case:      options.ToolNameCase.OrElse(toolname.CasePreserve),
+		}
+		if profile, ok := toolname.LookupProfile(options.ClientProfile.OrElse("")); ok {
+			n.profile = profile
+		}
+	}
+	return n
+}
+
+// name returns the name of the tool at toolPath, and whether clients of the
+// profile accept it
+func (n toolNaming) name(toolPath string) (string, bool) {
+	name := n.scheme.Name(toolPath)
+	return name, n.profile.Check(name) == nil
+}
+
+// check warns about the tools at toolPaths that cannot be listed directly,
+// as clients of the profile would reject their names or as they share one
+// with another tool
+func (n toolNaming) check(toolPaths []string) {
+	named := make(map[string]string, len(toolPaths))
+	for _, toolPath := range toolPaths {
+		name := n.scheme.Name(toolPath)
+		if err := n.profile.Check(name); err != nil {
+			slog.Warn("Not listing tool directly", "tool", toolPath, "error", err)
+			continue
+		}
+		if other, ok := named[name]; ok {
+			slog.Warn("Tools listed directly share a name, only one is listed", "name", name, "tools", []string{other, toolPath})
+			continue
+		}
+		named[name] = toolPath
+	}
+}
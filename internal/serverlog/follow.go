@@ -0,0 +1,112 @@
+package serverlog
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// followInterval is how often Follow checks the file for more output
+const followInterval = 250 * time.Millisecond
+
+// LastLines returns the last n lines of the log file at path, reaching into
+// the rotated file when the current one has fewer
+func LastLines(path string, n int) ([]byte, error) {
+	current, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data := current
+	if countLines(current) < n {
+		if rotated, err := os.ReadFile(path + ".1"); err == nil {
+			data = append(rotated, current...)
+		}
+	}
+	return lastLines(data, n), nil
+}
+
+// lastLines returns the last n lines of data, counting an unterminated final
+// line
+func lastLines(data []byte, n int) []byte {
+	if n <= 0 {
+		return nil
+	}
+	end := len(data)
+	if end > 0 && data[end-1] == '\n' {
+		end--
+	}
+	for i := end - 1; i >= 0; i-- {
+		if data[i] == '\n' {
+			n--
+			if n == 0 {
+				return data[i+1:]
+			}
+		}
+	}
+	return data
+}
+
+func countLines(data []byte) int {
+	lines := bytes.Count(data, []byte{'\n'})
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		lines++
+	}
+	return lines
+}
+
+// Follow copies what is appended to the log file at path to w, from its
+// current end, until ctx is done. It carries on in the new file when the
+// file is rotated.
+func Follow(ctx context.Context, path string, w io.Writer) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(followInterval)
+	defer ticker.Stop()
+	for {
+		n, err := io.Copy(w, file)
+		offset += n
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		// Rotated, or truncated: read the new file from the start. A file not
+		// there yet is waited for.
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		current, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		if os.SameFile(info, current) && info.Size() >= offset {
+			continue
+		}
+		// What was written to the old file before it was rotated
+		if _, err := io.Copy(w, file); err != nil {
+			return err
+		}
+		reopened, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		_ = file.Close()
+		file, offset = reopened, 0
+	}
+}
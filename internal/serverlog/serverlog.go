@@ -0,0 +1,193 @@
+// Package serverlog keeps what stdio and Docker servers write to stderr: the
+// end of it in memory per server, across restarts, and all of it in a rotated
+// file per server, so that a server which fails to start can be diagnosed
+// after the fact with `mcp-proxy logs`.
+package serverlog
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// tailSize is how much of each server's most recent stderr output is kept in
+// memory
+const tailSize = 64 << 10
+
+// Logs receives the stderr output of servers
+type Logs struct {
+	dir     string // Where the files go; empty when they are disabled
+	maxSize int64
+
+	mu      sync.Mutex
+	servers map[string]*serverLog
+}
+
+// Open returns the logs cfg configures, creating their directory
+func Open(cfg *config.ServerLogsConfig) (*Logs, error) {
+	l := &Logs{maxSize: cfg.MaxSize(), servers: make(map[string]*serverLog)}
+	if !cfg.Enabled() {
+		return l, nil
+	}
+	dir, err := cfg.LogDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create the server log directory: %w", err)
+	}
+	l.dir = dir
+	return l, nil
+}
+
+// File returns the path of the named server's log file in dir
+func File(dir, server string) string {
+	return filepath.Join(dir, fileName(server)+".log")
+}
+
+// fileName makes a server name safe to use as a file name
+func fileName(server string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, server)
+}
+
+// Writer returns where a start of the named server writes its stderr, and
+// marks the start in its log file
+func (l *Logs) Writer(server string) io.Writer {
+	log := l.server(server)
+	_, _ = log.writeFile(fmt.Appendf(nil, "--- lazy-mcp started %s at %s\n", server, time.Now().Format(time.RFC3339)))
+	return log
+}
+
+// Tail returns the last lines of what the named server wrote to stderr, across
+// its restarts, as far as they are kept in memory
+func (l *Logs) Tail(server string, lines int) string {
+	l.mu.Lock()
+	log, exists := l.servers[server]
+	l.mu.Unlock()
+	if !exists {
+		return ""
+	}
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	return string(lastLines(log.tail, lines))
+}
+
+// Close closes the log files
+func (l *Logs) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, log := range l.servers {
+		log.mu.Lock()
+		log.close()
+		log.mu.Unlock()
+	}
+}
+
+func (l *Logs) server(name string) *serverLog {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	log, exists := l.servers[name]
+	if !exists {
+		log = &serverLog{name: name, maxSize: l.maxSize}
+		if l.dir != "" {
+			log.path = File(l.dir, name)
+		}
+		l.servers[name] = log
+	}
+	return log
+}
+
+// serverLog is the stderr output of one server
+type serverLog struct {
+	name    string
+	path    string // Empty when files are disabled
+	maxSize int64
+
+	mu     sync.Mutex
+	tail   []byte
+	file   *os.File
+	size   int64
+	failed bool // Writing the file failed, which was logged
+}
+
+func (s *serverLog) Write(data []byte) (int, error) {
+	s.mu.Lock()
+	s.tail = append(s.tail, data...)
+	if excess := len(s.tail) - tailSize; excess > 0 {
+		s.tail = append(s.tail[:0], s.tail[excess:]...)
+	}
+	s.mu.Unlock()
+
+	// Never fail the copy from the pipe, or the server would block on it
+	_, _ = s.writeFile(data)
+	return len(data), nil
+}
+
+// writeFile appends data to the log file, rotating it first if it would grow
+// past maxSize
+func (s *serverLog) writeFile(data []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.path == "" || s.failed {
+		return 0, nil
+	}
+	err := s.open()
+	if err == nil && s.size > 0 && s.size+int64(len(data)) > s.maxSize {
+		err = s.rotate()
+	}
+	if err != nil {
+		s.failed = true
+		slog.Warn("Failed to write server log", "server", s.name, "file", s.path, "error", err)
+		return 0, err
+	}
+	n, err := s.file.Write(data)
+	s.size += int64(n)
+	return n, err
+}
+
+func (s *serverLog) open() error {
+	if s.file != nil {
+		return nil
+	}
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+// rotate moves the file to <file>.1, replacing the previous one, and starts a
+// new one
+func (s *serverLog) rotate() error {
+	s.close()
+	if err := os.Rename(s.path, s.path+".1"); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *serverLog) close() {
+	if s.file != nil {
+		_ = s.file.Close()
+		s.file = nil
+	}
+}
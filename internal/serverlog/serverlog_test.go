@@ -0,0 +1,136 @@
+package serverlog
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TBXark/optional-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+)
+
+// TestLogsKeepOutputAcrossStarts verifies that each start of a server is
+// marked in its file, and that its output is kept in memory and in the file.
+func TestLogsKeepOutputAcrossStarts(t *testing.T) {
+	dir := t.TempDir()
+	logs, err := Open(&config.ServerLogsConfig{Dir: dir})
+	require.NoError(t, err)
+	defer logs.Close()
+
+	_, _ = logs.Writer("fs").Write([]byte("starting\nfailed to bind\n"))
+	_, _ = logs.Writer("fs").Write([]byte("starting again\n"))
+
+	assert.Equal(t, "failed to bind\nstarting again\n", logs.Tail("fs", 2))
+	assert.Empty(t, logs.Tail("other", 2))
+
+	data, err := os.ReadFile(File(dir, "fs"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), "--- lazy-mcp started fs at "))
+	assert.Contains(t, string(data), "failed to bind\n")
+	assert.Contains(t, string(data), "starting again\n")
+}
+
+// TestLogsRotate verifies that a file about to grow past maxSizeMB is moved
+// aside, and that LastLines reads on into it.
+func TestLogsRotate(t *testing.T) {
+	dir := t.TempDir()
+	logs, err := Open(&config.ServerLogsConfig{Dir: dir, MaxSizeMB: optional.NewField(1)})
+	require.NoError(t, err)
+	defer logs.Close()
+
+	w := logs.Writer("fs")
+	line := strings.Repeat("x", 1023) + "\n"
+	for i := 0; i < 1024; i++ {
+		_, _ = w.Write([]byte(line))
+	}
+	_, _ = w.Write([]byte("last\n"))
+
+	rotated, err := os.Stat(File(dir, "fs") + ".1")
+	require.NoError(t, err)
+	assert.LessOrEqual(t, rotated.Size(), int64(1<<20))
+	current, err := os.ReadFile(File(dir, "fs"))
+	require.NoError(t, err)
+	assert.Equal(t, line+"last\n", string(current))
+
+	output, err := LastLines(File(dir, "fs"), 3)
+	require.NoError(t, err)
+	assert.Equal(t, line+line+"last\n", string(output))
+}
+
+// TestLogsDisabled verifies that with files disabled, output is still kept in
+// memory.
+func TestLogsDisabled(t *testing.T) {
+	logs, err := Open(&config.ServerLogsConfig{Disabled: true})
+	require.NoError(t, err)
+	defer logs.Close()
+
+	_, _ = logs.Writer("fs").Write([]byte("oops\n"))
+	assert.Equal(t, "oops\n", logs.Tail("fs", 10))
+}
+
+func TestFileSanitizesServerName(t *testing.T) {
+	assert.Equal(t, "/logs/my_server____x.log", File("/logs", "my server/../x"))
+}
+
+func TestLastLines(t *testing.T) {
+	assert.Equal(t, "b\nc\n", string(lastLines([]byte("a\nb\nc\n"), 2)))
+	assert.Equal(t, "b\nc", string(lastLines([]byte("a\nb\nc"), 2)))
+	assert.Equal(t, "a\nb\n", string(lastLines([]byte("a\nb\n"), 5)))
+	assert.Empty(t, lastLines([]byte("a\n"), 0))
+}
+
+// syncBuffer is a bytes.Buffer safe to read while Follow writes to it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(data)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestFollow verifies that Follow prints what is appended from the end of
+// the file on, including across a rotation.
+func TestFollow(t *testing.T) {
+	dir := t.TempDir()
+	path := File(dir, "fs")
+	require.NoError(t, os.WriteFile(path, []byte("old\n"), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncBuffer
+	done := make(chan error)
+	go func() { done <- Follow(ctx, path, &out) }()
+
+	appendTo := func(data string) {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		require.NoError(t, err)
+		_, err = file.WriteString(data)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+	}
+	// Let Follow open the file before writing to it
+	time.Sleep(2 * followInterval)
+	appendTo("one\n")
+	assert.Eventually(t, func() bool { return out.String() == "one\n" }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendTo("two\n")
+	assert.Eventually(t, func() bool { return out.String() == "one\ntwo\n" }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
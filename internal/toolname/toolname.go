@@ -0,0 +1,167 @@
+// Package toolname names the tools listed directly to clients after their
+// hierarchy paths, and checks the names against what clients accept.
+package toolname
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Casings of the words of each path segment
+const (
+	// CasePreserve keeps segments as they are
+	CasePreserve = "preserve"
+	// CaseSnake lowercases words and joins them with _, as in create_issue
+	CaseSnake = "snake"
+	// CaseKebab lowercases words and joins them with -, as in create-issue
+	CaseKebab = "kebab"
+	// CaseCamel joins words capitalized after the first, as in createIssue
+	CaseCamel = "camel"
+)
+
+// DefaultSeparator joins path segments unless configured otherwise, as in
+// github_create_issue
+const DefaultSeparator = "_"
+
+// ValidCase reports whether c is one of the casings
+func ValidCase(c string) bool {
+	switch c {
+	case CasePreserve, CaseSnake, CaseKebab, CaseCamel:
+		return true
+	}
+	return false
+}
+
+// Scheme turns hierarchy tool paths into tool names
+type Scheme struct {
+	Separator string // Joins path segments; DefaultSeparator if empty
+	Case      string // One of the casings; CasePreserve if empty
+}
+
+// Name returns the name of the tool at toolPath, its segments recased and
+// joined with the separator
+func (s Scheme) Name(toolPath string) string {
+	separator := s.Separator
+	if separator == "" {
+		separator = DefaultSeparator
+	}
+	segments := strings.Split(toolPath, ".")
+	for i, segment := range segments {
+		segments[i] = recase(segment, s.Case)
+	}
+	return strings.Join(segments, separator)
+}
+
+// recase rewrites a path segment in the given casing. Words are separated by
+// _, - or spaces, and start at each uppercase letter following a lowercase
+// one or a digit.
+func recase(segment, c string) string {
+	if c == "" || c == CasePreserve {
+		return segment
+	}
+	words := splitWords(segment)
+	switch c {
+	case CaseSnake:
+		return strings.ToLower(strings.Join(words, "_"))
+	case CaseKebab:
+		return strings.ToLower(strings.Join(words, "-"))
+	case CaseCamel:
+		for i, word := range words {
+			word = strings.ToLower(word)
+			if i > 0 {
+				word = strings.ToUpper(word[:1]) + word[1:]
+			}
+			words[i] = word
+		}
+		return strings.Join(words, "")
+	}
+	return segment
+}
+
+func splitWords(segment string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(segment)
+	for i, r := range runes {
+		if r == '_' || r == '-' || r == ' ' {
+			if len(word) > 0 {
+				words = append(words, string(word))
+			}
+			word = nil
+			continue
+		}
+		if unicode.IsUpper(r) && len(word) > 0 && i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+			words = append(words, string(word))
+			word = nil
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// Client profiles
+const (
+	// ProfileMCP allows the names the MCP specification recommends: up to
+	// 128 letters, digits, _, - and .
+	ProfileMCP = "mcp"
+	// ProfileOpenAI allows the function names of OpenAI's API: up to 64
+	// letters, digits, _ and -
+	ProfileOpenAI = "openai"
+	// ProfileAnthropic allows the tool names of Anthropic's API: up to 64
+	// letters, digits, _ and -
+	ProfileAnthropic = "anthropic"
+	// ProfileCursor allows the names Cursor accepts: up to 60 letters,
+	// digits, _ and -
+	ProfileCursor = "cursor"
+)
+
+// Profile is the set of tool names a kind of client accepts
+type Profile struct {
+	Name      string
+	MaxLength int
+	allowed   *regexp.Regexp // Matches a name of allowed characters
+	chars     string         // The allowed characters, for messages
+}
+
+var profiles = map[string]Profile{
+	ProfileMCP:       {Name: ProfileMCP, MaxLength: 128, allowed: regexp.MustCompile(`^[A-Za-z0-9_.-]+$`), chars: "letters, digits, _, - and ."},
+	ProfileOpenAI:    {Name: ProfileOpenAI, MaxLength: 64, allowed: regexp.MustCompile(`^[A-Za-z0-9_-]+$`), chars: "letters, digits, _ and -"},
+	ProfileAnthropic: {Name: ProfileAnthropic, MaxLength: 64, allowed: regexp.MustCompile(`^[A-Za-z0-9_-]+$`), chars: "letters, digits, _ and -"},
+	ProfileCursor:    {Name: ProfileCursor, MaxLength: 60, allowed: regexp.MustCompile(`^[A-Za-z0-9_-]+$`), chars: "letters, digits, _ and -"},
+}
+
+// LookupProfile returns the client profile of the given name, ProfileMCP if
+// it is empty
+func LookupProfile(name string) (Profile, bool) {
+	if name == "" {
+		name = ProfileMCP
+	}
+	profile, ok := profiles[name]
+	return profile, ok
+}
+
+// Check returns why the profile's clients would reject a tool named name, or
+// nil if they accept it
+func (p Profile) Check(name string) error {
+	if name == "" {
+		return fmt.Errorf("tool name is empty")
+	}
+	if !p.allowed.MatchString(name) {
+		return fmt.Errorf("tool name %q has characters other than %s, which %s clients reject", name, p.chars, p.Name)
+	}
+	if len(name) > p.MaxLength {
+		return fmt.Errorf("tool name %q is longer than the %d characters %s clients accept", name, p.MaxLength, p.Name)
+	}
+	return nil
+}
+
+// AllowsSeparator reports whether the profile allows the characters of
+// separator in tool names
+func (p Profile) AllowsSeparator(separator string) bool {
+	return p.allowed.MatchString(separator)
+}
@@ -0,0 +1,55 @@
+package toolname
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemeName(t *testing.T) {
+	tests := []struct {
+		scheme Scheme
+		path   string
+		want   string
+	}{
+		{Scheme{}, "github.create_issue", "github_create_issue"},
+		{Scheme{Separator: "__"}, "github.create_issue", "github__create_issue"},
+		{Scheme{Separator: "."}, "github.create_issue", "github.create_issue"},
+		{Scheme{Separator: "-", Case: CaseKebab}, "github.create_issue", "github-create-issue"},
+		{Scheme{Case: CaseSnake}, "myServer.createIssue", "my_server_create_issue"},
+		{Scheme{Separator: "-", Case: CaseCamel}, "coding_tools.search-symbol", "codingTools-searchSymbol"},
+		{Scheme{Case: CasePreserve}, "Slack.postMessage", "Slack_postMessage"},
+		{Scheme{Case: CaseSnake}, "s3.listV2Objects", "s3_list_v2_objects"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.scheme.Name(tt.path), "%+v %s", tt.scheme, tt.path)
+	}
+}
+
+func TestProfileCheck(t *testing.T) {
+	mcp, ok := LookupProfile("")
+	require.True(t, ok)
+	assert.Equal(t, ProfileMCP, mcp.Name)
+	openai, ok := LookupProfile(ProfileOpenAI)
+	require.True(t, ok)
+	_, ok = LookupProfile("gemini")
+	assert.False(t, ok)
+
+	assert.NoError(t, mcp.Check("github.create_issue"))
+	assert.Error(t, openai.Check("github.create_issue"))
+	assert.NoError(t, openai.Check("github__create_issue"))
+	assert.Error(t, mcp.Check("github create"))
+	assert.Error(t, mcp.Check(""))
+
+	long := strings.Repeat("a", 65)
+	assert.NoError(t, mcp.Check(long))
+	err := openai.Check(long)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "64")
+
+	assert.True(t, mcp.AllowsSeparator("."))
+	assert.False(t, openai.AllowsSeparator("."))
+	assert.True(t, openai.AllowsSeparator("__"))
+}
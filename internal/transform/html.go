@@ -0,0 +1,195 @@
+package transform
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLToMarkdown converts each of a result's texts that is HTML, starting
+// with a tag, to Markdown. Scripts, styles and other markup without text for
+// the model are dropped.
+func HTMLToMarkdown(result *mcp.CallToolResult) (*mcp.CallToolResult, error) {
+	return mapTexts(result, func(text string) string {
+		if !strings.HasPrefix(strings.TrimSpace(text), "<") {
+			return text
+		}
+		doc, err := html.Parse(strings.NewReader(text))
+		if err != nil {
+			return text
+		}
+		var w markdownWriter
+		w.node(doc)
+		return w.String()
+	}), nil
+}
+
+// blankLines matches the runs of blank lines converted HTML is left with
+var blankLines = regexp.MustCompile(`\n(?:[ \t]*\n)+`)
+
+// markdownWriter writes HTML nodes as Markdown
+type markdownWriter struct {
+	b strings.Builder
+	// pre is set within <pre>, whose whitespace is kept
+	pre bool
+	// lists holds, for each list the writer is within, the number of the
+	// next item of an ordered one and zero for an unordered one
+	lists []int
+}
+
+func (w *markdownWriter) String() string {
+	return strings.TrimSpace(blankLines.ReplaceAllString(w.b.String(), "\n\n"))
+}
+
+// block ends the current paragraph
+func (w *markdownWriter) block() {
+	w.b.WriteString("\n\n")
+}
+
+func (w *markdownWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.node(c)
+	}
+}
+
+// inline writes the children of n wrapped in mark, unless they are empty
+func (w *markdownWriter) inline(n *html.Node, mark string) {
+	var inner markdownWriter
+	inner.pre, inner.lists = w.pre, w.lists
+	inner.children(n)
+	text := strings.TrimSpace(inner.b.String())
+	if text == "" {
+		return
+	}
+	w.b.WriteString(mark + text + mark)
+}
+
+func (w *markdownWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if w.pre {
+			w.b.WriteString(n.Data)
+		} else if text := strings.Join(strings.Fields(n.Data), " "); text != "" {
+			if n.Data[0] == ' ' || n.Data[0] == '\n' || n.Data[0] == '\t' {
+				text = " " + text
+			}
+			if last := n.Data[len(n.Data)-1]; last == ' ' || last == '\n' || last == '\t' {
+				text += " "
+			}
+			w.b.WriteString(text)
+		}
+		return
+	case html.ElementNode:
+	default:
+		w.children(n)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Head, atom.Noscript, atom.Svg, atom.Template, atom.Iframe:
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		w.block()
+		w.b.WriteString(strings.Repeat("#", int(n.Data[1]-'0')) + " ")
+		w.children(n)
+		w.block()
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Header, atom.Footer, atom.Main, atom.Nav, atom.Table, atom.Form:
+		w.block()
+		w.children(n)
+		w.block()
+	case atom.Br:
+		w.b.WriteString("\n")
+	case atom.Hr:
+		w.block()
+		w.b.WriteString("---")
+		w.block()
+	case atom.Tr:
+		w.b.WriteString("\n|")
+		w.children(n)
+	case atom.Td, atom.Th:
+		w.b.WriteString(" ")
+		w.children(n)
+		w.b.WriteString(" |")
+	case atom.Strong, atom.B:
+		w.inline(n, "**")
+	case atom.Em, atom.I:
+		w.inline(n, "*")
+	case atom.Code:
+		if w.pre {
+			w.children(n)
+		} else {
+			w.inline(n, "`")
+		}
+	case atom.Pre:
+		w.block()
+		w.b.WriteString("```\n")
+		w.pre = true
+		w.children(n)
+		w.pre = false
+		if !strings.HasSuffix(w.b.String(), "\n") {
+			w.b.WriteString("\n")
+		}
+		w.b.WriteString("```")
+		w.block()
+	case atom.A:
+		href := attr(n, "href")
+		if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+			w.children(n)
+			return
+		}
+		var inner markdownWriter
+		inner.children(n)
+		w.b.WriteString(fmt.Sprintf("[%s](%s)", strings.TrimSpace(inner.b.String()), href))
+	case atom.Img:
+		if src := attr(n, "src"); src != "" && !strings.HasPrefix(src, "data:") {
+			w.b.WriteString(fmt.Sprintf("![%s](%s)", attr(n, "alt"), src))
+		}
+	case atom.Ul, atom.Ol:
+		next := 0
+		if n.DataAtom == atom.Ol {
+			next = 1
+		}
+		if len(w.lists) == 0 {
+			w.b.WriteString("\n")
+		}
+		w.lists = append(w.lists, next)
+		w.children(n)
+		w.lists = w.lists[:len(w.lists)-1]
+		if len(w.lists) == 0 {
+			w.block()
+		}
+	case atom.Li:
+		w.b.WriteString("\n")
+		marker := "- "
+		if depth := len(w.lists); depth > 0 {
+			w.b.WriteString(strings.Repeat("  ", depth-1))
+			if next := w.lists[depth-1]; next > 0 {
+				marker = fmt.Sprintf("%d. ", next)
+				w.lists[depth-1]++
+			}
+		}
+		w.b.WriteString(marker)
+		w.children(n)
+	case atom.Blockquote:
+		var inner markdownWriter
+		inner.children(n)
+		w.block()
+		w.b.WriteString("> " + strings.ReplaceAll(inner.String(), "\n", "\n> "))
+		w.block()
+	default:
+		w.children(n)
+	}
+}
+
+// attr returns the value of the named attribute of n
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,321 @@
+// Package transform slims tool results before they are passed on: it keeps
+// only part of their JSON, redacts text, cuts long texts down and converts
+// HTML to Markdown.
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Mask is what Redact replaces matches with by default
+const Mask = "[REDACTED]"
+
+// Transform returns a result changed from the given one, which it leaves as
+// it is. A transform that fails returns the result unchanged with the error.
+type Transform func(result *mcp.CallToolResult) (*mcp.CallToolResult, error)
+
+// Apply applies transforms to result in turn, going on past any that fail,
+// and returns the result with the errors of those that failed
+func Apply(result *mcp.CallToolResult, transforms []Transform) (*mcp.CallToolResult, []error) {
+	var errs []error
+	for _, transform := range transforms {
+		transformed, err := transform(result)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result = transformed
+	}
+	return result, errs
+}
+
+// mapTexts returns result with each of its texts replaced by what f returns
+// for it
+func mapTexts(result *mcp.CallToolResult, f func(string) string) *mcp.CallToolResult {
+	mapped := *result
+	mapped.Content = make([]mcp.Content, len(result.Content))
+	for i, content := range result.Content {
+		if text, ok := mcp.AsTextContent(content); ok {
+			changed := *text
+			changed.Text = f(text.Text)
+			content = changed
+		}
+		mapped.Content[i] = content
+	}
+	return &mapped
+}
+
+// Redact returns a transform replacing the matches of pattern in a result's
+// texts and structured content with replacement, or Mask if it is empty.
+// The replacement may refer to submatches, as in regexp.Regexp.Expand.
+func Redact(pattern, replacement string) (Transform, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+	}
+	if replacement == "" {
+		replacement = Mask
+	}
+	redact := func(s string) string {
+		return re.ReplaceAllString(s, replacement)
+	}
+	return func(result *mcp.CallToolResult) (*mcp.CallToolResult, error) {
+		redacted := mapTexts(result, redact)
+		if result.StructuredContent != nil {
+			if structured, err := decoded(result.StructuredContent); err == nil {
+				redacted.StructuredContent = mapStrings(structured, redact)
+			} else {
+				// Not to be passed on unredacted
+				redacted.StructuredContent = nil
+			}
+		}
+		return redacted, nil
+	}, nil
+}
+
+// mapStrings returns value, decoded from JSON, with each of its strings
+// replaced by what f returns for it
+func mapStrings(value any, f func(string) string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		mapped := make(map[string]any, len(v))
+		for key, item := range v {
+			mapped[key] = mapStrings(item, f)
+		}
+		return mapped
+	case []any:
+		mapped := make([]any, len(v))
+		for i, item := range v {
+			mapped[i] = mapStrings(item, f)
+		}
+		return mapped
+	case string:
+		return f(v)
+	default:
+		return value
+	}
+}
+
+// MaxLines returns a transform cutting each of a result's texts down to its
+// first n lines, saying how many were cut
+func MaxLines(n int) Transform {
+	return func(result *mcp.CallToolResult) (*mcp.CallToolResult, error) {
+		return mapTexts(result, func(text string) string {
+			lines := strings.SplitAfter(text, "\n")
+			if len(lines) <= n {
+				return text
+			}
+			kept := strings.Join(lines[:n], "")
+			if !strings.HasSuffix(kept, "\n") {
+				kept += "\n"
+			}
+			return kept + fmt.Sprintf("[lazy-mcp: %d more lines cut]", len(lines)-n)
+		}), nil
+	}
+}
+
+// Extract returns a transform keeping only what path selects from a result's
+// structured content, or else from its text parsed as JSON. Paths are as in
+// jq or JSONPath, such as .items[0].title or $.items[*].title; [*] selects
+// every item of an array or value of an object, and makes the selection a
+// list. The selection becomes the result's text: a string as it is, anything
+// else as JSON. Content other than text is kept, and errors are passed on
+// whole.
+func Extract(path string) (Transform, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return func(result *mcp.CallToolResult) (*mcp.CallToolResult, error) {
+		if result.IsError {
+			return result, nil
+		}
+		source, err := jsonOf(result)
+		if err != nil {
+			return result, fmt.Errorf("extract %s: %w", path, err)
+		}
+		selected, err := selectPath(source, segments)
+		if err != nil {
+			return result, fmt.Errorf("extract %s: %w", path, err)
+		}
+		text, ok := selected.(string)
+		if !ok {
+			data, err := json.Marshal(selected)
+			if err != nil {
+				return result, fmt.Errorf("extract %s: %w", path, err)
+			}
+			text = string(data)
+		}
+
+		extracted := &mcp.CallToolResult{Result: result.Result}
+		extracted.Content = []mcp.Content{mcp.NewTextContent(text)}
+		for _, content := range result.Content {
+			if _, ok := mcp.AsTextContent(content); !ok {
+				extracted.Content = append(extracted.Content, content)
+			}
+		}
+		return extracted, nil
+	}, nil
+}
+
+// jsonOf returns a result's structured content as decoded from JSON, or else
+// its text parsed as JSON
+func jsonOf(result *mcp.CallToolResult) (any, error) {
+	if result.StructuredContent != nil {
+		return decoded(result.StructuredContent)
+	}
+	var texts []string
+	for _, content := range result.Content {
+		if text, ok := mcp.AsTextContent(content); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	var value any
+	if err := json.Unmarshal([]byte(strings.Join(texts, "\n")), &value); err != nil {
+		return nil, fmt.Errorf("the result is not JSON")
+	}
+	return value, nil
+}
+
+// decoded returns value as decoded from JSON, whatever type it has
+func decoded(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded any
+	err = json.Unmarshal(data, &decoded)
+	return decoded, err
+}
+
+// wildcard is the segment of a path written [*]
+type wildcard struct{}
+
+// segmentPattern matches one field of a path with its indexes, as in
+// items[0] or items[*]; the field may be left out, as in [0]
+var segmentPattern = regexp.MustCompile(`^([^.\[\]]*)((?:\[(?:\d+|\*)\])*)$`)
+
+// parsePath parses an Extract path into its segments: field names, indexes
+// and wildcards
+func parsePath(path string) ([]any, error) {
+	trimmed := strings.TrimSpace(path)
+	trimmed = strings.TrimPrefix(trimmed, "$")
+	trimmed = strings.TrimPrefix(trimmed, ".")
+	if trimmed == "" {
+		return nil, nil // The whole value
+	}
+	var segments []any
+	for _, part := range strings.Split(trimmed, ".") {
+		match := segmentPattern.FindStringSubmatch(part)
+		if match == nil || part == "" {
+			return nil, fmt.Errorf("invalid path %q", path)
+		}
+		if match[1] != "" {
+			segments = append(segments, match[1])
+		}
+		for _, index := range strings.Split(strings.Trim(match[2], "[]"), "][") {
+			switch index {
+			case "":
+			case "*":
+				segments = append(segments, wildcard{})
+			default:
+				n, err := strconv.Atoi(index)
+				if err != nil {
+					return nil, fmt.Errorf("invalid path %q", path)
+				}
+				segments = append(segments, n)
+			}
+		}
+	}
+	return segments, nil
+}
+
+// selectPath returns what segments select from value: a single value, or a
+// list if they contain a wildcard. Items a wildcard selects that lack the
+// rest of the path are left out.
+func selectPath(value any, segments []any) (any, error) {
+	for i, segment := range segments {
+		switch key := segment.(type) {
+		case string:
+			object, ok := value.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("cannot look up %q in %s", key, kind(value))
+			}
+			if value, ok = object[key]; !ok {
+				return nil, fmt.Errorf("no field %q", key)
+			}
+		case int:
+			array, ok := value.([]any)
+			if !ok {
+				return nil, fmt.Errorf("cannot index %s", kind(value))
+			}
+			if key >= len(array) {
+				return nil, fmt.Errorf("index %d out of range of %d items", key, len(array))
+			}
+			value = array[key]
+		case wildcard:
+			var items []any
+			switch v := value.(type) {
+			case []any:
+				items = v
+			case map[string]any:
+				keys := make([]string, 0, len(v))
+				for k := range v {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					items = append(items, v[k])
+				}
+			default:
+				return nil, fmt.Errorf("cannot select every item of %s", kind(value))
+			}
+			selected := make([]any, 0, len(items))
+			for _, item := range items {
+				if item, err := selectPath(item, segments[i+1:]); err == nil {
+					if list, ok := item.([]any); ok && containsWildcard(segments[i+1:]) {
+						selected = append(selected, list...)
+					} else {
+						selected = append(selected, item)
+					}
+				}
+			}
+			return selected, nil
+		}
+	}
+	return value, nil
+}
+
+func containsWildcard(segments []any) bool {
+	for _, segment := range segments {
+		if _, ok := segment.(wildcard); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// kind names the JSON type of value for errors
+func kind(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	default:
+		return "a number"
+	}
+}
@@ -0,0 +1,104 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func text(result *mcp.CallToolResult) string {
+	return result.Content[0].(mcp.TextContent).Text
+}
+
+func TestExtract(t *testing.T) {
+	issues := mcp.NewToolResultText(`{"total": 2, "items": [
+		{"id": 1, "title": "Crash", "labels": [{"name": "bug"}]},
+		{"id": 2, "title": "Docs", "labels": [{"name": "docs"}, {"name": "good first issue"}]}
+	]}`)
+
+	for path, want := range map[string]string{
+		"$.items[*].title":        `["Crash","Docs"]`,
+		".items[1].id":            `2`,
+		"items[0].title":          `Crash`,
+		"items[*].labels[*].name": `["bug","docs","good first issue"]`,
+		"$":                       `{"items":[{"id":1,"labels":[{"name":"bug"}],"title":"Crash"},{"id":2,"labels":[{"name":"docs"},{"name":"good first issue"}],"title":"Docs"}],"total":2}`,
+	} {
+		extract, err := Extract(path)
+		require.NoError(t, err, path)
+		result, err := extract(issues)
+		require.NoError(t, err, path)
+		assert.Equal(t, want, text(result), path)
+	}
+
+	structured := mcp.NewToolResultStructured(map[string]any{"page": map[string]any{"title": "Home"}}, "a page")
+	extract, err := Extract("page.title")
+	require.NoError(t, err)
+	result, err := extract(structured)
+	require.NoError(t, err)
+	assert.Equal(t, "Home", text(result), "structured content is preferred to the text")
+	assert.Nil(t, result.StructuredContent)
+
+	extract, err = Extract("items[5]")
+	require.NoError(t, err)
+	result, err = extract(issues)
+	assert.ErrorContains(t, err, "index 5 out of range of 2 items")
+	assert.Same(t, issues, result, "a failed extraction leaves the result as it is")
+
+	_, err = extract(mcp.NewToolResultText("not json"))
+	assert.ErrorContains(t, err, "not JSON")
+
+	_, err = Extract("items[first]")
+	assert.ErrorContains(t, err, "invalid path")
+}
+
+func TestRedact(t *testing.T) {
+	redact, err := Redact(`sk-[A-Za-z0-9]+`, "")
+	require.NoError(t, err)
+	result, err := redact(mcp.NewToolResultStructured(map[string]any{"keys": []any{"sk-abc123"}}, "key sk-abc123 created"))
+	require.NoError(t, err)
+	assert.Equal(t, "key [REDACTED] created", text(result))
+	assert.Equal(t, map[string]any{"keys": []any{"[REDACTED]"}}, result.StructuredContent)
+
+	redact, err = Redact(`(\w+)@example\.com`, "$1@…")
+	require.NoError(t, err)
+	result, err = redact(mcp.NewToolResultText("mail ada@example.com"))
+	require.NoError(t, err)
+	assert.Equal(t, "mail ada@…", text(result))
+
+	_, err = Redact(`(`, "")
+	assert.Error(t, err)
+}
+
+func TestMaxLines(t *testing.T) {
+	result, err := MaxLines(2)(mcp.NewToolResultText("one\ntwo\nthree\nfour"))
+	require.NoError(t, err)
+	assert.Equal(t, "one\ntwo\n[lazy-mcp: 2 more lines cut]", text(result))
+
+	short := mcp.NewToolResultText("one\ntwo")
+	result, err = MaxLines(2)(short)
+	require.NoError(t, err)
+	assert.Equal(t, "one\ntwo", text(result))
+}
+
+func TestHTMLToMarkdown(t *testing.T) {
+	result, err := HTMLToMarkdown(mcp.NewToolResultText(`<!DOCTYPE html>
+<html><head><title>Docs</title><style>p { color: red }</style></head>
+<body>
+  <nav><a href="#main">Skip</a></nav>
+  <h1>Getting   started</h1>
+  <p>Run <code>lazy-mcp</code> with a <a href="/config">config</a>, <strong>not</strong> flags.</p>
+  <ol><li>Install</li><li>Configure<ul><li>servers</li></ul></li></ol>
+  <pre><code>lazy-mcp --config config.json
+</code></pre>
+  <script>track()</script>
+</body></html>`))
+	require.NoError(t, err)
+	assert.Equal(t, "Skip\n\n# Getting started\n\nRun `lazy-mcp` with a [config](/config), **not** flags.\n\n1. Install\n2. Configure\n  - servers\n\n```\nlazy-mcp --config config.json\n```", text(result))
+
+	plain := mcp.NewToolResultText("a < b")
+	result, err = HTMLToMarkdown(plain)
+	require.NoError(t, err)
+	assert.Equal(t, "a < b", text(result), "text that is not HTML is left as it is")
+}
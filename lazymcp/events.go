@@ -0,0 +1,19 @@
+package lazymcp
+
+import "github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+
+// Event is something that happened in the gateway: a server started, stopped
+// or became unhealthy, a tool was called, or the tools changed
+type Event = hierarchy.Event
+
+// EventType is the kind of an Event
+type EventType = hierarchy.EventType
+
+// The kinds of events, as documented in docs/USAGE.md
+const (
+	EventServerStarted    = hierarchy.EventServerStarted
+	EventServerStopped    = hierarchy.EventServerStopped
+	EventServerUnhealthy  = hierarchy.EventServerUnhealthy
+	EventToolCalled       = hierarchy.EventToolCalled
+	EventHierarchyChanged = hierarchy.EventHierarchyChanged
+)
@@ -0,0 +1,104 @@
+// Package lazymcp embeds the lazy-mcp gateway in other Go programs: it serves
+// the tools of many MCP servers behind a hierarchy of categories, starting
+// each server only when one of its tools is first called. Middleware see every
+// tool call and server start, so programs can add their own policy, logging or
+// transformation of results.
+package lazymcp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/voicetreelab/lazy-mcp/internal/config"
+	proxyserver "github.com/voicetreelab/lazy-mcp/internal/server"
+)
+
+// Config is a lazy-mcp config, as documented in docs/CONFIGURATION.md
+type Config = config.Config
+
+// LoadConfig loads the JSON or YAML config at path, or at a http(s) URL,
+// expanding environment variables
+func LoadConfig(path string) (*Config, error) {
+	return config.Load(path, false, true, "", 10, "")
+}
+
+// Option configures a Gateway
+type Option func(*options)
+
+type options struct {
+	middlewares []Middleware
+}
+
+// WithMiddleware adds middlewares to the gateway. They see each tool call in
+// the order given, and its result in the reverse order.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(o *options) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
+
+// Gateway is an embedded lazy-mcp: an MCP server exposing the tools of the
+// configured servers
+type Gateway struct {
+	proxy *proxyserver.Proxy
+}
+
+// New creates a gateway for cfg. Its background tasks, such as health checks
+// and watching the config file, run until ctx is done or it is closed.
+// Logging goes to the default slog logger.
+func New(ctx context.Context, cfg *Config, opts ...Option) (*Gateway, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	proxy, err := proxyserver.NewProxy(ctx, cfg, proxyserver.ProxyOptions{
+		Middlewares: callMiddlewares(o.middlewares),
+		OnEvent:     onServerStarted(o.middlewares),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Gateway{proxy: proxy}, nil
+}
+
+// MCPServer returns the gateway's MCP server, to serve over any transport
+func (g *Gateway) MCPServer() *server.MCPServer {
+	return g.proxy.MCPServer
+}
+
+// ServeStdio serves a single client over stdin and stdout until it
+// disconnects
+func (g *Gateway) ServeStdio() error {
+	return server.ServeStdio(g.proxy.MCPServer)
+}
+
+// Handler serves clients over HTTP as the mcp-proxy command does: Streamable
+// HTTP at /mcp, SSE at /sse, and the servers' statuses at /healthz
+func (g *Gateway) Handler(ctx context.Context) (http.Handler, error) {
+	return g.proxy.HTTPHandler(ctx)
+}
+
+// Subscribe calls handler with each of the gateway's events from now on, in
+// its own goroutine, until the returned func is called or the gateway is
+// closed
+func (g *Gateway) Subscribe(handler func(ctx context.Context, event Event)) (unsubscribe func()) {
+	return g.proxy.Registry.Events().Subscribe(handler)
+}
+
+// RecentEvents returns the gateway's latest events, oldest first: of the given
+// server, or all of them for ""
+func (g *Gateway) RecentEvents(server string) []Event {
+	return g.proxy.Registry.Events().Recent(server)
+}
+
+// Close stops the gateway's servers and background tasks
+func (g *Gateway) Close() {
+	g.proxy.Close()
+}
+
+// Shutdown stops the gateway gracefully: new tool calls fail while those in
+// flight are given until ctx is done to finish, then it closes as Close does
+func (g *Gateway) Shutdown(ctx context.Context) {
+	g.proxy.Shutdown(ctx)
+}
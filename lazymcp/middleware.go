@@ -0,0 +1,74 @@
+package lazymcp
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+)
+
+// ToolCall is a tool call on its way to a server: the tool's path in the
+// hierarchy, the server, the server's own name for the tool, and the
+// arguments. Middleware may change the arguments.
+type ToolCall = hierarchy.ToolCall
+
+// Middleware intercepts what the gateway does. Embed BaseMiddleware to
+// implement only some of the methods.
+type Middleware interface {
+	// OnToolCall is called before a call is forwarded, and before it is held
+	// back for approval. Returning an error refuses the call with it.
+	OnToolCall(ctx context.Context, call *ToolCall) error
+	// OnResult is called with the outcome of a call OnToolCall let through,
+	// and returns the outcome the client gets
+	OnResult(ctx context.Context, call *ToolCall, result *mcp.CallToolResult, err error) (*mcp.CallToolResult, error)
+	// OnServerStart is called after a server has started, including after a
+	// crash, with the version it reports. It runs in the background, so the
+	// server may already be in use.
+	OnServerStart(ctx context.Context, server, version string)
+}
+
+// BaseMiddleware implements Middleware by letting everything through
+type BaseMiddleware struct{}
+
+func (BaseMiddleware) OnToolCall(ctx context.Context, call *ToolCall) error {
+	return nil
+}
+
+func (BaseMiddleware) OnResult(ctx context.Context, call *ToolCall, result *mcp.CallToolResult, err error) (*mcp.CallToolResult, error) {
+	return result, err
+}
+
+func (BaseMiddleware) OnServerStart(ctx context.Context, server, version string) {}
+
+// callMiddlewares adapts middlewares to the hierarchy's call middleware
+func callMiddlewares(middlewares []Middleware) []hierarchy.CallMiddleware {
+	adapted := make([]hierarchy.CallMiddleware, 0, len(middlewares))
+	for _, m := range middlewares {
+		adapted = append(adapted, func(next hierarchy.CallHandler) hierarchy.CallHandler {
+			return func(ctx context.Context, call *ToolCall) (*mcp.CallToolResult, error) {
+				if err := m.OnToolCall(ctx, call); err != nil {
+					return nil, err
+				}
+				result, err := next(ctx, call)
+				return m.OnResult(ctx, call, result, err)
+			}
+		})
+	}
+	return adapted
+}
+
+// onServerStarted tells every middleware of the server starts among the
+// gateway's events
+func onServerStarted(middlewares []Middleware) hierarchy.EventHandler {
+	if len(middlewares) == 0 {
+		return nil
+	}
+	return func(ctx context.Context, event Event) {
+		if event.Type != EventServerStarted {
+			return
+		}
+		for _, m := range middlewares {
+			m.OnServerStart(ctx, event.Server, event.Version)
+		}
+	}
+}
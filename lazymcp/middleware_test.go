@@ -0,0 +1,57 @@
+package lazymcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/voicetreelab/lazy-mcp/internal/hierarchy"
+)
+
+// recorder is a middleware that records what it sees and refuses calls of
+// the "delete" tool
+type recorder struct {
+	BaseMiddleware
+	name string
+	seen *[]string
+}
+
+func (r recorder) OnToolCall(ctx context.Context, call *ToolCall) error {
+	*r.seen = append(*r.seen, r.name+" call "+call.Tool)
+	if call.Tool == "delete" {
+		return errors.New("delete is not allowed")
+	}
+	return nil
+}
+
+func (r recorder) OnResult(ctx context.Context, call *ToolCall, result *mcp.CallToolResult, err error) (*mcp.CallToolResult, error) {
+	*r.seen = append(*r.seen, r.name+" result "+call.Tool)
+	return result, err
+}
+
+// TestMiddlewareOrder verifies that middlewares see calls in order and
+// results in reverse, and that a refused call goes no further.
+func TestMiddlewareOrder(t *testing.T) {
+	var seen []string
+	chain := callMiddlewares([]Middleware{recorder{name: "a", seen: &seen}, recorder{name: "b", seen: &seen}})
+	var handler hierarchy.CallHandler = func(ctx context.Context, call *ToolCall) (*mcp.CallToolResult, error) {
+		seen = append(seen, "server "+call.Tool)
+		return mcp.NewToolResultText("ok"), nil
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		handler = chain[i](handler)
+	}
+
+	result, err := handler(context.Background(), &ToolCall{Tool: "read"})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", result.Content[0].(mcp.TextContent).Text)
+	assert.Equal(t, []string{"a call read", "b call read", "server read", "b result read", "a result read"}, seen)
+
+	seen = nil
+	_, err = handler(context.Background(), &ToolCall{Tool: "delete"})
+	assert.EqualError(t, err, "delete is not allowed")
+	assert.Equal(t, []string{"a call delete"}, seen)
+}
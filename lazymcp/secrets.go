@@ -0,0 +1,19 @@
+package lazymcp
+
+import (
+	"context"
+
+	"github.com/voicetreelab/lazy-mcp/internal/secrets"
+)
+
+// SecretResolver fetches the secret a reference of its scheme points to: for
+// "!secret op:vault/item", the resolver of scheme op is passed "vault/item"
+type SecretResolver func(ctx context.Context, ref string) (string, error)
+
+// RegisterSecretResolver makes resolver resolve the secret references of the
+// given scheme in the env, headers and args of servers, alongside or in
+// place of the built-in keychain, vault and aws-sm. Register resolvers
+// before creating a gateway.
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	secrets.Register(scheme, secrets.ResolverFunc(resolver))
+}
@@ -0,0 +1,207 @@
+// Package mockserver is a scriptable MCP server for testing setups that use
+// MCP servers, such as lazy-mcp itself. A Script names the tools the server
+// offers, how long each takes to answer and how it fails, and the server
+// speaks MCP over stdio like any other.
+//
+// `mcp-proxy mock-server -script script.json` serves a script from the
+// command line, so a mock can stand in for a real server in a config.
+package mockserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Failure is how the calls of a tool fail
+type Failure string
+
+const (
+	// FailNone calls succeed
+	FailNone Failure = ""
+	// FailError calls are answered with a JSON-RPC error
+	FailError Failure = "error"
+	// FailToolError calls return a result marked as an error
+	FailToolError Failure = "tool_error"
+	// FailCrash calls exit the process, as a crashing server would
+	FailCrash Failure = "crash"
+	// FailHang calls are never answered, until the client cancels them
+	FailHang Failure = "hang"
+)
+
+// CrashExitCode is the exit code of a server whose tool crashed it
+const CrashExitCode = 3
+
+// Script describes a mock server and its tools
+type Script struct {
+	// Name and Version are what the server reports when initialized
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Tools   []Tool `json:"tools"`
+}
+
+// Tool is a tool of a mock server
+type Tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// InputSchema is the tool's JSON schema; without one it takes any object
+	InputSchema map[string]interface{} `json:"inputSchema,omitempty"`
+	// Result is the text the tool returns; without one it returns its
+	// arguments as JSON
+	Result string `json:"result,omitempty"`
+	// Latency is how long each call takes
+	Latency Duration `json:"latency,omitempty"`
+	// Failure is how calls fail once FailAfter calls have succeeded
+	Failure   Failure `json:"failure,omitempty"`
+	FailAfter int     `json:"failAfter,omitempty"`
+	// Message is the error of a failing call
+	Message string `json:"message,omitempty"`
+}
+
+// Duration is a time.Duration written as a string like "250ms", or as
+// nanoseconds
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	switch v := raw.(type) {
+	case float64:
+		*d = Duration(v)
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", v, err)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return nil
+}
+
+// Load reads the JSON script at path
+func Load(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var script Script
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := script.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid script %s: %w", path, err)
+	}
+	return &script, nil
+}
+
+// Validate checks that every tool has a unique name and a known failure
+func (s *Script) Validate() error {
+	seen := make(map[string]bool)
+	for i, tool := range s.Tools {
+		if tool.Name == "" {
+			return fmt.Errorf("tools[%d] has no name", i)
+		}
+		if seen[tool.Name] {
+			return fmt.Errorf("tool %q is defined twice", tool.Name)
+		}
+		seen[tool.Name] = true
+		switch tool.Failure {
+		case FailNone, FailError, FailToolError, FailCrash, FailHang:
+		default:
+			return fmt.Errorf("tool %q has unknown failure %q", tool.Name, tool.Failure)
+		}
+		if tool.FailAfter < 0 {
+			return fmt.Errorf("tool %q has negative failAfter", tool.Name)
+		}
+	}
+	return nil
+}
+
+// New creates the MCP server of script, to serve over any transport. Tools
+// that crash exit the current process, so serve those with Serve in a process
+// of their own.
+func New(script *Script) *server.MCPServer {
+	name, version := script.Name, script.Version
+	if name == "" {
+		name = "mock"
+	}
+	if version == "" {
+		version = "1.0.0"
+	}
+	mcpServer := server.NewMCPServer(name, version, server.WithToolCapabilities(false))
+	for _, tool := range script.Tools {
+		mcpServer.AddTool(newTool(tool), newHandler(tool))
+	}
+	return mcpServer
+}
+
+// Serve serves script over stdin and stdout until the client disconnects
+func Serve(script *Script) error {
+	return server.ServeStdio(New(script))
+}
+
+func newTool(tool Tool) mcp.Tool {
+	if tool.InputSchema != nil {
+		if raw, err := json.Marshal(tool.InputSchema); err == nil {
+			return mcp.NewToolWithRawSchema(tool.Name, tool.Description, raw)
+		}
+	}
+	return mcp.NewTool(tool.Name, mcp.WithDescription(tool.Description))
+}
+
+// newHandler answers the calls of tool as its script says, counting them to
+// know when to start failing
+func newHandler(tool Tool) server.ToolHandlerFunc {
+	var calls atomic.Int64
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if tool.Latency > 0 {
+			select {
+			case <-time.After(time.Duration(tool.Latency)):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		if calls.Add(1) > int64(tool.FailAfter) {
+			message := tool.Message
+			if message == "" {
+				message = fmt.Sprintf("%s failed", tool.Name)
+			}
+			switch tool.Failure {
+			case FailError:
+				return nil, errors.New(message)
+			case FailToolError:
+				return mcp.NewToolResultError(message), nil
+			case FailCrash:
+				os.Exit(CrashExitCode)
+			case FailHang:
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+		}
+
+		if tool.Result != "" {
+			return mcp.NewToolResultText(tool.Result), nil
+		}
+		arguments, err := json.Marshal(request.GetArguments())
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(arguments)), nil
+	}
+}
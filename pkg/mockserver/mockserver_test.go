@@ -0,0 +1,102 @@
+package mockserver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, script *Script) *client.Client {
+	require.NoError(t, script.Validate())
+	mcpClient, err := client.NewInProcessClient(New(script))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = mcpClient.Close() })
+
+	ctx := context.Background()
+	require.NoError(t, mcpClient.Start(ctx))
+	_, err = mcpClient.Initialize(ctx, mcp.InitializeRequest{})
+	require.NoError(t, err)
+	return mcpClient
+}
+
+func call(ctx context.Context, mcpClient *client.Client, tool string, arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	request := mcp.CallToolRequest{}
+	request.Params.Name = tool
+	request.Params.Arguments = arguments
+	return mcpClient.CallTool(ctx, request)
+}
+
+// TestScriptedTools verifies that tools echo their arguments or return their
+// result, and fail as scripted once failAfter calls have succeeded.
+func TestScriptedTools(t *testing.T) {
+	mcpClient := newTestClient(t, &Script{Tools: []Tool{
+		{Name: "echo"},
+		{Name: "fixed", Result: "done", Latency: Duration(10 * time.Millisecond)},
+		{Name: "flaky", Failure: FailToolError, FailAfter: 1, Message: "out of quota"},
+		{Name: "broken", Failure: FailError},
+	}})
+	ctx := context.Background()
+
+	tools, err := mcpClient.ListTools(ctx, mcp.ListToolsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, tools.Tools, 4)
+
+	result, err := call(ctx, mcpClient, "echo", map[string]interface{}{"message": "hi"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"message":"hi"}`, result.Content[0].(mcp.TextContent).Text)
+
+	start := time.Now()
+	result, err = call(ctx, mcpClient, "fixed", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "done", result.Content[0].(mcp.TextContent).Text)
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+
+	result, err = call(ctx, mcpClient, "flaky", nil)
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+	result, err = call(ctx, mcpClient, "flaky", nil)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	assert.Equal(t, "out of quota", result.Content[0].(mcp.TextContent).Text)
+
+	_, err = call(ctx, mcpClient, "broken", nil)
+	assert.ErrorContains(t, err, "broken failed")
+}
+
+// TestHangingToolWaitsForCancellation verifies that a hanging tool answers
+// only once its call is cancelled.
+func TestHangingToolWaitsForCancellation(t *testing.T) {
+	mcpClient := newTestClient(t, &Script{Tools: []Tool{{Name: "hang", Failure: FailHang}}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := call(ctx, mcpClient, "hang", nil)
+	assert.ErrorContains(t, err, context.DeadlineExceeded.Error())
+}
+
+// TestLoadValidatesScript verifies that scripts are read with their durations
+// and rejected with unknown failures.
+func TestLoadValidatesScript(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "script.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"name": "mock", "tools": [{"name": "slow", "latency": "2s"}]}`), 0o600))
+	script, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, Duration(2*time.Second), script.Tools[0].Latency)
+
+	data, err := json.Marshal(script)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"latency":"2s"`)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"tools": [{"name": "bad", "failure": "explode"}]}`), 0o600))
+	_, err = Load(path)
+	assert.ErrorContains(t, err, `tool "bad" has unknown failure "explode"`)
+}
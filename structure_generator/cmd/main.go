@@ -37,6 +37,7 @@ type ServerConfig struct {
 	Command string            `json:"command"`
 	Args    []string          `json:"args"`
 	Env     map[string]string `json:"env,omitempty"`
+	Group   string            `json:"group,omitempty"` // e.g. "productivity" or "work/devops"
 }
 
 func main() {
@@ -129,11 +130,15 @@ func main() {
 	fmt.Printf("%s/\n", *outputDir)
 	fmt.Println("├── root.json")
 	for i, server := range servers {
+		serverDir := server.ServerName
+		if group := strings.Trim(server.Group, "/"); group != "" {
+			serverDir = group + "/" + serverDir
+		}
 		if i == len(servers)-1 {
-			fmt.Printf("└── %s/\n", server.ServerName)
+			fmt.Printf("└── %s/\n", serverDir)
 			fmt.Printf("    └── %s.json (%d tools)\n", server.ServerName, len(server.Tools))
 		} else {
-			fmt.Printf("├── %s/\n", server.ServerName)
+			fmt.Printf("├── %s/\n", serverDir)
 			fmt.Printf("│   └── %s.json (%d tools)\n", server.ServerName, len(server.Tools))
 		}
 	}
@@ -171,6 +176,7 @@ func fetchFromConfig(configPath string) ([]generator.ServerTools, error) {
 			continue
 		}
 
+		serverTools.Group = serverConfig.Group
 		allServers = append(allServers, serverTools)
 		log.Printf("✓ Fetched %d tools from %s", len(serverTools.Tools), serverName)
 	}
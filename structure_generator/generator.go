@@ -86,12 +86,13 @@ func Regenerate(outputDir string) error {
 	}
 
 	// Create overview text in the format: "Root: N servers, M tools; server1 -> desc1, server2 -> desc2"
+	// With groups, the children listed are the top-level groups rather than servers
 	var overview string
 	if len(childSummaries) == 0 {
 		overview = "MCP Proxy - Hierarchical tool organization system. Use get_tools_in_category to explore available categories and execute_tool to run tools."
 	} else {
 		overview = fmt.Sprintf("Root: %d servers, %d tools; %s",
-			len(childSummaries), totalTools, joinWithCommas(childSummaries))
+			countServers(outputDir), totalTools, joinWithCommas(childSummaries))
 	}
 
 	// Create root node - branch node with overview only
@@ -330,6 +331,29 @@ func countTotalTools(dirPath string) int {
 	return total
 }
 
+// countServers counts the distinct servers providing the tools in a directory tree
+func countServers(dirPath string) int {
+	servers := make(map[string]bool)
+	_ = filepath.WalkDir(dirPath, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		var node ToolNode
+		if err := json.Unmarshal(data, &node); err != nil {
+			return nil
+		}
+		for _, tool := range node.Tools {
+			servers[tool.Server] = true
+		}
+		return nil
+	})
+	return len(servers)
+}
+
 // joinWithCommas joins strings with commas
 func joinWithCommas(items []string) string {
 	if len(items) == 0 {
@@ -376,8 +400,15 @@ func extractBriefDescription(text string) string {
 // generateServerStructure creates the folder and JSON file for a single server
 // New structure: server_name/server_name.json (parent) + server_name/tool_name/tool_name.json (children)
 func generateServerStructure(server ServerTools, outputDir string) error {
-	// Create server directory: structure/server_name/
-	serverDir := filepath.Join(outputDir, server.ServerName)
+	nodePath, err := serverPath(server)
+	if err != nil {
+		return err
+	}
+
+	// Create server directory: structure/server_name/, or
+	// structure/group/server_name/ for a grouped server. The group's own JSON
+	// files are written by Regenerate.
+	serverDir := filepath.Join(outputDir, filepath.FromSlash(nodePath))
 	if err := os.MkdirAll(serverDir, 0755); err != nil {
 		return fmt.Errorf("failed to create server directory: %w", err)
 	}
@@ -386,7 +417,7 @@ func generateServerStructure(server ServerTools, outputDir string) error {
 	var childSummaries []string
 	for _, tool := range server.Tools {
 		// Generate tool file (leaf node) in flat structure
-		if err := generateToolFile(tool, serverDir, server.ServerName); err != nil {
+		if err := generateToolFile(tool, serverDir, nodePath, server.ServerName); err != nil {
 			return fmt.Errorf("failed to generate tool file for %s: %w", tool.Name, err)
 		}
 
@@ -411,7 +442,7 @@ func generateServerStructure(server ServerTools, outputDir string) error {
 
 	// Create server-level ToolNode (branch node)
 	serverNode := ToolNode{
-		Path:     server.ServerName,
+		Path:     nodePath,
 		Overview: overview,
 		Tools:    nil, // Branch node - no direct tools
 	}
@@ -421,16 +452,32 @@ func generateServerStructure(server ServerTools, outputDir string) error {
 	return writeNodeToJSON(serverNode, jsonPath)
 }
 
+// serverPath returns the "/" separated path of a server's node: its group
+// followed by its name
+func serverPath(server ServerTools) (string, error) {
+	group := strings.Trim(server.Group, "/")
+	if group == "" {
+		return server.ServerName, nil
+	}
+	for _, segment := range strings.Split(group, "/") {
+		// Dots separate the levels of hierarchy paths, so cannot be part of a name
+		if segment == "" || strings.Contains(segment, ".") {
+			return "", fmt.Errorf("invalid group %q: levels must be non-empty and cannot contain dots", server.Group)
+		}
+	}
+	return group + "/" + server.ServerName, nil
+}
+
 // generateToolFile creates a JSON file for a single tool in flat structure
 // Structure: parent_dir/tool_name.json
 // This creates a leaf node (has tools, no overview)
-func generateToolFile(tool Tool, parentDir string, serverName string) error {
+func generateToolFile(tool Tool, parentDir string, parentPath string, serverName string) error {
 	// Flat structure: place tool.json directly in parent directory
 	jsonPath := filepath.Join(parentDir, tool.Name+".json")
 
 	// Create ToolNode for this tool (leaf node - no overview, only tools)
 	toolNode := ToolNode{
-		Path:     filepath.Join(serverName, tool.Name),
+		Path:     parentPath + "/" + tool.Name,
 		Overview: "", // Leaf nodes don't have overview
 		Tools: map[string]ToolDefinition{
 			tool.Name: {
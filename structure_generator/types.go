@@ -16,6 +16,7 @@ type Tool struct {
 // ServerTools represents all tools from a single MCP server
 type ServerTools struct {
 	ServerName string `json:"serverName"`
+	Group      string `json:"group,omitempty"` // Optional: "/" separated category to nest the server under
 	Tools      []Tool `json:"tools"`
 }
 